@@ -0,0 +1,72 @@
+package netconf
+
+import (
+	"errors"
+	"log"
+	"runtime/debug"
+
+	"github.com/nemith/netconf/transport"
+)
+
+// ErrorHandler is called with errors that happen in the background of a
+// session that can't be returned to a caller (i.e errors reading incoming
+// messages or a panic in a NotificationHandler).
+type ErrorHandler func(err error)
+
+type errorHandlerOpt ErrorHandler
+
+func (o errorHandlerOpt) apply(cfg *sessionConfig) {
+	cfg.errorHandler = ErrorHandler(o)
+}
+
+// WithErrorHandler sets the handler for background errors on the session.
+// By default these errors are logged with the standard library logger along
+// with the stack of panics.
+func WithErrorHandler(eh ErrorHandler) SessionOption {
+	return errorHandlerOpt(eh)
+}
+
+func defaultErrorHandler(err error) {
+	var panicErr *PanicError
+	if errors.As(err, &panicErr) && len(panicErr.Stack) > 0 {
+		log.Printf("netconf: %v\n%s", err, panicErr.Stack)
+		return
+	}
+	log.Printf("netconf: %v", err)
+}
+
+// PanicError is the error returned (and sent to the [ErrorHandler]) when a
+// user provided callback panics.  Callbacks are always called with a
+// recovery wrapper so a single bad callback can't bring down a process
+// managing many sessions.
+type PanicError = transport.PanicError
+
+// handleError passes an error to the session's error handler.  A panicking
+// error handler falls back to the default handler.
+func (s *Session) handleError(err error) {
+	eh := s.errorHandler
+	if eh == nil {
+		eh = defaultErrorHandler
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			defaultErrorHandler(&PanicError{Callback: "error handler", Value: r, Stack: debug.Stack()})
+			defaultErrorHandler(err)
+		}
+	}()
+	eh(err)
+}
+
+// safeCall calls fn recovering from any panics.  A panic is converted to a
+// PanicError which is sent to the error handler and returned.
+func (s *Session) safeCall(callback string, fn func()) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &PanicError{Callback: callback, Value: r, Stack: debug.Stack()}
+			s.handleError(err)
+		}
+	}()
+	fn()
+	return nil
+}
@@ -0,0 +1,79 @@
+package netconf
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testNotification = `<notification xmlns="urn:ietf:params:xml:ns:netconf:notification:1.0"><eventTime>2023-06-07T18:31:48Z</eventTime><event xmlns="urn:example:event"/></notification>`
+
+func TestNotificationHandlerPanic(t *testing.T) {
+	var errs []error
+	tr := newTestServer(t).transport()
+	sess := newSession(tr,
+		WithNotificationHandler(func(Notification) { panic("oops") }),
+		WithErrorHandler(func(err error) { errs = append(errs, err) }),
+	)
+
+	tr.pushMsg(testNotification)
+	err := sess.recvMsg()
+	assert.NoError(t, err)
+
+	require.Len(t, errs, 1)
+	var panicErr *PanicError
+	require.ErrorAs(t, errs[0], &panicErr)
+	assert.Equal(t, "notification handler", panicErr.Callback)
+	assert.Equal(t, "oops", panicErr.Value)
+	assert.NotEmpty(t, panicErr.Stack)
+}
+
+func TestInterceptorPanic(t *testing.T) {
+	errBoom := errors.New("boom")
+	var handled error
+	sess := newSession(newTestServer(t).transport(),
+		WithInterceptor(func(context.Context, *RPCInfo, any, Invoker) (*Reply, error) {
+			panic(errBoom)
+		}),
+		WithErrorHandler(func(err error) { handled = err }),
+	)
+
+	_, err := sess.Do(context.Background(), &LockReq{})
+	assert.ErrorIs(t, err, errBoom)
+	assert.Equal(t, err, handled)
+	// the stack is only kept in the error.
+	assert.Equal(t, "panic in interceptor: boom", err.Error())
+}
+
+func TestInterceptorInvokerPanic(t *testing.T) {
+	var handled []error
+	pass := func(ctx context.Context, info *RPCInfo, req any, next Invoker) (*Reply, error) {
+		return next(ctx, req)
+	}
+	sess := newSession(newTestServer(t).transport(),
+		WithInterceptor(pass, pass),
+		WithErrorHandler(func(err error) { handled = append(handled, err) }),
+	)
+
+	// a panic past the interceptors isn't reported as an interceptor panic.
+	invoke := sess.chainInterceptors(&RPCInfo{Session: sess}, func(context.Context, any) (*Reply, error) {
+		panic("boom")
+	})
+	assert.PanicsWithValue(t, "boom", func() {
+		_, _ = invoke(context.Background(), &LockReq{})
+	})
+	assert.Empty(t, handled)
+}
+
+func TestErrorHandlerPanic(t *testing.T) {
+	sess := newSession(newTestServer(t).transport(),
+		WithErrorHandler(func(err error) { panic("error handler broke") }),
+	)
+
+	assert.NotPanics(t, func() {
+		sess.handleError(errors.New("some error"))
+	})
+}
@@ -0,0 +1,1210 @@
+package netconf
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/nemith/netconf/clock"
+	"github.com/nemith/netconf/transport"
+	"github.com/nemith/netconf/transport/ratelimit"
+	ncssh "github.com/nemith/netconf/transport/ssh"
+	nctls "github.com/nemith/netconf/transport/tls"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// CallHomeSSHPort is the IANA assigned port for NETCONF call home over
+	// SSH (RFC8071).
+	CallHomeSSHPort = 4334
+
+	// CallHomeTLSPort is the IANA assigned port for NETCONF call home over
+	// TLS (RFC8071).
+	CallHomeTLSPort = 4335
+)
+
+var (
+	// ErrNoClientConfig is returned when a device calls home from an address
+	// without a config.
+	ErrNoClientConfig = errors.New("netconf: no call home config for client")
+
+	// ErrUnknownClient is returned when a device whose host key or
+	// certificate isn't known is rejected (see [WithCallHomeCertToName] and
+	// [CallHomeIdentifyFunc]).
+	ErrUnknownClient = errors.New("netconf: unknown call home client")
+
+	// ErrUnauthorized is returned when a device is rejected by
+	// [WithCallHomeAuthorize], [WithCallHomeCertToName] or a
+	// [CallHomeIdentifyFunc].
+	ErrUnauthorized = errors.New("netconf: call home client not authorized")
+
+	// ErrTransportHandshake is returned when the ssh or TLS handshake with a
+	// device calling home fails.
+	ErrTransportHandshake = errors.New("netconf: call home transport handshake failed")
+
+	// ErrSessionOpen is returned when the hello exchange with a device calling
+	// home fails.
+	ErrSessionOpen = errors.New("netconf: failed to open call home session")
+
+	// ErrCallHomeServerClosed is returned from [CallHomeServer.Listen] after
+	// the server has been shut down.
+	ErrCallHomeServerClosed = errors.New("netconf: call home server closed")
+)
+
+// CallHomeTransport establishes the transport over a connection opened by a
+// device calling home.
+type CallHomeTransport interface {
+	DoHandshake(ctx context.Context, conn net.Conn) (transport.Transport, error)
+}
+
+// SSHCallHomeTransport establishes an ssh transport with the device as the ssh
+// server.
+type SSHCallHomeTransport struct {
+	Config  *ssh.ClientConfig
+	Options []ncssh.DialOption
+}
+
+// DoHandshake implements CallHomeTransport.
+func (t *SSHCallHomeTransport) DoHandshake(ctx context.Context, conn net.Conn) (transport.Transport, error) {
+	return ncssh.Handshake(ctx, conn, t.Config, t.Options...)
+}
+
+// TLSCallHomeTransport establishes a TLS transport with the device as the TLS
+// server.  If the config doesn't set a ServerName the certificate of the
+// device is verified against its IP address.
+type TLSCallHomeTransport struct {
+	Config  *tls.Config
+	Options []nctls.DialOption
+
+	// VerifyPeer, if set, verifies the certificate of the device instead of
+	// matching it against the ServerName or IP address, which devices calling
+	// home rarely have in their certificates.  The chain is still verified
+	// against Config.RootCAs and the verified chain (leaf first) is passed to
+	// VerifyPeer.  If Config.InsecureSkipVerify is set the chain isn't
+	// verified and the certificates are passed as presented by the device
+	// (i.e to pin self-signed certificates by their fingerprint).
+	//
+	// Config.VerifyConnection, if set, is still called after VerifyPeer.
+	VerifyPeer func(chain []*x509.Certificate) error
+}
+
+// DoHandshake implements CallHomeTransport.
+func (t *TLSCallHomeTransport) DoHandshake(ctx context.Context, conn net.Conn) (transport.Transport, error) {
+	config := t.Config
+	if config == nil {
+		config = &tls.Config{}
+	}
+	config = config.Clone()
+	if config.ServerName == "" {
+		config.ServerName = remoteHost(conn)
+	}
+
+	var verified []*x509.Certificate
+	if t.VerifyPeer != nil {
+		roots, skipVerify := config.RootCAs, config.InsecureSkipVerify
+		next := config.VerifyConnection
+		config.InsecureSkipVerify = true
+		config.VerifyConnection = func(cs tls.ConnectionState) error {
+			chain := cs.PeerCertificates
+			if skipVerify {
+				if len(chain) == 0 {
+					return errors.New("tls: no certificate presented")
+				}
+			} else {
+				var err error
+				if chain, err = verifyChain(chain, roots); err != nil {
+					return err
+				}
+				verified = chain
+			}
+			if err := t.VerifyPeer(chain); err != nil {
+				return err
+			}
+			if next != nil {
+				return next(cs)
+			}
+			return nil
+		}
+	}
+
+	tlsConn := tls.Client(conn, config)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		tlsConn.Close()
+		return nil, err
+	}
+
+	tr := nctls.NewTransport(tlsConn, t.Options...)
+	if verified != nil {
+		return &verifiedTLSTransport{Transport: tr, chain: verified}, nil
+	}
+	return tr, nil
+}
+
+// verifyChain verifies the certificates presented by a server against roots
+// (or the system roots if nil) without checking the name.
+func verifyChain(certs []*x509.Certificate, roots *x509.CertPool) ([]*x509.Certificate, error) {
+	if len(certs) == 0 {
+		return nil, errors.New("tls: no certificate presented")
+	}
+	opts := x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: x509.NewCertPool(),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, cert := range certs[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	chains, err := certs[0].Verify(opts)
+	if err != nil {
+		return nil, err
+	}
+	return chains[0], nil
+}
+
+// verifiedTLSTransport reports the chain verified with
+// [TLSCallHomeTransport.VerifyPeer] as the tls package only knows the
+// certificates presented when skipping its own verification.
+type verifiedTLSTransport struct {
+	*nctls.Transport
+	chain []*x509.Certificate
+}
+
+func (t *verifiedTLSTransport) PeerCertificates() ([]*x509.Certificate, error) {
+	return t.chain, nil
+}
+
+// remoteHost returns the host (without the port) of the remote address of
+// conn.
+func remoteHost(conn net.Conn) string {
+	addr := conn.RemoteAddr().String()
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+// CallHomeClientConfig is how sessions are set up with a device calling home.
+type CallHomeClientConfig struct {
+	Transport CallHomeTransport
+	Options   []SessionOption
+}
+
+// CallHomeIdentity is what a device calling home presented during the
+// transport handshake.  RFC8071 devices are identified by their ssh host key
+// or TLS certificate rather than the address they connect from.
+type CallHomeIdentity struct {
+	// RemoteAddr is the address the device called home from.
+	RemoteAddr net.Addr
+
+	// HostKey is the ssh host key of the device.  It is nil for other
+	// transports.
+	HostKey ssh.PublicKey
+
+	// Certificates is the TLS certificate chain of the device with the leaf
+	// certificate first.  It is empty for other transports.
+	Certificates []*x509.Certificate
+
+	// Name is the name the certificate chain was mapped to with
+	// [WithCallHomeCertToName].
+	Name string
+}
+
+// Fingerprint returns the SHA-256 fingerprint of the host key (in the format
+// of [ssh.FingerprintSHA256]) or of the leaf certificate (in the format of
+// [nctls.Fingerprint]).  It is empty if the device presented neither.
+func (id *CallHomeIdentity) Fingerprint() string {
+	switch {
+	case id.HostKey != nil:
+		return ssh.FingerprintSHA256(id.HostKey)
+	case len(id.Certificates) > 0:
+		return nctls.Fingerprint(id.Certificates[0])
+	}
+	return ""
+}
+
+// ID identifies the device across connections: it is the name mapped from
+// its certificate, the fingerprint of its host key or certificate or, if it
+// presented neither, its IP address.  The ID is prefixed with its kind (i.e
+// `name:`, `ssh:`, `tls:` or `addr:`) so a name can't collide with an address
+// and a host key with a certificate.
+func (id *CallHomeIdentity) ID() string {
+	switch {
+	case id.Name != "":
+		return "name:" + id.Name
+	case id.HostKey != nil:
+		return "ssh:" + ssh.FingerprintSHA256(id.HostKey)
+	case len(id.Certificates) > 0:
+		return "tls:" + nctls.Fingerprint(id.Certificates[0])
+	}
+	host := id.RemoteAddr.String()
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return "addr:" + host
+}
+
+// callHomeIdentity returns the identity presented on tr.
+func callHomeIdentity(tr transport.Transport, addr net.Addr) (*CallHomeIdentity, error) {
+	id := &CallHomeIdentity{RemoteAddr: addr}
+	if t, ok := tr.(interface{ HostKey() ssh.PublicKey }); ok {
+		id.HostKey = t.HostKey()
+	}
+	if t, ok := tr.(interface {
+		PeerCertificates() ([]*x509.Certificate, error)
+	}); ok {
+		certs, err := t.PeerCertificates()
+		if err != nil {
+			return nil, err
+		}
+		id.Certificates = certs
+	}
+	return id, nil
+}
+
+// CallHomeClient is a device that called home and has an open session.
+type CallHomeClient struct {
+	session    *Session
+	config     *CallHomeClientConfig
+	remoteAddr net.Addr
+	identity   *CallHomeIdentity
+}
+
+// Session returns the session with the device.
+func (c *CallHomeClient) Session() *Session { return c.session }
+
+// Config returns the config used to set up the session.
+func (c *CallHomeClient) Config() *CallHomeClientConfig { return c.config }
+
+// RemoteAddr returns the address the device called home from.
+func (c *CallHomeClient) RemoteAddr() net.Addr { return c.remoteAddr }
+
+// Identity returns the host key or certificate the device presented.
+func (c *CallHomeClient) Identity() *CallHomeIdentity { return c.identity }
+
+// ClientError is an error setting up a session with a device that called
+// home.  Err can be checked with errors.Is for the cause: [ErrNoClientConfig],
+// [ErrUnauthorized], [ErrTransportHandshake], [ErrSessionOpen] or
+// [ErrCallHomeLimit].
+type ClientError struct {
+	// Address is the address the device called home from.
+	Address string
+
+	// Identity is what the device presented during the transport handshake.
+	// It is nil if the error happened before the handshake completed.
+	Identity *CallHomeIdentity
+
+	Err error
+}
+
+func (e *ClientError) Error() string {
+	if e.Identity != nil {
+		return fmt.Sprintf("netconf: call home from %s (%s): %v", e.Address, e.Identity.ID(), e.Err)
+	}
+	return fmt.Sprintf("netconf: call home from %s: %v", e.Address, e.Err)
+}
+
+func (e *ClientError) Unwrap() error { return e.Err }
+
+// CallHomeOption is an optional argument to [NewCallHomeServer].
+type CallHomeOption interface {
+	apply(*callHomeConfig)
+}
+
+type callHomeConfig struct {
+	network          string
+	addr             string
+	listeners        []callHomeListener
+	clients          map[netip.Addr]*CallHomeClientConfig
+	prefixes         []callHomePrefix
+	configFunc       CallHomeConfigFunc
+	authorize        CallHomeAuthorizeFunc
+	identifyFunc     CallHomeIdentifyFunc
+	handler          CallHomeHandler
+	tls              *TLSCallHomeTransport
+	certToName       []nctls.CertToName
+	certConfigs      map[string]*CallHomeClientConfig
+	keepalive        time.Duration
+	healthCheck      HealthCheck
+	reconnect        bool
+	reconnectFunc    CallHomeReconnectFunc
+	metrics          CallHomeMetrics
+	logger           *slog.Logger
+	maxSessions      int
+	maxPerSource     int
+	acceptRate       float64
+	acceptBurst      int
+	handshakeTimeout time.Duration
+	drain            bool
+	clock            clock.Clock
+	eventBus         *EventBus
+}
+
+type callHomeAddrOpt struct{ network, addr string }
+
+func (o callHomeAddrOpt) apply(cfg *callHomeConfig) { cfg.network, cfg.addr = o.network, o.addr }
+
+// WithCallHomeAddress sets the address listened on.  The default is tcp on
+// port 4334 ([CallHomeSSHPort]) of all addresses or port 4335
+// ([CallHomeTLSPort]) with [WithCallHomeTLS].
+func WithCallHomeAddress(network, addr string) CallHomeOption {
+	return callHomeAddrOpt{network, addr}
+}
+
+type callHomeListener struct {
+	network, addr string
+	transport     CallHomeTransport
+}
+
+func (o callHomeListener) apply(cfg *callHomeConfig) { cfg.listeners = append(cfg.listeners, o) }
+
+// WithCallHomeListener adds a listener on addr.  It can be given multiple
+// times to listen on several ports (i.e [CallHomeSSHPort] for ssh and
+// [CallHomeTLSPort] for TLS) or on IPv4 and IPv6 separately ("tcp4" and
+// "tcp6").  Once a listener is added the address from [WithCallHomeAddress]
+// and [WithCallHomeTLS] are no longer listened on.
+//
+// t, if not nil, is the transport for devices calling home on this listener
+// whose config has no Transport as well as for devices without a config,
+// which can then be identified after the handshake (see
+// [WithCallHomeIdentify] and [WithCallHomeCertToName]).
+func WithCallHomeListener(network, addr string, t CallHomeTransport) CallHomeOption {
+	return callHomeListener{network, addr, t}
+}
+
+type callHomeClientOpt struct {
+	addr netip.Addr
+	cfg  *CallHomeClientConfig
+}
+
+func (o callHomeClientOpt) apply(cfg *callHomeConfig) {
+	if cfg.clients == nil {
+		cfg.clients = make(map[netip.Addr]*CallHomeClientConfig)
+	}
+	cfg.clients[o.addr] = o.cfg
+}
+
+// WithCallHomeClientConfig sets the config for devices calling home from the
+// IP address ip.  Connections from addresses without a config are closed
+// and reported with [ErrNoClientConfig].  It panics if ip is not a valid IP
+// address.
+func WithCallHomeClientConfig(ip string, config *CallHomeClientConfig) CallHomeOption {
+	return callHomeClientOpt{netip.MustParseAddr(ip).Unmap(), config}
+}
+
+type callHomePrefix struct {
+	prefix netip.Prefix
+	cfg    *CallHomeClientConfig
+}
+
+func (o callHomePrefix) apply(cfg *callHomeConfig) {
+	o.prefix = o.prefix.Masked()
+	if o.prefix.Addr().Is4In6() {
+		o.prefix = netip.PrefixFrom(o.prefix.Addr().Unmap(), o.prefix.Bits()-96)
+	}
+	cfg.prefixes = append(cfg.prefixes, o)
+}
+
+// WithCallHomeClientPrefix sets the config for devices calling home from any
+// address in prefix (i.e a management subnet).  A config for the exact
+// address given with [WithCallHomeClientConfig] takes precedence and
+// otherwise the longest matching prefix is used.
+func WithCallHomeClientPrefix(prefix netip.Prefix, config *CallHomeClientConfig) CallHomeOption {
+	return callHomePrefix{prefix: prefix, cfg: config}
+}
+
+// CallHomeConfigFunc returns the config for a device that called home on conn
+// (i.e looked up from an inventory system).  It returns nil without an error
+// if there is no config for the device.
+type CallHomeConfigFunc func(ctx context.Context, conn net.Conn) (*CallHomeClientConfig, error)
+
+type callHomeConfigFuncOpt CallHomeConfigFunc
+
+func (o callHomeConfigFuncOpt) apply(cfg *callHomeConfig) { cfg.configFunc = CallHomeConfigFunc(o) }
+
+// WithCallHomeConfigFunc sets a func used to look up the config of devices
+// calling home from addresses without a config given with
+// [WithCallHomeClientConfig] or [WithCallHomeClientPrefix].
+//
+// Devices behind NAT don't call home from a known address.  For those return
+// a config that doesn't depend on the address and identify the device once
+// the session is open.
+func WithCallHomeConfigFunc(fn CallHomeConfigFunc) CallHomeOption {
+	return callHomeConfigFuncOpt(fn)
+}
+
+// CallHomeAuthorizeFunc decides whether a connection from a device is served
+// at all.  It returns an error to reject it.
+type CallHomeAuthorizeFunc func(conn net.Conn) error
+
+type callHomeAuthorizeOpt CallHomeAuthorizeFunc
+
+func (o callHomeAuthorizeOpt) apply(cfg *callHomeConfig) { cfg.authorize = CallHomeAuthorizeFunc(o) }
+
+// WithCallHomeAuthorize sets a func called for every connection as soon as it
+// is accepted, before the config is looked up or any handshake is done.  This
+// rejects unwanted connections (i.e sources not on an allow list) cheaply.
+// Rejected connections are closed and reported like connections without a
+// config.
+func WithCallHomeAuthorize(fn CallHomeAuthorizeFunc) CallHomeOption {
+	return callHomeAuthorizeOpt(fn)
+}
+
+// CallHomeIdentifyFunc identifies a device once the transport handshake is
+// done and before the session is opened.  config is the config found for the
+// device so far: the one used for the handshake or the one its certificate
+// was mapped to with [WithCallHomeCertToName].
+//
+// It returns the config for the identified device of which only the session
+// options are used, or nil to keep config.  Returning an error (i.e
+// [ErrUnknownClient]) rejects the device and closes the connection.
+type CallHomeIdentifyFunc func(ctx context.Context, id *CallHomeIdentity, config *CallHomeClientConfig) (*CallHomeClientConfig, error)
+
+type callHomeIdentifyOpt CallHomeIdentifyFunc
+
+func (o callHomeIdentifyOpt) apply(cfg *callHomeConfig) { cfg.identifyFunc = CallHomeIdentifyFunc(o) }
+
+// WithCallHomeIdentify sets a func identifying devices by the host key or
+// certificate presented during the transport handshake.
+//
+// The handshake still needs a config found by address.  To identify devices
+// only by their key, give a config for all addresses (i.e with
+// [WithCallHomeClientPrefix] and 0.0.0.0/0 and ::/0) whose transport accepts
+// any host key or certificate and verify it here:
+//
+//	netconf.WithCallHomeIdentify(func(ctx context.Context, id *netconf.CallHomeIdentity, _ *netconf.CallHomeClientConfig) (*netconf.CallHomeClientConfig, error) {
+//		cfg, ok := devices[id.Fingerprint()]
+//		if !ok {
+//			return nil, netconf.ErrUnknownClient
+//		}
+//		return cfg, nil
+//	})
+func WithCallHomeIdentify(fn CallHomeIdentifyFunc) CallHomeOption {
+	return callHomeIdentifyOpt(fn)
+}
+
+// CallHomeHandler is called with the devices that called home as an
+// alternative to reading the channels of the [CallHomeServer].  The methods
+// are called on the goroutine handling the connection so a slow handler only
+// holds up its own device.
+type CallHomeHandler interface {
+	// OnSession is called with a device once its session is open.
+	OnSession(client *CallHomeClient)
+
+	// OnError is called when setting up a session with a device fails.
+	OnError(err *ClientError)
+}
+
+// CallHomeHandlerFuncs adapts funcs to a [CallHomeHandler].  Nil funcs are
+// skipped.
+type CallHomeHandlerFuncs struct {
+	Session func(client *CallHomeClient)
+	Error   func(err *ClientError)
+}
+
+// OnSession implements CallHomeHandler.
+func (h CallHomeHandlerFuncs) OnSession(client *CallHomeClient) {
+	if h.Session != nil {
+		h.Session(client)
+	}
+}
+
+// OnError implements CallHomeHandler.
+func (h CallHomeHandlerFuncs) OnError(err *ClientError) {
+	if h.Error != nil {
+		h.Error(err)
+	}
+}
+
+type callHomeHandlerOpt struct{ h CallHomeHandler }
+
+func (o callHomeHandlerOpt) apply(cfg *callHomeConfig) { cfg.handler = o.h }
+
+// WithCallHomeHandler delivers devices and failures to h instead of the
+// client and error channels, which then only get closed by
+// [CallHomeServer.Shutdown].  Shutdown doesn't wait for the handler to
+// return; sessions still open are closed (or drained) as usual.
+func WithCallHomeHandler(h CallHomeHandler) CallHomeOption {
+	return callHomeHandlerOpt{h}
+}
+
+type callHomeTLSOpt struct{ t *TLSCallHomeTransport }
+
+func (o callHomeTLSOpt) apply(cfg *callHomeConfig) { cfg.tls = o.t }
+
+// WithCallHomeTLS accepts NETCONF over TLS call home (RFC8071 section 4).
+// Unless set with [WithCallHomeAddress] port 4335 ([CallHomeTLSPort]) is
+// listened on.  t is the transport for devices without a config for their
+// address as well as for configs without a Transport, so that devices can be
+// identified by their certificate (i.e with [WithCallHomeCertToName]) instead
+// of their address.  To also accept ssh use [WithCallHomeListener] for each
+// port instead.
+func WithCallHomeTLS(t *TLSCallHomeTransport) CallHomeOption {
+	return callHomeTLSOpt{t}
+}
+
+type callHomeCertToNameOpt struct {
+	entries []nctls.CertToName
+	configs map[string]*CallHomeClientConfig
+}
+
+func (o callHomeCertToNameOpt) apply(cfg *callHomeConfig) {
+	cfg.certToName, cfg.certConfigs = o.entries, o.configs
+}
+
+// WithCallHomeCertToName identifies devices calling home over TLS by mapping
+// their certificate chain to a name with the cert-to-name entries (see
+// [nctls.MapCertToName]).  The session is opened with the options of the
+// config for the name in configs; the transport of that config is not used.
+// Devices whose certificate doesn't map to a name with a config are rejected
+// with [ErrUnknownClient].
+func WithCallHomeCertToName(entries []nctls.CertToName, configs map[string]*CallHomeClientConfig) CallHomeOption {
+	return callHomeCertToNameOpt{entries, configs}
+}
+
+type callHomeKeepaliveOpt struct {
+	interval time.Duration
+	check    HealthCheck
+}
+
+func (o callHomeKeepaliveOpt) apply(cfg *callHomeConfig) {
+	cfg.keepalive, cfg.healthCheck = o.interval, o.check
+}
+
+// WithCallHomeKeepalive checks that the devices that called home are still
+// there every interval.  Sessions failing the check (or not completing it
+// within interval) are closed and removed from the server.  A nil check uses
+// [Session.Ping].
+func WithCallHomeKeepalive(interval time.Duration, check HealthCheck) CallHomeOption {
+	return callHomeKeepaliveOpt{interval, check}
+}
+
+// CallHomeReconnectFunc is called when a device calls home again.  old is the
+// previous client of the device, whose session has been closed, and client is
+// the new one.
+type CallHomeReconnectFunc func(old, client *CallHomeClient)
+
+type callHomeReconnectOpt CallHomeReconnectFunc
+
+func (o callHomeReconnectOpt) apply(cfg *callHomeConfig) {
+	cfg.reconnect, cfg.reconnectFunc = true, CallHomeReconnectFunc(o)
+}
+
+// WithCallHomeReconnect keeps a single session per device: when a device
+// calls home again (i.e after a reboot left the old connection half open) the
+// session of its previous connection is closed and fn, if not nil, is called
+// before the new client is delivered.  Devices are recognized by
+// [CallHomeIdentity.ID].
+func WithCallHomeReconnect(fn CallHomeReconnectFunc) CallHomeOption {
+	return callHomeReconnectOpt(fn)
+}
+
+type callHomeHandshakeTimeoutOpt time.Duration
+
+func (o callHomeHandshakeTimeoutOpt) apply(cfg *callHomeConfig) {
+	cfg.handshakeTimeout = time.Duration(o)
+}
+
+// WithCallHomeHandshakeTimeout sets how long a device is given to complete
+// the transport handshake and hello exchange.  The default is 30 seconds.
+func WithCallHomeHandshakeTimeout(d time.Duration) CallHomeOption {
+	return callHomeHandshakeTimeoutOpt(d)
+}
+
+type callHomeDrainOpt struct{}
+
+func (callHomeDrainOpt) apply(cfg *callHomeConfig) { cfg.drain = true }
+
+// WithCallHomeDrain makes [CallHomeServer.Shutdown] wait for the sessions of
+// the devices that called home to be closed by their users instead of closing
+// them.
+func WithCallHomeDrain() CallHomeOption { return callHomeDrainOpt{} }
+
+type callHomeEventBusOpt struct{ b *EventBus }
+
+func (o callHomeEventBusOpt) apply(cfg *callHomeConfig) { cfg.eventBus = o.b }
+
+// WithCallHomeEventBus publishes an [EventConnected] and [EventDisconnected]
+// to b for the session of every device that called home.  Event.Detail is the
+// [*CallHomeClient].
+func WithCallHomeEventBus(b *EventBus) CallHomeOption { return callHomeEventBusOpt{b} }
+
+type callHomeClockOpt struct{ c clock.Clock }
+
+func (o callHomeClockOpt) apply(cfg *callHomeConfig) { cfg.clock = o.c }
+
+// WithCallHomeClock sets the clock used for the handshake timeout,
+// keepalives and the accept rate.  It defaults to the system clock.
+func WithCallHomeClock(c clock.Clock) CallHomeOption { return callHomeClockOpt{c} }
+
+// CallHomeServer accepts connections from devices calling home (RFC8071) and
+// opens sessions with them.  Sessions are delivered on [ClientChannel] and
+// failures on [ErrorChannel].  Both have to be read from until they are closed
+// by [CallHomeServer.Shutdown] as the connection waits for its session or
+// error to be taken.  Use [WithCallHomeHandler] to be called back instead.
+//
+//	srv := netconf.NewCallHomeServer(
+//		netconf.WithCallHomeClientConfig("192.0.2.1", &netconf.CallHomeClientConfig{
+//			Transport: &netconf.SSHCallHomeTransport{Config: sshConfig},
+//		}))
+//	go srv.Listen(ctx)
+//	for client := range srv.ClientChannel() {
+//		// use client.Session()
+//	}
+//
+// [ClientChannel]: CallHomeServer.ClientChannel
+// [ErrorChannel]: CallHomeServer.ErrorChannel
+type CallHomeServer struct {
+	cfg callHomeConfig
+
+	clientsChannel chan *CallHomeClient
+	errorChannel   chan *ClientError
+
+	// ctx is canceled by Shutdown to abort the handshakes in progress.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu        sync.Mutex
+	listeners []net.Listener
+	active    map[*CallHomeClient]struct{}
+	devices   map[string]*CallHomeClient
+	conns     int
+	sources   map[string]int
+	limiter   *ratelimit.Limiter
+	closed    bool
+	shutdown  chan struct{}
+	handlers  sync.WaitGroup
+	watchers  sync.WaitGroup
+}
+
+// NewCallHomeServer returns a new call home server.  It doesn't accept
+// connections until [CallHomeServer.Listen] is called.
+func NewCallHomeServer(opts ...CallHomeOption) *CallHomeServer {
+	cfg := callHomeConfig{
+		network:          "tcp",
+		handshakeTimeout: 30 * time.Second,
+		metrics:          nopCallHomeMetrics{},
+	}
+	for _, opt := range opts {
+		opt.apply(&cfg)
+	}
+	if len(cfg.listeners) == 0 {
+		addr := cfg.addr
+		if addr == "" {
+			port := CallHomeSSHPort
+			if cfg.tls != nil {
+				port = CallHomeTLSPort
+			}
+			addr = fmt.Sprintf(":%d", port)
+		}
+		l := callHomeListener{network: cfg.network, addr: addr}
+		if cfg.tls != nil {
+			l.transport = cfg.tls
+		}
+		cfg.listeners = []callHomeListener{l}
+	}
+	cfg.clock = clock.Or(cfg.clock)
+
+	var limiter *ratelimit.Limiter
+	if cfg.acceptRate > 0 {
+		limiter = ratelimit.NewLimiter(cfg.acceptRate, cfg.acceptBurst, cfg.clock)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &CallHomeServer{
+		cfg:            cfg,
+		clientsChannel: make(chan *CallHomeClient),
+		errorChannel:   make(chan *ClientError),
+		ctx:            ctx,
+		cancel:         cancel,
+		active:         make(map[*CallHomeClient]struct{}),
+		devices:        make(map[string]*CallHomeClient),
+		sources:        make(map[string]int),
+		limiter:        limiter,
+		shutdown:       make(chan struct{}),
+	}
+}
+
+// ClientChannel returns the channel the devices that called home are
+// delivered on.  It is closed by [CallHomeServer.Shutdown].
+func (s *CallHomeServer) ClientChannel() <-chan *CallHomeClient { return s.clientsChannel }
+
+// ErrorChannel returns the channel failures to set up sessions with devices
+// calling home are delivered on.  It is closed by [CallHomeServer.Shutdown].
+func (s *CallHomeServer) ErrorChannel() <-chan *ClientError { return s.errorChannel }
+
+// Addr returns the address of the first listener or nil if the server isn't
+// listening.
+func (s *CallHomeServer) Addr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.listeners) == 0 {
+		return nil
+	}
+	return s.listeners[0].Addr()
+}
+
+// Addrs returns the addresses being listened on in the order the listeners
+// were given.
+func (s *CallHomeServer) Addrs() []net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	addrs := make([]net.Addr, len(s.listeners))
+	for i, ln := range s.listeners {
+		addrs[i] = ln.Addr()
+	}
+	return addrs
+}
+
+// Listen accepts connections from devices on all listeners until ctx is done
+// or the server is shut down.  When ctx is done it stops accepting and
+// returns the context's error; sessions already established are kept until
+// [CallHomeServer.Shutdown].  After Shutdown it returns
+// [ErrCallHomeServerClosed].  If any listener fails the others are stopped
+// and its error is returned.
+func (s *CallHomeServer) Listen(ctx context.Context) error {
+	var lc net.ListenConfig
+	lns := make([]net.Listener, 0, len(s.cfg.listeners))
+	closeAll := func() {
+		for _, ln := range lns {
+			ln.Close()
+		}
+	}
+	for _, l := range s.cfg.listeners {
+		ln, err := lc.Listen(ctx, l.network, l.addr)
+		if err != nil {
+			closeAll()
+			return err
+		}
+		lns = append(lns, ln)
+	}
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		closeAll()
+		return ErrCallHomeServerClosed
+	}
+	s.listeners = append(s.listeners, lns...)
+	s.mu.Unlock()
+
+	// the first listener to return stops the others.
+	acceptCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	errs := make(chan error, len(lns))
+	for i, ln := range lns {
+		go func(ln net.Listener, def CallHomeTransport) {
+			defer s.removeListener(ln)
+			err := s.accept(acceptCtx, ln, def)
+			cancel()
+			errs <- err
+		}(ln, s.cfg.listeners[i].transport)
+	}
+
+	err := <-errs
+	for range lns[1:] {
+		<-errs
+	}
+	s.mu.Lock()
+	closed := s.closed
+	s.mu.Unlock()
+	if closed {
+		return ErrCallHomeServerClosed
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// accept accepts connections on ln until ctx is done or the server is shut
+// down.  Connections are set up with def if the device has no config or its
+// config has no transport.
+func (s *CallHomeServer) accept(ctx context.Context, ln net.Listener, def CallHomeTransport) error {
+	stop := context.AfterFunc(ctx, func() { ln.Close() })
+	defer stop()
+
+	for {
+		if err := s.waitAccept(ctx); err != nil {
+			return err
+		}
+
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return ErrCallHomeServerClosed
+		}
+		s.handlers.Add(1)
+		s.mu.Unlock()
+
+		go s.serveConn(conn, def)
+	}
+}
+
+func (s *CallHomeServer) removeListener(ln net.Listener) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, l := range s.listeners {
+		if l == ln {
+			s.listeners = append(s.listeners[:i], s.listeners[i+1:]...)
+			break
+		}
+	}
+}
+
+// clientConfig returns the config for the device that called home on conn.
+func (s *CallHomeServer) clientConfig(ctx context.Context, conn net.Conn) (*CallHomeClientConfig, error) {
+	if addrPort, err := netip.ParseAddrPort(conn.RemoteAddr().String()); err == nil {
+		addr := addrPort.Addr().Unmap()
+		if cfg, ok := s.cfg.clients[addr]; ok {
+			return cfg, nil
+		}
+
+		var best *callHomePrefix
+		for i, p := range s.cfg.prefixes {
+			if p.prefix.Contains(addr) && (best == nil || p.prefix.Bits() > best.prefix.Bits()) {
+				best = &s.cfg.prefixes[i]
+			}
+		}
+		if best != nil {
+			return best.cfg, nil
+		}
+	}
+
+	if s.cfg.configFunc != nil {
+		cfg, err := s.cfg.configFunc(ctx, conn)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up config: %w", err)
+		}
+		if cfg != nil {
+			return cfg, nil
+		}
+	}
+	return nil, ErrNoClientConfig
+}
+
+// identify returns the identity presented on tr and the config to open the
+// session with.
+func (s *CallHomeServer) identify(ctx context.Context, tr transport.Transport, addr net.Addr, config *CallHomeClientConfig) (*CallHomeIdentity, *CallHomeClientConfig, error) {
+	id, err := callHomeIdentity(tr, addr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get client identity: %w", err)
+	}
+
+	if s.cfg.certToName != nil && len(id.Certificates) > 0 {
+		name, err := nctls.MapCertToName(id.Certificates, s.cfg.certToName)
+		if err != nil && !errors.Is(err, nctls.ErrNoIdentity) {
+			return id, nil, fmt.Errorf("failed to map certificate to name: %w", err)
+		}
+		cfg, ok := s.cfg.certConfigs[name]
+		if err != nil || !ok {
+			return id, nil, fmt.Errorf("%w: %w", ErrUnauthorized, ErrUnknownClient)
+		}
+		id.Name, config = name, cfg
+	}
+
+	if s.cfg.identifyFunc == nil {
+		return id, config, nil
+	}
+
+	cfg, err := s.cfg.identifyFunc(ctx, id, config)
+	if err != nil {
+		return id, nil, fmt.Errorf("%w: %w", ErrUnauthorized, err)
+	}
+	if cfg != nil {
+		config = cfg
+	}
+	return id, config, nil
+}
+
+func (s *CallHomeServer) handleConn(conn net.Conn, def CallHomeTransport) (client *CallHomeClient, identity *CallHomeIdentity, err error) {
+	addr := conn.RemoteAddr()
+	if s.cfg.authorize != nil {
+		if err := s.cfg.authorize(conn); err != nil {
+			conn.Close()
+			return nil, nil, s.rejected(addr, fmt.Errorf("%w: %w", ErrUnauthorized, err))
+		}
+	}
+
+	release, err := s.acquire(addr)
+	if err != nil {
+		conn.Close()
+		return nil, nil, s.rejected(addr, err)
+	}
+	defer func() {
+		// the watcher releases the connection once the session is closed.
+		if client == nil {
+			release()
+		}
+	}()
+
+	ctx, cancel := clock.WithTimeout(s.ctx, s.cfg.clock, s.cfg.handshakeTimeout)
+	defer cancel()
+
+	config, err := s.clientConfig(ctx, conn)
+	if errors.Is(err, ErrNoClientConfig) && def != nil {
+		config, err = &CallHomeClientConfig{Transport: def}, nil
+	}
+	if err != nil {
+		conn.Close()
+		return nil, nil, s.rejected(addr, err)
+	}
+
+	ct := config.Transport
+	if ct == nil {
+		ct = def
+	}
+	if ct == nil {
+		conn.Close()
+		return nil, nil, s.rejected(addr, errors.New("no transport in call home config"))
+	}
+
+	tr, err := ct.DoHandshake(ctx, conn)
+	if err != nil {
+		conn.Close()
+		return nil, nil, s.handshakeFailed(addr, fmt.Errorf("%w: %w", ErrTransportHandshake, err))
+	}
+
+	identity, config, err = s.identify(ctx, tr, addr, config)
+	if err != nil {
+		tr.Close()
+		return nil, identity, s.rejected(addr, err)
+	}
+
+	// Open doesn't take a context so close the transport to abort the hello
+	// exchange if the handshake timeout expires first.
+	stop := context.AfterFunc(ctx, func() { tr.Close() })
+	sess, err := Open(tr, config.Options...)
+	if !stop() {
+		if err == nil {
+			tr.Close()
+		}
+		err = ctx.Err()
+	}
+	if err != nil {
+		return nil, identity, s.handshakeFailed(addr, fmt.Errorf("%w: %w", ErrSessionOpen, err))
+	}
+
+	client = &CallHomeClient{
+		session:    sess,
+		config:     config,
+		remoteAddr: addr,
+		identity:   identity,
+	}
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		_ = sess.Close(ctx)
+		return nil, nil, nil
+	}
+	s.active[client] = struct{}{}
+	var old *CallHomeClient
+	if s.cfg.reconnect {
+		key := identity.ID()
+		old = s.devices[key]
+		s.devices[key] = client
+	}
+	s.watchers.Add(1)
+	s.mu.Unlock()
+	s.sessionOpened(client)
+	publishLifecycle(s.cfg.eventBus, sess, client)
+
+	go func() {
+		defer s.watchers.Done()
+		s.watch(client)
+		s.mu.Lock()
+		delete(s.active, client)
+		if key := identity.ID(); s.devices[key] == client {
+			// the device didn't call home again in the meantime.
+			delete(s.devices, key)
+		}
+		s.mu.Unlock()
+		release()
+		s.sessionClosed(client)
+	}()
+
+	if old != nil {
+		s.replaced(old, client)
+	}
+	return client, identity, nil
+}
+
+// watch returns once the session of client is closed, checking that the
+// device is still there if keepalives are enabled.
+func (s *CallHomeServer) watch(client *CallHomeClient) {
+	sess := client.session
+	if s.cfg.keepalive <= 0 {
+		<-sess.Done()
+		return
+	}
+
+	check := s.cfg.healthCheck
+	if check == nil {
+		check = func(ctx context.Context, s *Session) error { return s.Ping(ctx) }
+	}
+
+	timer := s.cfg.clock.NewTimer(s.cfg.keepalive)
+	defer timer.Stop()
+	for {
+		select {
+		case <-sess.Done():
+			return
+		case <-timer.C():
+		}
+
+		ctx, cancel := clock.WithTimeout(context.Background(), s.cfg.clock, s.cfg.keepalive)
+		err := check(ctx, sess)
+		cancel()
+		if err != nil {
+			s.keepaliveFailed(client, err)
+			closeNow(sess)
+			<-sess.Done()
+			return
+		}
+		timer.Reset(s.cfg.keepalive)
+	}
+}
+
+// replaced closes the session of old, the previous client of the device
+// that called home again as client.
+func (s *CallHomeServer) replaced(old, client *CallHomeClient) {
+	closeNow(old.session)
+	if s.cfg.reconnectFunc != nil {
+		s.cfg.reconnectFunc(old, client)
+	}
+}
+
+// closeNow closes the session without waiting for the device to reply to the
+// close-session as it may be gone.
+func closeNow(sess *Session) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	// Close with a done context still closes the transport.
+	_ = sess.Close(ctx)
+}
+
+// serveConn sets up a session with the device on conn and delivers it (or
+// the error) to the handler or the channels.
+func (s *CallHomeServer) serveConn(conn net.Conn, def CallHomeTransport) {
+	addr := conn.RemoteAddr()
+	s.connectionAccepted(addr)
+	client, identity, err := s.handleConn(conn, def)
+
+	if h := s.cfg.handler; h != nil {
+		// Shutdown doesn't wait for the handler.
+		s.handlers.Done()
+		switch {
+		case err != nil:
+			h.OnError(&ClientError{Address: addr.String(), Identity: identity, Err: err})
+		case client != nil:
+			h.OnSession(client)
+		}
+		return
+	}
+
+	defer s.handlers.Done()
+	switch {
+	case err != nil:
+		select {
+		case s.errorChannel <- &ClientError{Address: addr.String(), Identity: identity, Err: err}:
+		case <-s.shutdown:
+		}
+	case client != nil:
+		select {
+		case s.clientsChannel <- client:
+		case <-s.shutdown:
+			// the session is closed (or drained) by Shutdown.
+		}
+	}
+}
+
+// ActiveClients returns the number of devices that called home whose session
+// is still open.
+func (s *CallHomeServer) ActiveClients() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.active)
+}
+
+// Shutdown stops accepting connections, aborts the handshakes in progress and
+// closes the sessions of the devices that called home with
+// [Session.Shutdown] (or, with [WithCallHomeDrain], waits for them to be
+// closed).  Once done the client and error channels are closed.
+//
+// If ctx is done first the remaining sessions are closed and the context's
+// error is returned.  Calling Shutdown again returns
+// [ErrCallHomeServerClosed].
+func (s *CallHomeServer) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return ErrCallHomeServerClosed
+	}
+	s.closed = true
+	for _, ln := range s.listeners {
+		ln.Close()
+	}
+	s.mu.Unlock()
+	close(s.shutdown)
+	s.cancel()
+
+	// no new sessions are added once the handlers are done.
+	s.handlers.Wait()
+
+	s.mu.Lock()
+	clients := make([]*CallHomeClient, 0, len(s.active))
+	for c := range s.active {
+		clients = append(clients, c)
+	}
+	s.mu.Unlock()
+
+	if !s.cfg.drain {
+		var wg sync.WaitGroup
+		for _, c := range clients {
+			wg.Add(1)
+			go func(c *CallHomeClient) {
+				defer wg.Done()
+				_ = c.session.Shutdown(ctx)
+			}(c)
+		}
+		wg.Wait()
+	}
+
+	watched := make(chan struct{})
+	go func() {
+		s.watchers.Wait()
+		close(watched)
+	}()
+
+	var err error
+	select {
+	case <-watched:
+	case <-ctx.Done():
+		err = ctx.Err()
+		for _, c := range clients {
+			// Close with a done context still closes the transport.
+			_ = c.session.Close(ctx)
+		}
+		<-watched
+	}
+
+	close(s.clientsChannel)
+	close(s.errorChannel)
+	return err
+}
@@ -0,0 +1,249 @@
+package netconf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"time"
+
+	"github.com/nemith/netconf/clock"
+	"github.com/nemith/netconf/transport"
+)
+
+// CallHomeConnFunc serves a connection to a collector opened by a
+// [CallHomeDialer].  The device is the ssh or TLS server on conn and runs its
+// NETCONF server until the session ends.  conn is closed once it returns or
+// when ctx is done.
+type CallHomeConnFunc func(ctx context.Context, conn net.Conn) error
+
+// CallHomeDialerOption is an optional argument to [NewCallHomeDialer].
+type CallHomeDialerOption interface {
+	apply(*callHomeDialerConfig)
+}
+
+type callHomeDialerConfig struct {
+	network    string
+	dialer     transport.ContextDialer
+	port       int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	period     time.Duration
+	onError    func(addr string, err error)
+	clock      clock.Clock
+}
+
+type callHomeNetDialerOpt struct{ d transport.ContextDialer }
+
+func (o callHomeNetDialerOpt) apply(cfg *callHomeDialerConfig) { cfg.dialer = o.d }
+
+// WithCallHomeNetDialer sets the dialer used to connect to the collectors.
+// The default is a net.Dialer with a 30 second timeout.
+func WithCallHomeNetDialer(d transport.ContextDialer) CallHomeDialerOption {
+	return callHomeNetDialerOpt{d}
+}
+
+type callHomePortOpt int
+
+func (o callHomePortOpt) apply(cfg *callHomeDialerConfig) { cfg.port = int(o) }
+
+// WithCallHomePort sets the port used for collector addresses without one.
+// The default is 4334 ([CallHomeSSHPort]).
+func WithCallHomePort(port int) CallHomeDialerOption { return callHomePortOpt(port) }
+
+type callHomeBackoffOpt struct{ min, max time.Duration }
+
+func (o callHomeBackoffOpt) apply(cfg *callHomeDialerConfig) {
+	cfg.minBackoff, cfg.maxBackoff = o.min, o.max
+}
+
+// WithCallHomeBackoff sets how long to wait before trying the collectors
+// again after none could be reached or the connection failed.  The wait
+// starts at min and doubles up to max with some random jitter so that devices
+// restarting together don't all call home at once.  The default is 1 second
+// to 2 minutes.
+func WithCallHomeBackoff(min, max time.Duration) CallHomeDialerOption {
+	return callHomeBackoffOpt{min, max}
+}
+
+type callHomePeriodicOpt time.Duration
+
+func (o callHomePeriodicOpt) apply(cfg *callHomeDialerConfig) { cfg.period = time.Duration(o) }
+
+// WithCallHomePeriodic calls home every period instead of keeping a
+// persistent connection (the "periodic" connection type of RFC8071).  The
+// next connection is made period after the previous one ended.
+func WithCallHomePeriodic(period time.Duration) CallHomeDialerOption {
+	return callHomePeriodicOpt(period)
+}
+
+type callHomeDialErrorOpt func(addr string, err error)
+
+func (o callHomeDialErrorOpt) apply(cfg *callHomeDialerConfig) { cfg.onError = o }
+
+// WithCallHomeDialErrors calls fn with the collector address and the error
+// when connecting to a collector or serving the connection fails.
+func WithCallHomeDialErrors(fn func(addr string, err error)) CallHomeDialerOption {
+	return callHomeDialErrorOpt(fn)
+}
+
+type callHomeDialerClockOpt struct{ c clock.Clock }
+
+func (o callHomeDialerClockOpt) apply(cfg *callHomeDialerConfig) { cfg.clock = o.c }
+
+// WithCallHomeDialerClock sets the clock used to wait between connections.
+// It defaults to the system clock.
+func WithCallHomeDialerClock(c clock.Clock) CallHomeDialerOption {
+	return callHomeDialerClockOpt{c}
+}
+
+// CallHomeDialer is the device side of call home (RFC8071): it connects to
+// collectors (NETCONF clients) and hands the connection to the NETCONF server
+// of the device.  It can be used by server implementations or by device
+// simulators in tests.
+//
+//	d := netconf.NewCallHomeDialer([]string{"collector1.example.com", "collector2.example.com"})
+//	err := d.Run(ctx, func(ctx context.Context, conn net.Conn) error {
+//		// run the ssh server and the NETCONF subsystem on conn.
+//	})
+//
+// Collectors are tried in order until one accepts the connection.  By default
+// the connection is persistent: once it ends the collectors are called again
+// right away unless it ended sooner than the minimum backoff.
+type CallHomeDialer struct {
+	addrs []string
+	cfg   callHomeDialerConfig
+}
+
+// NewCallHomeDialer returns a dialer calling home to the collectors at addrs.
+func NewCallHomeDialer(addrs []string, opts ...CallHomeDialerOption) *CallHomeDialer {
+	cfg := callHomeDialerConfig{
+		network:    "tcp",
+		port:       CallHomeSSHPort,
+		minBackoff: time.Second,
+		maxBackoff: 2 * time.Minute,
+	}
+	for _, opt := range opts {
+		opt.apply(&cfg)
+	}
+	cfg.clock = clock.Or(cfg.clock)
+	if cfg.dialer == nil {
+		cfg.dialer = &net.Dialer{Timeout: 30 * time.Second}
+	}
+	if cfg.maxBackoff < cfg.minBackoff {
+		cfg.maxBackoff = cfg.minBackoff
+	}
+
+	d := &CallHomeDialer{cfg: cfg}
+	for _, addr := range addrs {
+		d.addrs = append(d.addrs, transport.JoinDefaultPort(addr, cfg.port))
+	}
+	return d
+}
+
+// Run calls home and serves each connection with fn until ctx is done and
+// then returns the context's error.  It returns an error right away if the
+// dialer has no collectors.
+//
+// Failing to reach any collector or fn returning an error waits for the
+// backoff before calling home again.  So does a connection ending sooner than
+// the minimum backoff, so that a collector dropping connections right away
+// isn't called in a tight loop.  The backoff starts over from the minimum
+// after a connection that lasted at least the minimum backoff.
+func (d *CallHomeDialer) Run(ctx context.Context, fn CallHomeConnFunc) error {
+	if len(d.addrs) == 0 {
+		return errors.New("netconf: no call home collectors")
+	}
+
+	attempt := 0
+	for {
+		var short bool
+		conn, addr, err := d.dial(ctx)
+		if err == nil {
+			start := d.cfg.clock.Now()
+			stop := context.AfterFunc(ctx, func() { conn.Close() })
+			err = fn(ctx, conn)
+			stop()
+			conn.Close()
+			if err != nil && ctx.Err() == nil {
+				d.report(addr, fmt.Errorf("call home connection failed: %w", err))
+			}
+			short = clock.Since(d.cfg.clock, start) < d.cfg.minBackoff
+			if !short {
+				attempt = 0
+			}
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		var wait time.Duration
+		switch {
+		case err != nil:
+			wait = d.backoff(attempt)
+			attempt++
+		case short:
+			wait = max(d.cfg.period, d.backoff(attempt))
+			attempt++
+		default:
+			wait = d.cfg.period
+		}
+		if err := d.sleep(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+// dial connects to the first collector that accepts the connection.
+func (d *CallHomeDialer) dial(ctx context.Context) (net.Conn, string, error) {
+	var errs []error
+	for _, addr := range d.addrs {
+		conn, err := d.cfg.dialer.DialContext(ctx, d.cfg.network, addr)
+		if err == nil {
+			return conn, addr, nil
+		}
+		if ctx.Err() != nil {
+			return nil, "", ctx.Err()
+		}
+		d.report(addr, err)
+		errs = append(errs, err)
+	}
+	return nil, "", errors.Join(errs...)
+}
+
+func (d *CallHomeDialer) report(addr string, err error) {
+	if d.cfg.onError != nil {
+		d.cfg.onError(addr, err)
+	}
+}
+
+// backoff returns the wait after attempt consecutive failures: the minimum
+// doubled for each attempt up to the maximum with the upper half randomized.
+func (d *CallHomeDialer) backoff(attempt int) time.Duration {
+	wait := d.cfg.minBackoff
+	for i := 0; i < attempt && wait < d.cfg.maxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > d.cfg.maxBackoff {
+		wait = d.cfg.maxBackoff
+	}
+	if half := wait / 2; half > 0 {
+		wait = half + time.Duration(rand.Int63n(int64(half)+1))
+	}
+	return wait
+}
+
+func (d *CallHomeDialer) sleep(ctx context.Context, wait time.Duration) error {
+	if wait <= 0 {
+		return nil
+	}
+	timer := d.cfg.clock.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,277 @@
+package netconf
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nemith/netconf/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// deadAddr returns an address nothing listens on.
+func deadAddr(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+	ln.Close()
+	return addr
+}
+
+func TestCallHomeDialer(t *testing.T) {
+	srv, _ := startCallHomeServer(t,
+		WithCallHomeClientConfig("127.0.0.1", &CallHomeClientConfig{Transport: pipeCallHome{}}))
+	defer srv.Shutdown(context.Background())
+
+	dead := deadAddr(t)
+	var (
+		mu     sync.Mutex
+		failed []string
+	)
+	d := NewCallHomeDialer([]string{dead, srv.Addr().String()},
+		WithCallHomeDialErrors(func(addr string, err error) {
+			mu.Lock()
+			failed = append(failed, addr)
+			mu.Unlock()
+		}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- d.Run(ctx, func(ctx context.Context, conn net.Conn) error {
+			<-serveCallHomeDevice(conn)
+			return nil
+		})
+	}()
+
+	// the first collector can't be reached so the device calls the second.
+	client := <-srv.ClientChannel()
+	assert.EqualValues(t, 7, client.Session().SessionID())
+	mu.Lock()
+	assert.Equal(t, []string{dead}, failed)
+	mu.Unlock()
+
+	// the connection is persistent.
+	require.NoError(t, client.Session().Close(context.Background()))
+	<-srv.ClientChannel()
+
+	cancel()
+	assert.ErrorIs(t, <-runErr, context.Canceled)
+}
+
+func TestCallHomeDialerBackoff(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	clk := clock.NewFake(time.Now())
+	conns := make(chan struct{})
+	d := NewCallHomeDialer([]string{ln.Addr().String()},
+		WithCallHomeBackoff(time.Second, 4*time.Second),
+		WithCallHomeDialerClock(clk))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- d.Run(ctx, func(ctx context.Context, conn net.Conn) error {
+			conns <- struct{}{}
+			return errors.New("handshake failed")
+		})
+	}()
+
+	<-conns
+	for i := 0; i < 3; i++ {
+		// a failed connection waits for the backoff before calling again.
+		clk.BlockUntil(1)
+		select {
+		case <-conns:
+			t.Fatal("called home before the backoff expired")
+		case <-time.After(10 * time.Millisecond):
+		}
+		clk.Advance(4 * time.Second)
+		<-conns
+	}
+
+	clk.BlockUntil(1)
+	cancel()
+	assert.ErrorIs(t, <-runErr, context.Canceled)
+}
+
+func TestCallHomeDialerBackoffReset(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	clk := clock.NewFake(time.Now())
+	// each connection lasts for the duration sent before failing.
+	lives := make(chan time.Duration)
+	d := NewCallHomeDialer([]string{ln.Addr().String()},
+		WithCallHomeBackoff(time.Second, 8*time.Second),
+		WithCallHomeDialerClock(clk))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- d.Run(ctx, func(ctx context.Context, conn net.Conn) error {
+			clk.Advance(<-lives)
+			return errors.New("connection reset")
+		})
+	}()
+
+	// back off up to 1s and 2s after connections failing right away.
+	lives <- 0
+	for _, wait := range []time.Duration{time.Second, 2 * time.Second} {
+		clk.BlockUntil(1)
+		clk.Advance(wait)
+		lives <- 0
+	}
+
+	// a connection that lasted starts the backoff over instead of waiting
+	// up to 8s.
+	clk.BlockUntil(1)
+	clk.Advance(4 * time.Second)
+	lives <- 2 * time.Second
+	clk.BlockUntil(1)
+	clk.Advance(time.Second)
+	select {
+	case lives <- 0:
+	case <-time.After(time.Second):
+		t.Fatal("backoff not reset after a lasting connection")
+	}
+
+	clk.BlockUntil(1)
+	cancel()
+	assert.ErrorIs(t, <-runErr, context.Canceled)
+}
+
+func TestCallHomeDialerPeriodic(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	clk := clock.NewFake(time.Now())
+	conns := make(chan struct{})
+	d := NewCallHomeDialer([]string{ln.Addr().String()},
+		WithCallHomePeriodic(time.Hour),
+		WithCallHomeDialerClock(clk))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go d.Run(ctx, func(ctx context.Context, conn net.Conn) error {
+		conns <- struct{}{}
+		return nil
+	})
+
+	<-conns
+	clk.BlockUntil(1)
+	clk.Advance(59 * time.Minute)
+	select {
+	case <-conns:
+		t.Fatal("called home before the period expired")
+	case <-time.After(10 * time.Millisecond):
+	}
+	clk.Advance(time.Minute)
+	<-conns
+}
+
+func TestCallHomeDialerShortLived(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	clk := clock.NewFake(time.Now())
+	conns := make(chan struct{})
+	d := NewCallHomeDialer([]string{ln.Addr().String()},
+		WithCallHomeBackoff(time.Second, 4*time.Second),
+		WithCallHomeDialerClock(clk))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go d.Run(ctx, func(ctx context.Context, conn net.Conn) error {
+		conns <- struct{}{}
+		return nil
+	})
+
+	// a connection dropped right away isn't retried in a loop.
+	<-conns
+	clk.BlockUntil(1)
+	select {
+	case <-conns:
+		t.Fatal("called home again without waiting")
+	case <-time.After(10 * time.Millisecond):
+	}
+	clk.Advance(time.Second)
+	<-conns
+}
+
+func TestCallHomeDialerBackoffRange(t *testing.T) {
+	d := NewCallHomeDialer([]string{"collector"}, WithCallHomeBackoff(time.Second, 10*time.Second))
+	assert.Equal(t, []string{"collector:4334"}, d.addrs)
+
+	tt := []struct {
+		attempt  int
+		min, max time.Duration
+	}{
+		{0, 500 * time.Millisecond, time.Second},
+		{1, time.Second, 2 * time.Second},
+		{3, 4 * time.Second, 8 * time.Second},
+		{4, 5 * time.Second, 10 * time.Second},
+		{100, 5 * time.Second, 10 * time.Second},
+	}
+	for _, tc := range tt {
+		for i := 0; i < 10; i++ {
+			got := d.backoff(tc.attempt)
+			assert.GreaterOrEqual(t, got, tc.min, "attempt %d", tc.attempt)
+			assert.LessOrEqual(t, got, tc.max, "attempt %d", tc.attempt)
+		}
+	}
+}
+
+func TestCallHomeDialerNoCollectors(t *testing.T) {
+	err := NewCallHomeDialer(nil).Run(context.Background(), nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,107 @@
+package netconf
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"sync"
+)
+
+// ErrCallHomeLimit is returned when a device calling home is turned away
+// because of [WithCallHomeMaxSessions] or [WithCallHomeMaxPerSource].
+var ErrCallHomeLimit = errors.New("netconf: call home connection limit reached")
+
+type callHomeMaxSessionsOpt int
+
+func (o callHomeMaxSessionsOpt) apply(cfg *callHomeConfig) { cfg.maxSessions = int(o) }
+
+// WithCallHomeMaxSessions limits the connections from devices to n.  This
+// counts connections still being set up as well as open sessions.
+// Connections over the limit are closed right away and reported with
+// [ErrCallHomeLimit].  The default (0) is no limit.
+func WithCallHomeMaxSessions(n int) CallHomeOption { return callHomeMaxSessionsOpt(n) }
+
+type callHomeMaxPerSourceOpt int
+
+func (o callHomeMaxPerSourceOpt) apply(cfg *callHomeConfig) { cfg.maxPerSource = int(o) }
+
+// WithCallHomeMaxPerSource limits the connections from a single IP address
+// to n, counted like [WithCallHomeMaxSessions].  With [WithCallHomeReconnect]
+// allow at least 2 so that a device can call home again while its old
+// connection is still open.  The default (0) is no limit.
+func WithCallHomeMaxPerSource(n int) CallHomeOption { return callHomeMaxPerSourceOpt(n) }
+
+type callHomeAcceptRateOpt struct {
+	rate  float64
+	burst int
+}
+
+func (o callHomeAcceptRateOpt) apply(cfg *callHomeConfig) {
+	cfg.acceptRate, cfg.acceptBurst = o.rate, o.burst
+}
+
+// WithCallHomeAcceptRate limits accepting connections to rate per second
+// allowing bursts of up to burst connections.  Connections over the rate
+// wait in the listen backlog of the operating system rather than using up
+// file descriptors, so a fleet of devices rebooting at once is taken in
+// gradually.  The default is no limit.
+func WithCallHomeAcceptRate(rate float64, burst int) CallHomeOption {
+	return callHomeAcceptRateOpt{rate, burst}
+}
+
+// sourceKey returns the key the connections from addr are counted under.
+func sourceKey(addr net.Addr) string {
+	if addrPort, err := netip.ParseAddrPort(addr.String()); err == nil {
+		return addrPort.Addr().Unmap().String()
+	}
+	return addr.String()
+}
+
+// acquire counts a connection from addr against the limits.  The returned
+// func releases it.
+func (s *CallHomeServer) acquire(addr net.Addr) (func(), error) {
+	key := sourceKey(addr)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cfg.maxSessions > 0 && s.conns >= s.cfg.maxSessions {
+		return nil, ErrCallHomeLimit
+	}
+	if s.cfg.maxPerSource > 0 && s.sources[key] >= s.cfg.maxPerSource {
+		return nil, ErrCallHomeLimit
+	}
+	s.conns++
+	s.sources[key]++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.conns--
+			if s.sources[key]--; s.sources[key] <= 0 {
+				delete(s.sources, key)
+			}
+		})
+	}, nil
+}
+
+// waitAccept waits until the next connection may be accepted.  It returns
+// early with an error if ctx is done or the server is shut down.
+func (s *CallHomeServer) waitAccept(ctx context.Context) error {
+	if s.limiter == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := context.AfterFunc(s.ctx, cancel)
+	defer stop()
+
+	err := s.limiter.Wait(ctx)
+	if err != nil && s.ctx.Err() != nil {
+		return s.ctx.Err()
+	}
+	return err
+}
@@ -0,0 +1,82 @@
+package netconf
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/nemith/netconf/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCallHomeLimits(t *testing.T) {
+	tt := []struct {
+		name string
+		opt  CallHomeOption
+	}{
+		{"MaxSessions", WithCallHomeMaxSessions(1)},
+		{"MaxPerSource", WithCallHomeMaxPerSource(1)},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			srv, _ := startCallHomeServer(t,
+				WithCallHomeClientConfig("127.0.0.1", &CallHomeClientConfig{Transport: pipeCallHome{}}),
+				tc.opt)
+			defer srv.Shutdown(context.Background())
+
+			device := callHomeDevice(t, srv.Addr().String())
+			client := <-srv.ClientChannel()
+
+			conn, err := net.Dial("tcp", srv.Addr().String())
+			require.NoError(t, err)
+			defer conn.Close()
+			assert.ErrorIs(t, <-srv.ErrorChannel(), ErrCallHomeLimit)
+
+			// closing the session frees up the connection.
+			require.NoError(t, client.Session().Close(context.Background()))
+			<-device
+			require.Eventually(t, func() bool { return srv.ActiveClients() == 0 }, time.Second, time.Millisecond)
+
+			callHomeDevice(t, srv.Addr().String())
+			<-srv.ClientChannel()
+		})
+	}
+}
+
+func TestAcceptLimiter(t *testing.T) {
+	ctx := context.Background()
+	require.NoError(t, NewCallHomeServer().waitAccept(ctx))
+
+	clk := clock.NewFake(time.Now())
+	srv := NewCallHomeServer(WithCallHomeAcceptRate(10, 1), WithCallHomeClock(clk))
+	require.NoError(t, srv.waitAccept(ctx))
+
+	// the next one waits for another token.
+	done := make(chan error)
+	go func() { done <- srv.waitAccept(ctx) }()
+	clk.BlockUntil(1)
+	clk.Advance(100 * time.Millisecond)
+	require.NoError(t, <-done)
+
+	// shutting down stops waiting.
+	go func() { done <- srv.waitAccept(ctx) }()
+	clk.BlockUntil(1)
+	require.NoError(t, srv.Shutdown(ctx))
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
+
+func TestCallHomeAcceptRate(t *testing.T) {
+	srv, _ := startCallHomeServer(t,
+		WithCallHomeClientConfig("127.0.0.1", &CallHomeClientConfig{Transport: pipeCallHome{}}),
+		WithCallHomeAcceptRate(20, 1))
+	defer srv.Shutdown(context.Background())
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		callHomeDevice(t, srv.Addr().String())
+		<-srv.ClientChannel()
+	}
+	assert.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond)
+}
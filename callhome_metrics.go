@@ -0,0 +1,217 @@
+package netconf
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net"
+	"sync"
+)
+
+// CallHomeMetrics is a hook that can be given to a [CallHomeServer] with
+// [WithCallHomeMetrics] to observe the devices calling home.  All methods may
+// be called concurrently and must not block.
+//
+// [CallHomeStats] is a ready made implementation that can be published with
+// expvar.
+type CallHomeMetrics interface {
+	// ConnectionAccepted is called for every connection from a device.
+	ConnectionAccepted(addr net.Addr)
+
+	// ConnectionRejected is called when a device is turned away before a
+	// session is opened: there is no config for it ([ErrNoClientConfig]), it
+	// isn't known ([ErrUnknownClient]) or looking up its config failed.
+	ConnectionRejected(addr net.Addr, err error)
+
+	// HandshakeFailed is called when the transport handshake or the hello
+	// exchange with a device fails.
+	HandshakeFailed(addr net.Addr, err error)
+
+	// SessionOpened and SessionClosed are called when the session of a device
+	// is opened and when it is closed.
+	SessionOpened(client *CallHomeClient)
+	SessionClosed(client *CallHomeClient)
+
+	// KeepaliveFailed is called when a device fails the keepalive check (see
+	// [WithCallHomeKeepalive]) before its session is closed.
+	KeepaliveFailed(client *CallHomeClient, err error)
+}
+
+type nopCallHomeMetrics struct{}
+
+func (nopCallHomeMetrics) ConnectionAccepted(net.Addr)            {}
+func (nopCallHomeMetrics) ConnectionRejected(net.Addr, error)     {}
+func (nopCallHomeMetrics) HandshakeFailed(net.Addr, error)        {}
+func (nopCallHomeMetrics) SessionOpened(*CallHomeClient)          {}
+func (nopCallHomeMetrics) SessionClosed(*CallHomeClient)          {}
+func (nopCallHomeMetrics) KeepaliveFailed(*CallHomeClient, error) {}
+
+type callHomeMetricsOpt struct{ m CallHomeMetrics }
+
+func (o callHomeMetricsOpt) apply(cfg *callHomeConfig) { cfg.metrics = o.m }
+
+// WithCallHomeMetrics sets a [CallHomeMetrics] hook used to record statistics
+// for the server.
+func WithCallHomeMetrics(m CallHomeMetrics) CallHomeOption {
+	if m == nil {
+		m = nopCallHomeMetrics{}
+	}
+	return callHomeMetricsOpt{m}
+}
+
+type callHomeLoggerOpt struct{ l *slog.Logger }
+
+func (o callHomeLoggerOpt) apply(cfg *callHomeConfig) { cfg.logger = o.l }
+
+// WithCallHomeLogger logs the devices calling home to l: connections and
+// sessions opened at debug and info level and rejected or failed connections
+// at warn level.  Nothing is logged by default.
+func WithCallHomeLogger(l *slog.Logger) CallHomeOption { return callHomeLoggerOpt{l} }
+
+func (s *CallHomeServer) log(level slog.Level, msg string, args ...any) {
+	if s.cfg.logger != nil {
+		s.cfg.logger.Log(context.Background(), level, msg, args...)
+	}
+}
+
+func (s *CallHomeServer) connectionAccepted(addr net.Addr) {
+	s.cfg.metrics.ConnectionAccepted(addr)
+	s.log(slog.LevelDebug, "call home connection accepted", "addr", addr.String())
+}
+
+// rejected records the rejection of the device at addr and returns err.
+func (s *CallHomeServer) rejected(addr net.Addr, err error) error {
+	s.cfg.metrics.ConnectionRejected(addr, err)
+	s.log(slog.LevelWarn, "call home connection rejected", "addr", addr.String(), "err", err)
+	return err
+}
+
+// handshakeFailed records the failed handshake with the device at addr and
+// returns err.
+func (s *CallHomeServer) handshakeFailed(addr net.Addr, err error) error {
+	s.cfg.metrics.HandshakeFailed(addr, err)
+	s.log(slog.LevelWarn, "call home handshake failed", "addr", addr.String(), "err", err)
+	return err
+}
+
+func (s *CallHomeServer) sessionOpened(c *CallHomeClient) {
+	s.cfg.metrics.SessionOpened(c)
+	s.log(slog.LevelInfo, "call home session opened",
+		"addr", c.remoteAddr.String(), "device", c.identity.ID(), "session_id", c.session.SessionID())
+}
+
+func (s *CallHomeServer) sessionClosed(c *CallHomeClient) {
+	s.cfg.metrics.SessionClosed(c)
+	s.log(slog.LevelInfo, "call home session closed",
+		"addr", c.remoteAddr.String(), "device", c.identity.ID(), "session_id", c.session.SessionID())
+}
+
+func (s *CallHomeServer) keepaliveFailed(c *CallHomeClient, err error) {
+	s.cfg.metrics.KeepaliveFailed(c, err)
+	s.log(slog.LevelWarn, "call home keepalive failed",
+		"addr", c.remoteAddr.String(), "device", c.identity.ID(), "err", err)
+}
+
+// CallHomeStatsSnapshot is a point in time copy of the statistics held in
+// [CallHomeStats].
+type CallHomeStatsSnapshot struct {
+	Accepted          uint64 `json:"accepted"`
+	RejectedNoConfig  uint64 `json:"rejected_no_config"`
+	RejectedUnknown   uint64 `json:"rejected_unknown"`
+	Rejected          uint64 `json:"rejected"`
+	HandshakeFailures uint64 `json:"handshake_failures"`
+	KeepaliveFailures uint64 `json:"keepalive_failures"`
+	Sessions          uint64 `json:"sessions"`
+
+	// Active is the number of open sessions per device keyed by
+	// [CallHomeIdentity.ID].
+	Active map[string]int `json:"active"`
+}
+
+// CallHomeStats is an implementation of [CallHomeMetrics] that keeps counters
+// in memory.  It implements `expvar.Var` so it can be published directly:
+//
+//	stats := &netconf.CallHomeStats{}
+//	expvar.Publish("netconf_callhome", stats)
+//	srv := netconf.NewCallHomeServer(netconf.WithCallHomeMetrics(stats))
+//
+// Rejected counts all rejected connections including the ones also counted
+// by RejectedNoConfig and RejectedUnknown.  The zero value is ready to use.
+type CallHomeStats struct {
+	mu   sync.Mutex
+	snap CallHomeStatsSnapshot
+}
+
+func (s *CallHomeStats) ConnectionAccepted(net.Addr) {
+	s.mu.Lock()
+	s.snap.Accepted++
+	s.mu.Unlock()
+}
+
+func (s *CallHomeStats) ConnectionRejected(_ net.Addr, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.snap.Rejected++
+	switch {
+	case errors.Is(err, ErrNoClientConfig):
+		s.snap.RejectedNoConfig++
+	case errors.Is(err, ErrUnknownClient):
+		s.snap.RejectedUnknown++
+	}
+}
+
+func (s *CallHomeStats) HandshakeFailed(net.Addr, error) {
+	s.mu.Lock()
+	s.snap.HandshakeFailures++
+	s.mu.Unlock()
+}
+
+func (s *CallHomeStats) SessionOpened(c *CallHomeClient) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.snap.Sessions++
+	if s.snap.Active == nil {
+		s.snap.Active = make(map[string]int)
+	}
+	s.snap.Active[c.Identity().ID()]++
+}
+
+func (s *CallHomeStats) SessionClosed(c *CallHomeClient) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := c.Identity().ID()
+	if s.snap.Active[id]--; s.snap.Active[id] <= 0 {
+		delete(s.snap.Active, id)
+	}
+}
+
+func (s *CallHomeStats) KeepaliveFailed(*CallHomeClient, error) {
+	s.mu.Lock()
+	s.snap.KeepaliveFailures++
+	s.mu.Unlock()
+}
+
+// Snapshot returns a copy of the current statistics.
+func (s *CallHomeStats) Snapshot() CallHomeStatsSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snap := s.snap
+	if s.snap.Active != nil {
+		snap.Active = make(map[string]int, len(s.snap.Active))
+		for id, n := range s.snap.Active {
+			snap.Active[id] = n
+		}
+	}
+	return snap
+}
+
+// String returns the statistics encoded as JSON.  This implements the
+// `expvar.Var` interface.
+func (s *CallHomeStats) String() string {
+	out, err := json.Marshal(s.Snapshot())
+	if err != nil {
+		return "{}"
+	}
+	return string(out)
+}
@@ -0,0 +1,63 @@
+package netconf
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCallHomeStats(t *testing.T) {
+	stats := &CallHomeStats{}
+	var logs bytes.Buffer
+	srv, _ := startCallHomeServer(t,
+		WithCallHomeClientConfig("127.0.0.1", &CallHomeClientConfig{Transport: pipeCallHome{}}),
+		WithCallHomeMetrics(stats),
+		WithCallHomeLogger(slog.New(slog.NewTextHandler(&logs, nil))))
+
+	device := callHomeDevice(t, srv.Addr().String())
+	<-srv.ClientChannel()
+
+	snap := stats.Snapshot()
+	assert.EqualValues(t, 1, snap.Accepted)
+	assert.EqualValues(t, 1, snap.Sessions)
+	assert.Equal(t, map[string]int{"addr:127.0.0.1": 1}, snap.Active)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, srv.Shutdown(ctx))
+	<-device
+
+	snap = stats.Snapshot()
+	assert.Empty(t, snap.Active)
+	assert.Contains(t, logs.String(), `msg="call home session opened" addr=127.0.0.1:`)
+	assert.Contains(t, logs.String(), `device=addr:127.0.0.1 session_id=7`)
+	assert.Contains(t, logs.String(), `msg="call home session closed"`)
+
+	var decoded CallHomeStatsSnapshot
+	require.NoError(t, json.Unmarshal([]byte(stats.String()), &decoded))
+	assert.Equal(t, snap, decoded)
+}
+
+func TestCallHomeStatsRejected(t *testing.T) {
+	stats := &CallHomeStats{}
+	srv, _ := startCallHomeServer(t, WithCallHomeMetrics(stats))
+	defer srv.Shutdown(context.Background())
+
+	conn, err := net.Dial("tcp", srv.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	<-srv.ErrorChannel()
+
+	snap := stats.Snapshot()
+	assert.EqualValues(t, 1, snap.Accepted)
+	assert.EqualValues(t, 1, snap.Rejected)
+	assert.EqualValues(t, 1, snap.RejectedNoConfig)
+	assert.EqualValues(t, 0, snap.Sessions)
+}
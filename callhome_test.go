@@ -0,0 +1,822 @@
+package netconf
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"net/netip"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nemith/netconf/clock"
+	"github.com/nemith/netconf/transport"
+	nctls "github.com/nemith/netconf/transport/tls"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+// pipeCallHome sets up a transport without any handshake.
+type pipeCallHome struct{}
+
+func (pipeCallHome) DoHandshake(ctx context.Context, conn net.Conn) (transport.Transport, error) {
+	return transport.NewPipeTransport(conn), nil
+}
+
+var callHomeMsgIDRe = regexp.MustCompile(`message-id="([^"]*)"`)
+
+// callHomeDevice calls home to addr and answers close-session until the
+// connection is closed.  The returned channel is closed once it is done.
+func callHomeDevice(t *testing.T, addr string) <-chan struct{} {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	return serveCallHomeDevice(conn)
+}
+
+// serveCallHomeDevice acts as a device on conn as for callHomeDevice.
+func serveCallHomeDevice(conn net.Conn) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		tr := transport.NewPipeTransport(conn)
+		defer tr.Close()
+
+		send := func(msg string) bool {
+			w, err := tr.MsgWriter()
+			if err != nil {
+				return false
+			}
+			_, _ = io.WriteString(w, msg)
+			return w.Close() == nil
+		}
+
+		if !send(`<hello xmlns="urn:ietf:params:xml:ns:netconf:base:1.0"><capabilities><capability>urn:ietf:params:netconf:base:1.0</capability></capabilities><session-id>7</session-id></hello>`) {
+			return
+		}
+		for {
+			r, err := tr.MsgReader()
+			if err != nil {
+				return
+			}
+			msg, err := io.ReadAll(r)
+			if err != nil {
+				return
+			}
+			if !strings.Contains(string(msg), "<close-session") {
+				continue
+			}
+			m := callHomeMsgIDRe.FindSubmatch(msg)
+			if m == nil {
+				return
+			}
+			send(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="` + string(m[1]) + `"><ok/></rpc-reply>`)
+			return
+		}
+	}()
+	return done
+}
+
+func startCallHomeServer(t *testing.T, opts ...CallHomeOption) (*CallHomeServer, <-chan error) {
+	t.Helper()
+	opts = append([]CallHomeOption{WithCallHomeAddress("tcp", "127.0.0.1:0")}, opts...)
+	srv := NewCallHomeServer(opts...)
+
+	listenErr := make(chan error, 1)
+	go func() { listenErr <- srv.Listen(context.Background()) }()
+	require.Eventually(t, func() bool { return srv.Addr() != nil }, time.Second, time.Millisecond)
+	return srv, listenErr
+}
+
+func TestCallHomeServer(t *testing.T) {
+	srv, listenErr := startCallHomeServer(t,
+		WithCallHomeClientConfig("127.0.0.1", &CallHomeClientConfig{Transport: pipeCallHome{}}))
+
+	device := callHomeDevice(t, srv.Addr().String())
+
+	client := <-srv.ClientChannel()
+	require.NotNil(t, client)
+	assert.EqualValues(t, 7, client.Session().SessionID())
+	assert.Equal(t, "127.0.0.1", client.RemoteAddr().(*net.TCPAddr).IP.String())
+	assert.Equal(t, 1, srv.ActiveClients())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, srv.Shutdown(ctx))
+
+	// the device got a close-session.
+	<-device
+	<-client.Session().Done()
+	assert.ErrorIs(t, <-listenErr, ErrCallHomeServerClosed)
+	assert.Equal(t, 0, srv.ActiveClients())
+
+	_, ok := <-srv.ClientChannel()
+	assert.False(t, ok)
+	_, ok = <-srv.ErrorChannel()
+	assert.False(t, ok)
+
+	assert.ErrorIs(t, srv.Shutdown(ctx), ErrCallHomeServerClosed)
+}
+
+func TestCallHomeNoClientConfig(t *testing.T) {
+	srv, _ := startCallHomeServer(t,
+		WithCallHomeClientConfig("192.0.2.1", &CallHomeClientConfig{Transport: pipeCallHome{}}))
+	defer srv.Shutdown(context.Background())
+
+	conn, err := net.Dial("tcp", srv.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	clientErr := <-srv.ErrorChannel()
+	assert.ErrorIs(t, clientErr, ErrNoClientConfig)
+	assert.Equal(t, conn.LocalAddr().String(), clientErr.Address)
+
+	// the connection was closed.
+	_, err = conn.Read(make([]byte, 1))
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestCallHomeListenContext(t *testing.T) {
+	srv := NewCallHomeServer(WithCallHomeAddress("tcp", "127.0.0.1:0"))
+	defer srv.Shutdown(context.Background())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	listenErr := make(chan error, 1)
+	go func() { listenErr <- srv.Listen(ctx) }()
+	require.Eventually(t, func() bool { return srv.Addr() != nil }, time.Second, time.Millisecond)
+
+	cancel()
+	assert.ErrorIs(t, <-listenErr, context.Canceled)
+	assert.Nil(t, srv.Addr())
+}
+
+func TestCallHomeShutdownUnblocks(t *testing.T) {
+	srv, _ := startCallHomeServer(t,
+		WithCallHomeClientConfig("127.0.0.1", &CallHomeClientConfig{Transport: pipeCallHome{}}))
+
+	// nothing reads the client channel.
+	device := callHomeDevice(t, srv.Addr().String())
+	require.Eventually(t, func() bool { return srv.ActiveClients() == 1 }, time.Second, time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, srv.Shutdown(ctx))
+	<-device
+	assert.Equal(t, 0, srv.ActiveClients())
+}
+
+func TestCallHomeShutdownDrain(t *testing.T) {
+	srv, _ := startCallHomeServer(t,
+		WithCallHomeClientConfig("127.0.0.1", &CallHomeClientConfig{Transport: pipeCallHome{}}),
+		WithCallHomeDrain())
+
+	device := callHomeDevice(t, srv.Addr().String())
+	client := <-srv.ClientChannel()
+
+	// the session is left open until the context expires.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, srv.Shutdown(ctx), context.DeadlineExceeded)
+	<-client.Session().Done()
+	<-device
+}
+
+func TestCallHomeShutdownDrainClosed(t *testing.T) {
+	srv, _ := startCallHomeServer(t,
+		WithCallHomeClientConfig("127.0.0.1", &CallHomeClientConfig{Transport: pipeCallHome{}}),
+		WithCallHomeDrain())
+
+	device := callHomeDevice(t, srv.Addr().String())
+	client := <-srv.ClientChannel()
+
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- srv.Shutdown(context.Background()) }()
+
+	select {
+	case <-shutdown:
+		t.Fatal("shutdown returned before the session was closed")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	require.NoError(t, client.Session().Close(context.Background()))
+	assert.NoError(t, <-shutdown)
+	<-device
+}
+
+func TestCallHomeHandshakeClock(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	srv, _ := startCallHomeServer(t,
+		WithCallHomeClientConfig("127.0.0.1", &CallHomeClientConfig{Transport: pipeCallHome{}}),
+		WithCallHomeHandshakeTimeout(time.Minute),
+		WithCallHomeClock(clk))
+	defer srv.Shutdown(context.Background())
+
+	// the device never sends its hello.
+	conn, err := net.Dial("tcp", srv.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// the handshake times out on the clock.
+	clk.BlockUntil(1)
+	select {
+	case err := <-srv.ErrorChannel():
+		t.Fatalf("handshake failed before the timeout: %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	clk.Advance(time.Minute)
+	select {
+	case err := <-srv.ErrorChannel():
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	case <-time.After(time.Second):
+		t.Fatal("handshake not timed out")
+	}
+}
+
+func TestCallHomeEvents(t *testing.T) {
+	var bus EventBus
+	events := make(chan Event, 10)
+	bus.Subscribe(func(ev Event) { events <- ev })
+
+	srv, _ := startCallHomeServer(t,
+		WithCallHomeClientConfig("127.0.0.1", &CallHomeClientConfig{Transport: pipeCallHome{}}),
+		WithCallHomeEventBus(&bus))
+	defer srv.Shutdown(context.Background())
+
+	device := callHomeDevice(t, srv.Addr().String())
+	client := <-srv.ClientChannel()
+	ev := <-events
+	assert.Equal(t, EventConnected, ev.Type)
+	assert.Same(t, client.Session(), ev.Session)
+	assert.Equal(t, uint64(7), ev.SessionID)
+	assert.Same(t, client, ev.Detail)
+
+	require.NoError(t, client.Session().Close(context.Background()))
+	<-device
+	ev = <-events
+	assert.Equal(t, EventDisconnected, ev.Type)
+	assert.Same(t, client, ev.Detail)
+	assert.NoError(t, ev.Err)
+}
+
+type addrConn struct {
+	net.Conn
+	remote net.Addr
+}
+
+func (c addrConn) RemoteAddr() net.Addr { return c.remote }
+
+func TestCallHomeClientConfigLookup(t *testing.T) {
+	exact := &CallHomeClientConfig{}
+	subnet := &CallHomeClientConfig{}
+	host := &CallHomeClientConfig{}
+	inventory := &CallHomeClientConfig{}
+	errLookup := errors.New("inventory unavailable")
+
+	srv := NewCallHomeServer(
+		WithCallHomeClientConfig("192.0.2.1", exact),
+		WithCallHomeClientPrefix(netip.MustParsePrefix("192.0.2.0/24"), subnet),
+		WithCallHomeClientPrefix(netip.MustParsePrefix("192.0.2.128/25"), host),
+		WithCallHomeConfigFunc(func(ctx context.Context, conn net.Conn) (*CallHomeClientConfig, error) {
+			switch conn.RemoteAddr().(*net.TCPAddr).IP.String() {
+			case "198.51.100.1":
+				return inventory, nil
+			case "198.51.100.2":
+				return nil, errLookup
+			}
+			return nil, nil
+		}),
+	)
+
+	tt := []struct {
+		addr    string
+		want    *CallHomeClientConfig
+		wantErr error
+	}{
+		{"192.0.2.1", exact, nil},
+		{"::ffff:192.0.2.1", exact, nil},
+		{"192.0.2.2", subnet, nil},
+		{"192.0.2.200", host, nil},
+		{"198.51.100.1", inventory, nil},
+		{"198.51.100.2", nil, errLookup},
+		{"203.0.113.1", nil, ErrNoClientConfig},
+	}
+	for _, tc := range tt {
+		t.Run(tc.addr, func(t *testing.T) {
+			conn := addrConn{remote: &net.TCPAddr{IP: net.ParseIP(tc.addr), Port: 4334}}
+			got, err := srv.clientConfig(context.Background(), conn)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Same(t, tc.want, got)
+		})
+	}
+
+	// an invalid address would never match so it is rejected up front.
+	assert.Panics(t, func() { WithCallHomeClientConfig("router1", exact) })
+}
+
+// keyCallHome sets up a transport without any handshake that presents the
+// next key from keys as its ssh host key.
+type keyCallHome struct{ keys chan ssh.PublicKey }
+
+type keyTransport struct {
+	*transport.PipeTransport
+	key ssh.PublicKey
+}
+
+func (t keyTransport) HostKey() ssh.PublicKey { return t.key }
+
+func (h keyCallHome) DoHandshake(ctx context.Context, conn net.Conn) (transport.Transport, error) {
+	return keyTransport{transport.NewPipeTransport(conn), <-h.keys}, nil
+}
+
+func newHostKey(t *testing.T) ssh.PublicKey {
+	t.Helper()
+	pub, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	key, err := ssh.NewPublicKey(pub)
+	require.NoError(t, err)
+	return key
+}
+
+func TestCallHomeIdentify(t *testing.T) {
+	known, unknown := newHostKey(t), newHostKey(t)
+	device := &CallHomeClientConfig{}
+
+	keys := make(chan ssh.PublicKey, 2)
+	keys <- known
+	keys <- unknown
+	srv, _ := startCallHomeServer(t,
+		WithCallHomeClientConfig("127.0.0.1", &CallHomeClientConfig{Transport: keyCallHome{keys}}),
+		WithCallHomeIdentify(func(ctx context.Context, id *CallHomeIdentity, _ *CallHomeClientConfig) (*CallHomeClientConfig, error) {
+			if id.Fingerprint() != ssh.FingerprintSHA256(known) {
+				return nil, ErrUnknownClient
+			}
+			return device, nil
+		}))
+	defer srv.Shutdown(context.Background())
+
+	callHomeDevice(t, srv.Addr().String())
+	client := <-srv.ClientChannel()
+	assert.Same(t, device, client.Config())
+	assert.Equal(t, known.Marshal(), client.Identity().HostKey.Marshal())
+	assert.Equal(t, "127.0.0.1", client.Identity().RemoteAddr.(*net.TCPAddr).IP.String())
+
+	<-callHomeDevice(t, srv.Addr().String())
+	clientErr := <-srv.ErrorChannel()
+	assert.ErrorIs(t, clientErr, ErrUnknownClient)
+	assert.ErrorIs(t, clientErr, ErrUnauthorized)
+	require.NotNil(t, clientErr.Identity)
+	assert.Equal(t, "ssh:"+ssh.FingerprintSHA256(unknown), clientErr.Identity.ID())
+	assert.Contains(t, clientErr.Error(), "(ssh:"+ssh.FingerprintSHA256(unknown)+")")
+}
+
+func TestCallHomeHandler(t *testing.T) {
+	sessions := make(chan *CallHomeClient, 1)
+	srv, _ := startCallHomeServer(t,
+		WithCallHomeClientConfig("127.0.0.1", &CallHomeClientConfig{Transport: pipeCallHome{}}),
+		WithCallHomeHandler(CallHomeHandlerFuncs{
+			Session: func(client *CallHomeClient) { sessions <- client },
+		}))
+
+	device := callHomeDevice(t, srv.Addr().String())
+	client := <-sessions
+	assert.EqualValues(t, 7, client.Session().SessionID())
+
+	// a handler that doesn't return doesn't block shutdown.
+	blocked := make(chan struct{})
+	defer close(blocked)
+	srv2, _ := startCallHomeServer(t,
+		WithCallHomeClientConfig("127.0.0.1", &CallHomeClientConfig{Transport: pipeCallHome{}}),
+		WithCallHomeHandler(CallHomeHandlerFuncs{
+			Session: func(*CallHomeClient) { <-blocked },
+		}))
+	device2 := callHomeDevice(t, srv2.Addr().String())
+	require.Eventually(t, func() bool { return srv2.ActiveClients() == 1 }, time.Second, time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, srv2.Shutdown(ctx))
+	<-device2
+
+	require.NoError(t, srv.Shutdown(ctx))
+	<-device
+	_, ok := <-srv.ClientChannel()
+	assert.False(t, ok)
+}
+
+func TestCallHomeHandlerError(t *testing.T) {
+	errs := make(chan *ClientError, 1)
+	srv, _ := startCallHomeServer(t,
+		WithCallHomeHandler(CallHomeHandlerFuncs{
+			Error: func(err *ClientError) { errs <- err },
+		}))
+	defer srv.Shutdown(context.Background())
+
+	conn, err := net.Dial("tcp", srv.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	assert.ErrorIs(t, <-errs, ErrNoClientConfig)
+}
+
+// newCallHomeCert returns a certificate signed by parent (or self-signed if
+// nil) from tmpl.
+func newCallHomeCert(t *testing.T, tmpl *x509.Certificate, parent *tls.Certificate) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl.SerialNumber = big.NewInt(time.Now().UnixNano())
+	tmpl.NotBefore = time.Now().Add(-time.Hour)
+	tmpl.NotAfter = time.Now().Add(time.Hour)
+
+	parentCert, parentKey := tmpl, any(key)
+	if parent != nil {
+		parentCert, parentKey = parent.Leaf, parent.PrivateKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parentCert, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: cert}
+}
+
+func TestCallHomeIdentityID(t *testing.T) {
+	addr := &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 4334}
+	key := newHostKey(t)
+	cert := newCallHomeCert(t, &x509.Certificate{Subject: pkix.Name{CommonName: "r1"}}, nil)
+
+	ids := map[string]*CallHomeIdentity{
+		"addr:192.0.2.1":                      {RemoteAddr: addr},
+		"ssh:" + ssh.FingerprintSHA256(key):   {RemoteAddr: addr, HostKey: key},
+		"tls:" + nctls.Fingerprint(cert.Leaf): {RemoteAddr: addr, Certificates: []*x509.Certificate{cert.Leaf}},
+		"name:192.0.2.1":                      {RemoteAddr: addr, Certificates: []*x509.Certificate{cert.Leaf}, Name: "192.0.2.1"},
+	}
+	for want, id := range ids {
+		assert.Equal(t, want, id.ID())
+	}
+}
+
+func TestCallHomeTLS(t *testing.T) {
+	ca := newCallHomeCert(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "test ca"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil)
+	leaf := newCallHomeCert(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "router1"},
+		DNSNames:    []string{"router1.example.com"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, &ca)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Leaf)
+
+	var verified []*x509.Certificate
+	router1 := &CallHomeClientConfig{}
+	srv, _ := startCallHomeServer(t,
+		WithCallHomeTLS(&TLSCallHomeTransport{
+			Config: &tls.Config{RootCAs: roots},
+			VerifyPeer: func(chain []*x509.Certificate) error {
+				verified = chain
+				return nil
+			},
+		}),
+		WithCallHomeCertToName([]nctls.CertToName{
+			{ID: 1, Fingerprint: nctls.Fingerprint(ca.Leaf), MapType: nctls.MapSANDNSName},
+		}, map[string]*CallHomeClientConfig{"router1.example.com": router1}))
+	defer srv.Shutdown(context.Background())
+
+	callHomeTLS := func(cert tls.Certificate) <-chan struct{} {
+		conn, err := net.Dial("tcp", srv.Addr().String())
+		require.NoError(t, err)
+		return serveCallHomeDevice(tls.Server(conn, &tls.Config{Certificates: []tls.Certificate{cert}}))
+	}
+
+	// the name in the certificate doesn't match the address but VerifyPeer
+	// is used instead.
+	callHomeTLS(leaf)
+	client := <-srv.ClientChannel()
+	assert.Same(t, router1, client.Config())
+	assert.Equal(t, "router1.example.com", client.Identity().Name)
+	require.Len(t, client.Identity().Certificates, 2)
+	assert.Equal(t, ca.Leaf, client.Identity().Certificates[1])
+	assert.Equal(t, client.Identity().Certificates, verified)
+
+	// untrusted certificate
+	other := newCallHomeCert(t, &x509.Certificate{
+		DNSNames:    []string{"router1.example.com"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, nil)
+	<-callHomeTLS(other)
+	clientErr := <-srv.ErrorChannel()
+	assert.ErrorIs(t, clientErr, ErrTransportHandshake)
+	assert.Nil(t, clientErr.Identity)
+
+	// trusted but not mapped to a config
+	unknown := newCallHomeCert(t, &x509.Certificate{
+		DNSNames:    []string{"router2.example.com"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, &ca)
+	<-callHomeTLS(unknown)
+	clientErr = <-srv.ErrorChannel()
+	assert.ErrorIs(t, clientErr, ErrUnknownClient)
+	assert.ErrorIs(t, clientErr, ErrUnauthorized)
+	require.NotNil(t, clientErr.Identity)
+	assert.Equal(t, unknown.Leaf, clientErr.Identity.Certificates[0])
+}
+
+func TestTLSCallHomeTransportVerify(t *testing.T) {
+	cert := newCallHomeCert(t, &x509.Certificate{
+		DNSNames:    []string{"router1.example.com"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, nil)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_ = tls.Server(conn, &tls.Config{Certificates: []tls.Certificate{cert}}).Handshake()
+			}()
+		}
+	}()
+
+	handshake := func(ct *TLSCallHomeTransport) error {
+		conn, err := net.Dial("tcp", ln.Addr().String())
+		require.NoError(t, err)
+		tr, err := ct.DoHandshake(context.Background(), conn)
+		if err == nil {
+			tr.Close()
+		}
+		return err
+	}
+
+	// VerifyPeer still runs without verifying the chain.
+	var peer []*x509.Certificate
+	errPinned := errors.New("not pinned")
+	err = handshake(&TLSCallHomeTransport{
+		Config: &tls.Config{InsecureSkipVerify: true},
+		VerifyPeer: func(chain []*x509.Certificate) error {
+			peer = chain
+			return errPinned
+		},
+	})
+	assert.ErrorIs(t, err, errPinned)
+	require.Len(t, peer, 1)
+	assert.Equal(t, cert.Leaf, peer[0])
+
+	// VerifyConnection of the config is called after VerifyPeer.
+	var calls []string
+	errConn := errors.New("rejected by VerifyConnection")
+	err = handshake(&TLSCallHomeTransport{
+		Config: &tls.Config{
+			InsecureSkipVerify: true,
+			VerifyConnection: func(tls.ConnectionState) error {
+				calls = append(calls, "connection")
+				return errConn
+			},
+		},
+		VerifyPeer: func([]*x509.Certificate) error {
+			calls = append(calls, "peer")
+			return nil
+		},
+	})
+	assert.ErrorIs(t, err, errConn)
+	assert.Equal(t, []string{"peer", "connection"}, calls)
+}
+
+func TestCallHomeTLSPort(t *testing.T) {
+	srv := NewCallHomeServer(WithCallHomeTLS(&TLSCallHomeTransport{}))
+	assert.Equal(t, ":4335", srv.cfg.listeners[0].addr)
+
+	srv = NewCallHomeServer(WithCallHomeTLS(&TLSCallHomeTransport{}), WithCallHomeAddress("tcp", ":1234"))
+	assert.Equal(t, ":1234", srv.cfg.listeners[0].addr)
+
+	srv = NewCallHomeServer()
+	assert.Equal(t, ":4334", srv.cfg.listeners[0].addr)
+}
+
+func TestCallHomeKeepalive(t *testing.T) {
+	var alive atomic.Bool
+	alive.Store(true)
+	srv, _ := startCallHomeServer(t,
+		WithCallHomeClientConfig("127.0.0.1", &CallHomeClientConfig{Transport: pipeCallHome{}}),
+		WithCallHomeKeepalive(10*time.Millisecond, func(ctx context.Context, s *Session) error {
+			if !alive.Load() {
+				return errors.New("device gone")
+			}
+			return s.Ping(ctx)
+		}))
+	defer srv.Shutdown(context.Background())
+
+	device := callHomeDevice(t, srv.Addr().String())
+	client := <-srv.ClientChannel()
+
+	// passing checks keep the session open.
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 1, srv.ActiveClients())
+
+	alive.Store(false)
+	<-client.Session().Done()
+	<-device
+	require.Eventually(t, func() bool { return srv.ActiveClients() == 0 }, time.Second, time.Millisecond)
+}
+
+func TestCallHomeKeepaliveClock(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	checks := make(chan struct{}, 1)
+	srv, _ := startCallHomeServer(t,
+		WithCallHomeClientConfig("127.0.0.1", &CallHomeClientConfig{Transport: pipeCallHome{}}),
+		WithCallHomeClock(clk),
+		WithCallHomeKeepalive(time.Minute, func(ctx context.Context, s *Session) error {
+			checks <- struct{}{}
+			return errors.New("device gone")
+		}))
+	defer srv.Shutdown(context.Background())
+
+	device := callHomeDevice(t, srv.Addr().String())
+	client := <-srv.ClientChannel()
+
+	// the check waits for the clock.
+	clk.BlockUntil(1)
+	select {
+	case <-checks:
+		t.Fatal("keepalive checked before the interval")
+	default:
+	}
+
+	clk.Advance(time.Minute)
+	<-checks
+	<-client.Session().Done()
+	<-device
+}
+
+func TestCallHomeReconnect(t *testing.T) {
+	type reconnect struct{ old, client *CallHomeClient }
+	reconnects := make(chan reconnect, 1)
+	srv, _ := startCallHomeServer(t,
+		WithCallHomeClientConfig("127.0.0.1", &CallHomeClientConfig{Transport: pipeCallHome{}}),
+		WithCallHomeReconnect(func(old, client *CallHomeClient) {
+			reconnects <- reconnect{old, client}
+		}))
+	defer srv.Shutdown(context.Background())
+
+	device := callHomeDevice(t, srv.Addr().String())
+	first := <-srv.ClientChannel()
+
+	callHomeDevice(t, srv.Addr().String())
+	r := <-reconnects
+	assert.Same(t, first, r.old)
+	second := <-srv.ClientChannel()
+	assert.Same(t, second, r.client)
+
+	// the old session was replaced.
+	<-first.Session().Done()
+	<-device
+	require.Eventually(t, func() bool { return srv.ActiveClients() == 1 }, time.Second, time.Millisecond)
+}
+
+func TestCallHomeReconnectForget(t *testing.T) {
+	srv, _ := startCallHomeServer(t,
+		WithCallHomeClientConfig("127.0.0.1", &CallHomeClientConfig{Transport: pipeCallHome{}}),
+		WithCallHomeReconnect(nil))
+	defer srv.Shutdown(context.Background())
+
+	device := callHomeDevice(t, srv.Addr().String())
+	client := <-srv.ClientChannel()
+	srv.mu.Lock()
+	assert.Len(t, srv.devices, 1)
+	srv.mu.Unlock()
+
+	// devices are forgotten once their session is closed.
+	require.NoError(t, client.Session().Close(context.Background()))
+	<-device
+	require.Eventually(t, func() bool {
+		srv.mu.Lock()
+		defer srv.mu.Unlock()
+		return len(srv.devices) == 0
+	}, time.Second, time.Millisecond)
+}
+
+func TestCallHomeListeners(t *testing.T) {
+	srv := NewCallHomeServer(
+		WithCallHomeListener("tcp4", "127.0.0.1:0", pipeCallHome{}),
+		WithCallHomeListener("tcp4", "127.0.0.1:0", nil),
+		WithCallHomeClientConfig("127.0.0.1", &CallHomeClientConfig{}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	listenErr := make(chan error, 1)
+	go func() { listenErr <- srv.Listen(ctx) }()
+	require.Eventually(t, func() bool { return len(srv.Addrs()) == 2 }, time.Second, time.Millisecond)
+	addrs := srv.Addrs()
+	assert.Equal(t, addrs[0], srv.Addr())
+
+	// the config has no transport so the default of the listener is used.
+	device := callHomeDevice(t, addrs[0].String())
+	client := <-srv.ClientChannel()
+	assert.EqualValues(t, 7, client.Session().SessionID())
+
+	// there is no default on the second listener.
+	conn, err := net.Dial("tcp", addrs[1].String())
+	require.NoError(t, err)
+	defer conn.Close()
+	assert.ErrorContains(t, <-srv.ErrorChannel(), "no transport")
+
+	cancel()
+	assert.ErrorIs(t, <-listenErr, context.Canceled)
+	assert.Empty(t, srv.Addrs())
+
+	require.NoError(t, srv.Shutdown(context.Background()))
+	<-device
+}
+
+func TestCallHomeListenerDefault(t *testing.T) {
+	// devices without a config are set up with the default of the listener.
+	srv := NewCallHomeServer(WithCallHomeListener("tcp", "127.0.0.1:0", pipeCallHome{}))
+	defer srv.Shutdown(context.Background())
+	go srv.Listen(context.Background())
+	require.Eventually(t, func() bool { return srv.Addr() != nil }, time.Second, time.Millisecond)
+
+	callHomeDevice(t, srv.Addr().String())
+	client := <-srv.ClientChannel()
+	assert.Equal(t, "addr:127.0.0.1", client.Identity().ID())
+}
+
+func TestCallHomeListenFails(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	srv := NewCallHomeServer(
+		WithCallHomeListener("tcp", "127.0.0.1:0", nil),
+		WithCallHomeListener("tcp", ln.Addr().String(), nil))
+	defer srv.Shutdown(context.Background())
+	assert.Error(t, srv.Listen(context.Background()))
+	assert.Empty(t, srv.Addrs())
+}
+
+func TestCallHomeAuthorize(t *testing.T) {
+	errDenied := errors.New("not on the allow list")
+	stats := &CallHomeStats{}
+	srv, _ := startCallHomeServer(t,
+		WithCallHomeClientConfig("127.0.0.1", &CallHomeClientConfig{}),
+		WithCallHomeMetrics(stats),
+		WithCallHomeAuthorize(func(conn net.Conn) error {
+			return errDenied
+		}))
+	defer srv.Shutdown(context.Background())
+
+	conn, err := net.Dial("tcp", srv.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// rejected before the config (without a transport) is used.
+	clientErr := <-srv.ErrorChannel()
+	assert.ErrorIs(t, clientErr, errDenied)
+	assert.ErrorIs(t, clientErr, ErrUnauthorized)
+	assert.Nil(t, clientErr.Identity)
+	assert.EqualValues(t, 1, stats.Snapshot().Rejected)
+
+	_, err = conn.Read(make([]byte, 1))
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestCallHomeSessionOpenError(t *testing.T) {
+	srv, _ := startCallHomeServer(t,
+		WithCallHomeClientConfig("127.0.0.1", &CallHomeClientConfig{Transport: pipeCallHome{}}))
+	defer srv.Shutdown(context.Background())
+
+	// the device hangs up instead of sending its hello.
+	conn, err := net.Dial("tcp", srv.Addr().String())
+	require.NoError(t, err)
+	conn.Close()
+
+	clientErr := <-srv.ErrorChannel()
+	assert.ErrorIs(t, clientErr, ErrSessionOpen)
+	require.NotNil(t, clientErr.Identity)
+	assert.Equal(t, "addr:127.0.0.1", clientErr.Identity.ID())
+}
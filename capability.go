@@ -1,5 +1,11 @@
 package netconf
 
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
 const (
 	baseCap      = "urn:ietf:params:netconf:base"
 	stdCapPrefix = "urn:ietf:params:netconf:capability"
@@ -41,39 +47,212 @@ func ExpandCapability(s string) string {
 	return stdCapPrefix + s
 }
 
-// XXX: may want to expose this type publicly in the future when the api has
-// stabilized?
-type capabilitySet struct {
-	caps map[string]struct{}
+// CapabilitySet is a set of capability URIs as exchanged in the `<hello>`
+// messages.  Lookups match on the base URI of a capability ignoring any query
+// parameters (i.e `:with-defaults:1.0` matches
+// `urn:ietf:params:netconf:capability:with-defaults:1.0?basic-mode=explicit`).
+// NETCONF capabilities looked up without a version match any version (i.e
+// `:candidate` matches `urn:ietf:params:netconf:capability:candidate:1.0`).
+type CapabilitySet struct {
+	// caps maps the base uri to the full capability
+	caps map[string]string
 }
 
-func newCapabilitySet(capabilities ...string) capabilitySet {
-	cs := capabilitySet{
-		caps: make(map[string]struct{}),
+// NewCapabilitySet returns a set of the given capabilities.  Capabilities
+// can use the short form accepted by [ExpandCapability].
+func NewCapabilitySet(capabilities ...string) CapabilitySet {
+	cs := CapabilitySet{
+		caps: make(map[string]string),
 	}
 	cs.Add(capabilities...)
 	return cs
 }
 
-func (cs *capabilitySet) Add(capabilities ...string) {
+// Add adds capabilities to the set.  A capability with the same base URI as
+// an existing one replaces it.
+func (cs *CapabilitySet) Add(capabilities ...string) {
+	if cs.caps == nil {
+		cs.caps = make(map[string]string)
+	}
 	for _, cap := range capabilities {
 		cap = ExpandCapability(cap)
-		cs.caps[cap] = struct{}{}
+		cs.caps[capabilityBase(cap)] = cap
 	}
 }
 
-func (cs capabilitySet) Has(s string) bool {
-	// XXX: need to figure out how to handle versions (i.e always map to 1.0 or
-	// map to latest/any?)
-	s = ExpandCapability(s)
-	_, ok := cs.caps[s]
+// clone returns a copy of the set.
+func (cs CapabilitySet) clone() CapabilitySet {
+	out := CapabilitySet{caps: make(map[string]string, len(cs.caps))}
+	for base, cap := range cs.caps {
+		out.caps[base] = cap
+	}
+	return out
+}
+
+// Has returns true if the set contains a capability with the same base URI as
+// s.  Query parameters in s are ignored and if s is a NETCONF capability
+// without a version any version matches.
+func (cs CapabilitySet) Has(s string) bool {
+	_, ok := cs.Get(s)
 	return ok
 }
 
-func (cs capabilitySet) All() []string {
+// Get returns the full capability (including any query parameters) with the
+// same base URI as s.  If s is a NETCONF capability without a version the
+// capability with the highest version is returned.
+func (cs CapabilitySet) Get(s string) (string, bool) {
+	base := capabilityBase(ExpandCapability(s))
+	if cap, ok := cs.caps[base]; ok {
+		return cap, true
+	}
+	if !strings.HasPrefix(base, "urn:ietf:params:netconf:") || capabilityID(base) != base {
+		return "", false
+	}
+
+	var found string
+	for b := range cs.caps {
+		if capabilityID(b) == base && b > found {
+			found = b
+		}
+	}
+	if found == "" {
+		return "", false
+	}
+	return cs.caps[found], true
+}
+
+// All returns all the capabilities in the set sorted.
+func (cs CapabilitySet) All() []string {
 	out := make([]string, 0, len(cs.caps))
-	for cap := range cs.caps {
+	for _, cap := range cs.caps {
 		out = append(out, cap)
 	}
+	sort.Strings(out)
+	return out
+}
+
+// Len returns the number of capabilities in the set.
+func (cs CapabilitySet) Len() int { return len(cs.caps) }
+
+func capabilityBase(cap string) string {
+	base, _, _ := strings.Cut(cap, "?")
+	return base
+}
+
+// capabilityID returns the base URI of a NETCONF capability without the
+// version.
+func capabilityID(base string) string {
+	if !strings.HasPrefix(base, "urn:ietf:params:netconf:") {
+		return base
+	}
+	if i := strings.LastIndexByte(base, ':'); i > 0 && isVersion(base[i+1:]) {
+		return base[:i]
+	}
+	return base
+}
+
+// Capability is a parsed capability URI.
+//
+// Capabilities are either NETCONF capabilities (i.e
+// `urn:ietf:params:netconf:capability:url:1.0?scheme=http,ftp`) or YANG module
+// advertisements (i.e
+// `urn:ietf:params:xml:ns:yang:ietf-interfaces?module=ietf-interfaces&revision=2018-02-20`).
+type Capability struct {
+	// URI is the full capability as advertised.
+	URI string
+
+	// ID is the capability without the version or query parameters (i.e
+	// `urn:ietf:params:netconf:capability:url`).
+	ID string
+
+	// Version is the version of NETCONF capabilities (i.e `1.0`).  It is
+	// empty for capabilities without a version like YANG modules.
+	Version string
+
+	// Params are the query parameters of the capability.
+	Params url.Values
+}
+
+// ParseCapability parses a capability URI.  The short form accepted by
+// [ExpandCapability] can be used.  Malformed query parameters are ignored.
+func ParseCapability(s string) Capability {
+	s = ExpandCapability(s)
+	base, query, _ := strings.Cut(s, "?")
+
+	c := Capability{
+		URI: s,
+		ID:  base,
+	}
+
+	if id := capabilityID(base); id != base {
+		c.ID, c.Version = id, base[len(id)+1:]
+	}
+
+	// some servers escape the `&` in the capability as it is sent in xml.
+	query = strings.ReplaceAll(query, "&amp;", "&")
+	c.Params, _ = url.ParseQuery(query)
+	return c
+}
+
+func isVersion(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r != '.' && (r < '0' || r > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+func (c Capability) list(key string) []string {
+	var out []string
+	for _, v := range c.Params[key] {
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				out = append(out, item)
+			}
+		}
+	}
+	return out
+}
+
+// Module returns the `module` parameter of a YANG module advertisement.
+func (c Capability) Module() string { return c.Params.Get("module") }
+
+// Revision returns the `revision` parameter of a YANG module advertisement.
+func (c Capability) Revision() string { return c.Params.Get("revision") }
+
+// Features returns the comma separated `features` parameter of a YANG module
+// advertisement.
+func (c Capability) Features() []string { return c.list("features") }
+
+// Deviations returns the comma separated `deviations` parameter of a YANG
+// module advertisement.
+func (c Capability) Deviations() []string { return c.list("deviations") }
+
+// Schemes returns the comma separated `scheme` parameter of the `:url`
+// capability.
+func (c Capability) Schemes() []string { return c.list("scheme") }
+
+// Capability returns the parsed capability with the same base URI as s.
+func (cs CapabilitySet) Capability(s string) (Capability, bool) {
+	cap, ok := cs.Get(s)
+	if !ok {
+		return Capability{}, false
+	}
+	return ParseCapability(cap), true
+}
+
+// Modules returns the YANG modules advertised in the set (capabilities with a
+// `module` parameter) sorted by URI.
+func (cs CapabilitySet) Modules() []Capability {
+	var out []Capability
+	for _, cap := range cs.All() {
+		if c := ParseCapability(cap); c.Module() != "" {
+			out = append(out, c)
+		}
+	}
 	return out
 }
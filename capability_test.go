@@ -0,0 +1,159 @@
+package netconf
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCapabilitySet(t *testing.T) {
+	cs := NewCapabilitySet(
+		"urn:ietf:params:netconf:base:1.1",
+		":candidate:1.0",
+		"urn:ietf:params:netconf:capability:with-defaults:1.0?basic-mode=explicit",
+		"http://example.com/yang/foo?module=foo&revision=2023-06-07",
+	)
+
+	assert.Equal(t, 4, cs.Len())
+	assert.True(t, cs.Has(":candidate:1.0"))
+	assert.True(t, cs.Has("urn:ietf:params:netconf:capability:candidate:1.0"))
+	assert.False(t, cs.Has(":startup:1.0"))
+	assert.True(t, cs.Has(":with-defaults:1.0"))
+	assert.True(t, cs.Has(":with-defaults:1.0?basic-mode=trim"))
+	assert.True(t, cs.Has("http://example.com/yang/foo"))
+
+	// the version is optional.
+	assert.True(t, cs.Has(":candidate"))
+	assert.True(t, cs.Has("urn:ietf:params:netconf:base"))
+	assert.False(t, cs.Has("urn:ietf:params:netconf:base:1.0"))
+	assert.True(t, cs.Has(":with-defaults?basic-mode=trim"))
+	assert.False(t, cs.Has(":startup"))
+	assert.False(t, cs.Has("http://example.com/yang"))
+
+	both := NewCapabilitySet("urn:ietf:params:netconf:base:1.0", "urn:ietf:params:netconf:base:1.1")
+	cap, ok := both.Get("urn:ietf:params:netconf:base")
+	assert.True(t, ok)
+	assert.Equal(t, "urn:ietf:params:netconf:base:1.1", cap)
+
+	cap, ok = cs.Get(":with-defaults:1.0")
+	assert.True(t, ok)
+	assert.Equal(t, "urn:ietf:params:netconf:capability:with-defaults:1.0?basic-mode=explicit", cap)
+
+	assert.Equal(t, []string{
+		"http://example.com/yang/foo?module=foo&revision=2023-06-07",
+		"urn:ietf:params:netconf:base:1.1",
+		"urn:ietf:params:netconf:capability:candidate:1.0",
+		"urn:ietf:params:netconf:capability:with-defaults:1.0?basic-mode=explicit",
+	}, cs.All())
+
+	var zero CapabilitySet
+	assert.False(t, zero.Has(":candidate:1.0"))
+	zero.Add(":startup:1.0")
+	assert.True(t, zero.Has(":startup:1.0"))
+}
+
+func TestSessionSupports(t *testing.T) {
+	sess := newSession(newTestServer(t).transport())
+	sess.serverCaps = NewCapabilitySet(":candidate:1.0", ":url:1.0?scheme=http,ftp")
+
+	assert.True(t, sess.Supports(":candidate:1.0"))
+	assert.True(t, sess.Supports(":url:1.0"))
+	assert.False(t, sess.Supports(":startup:1.0"))
+	assert.True(t, sess.ServerCapabilitySet().Has(":url:1.0"))
+
+	// without a version any version matches.
+	assert.True(t, sess.Supports(":candidate"))
+	assert.True(t, sess.Supports(":url"))
+	assert.False(t, sess.Supports(":startup"))
+	assert.False(t, sess.Supports(":candidate:1.1"))
+
+	// changing the returned set doesn't change the session.
+	cs := sess.ServerCapabilitySet()
+	cs.Add(":startup:1.0")
+	assert.True(t, cs.Has(":startup:1.0"))
+	assert.False(t, sess.Supports(":startup:1.0"))
+}
+
+func TestParseCapability(t *testing.T) {
+	tt := []struct {
+		in   string
+		want Capability
+	}{
+		{
+			in: ":candidate:1.0",
+			want: Capability{
+				URI:     "urn:ietf:params:netconf:capability:candidate:1.0",
+				ID:      "urn:ietf:params:netconf:capability:candidate",
+				Version: "1.0",
+				Params:  url.Values{},
+			},
+		},
+		{
+			in: "urn:ietf:params:netconf:base:1.1",
+			want: Capability{
+				URI:     "urn:ietf:params:netconf:base:1.1",
+				ID:      "urn:ietf:params:netconf:base",
+				Version: "1.1",
+				Params:  url.Values{},
+			},
+		},
+		{
+			in: ":url:1.0?scheme=http,ftp,file",
+			want: Capability{
+				URI:     "urn:ietf:params:netconf:capability:url:1.0?scheme=http,ftp,file",
+				ID:      "urn:ietf:params:netconf:capability:url",
+				Version: "1.0",
+				Params:  url.Values{"scheme": {"http,ftp,file"}},
+			},
+		},
+		{
+			in: "urn:ietf:params:xml:ns:yang:ietf-interfaces?module=ietf-interfaces&amp;revision=2018-02-20&amp;features=arbitrary-names,pre-provisioning&amp;deviations=vendor-dev",
+			want: Capability{
+				URI: "urn:ietf:params:xml:ns:yang:ietf-interfaces?module=ietf-interfaces&amp;revision=2018-02-20&amp;features=arbitrary-names,pre-provisioning&amp;deviations=vendor-dev",
+				ID:  "urn:ietf:params:xml:ns:yang:ietf-interfaces",
+				Params: url.Values{
+					"module":     {"ietf-interfaces"},
+					"revision":   {"2018-02-20"},
+					"features":   {"arbitrary-names,pre-provisioning"},
+					"deviations": {"vendor-dev"},
+				},
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.in, func(t *testing.T) {
+			assert.Equal(t, tc.want, ParseCapability(tc.in))
+		})
+	}
+
+	c := ParseCapability(tt[3].in)
+	assert.Equal(t, "ietf-interfaces", c.Module())
+	assert.Equal(t, "2018-02-20", c.Revision())
+	assert.Equal(t, []string{"arbitrary-names", "pre-provisioning"}, c.Features())
+	assert.Equal(t, []string{"vendor-dev"}, c.Deviations())
+	assert.Equal(t, []string{"http", "ftp", "file"}, ParseCapability(tt[2].in).Schemes())
+}
+
+func TestCapabilitySetModules(t *testing.T) {
+	cs := NewCapabilitySet(
+		":candidate:1.0",
+		":url:1.0?scheme=https",
+		"urn:ietf:params:xml:ns:yang:ietf-interfaces?module=ietf-interfaces&revision=2018-02-20",
+		"http://openconfig.net/yang/system?module=openconfig-system",
+	)
+
+	var modules []string
+	for _, c := range cs.Modules() {
+		modules = append(modules, c.Module())
+	}
+	assert.Equal(t, []string{"openconfig-system", "ietf-interfaces"}, modules)
+
+	c, ok := cs.Capability(":url:1.0")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"https"}, c.Schemes())
+
+	_, ok = cs.Capability(":startup:1.0")
+	assert.False(t, ok)
+}
@@ -0,0 +1,141 @@
+package netconf
+
+import (
+	"strings"
+)
+
+// YANGModule is a YANG module implemented by a server.
+type YANGModule struct {
+	// Namespace is the XML namespace of the module.
+	Namespace string
+
+	// Name and Revision identify the module (i.e `ietf-interfaces` and
+	// `2018-02-20`).
+	Name     string
+	Revision string
+
+	// Features are the features of the module that are supported.
+	Features []string
+
+	// Deviations are the names of the modules with deviations to this one.
+	Deviations []string
+}
+
+// Capability returns the capability advertising the module in the hello as
+// described in RFC6020 section 5.6.4 (i.e
+// `urn:ietf:params:xml:ns:yang:ietf-interfaces?module=ietf-interfaces&revision=2018-02-20`).
+func (m YANGModule) Capability() string {
+	var sb strings.Builder
+	sb.WriteString(m.Namespace)
+	sb.WriteString("?module=")
+	sb.WriteString(m.Name)
+	if m.Revision != "" {
+		sb.WriteString("&revision=")
+		sb.WriteString(m.Revision)
+	}
+	if len(m.Features) > 0 {
+		sb.WriteString("&features=")
+		sb.WriteString(strings.Join(m.Features, ","))
+	}
+	if len(m.Deviations) > 0 {
+		sb.WriteString("&deviations=")
+		sb.WriteString(strings.Join(m.Deviations, ","))
+	}
+	return sb.String()
+}
+
+// CapabilityBuilder builds the capabilities a server advertises in its hello.
+// Methods return the builder so calls can be chained:
+//
+//	caps := netconf.NewCapabilityBuilder().
+//		Base("1.0", "1.1").
+//		Add(":candidate:1.0", ":validate:1.1").
+//		URL("http", "file").
+//		WithDefaults(netconf.DefaultsExplicit, netconf.DefaultsReportAll).
+//		Modules(modules...).
+//		Build()
+//
+// A capability with the same base URI as an earlier one replaces it.
+type CapabilityBuilder struct {
+	caps  []string
+	index map[string]int
+}
+
+// NewCapabilityBuilder returns an empty builder.
+func NewCapabilityBuilder() *CapabilityBuilder {
+	return &CapabilityBuilder{index: make(map[string]int)}
+}
+
+// Add adds capabilities.  Capabilities can use the short form accepted by
+// [ExpandCapability].
+func (b *CapabilityBuilder) Add(capabilities ...string) *CapabilityBuilder {
+	for _, cap := range capabilities {
+		cap = ExpandCapability(cap)
+		base := capabilityBase(cap)
+		if i, ok := b.index[base]; ok {
+			b.caps[i] = cap
+			continue
+		}
+		b.index[base] = len(b.caps)
+		b.caps = append(b.caps, cap)
+	}
+	return b
+}
+
+// Base adds the base protocol capabilities for the given versions (i.e `1.1`
+// for `urn:ietf:params:netconf:base:1.1`).
+func (b *CapabilityBuilder) Base(versions ...string) *CapabilityBuilder {
+	for _, v := range versions {
+		b.Add(baseCap + ":" + v)
+	}
+	return b
+}
+
+// Standard adds a standard NETCONF capability by name and version with
+// optional parameters given as key and value pairs (i.e
+// `Standard("with-defaults", "1.0", "basic-mode", "explicit")`).
+func (b *CapabilityBuilder) Standard(name, version string, params ...string) *CapabilityBuilder {
+	var sb strings.Builder
+	sb.WriteString(stdCapPrefix + ":" + name + ":" + version)
+	for i := 0; i+1 < len(params); i += 2 {
+		if i == 0 {
+			sb.WriteByte('?')
+		} else {
+			sb.WriteByte('&')
+		}
+		sb.WriteString(params[i] + "=" + params[i+1])
+	}
+	return b.Add(sb.String())
+}
+
+// URL adds the `:url:1.0` capability with the supported schemes.
+func (b *CapabilityBuilder) URL(schemes ...string) *CapabilityBuilder {
+	return b.Standard("url", "1.0", "scheme", strings.Join(schemes, ","))
+}
+
+// WithDefaults adds the `:with-defaults:1.0` capability (RFC6243) with the
+// basic mode of the server and the other modes it supports.
+func (b *CapabilityBuilder) WithDefaults(basic DefaultsMode, alsoSupported ...DefaultsMode) *CapabilityBuilder {
+	params := []string{"basic-mode", string(basic)}
+	if len(alsoSupported) > 0 {
+		modes := make([]string, len(alsoSupported))
+		for i, m := range alsoSupported {
+			modes[i] = string(m)
+		}
+		params = append(params, "also-supported", strings.Join(modes, ","))
+	}
+	return b.Standard("with-defaults", "1.0", params...)
+}
+
+// Modules adds the capabilities advertising YANG modules.
+func (b *CapabilityBuilder) Modules(modules ...YANGModule) *CapabilityBuilder {
+	for _, m := range modules {
+		b.Add(m.Capability())
+	}
+	return b
+}
+
+// Build returns the capabilities in the order they were first added.
+func (b *CapabilityBuilder) Build() []string {
+	return append([]string(nil), b.caps...)
+}
@@ -0,0 +1,54 @@
+package netconf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCapabilityBuilder(t *testing.T) {
+	caps := NewCapabilityBuilder().
+		Base("1.0", "1.1").
+		Add(":candidate:1.0", ":validate:1.0").
+		Standard("confirmed-commit", "1.1").
+		URL("http", "file").
+		WithDefaults(DefaultsExplicit, DefaultsReportAll, DefaultsTrim).
+		Modules(
+			YANGModule{
+				Namespace: "urn:ietf:params:xml:ns:yang:ietf-interfaces",
+				Name:      "ietf-interfaces",
+				Revision:  "2018-02-20",
+				Features:  []string{"arbitrary-names", "pre-provisioning"},
+			},
+			YANGModule{
+				Namespace:  "http://example.com/system",
+				Name:       "example-system",
+				Deviations: []string{"example-deviations"},
+			},
+		).
+		// replaces the earlier url capability in place.
+		URL("https").
+		Build()
+
+	assert.Equal(t, []string{
+		"urn:ietf:params:netconf:base:1.0",
+		"urn:ietf:params:netconf:base:1.1",
+		"urn:ietf:params:netconf:capability:candidate:1.0",
+		"urn:ietf:params:netconf:capability:validate:1.0",
+		"urn:ietf:params:netconf:capability:confirmed-commit:1.1",
+		"urn:ietf:params:netconf:capability:url:1.0?scheme=https",
+		"urn:ietf:params:netconf:capability:with-defaults:1.0?basic-mode=explicit&also-supported=report-all,trim",
+		"urn:ietf:params:xml:ns:yang:ietf-interfaces?module=ietf-interfaces&revision=2018-02-20&features=arbitrary-names,pre-provisioning",
+		"http://example.com/system?module=example-system&deviations=example-deviations",
+	}, caps)
+
+	// the built capabilities parse back to the same values.
+	cs := NewCapabilitySet(caps...)
+	wd, _ := cs.Capability(":with-defaults:1.0")
+	assert.Equal(t, []string{"report-all", "trim"}, wd.list("also-supported"))
+	mods := cs.Modules()
+	assert.Len(t, mods, 2)
+	assert.Equal(t, "example-system", mods[0].Module())
+	assert.Equal(t, []string{"example-deviations"}, mods[0].Deviations())
+	assert.Equal(t, []string{"arbitrary-names", "pre-provisioning"}, mods[1].Features())
+}
@@ -0,0 +1,116 @@
+// Package clock provides the time source used by netconf sessions for
+// timeouts, idle tracking and timestamps so that tests can control time with
+// a [Fake] clock instead of sleeping.
+package clock
+
+import (
+	"context"
+	"time"
+)
+
+// Clock is a source of time.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+
+	// NewTimer creates a timer that sends the current time on its channel
+	// after at least d.
+	NewTimer(d time.Duration) Timer
+
+	// AfterFunc waits for d to elapse and then calls f in its own goroutine.
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is a single event timer like time.Timer.
+type Timer interface {
+	// C returns the channel the time is sent on when the timer fires.  It is
+	// nil for timers created with AfterFunc.
+	C() <-chan time.Time
+
+	// Stop prevents the timer from firing.  It returns false if the timer
+	// has already fired or been stopped.
+	Stop() bool
+
+	// Reset changes the timer to fire after d.  It returns true if the timer
+	// was active.
+	Reset(d time.Duration) bool
+}
+
+// Real is the clock using the system time.
+var Real Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	t := time.NewTimer(d)
+	return realTimer{t: t, c: t.C}
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return realTimer{t: time.AfterFunc(d, f)}
+}
+
+type realTimer struct {
+	t *time.Timer
+	c <-chan time.Time
+}
+
+func (t realTimer) C() <-chan time.Time        { return t.c }
+func (t realTimer) Stop() bool                 { return t.t.Stop() }
+func (t realTimer) Reset(d time.Duration) bool { return t.t.Reset(d) }
+
+// Or returns c or Real if c is nil.
+func Or(c Clock) Clock {
+	if c == nil {
+		return Real
+	}
+	return c
+}
+
+// Since returns the time elapsed since t according to c.
+func Since(c Clock, t time.Time) time.Duration {
+	return c.Now().Sub(t)
+}
+
+// WithTimeout is like context.WithTimeout but the deadline is measured with
+// c.  With the [Real] clock it is context.WithTimeout.
+func WithTimeout(ctx context.Context, c Clock, d time.Duration) (context.Context, context.CancelFunc) {
+	if c == Real {
+		return context.WithTimeout(ctx, d)
+	}
+
+	deadline := c.Now().Add(d)
+	if cur, ok := ctx.Deadline(); ok && cur.Before(deadline) {
+		// the parent expires first.
+		return context.WithCancel(ctx)
+	}
+
+	inner, cancel := context.WithCancelCause(ctx)
+	dctx := &deadlineCtx{Context: inner, deadline: deadline}
+	t := c.AfterFunc(d, func() { cancel(context.DeadlineExceeded) })
+	return dctx, func() {
+		t.Stop()
+		cancel(context.Canceled)
+	}
+}
+
+// deadlineCtx reports a deadline from a clock other than the system clock
+// and returns context.DeadlineExceeded once it is reached.
+type deadlineCtx struct {
+	context.Context
+	deadline time.Time
+}
+
+func (c *deadlineCtx) Deadline() (time.Time, bool) { return c.deadline, true }
+
+func (c *deadlineCtx) Err() error {
+	if err := c.Context.Err(); err != nil {
+		if cause := context.Cause(c.Context); cause == context.DeadlineExceeded {
+			return cause
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package clock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var epoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestFakeTimer(t *testing.T) {
+	f := NewFake(epoch)
+	timer := f.NewTimer(time.Minute)
+	assert.Equal(t, 1, f.Timers())
+
+	f.Advance(59 * time.Second)
+	select {
+	case <-timer.C():
+		t.Fatal("timer fired early")
+	default:
+	}
+
+	f.Advance(time.Second)
+	select {
+	case now := <-timer.C():
+		assert.Equal(t, epoch.Add(time.Minute), now)
+	default:
+		t.Fatal("timer didn't fire")
+	}
+	assert.Equal(t, 0, f.Timers())
+	assert.False(t, timer.Stop())
+
+	assert.False(t, timer.Reset(time.Second))
+	assert.True(t, timer.Stop())
+	f.Advance(time.Hour)
+	select {
+	case <-timer.C():
+		t.Fatal("stopped timer fired")
+	default:
+	}
+}
+
+func TestFakeAfterFunc(t *testing.T) {
+	f := NewFake(epoch)
+	fired := make(chan int, 2)
+	f.AfterFunc(2*time.Second, func() { fired <- 2 })
+	f.AfterFunc(time.Second, func() { fired <- 1 })
+
+	f.Set(epoch.Add(time.Second))
+	assert.Equal(t, 1, <-fired)
+	f.Advance(time.Second)
+	assert.Equal(t, 2, <-fired)
+	assert.Equal(t, epoch.Add(2*time.Second), f.Now())
+	assert.Equal(t, 2*time.Second, Since(f, epoch))
+}
+
+func TestFakeBlockUntil(t *testing.T) {
+	f := NewFake(epoch)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		<-f.NewTimer(time.Second).C()
+	}()
+
+	f.BlockUntil(1)
+	f.Advance(time.Second)
+	<-done
+}
+
+func TestWithTimeout(t *testing.T) {
+	f := NewFake(epoch)
+	ctx, cancel := WithTimeout(context.Background(), f, time.Minute)
+	defer cancel()
+
+	deadline, ok := ctx.Deadline()
+	require.True(t, ok)
+	assert.Equal(t, epoch.Add(time.Minute), deadline)
+	assert.NoError(t, ctx.Err())
+
+	f.Advance(time.Minute)
+	<-ctx.Done()
+	assert.ErrorIs(t, ctx.Err(), context.DeadlineExceeded)
+
+	ctx, cancel = WithTimeout(context.Background(), f, time.Minute)
+	cancel()
+	assert.ErrorIs(t, ctx.Err(), context.Canceled)
+	assert.Equal(t, 0, f.Timers())
+}
@@ -0,0 +1,150 @@
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Fake is a clock that only moves when told to.  Timers fire when the clock
+// is moved past their deadline with [Fake.Advance] or [Fake.Set].  It is safe
+// for concurrent use.
+type Fake struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	now    time.Time
+	timers []*fakeTimer
+}
+
+// NewFake returns a fake clock set to now.
+func NewFake(now time.Time) *Fake {
+	f := &Fake{now: now}
+	f.cond = sync.NewCond(&f.mu)
+	return f
+}
+
+// Now implements Clock.
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// NewTimer implements Clock.
+func (f *Fake) NewTimer(d time.Duration) Timer {
+	t := &fakeTimer{f: f, c: make(chan time.Time, 1)}
+	t.Reset(d)
+	return t
+}
+
+// AfterFunc implements Clock.
+func (f *Fake) AfterFunc(d time.Duration, fn func()) Timer {
+	t := &fakeTimer{f: f, fn: fn}
+	t.Reset(d)
+	return t
+}
+
+// Advance moves the clock forward by d firing any timers that expire.
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	now := f.now.Add(d)
+	f.mu.Unlock()
+	f.Set(now)
+}
+
+// Set moves the clock to t firing any timers that expire.  Timers fire in
+// order of their deadline.
+func (f *Fake) Set(t time.Time) {
+	f.mu.Lock()
+	f.now = t
+
+	var expired []*fakeTimer
+	active := f.timers[:0]
+	for _, ft := range f.timers {
+		if !ft.when.After(t) {
+			expired = append(expired, ft)
+		} else {
+			active = append(active, ft)
+		}
+	}
+	f.timers = active
+	f.mu.Unlock()
+
+	sort.SliceStable(expired, func(i, j int) bool { return expired[i].when.Before(expired[j].when) })
+	for _, ft := range expired {
+		ft.fire(t)
+	}
+}
+
+// Timers returns the number of active timers.
+func (f *Fake) Timers() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.timers)
+}
+
+// BlockUntil waits until there are at least n active timers.  This is useful
+// to wait for code running in another goroutine to start waiting on the
+// clock before advancing it.
+func (f *Fake) BlockUntil(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for len(f.timers) < n {
+		f.cond.Wait()
+	}
+}
+
+// remove removes a timer returning true if it was active.  Must be called with
+// f.mu held.
+func (f *Fake) remove(t *fakeTimer) bool {
+	for i, ft := range f.timers {
+		if ft == t {
+			f.timers = append(f.timers[:i], f.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+type fakeTimer struct {
+	f    *Fake
+	when time.Time
+	c    chan time.Time
+	fn   func()
+}
+
+func (t *fakeTimer) C() <-chan time.Time { return t.c }
+
+func (t *fakeTimer) Stop() bool {
+	t.f.mu.Lock()
+	defer t.f.mu.Unlock()
+	return t.f.remove(t)
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.f.mu.Lock()
+	defer t.f.mu.Unlock()
+
+	active := t.f.remove(t)
+	t.when = t.f.now.Add(d)
+	if d <= 0 {
+		// fire outside of the lock like an expired timer would.
+		now := t.f.now
+		go t.fire(now)
+		return active
+	}
+	t.f.timers = append(t.f.timers, t)
+	t.f.cond.Broadcast()
+	return active
+}
+
+func (t *fakeTimer) fire(now time.Time) {
+	if t.fn != nil {
+		go t.fn()
+		return
+	}
+	select {
+	case t.c <- now:
+	default:
+	}
+}
@@ -0,0 +1,228 @@
+package netconf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nemith/netconf/clock"
+)
+
+// ErrCommitReverted is returned when confirming or canceling a confirmed
+// commit that the server has already reverted because the session that issued
+// it was closed or the confirm timeout expired.
+var ErrCommitReverted = errors.New("confirmed commit reverted: not confirmed before the issuing session closed or the timeout expired")
+
+// ConfirmedCommit is an outstanding confirmed commit created with
+// [Session.CommitConfirmed].
+//
+// Per RFC6241 section 8.4 the server reverts a confirmed commit made without
+// `<persist>` if the session that issued it is closed for any reason before it
+// is confirmed and any confirmed commit once the confirm timeout expires.
+// ConfirmedCommit watches the issuing session and the timeout and reports the
+// implied revert through [ConfirmedCommit.Reverted] and by returning
+// [ErrCommitReverted] instead of sending a confirmation the server will
+// reject.
+//
+// A commit issued with [WithPersist] survives the loss of the session and can
+// be confirmed or canceled from a new session with [ConfirmedCommit.ConfirmOn]
+// and [ConfirmedCommit.CancelOn].
+type ConfirmedCommit struct {
+	sess     *Session
+	persist  string
+	timeout  time.Duration
+	deadline time.Time
+	reverted chan struct{}
+
+	// finished is closed once the commit is confirmed or canceled to stop
+	// watching the session and watched is closed when the watch stops.
+	finished chan struct{}
+	watched  chan struct{}
+
+	mu sync.Mutex
+	// done is set once the commit is confirmed, canceled or reverted.
+	done bool
+	// finishing is set while a confirm or cancel is in-flight and expired
+	// if the timeout expired meanwhile.
+	finishing bool
+	expired   bool
+}
+
+// CommitConfirmed issues a confirmed `<commit>` and returns a handle to
+// confirm or cancel it.  The commit is always marked as confirmed; use
+// [WithConfirmedTimeout] to set the timeout and [WithPersist] to allow it to
+// be confirmed from another session.
+func (s *Session) CommitConfirmed(ctx context.Context, opts ...CommitOption) (*ConfirmedCommit, error) {
+	req := CommitReq{Confirmed: true}
+	for _, opt := range opts {
+		opt.apply(&req)
+	}
+
+	if req.PersistID != "" {
+		return nil, fmt.Errorf("PersistID cannot be used with a confirmed commit")
+	}
+
+	start := s.clock.Now()
+	var resp OKResp
+	if err := s.Call(ctx, &req, &resp); err != nil {
+		return nil, err
+	}
+
+	timeout := 600 * time.Second
+	if req.ConfirmTimeout > 0 {
+		timeout = time.Duration(req.ConfirmTimeout) * time.Second
+	}
+
+	c := &ConfirmedCommit{
+		sess:     s,
+		persist:  req.Persist,
+		timeout:  timeout - clock.Since(s.clock, start),
+		deadline: start.Add(timeout),
+		reverted: make(chan struct{}),
+		finished: make(chan struct{}),
+		watched:  make(chan struct{}),
+	}
+	go c.watch()
+	return c, nil
+}
+
+// watch reports the commit as reverted when the issuing session is closed
+// (unless it was persisted) or the timeout expires.
+func (c *ConfirmedCommit) watch() {
+	defer close(c.watched)
+
+	t := c.sess.clock.NewTimer(c.timeout)
+	defer t.Stop()
+
+	var sessDone <-chan struct{}
+	if c.persist == "" {
+		sessDone = c.sess.Done()
+	}
+
+	var expired bool
+	select {
+	case <-sessDone:
+	case <-t.C():
+		expired = true
+	case <-c.finished:
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.finishing {
+		// the in-flight confirm or cancel decides.
+		c.expired = expired
+		return
+	}
+	c.revert()
+}
+
+// revert reports the commit as reverted.  Must be called with c.mu held.
+func (c *ConfirmedCommit) revert() {
+	if !c.done {
+		c.done = true
+		close(c.reverted)
+	}
+}
+
+// PersistID returns the id given with [WithPersist] or an empty string if the
+// commit is bound to the issuing session.
+func (c *ConfirmedCommit) PersistID() string { return c.persist }
+
+// Deadline returns the time the server will revert the commit if it isn't
+// confirmed.  It is calculated from when the commit was sent so the server
+// may revert slightly before.
+func (c *ConfirmedCommit) Deadline() time.Time { return c.deadline }
+
+// Reverted returns a channel that is closed when the commit wasn't confirmed or
+// canceled before the confirm timeout expired or (unless it was persisted)
+// the issuing session was closed, meaning the server has reverted the
+// configuration.
+func (c *ConfirmedCommit) Reverted() <-chan struct{} { return c.reverted }
+
+// Confirm confirms the commit on the issuing session.
+func (c *ConfirmedCommit) Confirm(ctx context.Context) error {
+	return c.ConfirmOn(ctx, c.sess)
+}
+
+// ConfirmOn confirms the commit on the given session.  Commits without a
+// persist id can only be confirmed on the issuing session.
+func (c *ConfirmedCommit) ConfirmOn(ctx context.Context, s *Session) error {
+	var opts []CommitOption
+	if c.persist != "" {
+		opts = append(opts, WithPersistID(c.persist))
+	}
+	return c.finish(s, func() error { return s.Commit(ctx, opts...) })
+}
+
+// Cancel cancels the commit on the issuing session reverting the
+// configuration immediately.
+func (c *ConfirmedCommit) Cancel(ctx context.Context) error {
+	return c.CancelOn(ctx, c.sess)
+}
+
+// CancelOn cancels the commit on the given session.  Commits without a
+// persist id can only be canceled on the issuing session.
+func (c *ConfirmedCommit) CancelOn(ctx context.Context, s *Session) error {
+	var opts []CancelCommitOption
+	if c.persist != "" {
+		opts = append(opts, WithPersistID(c.persist))
+	}
+	return c.finish(s, func() error { return s.CancelCommit(ctx, opts...) })
+}
+
+func (c *ConfirmedCommit) finish(s *Session, fn func() error) error {
+	if c.persist == "" && s != c.sess {
+		return fmt.Errorf("confirmed commit without a persist id can only be completed on the issuing session")
+	}
+
+	c.mu.Lock()
+	select {
+	case <-c.reverted:
+		c.mu.Unlock()
+		return ErrCommitReverted
+	default:
+	}
+	if c.done {
+		c.mu.Unlock()
+		return fmt.Errorf("confirmed commit already confirmed or canceled")
+	}
+	if c.finishing {
+		c.mu.Unlock()
+		return fmt.Errorf("confirmed commit is already being confirmed or canceled")
+	}
+	c.finishing = true
+	c.mu.Unlock()
+
+	err := fn()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.finishing = false
+
+	if err != nil {
+		// the session may have been lost or the timeout expired while
+		// waiting for the reply.
+		lost := false
+		if c.persist == "" {
+			select {
+			case <-c.sess.Done():
+				lost = true
+			default:
+				lost = errors.Is(err, ErrClosed)
+			}
+		}
+		if lost || c.expired {
+			c.revert()
+			return fmt.Errorf("%w: %w", ErrCommitReverted, err)
+		}
+		return err
+	}
+
+	c.done = true
+	close(c.finished)
+	return nil
+}
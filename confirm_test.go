@@ -0,0 +1,214 @@
+package netconf
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nemith/netconf/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfirmedCommitSessionLost(t *testing.T) {
+	ts := newTestServer(t)
+	tr := ts.transport()
+	sess := newSession(tr, WithErrorHandler(func(error) {}))
+	done := make(chan struct{})
+	go func() {
+		sess.recv()
+		close(done)
+	}()
+
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><ok/></rpc-reply>`)
+	start := time.Now()
+	cc, err := sess.CommitConfirmed(context.Background(), WithConfirmedTimeout(2*time.Minute))
+	require.NoError(t, err)
+	req, err := ts.popReqString()
+	require.NoError(t, err)
+	assert.Contains(t, req, "<commit><confirmed></confirmed><confirm-timeout>120</confirm-timeout></commit>")
+	assert.WithinDuration(t, start.Add(2*time.Minute), cc.Deadline(), time.Second)
+
+	// an empty message is read as the connection closing
+	tr.pushMsg("")
+	<-done
+
+	select {
+	case <-cc.Reverted():
+	case <-time.After(time.Second):
+		t.Fatal("commit not reported as reverted")
+	}
+	assert.ErrorIs(t, cc.Confirm(context.Background()), ErrCommitReverted)
+	assert.ErrorIs(t, cc.Cancel(context.Background()), ErrCommitReverted)
+}
+
+func TestConfirmedCommitPersist(t *testing.T) {
+	ts := newTestServer(t)
+	tr := ts.transport()
+	sess := newSession(tr, WithErrorHandler(func(error) {}))
+	done := make(chan struct{})
+	go func() {
+		sess.recv()
+		close(done)
+	}()
+
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><ok/></rpc-reply>`)
+	cc, err := sess.CommitConfirmed(context.Background(), WithPersist("change-1"))
+	require.NoError(t, err)
+	_, err = ts.popReqString()
+	require.NoError(t, err)
+	assert.Equal(t, "change-1", cc.PersistID())
+
+	tr.pushMsg("")
+	<-done
+
+	select {
+	case <-cc.Reverted():
+		t.Fatal("persisted commit reported as reverted")
+	default:
+	}
+
+	// confirm from a new session
+	ts2 := newTestServer(t)
+	sess2 := newSession(ts2.transport())
+	go sess2.recv()
+
+	ts2.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><ok/></rpc-reply>`)
+	require.NoError(t, cc.ConfirmOn(context.Background(), sess2))
+	req, err := ts2.popReqString()
+	require.NoError(t, err)
+	assert.Contains(t, req, "<commit><persist-id>change-1</persist-id></commit>")
+}
+
+func TestConfirmedCommitOtherSession(t *testing.T) {
+	ts := newTestServer(t)
+	sess := newSession(ts.transport())
+	go sess.recv()
+
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><ok/></rpc-reply>`)
+	cc, err := sess.CommitConfirmed(context.Background())
+	require.NoError(t, err)
+	_, err = ts.popReqString()
+	require.NoError(t, err)
+
+	other := newSession(newTestServer(t).transport())
+	assert.Error(t, cc.ConfirmOn(context.Background(), other))
+
+	_, err = sess.CommitConfirmed(context.Background(), WithPersistID("x"))
+	assert.Error(t, err)
+}
+
+func TestConfirmedCommitStopsWatching(t *testing.T) {
+	ts := newTestServer(t)
+	sess := newSession(ts.transport())
+	go sess.recv()
+
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><ok/></rpc-reply>`)
+	cc, err := sess.CommitConfirmed(context.Background())
+	require.NoError(t, err)
+	_, err = ts.popReqString()
+	require.NoError(t, err)
+
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="2"><ok/></rpc-reply>`)
+	require.NoError(t, cc.Confirm(context.Background()))
+	_, err = ts.popReqString()
+	require.NoError(t, err)
+
+	// the session is still open but there is nothing left to watch.
+	select {
+	case <-cc.watched:
+	case <-time.After(time.Second):
+		t.Fatal("still watching the session after the commit was confirmed")
+	}
+	select {
+	case <-cc.Reverted():
+		t.Fatal("confirmed commit reported as reverted")
+	default:
+	}
+}
+
+func TestConfirmedCommitTimeout(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	ts := newTestServer(t)
+	sess := newSession(ts.transport(), WithClock(clk))
+	go sess.recv()
+
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><ok/></rpc-reply>`)
+	cc, err := sess.CommitConfirmed(context.Background(), WithConfirmedTimeout(time.Minute), WithPersist("change-1"))
+	require.NoError(t, err)
+	_, err = ts.popReqString()
+	require.NoError(t, err)
+
+	clk.BlockUntil(1)
+	clk.Advance(time.Minute)
+
+	select {
+	case <-cc.Reverted():
+	case <-time.After(time.Second):
+		t.Fatal("commit not reported as reverted after the timeout")
+	}
+	assert.ErrorIs(t, cc.Confirm(context.Background()), ErrCommitReverted)
+}
+
+func TestConfirmedCommitConfirmedTwice(t *testing.T) {
+	ts := newTestServer(t)
+	tr := ts.transport()
+	sess := newSession(tr, WithErrorHandler(func(error) {}))
+	done := make(chan struct{})
+	go func() {
+		sess.recv()
+		close(done)
+	}()
+
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><ok/></rpc-reply>`)
+	cc, err := sess.CommitConfirmed(context.Background())
+	require.NoError(t, err)
+	_, err = ts.popReqString()
+	require.NoError(t, err)
+
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="2"><ok/></rpc-reply>`)
+	require.NoError(t, cc.Confirm(context.Background()))
+	_, err = ts.popReqString()
+	require.NoError(t, err)
+
+	tr.pushMsg("")
+	<-done
+
+	// the commit was confirmed before the session was lost.
+	err = cc.Confirm(context.Background())
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrCommitReverted)
+	select {
+	case <-cc.Reverted():
+		t.Fatal("confirmed commit reported as reverted")
+	default:
+	}
+}
+
+func TestConfirmedCommitInFlight(t *testing.T) {
+	ts := newTestServer(t)
+	sess := newSession(ts.transport())
+	go sess.recv()
+
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><ok/></rpc-reply>`)
+	cc, err := sess.CommitConfirmed(context.Background())
+	require.NoError(t, err)
+	_, err = ts.popReqString()
+	require.NoError(t, err)
+
+	// the reply to the confirm is held back until after the cancel.
+	confirmed := make(chan error)
+	go func() { confirmed <- cc.Confirm(context.Background()) }()
+	_, err = ts.popReqString()
+	require.NoError(t, err)
+
+	assert.Error(t, cc.Cancel(context.Background()))
+	select {
+	case <-cc.Reverted():
+		t.Fatal("commit reported as reverted")
+	default:
+	}
+
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="2"><ok/></rpc-reply>`)
+	require.NoError(t, <-confirmed)
+}
@@ -0,0 +1,128 @@
+// Package conformance implements reusable test suites that check transports
+// and servers against the NETCONF protocol rules so that third-party
+// implementations can verify themselves.
+//
+// [TestTransport] checks the message framing of a [transport.Transport] with
+// End-of-Message and Chunked framing (RFC6242) including large messages and
+// concurrent reads and writes.  [TestServer] speaks to a server over a
+// transport and checks the hello exchange, replies to rpcs, malformed input,
+// large and pipelined rpcs and closing the session.
+//
+//	func TestMyTransport(t *testing.T) {
+//		conformance.TestTransport(t, func(t *testing.T) (client, server transport.Transport) {
+//			return mytransport.Pair()
+//		})
+//	}
+package conformance
+
+import (
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/nemith/netconf/transport"
+)
+
+// Timeout is how long the suites wait for a message before failing the test.
+var Timeout = 10 * time.Second
+
+// upgrade switches tr to Chunked framing.  It reports false if tr doesn't
+// support it.
+func upgrade(tr transport.Transport) bool {
+	upgrader, ok := tr.(interface{ Upgrade() })
+	if ok {
+		upgrader.Upgrade()
+	}
+	return ok
+}
+
+func writeMsg(tr transport.Transport, msg []byte) error {
+	w, err := tr.MsgWriter()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+func readMsg(tr transport.Transport) ([]byte, error) {
+	r, err := tr.MsgReader()
+	if err != nil {
+		return nil, err
+	}
+	msg, err := io.ReadAll(r)
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+	return msg, r.Close()
+}
+
+type readResult struct {
+	msg []byte
+	err error
+}
+
+// readTimeout reads a message from tr failing the test if none is read within
+// [Timeout].  The transport is closed on a timeout to unblock the reader.
+func readTimeout(t *testing.T, tr transport.Transport) ([]byte, error) {
+	t.Helper()
+	ch := make(chan readResult, 1)
+	go func() {
+		msg, err := readMsg(tr)
+		ch <- readResult{msg, err}
+	}()
+
+	select {
+	case res := <-ch:
+		return res.msg, res.err
+	case <-time.After(Timeout):
+		tr.Close()
+		t.Fatalf("no message read after %s", Timeout)
+		return nil, nil
+	}
+}
+
+// mustRead reads a message from tr failing the test on errors.
+func mustRead(t *testing.T, tr transport.Transport) []byte {
+	t.Helper()
+	msg, err := readTimeout(t, tr)
+	if err != nil {
+		t.Fatalf("failed to read message: %v", err)
+	}
+	return msg
+}
+
+// goWrite writes msg to tr in the background as writes over unbuffered
+// transports block until the message is read.  The returned channel receives
+// the error of the write.
+func goWrite(tr transport.Transport, msg []byte) <-chan error {
+	ch := make(chan error, 1)
+	go func() { ch <- writeMsg(tr, msg) }()
+	return ch
+}
+
+// waitErr fails the test if ch doesn't receive nil within [Timeout].
+func waitErr(t *testing.T, what string, ch <-chan error) {
+	t.Helper()
+	select {
+	case err := <-ch:
+		if err != nil {
+			t.Fatalf("%s failed: %v", what, err)
+		}
+	case <-time.After(Timeout):
+		t.Fatalf("%s didn't finish after %s", what, Timeout)
+	}
+}
+
+func truncate(b []byte) string {
+	const max = 256
+	if len(b) <= max {
+		return string(b)
+	}
+	return fmt.Sprintf("%s... (%d bytes)", b[:max], len(b))
+}
@@ -0,0 +1,50 @@
+package conformance
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/nemith/netconf/server"
+	"github.com/nemith/netconf/transport"
+	"github.com/nemith/netconf/transport/transporttest"
+)
+
+func TestPipeTransport(t *testing.T) {
+	TestTransport(t, func(t *testing.T) (client, server transport.Transport) {
+		return transporttest.Pipe()
+	})
+}
+
+func TestTCPTransport(t *testing.T) {
+	TestTransport(t, func(t *testing.T) (client, server transport.Transport) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ln.Close()
+
+		accepted := make(chan net.Conn, 1)
+		go func() {
+			conn, _ := ln.Accept()
+			accepted <- conn
+		}()
+		conn, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return transport.NewPipeTransport(conn), transport.NewPipeTransport(<-accepted)
+	})
+}
+
+func TestServerPackage(t *testing.T) {
+	srv := &server.Server{}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	TestServer(t, func(t *testing.T) transport.Transport {
+		client, tr := transporttest.Pipe()
+		go func() { _ = srv.ServeTransport(ctx, tr, server.Peer{}) }()
+		return client
+	})
+}
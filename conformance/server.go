@@ -0,0 +1,285 @@
+package conformance
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/nemith/netconf/transport"
+)
+
+const (
+	baseNamespace = "urn:ietf:params:xml:ns:netconf:base:1.0"
+	baseCap10     = "urn:ietf:params:netconf:base:1.0"
+	baseCap11     = "urn:ietf:params:netconf:base:1.1"
+
+	// testNamespace is the namespace of the operation sent to servers.  It
+	// isn't expected to be supported so replies can be ok or an error.
+	testNamespace = "urn:github.com:nemith:netconf:conformance"
+)
+
+// Dial returns a transport connected to a new session on the server under
+// test.  The transport starts with End-of-Message framing and must implement
+// `Upgrade()` for the Chunked framing tests to run.  The transport is closed
+// by the suite.
+type Dial func(t *testing.T) transport.Transport
+
+// TestServer runs the server conformance suite on sessions from dial.
+func TestServer(t *testing.T, dial Dial) {
+	t.Run("Hello", func(t *testing.T) {
+		c := connect(t, dial, baseCap10, baseCap11)
+		if c.sessionID == "" || c.sessionID == "0" {
+			t.Errorf("invalid session-id %q", c.sessionID)
+		}
+		if !c.has(baseCap10) && !c.has(baseCap11) {
+			t.Errorf("no base capability in %q", c.caps)
+		}
+	})
+
+	t.Run("Base10", func(t *testing.T) {
+		c := connect(t, dial, baseCap10)
+		if !c.has(baseCap10) {
+			t.Skip("server doesn't support base:1.0")
+		}
+		c.call(t, "1", "")
+	})
+
+	t.Run("Base11", func(t *testing.T) {
+		c := connect(t, dial, baseCap11)
+		if !c.has(baseCap11) {
+			t.Skip("server doesn't support base:1.1")
+		}
+		if !c.chunked {
+			t.Skip("transport doesn't support chunked framing")
+		}
+		c.call(t, "1", "")
+	})
+
+	t.Run("Attributes", func(t *testing.T) {
+		// RFC6241 section 4.2 requires attributes of the rpc to be returned
+		// in the reply.
+		c := connect(t, dial, baseCap10, baseCap11)
+		reply := c.call(t, "abc-101", `xmlns:ex="urn:example" ex:user-id="fred"`)
+		if reply.attr("urn:example", "user-id") != "fred" {
+			t.Errorf("rpc attribute not returned in reply: %v", reply.Attrs)
+		}
+	})
+
+	t.Run("LargeMessage", func(t *testing.T) {
+		c := connect(t, dial, baseCap10, baseCap11)
+		c.send(t, fmt.Sprintf(`<rpc message-id="large" xmlns="%s"><conformance xmlns="%s">%s</conformance></rpc>`,
+			baseNamespace, testNamespace, largeMsg(4<<20)))
+		c.recvReply(t, "large")
+	})
+
+	t.Run("Pipelined", func(t *testing.T) {
+		// rpcs are sent without waiting for replies.  Servers can reply in
+		// any order.
+		c := connect(t, dial, baseCap10, baseCap11)
+		const n = 20
+
+		written := make(chan error, 1)
+		go func() {
+			for i := 0; i < n; i++ {
+				if err := writeMsg(c.tr, []byte(rpcMsg(fmt.Sprint(i), ""))); err != nil {
+					written <- err
+					return
+				}
+			}
+			written <- nil
+		}()
+
+		seen := make(map[string]bool)
+		for i := 0; i < n; i++ {
+			reply := c.recv(t)
+			if seen[reply.MessageID] {
+				t.Fatalf("duplicate reply for message-id %q", reply.MessageID)
+			}
+			seen[reply.MessageID] = true
+		}
+		waitErr(t, "writing rpcs", written)
+		for i := 0; i < n; i++ {
+			if !seen[fmt.Sprint(i)] {
+				t.Errorf("no reply for message-id %d", i)
+			}
+		}
+	})
+
+	t.Run("Malformed", func(t *testing.T) {
+		// servers either reply with an error or close the session on
+		// malformed messages but must keep working or go away.
+		msgs := map[string]string{
+			"truncated":    `<rpc message-id="bad" xmlns="` + baseNamespace + `"><get>`,
+			"not-xml":      `this is not xml`,
+			"no-operation": `<rpc message-id="bad" xmlns="` + baseNamespace + `"></rpc>`,
+			"wrong-root":   `<rpc-reply message-id="bad" xmlns="` + baseNamespace + `"><ok/></rpc-reply>`,
+		}
+		for name, msg := range msgs {
+			t.Run(name, func(t *testing.T) {
+				c := connect(t, dial, baseCap10, baseCap11)
+
+				// the error reply to the malformed message can be sent
+				// while the next rpc is written.
+				go func() {
+					if err := writeMsg(c.tr, []byte(msg)); err == nil {
+						_ = writeMsg(c.tr, []byte(rpcMsg("after", "")))
+					}
+				}()
+
+				for {
+					raw, err := readTimeout(t, c.tr)
+					if err != nil {
+						// the session was closed.
+						return
+					}
+					reply, err := parseReply(raw)
+					if err != nil {
+						t.Fatalf("invalid reply: %v: %s", err, truncate(raw))
+					}
+					if reply.MessageID == "after" {
+						return
+					}
+					if len(reply.Errors) == 0 {
+						t.Fatalf("malformed message was accepted: %s", truncate(raw))
+					}
+				}
+			})
+		}
+	})
+
+	t.Run("CloseSession", func(t *testing.T) {
+		c := connect(t, dial, baseCap10, baseCap11)
+		c.send(t, fmt.Sprintf(`<rpc message-id="close" xmlns="%s"><close-session/></rpc>`, baseNamespace))
+		reply := c.recvReply(t, "close")
+		if reply.OK == nil || len(reply.Errors) > 0 {
+			t.Fatalf("close-session failed: %s", reply.raw)
+		}
+		if _, err := readTimeout(t, c.tr); err == nil {
+			t.Fatal("session not closed after close-session")
+		}
+	})
+}
+
+type conn struct {
+	tr        transport.Transport
+	sessionID string
+	caps      []string
+	chunked   bool
+}
+
+type hello struct {
+	XMLName      xml.Name `xml:"urn:ietf:params:xml:ns:netconf:base:1.0 hello"`
+	SessionID    string   `xml:"session-id,omitempty"`
+	Capabilities []string `xml:"capabilities>capability"`
+}
+
+// connect dials a session and exchanges hellos with the given client
+// capabilities.
+func connect(t *testing.T, dial Dial, caps ...string) *conn {
+	t.Helper()
+	tr := dial(t)
+	t.Cleanup(func() { tr.Close() })
+
+	out, err := xml.Marshal(&hello{Capabilities: caps})
+	if err != nil {
+		t.Fatalf("failed to encode hello: %v", err)
+	}
+	written := goWrite(tr, out)
+
+	raw := mustRead(t, tr)
+	waitErr(t, "writing hello", written)
+
+	var h hello
+	if err := xml.Unmarshal(raw, &h); err != nil {
+		t.Fatalf("invalid server hello: %v: %s", err, truncate(raw))
+	}
+
+	c := &conn{tr: tr, sessionID: strings.TrimSpace(h.SessionID), caps: h.Capabilities}
+	for i := range c.caps {
+		c.caps[i] = strings.TrimSpace(c.caps[i])
+	}
+	for _, cap := range caps {
+		if cap == baseCap11 && c.has(baseCap11) {
+			c.chunked = upgrade(tr)
+		}
+	}
+	return c
+}
+
+func (c *conn) has(cap string) bool {
+	for _, c := range c.caps {
+		if c == cap {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *conn) send(t *testing.T, msg string) {
+	t.Helper()
+	waitErr(t, "writing message", goWrite(c.tr, []byte(msg)))
+}
+
+func (c *conn) recv(t *testing.T) *reply {
+	t.Helper()
+	raw := mustRead(t, c.tr)
+	reply, err := parseReply(raw)
+	if err != nil {
+		t.Fatalf("invalid reply: %v: %s", err, truncate(raw))
+	}
+	return reply
+}
+
+// recvReply reads a reply and checks its message-id.
+func (c *conn) recvReply(t *testing.T, msgID string) *reply {
+	t.Helper()
+	reply := c.recv(t)
+	if reply.MessageID != msgID {
+		t.Fatalf("got reply for message-id %q, want %q", reply.MessageID, msgID)
+	}
+	return reply
+}
+
+// call sends a test rpc and reads the reply to it.
+func (c *conn) call(t *testing.T, msgID, attrs string) *reply {
+	t.Helper()
+	c.send(t, rpcMsg(msgID, attrs))
+	return c.recvReply(t, msgID)
+}
+
+func rpcMsg(msgID, attrs string) string {
+	return fmt.Sprintf(`<rpc message-id="%s" xmlns="%s" %s><conformance xmlns="%s"/></rpc>`,
+		msgID, baseNamespace, attrs, testNamespace)
+}
+
+type reply struct {
+	XMLName   xml.Name   `xml:"urn:ietf:params:xml:ns:netconf:base:1.0 rpc-reply"`
+	MessageID string     `xml:"message-id,attr"`
+	Attrs     []xml.Attr `xml:",any,attr"`
+	OK        *struct{}  `xml:"ok"`
+	Errors    []string   `xml:"rpc-error>error-tag"`
+	raw       []byte
+}
+
+func parseReply(raw []byte) (*reply, error) {
+	var r reply
+	if err := xml.Unmarshal(raw, &r); err != nil {
+		return nil, err
+	}
+	if r.XMLName.Local != "rpc-reply" {
+		return nil, errors.New("not a rpc-reply")
+	}
+	r.raw = raw
+	return &r, nil
+}
+
+func (r *reply) attr(space, local string) string {
+	for _, a := range r.Attrs {
+		if a.Name.Space == space && a.Name.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,260 @@
+package conformance
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/nemith/netconf/transport"
+)
+
+// MakePipe returns a new pair of transports connected to each other.  Both
+// start with End-of-Message framing.  Transports that support Chunked framing
+// implement `Upgrade()` like [transport.Framer].  The transports are closed by
+// the suite.
+type MakePipe func(t *testing.T) (client, server transport.Transport)
+
+// TestTransport runs the transport conformance suite on transports from mp
+// with End-of-Message framing and, if supported, Chunked framing.
+func TestTransport(t *testing.T, mp MakePipe) {
+	t.Run("EOM", func(t *testing.T) {
+		testFraming(t, mp, false)
+	})
+	t.Run("Chunked", func(t *testing.T) {
+		testFraming(t, mp, true)
+	})
+}
+
+func testFraming(t *testing.T, mp MakePipe, chunked bool) {
+	pipe := func(t *testing.T) (client, server transport.Transport) {
+		client, server = mp(t)
+		t.Cleanup(func() {
+			client.Close()
+			server.Close()
+		})
+		if chunked && !(upgrade(client) && upgrade(server)) {
+			t.Skip("transport doesn't support chunked framing")
+		}
+		return client, server
+	}
+
+	t.Run("Messages", func(t *testing.T) {
+		msgs := map[string]string{
+			"empty":    "",
+			"small":    `<rpc message-id="1" xmlns="urn:ietf:params:xml:ns:netconf:base:1.0"><get/></rpc>`,
+			"unicode":  "<data>ünïcødé ✓ 日本語</data>",
+			"newlines": "\n\n<data>\n#1\n#\n##\n</data>\n\n",
+			"partial":  "<data>]]>]]</data>",
+		}
+		if chunked {
+			// only chunked framing can carry the end-of-message marker.
+			msgs["eom-marker"] = "<data>]]>]]></data>"
+		}
+		for name, msg := range msgs {
+			t.Run(name, func(t *testing.T) {
+				client, server := pipe(t)
+				testRoundTrip(t, client, server, []byte(msg), chunked)
+				testRoundTrip(t, server, client, []byte(msg), chunked)
+			})
+		}
+	})
+
+	t.Run("Large", func(t *testing.T) {
+		client, server := pipe(t)
+		for _, size := range []int{64 << 10, 1 << 20, 4 << 20} {
+			msg := largeMsg(size)
+			testRoundTrip(t, client, server, msg, chunked)
+			testRoundTrip(t, server, client, msg, chunked)
+		}
+	})
+
+	t.Run("Sequential", func(t *testing.T) {
+		client, server := pipe(t)
+		const n = 100
+
+		written := make(chan error, 1)
+		go func() {
+			for i := 0; i < n; i++ {
+				if err := writeMsg(client, seqMsg(i)); err != nil {
+					written <- err
+					return
+				}
+			}
+			written <- nil
+		}()
+
+		for i := 0; i < n; i++ {
+			if got := mustRead(t, server); !sameMsg(got, seqMsg(i), chunked) {
+				t.Fatalf("message %d: got %q, want %q", i, truncate(got), seqMsg(i))
+			}
+		}
+		waitErr(t, "writing messages", written)
+	})
+
+	t.Run("Bidirectional", func(t *testing.T) {
+		client, server := pipe(t)
+		const n = 50
+
+		var wg sync.WaitGroup
+		errs := make(chan error, 4)
+		for _, tr := range []transport.Transport{client, server} {
+			tr := tr
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < n; i++ {
+					if err := writeMsg(tr, seqMsg(i)); err != nil {
+						errs <- fmt.Errorf("write %d: %w", i, err)
+						return
+					}
+				}
+			}()
+			go func() {
+				defer wg.Done()
+				for i := 0; i < n; i++ {
+					got, err := readMsg(tr)
+					if err != nil {
+						errs <- fmt.Errorf("read %d: %w", i, err)
+						return
+					}
+					if !sameMsg(got, seqMsg(i), chunked) {
+						errs <- fmt.Errorf("message %d: got %q, want %q", i, truncate(got), seqMsg(i))
+						return
+					}
+				}
+			}()
+		}
+
+		done := make(chan error, 1)
+		go func() {
+			wg.Wait()
+			close(errs)
+			done <- <-errs
+		}()
+		waitErr(t, "exchanging messages", done)
+	})
+
+	t.Run("ChunkedWrites", func(t *testing.T) {
+		// messages written in many small writes arrive as one message.
+		client, server := pipe(t)
+		want := largeMsg(256 << 10)
+
+		written := make(chan error, 1)
+		go func() {
+			w, err := client.MsgWriter()
+			if err != nil {
+				written <- err
+				return
+			}
+			for b := want; len(b) > 0; {
+				n := min(len(b), 1021)
+				if _, err := w.Write(b[:n]); err != nil {
+					written <- err
+					return
+				}
+				b = b[n:]
+			}
+			written <- w.Close()
+		}()
+
+		if got := mustRead(t, server); !sameMsg(got, want, chunked) {
+			t.Fatalf("got %s, want %s", truncate(got), truncate(want))
+		}
+		waitErr(t, "writing message", written)
+	})
+
+	t.Run("PartialRead", func(t *testing.T) {
+		// closing a reader before the end of the message skips the rest of
+		// it.
+		client, server := pipe(t)
+		written := make(chan error, 1)
+		go func() {
+			if err := writeMsg(client, largeMsg(64<<10)); err != nil {
+				written <- err
+				return
+			}
+			written <- writeMsg(client, seqMsg(1))
+		}()
+
+		r, err := server.MsgReader()
+		if err != nil {
+			t.Fatalf("failed to get reader: %v", err)
+		}
+		if _, err := r.Read(make([]byte, 16)); err != nil {
+			t.Fatalf("failed to read: %v", err)
+		}
+		if err := r.Close(); err != nil {
+			t.Fatalf("failed to close reader: %v", err)
+		}
+
+		if got := mustRead(t, server); !sameMsg(got, seqMsg(1), chunked) {
+			t.Fatalf("got %q, want %q", truncate(got), seqMsg(1))
+		}
+		waitErr(t, "writing messages", written)
+	})
+
+	t.Run("ExistingWriter", func(t *testing.T) {
+		client, server := pipe(t)
+		go func() { _, _ = readMsg(server) }()
+
+		w, err := client.MsgWriter()
+		if err != nil {
+			t.Fatalf("failed to get writer: %v", err)
+		}
+		defer w.Close()
+
+		if _, err := client.MsgWriter(); err == nil {
+			t.Fatal("got a second writer while the first is open")
+		}
+	})
+
+	t.Run("Close", func(t *testing.T) {
+		client, server := pipe(t)
+		if err := client.Close(); err != nil {
+			t.Fatalf("failed to close transport: %v", err)
+		}
+		if _, err := readTimeout(t, server); err == nil {
+			t.Fatal("read a message after the other end was closed")
+		}
+	})
+}
+
+// testRoundTrip writes msg to from and checks that it is read from to.
+func testRoundTrip(t *testing.T, from, to transport.Transport, msg []byte, chunked bool) {
+	t.Helper()
+	written := goWrite(from, msg)
+	if got := mustRead(t, to); !sameMsg(got, msg, chunked) {
+		t.Fatalf("got %q, want %q", truncate(got), truncate(msg))
+	}
+	waitErr(t, "writing message", written)
+}
+
+// sameMsg reports whether a message read is the one written.  Writers
+// commonly end messages with a newline before the End-of-Message marker so
+// trailing whitespace is ignored without Chunked framing.
+func sameMsg(got, want []byte, chunked bool) bool {
+	if chunked {
+		return bytes.Equal(got, want)
+	}
+	const space = " \t\r\n"
+	return bytes.Equal(bytes.TrimRight(got, space), bytes.TrimRight(want, space))
+}
+
+func seqMsg(i int) []byte {
+	return []byte(fmt.Sprintf(`<rpc message-id="%d"><get/></rpc>`, i))
+}
+
+// largeMsg returns a valid xml message of about size bytes.
+func largeMsg(size int) []byte {
+	const item = "<item>0123456789abcdef</item>\n"
+	var sb strings.Builder
+	sb.Grow(size + len(item))
+	sb.WriteString("<data>")
+	for sb.Len() < size-len("</data>") {
+		sb.WriteString(item)
+	}
+	sb.WriteString("</data>")
+	return []byte(sb.String())
+}
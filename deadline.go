@@ -0,0 +1,92 @@
+package netconf
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/nemith/netconf/transport"
+)
+
+// aLongTimeAgo is a deadline in the past used to interrupt blocked reads and
+// writes.
+var aLongTimeAgo = time.Unix(1, 0)
+
+// interruptOnDone sets a deadline with set once ctx is done to interrupt a
+// blocked read or write.  The returned function must be called with the error
+// of the read or write once it is complete.  It clears the deadline unless the
+// read or write was interrupted in which case the connection is no longer
+// usable and the deadline is kept so further reads or writes fail instead of
+// blocking.
+func interruptOnDone(ctx context.Context, set func(time.Time) error) (stop func(error)) {
+	var (
+		mu       sync.Mutex
+		finished bool
+		fired    bool
+	)
+	stopAfter := context.AfterFunc(ctx, func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if !finished {
+			fired = true
+			_ = set(aLongTimeAgo)
+		}
+	})
+
+	return func(err error) {
+		stopAfter()
+		mu.Lock()
+		defer mu.Unlock()
+		finished = true
+		if fired && !isDeadlineErr(err) {
+			_ = set(time.Time{})
+		}
+	}
+}
+
+// interruptRead interrupts the message being read if ctx is done before it
+// has been read.  It is a no-op for transports not supporting deadlines.
+func (s *Session) interruptRead(ctx context.Context) func(error) {
+	d, ok := s.tr.(transport.Deadliner)
+	if !ok || ctx == nil {
+		return func(error) {}
+	}
+	return interruptOnDone(ctx, d.SetReadDeadline)
+}
+
+// interruptWrite interrupts the message being written if ctx is done before
+// it has been written.  It is a no-op for transports not supporting
+// deadlines.
+func (s *Session) interruptWrite(ctx context.Context) func(error) {
+	d, ok := s.tr.(transport.Deadliner)
+	if !ok {
+		return func(error) {}
+	}
+	return interruptOnDone(ctx, d.SetWriteDeadline)
+}
+
+// isDeadlineErr reports if err is from a read or write interrupted by a
+// deadline.
+func isDeadlineErr(err error) bool {
+	return errors.Is(err, os.ErrDeadlineExceeded)
+}
+
+// pendingCtx returns the context of the pending rpc a reply with the given
+// attributes is for or nil if there is none.
+func (s *Session) pendingCtx(attrs []xml.Attr) context.Context {
+	for _, attr := range attrs {
+		if attr.Name.Space != "" || attr.Name.Local != "message-id" {
+			continue
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if r, ok := s.reqs[attr.Value]; ok {
+			return r.ctx
+		}
+		return nil
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package netconf
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nemith/netconf/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStalledReplyInterrupted(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+
+	sess := newSession(transport.NewPipeTransport(client))
+	go sess.recv()
+
+	go func() {
+		r := bufio.NewReader(server)
+		var sb strings.Builder
+		for !strings.HasSuffix(sb.String(), "]]>]]>") {
+			b, err := r.ReadByte()
+			if err != nil {
+				return
+			}
+			sb.WriteByte(b)
+		}
+		// send the start of the reply and stall.
+		_, _ = io.WriteString(server, `<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><data><configuration>`)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := sess.GetConfig(ctx, Running)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	select {
+	case <-sess.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("session not closed after the stalled reply was interrupted")
+	}
+}
+
+func TestStalledWriteInterrupted(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+
+	// nothing reads from server so the write blocks.
+	sess := newSession(transport.NewPipeTransport(client))
+	go sess.recv()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := sess.Lock(ctx, Running)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	select {
+	case <-sess.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("session not closed after the stalled write was interrupted")
+	}
+}
+
+func TestInterruptOnDone(t *testing.T) {
+	var (
+		mu        sync.Mutex
+		deadlines []time.Time
+	)
+	set := func(d time.Time) error {
+		mu.Lock()
+		defer mu.Unlock()
+		deadlines = append(deadlines, d)
+		return nil
+	}
+	got := func() []time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]time.Time(nil), deadlines...)
+	}
+
+	stop := interruptOnDone(context.Background(), set)
+	stop(nil)
+	assert.Empty(t, got())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// the deadline is cleared if the read finished anyway.
+	stop = interruptOnDone(ctx, set)
+	require.Eventually(t, func() bool { return len(got()) > 0 }, time.Second, time.Millisecond)
+	stop(nil)
+	assert.Equal(t, []time.Time{aLongTimeAgo, {}}, got())
+
+	// and kept if it was interrupted.
+	mu.Lock()
+	deadlines = nil
+	mu.Unlock()
+	stop = interruptOnDone(ctx, set)
+	require.Eventually(t, func() bool { return len(got()) > 0 }, time.Second, time.Millisecond)
+	stop(os.ErrDeadlineExceeded)
+	assert.Equal(t, []time.Time{aLongTimeAgo}, got())
+}
@@ -0,0 +1,167 @@
+package netconf
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/nemith/netconf/clock"
+	"github.com/nemith/netconf/transport"
+)
+
+type debugCaptureOpt struct{ in, out io.Writer }
+
+func (o debugCaptureOpt) apply(cfg *sessionConfig) {
+	cfg.captureIn = o.in
+	cfg.captureOut = o.out
+}
+
+// WithDebugCapture captures every message received from (in) and sent to
+// (out) the server including the hello messages.  See
+// [Session.DebugCapture].
+func WithDebugCapture(in, out io.Writer) SessionOption { return debugCaptureOpt{in, out} }
+
+// DebugCapture writes a copy of every message received from the server to in
+// and every message sent to the server to out.  Either can be nil to not
+// capture that direction.  Each message is written whole, without the
+// transport framing, after a comment with the time, direction, message type
+// and message-id:
+//
+//	<!-- 2024-05-01T10:00:00.123456Z recv rpc-reply message-id="3" -->
+//	<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="3"><ok/></rpc-reply>
+//
+// This is meant for debugging and works with any transport.  On transports
+// using a [transport.Framer] (i.e the ssh and tls transports, also when
+// wrapped by the ratelimit or capture transports which forward it) the
+// messages are captured by the framer with [transport.Framer.DebugCaptureMsgs],
+// which replaces any other message capture set there, and otherwise by the
+// session.  It can be called at any time to start, change or stop (with nil
+// writers) capturing and applies from the next message.  Use
+// [WithDebugCapture] to capture the hello exchange as well.
+func (s *Session) DebugCapture(in, out io.Writer) {
+	if mc, ok := s.tr.(msgCapturer); ok {
+		mc.DebugCaptureMsgs(captureMsgs(in, out))
+		return
+	}
+
+	s.capMu.Lock()
+	defer s.capMu.Unlock()
+	s.captureIn = in
+	s.captureOut = out
+}
+
+// msgCapturer is implemented by transports using a [transport.Framer].
+type msgCapturer interface {
+	DebugCaptureMsgs(fn func(transport.CapturedMsg))
+}
+
+// captureMsgs returns the func capturing the messages of a framer to in and
+// out or nil if both are nil.
+func captureMsgs(in, out io.Writer) func(transport.CapturedMsg) {
+	if in == nil && out == nil {
+		return nil
+	}
+	return func(m transport.CapturedMsg) {
+		switch {
+		case m.Sent && out != nil:
+			writeCapture(out, "send", m.Time, m.Data)
+		case !m.Sent && in != nil:
+			writeCapture(in, "recv", m.Time, m.Data)
+		}
+	}
+}
+
+func (s *Session) captureWriters() (in, out io.Writer) {
+	s.capMu.Lock()
+	defer s.capMu.Unlock()
+	return s.captureIn, s.captureOut
+}
+
+// writeCapture writes a single annotated message to w.
+func writeCapture(w io.Writer, dir string, t time.Time, msg []byte) {
+	kind, msgID := sniffMessage(msg)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "<!-- %s %s %s", t.UTC().Format(time.RFC3339Nano), dir, kind)
+	if msgID != "" {
+		fmt.Fprintf(&buf, " message-id=%q", msgID)
+	}
+	buf.WriteString(" -->\n")
+	buf.Write(bytes.TrimSpace(msg))
+	buf.WriteByte('\n')
+
+	// errors writing debug output shouldn't affect the session.
+	_, _ = w.Write(buf.Bytes())
+}
+
+// sniffMessage returns the root element name and message-id of a message.
+func sniffMessage(msg []byte) (kind, msgID string) {
+	dec := xml.NewDecoder(bytes.NewReader(msg))
+	for {
+		tok, err := dec.RawToken()
+		if err != nil {
+			return "unknown", ""
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		for _, attr := range start.Attr {
+			if attr.Name.Local == "message-id" {
+				msgID = attr.Value
+			}
+		}
+		return start.Name.Local, msgID
+	}
+}
+
+// captureReader copies a received message to be written to the capture
+// when it is closed.
+type captureReader struct {
+	io.ReadCloser
+	w     io.Writer
+	clock clock.Clock
+	start time.Time
+	buf   bytes.Buffer
+}
+
+func (r *captureReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 && r.start.IsZero() {
+		r.start = r.clock.Now()
+	}
+	r.buf.Write(p[:n])
+	return n, err
+}
+
+func (r *captureReader) Close() error {
+	// read the rest of the message so the whole message is captured.
+	_, _ = io.Copy(io.Discard, r)
+	if r.buf.Len() > 0 {
+		writeCapture(r.w, "recv", r.start, r.buf.Bytes())
+	}
+	return r.ReadCloser.Close()
+}
+
+// captureWriter copies a sent message to be written to the capture when it
+// is closed.
+type captureWriter struct {
+	io.WriteCloser
+	w     io.Writer
+	clock clock.Clock
+	buf   bytes.Buffer
+}
+
+func (w *captureWriter) Write(p []byte) (int, error) {
+	n, err := w.WriteCloser.Write(p)
+	w.buf.Write(p[:n])
+	return n, err
+}
+
+func (w *captureWriter) Close() error {
+	err := w.WriteCloser.Close()
+	writeCapture(w.w, "send", w.clock.Now(), w.buf.Bytes())
+	return err
+}
@@ -0,0 +1,89 @@
+package netconf
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nemith/netconf/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestDebugCapture(t *testing.T) {
+	ts := newTestServer(t)
+	var in, out lockedBuffer
+	sess := newSession(ts.transport(), WithDebugCapture(&in, &out))
+	go sess.recv()
+
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><ok/></rpc-reply>`)
+	require.NoError(t, sess.Lock(context.Background(), Running))
+
+	assert.Regexp(t, regexp.MustCompile(`^<!-- \S+Z send rpc message-id="1" -->\n<rpc xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><lock>.*</lock></rpc>\n$`), out.String())
+	require.Eventually(t, func() bool { return in.String() != "" }, time.Second, time.Millisecond)
+	assert.Regexp(t, regexp.MustCompile(`^<!-- \S+Z recv rpc-reply message-id="1" -->\n<rpc-reply .*<ok/></rpc-reply>\n$`), in.String())
+
+	// stop capturing outgoing messages.
+	sess.DebugCapture(&in, nil)
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="2"><ok/></rpc-reply>`)
+	require.NoError(t, sess.Unlock(context.Background(), Running))
+	assert.NotContains(t, out.String(), "unlock")
+	require.Eventually(t, func() bool { return strings.Count(in.String(), "rpc-reply message-id") == 2 }, time.Second, time.Millisecond)
+}
+
+func TestDebugCaptureFramer(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	device := transport.NewPipeTransport(server)
+	go func() {
+		r, err := device.MsgReader()
+		if err != nil {
+			return
+		}
+		_, _ = io.Copy(io.Discard, r)
+		w, err := device.MsgWriter()
+		if err != nil {
+			return
+		}
+		_, _ = io.WriteString(w, `<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><ok/></rpc-reply>`)
+		_ = w.Close()
+	}()
+
+	tr := transport.NewPipeTransport(client)
+	var in, out lockedBuffer
+	sess := newSession(tr, WithDebugCapture(&in, &out))
+	go sess.recv()
+
+	// the framer captures the messages instead of the session.
+	capIn, capOut := sess.captureWriters()
+	assert.Nil(t, capIn)
+	assert.Nil(t, capOut)
+
+	require.NoError(t, sess.Lock(context.Background(), Running))
+	assert.Regexp(t, regexp.MustCompile(`^<!-- \S+Z send rpc message-id="1" -->\n<rpc .*<lock>.*</lock></rpc>\n$`), out.String())
+	require.Eventually(t, func() bool { return in.String() != "" }, time.Second, time.Millisecond)
+	assert.Regexp(t, regexp.MustCompile(`^<!-- \S+Z recv rpc-reply message-id="1" -->\n<rpc-reply .*<ok/></rpc-reply>\n$`), in.String())
+}
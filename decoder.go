@@ -0,0 +1,126 @@
+package netconf
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+type decoderConfigOpt func(*xml.Decoder)
+
+func (o decoderConfigOpt) apply(cfg *sessionConfig) {
+	cfg.decoderConfigs = append(cfg.decoderConfigs, o)
+}
+
+// WithDecoderConfig sets up the xml.Decoder used to read messages from the
+// server.  fn is called for every new decoder and can change its settings
+// (i.e CharsetReader, Strict, AutoClose and Entity) to cope with devices that
+// send xml the standard decoder rejects.  For example to accept HTML entities:
+//
+//	netconf.WithDecoderConfig(func(d *xml.Decoder) {
+//		d.Strict = false
+//		d.Entity = xml.HTMLEntity
+//	})
+//
+// The settings are also used when decoding the contents of replies and
+// notifications with [Reply.Decode] and [Notification.Decode].
+func WithDecoderConfig(fn func(d *xml.Decoder)) SessionOption { return decoderConfigOpt(fn) }
+
+// Latin1CharsetReader is a xml.Decoder CharsetReader that converts messages
+// declared with the ISO-8859-1 (latin1) or US-ASCII encoding to UTF-8.  Use it
+// with [WithDecoderConfig] for devices that send config in these encodings.
+func Latin1CharsetReader(charset string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(charset) {
+	case "iso-8859-1", "iso8859-1", "latin1", "us-ascii", "ascii":
+		return &latin1Reader{r: bufio.NewReader(input)}, nil
+	}
+	return nil, fmt.Errorf("unsupported charset %q", charset)
+}
+
+// latin1Reader converts latin1 bytes (which map directly to unicode code
+// points) to UTF-8.
+type latin1Reader struct {
+	r   io.ByteReader
+	buf []byte
+}
+
+func (r *latin1Reader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if len(r.buf) > 0 {
+			c := copy(p[n:], r.buf)
+			r.buf = r.buf[c:]
+			n += c
+			continue
+		}
+
+		b, err := r.r.ReadByte()
+		if err != nil {
+			if n > 0 {
+				return n, nil
+			}
+			return 0, err
+		}
+		if b < utf8.RuneSelf {
+			p[n] = b
+			n++
+			continue
+		}
+		r.buf = utf8.AppendRune(r.buf[:0], rune(b))
+	}
+	return n, nil
+}
+
+// newDecoder returns a decoder for a message read from r after skipping its
+// prolog (see skipProlog) set up with [WithDecoderConfig].
+func (s *Session) newDecoder(r *bufio.Reader) *xml.Decoder {
+	d := xml.NewDecoder(r)
+	for _, fn := range s.decoderConfigs {
+		fn(d)
+	}
+	return d
+}
+
+// decoderSettings are the parsing settings of a xml.Decoder.  They are kept
+// with replies and notifications so that their contents are decoded the same
+// way the message was.
+type decoderSettings struct {
+	strict        bool
+	autoClose     []string
+	entity        map[string]string
+	charsetReader func(charset string, input io.Reader) (io.Reader, error)
+}
+
+// settingsOf returns the settings of d or nil if they are the defaults.
+func settingsOf(d *xml.Decoder) *decoderSettings {
+	if d.Strict && d.AutoClose == nil && d.Entity == nil && d.CharsetReader == nil {
+		return nil
+	}
+	return &decoderSettings{
+		strict:        d.Strict,
+		autoClose:     d.AutoClose,
+		entity:        d.Entity,
+		charsetReader: d.CharsetReader,
+	}
+}
+
+// newDecoder returns a decoder for r using the settings.  ds may be nil.
+func (ds *decoderSettings) newDecoder(r io.Reader) *xml.Decoder {
+	d := xml.NewDecoder(r)
+	if ds != nil {
+		d.Strict = ds.strict
+		d.AutoClose = ds.autoClose
+		d.Entity = ds.entity
+		d.CharsetReader = ds.charsetReader
+	}
+	return d
+}
+
+// unmarshal is xml.Unmarshal using the settings.  ds may be nil.
+func (ds *decoderSettings) unmarshal(data []byte, v any) error {
+	return ds.newDecoder(bytes.NewReader(data)).Decode(v)
+}
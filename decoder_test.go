@@ -0,0 +1,50 @@
+package netconf
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLatin1CharsetReader(t *testing.T) {
+	r, err := Latin1CharsetReader("ISO-8859-1", strings.NewReader("caf\xe9 \xbd"))
+	require.NoError(t, err)
+	out, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "café ½", string(out))
+
+	_, err = Latin1CharsetReader("ebcdic", strings.NewReader(""))
+	assert.ErrorContains(t, err, `unsupported charset "ebcdic"`)
+}
+
+func TestDecoderConfig(t *testing.T) {
+	ts := newTestServer(t)
+	sess := newSession(ts.transport(), WithDecoderConfig(func(d *xml.Decoder) {
+		d.CharsetReader = Latin1CharsetReader
+		d.Strict = false
+		d.Entity = xml.HTMLEntity
+	}))
+	go sess.recv()
+
+	ts.queueRespString("<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?>" +
+		`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1">` +
+		"<data><description>caf\xe9&nbsp;bar</description></data></rpc-reply>")
+
+	reply, err := sess.Do(context.Background(), &GetConfigReq{Source: Running})
+	require.NoError(t, err)
+
+	var data struct {
+		Description string `xml:"description"`
+	}
+	require.NoError(t, reply.Decode(&data))
+	assert.Equal(t, "café bar", data.Description)
+
+	// without the settings the entity is rejected.
+	plain := Reply{Body: reply.Body}
+	assert.Error(t, plain.Decode(&data))
+}
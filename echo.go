@@ -0,0 +1,16 @@
+package netconf
+
+type requestEchoOpt struct{}
+
+func (requestEchoOpt) apply(cfg *sessionConfig) { cfg.requestEcho = true }
+
+// WithRequestEcho keeps the exact bytes of every `<rpc>` sent on the session
+// so they can be retrieved with [Reply.RequestRaw].  This is useful for
+// debugging a failing rpc with a vendor as it shows exactly what the client
+// sent.  It costs a copy of every request for the lifetime of the reply.
+func WithRequestEcho() SessionOption { return requestEchoOpt{} }
+
+// RequestRaw returns the exact bytes of the `<rpc>` message the reply is for,
+// not including the transport framing.  It is nil unless the session was
+// opened with [WithRequestEcho].
+func (r Reply) RequestRaw() []byte { return r.requestRaw }
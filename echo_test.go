@@ -0,0 +1,33 @@
+package netconf
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestEcho(t *testing.T) {
+	ts := newTestServer(t)
+	sess := newSession(ts.transport(), WithRequestEcho())
+	go sess.recv()
+
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><ok/></rpc-reply>`)
+	reply, err := sess.Do(context.Background(), &CommitReq{})
+	require.NoError(t, err)
+
+	sent, err := ts.popReq()
+	require.NoError(t, err)
+	assert.Equal(t, sent, reply.RequestRaw())
+
+	// disabled by default
+	ts = newTestServer(t)
+	sess = newSession(ts.transport())
+	go sess.recv()
+
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><ok/></rpc-reply>`)
+	reply, err = sess.Do(context.Background(), &CommitReq{})
+	require.NoError(t, err)
+	assert.Nil(t, reply.RequestRaw())
+}
@@ -0,0 +1,228 @@
+package netconf
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// EventType is the kind of lifecycle [Event] published to an [EventBus].
+type EventType int
+
+const (
+	// EventConnected is published after a session completes the hello
+	// exchange.
+	EventConnected EventType = iota + 1
+
+	// EventDisconnected is published when the session's connection is
+	// closed.  Event.Err is set if the connection was closed unexpectedly.
+	EventDisconnected
+
+	// EventCapabilityChange is published when the server sends a RFC6470
+	// `<netconf-capability-change>` notification.
+	EventCapabilityChange
+
+	// EventDrift is published when the configuration of a device is found to
+	// differ from what is expected.  The library doesn't detect drift itself;
+	// this is for applications and integrations that do.
+	EventDrift
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventConnected:
+		return "connected"
+	case EventDisconnected:
+		return "disconnected"
+	case EventCapabilityChange:
+		return "capability-change"
+	case EventDrift:
+		return "drift"
+	default:
+		return "unknown"
+	}
+}
+
+// Event is a device lifecycle event.
+type Event struct {
+	Type EventType
+	Time time.Time
+
+	// Session is the session the event is for.  It may be nil for events
+	// published by applications.
+	Session    *Session
+	SessionID  uint64
+	RemoteAddr net.Addr
+
+	// Capabilities are the server capabilities for EventConnected and the
+	// added (or modified) capabilities for EventCapabilityChange.
+	Capabilities []string
+
+	// DeletedCapabilities are the capabilities removed by the server for
+	// EventCapabilityChange.
+	DeletedCapabilities []string
+
+	// Err is the reason for an unexpected EventDisconnected.
+	Err error
+
+	// Detail holds any extra information about the event (i.e the
+	// Notification for EventCapabilityChange or an application defined
+	// value for EventDrift).
+	Detail any
+}
+
+// EventBus delivers lifecycle events from many sessions to subscribers so that
+// applications can watch all devices in one place instead of wiring callbacks
+// per session.  Sessions publish to a bus when opened with [WithEventBus] and
+// pools and call home servers publish the sessions they open with
+// [WithPoolEventBus] and [WithCallHomeEventBus].
+//
+// Subscribers are called synchronously in the order they were added from the
+// goroutine publishing the event so they must not block.  The zero value is
+// ready to use.
+type EventBus struct {
+	mu   sync.RWMutex
+	subs []*eventSub
+}
+
+type eventSub struct {
+	fn func(Event)
+}
+
+// DefaultEventBus is a process-wide EventBus for applications that don't need
+// more than one.
+var DefaultEventBus = &EventBus{}
+
+// Subscribe registers fn to be called for every event published on the bus.
+// The returned function removes the subscription.
+func (b *EventBus) Subscribe(fn func(Event)) (unsubscribe func()) {
+	sub := &eventSub{fn: fn}
+
+	b.mu.Lock()
+	b.subs = append(b.subs, sub)
+	b.mu.Unlock()
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		for i, s := range b.subs {
+			if s == sub {
+				b.subs = append(b.subs[:i:i], b.subs[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Publish sends an event to all subscribers.  If the time of the event is zero
+// it is set to the current time.  A panicking subscriber is logged and doesn't
+// prevent delivery to other subscribers.
+func (b *EventBus) Publish(ev Event) {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+
+	b.mu.RLock()
+	subs := b.subs
+	b.mu.RUnlock()
+
+	for _, sub := range subs {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					defaultErrorHandler(&PanicError{Callback: "event subscriber", Value: r, Stack: debug.Stack()})
+				}
+			}()
+			sub.fn(ev)
+		}()
+	}
+}
+
+type eventBusOpt struct{ b *EventBus }
+
+func (o eventBusOpt) apply(cfg *sessionConfig) { cfg.eventBus = o.b }
+
+// WithEventBus publishes the lifecycle events of the session to the given
+// bus.
+func WithEventBus(b *EventBus) SessionOption { return eventBusOpt{b} }
+
+// publish sends an event for this session to the session's event bus if it
+// has one.
+func (s *Session) publish(ev Event) {
+	if s.eventBus == nil {
+		return
+	}
+	ev.Session = s
+	ev.SessionID = s.sessionID
+	ev.RemoteAddr = s.RemoteAddr()
+	s.eventBus.Publish(ev)
+}
+
+// publishLifecycle publishes EventConnected for sess to b and
+// EventDisconnected once it is closed for sessions opened by a [Pool] or
+// [CallHomeServer].  Sessions opened with b as their own bus are skipped as
+// they already publish these events.
+func publishLifecycle(b *EventBus, sess *Session, detail any) {
+	if b == nil || sess.eventBus == b {
+		return
+	}
+	ev := Event{
+		Session:    sess,
+		SessionID:  sess.sessionID,
+		RemoteAddr: sess.RemoteAddr(),
+		Detail:     detail,
+	}
+
+	ev.Type = EventConnected
+	ev.Capabilities = sess.ServerCapabilities()
+	b.Publish(ev)
+
+	go func() {
+		<-sess.Done()
+		ev.Type, ev.Capabilities = EventDisconnected, nil
+		sess.mu.Lock()
+		if !sess.closing {
+			ev.Err = fmt.Errorf("connection closed unexpectedly: %w", ErrClosed)
+		}
+		sess.mu.Unlock()
+		b.Publish(ev)
+	}()
+}
+
+// capabilityChange is the event of the `netconf-capability-change`
+// notification defined in RFC6470.
+type capabilityChange struct {
+	Added    []string `xml:"added-capability"`
+	Deleted  []string `xml:"deleted-capability"`
+	Modified []string `xml:"modified-capability"`
+}
+
+var capabilityChangeName = xml.Name{
+	Space: "urn:ietf:params:xml:ns:yang:ietf-netconf-notifications",
+	Local: "netconf-capability-change",
+}
+
+// publishCapabilityChange publishes an EventCapabilityChange if the
+// notification is a capability change.
+func (s *Session) publishCapabilityChange(notif Notification) {
+	if s.eventBus == nil || notif.EventName() != capabilityChangeName {
+		return
+	}
+
+	var change capabilityChange
+	if err := notif.Decode(&change); err != nil {
+		s.handleError(err)
+		return
+	}
+
+	s.publish(Event{
+		Type:                EventCapabilityChange,
+		Time:                notif.EventTime,
+		Capabilities:        append(change.Added, change.Modified...),
+		DeletedCapabilities: change.Deleted,
+		Detail:              notif,
+	})
+}
@@ -0,0 +1,72 @@
+package netconf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventBus(t *testing.T) {
+	var bus EventBus
+
+	var first, second []EventType
+	unsub := bus.Subscribe(func(ev Event) {
+		first = append(first, ev.Type)
+		panic("bad subscriber")
+	})
+	bus.Subscribe(func(ev Event) {
+		assert.False(t, ev.Time.IsZero())
+		second = append(second, ev.Type)
+	})
+
+	bus.Publish(Event{Type: EventDrift})
+	unsub()
+	bus.Publish(Event{Type: EventConnected})
+
+	assert.Equal(t, []EventType{EventDrift}, first)
+	assert.Equal(t, []EventType{EventDrift, EventConnected}, second)
+}
+
+func TestSessionEvents(t *testing.T) {
+	const capChange = `<notification xmlns="urn:ietf:params:xml:ns:netconf:notification:1.0">
+  <eventTime>2023-06-07T18:31:48Z</eventTime>
+  <netconf-capability-change xmlns="urn:ietf:params:xml:ns:yang:ietf-netconf-notifications">
+    <changed-by><server/></changed-by>
+    <added-capability>urn:ietf:params:netconf:capability:candidate:1.0</added-capability>
+    <deleted-capability>urn:ietf:params:netconf:capability:startup:1.0</deleted-capability>
+  </netconf-capability-change>
+</notification>`
+
+	var bus EventBus
+	events := make(chan Event, 10)
+	bus.Subscribe(func(ev Event) { events <- ev })
+
+	tr := newTestServer(t).transport()
+	sess := newSession(tr, WithEventBus(&bus), WithErrorHandler(func(error) {}))
+	sess.sessionID = 42
+
+	// other notifications don't publish events
+	tr.pushMsg(testNotification)
+	require.NoError(t, sess.recvMsg())
+
+	tr.pushMsg(capChange)
+	require.NoError(t, sess.recvMsg())
+
+	ev := <-events
+	assert.Equal(t, EventCapabilityChange, ev.Type)
+	assert.Same(t, sess, ev.Session)
+	assert.Equal(t, uint64(42), ev.SessionID)
+	assert.Equal(t, []string{"urn:ietf:params:netconf:capability:candidate:1.0"}, ev.Capabilities)
+	assert.Equal(t, []string{"urn:ietf:params:netconf:capability:startup:1.0"}, ev.DeletedCapabilities)
+	assert.Equal(t, 2023, ev.Time.Year())
+
+	// an empty message is read as the connection closing
+	tr.pushMsg("")
+	sess.recv()
+
+	ev = <-events
+	assert.Equal(t, EventDisconnected, ev.Type)
+	assert.ErrorIs(t, ev.Err, ErrClosed)
+	assert.Empty(t, events)
+}
@@ -7,17 +7,16 @@ import (
 
 	"github.com/nemith/netconf"
 	ncssh "github.com/nemith/netconf/transport/ssh"
-	"golang.org/x/crypto/ssh"
 )
 
 const sshAddr = "myrouter.example.com:830"
 
 func Example_ssh() {
-	config := &ssh.ClientConfig{
-		User: "admin",
-		Auth: []ssh.AuthMethod{
-			ssh.Password("secret"),
-		},
+	// use the ssh-agent and the keys in ~/.ssh and verify the host key
+	// against ~/.ssh/known_hosts.
+	config, err := ncssh.NewClientConfig("admin", nil)
+	if err != nil {
+		panic(err)
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
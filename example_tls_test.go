@@ -5,6 +5,7 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	_ "embed"
+	"encoding/pem"
 	"fmt"
 	"log"
 	"os"
@@ -78,3 +79,55 @@ func Example_tls() {
 		log.Print(err)
 	}
 }
+
+func Example_callHomeTLS() {
+	caCert, err := os.ReadFile("ca.crt")
+	if err != nil {
+		log.Fatalf("failed to load ca cert: %v", err)
+	}
+
+	caCertPool := x509.NewCertPool()
+	caCertPool.AppendCertsFromPEM(caCert)
+
+	// Devices calling home are identified by the name in their certificate
+	// (issued by the CA) rather than the address they connect from.
+	ca, _ := pem.Decode(caCert)
+	entries := []nctls.CertToName{
+		{ID: 1, Fingerprint: nctls.Fingerprint(mustParseCert(ca.Bytes)), MapType: nctls.MapSANDNSName},
+	}
+	devices := map[string]*netconf.CallHomeClientConfig{
+		"router1.example.com": {},
+	}
+
+	srv := netconf.NewCallHomeServer(
+		netconf.WithCallHomeTLS(&netconf.TLSCallHomeTransport{
+			Config: &tls.Config{RootCAs: caCertPool},
+			// the certificate is verified against the CA but not the address
+			// of the device.
+			VerifyPeer: func(chain []*x509.Certificate) error { return nil },
+		}),
+		netconf.WithCallHomeCertToName(entries, devices),
+	)
+	go func() {
+		if err := srv.Listen(context.Background()); err != nil {
+			log.Print(err)
+		}
+	}()
+	go func() {
+		for err := range srv.ErrorChannel() {
+			log.Print(err)
+		}
+	}()
+
+	for client := range srv.ClientChannel() {
+		fmt.Printf("%s called home from %s\n", client.Identity().Name, client.RemoteAddr())
+	}
+}
+
+func mustParseCert(der []byte) *x509.Certificate {
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		panic(err)
+	}
+	return cert
+}
@@ -0,0 +1,75 @@
+package netconf
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// XMLFragment is a xml fragment that is encoded in place without a wrapping
+// element unlike [RawXML].  It is meant for the Extensions of an operation
+// (i.e `netconf.XMLFragment("<synchronize/>")` for a Junos `<commit>`).
+// Namespace prefixes are resolved and re-declared by the encoder.
+type XMLFragment string
+
+// MarshalXML implements xml.Marshaler.  The start element is ignored.
+func (x XMLFragment) MarshalXML(e *xml.Encoder, _ xml.StartElement) error {
+	d := xml.NewDecoder(strings.NewReader(string(x)))
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid xml fragment: %w", err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			// namespaces are already resolved into the names
+			attrs := t.Attr[:0]
+			for _, attr := range t.Attr {
+				if attr.Name.Space == "xmlns" || (attr.Name.Space == "" && attr.Name.Local == "xmlns") {
+					continue
+				}
+				attrs = append(attrs, attr)
+			}
+			t.Attr = attrs
+			tok = t
+		case xml.ProcInst, xml.Directive:
+			continue
+		}
+
+		if err := e.EncodeToken(tok); err != nil {
+			return err
+		}
+	}
+}
+
+type editConfigExtensions []any
+
+func (o editConfigExtensions) apply(req *EditConfigReq) {
+	req.Extensions = append(req.Extensions, o...)
+}
+
+// WithEditConfigExtensions adds vendor specific elements to the
+// `<edit-config>` operation.  Elements can be any value that can be marshaled
+// to xml including [XMLFragment].
+func WithEditConfigExtensions(elems ...any) EditConfigOption { return editConfigExtensions(elems) }
+
+type commitExtensions []any
+
+func (o commitExtensions) apply(req *CommitReq) {
+	req.Extensions = append(req.Extensions, o...)
+}
+
+func (o commitExtensions) applyCancelCommit(req *CancelCommitReq) {
+	req.Extensions = append(req.Extensions, o...)
+}
+
+// WithCommitExtensions adds vendor specific elements to the `<commit>` or
+// `<cancel-commit>` operation (i.e `netconf.XMLFragment("<synchronize/>")`
+// on Junos).  Elements can be any value that can be marshaled to xml including
+// [XMLFragment].
+func WithCommitExtensions(elems ...any) CommitCancelOption { return commitExtensions(elems) }
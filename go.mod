@@ -3,6 +3,7 @@ module github.com/nemith/netconf
 go 1.21
 
 require (
+	github.com/klauspost/compress v1.17.11
 	github.com/stretchr/testify v1.10.0
 	golang.org/x/crypto v0.30.0
 )
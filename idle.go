@@ -0,0 +1,81 @@
+package netconf
+
+import (
+	"context"
+	"time"
+
+	"github.com/nemith/netconf/clock"
+)
+
+type idleTimeoutOpt time.Duration
+
+func (o idleTimeoutOpt) apply(cfg *sessionConfig) { cfg.idleTimeout = time.Duration(o) }
+
+// WithIdleTimeout closes the session once nothing has been sent or received
+// for d.  Many devices limit the number of concurrent sessions so reaping idle
+// ones frees them up for other clients.  Sessions with rpcs waiting for a
+// reply or with a notification subscription are never considered idle.
+func WithIdleTimeout(d time.Duration) SessionOption { return idleTimeoutOpt(d) }
+
+func unixNanoTime(n int64) time.Time {
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n)
+}
+
+// LastSent returns the time the last message was sent on the session.
+func (s *Session) LastSent() time.Time { return unixNanoTime(s.lastSent.Load()) }
+
+// LastReceived returns the time the last message was received on the session.
+func (s *Session) LastReceived() time.Time { return unixNanoTime(s.lastRecv.Load()) }
+
+// LastActivity returns the time a message was last sent or received on the
+// session.
+func (s *Session) LastActivity() time.Time {
+	sent, recv := s.LastSent(), s.LastReceived()
+	if sent.After(recv) {
+		return sent
+	}
+	return recv
+}
+
+// busy reports if the session has outstanding rpcs or a notification
+// subscription.
+func (s *Session) busy() bool {
+	if s.subscribed.Load() {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.reqs) > 0
+}
+
+// watchIdle closes the session once it has been idle for the idle timeout.
+func (s *Session) watchIdle() {
+	timer := s.clock.NewTimer(s.idleTimeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-timer.C():
+		}
+
+		idle := clock.Since(s.clock, s.LastActivity())
+		if s.busy() {
+			timer.Reset(s.idleTimeout)
+			continue
+		}
+		if idle < s.idleTimeout {
+			timer.Reset(s.idleTimeout - idle)
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		_ = s.Close(ctx)
+		cancel()
+		return
+	}
+}
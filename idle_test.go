@@ -0,0 +1,74 @@
+package netconf
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nemith/netconf/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLastActivity(t *testing.T) {
+	ts := newTestServer(t)
+	sess := newSession(ts.transport())
+	go sess.recv()
+
+	assert.True(t, sess.LastActivity().IsZero())
+
+	start := time.Now()
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><ok/></rpc-reply>`)
+	require.NoError(t, sess.Lock(context.Background(), Running))
+
+	assert.False(t, sess.LastSent().Before(start))
+	assert.False(t, sess.LastReceived().Before(sess.LastSent()))
+	assert.Equal(t, sess.LastReceived(), sess.LastActivity())
+}
+
+func TestIdleTimeout(t *testing.T) {
+	ts := newTestServer(t)
+	sess := newSession(ts.transport(), WithIdleTimeout(50*time.Millisecond))
+	go sess.recv()
+
+	start := time.Now()
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><ok/></rpc-reply>`)
+	require.NoError(t, sess.Lock(context.Background(), Running))
+	_, err := ts.popReq()
+	require.NoError(t, err)
+
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="2"><ok/></rpc-reply>`)
+	req, err := ts.popReqString()
+	require.NoError(t, err)
+	assert.Contains(t, req, "<close-session>")
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+}
+
+func TestIdleTimeoutFakeClock(t *testing.T) {
+	clk := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	ts := newTestServer(t)
+	sess := newSession(ts.transport(), WithIdleTimeout(time.Minute), WithClock(clk))
+	go sess.recv()
+
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><ok/></rpc-reply>`)
+	require.NoError(t, sess.Lock(context.Background(), Running))
+	_, err := ts.popReq()
+	require.NoError(t, err)
+	assert.True(t, clk.Now().Equal(sess.LastActivity()))
+
+	clk.BlockUntil(1)
+	clk.Advance(30 * time.Second)
+	// the timer is rearmed for the remaining 30 seconds.
+	clk.BlockUntil(1)
+	select {
+	case <-sess.Done():
+		t.Fatal("session closed before the idle timeout")
+	default:
+	}
+
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="2"><ok/></rpc-reply>`)
+	clk.Advance(30 * time.Second)
+	req, err := ts.popReqString()
+	require.NoError(t, err)
+	assert.Contains(t, req, "<close-session>")
+}
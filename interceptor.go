@@ -0,0 +1,101 @@
+package netconf
+
+import (
+	"context"
+	"runtime/debug"
+)
+
+// RPCInfo contains information about a rpc passed to an [Interceptor].
+type RPCInfo struct {
+	// Session the rpc is issued on.
+	Session *Session
+
+	// Operation is the element name of the operation (i.e `get-config`).
+	Operation string
+
+	// MessageID is the `message-id` attribute of the `<rpc>`.  It is assigned
+	// when the request is sent, and is therefore only set after calling the
+	// Invoker.
+	MessageID string
+}
+
+// Invoker sends a rpc and waits for the reply.  It is the `next` function
+// passed to an [Interceptor].
+type Invoker func(ctx context.Context, req any) (*Reply, error)
+
+// Interceptor is a hook that wraps every rpc issued by [Session.Do] (and
+// therefore [Session.Call] and all operation methods).  An interceptor must
+// call `next` to issue the rpc and can inspect or modify the context, request,
+// reply and error.  This can be used to implement things like tracing,
+// logging or retries without wrapping every call site.
+//
+// A tracing interceptor would look something like:
+//
+//	func tracing(ctx context.Context, info *netconf.RPCInfo, req any, next netconf.Invoker) (*netconf.Reply, error) {
+//		ctx, span := tracer.Start(ctx, "netconf "+info.Operation)
+//		defer span.End()
+//
+//		reply, err := next(ctx, req)
+//		span.SetAttributes(
+//			attribute.String("netconf.operation", info.Operation),
+//			attribute.String("netconf.message_id", info.MessageID),
+//			attribute.Int64("netconf.session_id", int64(info.Session.SessionID())),
+//		)
+//		// not all transports have an address.
+//		if addr := info.Session.RemoteAddr(); addr != nil {
+//			span.SetAttributes(attribute.String("server.address", addr.String()))
+//		}
+//		if reply != nil {
+//			for _, rpcErr := range reply.Errors {
+//				span.AddEvent("rpc-error", trace.WithAttributes(attribute.String("netconf.error_tag", string(rpcErr.Tag))))
+//			}
+//		}
+//		return reply, err
+//	}
+type Interceptor func(ctx context.Context, info *RPCInfo, req any, next Invoker) (*Reply, error)
+
+type interceptorOpt []Interceptor
+
+func (o interceptorOpt) apply(cfg *sessionConfig) {
+	cfg.interceptors = append(cfg.interceptors, o...)
+}
+
+// WithInterceptor adds one or more interceptors to the session.  Interceptors
+// are called in the order they are given with the first being the outermost.
+func WithInterceptor(interceptors ...Interceptor) SessionOption {
+	return interceptorOpt(interceptors)
+}
+
+// chainInterceptors returns an Invoker that calls all of the session's
+// interceptors in order ending with the final invoker.  A panic in an
+// interceptor is returned as a PanicError while a panic in the invoker it
+// calls is passed through as is.
+func (s *Session) chainInterceptors(info *RPCInfo, final Invoker) Invoker {
+	next := final
+	for i := len(s.interceptors) - 1; i >= 0; i-- {
+		interceptor, inner := s.interceptors[i], next
+		next = func(ctx context.Context, req any) (reply *Reply, err error) {
+			// invoking is left set if inner panics so the panic isn't
+			// blamed on the interceptor.
+			var invoking bool
+			invoke := func(ctx context.Context, req any) (*Reply, error) {
+				invoking = true
+				reply, err := inner(ctx, req)
+				invoking = false
+				return reply, err
+			}
+
+			defer func() {
+				if r := recover(); r != nil {
+					if invoking {
+						panic(r)
+					}
+					reply, err = nil, &PanicError{Callback: "interceptor", Value: r, Stack: debug.Stack()}
+					s.handleError(err)
+				}
+			}()
+			return interceptor(ctx, info, req, invoke)
+		}
+	}
+	return next
+}
@@ -0,0 +1,52 @@
+package netconf
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterceptor(t *testing.T) {
+	var calls []string
+	record := func(name string) Interceptor {
+		return func(ctx context.Context, info *RPCInfo, req any, next Invoker) (*Reply, error) {
+			calls = append(calls, name+" before "+info.Operation)
+			reply, err := next(ctx, req)
+			calls = append(calls, name+" after "+info.Operation)
+			assert.Equal(t, "1", info.MessageID)
+			return reply, err
+		}
+	}
+
+	ts := newTestServer(t)
+	sess := newSession(ts.transport(), WithInterceptor(record("first"), record("second")))
+	go sess.recv()
+
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><ok/></rpc-reply>`)
+	err := sess.Lock(context.Background(), Running)
+	require.NoError(t, err)
+
+	_, err = ts.popReq()
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"first before lock",
+		"second before lock",
+		"second after lock",
+		"first after lock",
+	}, calls)
+}
+
+func TestInterceptorShortCircuit(t *testing.T) {
+	errDenied := errors.New("denied")
+	deny := func(ctx context.Context, info *RPCInfo, req any, next Invoker) (*Reply, error) {
+		return nil, errDenied
+	}
+
+	sess := newSession(newTestServer(t).transport(), WithInterceptor(deny))
+	err := sess.Lock(context.Background(), Running)
+	assert.ErrorIs(t, err, errDenied)
+}
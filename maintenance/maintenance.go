@@ -0,0 +1,271 @@
+// Package maintenance implements helpers for device maintenance operations
+// (rebooting and installing software) over NETCONF.
+//
+// There is no standard NETCONF operation for these so the rpc to use is
+// detected from the capabilities advertised by the device.  The helpers take
+// care of the parts of these flows that are the same for every device: pre and
+// post checks, tolerating the session being dropped by the device and waiting
+// for the device to come back.
+package maintenance
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nemith/netconf"
+	"github.com/nemith/netconf/clock"
+)
+
+// ErrUnsupported is returned when the operation can't be detected from the
+// capabilities of the device.  Use [WithRequest] to provide the rpc.
+var ErrUnsupported = errors.New("maintenance: operation not supported by device")
+
+const (
+	// IETFSystemNamespace is the namespace of the ietf-system YANG module
+	// (RFC7317) which defines the `<system-restart>` rpc.
+	IETFSystemNamespace = "urn:ietf:params:xml:ns:yang:ietf-system"
+
+	// JunosCapability is advertised by Juniper devices.
+	JunosCapability = "http://xml.juniper.net/netconf/junos/1.0"
+)
+
+// SystemRestartReq is the `<system-restart>` rpc defined by RFC7317.
+type SystemRestartReq struct {
+	XMLName xml.Name `xml:"urn:ietf:params:xml:ns:yang:ietf-system system-restart"`
+}
+
+// JunosRebootReq is the Junos `<request-reboot>` rpc.
+type JunosRebootReq struct {
+	XMLName xml.Name `xml:"request-reboot"`
+}
+
+// JunosPackageAddReq is the Junos `<request-package-add>` rpc used to install
+// a software image.
+type JunosPackageAddReq struct {
+	XMLName     xml.Name           `xml:"request-package-add"`
+	PackageName string             `xml:"package-name"`
+	NoValidate  netconf.ExtantBool `xml:"no-validate,omitempty"`
+	Reboot      netconf.ExtantBool `xml:"reboot,omitempty"`
+}
+
+// supportsModule reports if the device advertises a YANG module with the
+// given namespace.
+func supportsModule(sess *netconf.Session, ns string) bool {
+	c, ok := sess.ServerCapabilitySet().Capability(ns)
+	return ok && c.Module() != ""
+}
+
+// RebootRequest returns the rpc used to reboot the device the session is
+// connected to.
+func RebootRequest(sess *netconf.Session) (any, error) {
+	switch {
+	case supportsModule(sess, IETFSystemNamespace):
+		return &SystemRestartReq{}, nil
+	case sess.Supports(JunosCapability):
+		return &JunosRebootReq{}, nil
+	}
+	return nil, ErrUnsupported
+}
+
+// InstallRequest returns the rpc used to install the software image at path
+// (a path or url on the device) on the device the session is connected to.
+// The image is installed but not activated; devices usually activate it on
+// the next reboot.
+func InstallRequest(sess *netconf.Session, path string) (any, error) {
+	if sess.Supports(JunosCapability) {
+		return &JunosPackageAddReq{PackageName: path}, nil
+	}
+	return nil, ErrUnsupported
+}
+
+// Check is a pre or post check run around a maintenance operation.
+type Check func(ctx context.Context, sess *netconf.Session) error
+
+// Dialer opens a new session to the device.  It is used to wait for the device
+// to come back after a reboot.
+type Dialer func(ctx context.Context) (*netconf.Session, error)
+
+// Option is an optional argument to the maintenance helpers.
+type Option interface {
+	apply(*config)
+}
+
+type config struct {
+	request      any
+	preChecks    []Check
+	postChecks   []Check
+	downDelay    time.Duration
+	pollInterval time.Duration
+	clock        clock.Clock
+}
+
+type requestOpt struct{ req any }
+
+func (o requestOpt) apply(cfg *config) { cfg.request = o.req }
+
+// WithRequest uses the given rpc instead of detecting it from the device's
+// capabilities.
+func WithRequest(req any) Option { return requestOpt{req} }
+
+type preCheckOpt Check
+
+func (o preCheckOpt) apply(cfg *config) { cfg.preChecks = append(cfg.preChecks, Check(o)) }
+
+// WithPreCheck adds a check that is run on the existing session before the
+// operation is issued.  The operation is aborted if a check fails.
+func WithPreCheck(c Check) Option { return preCheckOpt(c) }
+
+type postCheckOpt Check
+
+func (o postCheckOpt) apply(cfg *config) { cfg.postChecks = append(cfg.postChecks, Check(o)) }
+
+// WithPostCheck adds a check that is run once the operation is complete (on
+// the new session after a reboot).
+func WithPostCheck(c Check) Option { return postCheckOpt(c) }
+
+type downDelayOpt time.Duration
+
+func (o downDelayOpt) apply(cfg *config) { cfg.downDelay = time.Duration(o) }
+
+// WithDownDelay sets how long to wait after issuing a reboot before trying to
+// reconnect.  Devices often keep accepting connections for a while before
+// going down.  The default is 30 seconds.
+func WithDownDelay(d time.Duration) Option { return downDelayOpt(d) }
+
+type pollIntervalOpt time.Duration
+
+func (o pollIntervalOpt) apply(cfg *config) { cfg.pollInterval = time.Duration(o) }
+
+// WithPollInterval sets how often to try to reconnect to a rebooting device.
+// The default is 10 seconds.
+func WithPollInterval(d time.Duration) Option { return pollIntervalOpt(d) }
+
+type clockOpt struct{ c clock.Clock }
+
+func (o clockOpt) apply(cfg *config) { cfg.clock = o.c }
+
+// WithClock sets the clock used to wait for a rebooting device.  It defaults
+// to the system clock.
+func WithClock(c clock.Clock) Option { return clockOpt{c} }
+
+func newConfig(opts []Option) config {
+	cfg := config{
+		downDelay:    30 * time.Second,
+		pollInterval: 10 * time.Second,
+	}
+	for _, opt := range opts {
+		opt.apply(&cfg)
+	}
+	cfg.clock = clock.Or(cfg.clock)
+	return cfg
+}
+
+func runChecks(ctx context.Context, sess *netconf.Session, checks []Check, kind string) error {
+	for i, check := range checks {
+		if err := check(ctx, sess); err != nil {
+			return fmt.Errorf("%s check %d failed: %w", kind, i+1, err)
+		}
+	}
+	return nil
+}
+
+// issue sends a maintenance rpc.  The device closing the session before
+// replying is not an error as many devices don't reply to a reboot.
+func issue(ctx context.Context, sess *netconf.Session, req any) error {
+	var resp netconf.OKResp
+	err := sess.Call(ctx, req, &resp)
+	if errors.Is(err, netconf.ErrClosed) {
+		return nil
+	}
+	return err
+}
+
+// Install installs a software image on the device.  The pre and post checks
+// are run on the given session.
+func Install(ctx context.Context, sess *netconf.Session, path string, opts ...Option) error {
+	cfg := newConfig(opts)
+
+	req := cfg.request
+	if req == nil {
+		var err error
+		if req, err = InstallRequest(sess, path); err != nil {
+			return err
+		}
+	}
+
+	if err := runChecks(ctx, sess, cfg.preChecks, "pre"); err != nil {
+		return err
+	}
+	if err := issue(ctx, sess, req); err != nil {
+		return fmt.Errorf("install failed: %w", err)
+	}
+	return runChecks(ctx, sess, cfg.postChecks, "post")
+}
+
+// Reboot reboots the device, closes the session and waits for the device to
+// come back by dialing it until it succeeds or ctx is done.  The post checks
+// are run on the new session which is returned.  If a post check fails the new
+// session is closed.
+func Reboot(ctx context.Context, sess *netconf.Session, dial Dialer, opts ...Option) (*netconf.Session, error) {
+	cfg := newConfig(opts)
+
+	req := cfg.request
+	if req == nil {
+		var err error
+		if req, err = RebootRequest(sess); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := runChecks(ctx, sess, cfg.preChecks, "pre"); err != nil {
+		return nil, err
+	}
+	if err := issue(ctx, sess, req); err != nil {
+		return nil, fmt.Errorf("reboot failed: %w", err)
+	}
+
+	// the device is going away so errors closing the session don't matter.
+	closeCtx, cancel := context.WithTimeout(ctx, time.Second)
+	_ = sess.Close(closeCtx)
+	cancel()
+
+	newSess, err := waitForDevice(ctx, dial, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := runChecks(ctx, newSess, cfg.postChecks, "post"); err != nil {
+		closeCtx, cancel := context.WithTimeout(ctx, time.Second)
+		_ = newSess.Close(closeCtx)
+		cancel()
+		return nil, err
+	}
+	return newSess, nil
+}
+
+func waitForDevice(ctx context.Context, dial Dialer, cfg config) (*netconf.Session, error) {
+	timer := cfg.clock.NewTimer(cfg.downDelay)
+	defer timer.Stop()
+
+	var lastErr error
+	for {
+		select {
+		case <-ctx.Done():
+			if lastErr != nil {
+				return nil, fmt.Errorf("device did not come back: %w (last error: %v)", ctx.Err(), lastErr)
+			}
+			return nil, fmt.Errorf("device did not come back: %w", ctx.Err())
+		case <-timer.C():
+		}
+
+		sess, err := dial(ctx)
+		if err == nil {
+			return sess, nil
+		}
+		lastErr = err
+		timer.Reset(cfg.pollInterval)
+	}
+}
@@ -0,0 +1,267 @@
+package maintenance
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nemith/netconf"
+	"github.com/nemith/netconf/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var msgIDRe = regexp.MustCompile(`message-id="([^"]+)"`)
+
+// fakeDevice is a transport that answers every rpc with `<ok/>` unless the
+// rpc matches drop in which case the connection is closed instead.
+type fakeDevice struct {
+	caps []string
+	drop string
+
+	mu   sync.Mutex
+	rpcs []string
+
+	in        chan string
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newFakeDevice(drop string, caps ...string) *fakeDevice {
+	return &fakeDevice{
+		caps: caps,
+		drop: drop,
+		in:   make(chan string, 10),
+		done: make(chan struct{}),
+	}
+}
+
+func (d *fakeDevice) open(t *testing.T) *netconf.Session {
+	sess, err := netconf.Open(d, netconf.WithErrorHandler(func(error) {}))
+	require.NoError(t, err)
+	return sess
+}
+
+func (d *fakeDevice) MsgReader() (io.ReadCloser, error) {
+	select {
+	case msg := <-d.in:
+		return io.NopCloser(strings.NewReader(msg)), nil
+	case <-d.done:
+		return nil, io.EOF
+	}
+}
+
+func (d *fakeDevice) MsgWriter() (io.WriteCloser, error) {
+	select {
+	case <-d.done:
+		return nil, io.ErrClosedPipe
+	default:
+	}
+	return &fakeWriter{d: d}, nil
+}
+
+func (d *fakeDevice) Close() error {
+	d.closeOnce.Do(func() { close(d.done) })
+	return nil
+}
+
+func (d *fakeDevice) handle(msg string) {
+	if strings.Contains(msg, "<hello") {
+		var caps strings.Builder
+		for _, c := range append([]string{"urn:ietf:params:netconf:base:1.0"}, d.caps...) {
+			fmt.Fprintf(&caps, "<capability>%s</capability>", c)
+		}
+		d.in <- `<hello xmlns="urn:ietf:params:xml:ns:netconf:base:1.0"><capabilities>` + caps.String() + `</capabilities><session-id>1</session-id></hello>`
+		return
+	}
+
+	d.mu.Lock()
+	d.rpcs = append(d.rpcs, msg)
+	d.mu.Unlock()
+
+	if d.drop != "" && strings.Contains(msg, d.drop) {
+		d.Close()
+		return
+	}
+
+	id := msgIDRe.FindStringSubmatch(msg)[1]
+	d.in <- `<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="` + id + `"><ok/></rpc-reply>`
+}
+
+func (d *fakeDevice) sent() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.rpcs...)
+}
+
+type fakeWriter struct {
+	d   *fakeDevice
+	buf bytes.Buffer
+}
+
+func (w *fakeWriter) Write(p []byte) (int, error) { return w.buf.Write(p) }
+
+func (w *fakeWriter) Close() error {
+	w.d.handle(w.buf.String())
+	return nil
+}
+
+func TestRebootRequest(t *testing.T) {
+	tt := []struct {
+		name string
+		caps []string
+		want any
+	}{
+		{"ietf-system", []string{IETFSystemNamespace + "?module=ietf-system&amp;revision=2014-08-06"}, &SystemRestartReq{}},
+		{"junos", []string{JunosCapability}, &JunosRebootReq{}},
+		{"unknown", nil, nil},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			sess := newFakeDevice("", tc.caps...).open(t)
+			req, err := RebootRequest(sess)
+			if tc.want == nil {
+				assert.ErrorIs(t, err, ErrUnsupported)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, req)
+		})
+	}
+}
+
+func TestReboot(t *testing.T) {
+	dev := newFakeDevice("request-reboot", JunosCapability)
+	sess := dev.open(t)
+
+	var checks []string
+	check := func(name string) Check {
+		return func(ctx context.Context, s *netconf.Session) error {
+			checks = append(checks, name)
+			return nil
+		}
+	}
+
+	attempts := 0
+	newDev := newFakeDevice("", JunosCapability)
+	dial := func(ctx context.Context) (*netconf.Session, error) {
+		attempts++
+		if attempts < 3 {
+			return nil, errors.New("connection refused")
+		}
+		return newDev.open(t), nil
+	}
+
+	newSess, err := Reboot(context.Background(), sess, dial,
+		WithDownDelay(time.Millisecond),
+		WithPollInterval(time.Millisecond),
+		WithPreCheck(check("pre")),
+		WithPostCheck(check("post")),
+	)
+	require.NoError(t, err)
+	assert.NotSame(t, sess, newSess)
+	assert.Equal(t, 3, attempts)
+	assert.Equal(t, []string{"pre", "post"}, checks)
+	require.Len(t, dev.sent(), 1)
+	assert.Contains(t, dev.sent()[0], "<request-reboot></request-reboot>")
+}
+
+func TestRebootClock(t *testing.T) {
+	sess := newFakeDevice("request-reboot", JunosCapability).open(t)
+
+	clk := clock.NewFake(time.Now())
+	dials := make(chan struct{}, 2)
+	newDev := newFakeDevice("", JunosCapability)
+	dial := func(ctx context.Context) (*netconf.Session, error) {
+		dials <- struct{}{}
+		if len(dials) < 2 {
+			return nil, errors.New("connection refused")
+		}
+		return newDev.open(t), nil
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := Reboot(context.Background(), sess, dial, WithClock(clk))
+		done <- err
+	}()
+
+	// the down delay and then the poll interval are waited on the clock.
+	clk.BlockUntil(1)
+	assert.Empty(t, dials)
+	clk.Advance(30 * time.Second)
+
+	clk.BlockUntil(1)
+	assert.Len(t, dials, 1)
+	clk.Advance(10 * time.Second)
+	require.NoError(t, <-done)
+	assert.Len(t, dials, 2)
+}
+
+func TestRebootPreCheckFailed(t *testing.T) {
+	dev := newFakeDevice("", JunosCapability)
+	sess := dev.open(t)
+
+	_, err := Reboot(context.Background(), sess, nil,
+		WithPreCheck(func(context.Context, *netconf.Session) error { return errors.New("alarms active") }))
+	assert.ErrorContains(t, err, "pre check 1 failed: alarms active")
+	assert.Empty(t, dev.sent())
+}
+
+func TestRebootPostCheckFailed(t *testing.T) {
+	sess := newFakeDevice("request-reboot", JunosCapability).open(t)
+
+	var newSess *netconf.Session
+	dial := func(ctx context.Context) (*netconf.Session, error) {
+		newSess = newFakeDevice("", JunosCapability).open(t)
+		return newSess, nil
+	}
+
+	got, err := Reboot(context.Background(), sess, dial,
+		WithDownDelay(time.Millisecond),
+		WithPostCheck(func(context.Context, *netconf.Session) error { return errors.New("interfaces down") }))
+	assert.ErrorContains(t, err, "post check 1 failed: interfaces down")
+	assert.Nil(t, got)
+
+	// the new session isn't leaked.
+	require.NotNil(t, newSess)
+	select {
+	case <-newSess.Done():
+	case <-time.After(time.Second):
+		t.Fatal("new session not closed")
+	}
+}
+
+func TestRebootTimeout(t *testing.T) {
+	sess := newFakeDevice("system-restart", IETFSystemNamespace+"?module=ietf-system").open(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := Reboot(ctx, sess,
+		func(context.Context) (*netconf.Session, error) { return nil, errors.New("no route to host") },
+		WithDownDelay(time.Millisecond),
+		WithPollInterval(time.Millisecond),
+	)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorContains(t, err, "no route to host")
+}
+
+func TestInstall(t *testing.T) {
+	dev := newFakeDevice("", JunosCapability)
+	sess := dev.open(t)
+
+	require.NoError(t, Install(context.Background(), sess, "/var/tmp/junos.tgz"))
+	require.Len(t, dev.sent(), 1)
+	assert.Contains(t, dev.sent()[0], "<request-package-add><package-name>/var/tmp/junos.tgz</package-name></request-package-add>")
+
+	_, err := InstallRequest(newFakeDevice("").open(t), "image.bin")
+	assert.ErrorIs(t, err, ErrUnsupported)
+}
@@ -0,0 +1,68 @@
+package netconf
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrMessageTooLarge is returned when a message received from the server is
+// larger than the limit set with [WithMaxMessageSize].
+var ErrMessageTooLarge = errors.New("message exceeds maximum size")
+
+type maxMessageSizeOpt int64
+
+func (o maxMessageSizeOpt) apply(cfg *sessionConfig) { cfg.maxMessageSize = int64(o) }
+
+// WithMaxMessageSize limits the size in bytes of messages received from the
+// server.  Reading a message that exceeds the limit is aborted and the rest of
+// the message is discarded.  If the message is a `<rpc-reply>` the rpc fails
+// with [ErrMessageTooLarge], otherwise the error is reported to the
+// [ErrorHandler].  A limit of 0 or less means no limit which is the default.
+func WithMaxMessageSize(n int64) SessionOption { return maxMessageSizeOpt(n) }
+
+// limitReader returns ErrMessageTooLarge once more than left bytes are read.
+type limitReader struct {
+	io.ReadCloser
+	limit int64
+	left  int64
+}
+
+func (r *limitReader) Read(p []byte) (int, error) {
+	if r.left <= 0 {
+		// only fail if there is more to the message.
+		var b [1]byte
+		n, err := r.ReadCloser.Read(b[:])
+		if n > 0 {
+			return 0, fmt.Errorf("%w of %d bytes", ErrMessageTooLarge, r.limit)
+		}
+		return 0, err
+	}
+
+	if int64(len(p)) > r.left {
+		p = p[:r.left]
+	}
+	n, err := r.ReadCloser.Read(p)
+	r.left -= int64(n)
+	return n, err
+}
+
+// failOversizedReply fails the rpc waiting for a reply that was too large to
+// read.  The message-id is taken from the attributes of the reply element
+// which are read before the limit is hit.
+func (s *Session) failOversizedReply(attrs []xml.Attr, err error) bool {
+	for _, attr := range attrs {
+		if attr.Name.Space != "" || attr.Name.Local != "message-id" {
+			continue
+		}
+		ok, r := s.req(attr.Value)
+		if !ok {
+			return false
+		}
+		r.err = err
+		close(r.reply)
+		return true
+	}
+	return false
+}
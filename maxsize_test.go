@@ -0,0 +1,47 @@
+package netconf
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaxMessageSize(t *testing.T) {
+	var errs []error
+	ts := newTestServer(t)
+	tr := ts.transport()
+	sess := newSession(tr,
+		WithMaxMessageSize(200),
+		WithErrorHandler(func(err error) { errs = append(errs, err) }))
+	go sess.recv()
+
+	big := strings.Repeat("<interface><name>ge-0/0/0</name></interface>", 100)
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><data>` + big + `</data></rpc-reply>`)
+	_, err := sess.GetConfig(context.Background(), Running)
+	assert.ErrorIs(t, err, ErrMessageTooLarge)
+
+	// the session is still usable
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="2"><data/></rpc-reply>`)
+	_, err = sess.GetConfig(context.Background(), Running)
+	require.NoError(t, err)
+	assert.Empty(t, errs)
+}
+
+func TestMaxMessageSizeNotification(t *testing.T) {
+	tr := newTestServer(t).transport()
+	sess := newSession(tr,
+		WithMaxMessageSize(100),
+		WithNotificationHandler(func(Notification) { t.Error("unexpected notification") }))
+
+	tr.pushMsg(`<notification xmlns="urn:ietf:params:xml:ns:netconf:notification:1.0"><eventTime>2023-06-07T18:31:48Z</eventTime><event>` + strings.Repeat("x", 200) + `</event></notification>`)
+	assert.ErrorIs(t, sess.recvMsg(), ErrMessageTooLarge)
+
+	// exactly at the limit is fine
+	msg := `<notification xmlns="urn:ietf:params:xml:ns:netconf:notification:1.0"></notification>`
+	sess = newSession(tr, WithMaxMessageSize(int64(len(msg))))
+	tr.pushMsg(msg)
+	assert.NoError(t, sess.recvMsg())
+}
@@ -0,0 +1,284 @@
+package netconf
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Metrics is a hook that can be given to a session with [WithMetrics] to
+// collect statistics about the session without having to wrap every call
+// site.  All methods may be called concurrently and must not block.
+//
+// [SessionStats] is a ready made implementation that can be published with
+// expvar or scraped to be exported to other systems like Prometheus.
+type Metrics interface {
+	// RPCSent is called after a rpc message has been written to the
+	// transport.  `op` is the name of the operation (i.e `get-config`).
+	RPCSent(op string)
+
+	// RPCReplied is called when a `<rpc-reply>` has been received for a rpc
+	// with the time it took from sending the request to receiving the reply.
+	// errs are any `<rpc-error>` elements contained in the reply.
+	RPCReplied(op string, latency time.Duration, errs RPCErrors)
+
+	// RPCFailed is called when a rpc did not receive a reply (i.e the context
+	// was canceled or the session was closed).
+	RPCFailed(op string, err error)
+
+	// NotificationReceived is called for every `<notification>` message.
+	NotificationReceived()
+
+	// BytesRead and BytesWritten are called with the number of (unframed)
+	// message bytes read from or written to the transport.
+	BytesRead(n int)
+	BytesWritten(n int)
+}
+
+type nopMetrics struct{}
+
+func (nopMetrics) RPCSent(string)                              {}
+func (nopMetrics) RPCReplied(string, time.Duration, RPCErrors) {}
+func (nopMetrics) RPCFailed(string, error)                     {}
+func (nopMetrics) NotificationReceived()                       {}
+func (nopMetrics) BytesRead(int)                               {}
+func (nopMetrics) BytesWritten(int)                            {}
+
+type metricsOpt struct{ m Metrics }
+
+func (o metricsOpt) apply(cfg *sessionConfig) { cfg.metrics = o.m }
+
+// WithMetrics sets a [Metrics] hook used to record statistics for the
+// session.  The same Metrics can be shared between multiple sessions to
+// aggregate statistics.
+func WithMetrics(m Metrics) SessionOption {
+	if m == nil {
+		m = nopMetrics{}
+	}
+	return metricsOpt{m}
+}
+
+// DefaultLatencyBuckets are the upper bounds of the buckets used for the rpc
+// latency histograms in [SessionStats].
+var DefaultLatencyBuckets = []time.Duration{
+	1 * time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	1 * time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+	30 * time.Second,
+}
+
+// LatencyHistogram is a cumulative histogram of rpc latencies.  Counts[i] is
+// the number of observations less than or equal to Buckets[i]. The last
+// element of Counts is the +Inf bucket and is always equal to Count.
+type LatencyHistogram struct {
+	Buckets []time.Duration `json:"buckets"`
+	Counts  []uint64        `json:"counts"`
+	Count   uint64          `json:"count"`
+	Sum     time.Duration   `json:"sum"`
+}
+
+func newLatencyHistogram() *LatencyHistogram {
+	return &LatencyHistogram{
+		Buckets: DefaultLatencyBuckets,
+		Counts:  make([]uint64, len(DefaultLatencyBuckets)+1),
+	}
+}
+
+func (h *LatencyHistogram) observe(d time.Duration) {
+	for i, le := range h.Buckets {
+		if d <= le {
+			h.Counts[i]++
+		}
+	}
+	h.Counts[len(h.Buckets)]++
+	h.Count++
+	h.Sum += d
+}
+
+func (h *LatencyHistogram) clone() *LatencyHistogram {
+	c := *h
+	c.Counts = append([]uint64(nil), h.Counts...)
+	return &c
+}
+
+// StatsSnapshot is a point in time copy of the statistics held in
+// [SessionStats].
+type StatsSnapshot struct {
+	RPCsSent      uint64                       `json:"rpcs_sent"`
+	Replies       uint64                       `json:"replies"`
+	RPCFailures   uint64                       `json:"rpc_failures"`
+	RPCErrors     map[ErrTag]uint64            `json:"rpc_errors"`
+	Notifications uint64                       `json:"notifications"`
+	BytesIn       uint64                       `json:"bytes_in"`
+	BytesOut      uint64                       `json:"bytes_out"`
+	Latency       map[string]*LatencyHistogram `json:"latency"`
+}
+
+// SessionStats is an implementation of [Metrics] that keeps counters in
+// memory.  It implements `expvar.Var` so it can be published directly:
+//
+//	stats := &netconf.SessionStats{}
+//	expvar.Publish("netconf", stats)
+//	session, err := netconf.Open(tr, netconf.WithMetrics(stats))
+//
+// The zero value is ready to use.
+type SessionStats struct {
+	mu   sync.Mutex
+	snap StatsSnapshot
+}
+
+func (s *SessionStats) RPCSent(string) {
+	s.mu.Lock()
+	s.snap.RPCsSent++
+	s.mu.Unlock()
+}
+
+func (s *SessionStats) RPCReplied(op string, latency time.Duration, errs RPCErrors) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.snap.Replies++
+	for _, err := range errs {
+		if s.snap.RPCErrors == nil {
+			s.snap.RPCErrors = make(map[ErrTag]uint64)
+		}
+		s.snap.RPCErrors[err.Tag]++
+	}
+
+	if s.snap.Latency == nil {
+		s.snap.Latency = make(map[string]*LatencyHistogram)
+	}
+	h, ok := s.snap.Latency[op]
+	if !ok {
+		h = newLatencyHistogram()
+		s.snap.Latency[op] = h
+	}
+	h.observe(latency)
+}
+
+func (s *SessionStats) RPCFailed(string, error) {
+	s.mu.Lock()
+	s.snap.RPCFailures++
+	s.mu.Unlock()
+}
+
+func (s *SessionStats) NotificationReceived() {
+	s.mu.Lock()
+	s.snap.Notifications++
+	s.mu.Unlock()
+}
+
+func (s *SessionStats) BytesRead(n int) {
+	s.mu.Lock()
+	s.snap.BytesIn += uint64(n)
+	s.mu.Unlock()
+}
+
+func (s *SessionStats) BytesWritten(n int) {
+	s.mu.Lock()
+	s.snap.BytesOut += uint64(n)
+	s.mu.Unlock()
+}
+
+// Snapshot returns a copy of the current statistics.
+func (s *SessionStats) Snapshot() StatsSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snap := s.snap
+	if s.snap.RPCErrors != nil {
+		snap.RPCErrors = make(map[ErrTag]uint64, len(s.snap.RPCErrors))
+		for tag, n := range s.snap.RPCErrors {
+			snap.RPCErrors[tag] = n
+		}
+	}
+	if s.snap.Latency != nil {
+		snap.Latency = make(map[string]*LatencyHistogram, len(s.snap.Latency))
+		for op, h := range s.snap.Latency {
+			snap.Latency[op] = h.clone()
+		}
+	}
+	return snap
+}
+
+// String returns the statistics encoded as JSON.  This implements the
+// `expvar.Var` interface.
+func (s *SessionStats) String() string {
+	out, err := json.Marshal(s.Snapshot())
+	if err != nil {
+		return "{}"
+	}
+	return string(out)
+}
+
+// operationName returns the element name of a rpc operation used for
+// labeling metrics.  The name is taken from the `XMLName` field of the struct
+// (either the value or the struct tag) falling back to the name of the type.
+func operationName(op any) string {
+	v := reflect.ValueOf(op)
+	for v.IsValid() && (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) {
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return ""
+	}
+
+	if v.Kind() == reflect.Struct {
+		if f, ok := v.Type().FieldByName("XMLName"); ok && f.Type == reflect.TypeOf(xml.Name{}) {
+			if name := v.FieldByIndex(f.Index).Interface().(xml.Name); name.Local != "" {
+				return name.Local
+			}
+
+			tag, _, _ := strings.Cut(f.Tag.Get("xml"), ",")
+			if i := strings.LastIndexByte(tag, ' '); i >= 0 {
+				tag = tag[i+1:]
+			}
+			if tag != "" {
+				return tag
+			}
+		}
+	}
+
+	return v.Type().Name()
+}
+
+// countingReader and countingWriter report the number of bytes that pass
+// through them to a Metrics hook.
+type countingReader struct {
+	io.ReadCloser
+	m Metrics
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		r.m.BytesRead(n)
+	}
+	return n, err
+}
+
+type countingWriter struct {
+	io.WriteCloser
+	m Metrics
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.WriteCloser.Write(p)
+	if n > 0 {
+		w.m.BytesWritten(n)
+	}
+	return n, err
+}
@@ -0,0 +1,74 @@
+package netconf
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOperationName(t *testing.T) {
+	var anyReq any = &GetConfigReq{}
+	tt := []struct {
+		name string
+		op   any
+		want string
+	}{
+		{"tag", &GetConfigReq{}, "get-config"},
+		{"value", &struct{ XMLName xml.Name }{XMLName: xml.Name{Local: "foo"}}, "foo"},
+		{"namespaced tag", CreateSubscriptionReq{}, "create-subscription"},
+		{"pointer to interface", &anyReq, "get-config"},
+		{"no xmlname", struct{ Foo string }{}, ""},
+		{"named no xmlname", OKResp{}, "OKResp"},
+		{"nil", nil, ""},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, operationName(tc.op))
+		})
+	}
+}
+
+func TestSessionStats(t *testing.T) {
+	stats := &SessionStats{}
+
+	ts := newTestServer(t)
+	sess := newSession(ts.transport(), WithMetrics(stats))
+	go sess.recv()
+
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><ok/></rpc-reply>`)
+	err := sess.Lock(context.Background(), Candidate)
+	require.NoError(t, err)
+	_, err = ts.popReq()
+	require.NoError(t, err)
+
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="2">
+  <rpc-error>
+	<error-type>protocol</error-type>
+	<error-tag>lock-denied</error-tag>
+	<error-severity>error</error-severity>
+  </rpc-error>
+</rpc-reply>`)
+	err = sess.Lock(context.Background(), Candidate)
+	assert.Error(t, err)
+	_, err = ts.popReq()
+	require.NoError(t, err)
+
+	snap := stats.Snapshot()
+	assert.Equal(t, uint64(2), snap.RPCsSent)
+	assert.Equal(t, uint64(2), snap.Replies)
+	assert.Equal(t, map[ErrTag]uint64{ErrLockDenied: 1}, snap.RPCErrors)
+	assert.NotZero(t, snap.BytesIn)
+	assert.NotZero(t, snap.BytesOut)
+	require.Contains(t, snap.Latency, "lock")
+	assert.Equal(t, uint64(2), snap.Latency["lock"].Count)
+	assert.Equal(t, uint64(2), snap.Latency["lock"].Counts[len(DefaultLatencyBuckets)])
+
+	var decoded StatsSnapshot
+	assert.NoError(t, json.Unmarshal([]byte(stats.String()), &decoded))
+	assert.Equal(t, snap.RPCsSent, decoded.RPCsSent)
+}
@@ -1,11 +1,14 @@
 package netconf
 
 import (
+	"bytes"
 	"encoding/xml"
 	"fmt"
+	"io"
 	"strings"
 	"time"
 
+	"github.com/nemith/netconf/xmltree"
 	"golang.org/x/exp/slices"
 )
 
@@ -46,9 +49,11 @@ type helloMsg struct {
 
 // request maps the xml value of <rpc> in RFC6241
 type request struct {
-	XMLName   xml.Name `xml:"urn:ietf:params:xml:ns:netconf:base:1.0 rpc"`
-	MessageID uint64   `xml:"message-id,attr"`
-	Operation any      `xml:",innerxml"`
+	XMLName   xml.Name    `xml:"urn:ietf:params:xml:ns:netconf:base:1.0 rpc"`
+	MessageID string      `xml:"message-id,attr"`
+	Attrs     []xml.Attr  `xml:",any,attr"`
+	Comment   xml.Comment `xml:",comment"`
+	Operation any         `xml:",innerxml"`
 }
 
 func (msg *request) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
@@ -67,15 +72,53 @@ func (msg *request) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
 // Reply maps the xml value of <rpc-reply> in RFC6241
 type Reply struct {
 	XMLName   xml.Name  `xml:"urn:ietf:params:xml:ns:netconf:base:1.0 rpc-reply"`
-	MessageID uint64    `xml:"message-id,attr"`
+	MessageID string    `xml:"message-id,attr"`
 	Errors    RPCErrors `xml:"rpc-error,omitempty"`
 	Body      []byte    `xml:",innerxml"`
+
+	requestRaw []byte
+	decoder    *decoderSettings
+}
+
+// UnmarshalXML implements xml.Unmarshaler to keep the settings of the decoder
+// for decoding the body.
+func (r *Reply) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	// alias the type to not cause recursion calling d.DecodeElement
+	type reply Reply
+	var inner reply
+	if err := d.DecodeElement(&inner, &start); err != nil {
+		return err
+	}
+	*r = Reply(inner)
+	r.decoder = settingsOf(d)
+	return nil
 }
 
 // Decode will decode the body of a reply into a value pointed to by v.  This is
-// a simple wrapper around xml.Unmarshal.
+// a simple wrapper around xml.Unmarshal using the settings of the decoder
+// that read the reply (see [WithDecoderConfig]).
 func (r Reply) Decode(v interface{}) error {
-	return xml.Unmarshal(r.Body, v)
+	return r.decoder.unmarshal(r.Body, v)
+}
+
+// Find returns the elements of the body selected by an XPath expression (see
+// [xmltree.Path] for the supported subset).  Paths start at the children of
+// `<rpc-reply>` and names without a prefix match any namespace, i.e:
+//
+//	nodes, err := reply.Find("/data/interfaces/interface[name='eth0']/mtu")
+//	nodes, err := reply.Find("//interface[enabled='true']/name")
+//
+// Use [xmltree.Find] to match namespaces by prefix.
+func (r Reply) Find(xpath string) ([]*xmltree.Node, error) {
+	path, err := xmltree.Compile(xpath, nil)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := xmltree.Parse(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	return path.Find(nodes), nil
 }
 
 // Err will return go error(s) from a Reply that are of the given severities. If
@@ -118,16 +161,141 @@ func (r Reply) Err(severity ...ErrSeverity) error {
 	}
 }
 
+// Notification maps the xml value of <notification> in RFC5277.
 type Notification struct {
 	XMLName   xml.Name  `xml:"urn:ietf:params:xml:ns:netconf:notification:1.0 notification"`
 	EventTime time.Time `xml:"eventTime"`
-	Body      []byte    `xml:",innerxml"`
+
+	// Body is the entire contents of the notification including the
+	// `<eventTime>` element.  Use Raw or Decode to access the event itself.
+	Body []byte `xml:",innerxml"`
+
+	eventName  xml.Name
+	event      []byte
+	namespaces []xml.Attr
+	decoder    *decoderSettings
+}
+
+// UnmarshalXML implements xml.Unmarshaler to split the event content out of
+// the notification.
+func (n *Notification) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	// alias the type to not cause recursion calling d.DecodeElement
+	type notification Notification
+	var inner notification
+	if err := d.DecodeElement(&inner, &start); err != nil {
+		return err
+	}
+	*n = Notification(inner)
+	n.decoder = settingsOf(d)
+	for _, attr := range start.Attr {
+		if attr.Name.Space == "xmlns" || (attr.Name.Space == "" && attr.Name.Local == "xmlns") {
+			n.namespaces = append(n.namespaces, attr)
+		}
+	}
+	return n.splitEvent()
+}
+
+// splitEvent finds the event element (the first element that is not
+// `<eventTime>`) in the body of the notification.
+func (n *Notification) splitEvent() error {
+	d, base, err := n.scopedDecoder(n.Body)
+	if err != nil {
+		return fmt.Errorf("failed to parse notification body: %w", err)
+	}
+	for {
+		offset := d.InputOffset() - base
+		tok, err := d.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to parse notification body: %w", err)
+		}
+
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		if start.Name.Local == "eventTime" {
+			if err := d.Skip(); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := d.Skip(); err != nil {
+			return fmt.Errorf("failed to parse notification event: %w", err)
+		}
+		n.eventName = start.Name
+		n.event = n.Body[offset : d.InputOffset()-base]
+		return nil
+	}
 }
 
-// Decode will decode the body of a noticiation into a value pointed to by v.
-// This is a simple wrapper around xml.Unmarshal.
-func (r Notification) Decode(v interface{}) error {
-	return xml.Unmarshal(r.Body, v)
+// scopedDecoder returns a decoder for data, which is part of the body, with
+// the namespaces declared on the `<notification>` element in scope.  The
+// returned offset is subtracted from the decoder's offsets to get offsets in
+// data.
+func (n *Notification) scopedDecoder(data []byte) (*xml.Decoder, int64, error) {
+	var open bytes.Buffer
+	open.WriteString("<scope")
+	for _, attr := range n.namespaces {
+		open.WriteString(" xmlns")
+		if attr.Name.Space != "" {
+			open.WriteString(":" + attr.Name.Local)
+		}
+		open.WriteString(`="`)
+		if err := xml.EscapeText(&open, []byte(attr.Value)); err != nil {
+			return nil, 0, err
+		}
+		open.WriteString(`"`)
+	}
+	open.WriteString(">")
+	base := int64(open.Len())
+
+	d := n.decoder.newDecoder(io.MultiReader(&open, bytes.NewReader(data), strings.NewReader("</scope>")))
+	if _, err := d.Token(); err != nil {
+		return nil, 0, err
+	}
+	return d, base, nil
+}
+
+// EventName returns the name of the event element of the notification (i.e
+// `{urn:ietf:params:xml:ns:yang:ietf-netconf-notifications netconf-config-change}`).
+func (n Notification) EventName() xml.Name {
+	return n.eventName
+}
+
+// Namespaces returns the namespace declarations of the `<notification>`
+// element.  Prefixes declared there may be used in Body so they are needed to
+// parse Body on its own.  The default namespace is named `xmlns` and prefixes
+// are in the `xmlns` space as returned by an xml.Decoder.
+func (n Notification) Namespaces() []xml.Attr {
+	return n.namespaces
+}
+
+// Raw returns the raw xml of the event element of the notification.  Prefixes
+// in it may be declared on the `<notification>` element (see
+// [Notification.Namespaces]).
+func (n Notification) Raw() []byte {
+	return n.event
+}
+
+// Decode will decode the event element of the notification into a value
+// pointed to by v.  This is a simple wrapper around xml.Unmarshal using the
+// settings of the decoder that read the notification (see
+// [WithDecoderConfig]) and the namespaces declared on the `<notification>`
+// element.
+func (n Notification) Decode(v interface{}) error {
+	if n.event == nil {
+		return fmt.Errorf("notification does not contain an event")
+	}
+	d, _, err := n.scopedDecoder(n.event)
+	if err != nil {
+		return err
+	}
+	return d.Decode(v)
 }
 
 type ErrSeverity string
@@ -2,9 +2,12 @@ package netconf
 
 import (
 	"encoding/xml"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 var rawXMLTests = []struct {
@@ -195,7 +198,7 @@ func TestMarshalRPCMsg(t *testing.T) {
 	for _, tc := range tt {
 		t.Run(tc.name, func(t *testing.T) {
 			out, err := xml.Marshal(&request{
-				MessageID: 1,
+				MessageID: "1",
 				Operation: tc.operation,
 			})
 			t.Logf("out: %s", out)
@@ -239,7 +242,7 @@ func TestUnmarshalRPCReply(t *testing.T) {
 					Space: "urn:ietf:params:xml:ns:netconf:base:1.0",
 					Local: "rpc-reply",
 				},
-				MessageID: 1,
+				MessageID: "1",
 				Errors: []RPCError{
 					{
 						Type:     ErrTypeProtocol,
@@ -276,3 +279,90 @@ func TestUnmarshalRPCReply(t *testing.T) {
 	}
 
 }
+
+func TestReplyFind(t *testing.T) {
+	const raw = `<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1">
+  <data>
+    <interfaces xmlns="urn:ietf:params:xml:ns:yang:ietf-interfaces">
+      <interface><name>eth0</name><mtu>1500</mtu></interface>
+      <interface><name>eth1</name><mtu>9000</mtu></interface>
+    </interfaces>
+  </data>
+</rpc-reply>`
+
+	var reply Reply
+	require.NoError(t, xml.Unmarshal([]byte(raw), &reply))
+
+	nodes, err := reply.Find("/data/interfaces/interface[name='eth1']/mtu")
+	require.NoError(t, err)
+	require.Len(t, nodes, 1)
+	assert.Equal(t, "9000", nodes[0].Text)
+
+	nodes, err = reply.Find("//name")
+	require.NoError(t, err)
+	assert.Len(t, nodes, 2)
+
+	_, err = reply.Find("/data/if:interfaces")
+	assert.Error(t, err)
+}
+
+func TestUnmarshalNotification(t *testing.T) {
+	const raw = `<notification xmlns="urn:ietf:params:xml:ns:netconf:notification:1.0">
+  <eventTime>2023-06-07T18:31:48Z</eventTime>
+  <netconf-config-change xmlns="urn:ietf:params:xml:ns:yang:ietf-netconf-notifications">
+    <changed-by><username>admin</username></changed-by>
+    <datastore>running</datastore>
+  </netconf-config-change>
+</notification>`
+
+	var got Notification
+	err := xml.Unmarshal([]byte(raw), &got)
+	assert.NoError(t, err)
+
+	assert.Equal(t, time.Date(2023, time.June, 7, 18, 31, 48, 0, time.UTC), got.EventTime)
+	assert.Equal(t, xml.Name{
+		Space: "urn:ietf:params:xml:ns:yang:ietf-netconf-notifications",
+		Local: "netconf-config-change",
+	}, got.EventName())
+	assert.True(t, strings.HasPrefix(string(got.Raw()), "<netconf-config-change"))
+	assert.True(t, strings.HasSuffix(string(got.Raw()), "</netconf-config-change>"))
+
+	var event struct {
+		Username  string `xml:"changed-by>username"`
+		Datastore string `xml:"datastore"`
+	}
+	err = got.Decode(&event)
+	assert.NoError(t, err)
+	assert.Equal(t, "admin", event.Username)
+	assert.Equal(t, "running", event.Datastore)
+}
+
+func TestNotificationNamespaces(t *testing.T) {
+	const raw = `<notification xmlns="urn:ietf:params:xml:ns:netconf:notification:1.0" xmlns:ex="urn:example"><eventTime>2023-06-07T18:31:48Z</eventTime><ex:link-down><ex:name>eth0</ex:name></ex:link-down></notification>`
+
+	var got Notification
+	require.NoError(t, xml.Unmarshal([]byte(raw), &got))
+	assert.Equal(t, []xml.Attr{
+		{Name: xml.Name{Local: "xmlns"}, Value: "urn:ietf:params:xml:ns:netconf:notification:1.0"},
+		{Name: xml.Name{Space: "xmlns", Local: "ex"}, Value: "urn:example"},
+	}, got.Namespaces())
+
+	// the prefix declared on the notification is resolved in the event.
+	assert.Equal(t, xml.Name{Space: "urn:example", Local: "link-down"}, got.EventName())
+	assert.Equal(t, "<ex:link-down><ex:name>eth0</ex:name></ex:link-down>", string(got.Raw()))
+
+	var event struct {
+		XMLName xml.Name `xml:"urn:example link-down"`
+		Name    string   `xml:"urn:example name"`
+	}
+	require.NoError(t, got.Decode(&event))
+	assert.Equal(t, "eth0", event.Name)
+}
+
+func TestNotificationNoEvent(t *testing.T) {
+	var got Notification
+	err := xml.Unmarshal([]byte(`<notification xmlns="urn:ietf:params:xml:ns:netconf:notification:1.0"><eventTime>2023-06-07T18:31:48Z</eventTime></notification>`), &got)
+	assert.NoError(t, err)
+	assert.Nil(t, got.Raw())
+	assert.Error(t, got.Decode(&struct{}{}))
+}
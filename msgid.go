@@ -0,0 +1,50 @@
+package netconf
+
+import (
+	"crypto/rand"
+	"fmt"
+	"strconv"
+)
+
+// MessageIDFunc generates the `message-id` attribute for a rpc.  seq is a
+// per-session counter starting at 1 and incremented for every rpc.
+//
+// RFC6241 defines the message-id as an arbitrary string so replies are
+// correlated by the exact string echoed by the server.  The generated ids
+// must be unique amongst the in-flight rpcs of a session.
+type MessageIDFunc func(seq uint64) string
+
+// SequentialMessageID generates message-ids as a decimal counter (i.e `1`,
+// `2`, ...).  This is the default.
+func SequentialMessageID(seq uint64) string {
+	return strconv.FormatUint(seq, 10)
+}
+
+// UUIDMessageID generates message-ids as random (version 4) UUID URNs (i.e
+// `urn:uuid:6ba7b810-9dad-41d1-80b4-00c04fd430c8`).
+func UUIDMessageID(uint64) string {
+	var u [16]byte
+	if _, err := rand.Read(u[:]); err != nil {
+		panic(fmt.Sprintf("netconf: failed to generate uuid: %v", err))
+	}
+	u[6] = (u[6] & 0x0f) | 0x40
+	u[8] = (u[8] & 0x3f) | 0x80
+	return fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:])
+}
+
+// PrefixMessageID generates message-ids with the sequence number appended to
+// a prefix (i.e `myapp-1`).  This is useful to identify the rpcs of a client
+// in device logs.
+func PrefixMessageID(prefix string) MessageIDFunc {
+	return func(seq uint64) string {
+		return prefix + strconv.FormatUint(seq, 10)
+	}
+}
+
+type messageIDFuncOpt MessageIDFunc
+
+func (o messageIDFuncOpt) apply(cfg *sessionConfig) { cfg.messageIDFunc = MessageIDFunc(o) }
+
+// WithMessageIDFunc sets the function used to generate the message-id of
+// rpcs.  The default is [SequentialMessageID].
+func WithMessageIDFunc(fn MessageIDFunc) SessionOption { return messageIDFuncOpt(fn) }
@@ -0,0 +1,44 @@
+package netconf
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessageIDFuncs(t *testing.T) {
+	assert.Equal(t, "42", SequentialMessageID(42))
+	assert.Equal(t, "myapp-42", PrefixMessageID("myapp-")(42))
+
+	uuidRe := regexp.MustCompile(`^urn:uuid:[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	a, b := UUIDMessageID(1), UUIDMessageID(1)
+	assert.Regexp(t, uuidRe, a)
+	assert.NotEqual(t, a, b)
+}
+
+func TestWithMessageIDFunc(t *testing.T) {
+	ts := newTestServer(t)
+	sess := newSession(ts.transport(), WithMessageIDFunc(func(seq uint64) string {
+		return "00" + SequentialMessageID(seq)
+	}))
+	go sess.recv()
+
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="001"><ok/></rpc-reply>`)
+	require.NoError(t, sess.Commit(context.Background()))
+
+	req, err := ts.popReqString()
+	require.NoError(t, err)
+	assert.Contains(t, req, `message-id="001"`)
+}
+
+func TestDuplicateMessageID(t *testing.T) {
+	ts := newTestServer(t)
+	sess := newSession(ts.transport(), WithMessageIDFunc(func(uint64) string { return "static" }))
+
+	sess.reqs["static"] = &req{}
+	_, err := sess.Do(context.Background(), &CommitReq{})
+	assert.ErrorContains(t, err, `message-id "static" is already in use`)
+}
@@ -0,0 +1,116 @@
+package netconf
+
+import (
+	"encoding/xml"
+	"fmt"
+	"sort"
+)
+
+const (
+	ncNamespace    = "urn:ietf:params:xml:ns:netconf:base:1.0"
+	notifNamespace = "urn:ietf:params:xml:ns:netconf:notification:1.0"
+)
+
+// NamespacePolicy controls how the session handles `<hello>`, `<rpc-reply>`
+// and `<notification>` messages that are not in the namespace required by the
+// RFCs.  Some devices send them without a namespace or in a vendor namespace.
+type NamespacePolicy int
+
+const (
+	// NamespaceLenient accepts messages with the expected local name in any
+	// namespace.  Each deviation is recorded (see
+	// [Session.NamespaceDeviations]) and the first occurrence of each is
+	// reported to the session's [ErrorHandler] as a [NamespaceError].  This
+	// is the default.
+	NamespaceLenient NamespacePolicy = iota
+
+	// NamespaceStrict rejects messages that are not in the expected
+	// namespace.
+	NamespaceStrict
+)
+
+type namespacePolicyOpt NamespacePolicy
+
+func (o namespacePolicyOpt) apply(cfg *sessionConfig) { cfg.namespacePolicy = NamespacePolicy(o) }
+
+// WithNamespacePolicy sets how messages in an unexpected namespace are
+// handled.
+func WithNamespacePolicy(p NamespacePolicy) SessionOption { return namespacePolicyOpt(p) }
+
+// NamespaceError describes a message that was not in the expected namespace.
+type NamespaceError struct {
+	Element  string
+	Expected string
+	Got      string
+}
+
+func (e *NamespaceError) Error() string {
+	return fmt.Sprintf("unexpected namespace for <%s>: got %q, expected %q", e.Element, e.Got, e.Expected)
+}
+
+// NamespaceDeviation is a count of messages received in an unexpected
+// namespace and accepted with [NamespaceLenient].
+type NamespaceDeviation struct {
+	NamespaceError
+	Count int
+}
+
+// NamespaceDeviations returns the messages accepted in an unexpected
+// namespace during the session.
+func (s *Session) NamespaceDeviations() []NamespaceDeviation {
+	s.nsMu.Lock()
+	defer s.nsMu.Unlock()
+
+	out := make([]NamespaceDeviation, 0, len(s.nsDeviations))
+	for _, d := range s.nsDeviations {
+		out = append(out, *d)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Element != out[j].Element {
+			return out[i].Element < out[j].Element
+		}
+		return out[i].Got < out[j].Got
+	})
+	return out
+}
+
+// matchName checks the name of a received element against the expected name
+// applying the session's namespace policy.  On success the namespace of start
+// is replaced with the expected namespace so it can be decoded into the
+// message types.
+func (s *Session) matchName(start *xml.StartElement, want xml.Name) (bool, error) {
+	if start.Name == want {
+		return true, nil
+	}
+	if start.Name.Local != want.Local {
+		return false, nil
+	}
+
+	nsErr := NamespaceError{
+		Element:  want.Local,
+		Expected: want.Space,
+		Got:      start.Name.Space,
+	}
+	if s.namespacePolicy == NamespaceStrict {
+		return false, &nsErr
+	}
+
+	s.nsMu.Lock()
+	if s.nsDeviations == nil {
+		s.nsDeviations = make(map[NamespaceError]*NamespaceDeviation)
+	}
+	d, seen := s.nsDeviations[nsErr]
+	if !seen {
+		d = &NamespaceDeviation{NamespaceError: nsErr}
+		s.nsDeviations[nsErr] = d
+	}
+	d.Count++
+	s.nsMu.Unlock()
+
+	if !seen {
+		s.handleError(&nsErr)
+	}
+
+	start.Name = want
+	return true, nil
+}
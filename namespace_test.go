@@ -0,0 +1,64 @@
+package netconf
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const helloBareNS = `
+<hello>
+  <capabilities>
+	<capability>urn:ietf:params:netconf:base:1.0</capability>
+  </capabilities>
+  <session-id>42</session-id>
+</hello>`
+
+func TestNamespaceLenient(t *testing.T) {
+	var errs []error
+	ts := newTestServer(t)
+	sess := newSession(ts.transport(), WithErrorHandler(func(err error) { errs = append(errs, err) }))
+
+	ts.queueRespString(helloBareNS)
+	require.NoError(t, sess.handshake())
+	_, err := ts.popReq()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(42), sess.SessionID())
+
+	go sess.recv()
+	for i := 0; i < 2; i++ {
+		ts.queueRespString(fmt.Sprintf(`<rpc-reply xmlns="urn:vendor" message-id="%d"><ok/></rpc-reply>`, i+1))
+		require.NoError(t, sess.Commit(context.Background()))
+	}
+
+	assert.Equal(t, []NamespaceDeviation{
+		{NamespaceError{Element: "hello", Expected: ncNamespace, Got: ""}, 1},
+		{NamespaceError{Element: "rpc-reply", Expected: ncNamespace, Got: "urn:vendor"}, 2},
+	}, sess.NamespaceDeviations())
+
+	// only the first occurrence of each deviation is reported
+	require.Len(t, errs, 2)
+	var nsErr *NamespaceError
+	assert.ErrorAs(t, errs[1], &nsErr)
+	assert.Equal(t, "rpc-reply", nsErr.Element)
+}
+
+func TestNamespaceStrict(t *testing.T) {
+	ts := newTestServer(t)
+	sess := newSession(ts.transport(), WithNamespacePolicy(NamespaceStrict))
+
+	ts.queueRespString(helloBareNS)
+	err := sess.handshake()
+	var nsErr *NamespaceError
+	assert.ErrorAs(t, err, &nsErr)
+
+	tr := ts.transport()
+	sess = newSession(tr, WithNamespacePolicy(NamespaceStrict))
+	tr.pushMsg(`<rpc-reply message-id="1"><ok/></rpc-reply>`)
+	err = sess.recvMsg()
+	assert.ErrorAs(t, err, &nsErr)
+	assert.Empty(t, sess.NamespaceDeviations())
+}
@@ -0,0 +1,147 @@
+package netconftest
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// Username and Password are the credentials accepted by the SSH
+	// listener.
+	Username = "netconf"
+	Password = "netconf"
+)
+
+func (s *Server) listen() net.Listener {
+	s.t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		s.t.Fatalf("netconftest: failed to listen: %v", err)
+	}
+	return ln
+}
+
+// ListenSSH serves NETCONF over SSH on a local port with a new host key.  It
+// returns the address and a client config that logs in with [Username] and
+// [Password] and only trusts the server's host key.
+func (s *Server) ListenSSH() (addr string, config *ssh.ClientConfig) {
+	s.t.Helper()
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		s.t.Fatalf("netconftest: failed to generate host key: %v", err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		s.t.Fatalf("netconftest: failed to generate host key: %v", err)
+	}
+
+	serverConfig := &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if conn.User() == Username && string(password) == Password {
+				return nil, nil
+			}
+			return nil, errors.New("invalid credentials")
+		},
+	}
+	serverConfig.AddHostKey(signer)
+
+	ln := s.listen()
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		_ = s.srv.ServeSSH(s.ctx, ln, serverConfig)
+	}()
+
+	return ln.Addr().String(), &ssh.ClientConfig{
+		User:            Username,
+		Auth:            []ssh.AuthMethod{ssh.Password(Password)},
+		HostKeyCallback: ssh.FixedHostKey(signer.PublicKey()),
+	}
+}
+
+// ListenTLS serves NETCONF over TLS on a local port with certificates from a
+// new CA.  It returns the address and a client config with a client
+// certificate for [Username] that trusts the CA.  The session's username is
+// the common name of the client certificate.
+func (s *Server) ListenTLS() (addr string, config *tls.Config) {
+	s.t.Helper()
+
+	ca := s.newCert(&x509.Certificate{
+		Subject:               pkix.Name{CommonName: "netconftest ca"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil)
+	serverCert := s.newCert(&x509.Certificate{
+		Subject:     pkix.Name{CommonName: "netconftest"},
+		DNSNames:    []string{"localhost"},
+		IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1)},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, &ca)
+	clientCert := s.newCert(&x509.Certificate{
+		Subject:     pkix.Name{CommonName: Username},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, &ca)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Leaf)
+
+	serverConfig := &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pool,
+	}
+	auth := func(chain []*x509.Certificate) (string, error) {
+		return chain[0].Subject.CommonName, nil
+	}
+
+	ln := s.listen()
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		_ = s.srv.ServeTLS(s.ctx, ln, serverConfig, auth)
+	}()
+
+	return ln.Addr().String(), &tls.Config{
+		Certificates: []tls.Certificate{clientCert},
+		RootCAs:      pool,
+	}
+}
+
+func (s *Server) newCert(tmpl *x509.Certificate, parent *tls.Certificate) tls.Certificate {
+	s.t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		s.t.Fatalf("netconftest: failed to generate key: %v", err)
+	}
+
+	tmpl.SerialNumber = big.NewInt(time.Now().UnixNano())
+	tmpl.NotBefore = time.Now().Add(-time.Hour)
+	tmpl.NotAfter = time.Now().Add(24 * time.Hour)
+
+	parentCert, parentKey := tmpl, any(key)
+	if parent != nil {
+		parentCert, parentKey = parent.Leaf, parent.PrivateKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parentCert, &key.PublicKey, parentKey)
+	if err != nil {
+		s.t.Fatalf("netconftest: failed to create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		s.t.Fatalf("netconftest: failed to create certificate: %v", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: cert}
+}
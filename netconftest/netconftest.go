@@ -0,0 +1,303 @@
+// Package netconftest implements a scriptable NETCONF device for integration
+// tests of code built on netconf sessions.
+//
+// Unlike [transporttest.Transport] the [Server] speaks the real protocol
+// including framing over in-memory pipes, SSH and TLS so the full client
+// stack is exercised.  Replies are canned fixtures that can be loaded from a
+// file and every rpc received is recorded for assertions.
+//
+//	srv := netconftest.NewServer(t, netconftest.WithFixtures(&netconftest.Fixtures{
+//		RPCs: []netconftest.Fixture{
+//			{Match: "<get-config>", Reply: "<data><system/></data>"},
+//		},
+//	}))
+//	sess, err := netconf.Open(srv.Pipe())
+package netconftest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"text/template"
+
+	"github.com/nemith/netconf"
+	"github.com/nemith/netconf/server"
+	"github.com/nemith/netconf/transport"
+	"github.com/nemith/netconf/transport/transporttest"
+)
+
+// Fixture is a canned reply to rpcs matching it.
+type Fixture struct {
+	// Match is a string the raw xml of the operation must contain (i.e
+	// `<get-config>` or `<name>eth0</name>`).  An empty Match matches every
+	// rpc.
+	Match string `json:"match"`
+
+	// Reply is the contents of the `<rpc-reply>` as a text/template with
+	// [ReplyData] as the data (i.e `<data><session>{{.SessionID}}</session></data>`).
+	// An empty reply is sent as `<ok/>`.
+	Reply string `json:"reply,omitempty"`
+
+	// Error is sent as a `<rpc-error>` instead of the Reply if set.  The
+	// error-type and error-severity default to application and error.
+	Error *netconf.RPCError `json:"error,omitempty"`
+
+	// Once removes the fixture after it has been used so that a later fixture
+	// for the same rpc is used for the next one.
+	Once bool `json:"once,omitempty"`
+
+	reply *template.Template
+}
+
+// ReplyData is the data available to the Reply template of a fixture.
+type ReplyData struct {
+	MessageID string
+	SessionID uint64
+	Username  string
+
+	// Operation is the local name of the operation element.
+	Operation string
+
+	// Request is the raw xml of the operation element.
+	Request string
+}
+
+// Fixtures is a set of canned capabilities and replies for a device.
+type Fixtures struct {
+	// Capabilities are sent in the hello.  The base 1.0 and 1.1
+	// capabilities are added unless a base capability is listed.
+	Capabilities []string `json:"capabilities,omitempty"`
+
+	// Modules are advertised in the hello after the capabilities.
+	Modules []netconf.YANGModule `json:"modules,omitempty"`
+
+	// RPCs are the replies.  The first fixture matching a rpc is used.
+	RPCs []Fixture `json:"rpcs"`
+}
+
+// LoadFixtures reads fixtures from a JSON file:
+//
+//	{
+//	  "capabilities": ["urn:ietf:params:netconf:capability:candidate:1.0"],
+//	  "modules": [
+//	    {"namespace": "urn:example:system", "name": "example-system", "revision": "2024-01-01"}
+//	  ],
+//	  "rpcs": [
+//	    {"match": "<get-config>", "reply": "<data><system/></data>"},
+//	    {"match": "<lock>", "error": {"tag": "lock-denied", "message": "locked"}}
+//	  ]
+//	}
+func LoadFixtures(path string) (*Fixtures, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f Fixtures
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse fixtures %q: %w", path, err)
+	}
+	return &f, nil
+}
+
+// RPC is a rpc received by the server.
+type RPC struct {
+	SessionID uint64
+	MessageID string
+	Operation xml.Name
+
+	// Body is the raw xml of the operation element.
+	Body string
+}
+
+// Option is an optional argument to [NewServer].
+type Option interface {
+	apply(*Server)
+}
+
+type capabilitiesOpt []string
+
+func (o capabilitiesOpt) apply(s *Server) { s.srv.Capabilities = append(s.srv.Capabilities, o...) }
+
+// WithCapabilities adds capabilities to the server hello (see
+// [netconf.CapabilityBuilder]).
+func WithCapabilities(caps ...string) Option { return capabilitiesOpt(caps) }
+
+type fixturesOpt struct{ f *Fixtures }
+
+func (o fixturesOpt) apply(s *Server) {
+	caps := netconf.NewCapabilityBuilder().
+		Add(o.f.Capabilities...).
+		Modules(o.f.Modules...).
+		Build()
+	s.srv.Capabilities = append(s.srv.Capabilities, caps...)
+	for _, f := range o.f.RPCs {
+		s.AddFixture(f)
+	}
+}
+
+// WithFixtures adds the capabilities and replies from f.
+func WithFixtures(f *Fixtures) Option { return fixturesOpt{f} }
+
+type strictOpt struct{}
+
+func (strictOpt) apply(s *Server) { s.strict = true }
+
+// WithStrict fails the test when a rpc doesn't match any fixture.  Without it
+// the rpc is answered with an operation-not-supported error like a device
+// would.
+func WithStrict() Option { return strictOpt{} }
+
+// Server is a mock NETCONF device.  It is closed when the test ends.
+type Server struct {
+	t      testing.TB
+	srv    *server.Server
+	strict bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu       sync.Mutex
+	fixtures []*Fixture
+	received []RPC
+}
+
+// NewServer returns a new mock device.
+func NewServer(t testing.TB, opts ...Option) *Server {
+	s := &Server{t: t}
+	s.srv = &server.Server{
+		Handler:      server.HandlerFunc(s.serveRPC),
+		ErrorHandler: func(err error) { t.Logf("netconftest: %v", err) },
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	for _, opt := range opts {
+		opt.apply(s)
+	}
+	t.Cleanup(s.Close)
+	return s
+}
+
+// AddFixture adds a reply after the existing ones.  The test fails if the
+// reply template is invalid.
+func (s *Server) AddFixture(f Fixture) {
+	s.t.Helper()
+	tmpl, err := template.New(f.Match).Parse(f.Reply)
+	if err != nil {
+		s.t.Fatalf("netconftest: invalid reply for %q: %v", f.Match, err)
+	}
+	f.reply = tmpl
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fixtures = append(s.fixtures, &f)
+}
+
+// Reply adds a fixture answering rpcs containing match with reply.
+func (s *Server) Reply(match, reply string) {
+	s.t.Helper()
+	s.AddFixture(Fixture{Match: match, Reply: reply})
+}
+
+// Received returns the rpcs received so far on all sessions in order.
+// `<close-session>` and `<kill-session>` are handled by the server and not
+// recorded.
+func (s *Server) Received() []RPC {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]RPC(nil), s.received...)
+}
+
+// Serve serves a session over tr in the background until it ends.
+func (s *Server) Serve(tr transport.Transport, peer server.Peer) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		if err := s.srv.ServeTransport(s.ctx, tr, peer); err != nil && s.ctx.Err() == nil {
+			s.t.Logf("netconftest: session ended: %v", err)
+		}
+	}()
+}
+
+// Pipe returns the client end of an in-memory connection to a new session.
+func (s *Server) Pipe() transport.Transport {
+	client, srv := transporttest.Pipe()
+	s.Serve(srv, server.Peer{})
+	return client
+}
+
+// Close ends all sessions and stops the listeners.
+func (s *Server) Close() {
+	s.cancel()
+	s.wg.Wait()
+}
+
+func (s *Server) match(body []byte) *Fixture {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, f := range s.fixtures {
+		if !bytes.Contains(body, []byte(f.Match)) {
+			continue
+		}
+		if f.Once {
+			s.fixtures = append(s.fixtures[:i:i], s.fixtures[i+1:]...)
+		}
+		return f
+	}
+	return nil
+}
+
+func (s *Server) serveRPC(ctx context.Context, req *server.Request) (any, error) {
+	s.mu.Lock()
+	s.received = append(s.received, RPC{
+		SessionID: req.Session.ID,
+		MessageID: req.MessageID,
+		Operation: req.Operation,
+		Body:      string(req.Body),
+	})
+	s.mu.Unlock()
+
+	f := s.match(req.Body)
+	if f == nil {
+		if s.strict {
+			s.t.Errorf("netconftest: unexpected rpc: %s", req.Body)
+		}
+		return nil, netconf.RPCError{
+			Type:     netconf.ErrTypeProtocol,
+			Tag:      netconf.ErrOperationNotSupported,
+			Severity: netconf.SevError,
+			Message:  "no fixture for " + req.Operation.Local,
+		}
+	}
+
+	if f.Error != nil {
+		rpcErr := *f.Error
+		if rpcErr.Type == "" {
+			rpcErr.Type = netconf.ErrTypeApp
+		}
+		if rpcErr.Severity == "" {
+			rpcErr.Severity = netconf.SevError
+		}
+		return nil, rpcErr
+	}
+
+	if strings.TrimSpace(f.Reply) == "" {
+		return nil, nil
+	}
+	var reply bytes.Buffer
+	if err := f.reply.Execute(&reply, ReplyData{
+		MessageID: req.MessageID,
+		SessionID: req.Session.ID,
+		Username:  req.Session.Username,
+		Operation: req.Operation.Local,
+		Request:   string(req.Body),
+	}); err != nil {
+		return nil, fmt.Errorf("netconftest: reply template: %w", err)
+	}
+	return reply.Bytes(), nil
+}
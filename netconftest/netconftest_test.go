@@ -0,0 +1,129 @@
+package netconftest
+
+import (
+	"context"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nemith/netconf"
+	ncssh "github.com/nemith/netconf/transport/ssh"
+	nctls "github.com/nemith/netconf/transport/tls"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type getConfig struct {
+	XMLName xml.Name `xml:"urn:ietf:params:xml:ns:netconf:base:1.0 get-config"`
+	Source  string   `xml:"source>running"`
+}
+
+func TestServerPipe(t *testing.T) {
+	srv := NewServer(t, WithCapabilities("urn:example:capability"))
+	srv.Reply("<get-config", `<data><id>{{.MessageID}}</id><session>{{.SessionID}}</session></data>`)
+
+	ctx := context.Background()
+	sess, err := netconf.Open(srv.Pipe())
+	require.NoError(t, err)
+	assert.Contains(t, sess.ServerCapabilities(), "urn:example:capability")
+
+	reply, err := sess.Do(ctx, &getConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, `<data><id>1</id><session>1</session></data>`, string(reply.Body))
+
+	// unmatched rpcs fail like on a device.
+	reply, err = sess.Do(ctx, &struct {
+		XMLName xml.Name `xml:"urn:example reboot"`
+	}{})
+	require.NoError(t, err)
+	err = reply.Err()
+	var rpcErr netconf.RPCError
+	require.ErrorAs(t, err, &rpcErr)
+	assert.Equal(t, netconf.ErrOperationNotSupported, rpcErr.Tag)
+
+	require.NoError(t, sess.Close(ctx))
+
+	rpcs := srv.Received()
+	require.Len(t, rpcs, 2)
+	assert.Equal(t, "get-config", rpcs[0].Operation.Local)
+	assert.Equal(t, "1", rpcs[0].MessageID)
+	assert.Contains(t, rpcs[0].Body, "<running>")
+	assert.Equal(t, xml.Name{Space: "urn:example", Local: "reboot"}, rpcs[1].Operation)
+}
+
+func TestServerFixtures(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fixtures.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+		"capabilities": ["urn:ietf:params:netconf:capability:candidate:1.0"],
+		"modules": [{"namespace": "urn:example:system", "name": "example-system", "revision": "2024-01-01"}],
+		"rpcs": [
+			{"match": "<lock", "error": {"tag": "lock-denied", "message": "locked"}, "once": true},
+			{"match": "<lock"}
+		]
+	}`), 0o600))
+
+	fixtures, err := LoadFixtures(path)
+	require.NoError(t, err)
+	srv := NewServer(t, WithFixtures(fixtures), WithStrict())
+
+	ctx := context.Background()
+	sess, err := netconf.Open(srv.Pipe())
+	require.NoError(t, err)
+	assert.Contains(t, sess.ServerCapabilities(), "urn:ietf:params:netconf:capability:candidate:1.0")
+	assert.Contains(t, sess.ServerCapabilities(), "urn:example:system?module=example-system&revision=2024-01-01")
+
+	err = sess.Lock(ctx, netconf.Candidate)
+	var rpcErr netconf.RPCError
+	require.ErrorAs(t, err, &rpcErr)
+	assert.Equal(t, netconf.RPCError{
+		Type:     netconf.ErrTypeApp,
+		Tag:      netconf.ErrLockDenied,
+		Severity: netconf.SevError,
+		Message:  "locked",
+	}, rpcErr)
+
+	assert.NoError(t, sess.Lock(ctx, netconf.Candidate))
+	assert.Len(t, srv.Received(), 2)
+}
+
+func TestLoadFixturesInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fixtures.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"rpcs": [`), 0o600))
+	_, err := LoadFixtures(path)
+	assert.Error(t, err)
+}
+
+func TestServerSSH(t *testing.T) {
+	srv := NewServer(t)
+	srv.Reply("", `<data><user>{{.Username}}</user></data>`)
+	addr, config := srv.ListenSSH()
+
+	ctx := context.Background()
+	tr, err := ncssh.Dial(ctx, "tcp", addr, config)
+	require.NoError(t, err)
+	sess, err := netconf.Open(tr)
+	require.NoError(t, err)
+
+	reply, err := sess.Do(ctx, &getConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, `<data><user>netconf</user></data>`, string(reply.Body))
+	assert.NoError(t, sess.Close(ctx))
+}
+
+func TestServerTLS(t *testing.T) {
+	srv := NewServer(t)
+	srv.Reply("", `<data><user>{{.Username}}</user></data>`)
+	addr, config := srv.ListenTLS()
+
+	ctx := context.Background()
+	tr, err := nctls.Dial(ctx, "tcp", addr, config)
+	require.NoError(t, err)
+	sess, err := netconf.Open(tr)
+	require.NoError(t, err)
+
+	reply, err := sess.Do(ctx, &getConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, `<data><user>netconf</user></data>`, string(reply.Body))
+	assert.NoError(t, sess.Close(ctx))
+}
@@ -0,0 +1,71 @@
+package netconftest
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/nemith/netconf/xmltree"
+)
+
+// XML is raw XML as a string, byte slice or [netconf.RawXML].
+type XML interface{ ~string | ~[]byte }
+
+// AssertXMLEqual checks that two XML documents or fragments are semantically
+// equal (see [xmltree.Equal]), ignoring namespace prefixes, attribute order,
+// whitespace around text and the form of empty elements.  On failure the
+// test is marked as failed with both documents in canonical form and false
+// is returned.
+func AssertXMLEqual[E, A XML](t testing.TB, expected E, actual A) bool {
+	t.Helper()
+
+	want, err := xmltree.Parse([]byte(expected))
+	if err != nil {
+		t.Errorf("netconftest: invalid expected xml: %v", err)
+		return false
+	}
+	got, err := xmltree.Parse([]byte(actual))
+	if err != nil {
+		t.Errorf("netconftest: invalid actual xml: %v", err)
+		return false
+	}
+	if xmltree.Equal(want, got) {
+		return true
+	}
+
+	wantStr, gotStr := string(xmltree.Canonical(want)), string(xmltree.Canonical(got))
+	t.Errorf("netconftest: xml not equal:\nexpected:\n%s\nactual:\n%s\n%s", indent(wantStr), indent(gotStr), firstDiff(wantStr, gotStr))
+	return false
+}
+
+// RequireXMLEqual is like [AssertXMLEqual] but stops the test on failure.
+func RequireXMLEqual[E, A XML](t testing.TB, expected E, actual A) {
+	t.Helper()
+	if !AssertXMLEqual(t, expected, actual) {
+		t.FailNow()
+	}
+}
+
+func indent(s string) string {
+	return "\t" + strings.ReplaceAll(strings.TrimSuffix(s, "\n"), "\n", "\n\t")
+}
+
+// firstDiff describes the first line that differs between canonical
+// documents.
+func firstDiff(want, got string) string {
+	wantLines := strings.Split(want, "\n")
+	gotLines := strings.Split(got, "\n")
+	for i := 0; i < len(wantLines) || i < len(gotLines); i++ {
+		var w, g string
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if w != g {
+			return "first difference at line " + strconv.Itoa(i+1) + ":\n-" + w + "\n+" + g
+		}
+	}
+	return ""
+}
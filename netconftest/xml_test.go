@@ -0,0 +1,47 @@
+package netconftest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/nemith/netconf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recorder captures failures of the assertions under test.
+type recorder struct {
+	testing.TB
+	errs []string
+}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.errs = append(r.errs, fmt.Sprintf(format, args...))
+}
+
+func TestAssertXMLEqual(t *testing.T) {
+	rec := &recorder{TB: t}
+	ok := AssertXMLEqual(rec,
+		`<system xmlns="urn:sys"><hostname>r1</hostname><ntp/></system>`,
+		[]byte("<s:system xmlns:s=\"urn:sys\">\n  <s:hostname> r1 </s:hostname>\n  <s:ntp></s:ntp>\n</s:system>"))
+	assert.True(t, ok)
+	assert.Empty(t, rec.errs)
+
+	ok = AssertXMLEqual(rec,
+		`<system xmlns="urn:sys"><hostname>r1</hostname></system>`,
+		netconf.RawXML(`<system xmlns="urn:sys"><hostname>r2</hostname></system>`))
+	assert.False(t, ok)
+	require.Len(t, rec.errs, 1)
+	assert.Contains(t, rec.errs[0], "netconftest: xml not equal")
+	assert.Contains(t, rec.errs[0], "first difference at line 2:\n-  <hostname>r1</hostname>\n+  <hostname>r2</hostname>")
+
+	rec.errs = nil
+	ok = AssertXMLEqual(rec, "<system>", "<system/>")
+	assert.False(t, ok)
+	require.Len(t, rec.errs, 1)
+	assert.Contains(t, rec.errs[0], "invalid expected xml")
+}
+
+func TestRequireXMLEqual(t *testing.T) {
+	RequireXMLEqual(t, `<a b="1" c="2"/>`, `<a c="2" b="1"></a>`)
+}
@@ -0,0 +1,58 @@
+package netconf
+
+import "encoding/xml"
+
+// NotificationFilter reports whether a notification should be delivered to
+// the handler it was given with to [WithNotificationHandler].  It is called
+// from the receive loop with only the notification envelope decoded so it
+// should be cheap; use [Notification.Raw] to match on the contents of the
+// event (i.e with an XPath library).
+//
+// Filtering on the client is useful for subscriptions to busy streams where
+// the server can't filter (or filter precisely enough) for the events the
+// application wants.  Filtered notifications are counted by
+// [Session.FilteredNotifications].  Lifecycle events such as
+// [EventCapabilityChange] are published regardless of the filters.
+type NotificationFilter func(n Notification) bool
+
+// FilteredNotifications returns the number of notifications discarded by the
+// filters of the notification handler.
+func (s *Session) FilteredNotifications() uint64 {
+	return s.filteredNotifs.Load()
+}
+
+// acceptNotification runs the notification filters.  A filter that panics
+// doesn't discard the notification.
+func (s *Session) acceptNotification(n Notification) bool {
+	for _, f := range s.notificationFilters {
+		ok := true
+		if err := s.safeCall("notification filter", func() { ok = f(n) }); err != nil {
+			continue
+		}
+		if !ok {
+			s.filteredNotifs.Add(1)
+			return false
+		}
+	}
+	return true
+}
+
+// MatchEvents returns a NotificationFilter matching notifications whose event
+// element has one of the given names.  Like [NotificationMux.Handle] a name
+// with an empty Space matches the local name in any namespace.
+func MatchEvents(names ...xml.Name) NotificationFilter {
+	return func(n Notification) bool {
+		name := n.EventName()
+		for _, want := range names {
+			if want.Local == name.Local && (want.Space == "" || want.Space == name.Space) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// NotFilter returns a NotificationFilter matching the notifications f doesn't.
+func NotFilter(f NotificationFilter) NotificationFilter {
+	return func(n Notification) bool { return !f(n) }
+}
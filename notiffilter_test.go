@@ -0,0 +1,85 @@
+package netconf
+
+import (
+	"encoding/xml"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotificationFilter(t *testing.T) {
+	notif := func(event string) string {
+		return fmt.Sprintf(`<notification xmlns="urn:ietf:params:xml:ns:netconf:notification:1.0"><eventTime>2024-01-01T00:00:00Z</eventTime>%s</notification>`, event)
+	}
+
+	var got []string
+	tr := newTestServer(t).transport()
+	sess := newSession(tr,
+		WithNotificationHandler(func(n Notification) { got = append(got, n.EventName().Local) },
+			MatchEvents(
+				xml.Name{Space: "urn:example", Local: "link-down"},
+				xml.Name{Local: "link-up"},
+			),
+			NotFilter(func(n Notification) bool {
+				var ev struct {
+					Name string `xml:"name"`
+				}
+				return n.Decode(&ev) == nil && ev.Name == "mgmt0"
+			})),
+	)
+
+	for _, event := range []string{
+		`<link-down xmlns="urn:example"><name>eth0</name></link-down>`,
+		`<link-down xmlns="urn:other"><name>eth0</name></link-down>`,
+		`<link-up xmlns="urn:other"><name>eth0</name></link-up>`,
+		`<link-up xmlns="urn:example"><name>mgmt0</name></link-up>`,
+		`<config-change xmlns="urn:example"/>`,
+	} {
+		tr.pushMsg(notif(event))
+		require.NoError(t, sess.recvMsg())
+	}
+
+	assert.Equal(t, []string{"link-down", "link-up"}, got)
+	assert.EqualValues(t, 3, sess.FilteredNotifications())
+}
+
+func TestNotificationFilterPanic(t *testing.T) {
+	var (
+		got  int
+		errs []error
+	)
+	tr := newTestServer(t).transport()
+	sess := newSession(tr,
+		WithNotificationHandler(func(Notification) { got++ },
+			func(Notification) bool { panic("boom") }),
+		WithErrorHandler(func(err error) { errs = append(errs, err) }),
+	)
+
+	tr.pushMsg(numberedNotification(1))
+	require.NoError(t, sess.recvMsg())
+
+	assert.Equal(t, 1, got)
+	assert.Zero(t, sess.FilteredNotifications())
+	require.Len(t, errs, 1)
+	var perr *PanicError
+	assert.ErrorAs(t, errs[0], &perr)
+}
+
+func TestNotificationFilterReplaced(t *testing.T) {
+	var first, second int
+	tr := newTestServer(t).transport()
+	sess := newSession(tr,
+		WithNotificationHandler(func(Notification) { first++ }, func(Notification) bool { return false }),
+		// the filters belong to the handler they were given with.
+		WithNotificationHandler(func(Notification) { second++ }),
+	)
+
+	tr.pushMsg(numberedNotification(1))
+	require.NoError(t, sess.recvMsg())
+
+	assert.Zero(t, first)
+	assert.Equal(t, 1, second)
+	assert.Zero(t, sess.FilteredNotifications())
+}
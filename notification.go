@@ -0,0 +1,226 @@
+package netconf
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// dispatchNotification delivers a notification to the notification handler
+// or holds on to it if any in-flight rpc has asked for notifications to be
+// held (see [WithHeldNotifications]).  No lock is held while the handler runs
+// so it can issue rpcs itself.
+func (s *Session) dispatchNotification(notif Notification) {
+	s.mu.Lock()
+	s.pendingNotifs = append(s.pendingNotifs, notif)
+	s.mu.Unlock()
+
+	s.deliverPending()
+}
+
+// deliverPending delivers the pending notifications unless they are held or
+// another goroutine is already delivering them (in which case it will deliver
+// them as well).
+func (s *Session) deliverPending() {
+	s.mu.Lock()
+	if s.delivering {
+		s.mu.Unlock()
+		return
+	}
+	s.delivering = true
+
+	for s.notifHolds == 0 && len(s.pendingNotifs) > 0 {
+		notif := s.pendingNotifs[0]
+		s.pendingNotifs[0] = Notification{}
+		s.pendingNotifs = s.pendingNotifs[1:]
+		s.mu.Unlock()
+
+		s.callNotificationHandler(notif)
+
+		s.mu.Lock()
+	}
+	if len(s.pendingNotifs) == 0 {
+		s.pendingNotifs = nil
+	}
+	s.delivering = false
+	s.mu.Unlock()
+}
+
+func (s *Session) callNotificationHandler(notif Notification) {
+	_ = s.safeCall("notification handler", func() { s.notificationHandler(notif) })
+}
+
+// holdNotifications starts buffering notifications until the returned
+// function is called.  Holds can be nested and notifications are released in
+// the order they were received once the last hold is released.
+func (s *Session) holdNotifications() (release func()) {
+	s.mu.Lock()
+	s.notifHolds++
+	s.mu.Unlock()
+
+	return func() {
+		s.mu.Lock()
+		s.notifHolds--
+		s.mu.Unlock()
+
+		s.deliverPending()
+	}
+}
+
+// ErrInterleaveUnsupported is returned when issuing a rpc on a session with
+// an active subscription to a server that doesn't support the `:interleave`
+// capability.  Such servers will drop or reject the request.
+var ErrInterleaveUnsupported = errors.New("netconf: server does not support interleaving rpcs with notifications")
+
+// InterleavePolicy controls what happens when a rpc is issued on a session
+// with an active subscription to a server without the `:interleave`
+// capability.
+type InterleavePolicy int
+
+const (
+	// InterleaveRefuse fails the rpc with [ErrInterleaveUnsupported] without
+	// sending it.  This is the default.
+	InterleaveRefuse InterleavePolicy = iota
+
+	// InterleaveWarn reports [ErrInterleaveUnsupported] to the session's
+	// [ErrorHandler] and sends the rpc anyway.
+	InterleaveWarn
+
+	// InterleaveIgnore sends the rpc without any checks.
+	InterleaveIgnore
+)
+
+type interleavePolicyOpt InterleavePolicy
+
+func (o interleavePolicyOpt) apply(cfg *sessionConfig) { cfg.interleavePolicy = InterleavePolicy(o) }
+
+// WithInterleavePolicy sets what happens when issuing rpcs after
+// `<create-subscription>` on a server that doesn't advertise the
+// `:interleave` capability.
+func WithInterleavePolicy(p InterleavePolicy) SessionOption { return interleavePolicyOpt(p) }
+
+// SupportsInterleave reports if the server advertised the `:interleave`
+// capability defined in RFC5277 allowing rpcs to be sent on a session with an
+// active subscription.
+func (s *Session) SupportsInterleave() bool {
+	return s.serverCaps.Has(":interleave:1.0")
+}
+
+// Subscribed reports if a notification subscription has been successfully
+// created on this session.
+func (s *Session) Subscribed() bool { return s.subscribed.Load() }
+
+// checkInterleave applies the interleave policy for an operation about to be
+// sent.  `<close-session>` is always allowed.
+func (s *Session) checkInterleave(op string) error {
+	if !s.subscribed.Load() || op == "close-session" || s.SupportsInterleave() {
+		return nil
+	}
+
+	switch s.interleavePolicy {
+	case InterleaveRefuse:
+		return fmt.Errorf("%w: cannot send %s", ErrInterleaveUnsupported, op)
+	case InterleaveWarn:
+		s.handleError(fmt.Errorf("%w: sending %s anyway", ErrInterleaveUnsupported, op))
+	}
+	return nil
+}
+
+// NotificationMux dispatches notifications to handlers registered for the
+// name of the event element.  This avoids having to switch on the raw xml of
+// every notification in a single handler.
+//
+//	mux := netconf.NewNotificationMux()
+//	name := xml.Name{
+//		Space: "urn:ietf:params:xml:ns:yang:ietf-netconf-notifications",
+//		Local: "netconf-config-change",
+//	}
+//	netconf.HandleEvent(mux, name, func(n netconf.Notification, ev ConfigChange) {
+//		// ...
+//	})
+//	session, err := netconf.Open(tr, netconf.WithNotificationHandler(mux.HandleNotification))
+type NotificationMux struct {
+	// ErrorHandler is called when a notification event cannot be decoded
+	// for a typed handler registered with [HandleEvent].  If nil errors are
+	// logged.
+	ErrorHandler ErrorHandler
+
+	mu       sync.RWMutex
+	handlers map[xml.Name]NotificationHandler
+	fallback NotificationHandler
+}
+
+// NewNotificationMux returns a new empty NotificationMux.
+func NewNotificationMux() *NotificationMux {
+	return &NotificationMux{
+		handlers: make(map[xml.Name]NotificationHandler),
+	}
+}
+
+// Handle registers a handler for notifications whose event element has the
+// given name.  If name.Space is empty the handler matches events with the
+// local name in any namespace.  Registering a handler for a name that
+// already has a handler replaces it.
+func (m *NotificationMux) Handle(name xml.Name, h NotificationHandler) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.handlers == nil {
+		m.handlers = make(map[xml.Name]NotificationHandler)
+	}
+	m.handlers[name] = h
+}
+
+// HandleDefault registers a handler that is called for notifications that
+// don't match any other handler.
+func (m *NotificationMux) HandleDefault(h NotificationHandler) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.fallback = h
+}
+
+// Handler returns the handler for the given event name or nil if there is no
+// handler (including a default handler).
+func (m *NotificationMux) Handler(name xml.Name) NotificationHandler {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if h, ok := m.handlers[name]; ok {
+		return h
+	}
+	if h, ok := m.handlers[xml.Name{Local: name.Local}]; ok {
+		return h
+	}
+	return m.fallback
+}
+
+// HandleNotification dispatches the notification to the registered handler.
+// This is a [NotificationHandler] and can be passed to
+// [WithNotificationHandler].
+func (m *NotificationMux) HandleNotification(n Notification) {
+	if h := m.Handler(n.EventName()); h != nil {
+		h(n)
+	}
+}
+
+func (m *NotificationMux) handleError(err error) {
+	if m.ErrorHandler != nil {
+		m.ErrorHandler(err)
+		return
+	}
+	defaultErrorHandler(err)
+}
+
+// HandleEvent registers a typed handler on the mux.  The event element of
+// matching notifications is decoded into a new value of T before calling the
+// handler.  Decoding errors are sent to the mux's ErrorHandler.
+func HandleEvent[T any](m *NotificationMux, name xml.Name, h func(n Notification, event T)) {
+	m.Handle(name, func(n Notification) {
+		var event T
+		if err := n.Decode(&event); err != nil {
+			m.handleError(fmt.Errorf("failed to decode %s notification: %w", n.EventName().Local, err))
+			return
+		}
+		h(n, event)
+	})
+}
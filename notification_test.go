@@ -0,0 +1,198 @@
+package netconf
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeldNotifications(t *testing.T) {
+	var (
+		got     []Notification
+		holding bool
+	)
+	tr := newTestServer(t).transport()
+	var sess *Session
+	sess = newSession(tr,
+		WithNotificationHandler(func(n Notification) { got = append(got, n) }),
+		// Use an interceptor to inject notifications while the rpc is
+		// in-flight without needing a server.
+		WithInterceptor(func(ctx context.Context, info *RPCInfo, req any, next Invoker) (*Reply, error) {
+			for i := 0; i < 2; i++ {
+				tr.pushMsg(testNotification)
+				require.NoError(t, sess.recvMsg())
+			}
+			if holding {
+				assert.Empty(t, got)
+			}
+			return &Reply{}, nil
+		}),
+	)
+
+	holding = true
+	_, err := sess.Do(context.Background(), &CommitReq{}, WithHeldNotifications())
+	require.NoError(t, err)
+	assert.Len(t, got, 2)
+
+	// without the option notifications are delivered immediately
+	got, holding = nil, false
+	_, err = sess.Do(context.Background(), &CommitReq{})
+	require.NoError(t, err)
+	assert.Len(t, got, 2)
+}
+
+func TestNestedHeldNotifications(t *testing.T) {
+	var got int
+	tr := newTestServer(t).transport()
+	sess := newSession(tr, WithNotificationHandler(func(Notification) { got++ }))
+
+	release1 := sess.holdNotifications()
+	release2 := sess.holdNotifications()
+
+	tr.pushMsg(testNotification)
+	require.NoError(t, sess.recvMsg())
+
+	release1()
+	assert.Zero(t, got)
+
+	release2()
+	assert.Equal(t, 1, got)
+}
+
+func TestHeldNotificationsFromHandler(t *testing.T) {
+	var got int
+	tr := newTestServer(t).transport()
+	var sess *Session
+	sess = newSession(tr, WithNotificationHandler(func(Notification) {
+		// i.e a handler issuing a rpc with WithHeldNotifications.
+		release := sess.holdNotifications()
+		got++
+		release()
+	}))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		tr.pushMsg(testNotification)
+		assert.NoError(t, sess.recvMsg())
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("deadlock releasing notifications from the handler")
+	}
+	assert.Equal(t, 1, got)
+}
+
+func TestNotificationMux(t *testing.T) {
+	const (
+		changeNotif = `<notification xmlns="urn:ietf:params:xml:ns:netconf:notification:1.0"><eventTime>2023-06-07T18:31:48Z</eventTime><netconf-config-change xmlns="urn:ietf:params:xml:ns:yang:ietf-netconf-notifications"><datastore>running</datastore></netconf-config-change></notification>`
+		linkNotif   = `<notification xmlns="urn:ietf:params:xml:ns:netconf:notification:1.0"><eventTime>2023-06-07T18:31:48Z</eventTime><link-down xmlns="urn:example:vendor"><ifname>ge-0/0/0</ifname></link-down></notification>`
+		otherNotif  = `<notification xmlns="urn:ietf:params:xml:ns:netconf:notification:1.0"><eventTime>2023-06-07T18:31:48Z</eventTime><other xmlns="urn:example:vendor"/></notification>`
+	)
+
+	parse := func(raw string) Notification {
+		var n Notification
+		require.NoError(t, xml.Unmarshal([]byte(raw), &n))
+		return n
+	}
+
+	type configChange struct {
+		Datastore string `xml:"datastore"`
+	}
+
+	var (
+		changes  []configChange
+		links    []string
+		fallback []xml.Name
+	)
+
+	mux := NewNotificationMux()
+	HandleEvent(mux, xml.Name{Space: "urn:ietf:params:xml:ns:yang:ietf-netconf-notifications", Local: "netconf-config-change"},
+		func(n Notification, ev configChange) { changes = append(changes, ev) })
+	mux.Handle(xml.Name{Local: "link-down"}, func(n Notification) { links = append(links, string(n.Raw())) })
+	mux.HandleDefault(func(n Notification) { fallback = append(fallback, n.EventName()) })
+
+	mux.HandleNotification(parse(changeNotif))
+	mux.HandleNotification(parse(linkNotif))
+	mux.HandleNotification(parse(otherNotif))
+
+	assert.Equal(t, []configChange{{Datastore: "running"}}, changes)
+	assert.Len(t, links, 1)
+	assert.Equal(t, []xml.Name{{Space: "urn:example:vendor", Local: "other"}}, fallback)
+}
+
+func TestNotificationMuxDecodeError(t *testing.T) {
+	var errs []error
+	mux := &NotificationMux{ErrorHandler: func(err error) { errs = append(errs, err) }}
+	HandleEvent(mux, xml.Name{Local: "event"}, func(Notification, struct {
+		Count int `xml:"count"`
+	}) {
+		t.Error("handler should not be called")
+	})
+
+	var n Notification
+	require.NoError(t, xml.Unmarshal([]byte(`<notification xmlns="urn:ietf:params:xml:ns:netconf:notification:1.0"><eventTime>2023-06-07T18:31:48Z</eventTime><event><count>lots</count></event></notification>`), &n))
+	mux.HandleNotification(n)
+	assert.Len(t, errs, 1)
+}
+
+func TestInterleave(t *testing.T) {
+	const okReply = `<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="%d"><ok/></rpc-reply>`
+
+	tt := []struct {
+		name       string
+		caps       []string
+		policy     InterleavePolicy
+		wantErr    bool
+		wantWarned bool
+	}{
+		{"refuse", nil, InterleaveRefuse, true, false},
+		{"warn", nil, InterleaveWarn, false, true},
+		{"ignore", nil, InterleaveIgnore, false, false},
+		{"supported", []string{":interleave:1.0"}, InterleaveRefuse, false, false},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var warned bool
+			ts := newTestServer(t)
+			sess := newSession(ts.transport(),
+				WithInterleavePolicy(tc.policy),
+				WithErrorHandler(func(err error) {
+					assert.ErrorIs(t, err, ErrInterleaveUnsupported)
+					warned = true
+				}),
+			)
+			sess.serverCaps = NewCapabilitySet(tc.caps...)
+			go sess.recv()
+
+			// rpcs before the subscription are always allowed
+			ts.queueRespString(fmt.Sprintf(okReply, 1))
+			require.NoError(t, sess.Commit(context.Background()))
+			assert.False(t, sess.Subscribed())
+
+			ts.queueRespString(fmt.Sprintf(okReply, 2))
+			require.NoError(t, sess.CreateSubscription(context.Background()))
+			assert.True(t, sess.Subscribed())
+			assert.Equal(t, len(tc.caps) > 0, sess.SupportsInterleave())
+
+			if !tc.wantErr {
+				ts.queueRespString(fmt.Sprintf(okReply, 3))
+			}
+			err := sess.Commit(context.Background())
+			if tc.wantErr {
+				assert.ErrorIs(t, err, ErrInterleaveUnsupported)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.wantWarned, warned)
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package notifications
+
+import (
+	"encoding/xml"
+
+	"github.com/nemith/netconf"
+)
+
+// BaseNamespace is the namespace of the ietf-netconf-notifications module
+// defining the NETCONF base notifications (RFC6470).
+const BaseNamespace = "urn:ietf:params:xml:ns:yang:ietf-netconf-notifications"
+
+var (
+	// ConfigChangeName is the name of the [ConfigChange] event.
+	ConfigChangeName = xml.Name{Space: BaseNamespace, Local: "netconf-config-change"}
+
+	// CapabilityChangeName is the name of the [CapabilityChange] event.
+	CapabilityChangeName = xml.Name{Space: BaseNamespace, Local: "netconf-capability-change"}
+
+	// SessionStartName is the name of the [SessionStart] event.
+	SessionStartName = xml.Name{Space: BaseNamespace, Local: "netconf-session-start"}
+
+	// SessionEndName is the name of the [SessionEnd] event.
+	SessionEndName = xml.Name{Space: BaseNamespace, Local: "netconf-session-end"}
+
+	// ConfirmedCommitName is the name of the [ConfirmedCommit] event.
+	ConfirmedCommitName = xml.Name{Space: BaseNamespace, Local: "netconf-confirmed-commit"}
+)
+
+// SessionParams identify the NETCONF session that caused an event.
+type SessionParams struct {
+	Username   string `xml:"username"`
+	SessionID  uint64 `xml:"session-id"`
+	SourceHost string `xml:"source-host,omitempty"`
+}
+
+// ChangedBy is who caused a change.  Server is set when the server made the
+// change itself, otherwise the session is given.
+type ChangedBy struct {
+	Server netconf.ExtantBool `xml:"server"`
+	SessionParams
+}
+
+// Edit is a single change to a datastore in a [ConfigChange].
+type Edit struct {
+	// Target is the instance-identifier of the changed node.
+	Target string `xml:"target"`
+
+	// Operation is one of merge, replace, create, delete or remove.
+	Operation string `xml:"operation"`
+}
+
+// ConfigChange is a `<netconf-config-change>` event sent when the running or
+// startup datastore is changed.
+type ConfigChange struct {
+	XMLName   xml.Name  `xml:"urn:ietf:params:xml:ns:yang:ietf-netconf-notifications netconf-config-change"`
+	ChangedBy ChangedBy `xml:"changed-by"`
+
+	// Datastore is the changed datastore.  Servers may leave it out for the
+	// running datastore.
+	Datastore string `xml:"datastore,omitempty"`
+	Edits     []Edit `xml:"edit"`
+}
+
+// ConfigDatastore returns the changed datastore applying the default of
+// running.
+func (c ConfigChange) ConfigDatastore() string {
+	if c.Datastore == "" {
+		return "running"
+	}
+	return c.Datastore
+}
+
+// CapabilityChange is a `<netconf-capability-change>` event sent when the
+// capabilities of the server change.
+type CapabilityChange struct {
+	XMLName   xml.Name  `xml:"urn:ietf:params:xml:ns:yang:ietf-netconf-notifications netconf-capability-change"`
+	ChangedBy ChangedBy `xml:"changed-by"`
+	Added     []string  `xml:"added-capability"`
+	Deleted   []string  `xml:"deleted-capability"`
+	Modified  []string  `xml:"modified-capability"`
+}
+
+// SessionStart is a `<netconf-session-start>` event sent when a NETCONF
+// session is started.
+type SessionStart struct {
+	XMLName xml.Name `xml:"urn:ietf:params:xml:ns:yang:ietf-netconf-notifications netconf-session-start"`
+	SessionParams
+}
+
+// TerminationReason is why a NETCONF session ended.
+type TerminationReason string
+
+const (
+	TerminationClosed   TerminationReason = "closed"
+	TerminationKilled   TerminationReason = "killed"
+	TerminationDropped  TerminationReason = "dropped"
+	TerminationTimeout  TerminationReason = "timeout"
+	TerminationBadHello TerminationReason = "bad-hello"
+	TerminationOther    TerminationReason = "other"
+)
+
+// SessionEnd is a `<netconf-session-end>` event sent when a NETCONF session
+// is terminated.
+type SessionEnd struct {
+	XMLName xml.Name `xml:"urn:ietf:params:xml:ns:yang:ietf-netconf-notifications netconf-session-end"`
+	SessionParams
+
+	// KilledBy is the id of the session that issued `<kill-session>` when
+	// TerminationReason is killed.
+	KilledBy          uint64            `xml:"killed-by,omitempty"`
+	TerminationReason TerminationReason `xml:"termination-reason"`
+}
+
+// ConfirmEvent is the stage of a confirmed commit.
+type ConfirmEvent string
+
+const (
+	ConfirmStart    ConfirmEvent = "start"
+	ConfirmCancel   ConfirmEvent = "cancel"
+	ConfirmTimeout  ConfirmEvent = "timeout"
+	ConfirmExtend   ConfirmEvent = "extend"
+	ConfirmComplete ConfirmEvent = "complete"
+)
+
+// ConfirmedCommit is a `<netconf-confirmed-commit>` event sent when a
+// confirmed commit is started, extended, confirmed, canceled or times out.
+// The session is not set for timeouts.
+type ConfirmedCommit struct {
+	XMLName xml.Name `xml:"urn:ietf:params:xml:ns:yang:ietf-netconf-notifications netconf-confirmed-commit"`
+	SessionParams
+	ConfirmEvent ConfirmEvent `xml:"confirm-event"`
+
+	// Timeout is the confirm timeout in seconds for the start and extend
+	// events.
+	Timeout uint32 `xml:"timeout,omitempty"`
+}
+
+// BaseHandler receives the typed NETCONF base notifications.  Any of the
+// functions may be nil to ignore that event.
+type BaseHandler struct {
+	ConfigChange     func(n netconf.Notification, ev ConfigChange)
+	CapabilityChange func(n netconf.Notification, ev CapabilityChange)
+	SessionStart     func(n netconf.Notification, ev SessionStart)
+	SessionEnd       func(n netconf.Notification, ev SessionEnd)
+	ConfirmedCommit  func(n netconf.Notification, ev ConfirmedCommit)
+}
+
+// Register adds the non-nil handlers to mux.  Events that cannot be decoded
+// are sent to the mux's ErrorHandler.
+func (h BaseHandler) Register(mux *netconf.NotificationMux) {
+	if h.ConfigChange != nil {
+		netconf.HandleEvent(mux, ConfigChangeName, h.ConfigChange)
+	}
+	if h.CapabilityChange != nil {
+		netconf.HandleEvent(mux, CapabilityChangeName, h.CapabilityChange)
+	}
+	if h.SessionStart != nil {
+		netconf.HandleEvent(mux, SessionStartName, h.SessionStart)
+	}
+	if h.SessionEnd != nil {
+		netconf.HandleEvent(mux, SessionEndName, h.SessionEnd)
+	}
+	if h.ConfirmedCommit != nil {
+		netconf.HandleEvent(mux, ConfirmedCommitName, h.ConfirmedCommit)
+	}
+}
@@ -0,0 +1,110 @@
+package notifications
+
+import (
+	"testing"
+
+	"github.com/nemith/netconf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func baseNotification(event string) string {
+	return `<notification xmlns="urn:ietf:params:xml:ns:netconf:notification:1.0"><eventTime>2024-01-02T03:04:05Z</eventTime>` +
+		event + `</notification>`
+}
+
+func TestBaseHandler(t *testing.T) {
+	var (
+		configChanges []ConfigChange
+		capChanges    []CapabilityChange
+		starts        []SessionStart
+		ends          []SessionEnd
+		commits       []ConfirmedCommit
+	)
+	mux := netconf.NewNotificationMux()
+	BaseHandler{
+		ConfigChange:     func(_ netconf.Notification, ev ConfigChange) { configChanges = append(configChanges, ev) },
+		CapabilityChange: func(_ netconf.Notification, ev CapabilityChange) { capChanges = append(capChanges, ev) },
+		SessionStart:     func(_ netconf.Notification, ev SessionStart) { starts = append(starts, ev) },
+		SessionEnd:       func(_ netconf.Notification, ev SessionEnd) { ends = append(ends, ev) },
+		ConfirmedCommit:  func(_ netconf.Notification, ev ConfirmedCommit) { commits = append(commits, ev) },
+	}.Register(mux)
+
+	for _, event := range []string{
+		`<netconf-config-change xmlns="urn:ietf:params:xml:ns:yang:ietf-netconf-notifications">
+			<changed-by><username>admin</username><session-id>12</session-id><source-host>192.0.2.1</source-host></changed-by>
+			<edit><target xmlns:if="urn:ietf:params:xml:ns:yang:ietf-interfaces">/if:interfaces/if:interface[if:name='eth0']</target><operation>merge</operation></edit>
+			<edit><target>/system/hostname</target><operation>replace</operation></edit>
+		</netconf-config-change>`,
+		`<netconf-config-change xmlns="urn:ietf:params:xml:ns:yang:ietf-netconf-notifications">
+			<changed-by><server/></changed-by>
+			<datastore>startup</datastore>
+		</netconf-config-change>`,
+		`<netconf-capability-change xmlns="urn:ietf:params:xml:ns:yang:ietf-netconf-notifications">
+			<changed-by><server/></changed-by>
+			<added-capability>urn:ietf:params:netconf:capability:candidate:1.0</added-capability>
+			<deleted-capability>urn:ietf:params:netconf:capability:startup:1.0</deleted-capability>
+		</netconf-capability-change>`,
+		`<netconf-session-start xmlns="urn:ietf:params:xml:ns:yang:ietf-netconf-notifications">
+			<username>admin</username><session-id>12</session-id><source-host>192.0.2.1</source-host>
+		</netconf-session-start>`,
+		`<netconf-session-end xmlns="urn:ietf:params:xml:ns:yang:ietf-netconf-notifications">
+			<username>admin</username><session-id>12</session-id>
+			<killed-by>7</killed-by><termination-reason>killed</termination-reason>
+		</netconf-session-end>`,
+		`<netconf-confirmed-commit xmlns="urn:ietf:params:xml:ns:yang:ietf-netconf-notifications">
+			<username>admin</username><session-id>12</session-id>
+			<confirm-event>start</confirm-event><timeout>600</timeout>
+		</netconf-confirmed-commit>`,
+		`<netconf-confirmed-commit xmlns="urn:ietf:params:xml:ns:yang:ietf-netconf-notifications">
+			<confirm-event>timeout</confirm-event>
+		</netconf-confirmed-commit>`,
+	} {
+		mux.HandleNotification(parseNotification(t, baseNotification(event)))
+	}
+
+	require.Len(t, configChanges, 2)
+	user := SessionParams{Username: "admin", SessionID: 12, SourceHost: "192.0.2.1"}
+	assert.Equal(t, ChangedBy{SessionParams: user}, configChanges[0].ChangedBy)
+	assert.Equal(t, "running", configChanges[0].ConfigDatastore())
+	assert.Equal(t, []Edit{
+		{Target: "/if:interfaces/if:interface[if:name='eth0']", Operation: "merge"},
+		{Target: "/system/hostname", Operation: "replace"},
+	}, configChanges[0].Edits)
+	assert.True(t, bool(configChanges[1].ChangedBy.Server))
+	assert.Equal(t, "startup", configChanges[1].ConfigDatastore())
+	assert.Empty(t, configChanges[1].Edits)
+
+	require.Len(t, capChanges, 1)
+	assert.Equal(t, []string{"urn:ietf:params:netconf:capability:candidate:1.0"}, capChanges[0].Added)
+	assert.Equal(t, []string{"urn:ietf:params:netconf:capability:startup:1.0"}, capChanges[0].Deleted)
+	assert.Empty(t, capChanges[0].Modified)
+
+	require.Len(t, starts, 1)
+	assert.Equal(t, user, starts[0].SessionParams)
+
+	require.Len(t, ends, 1)
+	assert.Equal(t, uint64(12), ends[0].SessionID)
+	assert.Equal(t, uint64(7), ends[0].KilledBy)
+	assert.Equal(t, TerminationKilled, ends[0].TerminationReason)
+
+	require.Len(t, commits, 2)
+	assert.Equal(t, ConfirmStart, commits[0].ConfirmEvent)
+	assert.Equal(t, uint32(600), commits[0].Timeout)
+	assert.Equal(t, uint64(12), commits[0].SessionID)
+	assert.Equal(t, ConfirmTimeout, commits[1].ConfirmEvent)
+	assert.Zero(t, commits[1].SessionID)
+}
+
+func TestBaseHandlerPartial(t *testing.T) {
+	var fallback int
+	mux := netconf.NewNotificationMux()
+	mux.HandleDefault(func(netconf.Notification) { fallback++ })
+	BaseHandler{
+		SessionStart: func(netconf.Notification, SessionStart) {},
+	}.Register(mux)
+
+	mux.HandleNotification(parseNotification(t, baseNotification(
+		`<netconf-session-end xmlns="urn:ietf:params:xml:ns:yang:ietf-netconf-notifications"><session-id>1</session-id><termination-reason>closed</termination-reason></netconf-session-end>`)))
+	assert.Equal(t, 1, fallback)
+}
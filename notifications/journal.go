@@ -0,0 +1,345 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nemith/netconf"
+	"github.com/nemith/netconf/clock"
+)
+
+// JournalFormat is the format of the entries written to a [Journal].
+type JournalFormat int
+
+const (
+	// JournalJSON writes each entry as a JSON object on its own line.
+	JournalJSON JournalFormat = iota
+
+	// JournalXML writes each entry as an `<entry>` element on its own line
+	// wrapping the notification as received.
+	JournalXML
+)
+
+const (
+	// DefaultJournalMaxSize is the size a journal file grows to before it is
+	// rotated.
+	DefaultJournalMaxSize = 64 << 20
+
+	// DefaultJournalMaxBackups is the number of rotated journal files kept.
+	DefaultJournalMaxBackups = 5
+)
+
+// JournalOption is an optional argument to [OpenJournal].
+type JournalOption interface {
+	apply(*Journal)
+}
+
+type journalFormatOpt JournalFormat
+
+func (o journalFormatOpt) apply(j *Journal) { j.format = JournalFormat(o) }
+
+// WithJournalFormat sets the format of the journal.  The default is
+// [JournalJSON].
+func WithJournalFormat(f JournalFormat) JournalOption { return journalFormatOpt(f) }
+
+type journalRotateOpt struct {
+	maxSize    int64
+	maxBackups int
+}
+
+func (o journalRotateOpt) apply(j *Journal) {
+	j.maxSize = o.maxSize
+	j.maxBackups = o.maxBackups
+}
+
+// WithJournalRotation rotates the journal file once writing an entry would
+// grow it past maxSize bytes.  The current file is renamed with a `.1` suffix
+// (moving older files to `.2` and so on) and at most maxBackups rotated files
+// are kept.  A maxSize of 0 disables rotation.
+func WithJournalRotation(maxSize int64, maxBackups int) JournalOption {
+	return journalRotateOpt{maxSize: maxSize, maxBackups: maxBackups}
+}
+
+type journalClockOpt struct{ c clock.Clock }
+
+func (o journalClockOpt) apply(j *Journal) { j.clock = o.c }
+
+// WithJournalClock sets the clock used for the receive time of entries.
+func WithJournalClock(c clock.Clock) JournalOption { return journalClockOpt{c} }
+
+// Source identifies where journaled notifications came from.
+type Source struct {
+	// Device is a name for the device such as its hostname or address.
+	Device string
+
+	// SessionID is the NETCONF session id of the subscription.  It may be
+	// zero if it isn't known when the handler is created.
+	SessionID uint64
+}
+
+// JournalEntry is a journaled notification as written in the JSON format.
+type JournalEntry struct {
+	Received  time.Time `json:"received"`
+	Device    string    `json:"device,omitempty"`
+	SessionID uint64    `json:"sessionId,omitempty"`
+	EventTime time.Time `json:"eventTime"`
+	Event     string    `json:"event"`
+	Namespace string    `json:"namespace,omitempty"`
+
+	// Notification is the raw `<notification>` element.
+	Notification string `json:"notification"`
+}
+
+type journalXMLEntry struct {
+	XMLName      xml.Name `xml:"entry"`
+	Received     string   `xml:"received,attr"`
+	Device       string   `xml:"device,attr,omitempty"`
+	SessionID    uint64   `xml:"session-id,attr,omitempty"`
+	Notification string   `xml:",innerxml"`
+}
+
+// Journal appends notifications to a file for auditing and post-mortem
+// analysis.  A Journal is safe to share between many sessions.
+type Journal struct {
+	// ErrorHandler is called when an entry written by a handler or
+	// [Journal.Consume] fails.  If nil errors are logged.
+	ErrorHandler netconf.ErrorHandler
+
+	path       string
+	format     JournalFormat
+	maxSize    int64
+	maxBackups int
+	clock      clock.Clock
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+// OpenJournal opens (or creates) the journal file at path for appending.
+func OpenJournal(path string, opts ...JournalOption) (*Journal, error) {
+	j := &Journal{
+		path:       path,
+		maxSize:    DefaultJournalMaxSize,
+		maxBackups: DefaultJournalMaxBackups,
+	}
+	for _, opt := range opts {
+		opt.apply(j)
+	}
+	j.clock = clock.Or(j.clock)
+
+	if err := j.open(); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+func (j *Journal) open() error {
+	f, err := os.OpenFile(j.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open journal: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to open journal: %w", err)
+	}
+	j.f = f
+	j.size = info.Size()
+	return nil
+}
+
+// Record appends a notification to the journal.
+func (j *Journal) Record(src Source, n netconf.Notification) error {
+	line, err := j.encode(src, n)
+	if err != nil {
+		return fmt.Errorf("failed to encode journal entry: %w", err)
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.f == nil {
+		return os.ErrClosed
+	}
+	if j.maxSize > 0 && j.size > 0 && j.size+int64(len(line)) > j.maxSize {
+		if err := j.rotate(); err != nil {
+			return err
+		}
+	}
+	written, err := j.f.Write(line)
+	j.size += int64(written)
+	if err != nil {
+		return fmt.Errorf("failed to write journal entry: %w", err)
+	}
+	return nil
+}
+
+func (j *Journal) encode(src Source, n netconf.Notification) ([]byte, error) {
+	received := j.clock.Now()
+	raw := notificationXML(n)
+
+	var (
+		line []byte
+		err  error
+	)
+	switch j.format {
+	case JournalXML:
+		line, err = xml.Marshal(journalXMLEntry{
+			Received:     received.Format(time.RFC3339Nano),
+			Device:       src.Device,
+			SessionID:    src.SessionID,
+			Notification: raw,
+		})
+	default:
+		name := n.EventName()
+		line, err = json.Marshal(JournalEntry{
+			Received:     received,
+			Device:       src.Device,
+			SessionID:    src.SessionID,
+			EventTime:    n.EventTime,
+			Event:        name.Local,
+			Namespace:    name.Space,
+			Notification: raw,
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return append(line, '\n'), nil
+}
+
+const notifNamespace = "urn:ietf:params:xml:ns:netconf:notification:1.0"
+
+// notificationXML rebuilds the `<notification>` element with the namespaces
+// declared on the original so that prefixes used in the body still resolve.
+func notificationXML(n netconf.Notification) string {
+	var (
+		decls  strings.Builder
+		dflt   = notifNamespace
+		prefix string
+	)
+	for _, attr := range n.Namespaces() {
+		if attr.Name.Space == "" {
+			dflt = attr.Value
+			continue
+		}
+		decls.WriteString(" xmlns:" + attr.Name.Local + `="`)
+		_ = xml.EscapeText(&decls, []byte(attr.Value))
+		decls.WriteString(`"`)
+		if attr.Value == notifNamespace && prefix == "" {
+			prefix = attr.Name.Local
+		}
+	}
+
+	// the original used a prefix for the notification if the default
+	// namespace is something else.
+	root := "notification"
+	if dflt != notifNamespace && prefix != "" {
+		root = prefix + ":" + root
+	}
+
+	var b strings.Builder
+	b.WriteString("<" + root + ` xmlns="`)
+	_ = xml.EscapeText(&b, []byte(dflt))
+	b.WriteString(`"` + decls.String() + ">")
+	b.Write(n.Body)
+	b.WriteString("</" + root + ">")
+	return b.String()
+}
+
+// rotate moves the current file aside and starts a new one.  It is called
+// with mu held.
+func (j *Journal) rotate() error {
+	if err := j.f.Close(); err != nil {
+		return fmt.Errorf("failed to rotate journal: %w", err)
+	}
+	j.f = nil
+
+	if j.maxBackups <= 0 {
+		if err := os.Remove(j.path); err != nil {
+			return fmt.Errorf("failed to rotate journal: %w", err)
+		}
+		return j.open()
+	}
+
+	_ = os.Remove(j.backup(j.maxBackups))
+	for i := j.maxBackups - 1; i > 0; i-- {
+		if err := os.Rename(j.backup(i), j.backup(i+1)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to rotate journal: %w", err)
+		}
+	}
+	if err := os.Rename(j.path, j.backup(1)); err != nil {
+		return fmt.Errorf("failed to rotate journal: %w", err)
+	}
+	return j.open()
+}
+
+func (j *Journal) backup(n int) string { return fmt.Sprintf("%s.%d", j.path, n) }
+
+func (j *Journal) handleError(err error) {
+	if j.ErrorHandler != nil {
+		j.ErrorHandler(err)
+		return
+	}
+	log.Printf("netconf: %v", err)
+}
+
+// Handler returns a [netconf.NotificationHandler] recording every notification
+// from src.  Use it alone or from another handler (i.e a
+// [netconf.NotificationMux] default).
+func (j *Journal) Handler(src Source) netconf.NotificationHandler {
+	return func(n netconf.Notification) {
+		if err := j.Record(src, n); err != nil {
+			j.handleError(err)
+		}
+	}
+}
+
+// Consume records the notifications received on ch until it is closed or ctx
+// is done.
+func (j *Journal) Consume(ctx context.Context, src Source, ch <-chan netconf.Notification) error {
+	for {
+		select {
+		case n, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			if err := j.Record(src, n); err != nil {
+				j.handleError(err)
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Sync commits the journal file to stable storage.
+func (j *Journal) Sync() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.f == nil {
+		return os.ErrClosed
+	}
+	return j.f.Sync()
+}
+
+// Close closes the journal file.  Notifications recorded afterwards fail with
+// [os.ErrClosed].
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.f == nil {
+		return nil
+	}
+	err := j.f.Close()
+	j.f = nil
+	return err
+}
@@ -0,0 +1,172 @@
+package notifications
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nemith/netconf"
+	"github.com/nemith/netconf/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const linkDownNotif = `<notification xmlns="urn:ietf:params:xml:ns:netconf:notification:1.0"><eventTime>2024-01-02T03:04:05Z</eventTime><link-down xmlns="urn:example"><name>eth0</name></link-down></notification>`
+
+func readLines(t *testing.T, path string) []string {
+	t.Helper()
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var lines []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		lines = append(lines, sc.Text())
+	}
+	require.NoError(t, sc.Err())
+	return lines
+}
+
+func TestJournalJSON(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 6, 0, time.UTC)
+	path := filepath.Join(t.TempDir(), "notifs.jsonl")
+	j, err := OpenJournal(path, WithJournalClock(clock.NewFake(now)))
+	require.NoError(t, err)
+
+	h := j.Handler(Source{Device: "router1", SessionID: 42})
+	h(parseNotification(t, linkDownNotif))
+	require.NoError(t, j.Close())
+
+	lines := readLines(t, path)
+	require.Len(t, lines, 1)
+
+	var entry JournalEntry
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+	assert.Equal(t, JournalEntry{
+		Received:     now,
+		Device:       "router1",
+		SessionID:    42,
+		EventTime:    time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Event:        "link-down",
+		Namespace:    "urn:example",
+		Notification: linkDownNotif,
+	}, entry)
+
+	// the journaled notification can be parsed again.
+	n := parseNotification(t, entry.Notification)
+	assert.Equal(t, "link-down", n.EventName().Local)
+
+	// reopening appends.
+	j, err = OpenJournal(path)
+	require.NoError(t, err)
+	require.NoError(t, j.Record(Source{}, parseNotification(t, linkDownNotif)))
+	require.NoError(t, j.Close())
+	assert.Len(t, readLines(t, path), 2)
+
+	assert.ErrorIs(t, j.Record(Source{}, n), os.ErrClosed)
+}
+
+func TestJournalXML(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 6, 0, time.UTC)
+	path := filepath.Join(t.TempDir(), "notifs.xml")
+	j, err := OpenJournal(path, WithJournalFormat(JournalXML), WithJournalClock(clock.NewFake(now)))
+	require.NoError(t, err)
+
+	require.NoError(t, j.Record(Source{Device: "router1", SessionID: 42}, parseNotification(t, linkDownNotif)))
+	require.NoError(t, j.Close())
+
+	assert.Equal(t, []string{
+		`<entry received="2024-01-02T03:04:06Z" device="router1" session-id="42">` + linkDownNotif + `</entry>`,
+	}, readLines(t, path))
+}
+
+func TestJournalRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "notifs.jsonl")
+
+	n := parseNotification(t, linkDownNotif)
+	j, err := OpenJournal(path, WithJournalRotation(1, 2))
+	require.NoError(t, err)
+	defer j.Close()
+
+	for i := 0; i < 4; i++ {
+		require.NoError(t, j.Record(Source{Device: strings.Repeat("x", i+1)}, n))
+	}
+	require.NoError(t, j.Sync())
+
+	// every entry is larger than the max size so each one is in its own file
+	// and the oldest is dropped.
+	for file, device := range map[string]string{
+		path:        "xxxx",
+		path + ".1": "xxx",
+		path + ".2": "xx",
+	} {
+		lines := readLines(t, file)
+		require.Len(t, lines, 1, file)
+		var entry JournalEntry
+		require.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+		assert.Equal(t, device, entry.Device, file)
+	}
+	_, err = os.Stat(path + ".3")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestJournalConsume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notifs.jsonl")
+	j, err := OpenJournal(path)
+	require.NoError(t, err)
+	defer j.Close()
+
+	ch := make(chan netconf.Notification, 2)
+	ch <- parseNotification(t, linkDownNotif)
+	ch <- parseNotification(t, linkDownNotif)
+	close(ch)
+	require.NoError(t, j.Consume(context.Background(), Source{Device: "router1"}, ch))
+	assert.Len(t, readLines(t, path), 2)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, j.Consume(ctx, Source{}, make(chan netconf.Notification)), context.Canceled)
+}
+
+func TestJournalNamespaces(t *testing.T) {
+	tt := []struct {
+		name  string
+		notif string
+	}{
+		{
+			name:  "prefixed event",
+			notif: `<notification xmlns="urn:ietf:params:xml:ns:netconf:notification:1.0" xmlns:ex="urn:example"><eventTime>2024-01-02T03:04:05Z</eventTime><ex:link-down><ex:name>eth0</ex:name></ex:link-down></notification>`,
+		},
+		{
+			name:  "prefixed notification",
+			notif: `<ncn:notification xmlns:ncn="urn:ietf:params:xml:ns:netconf:notification:1.0" xmlns="urn:example"><ncn:eventTime>2024-01-02T03:04:05Z</ncn:eventTime><link-down><name>eth0</name></link-down></ncn:notification>`,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "notifs.jsonl")
+			j, err := OpenJournal(path)
+			require.NoError(t, err)
+			require.NoError(t, j.Record(Source{}, parseNotification(t, tc.notif)))
+			require.NoError(t, j.Close())
+
+			lines := readLines(t, path)
+			require.Len(t, lines, 1)
+			var entry JournalEntry
+			require.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+
+			// the journaled notification still resolves the prefixes.
+			n := parseNotification(t, entry.Notification)
+			assert.Equal(t, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), n.EventTime)
+			assert.Equal(t, "urn:example", n.EventName().Space)
+			assert.Equal(t, "link-down", n.EventName().Local)
+		})
+	}
+}
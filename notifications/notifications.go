@@ -0,0 +1,93 @@
+// Package notifications implements helpers for NETCONF event notifications
+// (RFC5277) on top of [netconf.Session].
+package notifications
+
+import (
+	"context"
+	"encoding/xml"
+	"time"
+
+	"github.com/nemith/netconf"
+)
+
+// DefaultStream is the stream every server supporting notifications has.  It
+// is used by subscriptions that don't name a stream.
+const DefaultStream = "NETCONF"
+
+// Namespace is the namespace of the notification management data model from
+// RFC5277 section 3.4 listing the event streams of a server.
+const Namespace = "urn:ietf:params:xml:ns:netmod:notification"
+
+// Stream is an event stream a client can subscribe to.
+type Stream struct {
+	Name        string `xml:"name"`
+	Description string `xml:"description,omitempty"`
+
+	// ReplaySupport reports if the server keeps a replay log for the stream
+	// so that subscriptions can ask for past notifications with a start time.
+	ReplaySupport bool `xml:"replaySupport"`
+
+	// ReplayLogCreationTime is when the replay log was created, the earliest
+	// time notifications can be replayed from unless notifications have aged
+	// out of the log since.
+	ReplayLogCreationTime time.Time `xml:"replayLogCreationTime,omitempty"`
+
+	// ReplayLogAgedTime is the time of the oldest notification still in the
+	// replay log if any have aged out.
+	ReplayLogAgedTime time.Time `xml:"replayLogAgedTime,omitempty"`
+}
+
+// ReplayStart returns the earliest time notifications can be replayed from or
+// the zero time if the stream doesn't support replay.
+func (s Stream) ReplayStart() time.Time {
+	if !s.ReplaySupport {
+		return time.Time{}
+	}
+	if !s.ReplayLogAgedTime.IsZero() {
+		return s.ReplayLogAgedTime
+	}
+	return s.ReplayLogCreationTime
+}
+
+// StreamsReq is a `<get>` with a subtree filter selecting the `<streams>` of
+// the notification management data.
+type StreamsReq struct {
+	XMLName xml.Name      `xml:"urn:ietf:params:xml:ns:netconf:base:1.0 get"`
+	Filter  streamsFilter `xml:"filter"`
+}
+
+type streamsFilter struct {
+	Type    string `xml:"type,attr"`
+	Netconf struct {
+		XMLName xml.Name `xml:"urn:ietf:params:xml:ns:netmod:notification netconf"`
+		Streams struct{} `xml:"streams"`
+	}
+}
+
+// StreamsReply is the reply to [StreamsReq].
+type StreamsReply struct {
+	XMLName xml.Name `xml:"data"`
+	Streams []Stream `xml:"urn:ietf:params:xml:ns:netmod:notification netconf>streams>stream"`
+}
+
+// Streams returns the event streams the server supports.  Servers that don't
+// implement the notification management data return no streams;
+// [DefaultStream] can still be subscribed to.
+func Streams(ctx context.Context, sess *netconf.Session) ([]Stream, error) {
+	req := StreamsReq{Filter: streamsFilter{Type: "subtree"}}
+	var resp StreamsReply
+	if err := sess.Call(ctx, &req, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Streams, nil
+}
+
+// Find returns the stream with the given name.
+func Find(streams []Stream, name string) (Stream, bool) {
+	for _, s := range streams {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return Stream{}, false
+}
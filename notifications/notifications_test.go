@@ -0,0 +1,77 @@
+package notifications
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nemith/netconf"
+	"github.com/nemith/netconf/transport/transporttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreams(t *testing.T) {
+	tr := transporttest.NewTransport(t)
+	tr.Expect(`<filter type="subtree"><netconf xmlns="urn:ietf:params:xml:ns:netmod:notification"><streams></streams></netconf></filter>`).
+		RespondData(`<netconf xmlns="urn:ietf:params:xml:ns:netmod:notification"><streams>
+			<stream>
+				<name>NETCONF</name>
+				<description>default NETCONF event stream</description>
+				<replaySupport>true</replaySupport>
+				<replayLogCreationTime>2024-01-02T03:04:05Z</replayLogCreationTime>
+			</stream>
+			<stream>
+				<name>SNMP</name>
+				<replaySupport>true</replaySupport>
+				<replayLogCreationTime>2024-01-02T03:04:05Z</replayLogCreationTime>
+				<replayLogAgedTime>2024-02-01T00:00:00Z</replayLogAgedTime>
+			</stream>
+			<stream>
+				<name>syslog</name>
+				<replaySupport>false</replaySupport>
+			</stream>
+		</streams></netconf>`)
+
+	sess, err := netconf.Open(tr)
+	require.NoError(t, err)
+	defer sess.Close(context.Background())
+
+	streams, err := Streams(context.Background(), sess)
+	require.NoError(t, err)
+	require.Len(t, streams, 3)
+
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	assert.Equal(t, Stream{
+		Name:                  "NETCONF",
+		Description:           "default NETCONF event stream",
+		ReplaySupport:         true,
+		ReplayLogCreationTime: created,
+	}, streams[0])
+	assert.Equal(t, created, streams[0].ReplayStart())
+
+	snmp, ok := Find(streams, "SNMP")
+	require.True(t, ok)
+	assert.Equal(t, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), snmp.ReplayStart())
+
+	syslog, ok := Find(streams, "syslog")
+	require.True(t, ok)
+	assert.False(t, syslog.ReplaySupport)
+	assert.True(t, syslog.ReplayStart().IsZero())
+
+	_, ok = Find(streams, "missing")
+	assert.False(t, ok)
+}
+
+func TestStreamsUnsupported(t *testing.T) {
+	tr := transporttest.NewTransport(t)
+	tr.Expect("<get").RespondData("")
+
+	sess, err := netconf.Open(tr)
+	require.NoError(t, err)
+	defer sess.Close(context.Background())
+
+	streams, err := Streams(context.Background(), sess)
+	require.NoError(t, err)
+	assert.Empty(t, streams)
+}
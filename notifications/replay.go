@@ -0,0 +1,92 @@
+package notifications
+
+import (
+	"encoding/xml"
+	"sync"
+
+	"github.com/nemith/netconf"
+)
+
+var (
+	// ReplayCompleteName is the name of the [ReplayComplete] event.
+	ReplayCompleteName = xml.Name{Space: Namespace, Local: "replayComplete"}
+
+	// NotificationCompleteName is the name of the [NotificationComplete]
+	// event.
+	NotificationCompleteName = xml.Name{Space: Namespace, Local: "notificationComplete"}
+)
+
+// ReplayComplete is sent by the server after the last notification replayed
+// from its log for a subscription with a start time.  Notifications after it
+// are live.
+type ReplayComplete struct {
+	XMLName xml.Name `xml:"urn:ietf:params:xml:ns:netmod:notification replayComplete"`
+}
+
+// NotificationComplete is sent by the server when the stop time of a
+// subscription has passed.  It is the last notification of the subscription.
+type NotificationComplete struct {
+	XMLName xml.Name `xml:"urn:ietf:params:xml:ns:netmod:notification notificationComplete"`
+}
+
+// Subscription tracks the state of a replay subscription so that consumers
+// know when historical notifications end and live ones begin.  Pass
+// [Subscription.HandleNotification] to [netconf.WithNotificationHandler] and
+// start the subscription with [netconf.WithStartTimeOption] (and optionally
+// [netconf.WithStopTimeOption]).
+//
+//	sub := notifications.NewSubscription(handler)
+//	sess, err := netconf.Open(tr, netconf.WithNotificationHandler(sub.HandleNotification))
+//	...
+//	err = sess.CreateSubscription(ctx, netconf.WithStartTimeOption(since))
+//	...
+//	<-sub.Replayed()
+//
+// Every notification, including the `<replayComplete>` and
+// `<notificationComplete>` events, is passed on to the handler.  The channels
+// are closed after the handler returns for the event so all notifications
+// before it have been handled.
+type Subscription struct {
+	handler netconf.NotificationHandler
+
+	replayedOnce sync.Once
+	replayed     chan struct{}
+	doneOnce     sync.Once
+	done         chan struct{}
+}
+
+// NewSubscription returns a Subscription passing notifications to h.  h may be
+// nil if only the completion signals are needed.
+func NewSubscription(h netconf.NotificationHandler) *Subscription {
+	return &Subscription{
+		handler:  h,
+		replayed: make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// HandleNotification is a [netconf.NotificationHandler].
+func (s *Subscription) HandleNotification(n netconf.Notification) {
+	if s.handler != nil {
+		s.handler(n)
+	}
+
+	switch n.EventName() {
+	case ReplayCompleteName:
+		s.replayedOnce.Do(func() { close(s.replayed) })
+	case NotificationCompleteName:
+		// the replay is over too if the stop time was within the replay log.
+		s.replayedOnce.Do(func() { close(s.replayed) })
+		s.doneOnce.Do(func() { close(s.done) })
+	}
+}
+
+// Replayed returns a channel that is closed when the server signals the end of
+// the replay with `<replayComplete>` (or `<notificationComplete>`).  It is
+// never closed for subscriptions without a start time.
+func (s *Subscription) Replayed() <-chan struct{} { return s.replayed }
+
+// Done returns a channel that is closed when the server signals the end of the
+// subscription with `<notificationComplete>`.  It is never closed for
+// subscriptions without a stop time.
+func (s *Subscription) Done() <-chan struct{} { return s.done }
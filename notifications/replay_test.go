@@ -0,0 +1,100 @@
+package notifications
+
+import (
+	"context"
+	"encoding/xml"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nemith/netconf"
+	"github.com/nemith/netconf/transport/transporttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubscriptionReplay(t *testing.T) {
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	stop := start.Add(time.Hour)
+
+	tr := transporttest.NewTransport(t)
+	tr.Expect("<startTime>2024-01-02T03:04:05Z</startTime><stopTime>2024-01-02T04:04:05Z</stopTime>").
+		RespondOK().
+		ThenNotify(`<event xmlns="urn:example">old</event>`).
+		ThenNotify(`<replayComplete xmlns="urn:ietf:params:xml:ns:netmod:notification"/>`).
+		ThenNotify(`<event xmlns="urn:example">new</event>`).
+		ThenNotify(`<notificationComplete xmlns="urn:ietf:params:xml:ns:netmod:notification"/>`)
+
+	var (
+		mu     sync.Mutex
+		events []string
+	)
+	mux := netconf.NewNotificationMux()
+	netconf.HandleEvent(mux, xml.Name{Space: "urn:example", Local: "event"}, func(_ netconf.Notification, ev struct {
+		Value string `xml:",chardata"`
+	}) {
+		mu.Lock()
+		events = append(events, ev.Value)
+		mu.Unlock()
+	})
+	netconf.HandleEvent(mux, ReplayCompleteName, func(_ netconf.Notification, _ ReplayComplete) {
+		mu.Lock()
+		events = append(events, "replayComplete")
+		mu.Unlock()
+	})
+	sub := NewSubscription(mux.HandleNotification)
+
+	sess, err := netconf.Open(tr, netconf.WithNotificationHandler(sub.HandleNotification))
+	require.NoError(t, err)
+	defer sess.Close(context.Background())
+
+	require.NoError(t, sess.CreateSubscription(context.Background(),
+		netconf.WithStartTimeOption(start), netconf.WithStopTimeOption(stop)))
+
+	select {
+	case <-sub.Replayed():
+	case <-time.After(5 * time.Second):
+		t.Fatal("replay never completed")
+	}
+	// live notifications may already be handled but never before the replay
+	// completed.
+	mu.Lock()
+	assert.Equal(t, []string{"old", "replayComplete"}, events[:2])
+	mu.Unlock()
+
+	select {
+	case <-sub.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("subscription never completed")
+	}
+	mu.Lock()
+	assert.Equal(t, []string{"old", "replayComplete", "new"}, events)
+	mu.Unlock()
+}
+
+func TestSubscriptionLive(t *testing.T) {
+	sub := NewSubscription(nil)
+
+	tr := transporttest.NewTransport(t)
+	tr.Expect("<create-subscription").RespondOK().
+		ThenNotify(`<event xmlns="urn:example"/>`)
+
+	received := make(chan struct{})
+	sess, err := netconf.Open(tr, netconf.WithNotificationHandler(func(n netconf.Notification) {
+		sub.HandleNotification(n)
+		close(received)
+	}))
+	require.NoError(t, err)
+	defer sess.Close(context.Background())
+
+	require.NoError(t, sess.CreateSubscription(context.Background()))
+	<-received
+
+	select {
+	case <-sub.Replayed():
+		t.Fatal("replay completed without a replayComplete")
+	case <-sub.Done():
+		t.Fatal("subscription completed without a notificationComplete")
+	default:
+	}
+}
@@ -0,0 +1,145 @@
+package notifications
+
+import (
+	"encoding/xml"
+	"sync"
+
+	"github.com/nemith/netconf"
+)
+
+// YangPushNamespace is the namespace of the ietf-yang-push module (RFC8641).
+const YangPushNamespace = "urn:ietf:params:xml:ns:yang:ietf-yang-push"
+
+var (
+	// PushUpdateName is the name of the [PushUpdate] event.
+	PushUpdateName = xml.Name{Space: YangPushNamespace, Local: "push-update"}
+
+	// PushChangeUpdateName is the name of the [PushChangeUpdate] event.
+	PushChangeUpdateName = xml.Name{Space: YangPushNamespace, Local: "push-change-update"}
+)
+
+// PushUpdate is a `<push-update>` notification sent for periodic YANG-Push
+// subscriptions (and the first update of on-change subscriptions) with the
+// full contents of the subscribed datastore nodes.
+type PushUpdate struct {
+	XMLName xml.Name `xml:"urn:ietf:params:xml:ns:yang:ietf-yang-push push-update"`
+
+	// ID is the id of the subscription the update is for.
+	ID uint32 `xml:"id"`
+
+	// DatastoreContents is the raw xml of the selected datastore nodes.
+	DatastoreContents netconf.RawXML `xml:"datastore-contents"`
+
+	// IncompleteUpdate is set when the server couldn't include all of the
+	// selected data.
+	IncompleteUpdate netconf.ExtantBool `xml:"incomplete-update"`
+}
+
+// PushChangeUpdate is a `<push-change-update>` notification sent for
+// on-change YANG-Push subscriptions with the changes since the last update as
+// a YANG patch (RFC8072).
+type PushChangeUpdate struct {
+	XMLName xml.Name `xml:"urn:ietf:params:xml:ns:yang:ietf-yang-push push-change-update"`
+
+	// ID is the id of the subscription the update is for.
+	ID uint32 `xml:"id"`
+
+	// Patch holds the changes to the selected datastore nodes.
+	Patch YangPatch `xml:"datastore-changes>yang-patch"`
+
+	// IncompleteUpdate is set when the server couldn't include all of the
+	// changes.
+	IncompleteUpdate netconf.ExtantBool `xml:"incomplete-update"`
+}
+
+// YangPatch is an ordered list of edits to a datastore.
+type YangPatch struct {
+	PatchID string      `xml:"patch-id"`
+	Comment string      `xml:"comment,omitempty"`
+	Edits   []PatchEdit `xml:"edit"`
+}
+
+// PatchEdit is a single edit of a [YangPatch].
+type PatchEdit struct {
+	EditID string `xml:"edit-id"`
+
+	// Operation is one of create, delete, insert, merge, move, replace or
+	// remove.
+	Operation string `xml:"operation"`
+
+	// Target is the path of the changed node relative to the subscription's
+	// selection.
+	Target string `xml:"target"`
+
+	// Point and Where position inserted or moved entries of ordered-by user
+	// lists.
+	Point string `xml:"point,omitempty"`
+	Where string `xml:"where,omitempty"`
+
+	// Value is the raw xml of the new value for create, insert, merge,
+	// move and replace operations.
+	Value netconf.RawXML `xml:"value,omitempty"`
+}
+
+// PushHandler receives the updates of a YANG-Push subscription.  Either
+// function may be nil to ignore that kind of update.
+type PushHandler struct {
+	Update func(n netconf.Notification, u PushUpdate)
+	Change func(n netconf.Notification, u PushChangeUpdate)
+}
+
+// PushRouter dispatches YANG-Push updates to a handler by subscription id so
+// that each subscription can be consumed on its own.  Register it on a
+// [netconf.NotificationMux] with [PushRouter.Register].
+//
+// The zero value is ready to use.
+type PushRouter struct {
+	// Unknown is called for updates for subscriptions without a handler.
+	Unknown PushHandler
+
+	mu   sync.RWMutex
+	subs map[uint32]PushHandler
+}
+
+// Handle registers the handler for the subscription with the given id,
+// replacing any previous handler.
+func (r *PushRouter) Handle(id uint32, h PushHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.subs == nil {
+		r.subs = make(map[uint32]PushHandler)
+	}
+	r.subs[id] = h
+}
+
+// Remove removes the handler for the subscription with the given id.
+func (r *PushRouter) Remove(id uint32) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.subs, id)
+}
+
+func (r *PushRouter) handler(id uint32) PushHandler {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if h, ok := r.subs[id]; ok {
+		return h
+	}
+	return r.Unknown
+}
+
+// Register adds handlers for `<push-update>` and `<push-change-update>`
+// notifications to mux.  Updates that cannot be decoded are sent to the mux's
+// ErrorHandler.
+func (r *PushRouter) Register(mux *netconf.NotificationMux) {
+	netconf.HandleEvent(mux, PushUpdateName, func(n netconf.Notification, u PushUpdate) {
+		if h := r.handler(u.ID); h.Update != nil {
+			h.Update(n, u)
+		}
+	})
+	netconf.HandleEvent(mux, PushChangeUpdateName, func(n netconf.Notification, u PushChangeUpdate) {
+		if h := r.handler(u.ID); h.Change != nil {
+			h.Change(n, u)
+		}
+	})
+}
@@ -0,0 +1,125 @@
+package notifications
+
+import (
+	"encoding/xml"
+	"testing"
+
+	"github.com/nemith/netconf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	pushUpdateNotif = `<notification xmlns="urn:ietf:params:xml:ns:netconf:notification:1.0">
+	<eventTime>2024-01-02T03:04:05Z</eventTime>
+	<push-update xmlns="urn:ietf:params:xml:ns:yang:ietf-yang-push">
+		<id>1011</id>
+		<datastore-contents><interfaces xmlns="urn:ietf:params:xml:ns:yang:ietf-interfaces"><interface><name>eth0</name></interface></interfaces></datastore-contents>
+	</push-update>
+</notification>`
+
+	pushChangeNotif = `<notification xmlns="urn:ietf:params:xml:ns:netconf:notification:1.0">
+	<eventTime>2024-01-02T03:04:06Z</eventTime>
+	<push-change-update xmlns="urn:ietf:params:xml:ns:yang:ietf-yang-push">
+		<id>89</id>
+		<datastore-changes>
+			<yang-patch>
+				<patch-id>0</patch-id>
+				<edit>
+					<edit-id>edit1</edit-id>
+					<operation>merge</operation>
+					<target>/ietf-interfaces:interfaces</target>
+					<value><interfaces xmlns="urn:ietf:params:xml:ns:yang:ietf-interfaces"><interface><name>eth0</name><oper-status>up</oper-status></interface></interfaces></value>
+				</edit>
+				<edit>
+					<edit-id>edit2</edit-id>
+					<operation>delete</operation>
+					<target>/ietf-interfaces:interfaces/interface=eth1</target>
+				</edit>
+			</yang-patch>
+		</datastore-changes>
+		<incomplete-update/>
+	</push-change-update>
+</notification>`
+)
+
+func parseNotification(t *testing.T, raw string) netconf.Notification {
+	t.Helper()
+	var n netconf.Notification
+	require.NoError(t, xml.Unmarshal([]byte(raw), &n))
+	return n
+}
+
+func TestPushUpdateDecode(t *testing.T) {
+	n := parseNotification(t, pushUpdateNotif)
+	assert.Equal(t, PushUpdateName, n.EventName())
+
+	var u PushUpdate
+	require.NoError(t, n.Decode(&u))
+	assert.Equal(t, uint32(1011), u.ID)
+	assert.False(t, bool(u.IncompleteUpdate))
+	assert.Equal(t, `<interfaces xmlns="urn:ietf:params:xml:ns:yang:ietf-interfaces"><interface><name>eth0</name></interface></interfaces>`,
+		string(u.DatastoreContents))
+}
+
+func TestPushChangeUpdateDecode(t *testing.T) {
+	n := parseNotification(t, pushChangeNotif)
+	assert.Equal(t, PushChangeUpdateName, n.EventName())
+
+	var u PushChangeUpdate
+	require.NoError(t, n.Decode(&u))
+	assert.Equal(t, uint32(89), u.ID)
+	assert.True(t, bool(u.IncompleteUpdate))
+	assert.Equal(t, "0", u.Patch.PatchID)
+	require.Len(t, u.Patch.Edits, 2)
+
+	assert.Equal(t, "edit1", u.Patch.Edits[0].EditID)
+	assert.Equal(t, "merge", u.Patch.Edits[0].Operation)
+	assert.Equal(t, "/ietf-interfaces:interfaces", u.Patch.Edits[0].Target)
+	assert.Contains(t, string(u.Patch.Edits[0].Value), "<oper-status>up</oper-status>")
+
+	assert.Equal(t, "delete", u.Patch.Edits[1].Operation)
+	assert.Empty(t, u.Patch.Edits[1].Value)
+}
+
+func TestPushRouter(t *testing.T) {
+	var (
+		updates []PushUpdate
+		changes []PushChangeUpdate
+		unknown []uint32
+		errs    []error
+	)
+
+	router := &PushRouter{
+		Unknown: PushHandler{
+			Update: func(_ netconf.Notification, u PushUpdate) { unknown = append(unknown, u.ID) },
+		},
+	}
+	router.Handle(1011, PushHandler{
+		Update: func(_ netconf.Notification, u PushUpdate) { updates = append(updates, u) },
+	})
+	router.Handle(89, PushHandler{
+		Change: func(_ netconf.Notification, u PushChangeUpdate) { changes = append(changes, u) },
+	})
+
+	mux := &netconf.NotificationMux{ErrorHandler: func(err error) { errs = append(errs, err) }}
+	router.Register(mux)
+
+	mux.HandleNotification(parseNotification(t, pushUpdateNotif))
+	mux.HandleNotification(parseNotification(t, pushChangeNotif))
+	assert.Len(t, updates, 1)
+	assert.Len(t, changes, 1)
+
+	router.Remove(1011)
+	mux.HandleNotification(parseNotification(t, pushUpdateNotif))
+	assert.Len(t, updates, 1)
+	assert.Equal(t, []uint32{1011}, unknown)
+
+	// no Change handler for unknown subscriptions
+	router.Remove(89)
+	mux.HandleNotification(parseNotification(t, pushChangeNotif))
+	assert.Len(t, changes, 1)
+
+	mux.HandleNotification(parseNotification(t, `<notification xmlns="urn:ietf:params:xml:ns:netconf:notification:1.0"><eventTime>2024-01-02T03:04:05Z</eventTime><push-update xmlns="urn:ietf:params:xml:ns:yang:ietf-yang-push"><id>bogus</id></push-update></notification>`))
+	assert.Len(t, errs, 1)
+}
@@ -0,0 +1,135 @@
+package netconf
+
+import "sync/atomic"
+
+// NotificationQueuePolicy controls what happens when a notification is
+// received while the notification queue is full.
+type NotificationQueuePolicy int
+
+const (
+	// NotificationQueueBlock stops reading from the server until there is
+	// room in the queue.  No notifications are lost but replies are delayed
+	// as well.
+	NotificationQueueBlock NotificationQueuePolicy = iota
+
+	// NotificationQueueDropNewest drops the notification that was just
+	// received.
+	NotificationQueueDropNewest
+
+	// NotificationQueueDropOldest drops the oldest notification in the queue
+	// to make room for the one that was just received.
+	NotificationQueueDropOldest
+)
+
+// SlowConsumerHandler is called when the notification queue fills up.  queued
+// is the size of the queue and dropped the number of notifications dropped so
+// far on the session.
+type SlowConsumerHandler func(queued int, dropped uint64)
+
+type notificationQueueOpt struct {
+	size   int
+	policy NotificationQueuePolicy
+}
+
+func (o notificationQueueOpt) apply(cfg *sessionConfig) {
+	cfg.notifQueueSize = o.size
+	cfg.notifQueuePolicy = o.policy
+}
+
+// WithNotificationQueue delivers notifications to the NotificationHandler from
+// a separate goroutine through a queue of the given size.  Without a queue the
+// handler is called from the goroutine reading from the server so a slow
+// handler delays the processing of replies.
+//
+// The policy decides what happens when the handler can't keep up and the
+// queue is full.  Use [WithSlowConsumerHandler] to be told when this happens
+// and [Session.DroppedNotifications] to get the number of notifications
+// dropped.
+func WithNotificationQueue(size int, policy NotificationQueuePolicy) SessionOption {
+	return notificationQueueOpt{size: size, policy: policy}
+}
+
+type slowConsumerOpt SlowConsumerHandler
+
+func (o slowConsumerOpt) apply(cfg *sessionConfig) { cfg.slowConsumerHandler = SlowConsumerHandler(o) }
+
+// WithSlowConsumerHandler sets a function that is called when the
+// notification queue set with [WithNotificationQueue] becomes full.  It is
+// called once each time the queue fills up, not for every notification
+// received while it is full.
+func WithSlowConsumerHandler(fn SlowConsumerHandler) SessionOption { return slowConsumerOpt(fn) }
+
+// notificationQueue holds the notifications waiting to be delivered to the
+// handler.
+type notificationQueue struct {
+	ch      chan Notification
+	policy  NotificationQueuePolicy
+	full    bool
+	dropped atomic.Uint64
+}
+
+// DroppedNotifications returns the number of notifications dropped because
+// the notification queue was full.
+func (s *Session) DroppedNotifications() uint64 {
+	if s.notifQueue == nil {
+		return 0
+	}
+	return s.notifQueue.dropped.Load()
+}
+
+// QueuedNotifications returns the number of notifications waiting to be
+// delivered to the handler.
+func (s *Session) QueuedNotifications() int {
+	if s.notifQueue == nil {
+		return 0
+	}
+	return len(s.notifQueue.ch)
+}
+
+// queueNotification queues a notification for delivery or delivers it
+// directly if there is no queue.  It is only called from the receive loop.
+func (s *Session) queueNotification(notif Notification) {
+	q := s.notifQueue
+	if q == nil {
+		s.dispatchNotification(notif)
+		return
+	}
+
+	select {
+	case q.ch <- notif:
+		q.full = false
+		return
+	default:
+	}
+
+	if !q.full {
+		q.full = true
+		if s.slowConsumerHandler != nil {
+			dropped := q.dropped.Load()
+			_ = s.safeCall("slow consumer handler", func() { s.slowConsumerHandler(cap(q.ch), dropped) })
+		}
+	}
+
+	switch q.policy {
+	case NotificationQueueDropNewest:
+		q.dropped.Add(1)
+	case NotificationQueueDropOldest:
+		select {
+		case <-q.ch:
+			q.dropped.Add(1)
+		default:
+		}
+		// the receive loop is the only sender so there is room now.
+		q.ch <- notif
+	default:
+		q.ch <- notif
+	}
+}
+
+// deliverNotifications delivers queued notifications until the queue is
+// closed by the receive loop.
+func (s *Session) deliverNotifications() {
+	for notif := range s.notifQueue.ch {
+		s.dispatchNotification(notif)
+	}
+}
@@ -0,0 +1,125 @@
+package netconf
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func numberedNotification(n int) string {
+	return fmt.Sprintf(`<notification xmlns="urn:ietf:params:xml:ns:netconf:notification:1.0"><eventTime>2024-01-01T00:00:00Z</eventTime><event>%d</event></notification>`, n)
+}
+
+func TestNotificationQueue(t *testing.T) {
+	tt := []struct {
+		name      string
+		policy    NotificationQueuePolicy
+		delivered []string
+	}{
+		{"drop newest", NotificationQueueDropNewest, []string{"1", "2", "3"}},
+		{"drop oldest", NotificationQueueDropOldest, []string{"1", "4", "5"}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			entered := make(chan struct{})
+			unblock := make(chan struct{})
+			var got []string
+			handler := func(n Notification) {
+				if len(got) == 0 {
+					close(entered)
+					<-unblock
+				}
+				var event string
+				require.NoError(t, n.Decode(&event))
+				got = append(got, event)
+			}
+
+			type slowCall struct {
+				queued  int
+				dropped uint64
+			}
+			var slow []slowCall
+
+			tr := newTestServer(t).transport()
+			sess := newSession(tr,
+				WithNotificationHandler(handler),
+				WithNotificationQueue(2, tc.policy),
+				WithSlowConsumerHandler(func(queued int, dropped uint64) {
+					slow = append(slow, slowCall{queued, dropped})
+				}))
+
+			done := make(chan struct{})
+			go func() {
+				sess.deliverNotifications()
+				close(done)
+			}()
+
+			tr.pushMsg(numberedNotification(1))
+			require.NoError(t, sess.recvMsg())
+			<-entered
+
+			for i := 2; i <= 5; i++ {
+				tr.pushMsg(numberedNotification(i))
+				require.NoError(t, sess.recvMsg())
+			}
+			assert.Equal(t, 2, sess.QueuedNotifications())
+			assert.EqualValues(t, 2, sess.DroppedNotifications())
+			assert.Equal(t, []slowCall{{2, 0}}, slow)
+
+			close(unblock)
+			close(sess.notifQueue.ch)
+			<-done
+			assert.Equal(t, tc.delivered, got)
+		})
+	}
+}
+
+func TestNotificationQueueHandlerRPC(t *testing.T) {
+	ts := newTestServer(t)
+	tr := ts.transport()
+
+	var (
+		sess *Session
+		got  []string
+		errs = make(chan error, 2)
+		last = make(chan struct{})
+	)
+	sess = newSession(tr,
+		WithNotificationQueue(4, NotificationQueueBlock),
+		WithNotificationHandler(func(n Notification) {
+			var event string
+			_ = n.Decode(&event)
+			got = append(got, event)
+			if event != "1" {
+				close(last)
+				return
+			}
+			// notification 2 arrives while the rpc is in-flight and is
+			// delivered after it.
+			tr.pushMsg(numberedNotification(2))
+			_, err := sess.Do(context.Background(), &CommitReq{}, WithHeldNotifications())
+			got = append(got, "reply")
+			errs <- err
+		}))
+	go sess.recv()
+
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><ok/></rpc-reply>`)
+	tr.pushMsg(numberedNotification(1))
+
+	select {
+	case err := <-errs:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("rpc from the notification handler did not complete")
+	}
+	_, err := ts.popReq()
+	require.NoError(t, err)
+
+	<-last
+	assert.Equal(t, []string{"1", "reply", "2"}, got)
+}
@@ -0,0 +1,248 @@
+package netconf
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+type namespacePrefixesOpt map[string]string
+
+func (o namespacePrefixesOpt) apply(cfg *sessionConfig) {
+	if cfg.namespacePrefixes == nil {
+		cfg.namespacePrefixes = make(map[string]string, len(o))
+	}
+	for prefix, uri := range o {
+		cfg.namespacePrefixes[prefix] = uri
+	}
+}
+
+// WithNamespacePrefixes declares namespace prefixes (mapped to their
+// namespace) on the `<rpc>` element of every rpc sent on the session.
+// Elements of the operation in one of the namespaces are written with the
+// prefix instead of with a default namespace declaration (`xmlns="..."`) and
+// default namespace declarations repeating the one in scope are dropped.
+// This makes requests smaller and works around devices that choke on the
+// namespace declaration encoding/xml repeats on every element.
+//
+//	sess, err := netconf.Open(tr, netconf.WithNamespacePrefixes(map[string]string{
+//		"if": "urn:ietf:params:xml:ns:yang:ietf-interfaces",
+//	}))
+//
+// Prefixes declared for a single rpc with [WithRPCNamespace] are used the
+// same way.
+func WithNamespacePrefixes(prefixes map[string]string) SessionOption {
+	return namespacePrefixesOpt(prefixes)
+}
+
+// namespaceAttrs returns the declarations of prefixes ordered by prefix.
+func namespaceAttrs(prefixes map[string]string) []xml.Attr {
+	if len(prefixes) == 0 {
+		return nil
+	}
+	attrs := make([]xml.Attr, 0, len(prefixes))
+	for prefix, uri := range prefixes {
+		attrs = append(attrs, xml.Attr{Name: xml.Name{Local: "xmlns:" + prefix}, Value: uri})
+	}
+	sort.Slice(attrs, func(i, j int) bool { return attrs[i].Name.Local < attrs[j].Name.Local })
+	return attrs
+}
+
+// declaresPrefixes reports if any prefix is declared on the rpc element.
+func declaresPrefixes(msg *request) bool {
+	for _, attr := range msg.Attrs {
+		if attr.Name.Space == "" && strings.HasPrefix(attr.Name.Local, "xmlns:") {
+			return true
+		}
+	}
+	return false
+}
+
+// prefixScope is the namespace scope of an element being rewritten.
+type prefixScope struct {
+	// src is the name of the element in the input and name the name as
+	// written.
+	src  xml.Name
+	name string
+
+	// srcDefault is the default namespace of the element in the input and
+	// outDefault the one in the output.
+	srcDefault string
+	outDefault string
+
+	// bindings are the prefixes declared in the input.
+	bindings map[string]string
+}
+
+// usePrefixes rewrites an encoded `<rpc>` so elements of the operation in a
+// namespace with a prefix declared on the rpc element use the prefix.
+// Default namespace declarations are only written where the default
+// namespace changes.  Prefixes redeclared in the operation are left alone.
+func usePrefixes(raw []byte) ([]byte, error) {
+	var (
+		d          = xml.NewDecoder(bytes.NewReader(raw))
+		out        bytes.Buffer
+		stack      []prefixScope
+		prefixes   = make(map[string]string) // namespace to prefix
+		open       bool                      // start tag not yet closed
+		closeStart = func() {
+			if open {
+				out.WriteByte('>')
+				open = false
+			}
+		}
+	)
+	out.Grow(len(raw))
+
+	for {
+		tok, err := d.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to rewrite namespace prefixes: %w", err)
+		}
+
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			closeStart()
+
+			var parent prefixScope
+			if len(stack) > 0 {
+				parent = stack[len(stack)-1]
+			}
+			scope := prefixScope{
+				src:        tok.Name,
+				srcDefault: parent.srcDefault,
+				outDefault: parent.outDefault,
+				bindings:   parent.bindings,
+			}
+
+			var attrs []xml.Attr
+			for _, attr := range tok.Attr {
+				switch {
+				case attr.Name.Space == "" && attr.Name.Local == "xmlns":
+					scope.srcDefault = attr.Value
+				case attr.Name.Space == "xmlns":
+					bindings := make(map[string]string, len(scope.bindings)+1)
+					for p, uri := range scope.bindings {
+						bindings[p] = uri
+					}
+					bindings[attr.Name.Local] = attr.Value
+					scope.bindings = bindings
+					if len(stack) == 0 {
+						if _, ok := prefixes[attr.Value]; !ok {
+							prefixes[attr.Value] = attr.Name.Local
+						}
+					}
+					attrs = append(attrs, attr)
+				default:
+					attrs = append(attrs, attr)
+				}
+			}
+
+			out.WriteByte('<')
+			switch {
+			case tok.Name.Space != "":
+				// already prefixed
+				scope.name = tok.Name.Space + ":" + tok.Name.Local
+				out.WriteString(scope.name)
+			case len(stack) > 0 && scope.srcDefault != "" &&
+				prefixes[scope.srcDefault] != "" &&
+				scope.bindings[prefixes[scope.srcDefault]] == scope.srcDefault:
+				scope.name = prefixes[scope.srcDefault] + ":" + tok.Name.Local
+				out.WriteString(scope.name)
+			default:
+				scope.name = tok.Name.Local
+				out.WriteString(scope.name)
+				if scope.outDefault != scope.srcDefault {
+					writeAttr(&out, "xmlns", scope.srcDefault)
+					scope.outDefault = scope.srcDefault
+				}
+			}
+			for _, attr := range attrs {
+				name := attr.Name.Local
+				if attr.Name.Space != "" {
+					name = attr.Name.Space + ":" + name
+				}
+				writeAttr(&out, name, attr.Value)
+			}
+			open = true
+			stack = append(stack, scope)
+
+		case xml.EndElement:
+			if len(stack) == 0 || stack[len(stack)-1].src != tok.Name {
+				return nil, fmt.Errorf("failed to rewrite namespace prefixes: unexpected end element </%s>", tok.Name.Local)
+			}
+			scope := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if open {
+				out.WriteString("/>")
+				open = false
+				continue
+			}
+			out.WriteString("</" + scope.name + ">")
+
+		case xml.CharData:
+			closeStart()
+			escapeString(&out, string(tok), false)
+
+		case xml.Comment:
+			closeStart()
+			out.WriteString("<!--")
+			out.Write(tok)
+			out.WriteString("-->")
+
+		case xml.ProcInst:
+			closeStart()
+			out.WriteString("<?" + tok.Target)
+			if len(tok.Inst) > 0 {
+				out.WriteByte(' ')
+				out.Write(tok.Inst)
+			}
+			out.WriteString("?>")
+
+		case xml.Directive:
+			closeStart()
+			out.WriteString("<!")
+			out.Write(tok)
+			out.WriteByte('>')
+		}
+	}
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("failed to rewrite namespace prefixes: %w", io.ErrUnexpectedEOF)
+	}
+	return out.Bytes(), nil
+}
+
+func writeAttr(buf *bytes.Buffer, name, value string) {
+	buf.WriteString(" " + name + `="`)
+	escapeString(buf, value, true)
+	buf.WriteByte('"')
+}
+
+// escapeString escapes the characters that can't appear in character data or
+// attribute values.  Unlike xml.EscapeText whitespace is kept as is.
+func escapeString(buf *bytes.Buffer, s string, attr bool) {
+	for _, r := range s {
+		switch {
+		case r == '&':
+			buf.WriteString("&amp;")
+		case r == '<':
+			buf.WriteString("&lt;")
+		case r == '>':
+			buf.WriteString("&gt;")
+		case r == '"' && attr:
+			buf.WriteString("&quot;")
+		case (r == '\n' || r == '\t' || r == '\r') && attr:
+			fmt.Fprintf(buf, "&#x%X;", r)
+		case r == '\r':
+			buf.WriteString("&#xD;")
+		default:
+			buf.WriteRune(r)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package netconf
+
+import (
+	"context"
+	"encoding/xml"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsePrefixes(t *testing.T) {
+	tt := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "prefixed",
+			in:   `<rpc xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1" xmlns:if="urn:if"><edit-config xmlns="urn:ietf:params:xml:ns:netconf:base:1.0"><config><interfaces xmlns="urn:if"><interface xmlns="urn:if"><name xmlns="urn:if">eth0</name></interface></interfaces></config></edit-config></rpc>`,
+			want: `<rpc xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1" xmlns:if="urn:if"><edit-config><config><if:interfaces><if:interface><if:name>eth0</if:name></if:interface></if:interfaces></config></edit-config></rpc>`,
+		},
+		{
+			name: "other namespaces",
+			in:   `<rpc xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" xmlns:if="urn:if"><a xmlns="urn:x"><b xmlns="urn:x"><c xmlns="urn:if"><d xmlns="urn:x"/></c></b><e xmlns=""/></a></rpc>`,
+			want: `<rpc xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" xmlns:if="urn:if"><a xmlns="urn:x"><b><if:c><d/></if:c></b><e xmlns=""/></a></rpc>`,
+		},
+		{
+			name: "redeclared prefix",
+			in:   `<rpc xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" xmlns:if="urn:if"><a xmlns:if="urn:other"><b xmlns="urn:if"/></a></rpc>`,
+			want: `<rpc xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" xmlns:if="urn:if"><a xmlns:if="urn:other"><b xmlns="urn:if"/></a></rpc>`,
+		},
+		{
+			name: "content",
+			in:   "<rpc xmlns:x=\"urn:x\"><!-- note --><a xmlns=\"urn:x\" b=\"1 &amp; &quot;2&quot;\">x &lt; y\n<x:c>ex:value</x:c></a></rpc>",
+			want: "<rpc xmlns:x=\"urn:x\"><!-- note --><x:a b=\"1 &amp; &quot;2&quot;\">x &lt; y\n<x:c>ex:value</x:c></x:a></rpc>",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := usePrefixes([]byte(tc.in))
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, string(got))
+		})
+	}
+
+	for _, in := range []string{"<rpc><a></rpc>", "<rpc></rpc></a>", "<rpc><a>"} {
+		_, err := usePrefixes([]byte(in))
+		assert.Error(t, err, in)
+	}
+}
+
+func TestNamespacePrefixes(t *testing.T) {
+	ts := newTestServer(t)
+	sess := newSession(ts.transport(), WithNamespacePrefixes(map[string]string{
+		"sys": "urn:example:system",
+		"if":  "urn:ietf:params:xml:ns:yang:ietf-interfaces",
+	}))
+	go sess.recv()
+
+	type system struct {
+		XMLName  xml.Name `xml:"urn:example:system system"`
+		Hostname string   `xml:"urn:example:system hostname"`
+	}
+
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><ok/></rpc-reply>`)
+	err := sess.EditConfig(context.Background(), Candidate, system{Hostname: "r1"})
+	require.NoError(t, err)
+
+	req, err := ts.popReqString()
+	require.NoError(t, err)
+	assert.Contains(t, req, `<rpc xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1" xmlns:if="urn:ietf:params:xml:ns:yang:ietf-interfaces" xmlns:sys="urn:example:system">`)
+	assert.Contains(t, req, `<sys:system><sys:hostname>r1</sys:hostname></sys:system>`)
+	assert.NotContains(t, req, `xmlns="urn:example:system"`)
+
+	// prefixes given for a single rpc are used too.
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="2"><ok/></rpc-reply>`)
+	_, err = sess.Do(context.Background(), `<get-chassis-inventory xmlns="http://xml.juniper.net/junos/*/junos"/>`,
+		WithRPCNamespace("junos", "http://xml.juniper.net/junos/*/junos"))
+	require.NoError(t, err)
+
+	req, err = ts.popReqString()
+	require.NoError(t, err)
+	assert.Contains(t, req, `<junos:get-chassis-inventory/>`)
+}
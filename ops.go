@@ -4,8 +4,8 @@ import (
 	"context"
 	"encoding/xml"
 	"fmt"
-	"strings"
 	"time"
+	"unicode"
 )
 
 type ExtantBool bool
@@ -39,31 +39,60 @@ type OKResp struct {
 
 type Datastore string
 
-func (s Datastore) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
-	if s == "" {
-		return fmt.Errorf("datastores cannot be empty")
-	}
+// datastoreElem is the encoded form of a datastore.  The datastore is written
+// as raw inner xml to produce a self-closing element (i.e `<running/>`) which
+// encoding/xml can't otherwise generate.
+type datastoreElem struct {
+	Elem string `xml:",innerxml"`
+}
 
-	// XXX: it would be nice to actually just block names with crap in them
-	// instead of escaping them, but we need to find a list of what is allowed
-	// in an xml tag.
-	escaped, err := escapeXML(string(s))
+// The standard datastores are pre-encoded so that encoding them doesn't
+// allocate on the hot path of sending many rpcs.  Custom datastores are
+// validated and encoded every time.
+var (
+	runningElem   = datastoreElem{"<running/>"}
+	candidateElem = datastoreElem{"<candidate/>"}
+	startupElem   = datastoreElem{"<startup/>"}
+)
+
+func (s Datastore) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	elem, err := s.elem()
 	if err != nil {
-		return fmt.Errorf("invalid string element: %w", err)
+		return err
+	}
+	return e.EncodeElement(elem, start)
+}
+
+func (s Datastore) elem() (*datastoreElem, error) {
+	switch s {
+	case Running:
+		return &runningElem, nil
+	case Candidate:
+		return &candidateElem, nil
+	case Startup:
+		return &startupElem, nil
+	case "":
+		return nil, fmt.Errorf("datastores cannot be empty")
 	}
 
-	v := struct {
-		Elem string `xml:",innerxml"`
-	}{Elem: "<" + escaped + "/>"}
-	return e.EncodeElement(&v, start)
-}
-
-func escapeXML(input string) (string, error) {
-	buf := &strings.Builder{}
-	if err := xml.EscapeText(buf, []byte(input)); err != nil {
-		return "", err
+	if !isXMLName(string(s)) {
+		return nil, fmt.Errorf("invalid datastore name %q", string(s))
 	}
-	return buf.String(), nil
+	return &datastoreElem{"<" + string(s) + "/>"}, nil
+}
+
+// isXMLName reports if s is usable as the name of an xml element.  This is a
+// slightly stricter version of the `Name` production in the XML spec.
+func isXMLName(s string) bool {
+	for i, r := range s {
+		switch {
+		case r == '_' || unicode.IsLetter(r):
+		case i > 0 && (r == '-' || r == '.' || r == ':' || unicode.IsDigit(r)):
+		default:
+			return false
+		}
+	}
+	return s != ""
 }
 
 type URL string
@@ -92,6 +121,9 @@ type GetConfigReq struct {
 	XMLName xml.Name  `xml:"get-config"`
 	Source  Datastore `xml:"source"`
 	// Filter
+
+	// Extensions are vendor specific elements added to the operation.
+	Extensions []any `xml:",any"`
 }
 
 type GetConfigReply struct {
@@ -236,6 +268,9 @@ type EditConfigReq struct {
 	// either of these two values
 	Config any    `xml:"config,omitempty"`
 	URL    string `xml:"url,omitempty"`
+
+	// Extensions are vendor specific elements added to the operation.
+	Extensions []any `xml:",any"`
 }
 
 // EditOption is a optional arguments to [Session.EditConfig] method
@@ -248,6 +283,13 @@ type EditConfigOption interface {
 //
 // [RFC6241 7.2]: https://www.rfc-editor.org/rfc/rfc6241.html#section-7.2
 func (s *Session) EditConfig(ctx context.Context, target Datastore, config any, opts ...EditConfigOption) error {
+	req := newEditConfigReq(target, config, opts...)
+
+	var resp OKResp
+	return s.Call(ctx, &req, &resp)
+}
+
+func newEditConfigReq(target Datastore, config any, opts ...EditConfigOption) EditConfigReq {
 	req := EditConfigReq{
 		Target: target,
 	}
@@ -272,14 +314,16 @@ func (s *Session) EditConfig(ctx context.Context, target Datastore, config any,
 		opt.apply(&req)
 	}
 
-	var resp OKResp
-	return s.Call(ctx, &req, &resp)
+	return req
 }
 
 type CopyConfigReq struct {
 	XMLName xml.Name `xml:"copy-config"`
 	Source  any      `xml:"source"`
 	Target  any      `xml:"target"`
+
+	// Extensions are vendor specific elements added to the operation.
+	Extensions []any `xml:",any"`
 }
 
 // CopyConfig issues the `<copy-config>` operation as defined in [RFC6241 7.3]
@@ -304,6 +348,9 @@ func (s *Session) CopyConfig(ctx context.Context, source, target any) error {
 type DeleteConfigReq struct {
 	XMLName xml.Name  `xml:"delete-config"`
 	Target  Datastore `xml:"target"`
+
+	// Extensions are vendor specific elements added to the operation.
+	Extensions []any `xml:",any"`
 }
 
 func (s *Session) DeleteConfig(ctx context.Context, target Datastore) error {
@@ -316,24 +363,33 @@ func (s *Session) DeleteConfig(ctx context.Context, target Datastore) error {
 }
 
 type LockReq struct {
-	XMLName xml.Name
+	XMLName xml.Name  `xml:"lock"`
 	Target  Datastore `xml:"target"`
+
+	// Extensions are vendor specific elements added to the operation.
+	Extensions []any `xml:",any"`
 }
 
 func (s *Session) Lock(ctx context.Context, target Datastore) error {
 	req := LockReq{
-		XMLName: xml.Name{Local: "lock"},
-		Target:  target,
+		Target: target,
 	}
 
 	var resp OKResp
 	return s.Call(ctx, &req, &resp)
 }
 
+type UnlockReq struct {
+	XMLName xml.Name  `xml:"unlock"`
+	Target  Datastore `xml:"target"`
+
+	// Extensions are vendor specific elements added to the operation.
+	Extensions []any `xml:",any"`
+}
+
 func (s *Session) Unlock(ctx context.Context, target Datastore) error {
-	req := LockReq{
-		XMLName: xml.Name{Local: "unlock"},
-		Target:  target,
+	req := UnlockReq{
+		Target: target,
 	}
 
 	var resp OKResp
@@ -349,6 +405,9 @@ func (s *Session) Get(ctx context.Context,  filter Filter) error {
 type KillSessionReq struct {
 	XMLName   xml.Name `xml:"kill-session"`
 	SessionID uint32   `xml:"session-id"`
+
+	// Extensions are vendor specific elements added to the operation.
+	Extensions []any `xml:",any"`
 }
 
 func (s *Session) KillSession(ctx context.Context, sessionID uint32) error {
@@ -363,6 +422,9 @@ func (s *Session) KillSession(ctx context.Context, sessionID uint32) error {
 type ValidateReq struct {
 	XMLName xml.Name `xml:"validate"`
 	Source  any      `xml:"source"`
+
+	// Extensions are vendor specific elements added to the operation.
+	Extensions []any `xml:",any"`
 }
 
 func (s *Session) Validate(ctx context.Context, source any) error {
@@ -380,6 +442,9 @@ type CommitReq struct {
 	ConfirmTimeout int64      `xml:"confirm-timeout,omitempty"`
 	Persist        string     `xml:"persist,omitempty"`
 	PersistID      string     `xml:"persist-id,omitempty"`
+
+	// Extensions are vendor specific elements added to the operation.
+	Extensions []any `xml:",any"`
 }
 
 // CommitOption is a optional arguments to [Session.Commit] method
@@ -453,11 +518,21 @@ type CancelCommitOption interface {
 	applyCancelCommit(*CancelCommitReq)
 }
 
+// CommitCancelOption is an optional argument to both [Session.Commit] and
+// [Session.CancelCommit].
+type CommitCancelOption interface {
+	CommitOption
+	CancelCommitOption
+}
+
 func (o persistID) applyCancelCommit(req *CancelCommitReq) { req.PersistID = string(o) }
 
 type CancelCommitReq struct {
 	XMLName   xml.Name `xml:"cancel-commit"`
 	PersistID string   `xml:"persist-id,omitempty"`
+
+	// Extensions are vendor specific elements added to the operation.
+	Extensions []any `xml:",any"`
 }
 
 func (s *Session) CancelCommit(ctx context.Context, opts ...CancelCommitOption) error {
@@ -481,34 +556,59 @@ type CreateSubscriptionReq struct {
 	// TODO: Implement filter
 	//Filter    int64    `xml:"filter,omitempty"`
 	StartTime string `xml:"startTime,omitempty"`
-	EndTime   string `xml:"endTime,omitempty"`
+	StopTime  string `xml:"stopTime,omitempty"`
 }
 
 type stream string
 type startTime time.Time
-type endTime time.Time
+type stopTime time.Time
 
 func (o stream) apply(req *CreateSubscriptionReq) {
 	req.Stream = string(o)
 }
 func (o startTime) apply(req *CreateSubscriptionReq) {
-	req.StartTime = time.Time(o).Format(time.RFC3339)
+	req.StartTime = time.Time(o).Format(time.RFC3339Nano)
 }
-func (o endTime) apply(req *CreateSubscriptionReq) {
-	req.EndTime = time.Time(o).Format(time.RFC3339)
+func (o stopTime) apply(req *CreateSubscriptionReq) {
+	req.StopTime = time.Time(o).Format(time.RFC3339Nano)
 }
 
-func WithStreamOption(s string) CreateSubscriptionOption        { return stream(s) }
+func WithStreamOption(s string) CreateSubscriptionOption { return stream(s) }
+
+// WithStartTimeOption starts a replay subscription.  The server first sends
+// the notifications from its replay log logged since st followed by a
+// `<replayComplete>` notification before any new notifications.
 func WithStartTimeOption(st time.Time) CreateSubscriptionOption { return startTime(st) }
-func WithEndTimeOption(et time.Time) CreateSubscriptionOption   { return endTime(et) }
 
+// WithStopTimeOption ends the subscription at et.  The server sends a
+// `<notificationComplete>` notification once et has passed and no more
+// notifications are sent.  A stop time requires a start time and may be in
+// the future.
+func WithStopTimeOption(et time.Time) CreateSubscriptionOption { return stopTime(et) }
+
+// WithEndTimeOption is the same as [WithStopTimeOption].
+//
+// Deprecated: RFC5277 calls this the stop time; use [WithStopTimeOption].
+func WithEndTimeOption(et time.Time) CreateSubscriptionOption { return stopTime(et) }
+
+// CreateSubscription issues a `<create-subscription>` operation to start
+// receiving notifications on the session.  Unless the server supports the
+// `:interleave` capability no other rpcs can be sent on the session afterwards
+// (see [WithInterleavePolicy]).
 func (s *Session) CreateSubscription(ctx context.Context, opts ...CreateSubscriptionOption) error {
 	var req CreateSubscriptionReq
 	for _, opt := range opts {
 		opt.apply(&req)
 	}
+	if req.StopTime != "" && req.StartTime == "" {
+		return fmt.Errorf("StopTime cannot be used without StartTime")
+	}
 	// TODO: eventual custom notifications rpc logic, e.g. create subscription only if notification capability is present
 
 	var resp OKResp
-	return s.Call(ctx, &req, &resp)
+	if err := s.Call(ctx, &req, &resp); err != nil {
+		return err
+	}
+	s.subscribed.Store(true)
+	return nil
 }
@@ -3,6 +3,7 @@ package netconf
 import (
 	"context"
 	"encoding/xml"
+	"io"
 	"regexp"
 	"strconv"
 	"testing"
@@ -46,7 +47,9 @@ func TestMarshalDatastore(t *testing.T) {
 		{Candidate, "<rpc><target><candidate/></target></rpc>", false},
 		{Datastore("custom-store"), "<rpc><target><custom-store/></target></rpc>", false},
 		{Datastore(""), "", true},
-		{Datastore("<xml-elements>"), "<rpc><target><&lt;xml-elements&gt;/></target></rpc>", true},
+		{Datastore("<xml-elements>"), "", true},
+		{Datastore("1st"), "", true},
+		{Datastore("ds:operational"), "<rpc><target><ds:operational/></target></rpc>", false},
 	}
 
 	for _, tc := range tt {
@@ -57,7 +60,9 @@ func TestMarshalDatastore(t *testing.T) {
 			}{Target: tc.input}
 
 			got, err := xml.Marshal(&v)
-			if !tc.shouldErr {
+			if tc.shouldErr {
+				assert.Error(t, err)
+			} else {
 				assert.NoError(t, err)
 			}
 			assert.Equal(t, tc.want, string(got))
@@ -155,6 +160,19 @@ func TestEditConfig(t *testing.T) {
 				regexp.MustCompile(`<url>`),
 			},
 		},
+		{
+			name:   "extensions",
+			target: Candidate,
+			config: intfaceConfig,
+			options: []EditConfigOption{
+				WithEditConfigExtensions(struct {
+					XMLName xml.Name `xml:"load-insert"`
+				}{}),
+			},
+			mustMatch: []*regexp.Regexp{
+				regexp.MustCompile(`</config><load-insert></load-insert></edit-config>`),
+			},
+		},
 		{
 			name:   "byteslice config",
 			target: Running,
@@ -365,6 +383,15 @@ func TestLock(t *testing.T) {
 	}
 }
 
+func TestLockNamespace(t *testing.T) {
+	// the operations must stay in the namespace of the `<rpc>`.
+	for _, op := range []any{&LockReq{Target: Running}, &UnlockReq{Target: Running}} {
+		raw, err := xml.Marshal(&request{MessageID: "1", Operation: op})
+		assert.NoError(t, err)
+		assert.NotContains(t, string(raw), `xmlns=""`)
+	}
+}
+
 func TestUnlock(t *testing.T) {
 	tt := []struct {
 		target  Datastore
@@ -473,6 +500,13 @@ func TestCommit(t *testing.T) {
 				regexp.MustCompile(`<commit><persist-id>myid</persist-id></commit>`),
 			},
 		},
+		{
+			name:    "extensions",
+			options: []CommitOption{WithConfirmed(), WithCommitExtensions(XMLFragment(`<synchronize/><log xmlns="urn:vendor">ticket 1</log>`))},
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`<commit><confirmed></confirmed><synchronize></synchronize><log xmlns="urn:vendor">ticket 1</log></commit>`),
+			},
+		},
 	}
 
 	for _, tc := range tt {
@@ -515,6 +549,13 @@ func TestCancelCommit(t *testing.T) {
 				regexp.MustCompile(`<cancel-commit><persist-id>myid</persist-id></cancel-commit>`),
 			},
 		},
+		{
+			name:    "extensions",
+			options: []CancelCommitOption{WithCommitExtensions(XMLFragment(`<synchronize/>`))},
+			matches: []*regexp.Regexp{
+				regexp.MustCompile(`<cancel-commit><synchronize></synchronize></cancel-commit>`),
+			},
+		},
 	}
 
 	for _, tc := range tt {
@@ -561,10 +602,10 @@ func TestCreateSubscription(t *testing.T) {
 			},
 		},
 		{
-			name:    "endTime option",
-			options: []CreateSubscriptionOption{WithEndTimeOption(end)},
+			name:    "stopTime option",
+			options: []CreateSubscriptionOption{WithStartTimeOption(start), WithStopTimeOption(end)},
 			matches: []*regexp.Regexp{
-				regexp.MustCompile(`<create-subscription xmlns="urn:ietf:params:xml:ns:netconf:notification:1.0"><endTime>` + regexp.QuoteMeta(end.Format(time.RFC3339)) + `</endTime></create-subscription>`),
+				regexp.MustCompile(`<create-subscription xmlns="urn:ietf:params:xml:ns:netconf:notification:1.0"><startTime>` + regexp.QuoteMeta(start.Format(time.RFC3339)) + `</startTime><stopTime>` + regexp.QuoteMeta(end.Format(time.RFC3339)) + `</stopTime></create-subscription>`),
 			},
 		},
 		{
@@ -596,3 +637,31 @@ func TestCreateSubscription(t *testing.T) {
 		})
 	}
 }
+
+func TestCreateSubscriptionStopWithoutStart(t *testing.T) {
+	ts := newTestServer(t)
+	sess := newSession(ts.transport())
+
+	err := sess.CreateSubscription(context.Background(), WithStopTimeOption(time.Now()))
+	assert.ErrorContains(t, err, "without StartTime")
+	assert.False(t, sess.Subscribed())
+}
+
+func BenchmarkMarshalDatastore(b *testing.B) {
+	for _, ds := range []Datastore{Running, Datastore("custom-store")} {
+		b.Run(string(ds), func(b *testing.B) {
+			v := struct {
+				XMLName xml.Name  `xml:"lock"`
+				Target  Datastore `xml:"target"`
+			}{Target: ds}
+
+			e := xml.NewEncoder(io.Discard)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if err := e.Encode(&v); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package netconf
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nemith/netconf/transport"
+)
+
+// Ping checks that the session is still usable without sending an rpc.  It
+// returns [ErrClosed] if the session has been closed and, if the transport
+// implements [transport.Pinger] (i.e ssh and tls), checks the connection to
+// the device.  Transports that can't be pinged only have the session state
+// checked.
+//
+// Ping is cheap enough to use as the health check of a [Pool]:
+//
+//	netconf.WithPoolHealthCheck(func(ctx context.Context, s *netconf.Session) error {
+//		return s.Ping(ctx)
+//	})
+func (s *Session) Ping(ctx context.Context) error {
+	select {
+	case <-s.done:
+		return ErrClosed
+	default:
+	}
+
+	s.mu.Lock()
+	closing := s.closing
+	s.mu.Unlock()
+	if closing {
+		return ErrClosed
+	}
+
+	pinger, ok := s.tr.(transport.Pinger)
+	if !ok {
+		return nil
+	}
+	if err := pinger.Ping(ctx); err != nil {
+		return fmt.Errorf("ping failed: %w", err)
+	}
+	return nil
+}
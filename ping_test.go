@@ -0,0 +1,46 @@
+package netconf
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/nemith/netconf/transport"
+	"github.com/stretchr/testify/assert"
+)
+
+type pingTransport struct {
+	*testTransport
+	err error
+}
+
+func (t *pingTransport) Ping(ctx context.Context) error { return t.err }
+
+func TestPing(t *testing.T) {
+	errDead := errors.New("connection reset")
+
+	tr := &pingTransport{testTransport: newTestServer(t).transport()}
+	sess := newSession(tr)
+	go sess.recv()
+
+	assert.NoError(t, sess.Ping(context.Background()))
+
+	tr.err = errDead
+	err := sess.Ping(context.Background())
+	assert.ErrorIs(t, err, errDead)
+	assert.ErrorContains(t, err, "ping failed")
+}
+
+func TestPingNoPinger(t *testing.T) {
+	client, server := net.Pipe()
+
+	// net.Pipe can't be probed so only the session state is checked.
+	sess := newSession(transport.NewPipeTransport(client))
+	go sess.recv()
+	assert.NoError(t, sess.Ping(context.Background()))
+
+	server.Close()
+	<-sess.Done()
+	assert.ErrorIs(t, sess.Ping(context.Background()), ErrClosed)
+}
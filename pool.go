@@ -0,0 +1,401 @@
+package netconf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nemith/netconf/clock"
+	"github.com/nemith/netconf/transport"
+)
+
+// ErrPoolClosed is returned when getting a session from a closed [Pool].
+var ErrPoolClosed = errors.New("netconf: pool closed")
+
+// DialFunc opens a new session to the device at addr.  It is used by a [Pool]
+// to create sessions.
+type DialFunc func(ctx context.Context, addr string) (*Session, error)
+
+// HealthCheck checks that an idle session is still usable before it is handed
+// out by a [Pool].
+type HealthCheck func(ctx context.Context, s *Session) error
+
+// PoolOption is an optional argument to [NewPool].
+type PoolOption interface {
+	apply(*poolConfig)
+}
+
+type poolConfig struct {
+	maxIdle     int
+	idleTimeout time.Duration
+	healthCheck HealthCheck
+	clock       clock.Clock
+	resolver    transport.Resolver
+	eventBus    *EventBus
+}
+
+type poolMaxIdleOpt int
+
+func (o poolMaxIdleOpt) apply(cfg *poolConfig) { cfg.maxIdle = int(o) }
+
+// WithPoolMaxIdle sets the maximum number of idle sessions kept per device.
+// Sessions returned to a pool that already has this many idle sessions for the
+// device are closed.  The default is 2.
+func WithPoolMaxIdle(n int) PoolOption { return poolMaxIdleOpt(n) }
+
+type poolIdleTimeoutOpt time.Duration
+
+func (o poolIdleTimeoutOpt) apply(cfg *poolConfig) { cfg.idleTimeout = time.Duration(o) }
+
+// WithPoolIdleTimeout closes sessions that have been idle for longer than d.
+// Expired sessions are closed in the background until the pool is closed so
+// they don't linger on devices that aren't used anymore.  Many devices drop
+// idle sessions after a while.  A timeout of 0 (the default) keeps idle
+// sessions forever.
+func WithPoolIdleTimeout(d time.Duration) PoolOption { return poolIdleTimeoutOpt(d) }
+
+type poolHealthCheckOpt HealthCheck
+
+func (o poolHealthCheckOpt) apply(cfg *poolConfig) { cfg.healthCheck = HealthCheck(o) }
+
+// WithPoolHealthCheck runs a check on idle sessions before they are handed out.
+// Sessions failing the check are closed and another session is used.
+// Sessions whose connection has closed are always discarded.  [Session.Ping]
+// checks the connection without the cost of an rpc.
+func WithPoolHealthCheck(fn HealthCheck) PoolOption { return poolHealthCheckOpt(fn) }
+
+type poolClockOpt struct{ c clock.Clock }
+
+func (o poolClockOpt) apply(cfg *poolConfig) { cfg.clock = o.c }
+
+// WithPoolClock sets the clock used to expire idle sessions.  It defaults to
+// the system clock.
+func WithPoolClock(c clock.Clock) PoolOption { return poolClockOpt{c} }
+
+type poolResolverOpt struct{ r transport.Resolver }
+
+func (o poolResolverOpt) apply(cfg *poolConfig) { cfg.resolver = o.r }
+
+// WithPoolResolver resolves the address given to [Pool.Get] with r before
+// dialing.  The resolved addresses are dialed in order until one succeeds
+// while sessions are still pooled by the address given to Get, so a device
+// moving between management addresses keeps its idle sessions.
+func WithPoolResolver(r transport.Resolver) PoolOption { return poolResolverOpt{r} }
+
+type poolEventBusOpt struct{ b *EventBus }
+
+func (o poolEventBusOpt) apply(cfg *poolConfig) { cfg.eventBus = o.b }
+
+// WithPoolEventBus publishes an [EventConnected] and [EventDisconnected] to b
+// for every session the pool dials.  Event.Detail is the address the session
+// is pooled by.
+func WithPoolEventBus(b *EventBus) PoolOption { return poolEventBusOpt{b} }
+
+// Pool maintains sessions to many devices keyed by address.  Sessions are
+// dialed lazily when there is no idle session for a device and are returned to
+// the pool with [Pool.Put] to be reused.
+//
+// A session checked out from the pool is used by a single caller at a time.
+// Since sessions support concurrent rpcs, sharing a single session per device
+// is also possible by keeping sessions checked out.
+type Pool struct {
+	dial DialFunc
+	cfg  poolConfig
+
+	mu      sync.Mutex
+	idle    map[string][]idleSession
+	active  map[*Session]string
+	closed  bool
+	closeWG sync.WaitGroup
+
+	// stopReap is closed on Close to stop the reaper and reaped is closed
+	// once it has stopped.  Both are nil without an idle timeout.
+	stopReap chan struct{}
+	reaped   chan struct{}
+}
+
+type idleSession struct {
+	sess  *Session
+	since time.Time
+}
+
+// NewPool returns a pool that opens sessions with dial.
+func NewPool(dial DialFunc, opts ...PoolOption) *Pool {
+	cfg := poolConfig{
+		maxIdle: 2,
+	}
+	for _, opt := range opts {
+		opt.apply(&cfg)
+	}
+	cfg.clock = clock.Or(cfg.clock)
+
+	p := &Pool{
+		dial:   dial,
+		cfg:    cfg,
+		idle:   make(map[string][]idleSession),
+		active: make(map[*Session]string),
+	}
+	if cfg.idleTimeout > 0 {
+		p.stopReap = make(chan struct{})
+		p.reaped = make(chan struct{})
+		go p.reap()
+	}
+	return p
+}
+
+// reap closes idle sessions as they expire until the pool is closed.
+func (p *Pool) reap() {
+	defer close(p.reaped)
+
+	t := p.cfg.clock.NewTimer(p.cfg.idleTimeout)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C():
+		case <-p.stopReap:
+			return
+		}
+		t.Reset(p.reapIdle())
+	}
+}
+
+// reapIdle closes the idle sessions that are closed or expired and returns
+// how long until the next idle session expires.
+func (p *Pool) reapIdle() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	next := p.cfg.idleTimeout
+	for addr, sessions := range p.idle {
+		kept := sessions[:0]
+		for _, is := range sessions {
+			if !p.usable(is) {
+				p.closeSession(is.sess)
+				continue
+			}
+			kept = append(kept, is)
+			if d := p.cfg.idleTimeout - clock.Since(p.cfg.clock, is.since); d < next {
+				next = d
+			}
+		}
+		if len(kept) == 0 {
+			delete(p.idle, addr)
+			continue
+		}
+		p.idle[addr] = kept
+	}
+	return next
+}
+
+// Get checks out a session to the device at addr.  An idle session is reused
+// if there is a healthy one otherwise a new session is dialed.  The session
+// must be returned with [Pool.Put] once it is no longer needed.
+func (p *Pool) Get(ctx context.Context, addr string) (*Session, error) {
+	for {
+		sess, ok, err := p.popIdle(addr)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+
+		if p.cfg.healthCheck != nil {
+			if err := p.cfg.healthCheck(ctx, sess); err != nil {
+				p.Discard(sess)
+				continue
+			}
+		}
+		return sess, nil
+	}
+
+	sess, err := p.dialAddr(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	publishLifecycle(p.cfg.eventBus, sess, addr)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		p.closeSession(sess)
+		return nil, ErrPoolClosed
+	}
+	p.active[sess] = addr
+	return sess, nil
+}
+
+// dialAddr dials addr or, with a resolver, the addresses it resolves to.
+func (p *Pool) dialAddr(ctx context.Context, addr string) (*Session, error) {
+	if p.cfg.resolver == nil {
+		sess, err := p.dial(ctx, addr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to dial %q: %w", addr, err)
+		}
+		return sess, nil
+	}
+
+	addrs, err := p.cfg.resolver.Resolve(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %q: %w", addr, err)
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("failed to dial %q: no addresses to dial", addr)
+	}
+
+	var errs []error
+	for _, a := range addrs {
+		sess, err := p.dial(ctx, a)
+		if err == nil {
+			return sess, nil
+		}
+		errs = append(errs, fmt.Errorf("failed to dial %q: %w", a, err))
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, errors.Join(errs...)
+}
+
+// popIdle takes the most recently used idle session for addr discarding any
+// that are closed or expired.
+func (p *Pool) popIdle(addr string) (*Session, bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return nil, false, ErrPoolClosed
+	}
+
+	idle := p.idle[addr]
+	for len(idle) > 0 {
+		is := idle[len(idle)-1]
+		idle = idle[:len(idle)-1]
+
+		if !p.usable(is) {
+			p.closeSession(is.sess)
+			continue
+		}
+
+		p.idle[addr] = idle
+		p.active[is.sess] = addr
+		return is.sess, true, nil
+	}
+	delete(p.idle, addr)
+	return nil, false, nil
+}
+
+func (p *Pool) usable(is idleSession) bool {
+	select {
+	case <-is.sess.Done():
+		return false
+	default:
+	}
+	return p.cfg.idleTimeout <= 0 || clock.Since(p.cfg.clock, is.since) < p.cfg.idleTimeout
+}
+
+// Put returns a session checked out with [Pool.Get] to the pool.  Sessions
+// that are closed or over the idle limit are closed.  Sessions not from this
+// pool are ignored.
+func (p *Pool) Put(sess *Session) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	addr, ok := p.active[sess]
+	if !ok {
+		return
+	}
+	delete(p.active, sess)
+
+	is := idleSession{sess: sess, since: p.cfg.clock.Now()}
+	if p.closed || len(p.idle[addr]) >= p.cfg.maxIdle || !p.usable(is) {
+		p.closeSession(sess)
+		return
+	}
+	p.idle[addr] = append(p.idle[addr], is)
+}
+
+// Discard closes a session checked out with [Pool.Get] instead of returning
+// it to the pool.  Use this for sessions that are known to be broken.
+func (p *Pool) Discard(sess *Session) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.active[sess]; ok {
+		delete(p.active, sess)
+		p.closeSession(sess)
+	}
+}
+
+// Do checks out a session to addr, calls fn with it and returns it to the
+// pool.  If fn returns an error wrapping [ErrClosed] the session is discarded.
+func (p *Pool) Do(ctx context.Context, addr string, fn func(*Session) error) error {
+	sess, err := p.Get(ctx, addr)
+	if err != nil {
+		return err
+	}
+
+	err = fn(sess)
+	if errors.Is(err, ErrClosed) {
+		p.Discard(sess)
+	} else {
+		p.Put(sess)
+	}
+	return err
+}
+
+// Len returns the number of idle and checked out sessions in the pool.
+func (p *Pool) Len() (idle, active int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, sessions := range p.idle {
+		idle += len(sessions)
+	}
+	return idle, len(p.active)
+}
+
+// Close closes all idle sessions and stops expiring them.  Checked out
+// sessions are closed when they are returned.  Close waits for the idle
+// sessions to be closed.
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	if !p.closed && p.stopReap != nil {
+		close(p.stopReap)
+	}
+	for addr, sessions := range p.idle {
+		for _, is := range sessions {
+			p.closeSession(is.sess)
+		}
+		delete(p.idle, addr)
+	}
+	// sessions closed from now on aren't waited for.
+	p.closed = true
+	p.mu.Unlock()
+
+	if p.reaped != nil {
+		<-p.reaped
+	}
+	p.closeWG.Wait()
+	return nil
+}
+
+// closeSession closes a session in the background so a slow or dead device
+// doesn't hold up the pool.  Close only waits for the sessions closed before
+// the pool is.  Must be called with p.mu held.
+func (p *Pool) closeSession(sess *Session) {
+	wait := !p.closed
+	if wait {
+		p.closeWG.Add(1)
+	}
+	go func() {
+		if wait {
+			defer p.closeWG.Done()
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = sess.Close(ctx)
+	}()
+}
@@ -0,0 +1,293 @@
+package netconf
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nemith/netconf/clock"
+	"github.com/nemith/netconf/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var poolMsgIDRe = regexp.MustCompile(`message-id="([^"]+)"`)
+
+// okTransport answers every rpc with `<ok/>` and closes on `<close-session>`.
+type okTransport struct {
+	in        chan string
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newOKSession() *Session {
+	tr := &okTransport{in: make(chan string, 1), done: make(chan struct{})}
+	sess := newSession(tr, WithErrorHandler(func(error) {}))
+	go sess.recv()
+	return sess
+}
+
+func (t *okTransport) MsgReader() (io.ReadCloser, error) {
+	select {
+	case msg := <-t.in:
+		return io.NopCloser(strings.NewReader(msg)), nil
+	case <-t.done:
+		return nil, io.EOF
+	}
+}
+
+func (t *okTransport) MsgWriter() (io.WriteCloser, error) {
+	select {
+	case <-t.done:
+		return nil, io.ErrClosedPipe
+	default:
+	}
+	return &okWriter{t: t}, nil
+}
+
+func (t *okTransport) Close() error {
+	t.closeOnce.Do(func() { close(t.done) })
+	return nil
+}
+
+type okWriter struct {
+	t   *okTransport
+	buf bytes.Buffer
+}
+
+func (w *okWriter) Write(p []byte) (int, error) { return w.buf.Write(p) }
+
+func (w *okWriter) Close() error {
+	id := poolMsgIDRe.FindStringSubmatch(w.buf.String())[1]
+	w.t.in <- `<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="` + id + `"><ok/></rpc-reply>`
+	return nil
+}
+
+func TestPool(t *testing.T) {
+	var dials atomic.Int32
+	p := NewPool(func(ctx context.Context, addr string) (*Session, error) {
+		dials.Add(1)
+		return newOKSession(), nil
+	}, WithPoolMaxIdle(1))
+	ctx := context.Background()
+
+	s1, err := p.Get(ctx, "r1")
+	require.NoError(t, err)
+	s2, err := p.Get(ctx, "r1")
+	require.NoError(t, err)
+	assert.NotSame(t, s1, s2)
+
+	idle, active := p.Len()
+	assert.Equal(t, 0, idle)
+	assert.Equal(t, 2, active)
+
+	// only one session is kept idle
+	p.Put(s1)
+	p.Put(s2)
+	idle, active = p.Len()
+	assert.Equal(t, 1, idle)
+	assert.Equal(t, 0, active)
+
+	s3, err := p.Get(ctx, "r1")
+	require.NoError(t, err)
+	assert.Same(t, s1, s3)
+	assert.Equal(t, int32(2), dials.Load())
+
+	// sessions are keyed by address
+	s4, err := p.Get(ctx, "r2")
+	require.NoError(t, err)
+	assert.NotSame(t, s3, s4)
+	p.Put(s4)
+
+	require.NoError(t, p.Close())
+	<-s4.Done()
+
+	_, err = p.Get(ctx, "r1")
+	assert.ErrorIs(t, err, ErrPoolClosed)
+
+	// sessions checked out when closing are closed on return
+	p.Put(s3)
+	p.closeWG.Wait()
+	<-s3.Done()
+}
+
+func TestPoolHealth(t *testing.T) {
+	var checks atomic.Int32
+	clk := clock.NewFake(time.Now())
+	p := NewPool(func(ctx context.Context, addr string) (*Session, error) {
+		return newOKSession(), nil
+	},
+		WithPoolIdleTimeout(time.Hour),
+		WithPoolClock(clk),
+		WithPoolHealthCheck(func(ctx context.Context, s *Session) error {
+			if checks.Add(1) == 1 {
+				return errors.New("unhealthy")
+			}
+			return nil
+		}))
+	defer p.Close()
+	ctx := context.Background()
+
+	s1, err := p.Get(ctx, "r1")
+	require.NoError(t, err)
+	p.Put(s1)
+
+	// failed health check
+	s2, err := p.Get(ctx, "r1")
+	require.NoError(t, err)
+	assert.NotSame(t, s1, s2)
+	p.Put(s2)
+
+	s3, err := p.Get(ctx, "r1")
+	require.NoError(t, err)
+	assert.Same(t, s2, s3)
+
+	// closed sessions are not reused
+	require.NoError(t, s3.Close(ctx))
+	<-s3.Done()
+	p.Put(s3)
+	idle, _ := p.Len()
+	assert.Equal(t, 0, idle)
+
+	// expired sessions are not reused
+	s4, err := p.Get(ctx, "r1")
+	require.NoError(t, err)
+	p.Put(s4)
+	clk.Advance(2 * time.Hour)
+
+	s5, err := p.Get(ctx, "r1")
+	require.NoError(t, err)
+	assert.NotSame(t, s4, s5)
+}
+
+func TestPoolReapIdle(t *testing.T) {
+	clk := clock.NewFake(time.Now())
+	p := NewPool(func(ctx context.Context, addr string) (*Session, error) {
+		return newOKSession(), nil
+	},
+		WithPoolIdleTimeout(time.Hour),
+		WithPoolClock(clk))
+	ctx := context.Background()
+
+	s1, err := p.Get(ctx, "r1")
+	require.NoError(t, err)
+	p.Put(s1)
+
+	// expired without another Get or Put.
+	clk.BlockUntil(1)
+	clk.Advance(2 * time.Hour)
+	select {
+	case <-s1.Done():
+	case <-time.After(time.Second):
+		t.Fatal("idle session not closed after the idle timeout")
+	}
+	idle, _ := p.Len()
+	assert.Equal(t, 0, idle)
+
+	// the reaper is stopped on close.
+	require.NoError(t, p.Close())
+	assert.Equal(t, 0, clk.Timers())
+}
+
+func TestPoolDo(t *testing.T) {
+	p := NewPool(func(ctx context.Context, addr string) (*Session, error) {
+		return newOKSession(), nil
+	})
+	defer p.Close()
+
+	var used *Session
+	err := p.Do(context.Background(), "r1", func(s *Session) error {
+		used = s
+		return s.Commit(context.Background())
+	})
+	require.NoError(t, err)
+
+	err = p.Do(context.Background(), "r1", func(s *Session) error {
+		assert.Same(t, used, s)
+		return ErrClosed
+	})
+	assert.ErrorIs(t, err, ErrClosed)
+
+	idle, active := p.Len()
+	assert.Equal(t, 0, idle)
+	assert.Equal(t, 0, active)
+}
+
+func TestPoolCloseConcurrent(t *testing.T) {
+	p := NewPool(func(ctx context.Context, addr string) (*Session, error) {
+		return newOKSession(), nil
+	}, WithPoolMaxIdle(0))
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				sess, err := p.Get(ctx, "r1")
+				if err != nil {
+					return
+				}
+				p.Put(sess)
+			}
+		}()
+	}
+	require.NoError(t, p.Close())
+	wg.Wait()
+}
+
+func TestPoolResolver(t *testing.T) {
+	var dialed []string
+	p := NewPool(func(ctx context.Context, addr string) (*Session, error) {
+		dialed = append(dialed, addr)
+		if addr == "192.0.2.1" {
+			return nil, errors.New("connection refused")
+		}
+		return newOKSession(), nil
+	}, WithPoolResolver(transport.ResolverFunc(func(ctx context.Context, network, addr string) ([]string, error) {
+		return []string{"192.0.2.1", "192.0.2.2"}, nil
+	})))
+	defer p.Close()
+
+	sess, err := p.Get(context.Background(), "r1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"192.0.2.1", "192.0.2.2"}, dialed)
+
+	// sessions are pooled by the address given to Get.
+	p.Put(sess)
+	again, err := p.Get(context.Background(), "r1")
+	require.NoError(t, err)
+	assert.Same(t, sess, again)
+}
+
+func TestPoolEvents(t *testing.T) {
+	var bus EventBus
+	events := make(chan Event, 10)
+	bus.Subscribe(func(ev Event) { events <- ev })
+
+	p := NewPool(func(ctx context.Context, addr string) (*Session, error) {
+		return newOKSession(), nil
+	}, WithPoolEventBus(&bus))
+	defer p.Close()
+
+	sess, err := p.Get(context.Background(), "r1")
+	require.NoError(t, err)
+	ev := <-events
+	assert.Equal(t, EventConnected, ev.Type)
+	assert.Same(t, sess, ev.Session)
+	assert.Equal(t, "r1", ev.Detail)
+
+	p.Discard(sess)
+	ev = <-events
+	assert.Equal(t, EventDisconnected, ev.Type)
+	assert.Same(t, sess, ev.Session)
+	assert.NoError(t, ev.Err)
+}
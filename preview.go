@@ -0,0 +1,367 @@
+package netconf
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/nemith/netconf/xmltree"
+)
+
+// ChangePreview is the result of [Session.PreviewChange].
+type ChangePreview struct {
+	// Request is the rendered `<edit-config>` operation that would be sent to
+	// the device.
+	Request []byte
+
+	// Before is the configuration of the target datastore as returned by the
+	// device and After is the projected configuration after the edit has been
+	// applied.
+	Before []byte
+	After  []byte
+
+	// Diff is a unified diff between Before and After (with `before` and
+	// `after` as the file names) that can be read or given to tools like
+	// patch.  It is empty if the edit would not change the configuration.
+	Diff string
+
+	// Changes are the differences between Before and After as a list of
+	// added, removed and modified elements (see [xmltree.Diff]).
+	Changes xmltree.Changes
+
+	// Validated is true if the projected configuration was sent to the
+	// device in a `<validate>` operation.  ValidateErr contains any errors
+	// the device returned for it.
+	Validated   bool
+	ValidateErr error
+}
+
+// Changed reports if the edit would modify the configuration.
+func (p *ChangePreview) Changed() bool { return p.Diff != "" }
+
+// PreviewChange shows what a [Session.EditConfig] call would do without
+// modifying the device.  It renders the `<edit-config>` operation, retrieves
+// the current configuration of the target datastore, applies the edit locally
+// and returns a diff of the configuration.
+//
+// If the device supports the `:validate` capability the projected
+// configuration is also validated on the device and the result is available
+// in [ChangePreview.ValidateErr].
+//
+// The projected configuration is computed without the device's schema so list
+// entries are matched using [xmltree.NameKey].  It is a preview and not a
+// guarantee of the result on the device.
+func (s *Session) PreviewChange(ctx context.Context, target Datastore, config any, opts ...EditConfigOption) (*ChangePreview, error) {
+	req := newEditConfigReq(target, config, opts...)
+	if req.URL != "" {
+		return nil, fmt.Errorf("cannot preview a config loaded from a url")
+	}
+
+	rendered, err := xml.MarshalIndent(&req, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to render edit-config: %w", err)
+	}
+
+	edit, err := editConfigNodes(rendered)
+	if err != nil {
+		return nil, err
+	}
+
+	beforeRaw, err := s.GetConfig(ctx, target)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current config: %w", err)
+	}
+
+	before, err := xmltree.Parse(beforeRaw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse current config: %w", err)
+	}
+
+	after, err := xmltree.Edit(before, edit, string(req.DefaultMergeStrategy), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	preview := ChangePreview{Request: rendered}
+	if preview.Before, err = xmltree.Marshal(before, "  "); err != nil {
+		return nil, err
+	}
+	if preview.After, err = xmltree.Marshal(after, "  "); err != nil {
+		return nil, err
+	}
+	preview.Diff = lineDiff(string(preview.Before), string(preview.After))
+	preview.Changes = xmltree.Diff(before, after, nil)
+
+	if s.serverCaps.Has(":validate:1.0") || s.serverCaps.Has(":validate:1.1") {
+		var src struct {
+			Config struct {
+				Nodes []*xmltree.Node `xml:",any"`
+			} `xml:"config"`
+		}
+		src.Config.Nodes = after
+
+		err := s.Validate(ctx, &src)
+		var rpcErr RPCError
+		var rpcErrs RPCErrors
+		switch {
+		case err == nil:
+		case errors.As(err, &rpcErr), errors.As(err, &rpcErrs):
+			preview.ValidateErr = err
+		default:
+			return nil, fmt.Errorf("failed to validate config: %w", err)
+		}
+		preview.Validated = true
+	}
+
+	return &preview, nil
+}
+
+// editConfigNodes returns the contents of the `<config>` element from a
+// rendered `<edit-config>`.
+func editConfigNodes(rendered []byte) ([]*xmltree.Node, error) {
+	nodes, err := xmltree.Parse(rendered)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse rendered edit-config: %w", err)
+	}
+	if len(nodes) != 1 {
+		return nil, fmt.Errorf("unexpected rendered edit-config")
+	}
+
+	cfg := nodes[0].Child("config")
+	if cfg == nil {
+		return nil, nil
+	}
+	return cfg.Children, nil
+}
+
+// lineDiff returns a unified diff between a and b using the Myers diff
+// algorithm.  Returns an empty string when there are no differences.
+func lineDiff(a, b string) string {
+	ops := myersDiff(splitLines(a), splitLines(b))
+
+	const context = 3
+	var out bytes.Buffer
+	for i := 0; i < len(ops); {
+		// skip to the next change
+		if ops[i].kind == ' ' {
+			i++
+			continue
+		}
+
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+
+		// extend the hunk until there are more than 2*context unchanged lines
+		end := i
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			run := end
+			for run < len(ops) && ops[run].kind == ' ' {
+				run++
+			}
+			if run == len(ops) || run-end > 2*context {
+				end += context
+				if end > len(ops) {
+					end = len(ops)
+				}
+				break
+			}
+			end = run
+		}
+
+		if out.Len() == 0 {
+			out.WriteString("--- before\n+++ after\n")
+		}
+
+		var aLines, bLines int
+		for _, op := range ops[start:end] {
+			if op.kind != '+' {
+				aLines++
+			}
+			if op.kind != '-' {
+				bLines++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(ops[start].x, aLines), hunkRange(ops[start].y, bLines))
+		for _, op := range ops[start:end] {
+			out.WriteByte(op.kind)
+			out.WriteString(op.line)
+			out.WriteByte('\n')
+		}
+		i = end
+	}
+	return out.String()
+}
+
+// hunkRange formats the range of a hunk starting at the 0-based line start.
+// An empty range refers to the line before it.
+func hunkRange(start, lines int) string {
+	if lines == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	return fmt.Sprintf("%d,%d", start+1, lines)
+}
+
+func splitLines(s string) []string {
+	s = strings.TrimSuffix(s, "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+type diffOp struct {
+	kind byte // ' ', '-', '+'
+	line string
+	x, y int // line index in each input
+}
+
+// myersDiff returns the edit script turning a into b.  It uses the linear
+// space variant of the Myers algorithm: the middle of an optimal path is
+// found by searching from both ends at once and each half is diffed
+// recursively so memory stays proportional to the size of the inputs.
+func myersDiff(a, b []string) []diffOp {
+	d := differ{a: a, b: b}
+	d.diff(0, len(a), 0, len(b))
+	return d.ops
+}
+
+type differ struct {
+	a, b []string
+	ops  []diffOp
+}
+
+func (d *differ) op(kind byte, x, y int) {
+	line := ""
+	if kind == '+' {
+		line = d.b[y]
+	} else {
+		line = d.a[x]
+	}
+	d.ops = append(d.ops, diffOp{kind, line, x, y})
+}
+
+// diff adds the edit script turning a[aLo:aHi] into b[bLo:bHi].
+func (d *differ) diff(aLo, aHi, bLo, bHi int) {
+	for aLo < aHi && bLo < bHi && d.a[aLo] == d.b[bLo] {
+		d.op(' ', aLo, bLo)
+		aLo++
+		bLo++
+	}
+	suffix := 0
+	for aHi-suffix > aLo && bHi-suffix > bLo && d.a[aHi-suffix-1] == d.b[bHi-suffix-1] {
+		suffix++
+	}
+	aEnd, bEnd := aHi-suffix, bHi-suffix
+
+	if aLo < aEnd && bLo < bEnd {
+		if x, y, ok := d.bisect(aLo, aEnd, bLo, bEnd); ok {
+			d.diff(aLo, x, bLo, y)
+			d.diff(x, aEnd, y, bEnd)
+			aLo, bLo = aEnd, bEnd
+		}
+	}
+	for ; aLo < aEnd; aLo++ {
+		d.op('-', aLo, bLo)
+	}
+	for ; bLo < bEnd; bLo++ {
+		d.op('+', aLo, bLo)
+	}
+
+	for i := 0; i < suffix; i++ {
+		d.op(' ', aEnd+i, bEnd+i)
+	}
+}
+
+// bisect finds the point where the forward and reverse searches for the
+// shortest edit script of a[aLo:aHi] and b[bLo:bHi] meet.  It returns false if
+// the inputs have nothing in common.
+func (d *differ) bisect(aLo, aHi, bLo, bHi int) (x, y int, ok bool) {
+	a, b := d.a[aLo:aHi], d.b[bLo:bHi]
+	n, m := len(a), len(b)
+
+	maxD := (n + m + 1) / 2
+	offset := maxD
+	v1 := make([]int, 2*maxD+2)
+	v2 := make([]int, 2*maxD+2)
+	for i := range v1 {
+		v1[i], v2[i] = -1, -1
+	}
+	v1[offset+1], v2[offset+1] = 0, 0
+
+	delta := n - m
+	// if the total number of lines is odd the paths meet on the forward
+	// search, otherwise on the reverse one.
+	front := delta%2 != 0
+
+	// trim the diagonals that ran off the edit graph.
+	var k1start, k1end, k2start, k2end int
+	for e := 0; e < maxD; e++ {
+		for k1 := -e + k1start; k1 <= e-k1end; k1 += 2 {
+			var x1 int
+			if k1 == -e || (k1 != e && v1[offset+k1-1] < v1[offset+k1+1]) {
+				x1 = v1[offset+k1+1]
+			} else {
+				x1 = v1[offset+k1-1] + 1
+			}
+			y1 := x1 - k1
+			for x1 < n && y1 < m && a[x1] == b[y1] {
+				x1++
+				y1++
+			}
+			v1[offset+k1] = x1
+
+			switch {
+			case x1 > n:
+				k1end += 2
+			case y1 > m:
+				k1start += 2
+			case front:
+				k2 := offset + delta - k1
+				if k2 >= 0 && k2 < len(v2) && v2[k2] != -1 && x1 >= n-v2[k2] {
+					return aLo + x1, bLo + y1, true
+				}
+			}
+		}
+
+		for k2 := -e + k2start; k2 <= e-k2end; k2 += 2 {
+			var x2 int
+			if k2 == -e || (k2 != e && v2[offset+k2-1] < v2[offset+k2+1]) {
+				x2 = v2[offset+k2+1]
+			} else {
+				x2 = v2[offset+k2-1] + 1
+			}
+			y2 := x2 - k2
+			for x2 < n && y2 < m && a[n-x2-1] == b[m-y2-1] {
+				x2++
+				y2++
+			}
+			v2[offset+k2] = x2
+
+			switch {
+			case x2 > n:
+				k2end += 2
+			case y2 > m:
+				k2start += 2
+			case !front:
+				k1 := offset + delta - k2
+				if k1 >= 0 && k1 < len(v1) && v1[k1] != -1 {
+					x1 := v1[k1]
+					y1 := offset + x1 - k1
+					if x1 >= n-x2 {
+						return aLo + x1, bLo + y1, true
+					}
+				}
+			}
+		}
+	}
+	return 0, 0, false
+}
@@ -0,0 +1,148 @@
+package netconf
+
+import (
+	"context"
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreviewChange(t *testing.T) {
+	ts := newTestServer(t)
+	sess := newSession(ts.transport())
+	sess.serverCaps = NewCapabilitySet(":validate:1.1")
+	go sess.recv()
+
+	type result struct {
+		preview *ChangePreview
+		err     error
+	}
+	done := make(chan result)
+	go func() {
+		p, err := sess.PreviewChange(context.Background(), Running,
+			`<system><host-name>lightstar</host-name></system>`)
+		done <- result{p, err}
+	}()
+
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><data><system><host-name>darkstar</host-name><domain>example.com</domain></system></data></rpc-reply>`)
+	getReq, err := ts.popReqString()
+	require.NoError(t, err)
+
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="2"><ok/></rpc-reply>`)
+	validateReq, err := ts.popReqString()
+	require.NoError(t, err)
+
+	// the test server doesn't guarantee the order of received requests
+	reqs := getReq + validateReq
+	assert.Contains(t, reqs, "<get-config>")
+	assert.Contains(t, reqs, "<validate><source><config><system><host-name>lightstar</host-name><domain>example.com</domain></system></config></source></validate>")
+
+	res := <-done
+	require.NoError(t, res.err)
+	p := res.preview
+
+	assert.Contains(t, string(p.Request), "<edit-config>")
+	assert.True(t, p.Changed())
+	assert.True(t, p.Validated)
+	assert.NoError(t, p.ValidateErr)
+	assert.Equal(t, `--- before
++++ after
+@@ -1,4 +1,4 @@
+ <system>
+-  <host-name>darkstar</host-name>
++  <host-name>lightstar</host-name>
+   <domain>example.com</domain>
+ </system>
+`, p.Diff)
+	assert.Equal(t, "~ /system/host-name: \"darkstar\" -> \"lightstar\"\n", p.Changes.String())
+}
+
+func TestPreviewChangeURL(t *testing.T) {
+	sess := newSession(newTestServer(t).transport())
+	_, err := sess.PreviewChange(context.Background(), Running, URL("ftp://example.com/config.xml"))
+	assert.Error(t, err)
+}
+
+func TestLineDiff(t *testing.T) {
+	tt := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{"equal", "a\nb\n", "a\nb\n", ""},
+		{"empty", "", "", ""},
+		{"add", "", "a\n", "--- before\n+++ after\n@@ -0,0 +1,1 @@\n+a\n"},
+		{"remove", "a\nb\n", "a\n", "--- before\n+++ after\n@@ -1,2 +1,1 @@\n a\n-b\n"},
+		{
+			"separate hunks",
+			"1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n",
+			"0\n2\n3\n4\n5\n6\n7\n8\n9\n",
+			"--- before\n+++ after\n@@ -1,4 +1,4 @@\n-1\n+0\n 2\n 3\n 4\n@@ -7,4 +7,3 @@\n 7\n 8\n 9\n-10\n",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, lineDiff(tc.a, tc.b))
+		})
+	}
+}
+
+func TestMyersDiff(t *testing.T) {
+	// lcs returns the length of the longest common subsequence.
+	lcs := func(a, b []string) int {
+		dp := make([][]int, len(a)+1)
+		for i := range dp {
+			dp[i] = make([]int, len(b)+1)
+		}
+		for i := len(a) - 1; i >= 0; i-- {
+			for j := len(b) - 1; j >= 0; j-- {
+				if a[i] == b[j] {
+					dp[i][j] = dp[i+1][j+1] + 1
+				} else {
+					dp[i][j] = max(dp[i+1][j], dp[i][j+1])
+				}
+			}
+		}
+		return dp[0][0]
+	}
+
+	rnd := rand.New(rand.NewSource(1))
+	lines := func() []string {
+		out := make([]string, rnd.Intn(30))
+		for i := range out {
+			out[i] = strconv.Itoa(rnd.Intn(4))
+		}
+		return out
+	}
+
+	for i := 0; i < 500; i++ {
+		a, b := lines(), lines()
+		ops := myersDiff(a, b)
+
+		var gotA, gotB []string
+		edits := 0
+		for _, op := range ops {
+			switch op.kind {
+			case ' ':
+				require.Equal(t, a[op.x], b[op.y])
+				gotA = append(gotA, op.line)
+				gotB = append(gotB, op.line)
+			case '-':
+				gotA = append(gotA, op.line)
+				edits++
+			case '+':
+				gotB = append(gotB, op.line)
+				edits++
+			}
+		}
+		require.Equal(t, strings.Join(a, ","), strings.Join(gotA, ","))
+		require.Equal(t, strings.Join(b, ","), strings.Join(gotB, ","))
+		// the edit script is the shortest.
+		require.Equal(t, len(a)+len(b)-2*lcs(a, b), edits, "a: %v b: %v", a, b)
+	}
+}
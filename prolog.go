@@ -0,0 +1,96 @@
+package netconf
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+var (
+	bom         = []byte("\xef\xbb\xbf")
+	xmlDeclRe   = regexp.MustCompile(`^<\?xml\s[^>]*\?>`)
+	xmlEncodeRe = regexp.MustCompile(`encoding\s*=\s*["']([^"']*)["']`)
+)
+
+// skipProlog strips any leading whitespace, byte order marks and a UTF-8 xml
+// declaration from a message.  Some servers prefix every message with these
+// and declare the encoding with names encoding/xml doesn't accept (i.e
+// `utf8`).  Declarations of other encodings are left for the decoder's
+// CharsetReader (see [WithDecoderConfig]).
+//
+// The returned reader is taken from a pool and should be given back with
+// putMsgReader once the message has been read.
+func skipProlog(r io.Reader) *bufio.Reader {
+	br := msgReaderPool.Get().(*bufio.Reader)
+	br.Reset(r)
+	for {
+		// only look at what has been received so far so a message that
+		// arrives slowly isn't held up.
+		if _, err := br.Peek(1); err != nil {
+			return br
+		}
+		p, _ := br.Peek(br.Buffered())
+
+		switch {
+		case p[0] == ' ' || p[0] == '\t' || p[0] == '\r' || p[0] == '\n':
+			_, _ = br.Discard(1)
+		case p[0] == bom[0]:
+			if p, _ = br.Peek(len(bom)); !bytes.Equal(p, bom) {
+				return br
+			}
+			_, _ = br.Discard(len(bom))
+		case p[0] == '<':
+			if p, _ = br.Peek(2); len(p) < 2 || p[1] != '?' {
+				return br
+			}
+			decl := xmlDeclRe.Find(peekUntil(br, '>', 256))
+			if decl == nil || !isUTF8Decl(decl) {
+				return br
+			}
+			_, _ = br.Discard(len(decl))
+		default:
+			return br
+		}
+	}
+}
+
+// msgReaderPool holds the read buffers of incoming messages so a session
+// polled in a loop doesn't allocate a new one per message.
+var msgReaderPool = sync.Pool{
+	New: func() any { return bufio.NewReader(nil) },
+}
+
+// putMsgReader gives br back to the pool.  Nothing read from it may be used
+// afterwards.
+func putMsgReader(br *bufio.Reader) {
+	// drop the reference to the message so it can be collected.
+	br.Reset(nil)
+	msgReaderPool.Put(br)
+}
+
+// peekUntil peeks until c or max bytes have been read.
+func peekUntil(br *bufio.Reader, c byte, max int) []byte {
+	p, _ := br.Peek(br.Buffered())
+	for bytes.IndexByte(p, c) < 0 && len(p) < max {
+		var err error
+		if p, err = br.Peek(len(p) + 1); err != nil {
+			break
+		}
+	}
+	return p
+}
+
+func isUTF8Decl(decl []byte) bool {
+	m := xmlEncodeRe.FindSubmatch(decl)
+	if m == nil {
+		return true
+	}
+	switch strings.ToLower(string(m[1])) {
+	case "utf-8", "utf8":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package netconf
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSkipProlog(t *testing.T) {
+	tt := []struct {
+		name, in, want string
+	}{
+		{"none", `<hello/>`, `<hello/>`},
+		{"bom", "\ufeff<hello/>", `<hello/>`},
+		{"decl", `<?xml version="1.0" encoding="UTF-8"?>` + "\n<hello/>", `<hello/>`},
+		{"utf8 alias", "\ufeff" + `<?xml version="1.0" encoding="utf8"?><hello/>`, `<hello/>`},
+		{"repeated", `<?xml version="1.0"?><?xml version="1.0"?> <hello/>`, `<hello/>`},
+		{"other encoding", `<?xml version="1.0" encoding="ISO-8859-1"?><hello/>`, `<?xml version="1.0" encoding="ISO-8859-1"?><hello/>`},
+		{"empty", "\n\n", ""},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := io.ReadAll(skipProlog(strings.NewReader(tc.in)))
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, string(got))
+		})
+	}
+}
+
+func TestMsgReaderPool(t *testing.T) {
+	// a reader given back doesn't keep anything of the previous message.
+	br := skipProlog(strings.NewReader("<a/>" + strings.Repeat(" ", 8192)))
+	_, err := br.Peek(10)
+	require.NoError(t, err)
+	putMsgReader(br)
+	assert.Equal(t, 0, br.Buffered())
+
+	got, err := io.ReadAll(skipProlog(strings.NewReader("<b/>")))
+	require.NoError(t, err)
+	assert.Equal(t, "<b/>", string(got))
+}
+
+func BenchmarkRecvMsg(b *testing.B) {
+	msg := `<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><data/></rpc-reply>`
+	r := strings.NewReader(msg)
+	sess := newSession(readerTransport{testTransport: newTestServer(nil).transport(), r: r})
+
+	pending := &req{reply: make(chan Reply, 1), ctx: context.Background()}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		r.Reset(msg)
+		sess.reqs["1"] = pending
+		if err := sess.recvMsg(); err != nil {
+			b.Fatal(err)
+		}
+		<-pending.reply
+	}
+}
+
+// readerTransport reads every message from r.
+type readerTransport struct {
+	*testTransport
+	r io.Reader
+}
+
+func (t readerTransport) MsgReader() (io.ReadCloser, error) { return io.NopCloser(t.r), nil }
+
+func TestStrayHello(t *testing.T) {
+	tr := newTestServer(t).transport()
+	sess := newSession(tr)
+
+	tr.pushMsg("\ufeff" + `<?xml version="1.0" encoding="utf8"?>` + helloGood)
+	require.NoError(t, sess.recvMsg())
+	assert.Empty(t, sess.UnknownMessages())
+}
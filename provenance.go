@@ -0,0 +1,176 @@
+package netconf
+
+import (
+	"encoding/xml"
+	"sort"
+	"strings"
+)
+
+type labelsOpt map[string]string
+
+func (o labelsOpt) apply(cfg *sessionConfig) {
+	if cfg.labels == nil {
+		cfg.labels = make(map[string]string, len(o))
+	}
+	for k, v := range o {
+		cfg.labels[k] = v
+	}
+}
+
+// WithLabels attaches key/value labels to a session (i.e the name of the tool,
+// the user and the change ticket driving the session).  Labels are not sent to
+// the device unless [WithProvenance] is used.
+func WithLabels(labels map[string]string) SessionOption { return labelsOpt(labels) }
+
+// Labels returns a copy of the labels set on the session with [WithLabels].
+func (s *Session) Labels() map[string]string { return copyLabels(s.labels) }
+
+func copyLabels(labels map[string]string) map[string]string {
+	out := make(map[string]string, len(labels))
+	for k, v := range labels {
+		out[k] = v
+	}
+	return out
+}
+
+// ProvenanceNamespace is the namespace of the attributes added to the `<rpc>`
+// element with [ProvenanceAttributes].
+const ProvenanceNamespace = "urn:github:nemith:netconf:provenance"
+
+// ProvenanceMode selects how provenance is added to mutating rpcs.  Modes can
+// be combined.
+type ProvenanceMode int
+
+const (
+	// ProvenanceComment adds a xml comment with the labels to the `<rpc>`
+	// element (i.e `<!-- provenance: ticket=CHG-1 user=alice -->`).
+	ProvenanceComment ProvenanceMode = 1 << iota
+
+	// ProvenanceAttributes adds each label as an attribute in the
+	// [ProvenanceNamespace] namespace to the `<rpc>` element.  RFC6241
+	// requires servers to return these unmodified in the `<rpc-reply>` and
+	// many servers record them in their logs.
+	ProvenanceAttributes
+)
+
+// ProvenanceSetter can be implemented by operations that have a place to
+// store provenance (i.e vendor specific commit comment or log fields).  It is
+// called with the session labels for every rpc issued with the operation when
+// [WithProvenance] is used.  The labels are a copy that the operation may keep.
+type ProvenanceSetter interface {
+	SetProvenance(labels map[string]string)
+}
+
+type provenanceOpt struct {
+	mode ProvenanceMode
+	keys []string
+}
+
+func (o provenanceOpt) apply(cfg *sessionConfig) { cfg.provenance = &o }
+
+// WithProvenance injects the session labels (see [WithLabels]) into every rpc
+// that modifies the configuration of the device so that changes can be
+// attributed in the device's logs.  If keys are given then only those labels
+// are included.  A mode of 0 only sets the provenance on operations
+// implementing [ProvenanceSetter].
+//
+// The standard `<edit-config>`, `<copy-config>`, `<delete-config>`,
+// `<commit>`, `<cancel-commit>` and `<discard-changes>` operations as well as
+// any operation implementing [ProvenanceSetter] are considered mutating.
+func WithProvenance(mode ProvenanceMode, keys ...string) SessionOption {
+	return provenanceOpt{mode: mode, keys: append([]string(nil), keys...)}
+}
+
+var mutatingOps = map[string]bool{
+	"edit-config":     true,
+	"copy-config":     true,
+	"delete-config":   true,
+	"commit":          true,
+	"cancel-commit":   true,
+	"discard-changes": true,
+}
+
+// addProvenance adds the provenance for the operation to the rpc message.
+func (s *Session) addProvenance(msg *request, op string) {
+	if s.provenance == nil {
+		return
+	}
+
+	labels := s.provenanceLabels()
+	if len(labels) == 0 {
+		return
+	}
+
+	if setter, ok := unwrapOperation(msg.Operation).(ProvenanceSetter); ok {
+		setter.SetProvenance(labels)
+	} else if !mutatingOps[op] {
+		return
+	}
+
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	if s.provenance.mode&ProvenanceComment != 0 {
+		var sb strings.Builder
+		sb.WriteString(" provenance:")
+		for _, k := range keys {
+			sb.WriteString(" " + k + "=" + labels[k])
+		}
+		sb.WriteString(" ")
+		// "--" is not allowed in xml comments
+		comment := sb.String()
+		for strings.Contains(comment, "--") {
+			comment = strings.ReplaceAll(comment, "--", "- -")
+		}
+		msg.Comment = xml.Comment(comment)
+	}
+
+	if s.provenance.mode&ProvenanceAttributes != 0 {
+		// declare the prefix explicitly as encoding/xml can't derive a
+		// readable one from a urn.
+		msg.Attrs = append(msg.Attrs, xml.Attr{
+			Name:  xml.Name{Local: "xmlns:provenance"},
+			Value: ProvenanceNamespace,
+		})
+		for _, k := range keys {
+			// labels that aren't valid attribute names are only usable in
+			// comments.
+			if !isXMLName(k) || strings.Contains(k, ":") {
+				continue
+			}
+			msg.Attrs = append(msg.Attrs, xml.Attr{
+				Name:  xml.Name{Local: "provenance:" + k},
+				Value: labels[k],
+			})
+		}
+	}
+}
+
+func (s *Session) provenanceLabels() map[string]string {
+	if len(s.provenance.keys) == 0 {
+		return copyLabels(s.labels)
+	}
+
+	labels := make(map[string]string, len(s.provenance.keys))
+	for _, k := range s.provenance.keys {
+		if v, ok := s.labels[k]; ok {
+			labels[k] = v
+		}
+	}
+	return labels
+}
+
+// unwrapOperation removes the extra pointer to an interface added by
+// [Session.Call].
+func unwrapOperation(op any) any {
+	for {
+		p, ok := op.(*any)
+		if !ok || p == nil {
+			return op
+		}
+		op = *p
+	}
+}
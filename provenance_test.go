@@ -0,0 +1,140 @@
+package netconf
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type vendorCommit struct {
+	XMLName xml.Name `xml:"commit-configuration"`
+	Log     string   `xml:"log,omitempty"`
+}
+
+func (c *vendorCommit) SetProvenance(labels map[string]string) {
+	c.Log = "ticket " + labels["ticket"]
+}
+
+func TestProvenance(t *testing.T) {
+	const okReply = `<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><ok/></rpc-reply>`
+
+	labels := map[string]string{
+		"tool":   "pusher",
+		"user":   "alice",
+		"ticket": "CHG--1",
+	}
+
+	tt := []struct {
+		name    string
+		opts    []SessionOption
+		call    func(*Session) error
+		want    []string
+		notWant []string
+	}{
+		{
+			name: "comment",
+			opts: []SessionOption{WithProvenance(ProvenanceComment)},
+			call: func(s *Session) error { return s.Commit(context.Background()) },
+			want: []string{`<!-- provenance: ticket=CHG- -1 tool=pusher user=alice --><commit>`},
+		},
+		{
+			name: "attributes",
+			opts: []SessionOption{WithProvenance(ProvenanceAttributes, "user")},
+			call: func(s *Session) error { return s.EditConfig(context.Background(), Running, "<foo/>") },
+			want: []string{`xmlns:provenance="urn:github:nemith:netconf:provenance" provenance:user="alice"`},
+			notWant: []string{
+				"ticket",
+				"tool",
+			},
+		},
+		{
+			name:    "not mutating",
+			opts:    []SessionOption{WithProvenance(ProvenanceComment | ProvenanceAttributes)},
+			call:    func(s *Session) error { return s.Lock(context.Background(), Running) },
+			notWant: []string{"provenance", "alice"},
+		},
+		{
+			name:    "disabled",
+			call:    func(s *Session) error { return s.Commit(context.Background()) },
+			notWant: []string{"provenance", "alice"},
+		},
+		{
+			name: "setter",
+			opts: []SessionOption{WithProvenance(0)},
+			call: func(s *Session) error {
+				return s.Call(context.Background(), &vendorCommit{}, &OKResp{})
+			},
+			want:    []string{"<log>ticket CHG--1</log>"},
+			notWant: []string{"provenance"},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := newTestServer(t)
+			opts := append([]SessionOption{WithLabels(labels)}, tc.opts...)
+			sess := newSession(ts.transport(), opts...)
+			go sess.recv()
+
+			ts.queueRespString(okReply)
+			require.NoError(t, tc.call(sess))
+
+			req, err := ts.popReqString()
+			require.NoError(t, err)
+			for _, want := range tc.want {
+				assert.Contains(t, req, want)
+			}
+			for _, notWant := range tc.notWant {
+				assert.NotContains(t, req, notWant)
+			}
+		})
+	}
+
+	sess := newSession(nil, WithLabels(labels))
+	got := sess.Labels()
+	got["user"] = "mallory"
+	assert.Equal(t, "alice", sess.Labels()["user"])
+}
+
+type keepingCommit struct {
+	XMLName xml.Name `xml:"commit-configuration"`
+	labels  map[string]string
+}
+
+func (c *keepingCommit) SetProvenance(labels map[string]string) {
+	c.labels = labels
+	labels["user"] = "mallory"
+}
+
+func TestProvenanceLabelsCopied(t *testing.T) {
+	const okReply = `<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="%d"><ok/></rpc-reply>`
+
+	labels := map[string]string{"user": "alice"}
+	keys := []string{"user"}
+	for _, opt := range []SessionOption{WithProvenance(0), WithProvenance(0, keys...)} {
+		ts := newTestServer(t)
+		sess := newSession(ts.transport(), WithLabels(labels), opt)
+		go sess.recv()
+
+		// changing what was passed in doesn't change the session.
+		labels["user"] = "eve"
+		keys[0] = "tool"
+
+		for i := 1; i <= 2; i++ {
+			ts.queueRespString(fmt.Sprintf(okReply, i))
+			op := &keepingCommit{}
+			_, err := sess.Do(context.Background(), op)
+			require.NoError(t, err)
+			assert.Equal(t, map[string]string{"user": "mallory"}, op.labels)
+			_, err = ts.popReq()
+			require.NoError(t, err)
+		}
+		assert.Equal(t, "alice", sess.Labels()["user"])
+
+		labels["user"], keys[0] = "alice", "user"
+	}
+}
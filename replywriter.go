@@ -0,0 +1,223 @@
+package netconf
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+type replyWriterOpt struct{ w io.Writer }
+
+func (o replyWriterOpt) apply(cfg *doConfig) { cfg.replyWriter = o.w }
+
+// WithReplyWriter streams the contents of the `<rpc-reply>` (i.e the `<data>`
+// of a large `<get-config>`) to w as they are received instead of keeping them
+// in [Reply.Body].  The contents are copied as received without being decoded
+// and, as long as the message isn't wrapped for metrics, captures or a
+// maximum size, straight from the transport's read buffer.  The returned
+// Reply has an empty Body.
+//
+// Replies that are empty or start with `<ok/>` or `<rpc-error>` are decoded as
+// usual so errors are still returned in [Reply.Errors] and w isn't written
+// to.
+//
+// w is written to from the session's receive loop so other messages are held
+// up until the reply has been copied.  An error writing to w fails the rpc.
+// Once Do returns w isn't written to anymore.
+func WithReplyWriter(w io.Writer) DoOption { return replyWriterOpt{w} }
+
+// replyBody is the writer of a streamed reply.  It stops writing once the rpc
+// has been given up on.
+type replyBody struct {
+	mu      sync.Mutex
+	w       io.Writer
+	err     error
+	stopped bool
+}
+
+// Write writes p to the caller's writer.  Errors are kept rather than
+// returned so the rest of the message is still read.
+func (b *replyBody) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.stopped || b.err != nil {
+		return len(p), nil
+	}
+	if _, err := b.w.Write(p); err != nil {
+		b.err = fmt.Errorf("failed to write rpc-reply body: %w", err)
+	}
+	return len(p), nil
+}
+
+func (b *replyBody) stop() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.stopped = true
+}
+
+func (b *replyBody) writeErr() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.err
+}
+
+// streamReply copies the contents of the reply started by root from br to the
+// writer of the rpc it is for.  It returns false if the reply isn't streamed
+// and has to be decoded.
+func (s *Session) streamReply(br *bufio.Reader, root *xml.StartElement) (bool, error) {
+	var msgID string
+	for _, attr := range root.Attr {
+		if attr.Name.Space == "" && attr.Name.Local == "message-id" {
+			msgID = attr.Value
+		}
+	}
+
+	s.mu.Lock()
+	r := s.reqs[msgID]
+	s.mu.Unlock()
+	if r == nil || r.body == nil {
+		return false, nil
+	}
+
+	stop := s.interruptRead(r.ctx)
+	if !streamable(br) {
+		stop(nil)
+		return false, nil
+	}
+	w := &endTagWriter{w: r.body}
+	_, err := io.Copy(w, br)
+	if err == nil {
+		err = w.Close()
+	}
+	stop(err)
+
+	ok, r := s.req(msgID)
+	switch {
+	case err != nil:
+		if ok {
+			r.err = err
+			close(r.reply)
+		}
+		if errors.Is(err, ErrMessageTooLarge) {
+			// the rest of the message is discarded and the session can
+			// go on.
+			return true, nil
+		}
+		return true, fmt.Errorf("failed to read rpc-reply message: %w", err)
+	case !ok:
+		// given up on while being read.
+		return true, nil
+	case r.body.writeErr() != nil:
+		r.err = r.body.writeErr()
+		close(r.reply)
+		return true, nil
+	}
+
+	r.reply <- Reply{MessageID: msgID}
+	return true, nil
+}
+
+// maxStreamPeek bounds looking ahead for the first element of a reply.
+const maxStreamPeek = 512
+
+// streamable reports if the contents of a reply read from br are streamed.
+// Empty replies and ones starting with `<ok/>` or `<rpc-error>` are decoded
+// instead.
+func streamable(br *bufio.Reader) bool {
+	p := bytes.TrimLeft(peekUntil(br, '>', maxStreamPeek), " \t\r\n")
+	switch {
+	case len(p) == 0:
+		return false
+	case p[0] != '<':
+		return true
+	}
+
+	name := p[1:]
+	if end := bytes.IndexAny(name, " \t\r\n/>"); end >= 0 {
+		name = name[:end]
+	}
+	if len(name) == 0 {
+		// the end of the reply
+		return false
+	}
+	if i := bytes.IndexByte(name, ':'); i >= 0 {
+		name = name[i+1:]
+	}
+	return string(name) != "ok" && string(name) != "rpc-error"
+}
+
+// endTagWriter writes the contents of an `<rpc-reply>` to w holding back the
+// end tag.  Data is held back from each '<' until it can't be the end tag
+// anymore.
+type endTagWriter struct {
+	w       io.Writer
+	pending []byte
+	holding bool
+}
+
+func (e *endTagWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '<')
+		if i < 0 {
+			if !e.holding {
+				return n, e.write(p)
+			}
+			if len(e.pending)+len(p) > maxStreamPeek {
+				return n, e.flush(p)
+			}
+			e.pending = append(e.pending, p...)
+			return n, nil
+		}
+
+		if e.holding {
+			if err := e.flush(p[:i]); err != nil {
+				return n, err
+			}
+		} else if err := e.write(p[:i]); err != nil {
+			return n, err
+		}
+		e.pending = append(e.pending[:0], '<')
+		e.holding = true
+		p = p[i+1:]
+	}
+	return n, nil
+}
+
+// flush writes the held back data followed by p.
+func (e *endTagWriter) flush(p []byte) error {
+	e.holding = false
+	if err := e.write(e.pending); err != nil {
+		return err
+	}
+	e.pending = e.pending[:0]
+	return e.write(p)
+}
+
+func (e *endTagWriter) write(p []byte) error {
+	if len(p) == 0 {
+		return nil
+	}
+	_, err := e.w.Write(p)
+	return err
+}
+
+// Close checks that the held back data is the end tag of the reply.
+func (e *endTagWriter) Close() error {
+	tag := bytes.TrimRight(e.pending, " \t\r\n")
+	if !e.holding || !bytes.HasPrefix(tag, []byte("</")) || !bytes.HasSuffix(tag, []byte(">")) {
+		return fmt.Errorf("missing </rpc-reply>: %w", io.ErrUnexpectedEOF)
+	}
+	name := bytes.TrimRight(tag[2:len(tag)-1], " \t\r\n")
+	if i := bytes.IndexByte(name, ':'); i >= 0 {
+		name = name[i+1:]
+	}
+	if string(name) != "rpc-reply" {
+		return fmt.Errorf("unexpected end element </%s>", name)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package netconf
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writerToTransport reports if the messages it reads are copied with
+// io.WriterTo like the framed transports support.
+type writerToTransport struct {
+	*testTransport
+	used atomic.Bool
+}
+
+func (t *writerToTransport) MsgReader() (io.ReadCloser, error) {
+	r, err := t.testTransport.MsgReader()
+	return writerToReader{ReadCloser: r, used: &t.used}, err
+}
+
+type writerToReader struct {
+	io.ReadCloser
+	used *atomic.Bool
+}
+
+func (r writerToReader) WriteTo(w io.Writer) (int64, error) {
+	r.used.Store(true)
+	return io.Copy(w, r.ReadCloser)
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) { return 0, errors.New("disk full") }
+
+func TestReplyWriter(t *testing.T) {
+	ts := newTestServer(t)
+	tr := &writerToTransport{testTransport: ts.transport()}
+	sess := newSession(tr)
+	go sess.recv()
+
+	// larger than the read buffer so the rest is copied with WriteTo.
+	data := "<data>" + strings.Repeat(`<a xmlns="urn:a">text &amp; more</a>`, 1000) + "</data>"
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1">` + data + "</rpc-reply>\n")
+
+	var buf bytes.Buffer
+	reply, err := sess.Do(context.Background(), &GetConfigReq{Source: Running}, WithReplyWriter(&buf))
+	require.NoError(t, err)
+	assert.Equal(t, "1", reply.MessageID)
+	assert.Empty(t, reply.Body)
+	assert.Equal(t, data, buf.String())
+	assert.True(t, tr.used.Load())
+	_, err = ts.popReq()
+	require.NoError(t, err)
+
+	// errors are decoded and not written.
+	buf.Reset()
+	ts.queueRespString(`<nc:rpc-reply xmlns:nc="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="2">
+  <nc:rpc-error><nc:error-type>rpc</nc:error-type><nc:error-tag>operation-failed</nc:error-tag><nc:error-severity>error</nc:error-severity></nc:rpc-error>
+</nc:rpc-reply>`)
+	reply, err = sess.Do(context.Background(), &GetConfigReq{Source: Running}, WithReplyWriter(&buf))
+	require.NoError(t, err)
+	require.Len(t, reply.Errors, 1)
+	assert.Equal(t, ErrOperationFailed, reply.Errors[0].Tag)
+	assert.Empty(t, buf.String())
+	_, err = ts.popReq()
+	require.NoError(t, err)
+
+	// a prefixed end tag is held back too.
+	ts.queueRespString(`<nc:rpc-reply xmlns:nc="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="3"><nc:data/></nc:rpc-reply >`)
+	_, err = sess.Do(context.Background(), &GetConfigReq{Source: Running}, WithReplyWriter(&buf))
+	require.NoError(t, err)
+	assert.Equal(t, "<nc:data/>", buf.String())
+	_, err = ts.popReq()
+	require.NoError(t, err)
+
+	// failing to write fails the rpc but not the session.
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="4">` + data + "</rpc-reply>")
+	_, err = sess.Do(context.Background(), &GetConfigReq{Source: Running}, WithReplyWriter(failingWriter{}))
+	assert.ErrorContains(t, err, "disk full")
+	_, err = ts.popReq()
+	require.NoError(t, err)
+
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="5"><data/></rpc-reply>`)
+	reply, err = sess.Do(context.Background(), &GetConfigReq{Source: Running})
+	require.NoError(t, err)
+	assert.Equal(t, "<data/>", string(reply.Body))
+}
+
+func TestEndTagWriter(t *testing.T) {
+	tt := []struct {
+		name   string
+		writes []string
+		want   string
+		err    bool
+	}{
+		{name: "end tag", writes: []string{"<a>x</a></rpc-reply>"}, want: "<a>x</a>"},
+		{name: "split", writes: []string{"<a>x</a></rpc", "-reply", ">\n"}, want: "<a>x</a>"},
+		{name: "long text", writes: []string{"<a>", strings.Repeat("x", 1000), "</a></rpc-reply>"}, want: "<a>" + strings.Repeat("x", 1000) + "</a>"},
+		{name: "truncated", writes: []string{"<a>x</a>"}, err: true},
+		{name: "wrong end tag", writes: []string{"<a>x</a></b>"}, err: true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := &endTagWriter{w: &buf}
+			for _, p := range tc.writes {
+				_, err := w.Write([]byte(p))
+				require.NoError(t, err)
+			}
+			err := w.Close()
+			if tc.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, buf.String())
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package netconf
+
+import "encoding/xml"
+
+type rpcAttrsOpt []xml.Attr
+
+func (o rpcAttrsOpt) apply(cfg *doConfig) { cfg.rpcAttrs = append(cfg.rpcAttrs, o...) }
+
+// WithRPCAttrs adds attributes to the `<rpc>` element of a single rpc.  Some
+// devices expect vendor attributes on the envelope.  A `message-id` attribute
+// is ignored as the session sets it.
+//
+// To declare a namespace prefix use [WithRPCNamespace] as encoding/xml doesn't
+// handle prefixed namespace declarations given as xml.Attr.Name.Space.
+func WithRPCAttrs(attrs ...xml.Attr) DoOption { return rpcAttrsOpt(attrs) }
+
+// WithRPCNamespace declares a namespace prefix (i.e `xmlns:junos`) on the
+// `<rpc>` element of a single rpc.  Elements of the operation in the
+// namespace use the prefix (see [WithNamespacePrefixes]).
+func WithRPCNamespace(prefix, uri string) DoOption {
+	return rpcAttrsOpt{{Name: xml.Name{Local: "xmlns:" + prefix}, Value: uri}}
+}
+
+// addRPCAttrs adds the attributes given with [WithRPCAttrs] to the request.
+func addRPCAttrs(msg *request, attrs []xml.Attr) {
+	for _, attr := range attrs {
+		if attr.Name.Space == "" && attr.Name.Local == "message-id" {
+			continue
+		}
+		msg.Attrs = append(msg.Attrs, attr)
+	}
+}
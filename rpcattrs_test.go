@@ -0,0 +1,38 @@
+package netconf
+
+import (
+	"context"
+	"encoding/xml"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRPCAttrs(t *testing.T) {
+	ts := newTestServer(t)
+	sess := newSession(ts.transport())
+	go sess.recv()
+
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><ok/></rpc-reply>`)
+	_, err := sess.Do(context.Background(), &GetConfigReq{Source: Running},
+		WithRPCNamespace("junos", "http://xml.juniper.net/junos/*/junos"),
+		WithRPCAttrs(
+			xml.Attr{Name: xml.Name{Local: "format"}, Value: "text"},
+			xml.Attr{Name: xml.Name{Local: "message-id"}, Value: "spoofed"},
+		))
+	require.NoError(t, err)
+
+	req, err := ts.popReqString()
+	require.NoError(t, err)
+	assert.Contains(t, req, `<rpc xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1" xmlns:junos="http://xml.juniper.net/junos/*/junos" format="text">`)
+	assert.NotContains(t, req, "spoofed")
+
+	// attributes only apply to the rpc they were given with.
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="2"><ok/></rpc-reply>`)
+	_, err = sess.Do(context.Background(), &GetConfigReq{Source: Running})
+	require.NoError(t, err)
+	req, err = ts.popReqString()
+	require.NoError(t, err)
+	assert.Contains(t, req, `<rpc xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="2">`)
+}
@@ -0,0 +1,126 @@
+package netconf
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// DatastoreTargeter is implemented by operations that read or modify
+// datastores.  It is used by [WithDatastoreSerialization] to find the
+// datastores an operation uses.  All the standard operations in this package
+// implement it.
+type DatastoreTargeter interface {
+	TargetDatastores() []Datastore
+}
+
+// TargetDatastores implements DatastoreTargeter.
+func (r GetConfigReq) TargetDatastores() []Datastore { return []Datastore{r.Source} }
+
+// TargetDatastores implements DatastoreTargeter.
+func (r EditConfigReq) TargetDatastores() []Datastore { return []Datastore{r.Target} }
+
+// TargetDatastores implements DatastoreTargeter.  Sources and targets that are
+// not a [Datastore] (i.e a [URL] or inline config) are ignored.
+func (r CopyConfigReq) TargetDatastores() []Datastore {
+	var out []Datastore
+	for _, v := range []any{r.Source, r.Target} {
+		if ds, ok := v.(Datastore); ok {
+			out = append(out, ds)
+		}
+	}
+	return out
+}
+
+// TargetDatastores implements DatastoreTargeter.
+func (r DeleteConfigReq) TargetDatastores() []Datastore { return []Datastore{r.Target} }
+
+// TargetDatastores implements DatastoreTargeter.
+func (r LockReq) TargetDatastores() []Datastore { return []Datastore{r.Target} }
+
+// TargetDatastores implements DatastoreTargeter.
+func (r UnlockReq) TargetDatastores() []Datastore { return []Datastore{r.Target} }
+
+// TargetDatastores implements DatastoreTargeter.
+func (r ValidateReq) TargetDatastores() []Datastore {
+	if ds, ok := r.Source.(Datastore); ok {
+		return []Datastore{ds}
+	}
+	return nil
+}
+
+// TargetDatastores implements DatastoreTargeter.
+func (r CommitReq) TargetDatastores() []Datastore { return []Datastore{Candidate, Running} }
+
+// TargetDatastores implements DatastoreTargeter.
+func (r CancelCommitReq) TargetDatastores() []Datastore { return []Datastore{Candidate, Running} }
+
+type datastoreSerializationOpt struct{}
+
+func (datastoreSerializationOpt) apply(cfg *sessionConfig) { cfg.serializeDatastores = true }
+
+// WithDatastoreSerialization serializes operations that use the same
+// datastore so that only one of them is outstanding at a time.  Operations on
+// other datastores (i.e reading `running` while editing `candidate`) still
+// run concurrently.  This matches the locking done by most devices and avoids
+// `in-use` errors when many goroutines share a session.
+//
+// The datastores of an operation are found with [DatastoreTargeter].
+// Operations not implementing it are never serialized.
+func WithDatastoreSerialization() SessionOption { return datastoreSerializationOpt{} }
+
+// datastoreQueues holds a single slot semaphore per datastore.
+type datastoreQueues struct {
+	mu     sync.Mutex
+	queues map[Datastore]chan struct{}
+}
+
+func (q *datastoreQueues) queue(ds Datastore) chan struct{} {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.queues == nil {
+		q.queues = make(map[Datastore]chan struct{})
+	}
+	ch, ok := q.queues[ds]
+	if !ok {
+		ch = make(chan struct{}, 1)
+		q.queues[ds] = ch
+	}
+	return ch
+}
+
+// acquire waits for all of the datastores used by the operation.  Datastores
+// are always acquired in the same order to avoid deadlocks between operations
+// using more than one.
+func (q *datastoreQueues) acquire(ctx context.Context, op any) (release func(), err error) {
+	targeter, ok := unwrapOperation(op).(DatastoreTargeter)
+	if !ok {
+		return func() {}, nil
+	}
+
+	stores := append([]Datastore(nil), targeter.TargetDatastores()...)
+	sort.Slice(stores, func(i, j int) bool { return stores[i] < stores[j] })
+
+	var held []chan struct{}
+	release = func() {
+		for _, ch := range held {
+			<-ch
+		}
+	}
+
+	for i, ds := range stores {
+		if ds == "" || (i > 0 && ds == stores[i-1]) {
+			continue
+		}
+		ch := q.queue(ds)
+		select {
+		case ch <- struct{}{}:
+			held = append(held, ch)
+		case <-ctx.Done():
+			release()
+			return nil, ctx.Err()
+		}
+	}
+	return release, nil
+}
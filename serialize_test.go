@@ -0,0 +1,67 @@
+package netconf
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDatastoreQueues(t *testing.T) {
+	var q datastoreQueues
+	ctx := context.Background()
+
+	// Call passes a pointer to the interface
+	var lock any = &LockReq{Target: Candidate}
+	releaseLock, err := q.acquire(ctx, &lock)
+	require.NoError(t, err)
+
+	// commit uses the candidate datastore
+	tctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	_, err = q.acquire(tctx, &CommitReq{})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// other datastores are not blocked
+	release, err := q.acquire(ctx, &GetConfigReq{Source: Running})
+	require.NoError(t, err)
+	release()
+
+	// operations without datastores are never blocked
+	release, err = q.acquire(ctx, &KillSessionReq{SessionID: 1})
+	require.NoError(t, err)
+	release()
+
+	done := make(chan struct{})
+	go func() {
+		release, err := q.acquire(ctx, CopyConfigReq{Source: Running, Target: Candidate})
+		assert.NoError(t, err)
+		release()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("copy-config acquired a held datastore")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	releaseLock()
+	<-done
+}
+
+func TestWithDatastoreSerialization(t *testing.T) {
+	ts := newTestServer(t)
+	sess := newSession(ts.transport(), WithDatastoreSerialization())
+	go sess.recv()
+
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><ok/></rpc-reply>`)
+	require.NoError(t, sess.Lock(context.Background(), Candidate))
+
+	// the datastore is released after the reply
+	release, err := sess.datastoreQueues.acquire(context.Background(), &UnlockReq{Target: Candidate})
+	require.NoError(t, err)
+	release()
+}
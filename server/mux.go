@@ -0,0 +1,61 @@
+package server
+
+import (
+	"context"
+	"encoding/xml"
+	"sync"
+)
+
+// Mux is a [Handler] that routes rpcs to other handlers by the name of the
+// operation element.  Operations without a handler are answered with an
+// operation-not-supported error.
+//
+//	mux := server.NewMux()
+//	mux.HandleFunc(xml.Name{Space: "urn:example", Local: "reboot"}, reboot)
+//	srv := &server.Server{Handler: mux}
+type Mux struct {
+	mu       sync.RWMutex
+	handlers map[xml.Name]Handler
+}
+
+// NewMux returns a new empty Mux.
+func NewMux() *Mux {
+	return &Mux{handlers: make(map[xml.Name]Handler)}
+}
+
+// Handle registers the handler for operations with the given name.  If
+// name.Space is empty the handler matches the local name in any namespace.
+// Registering a handler for a name that already has one replaces it.
+func (m *Mux) Handle(name xml.Name, h Handler) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.handlers == nil {
+		m.handlers = make(map[xml.Name]Handler)
+	}
+	m.handlers[name] = h
+}
+
+// HandleFunc registers a handler function for operations with the given name.
+func (m *Mux) HandleFunc(name xml.Name, f func(ctx context.Context, req *Request) (any, error)) {
+	m.Handle(name, HandlerFunc(f))
+}
+
+// Handler returns the handler for the given operation or nil if there is
+// none.
+func (m *Mux) Handler(name xml.Name) Handler {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if h, ok := m.handlers[name]; ok {
+		return h
+	}
+	return m.handlers[xml.Name{Local: name.Local}]
+}
+
+// ServeRPC dispatches the rpc to the handler for its operation.
+func (m *Mux) ServeRPC(ctx context.Context, req *Request) (any, error) {
+	h := m.Handler(req.Operation)
+	if h == nil {
+		return nil, notSupported(req)
+	}
+	return h.ServeRPC(ctx, req)
+}
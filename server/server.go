@@ -0,0 +1,551 @@
+// Package server implements the device side of NETCONF (RFC6241) so that
+// applications can expose their own configuration and operations to NETCONF
+// clients.
+//
+// A [Server] runs the hello exchange and reads rpcs from a client handing each
+// one to a [Handler].  [Mux] routes rpcs to handlers by operation name.
+// Sessions can be served over any [transport.Transport] with
+// [Server.ServeTransport] or accepted over SSH with [Server.ServeSSH] and TLS
+// with [Server.ServeTLS].
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nemith/netconf"
+	"github.com/nemith/netconf/transport"
+)
+
+const (
+	baseNamespace = "urn:ietf:params:xml:ns:netconf:base:1.0"
+	baseCapPrefix = "urn:ietf:params:netconf:base:"
+	baseCap10     = baseCapPrefix + "1.0"
+	baseCap11     = baseCapPrefix + "1.1"
+)
+
+// ErrBadHello is returned from [Server.ServeTransport] when the client's hello
+// is malformed or doesn't share a base protocol version with the server.
+var ErrBadHello = errors.New("netconf: bad client hello")
+
+// Handler responds to rpcs.  ServeRPC returns the contents of the
+// `<rpc-reply>`:
+//
+//   - nil for an `<ok/>` reply.
+//   - a []byte or [netconf.RawXML] that is sent as is.
+//   - any other value that is marshaled with encoding/xml.
+//
+// A returned [netconf.RPCError] or [netconf.RPCErrors] is sent as the
+// `<rpc-error>` elements of the reply.  Any other error is sent as an
+// operation-failed application error with the error as the message.
+//
+// The context is canceled when the session ends.
+type Handler interface {
+	ServeRPC(ctx context.Context, req *Request) (any, error)
+}
+
+// HandlerFunc adapts a function to a [Handler].
+type HandlerFunc func(ctx context.Context, req *Request) (any, error)
+
+// ServeRPC calls f(ctx, req).
+func (f HandlerFunc) ServeRPC(ctx context.Context, req *Request) (any, error) {
+	return f(ctx, req)
+}
+
+// Request is a rpc received from a client.
+type Request struct {
+	// MessageID is the message-id of the `<rpc>`.
+	MessageID string
+
+	// Attrs are the attributes of the `<rpc>` element other than the
+	// message-id.
+	Attrs []xml.Attr
+
+	// Operation is the name of the operation element (i.e `get-config`).
+	Operation xml.Name
+
+	// Body is the raw xml of the operation element.
+	Body []byte
+
+	// Session is the session the rpc was received on.
+	Session *Session
+
+	// namespaces are the namespace declarations of the `<rpc>`.
+	namespaces []xml.Attr
+}
+
+// Decode decodes the operation element into v.  The namespace declarations
+// of the `<rpc>` (the default namespace and any prefixes) apply to the
+// operation as they do in the message.
+func (r *Request) Decode(v any) error {
+	if len(r.namespaces) == 0 {
+		return xml.Unmarshal(r.Body, v)
+	}
+
+	// wrap the body in an element re-declaring the namespaces of the rpc.
+	var buf bytes.Buffer
+	buf.WriteString("<rpc")
+	for _, ns := range r.namespaces {
+		name := ns.Name.Local
+		if ns.Name.Space == "xmlns" {
+			name = "xmlns:" + name
+		}
+		buf.WriteString(" " + name + `="`)
+		if err := xml.EscapeText(&buf, []byte(ns.Value)); err != nil {
+			return err
+		}
+		buf.WriteByte('"')
+	}
+	buf.WriteByte('>')
+	buf.Write(r.Body)
+	buf.WriteString("</rpc>")
+
+	d := xml.NewDecoder(&buf)
+	if _, err := d.Token(); err != nil {
+		return err
+	}
+	return d.Decode(v)
+}
+
+// ErrorHandler is called with errors that happen while serving sessions that
+// can't be returned to a caller.
+type ErrorHandler func(err error)
+
+// Server serves NETCONF sessions.  The zero value has no handler and answers
+// every rpc except `<close-session>` and `<kill-session>` with an
+// operation-not-supported error.
+type Server struct {
+	// Handler handles rpcs other than `<close-session>` and `<kill-session>`.
+	Handler Handler
+
+	// Capabilities are advertised in the hello (see
+	// [netconf.CapabilityBuilder]).  The base 1.0 and 1.1 capabilities are
+	// added unless Capabilities has a base capability which limits the
+	// versions the server speaks.
+	Capabilities []string
+
+	// ErrorHandler is called with errors of sessions served in the
+	// background (i.e by [Server.ServeSSH]) and handler panics.  If nil
+	// errors are logged.
+	ErrorHandler ErrorHandler
+
+	lastID atomic.Uint64
+
+	sessMu   sync.Mutex
+	sessions map[uint64]*Session
+}
+
+// Peer is what the server knows about the client of a session from the
+// transport.
+type Peer struct {
+	// Username is the authenticated user (i.e the ssh user or the name
+	// mapped from a TLS client certificate).
+	Username string
+
+	// RemoteAddr is the address of the client if known.
+	RemoteAddr net.Addr
+
+	// Transport is the ietf-netconf-monitoring identity of the transport
+	// (i.e [TransportSSH]) if known.
+	Transport string
+
+	// Certificates is the verified certificate chain (leaf first) of a
+	// client authenticated with TLS.
+	Certificates []*x509.Certificate
+}
+
+// Session is a NETCONF session served to a client.
+type Session struct {
+	Peer
+
+	// ID is the session-id sent to the client in the hello.
+	ID uint64
+
+	// Start is when the session was started.
+	Start time.Time
+
+	// ClientCapabilities are the capabilities from the client hello.
+	ClientCapabilities []string
+
+	tr      transport.Transport
+	writeMu sync.Mutex
+
+	cancel context.CancelCauseFunc
+	done   chan struct{}
+
+	inRPCs       atomic.Uint64
+	inBadRPCs    atomic.Uint64
+	outRPCErrors atomic.Uint64
+}
+
+type helloMsg struct {
+	XMLName      xml.Name `xml:"urn:ietf:params:xml:ns:netconf:base:1.0 hello"`
+	SessionID    uint64   `xml:"session-id,omitempty"`
+	Capabilities []string `xml:"capabilities>capability"`
+}
+
+type replyMsg struct {
+	XMLName   xml.Name           `xml:"urn:ietf:params:xml:ns:netconf:base:1.0 rpc-reply"`
+	MessageID string             `xml:"message-id,attr,omitempty"`
+	Attrs     []xml.Attr         `xml:",any,attr"`
+	Errors    []netconf.RPCError `xml:"rpc-error"`
+	Body      []byte             `xml:",innerxml"`
+}
+
+// ServeTransport runs a NETCONF session with a client over tr.  It returns
+// nil when the client closes the session with `<close-session>` or closes the
+// transport, ctx.Err() when ctx is done, [ErrSessionKilled] when the session
+// is killed, or the error that ended the session.  tr is closed before
+// returning.
+//
+// `<kill-session>` is handled by the server for all the sessions it serves.
+func (s *Server) ServeTransport(ctx context.Context, tr transport.Transport, peer Peer) error {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	defer tr.Close()
+
+	stop := context.AfterFunc(ctx, func() { _ = tr.Close() })
+	defer stop()
+
+	sess := &Session{
+		Peer:   peer,
+		ID:     s.lastID.Add(1),
+		Start:  time.Now(),
+		tr:     tr,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	s.addSession(sess)
+	defer s.removeSession(sess)
+
+	if err := s.handshake(sess); err != nil {
+		if ctx.Err() != nil {
+			return context.Cause(ctx)
+		}
+		return err
+	}
+
+	for {
+		msg, err := readMsg(tr)
+		if err != nil {
+			if ctx.Err() != nil {
+				return context.Cause(ctx)
+			}
+			if errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+
+		req, err := parseRPC(msg)
+		if err != nil {
+			sess.inBadRPCs.Add(1)
+			s.handleError(fmt.Errorf("session %d: %w", sess.ID, err))
+			if req == nil {
+				// without the rpc there is no message-id to reply to.
+				continue
+			}
+			if err := sess.reply(req, nil, netconf.RPCError{
+				Type:     netconf.ErrTypeRPC,
+				Tag:      netconf.ErrMalformedMessage,
+				Severity: netconf.SevError,
+				Message:  err.Error(),
+			}); err != nil {
+				return err
+			}
+			continue
+		}
+		req.Session = sess
+		sess.inRPCs.Add(1)
+
+		var body any
+		switch req.Operation {
+		case xml.Name{Space: baseNamespace, Local: "close-session"}:
+			return sess.reply(req, nil, nil)
+		case xml.Name{Space: baseNamespace, Local: "kill-session"}:
+			err = s.serveKillSession(ctx, req)
+		default:
+			body, err = s.serveRPC(ctx, req)
+		}
+		if err := sess.reply(req, body, err); err != nil {
+			if ctx.Err() != nil {
+				return context.Cause(ctx)
+			}
+			return err
+		}
+	}
+}
+
+// serveListener accepts connections on ln and serves each of them with serve
+// until ctx is done or accepting fails.  It waits for the connections to end
+// before returning.
+func (s *Server) serveListener(ctx context.Context, ln net.Listener, serve func(context.Context, net.Conn) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	defer ln.Close()
+
+	stop := context.AfterFunc(ctx, func() { _ = ln.Close() })
+	defer stop()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := serve(ctx, conn); err != nil && !isSessionEnd(err) {
+				s.handleError(err)
+			}
+		}()
+	}
+}
+
+// isSessionEnd reports whether err is a normal end of a session that isn't
+// worth reporting.
+func isSessionEnd(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, ErrSessionKilled)
+}
+
+func (s *Server) handshake(sess *Session) error {
+	hello := helloMsg{
+		SessionID:    sess.ID,
+		Capabilities: s.capabilities(),
+	}
+
+	// the hellos are sent at the same time by both sides so the server hello
+	// is written while reading the client's.
+	written := make(chan error, 1)
+	go func() { written <- sess.write(&hello) }()
+
+	msg, err := readMsg(sess.tr)
+	if err != nil {
+		return fmt.Errorf("failed to read client hello: %w", err)
+	}
+	if err := <-written; err != nil {
+		return fmt.Errorf("failed to write hello: %w", err)
+	}
+
+	var clientHello helloMsg
+	if err := xml.Unmarshal(msg, &clientHello); err != nil {
+		return fmt.Errorf("%w: %w", ErrBadHello, err)
+	}
+	if clientHello.SessionID != 0 {
+		return fmt.Errorf("%w: client sent a session-id", ErrBadHello)
+	}
+	sess.ClientCapabilities = clientHello.Capabilities
+
+	has10 := slices.Contains(clientHello.Capabilities, baseCap10) && slices.Contains(hello.Capabilities, baseCap10)
+	has11 := slices.Contains(clientHello.Capabilities, baseCap11) && slices.Contains(hello.Capabilities, baseCap11)
+	switch {
+	case has11:
+		if upgrader, ok := sess.tr.(interface{ Upgrade() }); ok {
+			upgrader.Upgrade()
+		}
+	case has10:
+		if pinner, ok := sess.tr.(interface{ PinEOM() }); ok {
+			pinner.PinEOM()
+		}
+	default:
+		return fmt.Errorf("%w: no common base capability", ErrBadHello)
+	}
+	return nil
+}
+
+// capabilities returns the capabilities for the hello.
+func (s *Server) capabilities() []string {
+	for _, c := range s.Capabilities {
+		if strings.HasPrefix(c, baseCapPrefix) {
+			return s.Capabilities
+		}
+	}
+	return append([]string{baseCap10, baseCap11}, s.Capabilities...)
+}
+
+func (s *Server) serveRPC(ctx context.Context, req *Request) (body any, err error) {
+	if s.Handler == nil {
+		return nil, notSupported(req)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			s.handleError(&netconf.PanicError{Callback: "rpc handler", Value: r, Stack: debug.Stack()})
+			body, err = nil, netconf.RPCError{
+				Type:     netconf.ErrTypeApp,
+				Tag:      netconf.ErrOperationFailed,
+				Severity: netconf.SevError,
+				Message:  "internal error",
+			}
+		}
+	}()
+	return s.Handler.ServeRPC(ctx, req)
+}
+
+func (s *Server) handleError(err error) {
+	if s.ErrorHandler != nil {
+		s.ErrorHandler(err)
+		return
+	}
+	log.Printf("netconf: %v", err)
+}
+
+func notSupported(req *Request) netconf.RPCError {
+	return netconf.RPCError{
+		Type:     netconf.ErrTypeProtocol,
+		Tag:      netconf.ErrOperationNotSupported,
+		Severity: netconf.SevError,
+		Message:  fmt.Sprintf("operation %q is not supported", req.Operation.Local),
+	}
+}
+
+// reply sends the reply for req.  The attributes of the `<rpc>` are returned
+// in the reply as required by RFC6241 section 4.2.
+func (sess *Session) reply(req *Request, body any, err error) error {
+	msg := replyMsg{
+		MessageID: req.MessageID,
+		Attrs:     req.Attrs,
+	}
+
+	if err != nil {
+		sess.outRPCErrors.Add(1)
+		var (
+			rpcErr  netconf.RPCError
+			rpcErrs netconf.RPCErrors
+		)
+		switch {
+		case errors.As(err, &rpcErrs):
+			msg.Errors = rpcErrs
+		case errors.As(err, &rpcErr):
+			msg.Errors = []netconf.RPCError{rpcErr}
+		default:
+			msg.Errors = []netconf.RPCError{{
+				Type:     netconf.ErrTypeApp,
+				Tag:      netconf.ErrOperationFailed,
+				Severity: netconf.SevError,
+				Message:  err.Error(),
+			}}
+		}
+		return sess.write(&msg)
+	}
+
+	switch v := body.(type) {
+	case nil:
+		msg.Body = []byte("<ok/>")
+	case []byte:
+		msg.Body = v
+	case netconf.RawXML:
+		msg.Body = v
+	default:
+		b, err := xml.Marshal(v)
+		if err != nil {
+			return sess.reply(req, nil, fmt.Errorf("failed to encode reply: %w", err))
+		}
+		msg.Body = b
+	}
+	return sess.write(&msg)
+}
+
+func (sess *Session) write(v any) error {
+	sess.writeMu.Lock()
+	defer sess.writeMu.Unlock()
+
+	w, err := sess.tr.MsgWriter()
+	if err != nil {
+		return err
+	}
+	if err := xml.NewEncoder(w).Encode(v); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+func readMsg(tr transport.Transport) ([]byte, error) {
+	r, err := tr.MsgReader()
+	if err != nil {
+		return nil, err
+	}
+	msg, err := io.ReadAll(r)
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+	return msg, r.Close()
+}
+
+// parseRPC finds the operation of a `<rpc>` message.  The request is returned
+// with an error if the `<rpc>` element could be read so that the error can be
+// replied to.
+func parseRPC(msg []byte) (*Request, error) {
+	d := xml.NewDecoder(bytes.NewReader(msg))
+
+	var rpc xml.StartElement
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse rpc: %w", err)
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			rpc = start
+			break
+		}
+	}
+	if rpc.Name != (xml.Name{Space: baseNamespace, Local: "rpc"}) {
+		return nil, fmt.Errorf("unexpected message %q", rpc.Name.Local)
+	}
+
+	req := &Request{}
+	for _, attr := range rpc.Attr {
+		switch {
+		case attr.Name.Space == "" && attr.Name.Local == "message-id":
+			req.MessageID = attr.Value
+		case attr.Name.Space == "" && attr.Name.Local == "xmlns",
+			attr.Name.Space == "xmlns":
+			// namespace declarations aren't copied to the reply but are
+			// kept to decode the operation.
+			req.namespaces = append(req.namespaces, attr)
+		default:
+			req.Attrs = append(req.Attrs, attr)
+		}
+	}
+
+	for {
+		offset := d.InputOffset()
+		tok, err := d.Token()
+		if err != nil {
+			return req, fmt.Errorf("failed to parse rpc: %w", err)
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			if err := d.Skip(); err != nil {
+				return req, fmt.Errorf("failed to parse rpc: %w", err)
+			}
+			req.Operation = tok.Name
+			req.Body = msg[offset:d.InputOffset()]
+			return req, nil
+		case xml.EndElement:
+			return req, fmt.Errorf("rpc has no operation")
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package server
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/nemith/netconf"
+	"github.com/nemith/netconf/transport"
+	"github.com/nemith/netconf/transport/transporttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// serve serves a session with srv over an in-memory pipe and opens a client
+// session to it.  The returned channel receives the result of ServeTransport.
+func serve(t *testing.T, srv *Server, opts ...netconf.SessionOption) (*netconf.Session, <-chan error) {
+	t.Helper()
+	client, server := transporttest.Pipe()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	finished := make(chan struct{})
+	go func() {
+		done <- srv.ServeTransport(ctx, server, Peer{Username: "admin"})
+		close(finished)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		select {
+		case <-finished:
+		case <-time.After(5 * time.Second):
+			t.Error("ServeTransport didn't return")
+		}
+	})
+
+	sess, err := netconf.Open(client, opts...)
+	require.NoError(t, err)
+	return sess, done
+}
+
+type echoReq struct {
+	XMLName xml.Name `xml:"urn:example echo"`
+	Value   string   `xml:"value"`
+}
+
+type echoReply struct {
+	XMLName xml.Name `xml:"urn:example echoed"`
+	Value   string   `xml:"value"`
+}
+
+func TestServer(t *testing.T) {
+	var got *Request
+	mux := NewMux()
+	mux.HandleFunc(xml.Name{Space: "urn:example", Local: "echo"}, func(ctx context.Context, req *Request) (any, error) {
+		got = req
+		var echo echoReq
+		if err := req.Decode(&echo); err != nil {
+			return nil, err
+		}
+		return echoReply{Value: echo.Value}, nil
+	})
+	mux.HandleFunc(xml.Name{Local: "noop"}, func(context.Context, *Request) (any, error) { return nil, nil })
+	mux.HandleFunc(xml.Name{Local: "raw"}, func(context.Context, *Request) (any, error) {
+		return []byte("<data><x/></data>"), nil
+	})
+
+	srv := &Server{Handler: mux, Capabilities: []string{"urn:ietf:params:netconf:capability:candidate:1.0"}}
+	sess, done := serve(t, srv)
+
+	assert.Equal(t, uint64(1), sess.SessionID())
+	assert.Contains(t, sess.ServerCapabilities(), "urn:ietf:params:netconf:capability:candidate:1.0")
+
+	var reply echoReply
+	require.NoError(t, sess.Call(context.Background(), &echoReq{Value: "hello"}, &reply))
+	assert.Equal(t, "hello", reply.Value)
+	require.NotNil(t, got)
+	assert.Equal(t, xml.Name{Space: "urn:example", Local: "echo"}, got.Operation)
+	assert.Equal(t, "admin", got.Session.Username)
+	assert.Contains(t, got.Session.ClientCapabilities, "urn:ietf:params:netconf:base:1.1")
+
+	r, err := sess.Do(context.Background(), &struct {
+		XMLName xml.Name `xml:"urn:other noop"`
+	}{})
+	require.NoError(t, err)
+	assert.Equal(t, "<ok/>", string(r.Body))
+
+	r, err = sess.Do(context.Background(), &struct {
+		XMLName xml.Name `xml:"raw"`
+	}{})
+	require.NoError(t, err)
+	assert.Equal(t, "<data><x/></data>", string(r.Body))
+
+	require.NoError(t, sess.Close(context.Background()))
+	assert.NoError(t, <-done)
+}
+
+func TestServerErrors(t *testing.T) {
+	mux := NewMux()
+	mux.HandleFunc(xml.Name{Local: "rpc-error"}, func(context.Context, *Request) (any, error) {
+		return nil, netconf.RPCError{
+			Type:     netconf.ErrTypeApp,
+			Tag:      netconf.ErrDataMissing,
+			Severity: netconf.SevError,
+			Message:  "not there",
+		}
+	})
+	mux.HandleFunc(xml.Name{Local: "plain-error"}, func(context.Context, *Request) (any, error) {
+		return nil, errors.New("broken")
+	})
+	mux.HandleFunc(xml.Name{Local: "panic"}, func(context.Context, *Request) (any, error) {
+		panic("boom")
+	})
+
+	var errs []error
+	srv := &Server{Handler: mux, ErrorHandler: func(err error) { errs = append(errs, err) }}
+	sess, _ := serve(t, srv)
+
+	call := func(name string) netconf.RPCError {
+		t.Helper()
+		err := sess.Call(context.Background(), &struct {
+			XMLName xml.Name
+		}{XMLName: xml.Name{Space: "urn:example", Local: name}}, &netconf.OKResp{})
+		var rpcErr netconf.RPCError
+		require.ErrorAs(t, err, &rpcErr)
+		return rpcErr
+	}
+
+	rpcErr := call("rpc-error")
+	assert.Equal(t, netconf.ErrDataMissing, rpcErr.Tag)
+	assert.Equal(t, "not there", rpcErr.Message)
+
+	rpcErr = call("plain-error")
+	assert.Equal(t, netconf.ErrOperationFailed, rpcErr.Tag)
+	assert.Equal(t, "broken", rpcErr.Message)
+
+	rpcErr = call("panic")
+	assert.Equal(t, netconf.ErrOperationFailed, rpcErr.Tag)
+	require.Len(t, errs, 1)
+	var perr *netconf.PanicError
+	assert.ErrorAs(t, errs[0], &perr)
+
+	rpcErr = call("unknown")
+	assert.Equal(t, netconf.ErrOperationNotSupported, rpcErr.Tag)
+
+	require.NoError(t, sess.Close(context.Background()))
+}
+
+func TestServerBadHello(t *testing.T) {
+	client, server := transporttest.Pipe()
+	defer client.Close()
+
+	done := make(chan error, 1)
+	go func() { done <- (&Server{}).ServeTransport(context.Background(), server, Peer{}) }()
+
+	send := func(tr *transport.PipeTransport, msg string) {
+		w, err := tr.MsgWriter()
+		require.NoError(t, err)
+		_, err = io.WriteString(w, msg)
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+	}
+
+	// the server hello is sent while the client's is read.
+	go send(client, `<hello xmlns="urn:ietf:params:xml:ns:netconf:base:1.0"><capabilities><capability>urn:ietf:params:netconf:base:2.0</capability></capabilities></hello>`)
+	r, err := client.MsgReader()
+	require.NoError(t, err)
+	hello, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Contains(t, string(hello), "<session-id>1</session-id>")
+
+	assert.ErrorIs(t, <-done, ErrBadHello)
+}
+
+func TestServerNoHandler(t *testing.T) {
+	var errs []error
+	sess, _ := serve(t, &Server{ErrorHandler: func(err error) { errs = append(errs, err) }})
+
+	err := sess.Call(context.Background(), &struct {
+		XMLName xml.Name `xml:"urn:example empty"`
+	}{}, &netconf.OKResp{})
+	var rpcErr netconf.RPCError
+	require.ErrorAs(t, err, &rpcErr)
+	assert.Equal(t, netconf.ErrOperationNotSupported, rpcErr.Tag)
+
+	require.NoError(t, sess.Close(context.Background()))
+	assert.Empty(t, errs)
+}
+
+func TestParseRPC(t *testing.T) {
+	req, err := parseRPC([]byte(`<rpc xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" xmlns:ex="urn:example" message-id="101" ex:user-id="fred"><get-config><source><running/></source></get-config></rpc>`))
+	require.NoError(t, err)
+	assert.Equal(t, "101", req.MessageID)
+	assert.Equal(t, xml.Name{Space: "urn:ietf:params:xml:ns:netconf:base:1.0", Local: "get-config"}, req.Operation)
+	assert.Equal(t, "<get-config><source><running/></source></get-config>", string(req.Body))
+	assert.Equal(t, []xml.Attr{{Name: xml.Name{Space: "urn:example", Local: "user-id"}, Value: "fred"}}, req.Attrs)
+
+	var getConfig struct {
+		XMLName xml.Name `xml:"urn:ietf:params:xml:ns:netconf:base:1.0 get-config"`
+		Source  struct {
+			Running *struct{} `xml:"running"`
+		} `xml:"source"`
+	}
+	require.NoError(t, req.Decode(&getConfig))
+	assert.NotNil(t, getConfig.Source.Running)
+
+	req, err = parseRPC([]byte(`<rpc xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="102"></rpc>`))
+	assert.Error(t, err)
+	require.NotNil(t, req)
+	assert.Equal(t, "102", req.MessageID)
+
+	req, err = parseRPC([]byte(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0"/>`))
+	assert.Error(t, err)
+	assert.Nil(t, req)
+}
+
+func TestRequestDecodePrefixes(t *testing.T) {
+	req, err := parseRPC([]byte(`<rpc xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" xmlns:if="urn:ietf:params:xml:ns:yang:ietf-interfaces" message-id="1"><edit-config><config><if:interfaces><if:interface><if:name>eth0</if:name></if:interface></if:interfaces></config></edit-config></rpc>`))
+	require.NoError(t, err)
+
+	var edit struct {
+		XMLName    xml.Name `xml:"urn:ietf:params:xml:ns:netconf:base:1.0 edit-config"`
+		Interfaces struct {
+			Names []string `xml:"urn:ietf:params:xml:ns:yang:ietf-interfaces interface>name"`
+		} `xml:"config>interfaces"`
+	}
+	require.NoError(t, req.Decode(&edit))
+	assert.Equal(t, []string{"eth0"}, edit.Interfaces.Names)
+	assert.Empty(t, req.Attrs)
+}
+
+func TestServerPrefixedPayload(t *testing.T) {
+	var got echoReq
+	mux := NewMux()
+	mux.HandleFunc(xml.Name{Space: "urn:example", Local: "echo"}, func(ctx context.Context, req *Request) (any, error) {
+		if err := req.Decode(&got); err != nil {
+			return nil, err
+		}
+		return echoReply{Value: got.Value}, nil
+	})
+
+	sess, _ := serve(t, &Server{Handler: mux},
+		netconf.WithNamespacePrefixes(map[string]string{"ex": "urn:example"}),
+		netconf.WithRequestEcho())
+
+	r, err := sess.Do(context.Background(), &echoReq{Value: "hello"})
+	require.NoError(t, err)
+	require.NoError(t, r.Err())
+	assert.Contains(t, string(r.RequestRaw()), "<ex:echo><ex:value>hello</ex:value></ex:echo>")
+	assert.Equal(t, "hello", got.Value)
+}
+
+func TestServerBaseVersions(t *testing.T) {
+	srv := &Server{
+		Handler: HandlerFunc(func(context.Context, *Request) (any, error) { return nil, nil }),
+		Capabilities: netconf.NewCapabilityBuilder().
+			Base("1.0").
+			Add(":candidate:1.0").
+			Build(),
+	}
+	sess, _ := serve(t, srv)
+
+	// the client speaks 1.1 but the server limits the session to 1.0.
+	assert.Equal(t, []string{
+		"urn:ietf:params:netconf:base:1.0",
+		"urn:ietf:params:netconf:capability:candidate:1.0",
+	}, sess.ServerCapabilities())
+
+	ctx := context.Background()
+	_, err := sess.Do(ctx, &echoReq{Value: "hello"})
+	require.NoError(t, err)
+	assert.NoError(t, sess.Close(ctx))
+}
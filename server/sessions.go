@@ -0,0 +1,211 @@
+package server
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nemith/netconf"
+)
+
+// MonitoringNamespace is the namespace of the ietf-netconf-monitoring YANG
+// module (RFC6022).
+const MonitoringNamespace = "urn:ietf:params:xml:ns:yang:ietf-netconf-monitoring"
+
+// Transports of a session as the ietf-netconf-monitoring identities.
+const (
+	TransportSSH = "netconf-ssh"
+	TransportTLS = "netconf-tls"
+)
+
+var (
+	// ErrSessionKilled is returned from [Server.ServeTransport] when the
+	// session was killed with `<kill-session>` or [Server.KillSession].
+	ErrSessionKilled = errors.New("netconf: session killed")
+
+	// ErrUnknownSession is returned from [Server.KillSession] for a session
+	// id that isn't being served.
+	ErrUnknownSession = errors.New("netconf: unknown session")
+)
+
+// Sessions returns the sessions currently being served ordered by id.
+func (s *Server) Sessions() []*Session {
+	s.sessMu.Lock()
+	sessions := make([]*Session, 0, len(s.sessions))
+	for _, sess := range s.sessions {
+		sessions = append(sessions, sess)
+	}
+	s.sessMu.Unlock()
+
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].ID < sessions[j].ID })
+	return sessions
+}
+
+// KillSession ends the session with the given id.  Operations in progress on
+// the session are aborted by canceling their context.  It waits for the
+// session to end or ctx to be done.
+func (s *Server) KillSession(ctx context.Context, id uint64) error {
+	return s.killSession(ctx, id, ErrSessionKilled)
+}
+
+func (s *Server) killSession(ctx context.Context, id uint64, cause error) error {
+	s.sessMu.Lock()
+	sess := s.sessions[id]
+	s.sessMu.Unlock()
+	if sess == nil {
+		return fmt.Errorf("%w: %d", ErrUnknownSession, id)
+	}
+
+	sess.cancel(cause)
+	select {
+	case <-sess.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *Server) addSession(sess *Session) {
+	s.sessMu.Lock()
+	defer s.sessMu.Unlock()
+	if s.sessions == nil {
+		s.sessions = make(map[uint64]*Session)
+	}
+	s.sessions[sess.ID] = sess
+}
+
+func (s *Server) removeSession(sess *Session) {
+	s.sessMu.Lock()
+	delete(s.sessions, sess.ID)
+	s.sessMu.Unlock()
+	close(sess.done)
+}
+
+// serveKillSession handles the `<kill-session>` operation (RFC6241 section
+// 7.9).
+func (s *Server) serveKillSession(ctx context.Context, req *Request) error {
+	var kill struct {
+		SessionID string `xml:"session-id"`
+	}
+	if err := req.Decode(&kill); err != nil {
+		return netconf.RPCError{
+			Type:     netconf.ErrTypeRPC,
+			Tag:      netconf.ErrMalformedMessage,
+			Severity: netconf.SevError,
+			Message:  err.Error(),
+		}
+	}
+	if kill.SessionID == "" {
+		return netconf.RPCError{
+			Type:     netconf.ErrTypeProtocol,
+			Tag:      netconf.ErrMissingElement,
+			Severity: netconf.SevError,
+			Info:     netconf.RawXML("<bad-element>session-id</bad-element>"),
+		}
+	}
+
+	invalid := func(msg string) error {
+		return netconf.RPCError{
+			Type:     netconf.ErrTypeProtocol,
+			Tag:      netconf.ErrInvalidValue,
+			Severity: netconf.SevError,
+			Message:  msg,
+		}
+	}
+
+	var id uint64
+	if _, err := fmt.Sscan(kill.SessionID, &id); err != nil || id == 0 {
+		return invalid(fmt.Sprintf("invalid session-id %q", kill.SessionID))
+	}
+	if id == req.Session.ID {
+		return invalid("a session can't kill itself")
+	}
+
+	err := s.killSession(ctx, id, fmt.Errorf("%w by session %d", ErrSessionKilled, req.Session.ID))
+	if errors.Is(err, ErrUnknownSession) {
+		return invalid(fmt.Sprintf("session %d doesn't exist", id))
+	}
+	return err
+}
+
+// NetconfState is the `<netconf-state>` container of ietf-netconf-monitoring
+// with the sessions subtree.  It can be returned from a `<get>` handler with
+// the rest of the device's state.
+type NetconfState struct {
+	XMLName  xml.Name       `xml:"urn:ietf:params:xml:ns:yang:ietf-netconf-monitoring netconf-state"`
+	Sessions []SessionState `xml:"sessions>session"`
+}
+
+// SessionState is an entry of the sessions list of ietf-netconf-monitoring.
+type SessionState struct {
+	SessionID    uint64    `xml:"session-id"`
+	Transport    Identity  `xml:"transport,omitempty"`
+	Username     string    `xml:"username,omitempty"`
+	SourceHost   string    `xml:"source-host,omitempty"`
+	LoginTime    time.Time `xml:"login-time"`
+	InRPCs       uint64    `xml:"in-rpcs"`
+	InBadRPCs    uint64    `xml:"in-bad-rpcs"`
+	OutRPCErrors uint64    `xml:"out-rpc-errors"`
+}
+
+// Identity is an identity of the ietf-netconf-monitoring module (i.e
+// `netconf-ssh`) encoded as an identityref.
+type Identity string
+
+// MarshalXML implements xml.Marshaler declaring the module prefix for the
+// value.
+func (id Identity) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if id == "" {
+		return nil
+	}
+	start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "xmlns:ncm"}, Value: MonitoringNamespace})
+	return e.EncodeElement("ncm:"+string(id), start)
+}
+
+// UnmarshalXML implements xml.Unmarshaler dropping the prefix of the value.
+func (id *Identity) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var v string
+	if err := d.DecodeElement(&v, &start); err != nil {
+		return err
+	}
+	if i := strings.LastIndexByte(v, ':'); i >= 0 {
+		v = v[i+1:]
+	}
+	*id = Identity(v)
+	return nil
+}
+
+// NetconfState returns the sessions subtree of ietf-netconf-monitoring for
+// the sessions currently being served.
+func (s *Server) NetconfState() *NetconfState {
+	state := &NetconfState{Sessions: []SessionState{}}
+	for _, sess := range s.Sessions() {
+		state.Sessions = append(state.Sessions, sess.State())
+	}
+	return state
+}
+
+// State returns the ietf-netconf-monitoring entry of the session.
+func (sess *Session) State() SessionState {
+	state := SessionState{
+		SessionID:    sess.ID,
+		Transport:    Identity(sess.Transport),
+		Username:     sess.Username,
+		LoginTime:    sess.Start,
+		InRPCs:       sess.inRPCs.Load(),
+		InBadRPCs:    sess.inBadRPCs.Load(),
+		OutRPCErrors: sess.outRPCErrors.Load(),
+	}
+	if sess.RemoteAddr != nil {
+		state.SourceHost = sess.RemoteAddr.String()
+		if host, _, err := net.SplitHostPort(state.SourceHost); err == nil {
+			state.SourceHost = host
+		}
+	}
+	return state
+}
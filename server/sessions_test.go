@@ -0,0 +1,145 @@
+package server
+
+import (
+	"context"
+	"encoding/xml"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/nemith/netconf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKillSession(t *testing.T) {
+	started := make(chan struct{})
+	aborted := make(chan error, 1)
+	mux := NewMux()
+	mux.HandleFunc(xml.Name{Local: "wait"}, func(ctx context.Context, req *Request) (any, error) {
+		close(started)
+		<-ctx.Done()
+		aborted <- context.Cause(ctx)
+		return nil, ctx.Err()
+	})
+	srv := &Server{Handler: mux}
+
+	ctx := context.Background()
+	victim, victimDone := serve(t, srv)
+	killer, _ := serve(t, srv)
+	require.Len(t, srv.Sessions(), 2)
+
+	go func() {
+		_, _ = victim.Do(ctx, &struct {
+			XMLName xml.Name `xml:"urn:example wait"`
+		}{})
+	}()
+	<-started
+
+	require.NoError(t, killer.KillSession(ctx, uint32(victim.SessionID())))
+
+	// the operation in progress was aborted and the session ended before the
+	// reply.
+	assert.ErrorIs(t, <-aborted, ErrSessionKilled)
+	assert.Len(t, srv.Sessions(), 1)
+	select {
+	case err := <-victimDone:
+		assert.ErrorIs(t, err, ErrSessionKilled)
+		assert.ErrorContains(t, err, "by session 2")
+	case <-time.After(5 * time.Second):
+		t.Fatal("killed session didn't end")
+	}
+
+	assert.Equal(t, uint64(2), srv.Sessions()[0].ID)
+
+	assert.NoError(t, killer.Close(ctx))
+}
+
+func TestKillSessionErrors(t *testing.T) {
+	srv := &Server{}
+	sess, _ := serve(t, srv)
+	ctx := context.Background()
+
+	tt := []struct {
+		name string
+		req  string
+		tag  netconf.ErrTag
+	}{
+		{"self", "<session-id>1</session-id>", netconf.ErrInvalidValue},
+		{"unknown", "<session-id>42</session-id>", netconf.ErrInvalidValue},
+		{"invalid", "<session-id>one</session-id>", netconf.ErrInvalidValue},
+		{"missing", "", netconf.ErrMissingElement},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			reply, err := sess.Do(ctx, &struct {
+				XMLName xml.Name `xml:"urn:ietf:params:xml:ns:netconf:base:1.0 kill-session"`
+				Body    string   `xml:",innerxml"`
+			}{Body: tc.req})
+			require.NoError(t, err)
+
+			var rpcErr netconf.RPCError
+			require.ErrorAs(t, reply.Err(), &rpcErr)
+			assert.Equal(t, tc.tag, rpcErr.Tag)
+		})
+	}
+
+	assert.ErrorIs(t, srv.KillSession(ctx, 42), ErrUnknownSession)
+}
+
+func TestServerKillSession(t *testing.T) {
+	srv := &Server{}
+	sess, done := serve(t, srv)
+
+	require.NoError(t, srv.KillSession(context.Background(), sess.SessionID()))
+	assert.ErrorIs(t, <-done, ErrSessionKilled)
+
+	select {
+	case <-sess.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("client session wasn't closed")
+	}
+}
+
+func TestNetconfState(t *testing.T) {
+	srv := &Server{}
+	sess, _ := serve(t, srv)
+	ctx := context.Background()
+
+	_, err := sess.Do(ctx, &struct {
+		XMLName xml.Name `xml:"urn:example unknown"`
+	}{})
+	require.NoError(t, err)
+
+	state := srv.NetconfState()
+	require.Len(t, state.Sessions, 1)
+	got := state.Sessions[0]
+	assert.Equal(t, uint64(1), got.SessionID)
+	assert.Equal(t, "admin", got.Username)
+	assert.Equal(t, uint64(1), got.InRPCs)
+	assert.Equal(t, uint64(1), got.OutRPCErrors)
+	assert.WithinDuration(t, time.Now(), got.LoginTime, time.Minute)
+
+	ssh := (&Session{
+		Peer: Peer{
+			Username:   "admin",
+			RemoteAddr: &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 4242},
+			Transport:  TransportSSH,
+		},
+		ID:    7,
+		Start: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	}).State()
+	out, err := xml.Marshal(&NetconfState{Sessions: []SessionState{ssh}})
+	require.NoError(t, err)
+	assert.Equal(t, `<netconf-state xmlns="urn:ietf:params:xml:ns:yang:ietf-netconf-monitoring"><sessions><session>`+
+		`<session-id>7</session-id>`+
+		`<transport xmlns:ncm="urn:ietf:params:xml:ns:yang:ietf-netconf-monitoring">ncm:netconf-ssh</transport>`+
+		`<username>admin</username><source-host>192.0.2.1</source-host>`+
+		`<login-time>2024-01-02T03:04:05Z</login-time>`+
+		`<in-rpcs>0</in-rpcs><in-bad-rpcs>0</in-bad-rpcs><out-rpc-errors>0</out-rpc-errors>`+
+		`</session></sessions></netconf-state>`, string(out))
+
+	var decoded NetconfState
+	require.NoError(t, xml.Unmarshal(out, &decoded))
+	assert.Equal(t, Identity(TransportSSH), decoded.Sessions[0].Transport)
+}
@@ -0,0 +1,131 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/nemith/netconf/transport"
+	"golang.org/x/crypto/ssh"
+)
+
+// SSHSubsystem is the name of the SSH subsystem for NETCONF (RFC6242).
+const SSHSubsystem = "netconf"
+
+// DefaultSSHPort is the IANA assigned port for NETCONF over SSH.
+const DefaultSSHPort = 830
+
+// ServeSSH accepts connections on ln and serves NETCONF over SSH on each of
+// them with [Server.ServeSSHConn] until ctx is done or accepting fails.  ln is
+// closed when ServeSSH returns and it waits for the connections it accepted to
+// end.  Errors from individual connections are sent to the ErrorHandler.
+func (s *Server) ServeSSH(ctx context.Context, ln net.Listener, config *ssh.ServerConfig) error {
+	return s.serveListener(ctx, ln, func(ctx context.Context, conn net.Conn) error {
+		return s.ServeSSHConn(ctx, conn, config)
+	})
+}
+
+// ServeSSHConn runs the SSH server handshake on conn and serves a NETCONF
+// session on every session channel that requests the `netconf` subsystem.
+// Each session is served with its own context derived from ctx and the
+// authenticated user as its [Peer].  It returns once the connection is
+// closed by the client or ctx is done.
+func (s *Server) ServeSSHConn(ctx context.Context, conn net.Conn, config *ssh.ServerConfig) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// close the connection when ctx is done even in the middle of the
+	// handshake.
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+
+	sconn, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		conn.Close()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("ssh handshake with %s failed: %w", conn.RemoteAddr(), err)
+	}
+	defer sconn.Close()
+	go ssh.DiscardRequests(reqs)
+
+	peer := Peer{Username: sconn.User(), RemoteAddr: sconn.RemoteAddr(), Transport: TransportSSH}
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	for newCh := range chans {
+		if newCh.ChannelType() != "session" {
+			_ = newCh.Reject(ssh.UnknownChannelType, "unsupported channel type")
+			continue
+		}
+		ch, chReqs, err := newCh.Accept()
+		if err != nil {
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.serveSSHChannel(ctx, ch, chReqs, peer)
+		}()
+	}
+	return ctx.Err()
+}
+
+// serveSSHChannel waits for the netconf subsystem to be requested on a session
+// channel and serves a session on it.
+func (s *Server) serveSSHChannel(ctx context.Context, ch ssh.Channel, reqs <-chan *ssh.Request, peer Peer) {
+	defer ch.Close()
+
+	for req := range reqs {
+		if req.Type != "subsystem" || !isNetconfSubsystem(req.Payload) {
+			// shells, ptys and environment variables aren't supported.
+			if req.WantReply {
+				_ = req.Reply(false, nil)
+			}
+			continue
+		}
+		if req.WantReply {
+			_ = req.Reply(true, nil)
+		}
+
+		// no more requests are accepted for the channel once the subsystem
+		// has started.
+		go func() {
+			for req := range reqs {
+				if req.WantReply {
+					_ = req.Reply(false, nil)
+				}
+			}
+		}()
+
+		tr := transport.NewPipeTransport(&sshChannel{Channel: ch})
+		if err := s.ServeTransport(ctx, tr, peer); err != nil && !isSessionEnd(err) {
+			s.handleError(fmt.Errorf("session with %s: %w", peer.RemoteAddr, err))
+		}
+		return
+	}
+}
+
+func isNetconfSubsystem(payload []byte) bool {
+	var msg struct{ Name string }
+	return ssh.Unmarshal(payload, &msg) == nil && msg.Name == SSHSubsystem
+}
+
+// sshChannel reports a zero exit status to the client before the channel is
+// closed.
+type sshChannel struct {
+	ssh.Channel
+	once sync.Once
+}
+
+func (c *sshChannel) Close() error {
+	c.once.Do(func() {
+		status := struct{ Status uint32 }{0}
+		_, _ = c.SendRequest("exit-status", false, ssh.Marshal(&status))
+	})
+	return c.Channel.Close()
+}
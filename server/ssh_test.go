@@ -0,0 +1,113 @@
+package server
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/xml"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/nemith/netconf"
+	ncssh "github.com/nemith/netconf/transport/ssh"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+func startSSHServer(t *testing.T, srv *Server) string {
+	t.Helper()
+
+	_, priv, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	signer, err := ssh.NewSignerFromKey(priv)
+	require.NoError(t, err)
+
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if conn.User() == "admin" && string(password) == "secret" {
+				return nil, nil
+			}
+			return nil, assert.AnError
+		},
+	}
+	config.AddHostKey(signer)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- srv.ServeSSH(ctx, ln, config) }()
+	t.Cleanup(func() {
+		cancel()
+		select {
+		case err := <-done:
+			assert.ErrorIs(t, err, context.Canceled)
+		case <-time.After(5 * time.Second):
+			t.Error("ServeSSH didn't return")
+		}
+	})
+
+	return ln.Addr().String()
+}
+
+func sshClientConfig(password string) *ssh.ClientConfig {
+	return &ssh.ClientConfig{
+		User:            "admin",
+		Auth:            []ssh.AuthMethod{ssh.Password(password)},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	}
+}
+
+func TestServeSSH(t *testing.T) {
+	users := make(chan string, 1)
+	mux := NewMux()
+	mux.HandleFunc(xml.Name{Local: "whoami"}, func(ctx context.Context, req *Request) (any, error) {
+		users <- req.Session.Username
+		return nil, nil
+	})
+	addr := startSSHServer(t, &Server{Handler: mux})
+
+	ctx := context.Background()
+	tr, err := ncssh.Dial(ctx, "tcp", addr, sshClientConfig("secret"))
+	require.NoError(t, err)
+
+	sess, err := netconf.Open(tr)
+	require.NoError(t, err)
+
+	_, err = sess.Do(ctx, &struct {
+		XMLName xml.Name `xml:"urn:example whoami"`
+	}{})
+	require.NoError(t, err)
+	assert.Equal(t, "admin", <-users)
+
+	// the server reports a zero exit status so closing doesn't fail.
+	assert.NoError(t, sess.Close(ctx))
+}
+
+func TestServeSSHAuthFailure(t *testing.T) {
+	addr := startSSHServer(t, &Server{ErrorHandler: func(error) {}})
+
+	_, err := ncssh.Dial(context.Background(), "tcp", addr, sshClientConfig("wrong"))
+	assert.Error(t, err)
+}
+
+func TestServeSSHOtherRequests(t *testing.T) {
+	addr := startSSHServer(t, &Server{})
+
+	client, err := ssh.Dial("tcp", addr, sshClientConfig("secret"))
+	require.NoError(t, err)
+	defer client.Close()
+
+	sess, err := client.NewSession()
+	require.NoError(t, err)
+	defer sess.Close()
+	assert.Error(t, sess.Shell())
+	assert.Error(t, sess.RequestSubsystem("sftp"))
+
+	_, _, err = client.OpenChannel("direct-tcpip", nil)
+	var openErr *ssh.OpenChannelError
+	require.ErrorAs(t, err, &openErr)
+	assert.Equal(t, ssh.UnknownChannelType, openErr.Reason)
+}
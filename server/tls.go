@@ -0,0 +1,83 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+
+	nctls "github.com/nemith/netconf/transport/tls"
+)
+
+// DefaultTLSPort is the IANA assigned port for NETCONF over TLS.
+const DefaultTLSPort = 6513
+
+// ErrUnauthorized is returned when a client isn't allowed to open a session.
+var ErrUnauthorized = errors.New("netconf: client not authorized")
+
+// TLSAuthFunc authenticates the client of a NETCONF over TLS session from its
+// certificate chain (leaf first) and returns the username of the session.
+// The chain is verified against the ClientCAs of the config unless the
+// config only requires a certificate to be presented.  An error rejects the
+// client.
+type TLSAuthFunc func(chain []*x509.Certificate) (username string, err error)
+
+// CertToNameAuth returns a TLSAuthFunc deriving the username from the client
+// certificate with the cert-to-name entries (see [nctls.MapCertToName]) as
+// described in RFC7589.  Clients that no entry maps are rejected.
+func CertToNameAuth(entries []nctls.CertToName) TLSAuthFunc {
+	return func(chain []*x509.Certificate) (string, error) {
+		return nctls.MapCertToName(chain, entries)
+	}
+}
+
+// ServeTLS accepts connections on ln and serves NETCONF over TLS on each of
+// them with [Server.ServeTLSConn] until ctx is done or accepting fails.  ln is
+// closed when ServeTLS returns and it waits for the connections it accepted to
+// end.  Errors from individual connections are sent to the ErrorHandler.
+func (s *Server) ServeTLS(ctx context.Context, ln net.Listener, config *tls.Config, auth TLSAuthFunc) error {
+	return s.serveListener(ctx, ln, func(ctx context.Context, conn net.Conn) error {
+		return s.ServeTLSConn(ctx, conn, config, auth)
+	})
+}
+
+// ServeTLSConn runs the TLS server handshake on conn, authenticates the client
+// with auth and serves a NETCONF session until it ends.  RFC7589 requires
+// mutual authentication so a config that doesn't ask for client certificates
+// is made to require and verify them.  If auth is nil the session has no
+// username.
+func (s *Server) ServeTLSConn(ctx context.Context, conn net.Conn, config *tls.Config, auth TLSAuthFunc) error {
+	if config.ClientAuth == tls.NoClientCert {
+		config = config.Clone()
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	tlsConn := tls.Server(conn, config)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("tls handshake with %s failed: %w", conn.RemoteAddr(), err)
+	}
+
+	state := tlsConn.ConnectionState()
+	chain := state.PeerCertificates
+	if len(state.VerifiedChains) > 0 {
+		chain = state.VerifiedChains[0]
+	}
+
+	peer := Peer{RemoteAddr: conn.RemoteAddr(), Transport: TransportTLS, Certificates: chain}
+	if auth != nil {
+		username, err := auth(chain)
+		if err != nil {
+			tlsConn.Close()
+			return fmt.Errorf("%w: %s: %w", ErrUnauthorized, conn.RemoteAddr(), err)
+		}
+		peer.Username = username
+	}
+
+	return s.ServeTransport(ctx, nctls.NewTransport(tlsConn), peer)
+}
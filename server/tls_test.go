@@ -0,0 +1,170 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/xml"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/nemith/netconf"
+	nctls "github.com/nemith/netconf/transport/tls"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestCert(t *testing.T, tmpl *x509.Certificate, parent *tls.Certificate) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl.SerialNumber = big.NewInt(time.Now().UnixNano())
+	tmpl.NotBefore = time.Now().Add(-time.Hour)
+	tmpl.NotAfter = time.Now().Add(time.Hour)
+
+	parentCert, parentKey := tmpl, any(key)
+	if parent != nil {
+		parentCert, parentKey = parent.Leaf, parent.PrivateKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parentCert, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: cert}
+}
+
+type tlsFixture struct {
+	ca, server, client tls.Certificate
+	pool               *x509.CertPool
+}
+
+func newTLSFixture(t *testing.T) *tlsFixture {
+	ca := newTestCert(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "test ca"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Leaf)
+
+	return &tlsFixture{
+		ca:   ca,
+		pool: pool,
+		server: newTestCert(t, &x509.Certificate{
+			Subject:     pkix.Name{CommonName: "router1"},
+			IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+			ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		}, &ca),
+		client: newTestCert(t, &x509.Certificate{
+			Subject:     pkix.Name{CommonName: "operator"},
+			ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		}, &ca),
+	}
+}
+
+func startTLSServer(t *testing.T, srv *Server, config *tls.Config, auth TLSAuthFunc) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- srv.ServeTLS(ctx, ln, config, auth) }()
+	t.Cleanup(func() {
+		cancel()
+		select {
+		case err := <-done:
+			assert.ErrorIs(t, err, context.Canceled)
+		case <-time.After(5 * time.Second):
+			t.Error("ServeTLS didn't return")
+		}
+	})
+	return ln.Addr().String()
+}
+
+func TestServeTLS(t *testing.T) {
+	fix := newTLSFixture(t)
+
+	peers := make(chan Peer, 1)
+	mux := NewMux()
+	mux.HandleFunc(xml.Name{Local: "whoami"}, func(ctx context.Context, req *Request) (any, error) {
+		peers <- req.Session.Peer
+		return nil, nil
+	})
+
+	// the server config doesn't ask for client certificates but they are
+	// required anyway.
+	addr := startTLSServer(t, &Server{Handler: mux},
+		&tls.Config{Certificates: []tls.Certificate{fix.server}, ClientCAs: fix.pool},
+		CertToNameAuth([]nctls.CertToName{
+			{ID: 1, Fingerprint: nctls.Fingerprint(fix.ca.Leaf), MapType: nctls.MapCommonName},
+		}))
+
+	ctx := context.Background()
+	tr, err := nctls.Dial(ctx, "tcp", addr, &tls.Config{
+		Certificates: []tls.Certificate{fix.client},
+		RootCAs:      fix.pool,
+	})
+	require.NoError(t, err)
+
+	sess, err := netconf.Open(tr)
+	require.NoError(t, err)
+	_, err = sess.Do(ctx, &struct {
+		XMLName xml.Name `xml:"urn:example whoami"`
+	}{})
+	require.NoError(t, err)
+
+	peer := <-peers
+	assert.Equal(t, "operator", peer.Username)
+	require.Len(t, peer.Certificates, 2)
+	assert.Equal(t, "operator", peer.Certificates[0].Subject.CommonName)
+	assert.NotNil(t, peer.RemoteAddr)
+
+	assert.NoError(t, sess.Close(ctx))
+}
+
+func TestServeTLSUnauthorized(t *testing.T) {
+	fix := newTLSFixture(t)
+
+	errs := make(chan error, 2)
+	srv := &Server{ErrorHandler: func(err error) { errs <- err }}
+	addr := startTLSServer(t, srv,
+		&tls.Config{Certificates: []tls.Certificate{fix.server}, ClientCAs: fix.pool},
+		CertToNameAuth([]nctls.CertToName{
+			{ID: 1, Fingerprint: nctls.Fingerprint(fix.server.Leaf), MapType: nctls.MapCommonName},
+		}))
+
+	ctx := context.Background()
+	tr, err := nctls.Dial(ctx, "tcp", addr, &tls.Config{
+		Certificates: []tls.Certificate{fix.client},
+		RootCAs:      fix.pool,
+	})
+	require.NoError(t, err)
+	_, err = netconf.Open(tr)
+	assert.Error(t, err)
+
+	select {
+	case err := <-errs:
+		assert.ErrorIs(t, err, ErrUnauthorized)
+		assert.ErrorIs(t, err, nctls.ErrNoIdentity)
+	case <-time.After(5 * time.Second):
+		t.Fatal("client wasn't rejected")
+	}
+
+	// clients without a certificate fail the handshake.
+	_, err = nctls.Dial(ctx, "tcp", addr, &tls.Config{RootCAs: fix.pool})
+	if err == nil {
+		// TLS 1.3 reports the missing certificate after the client's side of
+		// the handshake is done.
+		err = <-errs
+	}
+	assert.Error(t, err)
+}
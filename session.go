@@ -6,20 +6,50 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net"
+	"os"
 	"sync"
 	"sync/atomic"
 	"syscall"
+	"time"
 
+	"github.com/nemith/netconf/clock"
 	"github.com/nemith/netconf/transport"
 )
 
 var ErrClosed = errors.New("closed connection")
 
 type sessionConfig struct {
-	capabilities        []string
-	notificationHandler NotificationHandler
+	capabilities         []string
+	notificationHandler  NotificationHandler
+	metrics              Metrics
+	interceptors         []Interceptor
+	errorHandler         ErrorHandler
+	interleavePolicy     InterleavePolicy
+	eventBus             *EventBus
+	labels               map[string]string
+	provenance           *provenanceOpt
+	rpcTimeout           time.Duration
+	maxInFlight          int
+	inFlightPolicy       InFlightPolicy
+	namespacePolicy      NamespacePolicy
+	messageIDFunc        MessageIDFunc
+	unmatchedReplyPolicy UnmatchedReplyPolicy
+	serializeDatastores  bool
+	messageHandlers      map[xml.Name]MessageHandler
+	maxMessageSize       int64
+	requestEcho          bool
+	requestValidation    RequestValidation
+	idleTimeout          time.Duration
+	decoderConfigs       []func(*xml.Decoder)
+	captureIn            io.Writer
+	captureOut           io.Writer
+	notifQueueSize       int
+	notifQueuePolicy     NotificationQueuePolicy
+	slowConsumerHandler  SlowConsumerHandler
+	notificationFilters  []NotificationFilter
+	namespacePrefixes    map[string]string
+	clock                clock.Clock
 }
 
 type SessionOption interface {
@@ -38,14 +68,80 @@ func WithCapability(capabilities ...string) SessionOption {
 	return capabilityOpt(capabilities)
 }
 
-type notificationHandlerOpt NotificationHandler
+type notificationHandlerOpt struct {
+	h       NotificationHandler
+	filters []NotificationFilter
+}
 
 func (o notificationHandlerOpt) apply(cfg *sessionConfig) {
-	cfg.notificationHandler = NotificationHandler(o)
+	cfg.notificationHandler = o.h
+	cfg.notificationFilters = o.filters
+}
+
+// WithNotificationHandler sets the handler notifications are delivered to.
+// Notifications that don't match every one of the filters are discarded
+// before they are queued or given to the handler (see [NotificationFilter]).
+func WithNotificationHandler(nh NotificationHandler, filters ...NotificationFilter) SessionOption {
+	return notificationHandlerOpt{h: nh, filters: filters}
+}
+
+type rpcTimeoutOpt time.Duration
+
+func (o rpcTimeoutOpt) apply(cfg *sessionConfig) { cfg.rpcTimeout = time.Duration(o) }
+
+// WithDefaultRPCTimeout sets a timeout for rpcs issued with a context that
+// doesn't already have a deadline (i.e `context.Background()`).  Without it a
+// rpc to an unresponsive device waits forever.
+//
+// As with any rpc context, if the timeout expires while the reply is being
+// read on a transport that supports deadlines the read is interrupted and the
+// session is closed (see [Session.Do]), so d should be long enough for the
+// largest expected reply to be received.
+func WithDefaultRPCTimeout(d time.Duration) SessionOption { return rpcTimeoutOpt(d) }
+
+type clockOpt struct{ c clock.Clock }
+
+func (o clockOpt) apply(cfg *sessionConfig) { cfg.clock = o.c }
+
+// WithClock sets the clock used for rpc timeouts, the idle timeout,
+// confirmed commit deadlines and the timestamps recorded by the session.  It
+// defaults to the system clock.  Use a [clock.Fake] in tests to control time
+// without sleeping.
+func WithClock(c clock.Clock) SessionOption { return clockOpt{c} }
+
+// ErrTooManyRequests is returned when the limit of in-flight rpcs set with
+// [WithMaxInFlight] has been reached and the policy is [InFlightReject].
+var ErrTooManyRequests = errors.New("netconf: too many in-flight requests")
+
+// InFlightPolicy controls what happens when issuing a rpc when the limit of
+// in-flight rpcs has been reached.
+type InFlightPolicy int
+
+const (
+	// InFlightWait blocks until another rpc completes or the context is
+	// done.
+	InFlightWait InFlightPolicy = iota
+
+	// InFlightReject fails the rpc with [ErrTooManyRequests].
+	InFlightReject
+)
+
+type maxInFlightOpt struct {
+	n      int
+	policy InFlightPolicy
 }
 
-func WithNotificationHandler(nh NotificationHandler) SessionOption {
-	return notificationHandlerOpt(nh)
+func (o maxInFlightOpt) apply(cfg *sessionConfig) {
+	cfg.maxInFlight = o.n
+	cfg.inFlightPolicy = o.policy
+}
+
+// WithMaxInFlight limits the number of rpcs that can be outstanding (sent but
+// without a reply) at once.  By default rpcs are pipelined without limit
+// however some devices silently drop requests beyond a small window.  A limit
+// of 0 or less means no limit.
+func WithMaxInFlight(n int, policy InFlightPolicy) SessionOption {
+	return maxInFlightOpt{n: n, policy: policy}
 }
 
 // Session is represents a netconf session to a one given device.
@@ -54,13 +150,66 @@ type Session struct {
 	sessionID uint64
 	seq       atomic.Uint64
 
-	clientCaps          capabilitySet
-	serverCaps          capabilitySet
-	notificationHandler NotificationHandler
-
-	mu      sync.Mutex
-	reqs    map[uint64]*req
-	closing bool
+	clientCaps           CapabilitySet
+	serverCaps           CapabilitySet
+	notificationHandler  NotificationHandler
+	metrics              Metrics
+	interceptors         []Interceptor
+	errorHandler         ErrorHandler
+	interleavePolicy     InterleavePolicy
+	subscribed           atomic.Bool
+	eventBus             *EventBus
+	labels               map[string]string
+	provenance           *provenanceOpt
+	rpcTimeout           time.Duration
+	inFlight             chan struct{}
+	inFlightPolicy       InFlightPolicy
+	namespacePolicy      NamespacePolicy
+	messageIDFunc        MessageIDFunc
+	unmatchedReplyPolicy UnmatchedReplyPolicy
+	datastoreQueues      *datastoreQueues
+	messageHandlers      map[xml.Name]MessageHandler
+	maxMessageSize       int64
+	requestEcho          bool
+	requestValidation    RequestValidation
+	idleTimeout          time.Duration
+	decoderConfigs       []func(*xml.Decoder)
+	rpcNamespaces        []xml.Attr
+	clock                clock.Clock
+	lastSent             atomic.Int64
+	lastRecv             atomic.Int64
+
+	writeQ chan *outMsg
+
+	notifQueue          *notificationQueue
+	slowConsumerHandler SlowConsumerHandler
+
+	notificationFilters []NotificationFilter
+	filteredNotifs      atomic.Uint64
+
+	capMu      sync.Mutex
+	captureIn  io.Writer
+	captureOut io.Writer
+
+	unknownMu     sync.Mutex
+	unknownCounts map[xml.Name]uint64
+
+	nsMu         sync.Mutex
+	nsDeviations map[NamespaceError]*NamespaceDeviation
+
+	mu         sync.Mutex
+	reqs       map[string]*req
+	closing    bool
+	draining   bool
+	pending    sync.WaitGroup
+	done       chan struct{}
+	notifHolds int
+
+	// pendingNotifs are the notifications waiting to be delivered to the
+	// handler and delivering is set while a goroutine is delivering them so
+	// they are delivered one at a time and in order.
+	pendingNotifs []Notification
+	delivering    bool
 }
 
 // NotificationHandler function allows to work with received notifications.
@@ -71,7 +220,9 @@ type NotificationHandler func(msg Notification)
 
 func newSession(transport transport.Transport, opts ...SessionOption) *Session {
 	cfg := sessionConfig{
-		capabilities: DefaultCapabilities,
+		capabilities:  DefaultCapabilities,
+		metrics:       nopMetrics{},
+		messageIDFunc: SequentialMessageID,
 	}
 
 	for _, opt := range opts {
@@ -79,10 +230,49 @@ func newSession(transport transport.Transport, opts ...SessionOption) *Session {
 	}
 
 	s := &Session{
-		tr:                  transport,
-		clientCaps:          newCapabilitySet(cfg.capabilities...),
-		reqs:                make(map[uint64]*req),
-		notificationHandler: cfg.notificationHandler,
+		tr:                   transport,
+		clientCaps:           NewCapabilitySet(cfg.capabilities...),
+		reqs:                 make(map[string]*req),
+		done:                 make(chan struct{}),
+		writeQ:               make(chan *outMsg),
+		notificationHandler:  cfg.notificationHandler,
+		metrics:              cfg.metrics,
+		interceptors:         cfg.interceptors,
+		errorHandler:         cfg.errorHandler,
+		interleavePolicy:     cfg.interleavePolicy,
+		eventBus:             cfg.eventBus,
+		labels:               cfg.labels,
+		provenance:           cfg.provenance,
+		rpcTimeout:           cfg.rpcTimeout,
+		inFlightPolicy:       cfg.inFlightPolicy,
+		namespacePolicy:      cfg.namespacePolicy,
+		messageIDFunc:        cfg.messageIDFunc,
+		unmatchedReplyPolicy: cfg.unmatchedReplyPolicy,
+		messageHandlers:      cfg.messageHandlers,
+		maxMessageSize:       cfg.maxMessageSize,
+		requestEcho:          cfg.requestEcho,
+		requestValidation:    cfg.requestValidation,
+		idleTimeout:          cfg.idleTimeout,
+		decoderConfigs:       cfg.decoderConfigs,
+		rpcNamespaces:        namespaceAttrs(cfg.namespacePrefixes),
+		slowConsumerHandler:  cfg.slowConsumerHandler,
+		notificationFilters:  cfg.notificationFilters,
+		clock:                clock.Or(cfg.clock),
+	}
+	if cfg.notifQueueSize > 0 {
+		s.notifQueue = &notificationQueue{
+			ch:     make(chan Notification, cfg.notifQueueSize),
+			policy: cfg.notifQueuePolicy,
+		}
+	}
+	if cfg.serializeDatastores {
+		s.datastoreQueues = &datastoreQueues{}
+	}
+	if cfg.maxInFlight > 0 {
+		s.inFlight = make(chan struct{}, cfg.maxInFlight)
+	}
+	if cfg.captureIn != nil || cfg.captureOut != nil {
+		s.DebugCapture(cfg.captureIn, cfg.captureOut)
 	}
 	return s
 }
@@ -98,6 +288,8 @@ func Open(transport transport.Transport, opts ...SessionOption) (*Session, error
 		return nil, err
 	}
 
+	s.publish(Event{Type: EventConnected, Capabilities: s.ServerCapabilities()})
+
 	go s.recv()
 	return s, nil
 }
@@ -111,15 +303,32 @@ func (s *Session) handshake() error {
 		return fmt.Errorf("failed to write hello message: %w", err)
 	}
 
-	r, err := s.tr.MsgReader()
+	r, err := s.msgReader()
 	if err != nil {
 		return err
 	}
 	// TODO: capture this error some how (ah defer and errors)
 	defer r.Close()
 
+	br := skipProlog(r)
+	defer putMsgReader(br)
+	dec := s.newDecoder(br)
+	root, err := startElement(dec)
+	if err != nil {
+		return fmt.Errorf("failed to read server hello message: %w", err)
+	}
+	s.lastRecv.Store(s.clock.Now().UnixNano())
+
+	ok, err := s.matchName(root, xml.Name{Space: ncNamespace, Local: "hello"})
+	if err != nil {
+		return fmt.Errorf("invalid server hello message: %w", err)
+	}
+	if !ok {
+		return fmt.Errorf("expected server hello message, got %q", root.Name.Local)
+	}
+
 	var serverMsg helloMsg
-	if err := xml.NewDecoder(r).Decode(&serverMsg); err != nil {
+	if err := dec.DecodeElement(&serverMsg, root); err != nil {
 		return fmt.Errorf("failed to read server hello message: %w", err)
 	}
 
@@ -131,16 +340,19 @@ func (s *Session) handshake() error {
 		return fmt.Errorf("server did not return any capabilities")
 	}
 
-	s.serverCaps = newCapabilitySet(serverMsg.Capabilities...)
+	s.serverCaps = NewCapabilitySet(serverMsg.Capabilities...)
 	s.sessionID = serverMsg.SessionID
 
 	// upgrade the transport if we are on a larger version and the transport
-	// supports it.
+	// supports it.  Otherwise pin it to End-of-Message framing so it can't be
+	// upgraded later.
 	const baseCap11 = baseCap + ":1.1"
 	if s.serverCaps.Has(baseCap11) && s.clientCaps.Has(baseCap11) {
 		if upgrader, ok := s.tr.(interface{ Upgrade() }); ok {
 			upgrader.Upgrade()
 		}
+	} else if pinner, ok := s.tr.(interface{ PinEOM() }); ok {
+		pinner.PinEOM()
 	}
 
 	return nil
@@ -163,6 +375,29 @@ func (s *Session) ServerCapabilities() []string {
 	return s.serverCaps.All()
 }
 
+// ServerCapabilitySet returns the capabilities returned by the server in it's
+// hello message as a [CapabilitySet].  The set is a copy so adding to it
+// doesn't change the capabilities of the session.
+func (s *Session) ServerCapabilitySet() CapabilitySet {
+	return s.serverCaps.clone()
+}
+
+// Supports returns true if the server advertised the given capability.  The
+// capability can use the short form (i.e `:candidate:1.0`) and is matched on
+// the base URI ignoring any query parameters.
+func (s *Session) Supports(capability string) bool {
+	return s.serverCaps.Has(capability)
+}
+
+// RemoteAddr returns the address of the remote device if the transport
+// exposes it (both the ssh and tls transports do) or nil otherwise.
+func (s *Session) RemoteAddr() net.Addr {
+	if tr, ok := s.tr.(interface{ RemoteAddr() net.Addr }); ok {
+		return tr.RemoteAddr()
+	}
+	return nil
+}
+
 // startElement will walk though a xml.Decode until it finds a start element
 // and returns it.
 func startElement(d *xml.Decoder) (*xml.StartElement, error) {
@@ -181,55 +416,108 @@ func startElement(d *xml.Decoder) (*xml.StartElement, error) {
 type req struct {
 	reply chan Reply
 	ctx   context.Context
+
+	// err is set before reply is closed if the request failed for a reason
+	// other than the session closing.
+	err error
+
+	// raw is the encoded request when request echo is enabled.
+	raw []byte
+
+	// body receives the contents of the reply if it is streamed (see
+	// [WithReplyWriter]).
+	body *replyBody
 }
 
 func (s *Session) recvMsg() error {
-	r, err := s.tr.MsgReader()
+	r, err := s.msgReader()
 	if err != nil {
 		return err
 	}
 	defer r.Close()
-	dec := xml.NewDecoder(r)
+	br := skipProlog(r)
+	defer putMsgReader(br)
+	dec := s.newDecoder(br)
 
 	root, err := startElement(dec)
 	if err != nil {
 		return err
 	}
+	s.lastRecv.Store(s.clock.Now().UnixNano())
 
-	const (
-		ncNamespace    = "urn:ietf:params:xml:ns:netconf:base:1.0"
-		notifNamespace = "urn:ietf:params:xml:ns:netconf:notification:1.0"
+	var (
+		notifName = xml.Name{Space: notifNamespace, Local: "notification"}
+		replyName = xml.Name{Space: ncNamespace, Local: "rpc-reply"}
+		helloName = xml.Name{Space: ncNamespace, Local: "hello"}
 	)
 
-	switch root.Name {
-	case xml.Name{Space: notifNamespace, Local: "notification"}:
-		if s.notificationHandler == nil {
+	var isNotif, isReply, isHello bool
+	if isNotif, err = s.matchName(root, notifName); err != nil {
+		return err
+	}
+	if !isNotif {
+		if isReply, err = s.matchName(root, replyName); err != nil {
+			return err
+		}
+	}
+	if !isNotif && !isReply {
+		if isHello, err = s.matchName(root, helloName); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case isHello:
+		// Some servers send their hello again after the handshake.  The
+		// capabilities can't change during a session so it is dropped
+		// unless there is a handler for it.
+		return s.handleUnknown(dec, root, true)
+	case isNotif:
+		s.metrics.NotificationReceived()
+		if s.notificationHandler == nil && s.eventBus == nil {
 			return nil
 		}
 		var notif Notification
 		if err := dec.DecodeElement(&notif, root); err != nil {
 			return fmt.Errorf("failed to decode notification message: %w", err)
 		}
-		s.notificationHandler(notif)
-	case xml.Name{Space: ncNamespace, Local: "rpc-reply"}:
+		s.publishCapabilityChange(notif)
+		if s.notificationHandler != nil && s.acceptNotification(notif) {
+			s.queueNotification(notif)
+		}
+	case isReply:
+		if ok, err := s.streamReply(br, root); ok {
+			return err
+		}
+
+		// a reply being received for an rpc that is given up on is interrupted
+		// rather than waiting for a stalled device to send the rest of it.
+		stop := s.interruptRead(s.pendingCtx(root.Attr))
 		var reply Reply
-		if err := dec.DecodeElement(&reply, root); err != nil {
+		err := dec.DecodeElement(&reply, root)
+		stop(err)
+		if err != nil {
+			if errors.Is(err, ErrMessageTooLarge) && s.failOversizedReply(root.Attr, err) {
+				return nil
+			}
 			// What should we do here?  Kill the connection?
 			return fmt.Errorf("failed to decode rpc-reply message: %w", err)
 		}
 		ok, req := s.req(reply.MessageID)
 		if !ok {
-			return fmt.Errorf("cannot find reply channel for message-id: %d", reply.MessageID)
+			if req, err = s.unmatchedReply(reply); req == nil {
+				return err
+			}
 		}
 
 		select {
 		case req.reply <- reply:
 			return nil
 		case <-req.ctx.Done():
-			return fmt.Errorf("message %d context canceled: %s", reply.MessageID, req.ctx.Err().Error())
+			return fmt.Errorf("message %q context canceled: %s", reply.MessageID, req.ctx.Err().Error())
 		}
 	default:
-		return fmt.Errorf("unknown message type: %q", root.Name.Local)
+		return s.handleUnknown(dec, root, false)
 	}
 	return nil
 }
@@ -240,29 +528,47 @@ func (s *Session) recv() {
 	var err error
 	var opErr *net.OpError
 
+	go s.writeLoop()
+	if s.idleTimeout > 0 {
+		go s.watchIdle()
+	}
+	if s.notifQueue != nil {
+		go s.deliverNotifications()
+		defer close(s.notifQueue.ch)
+	}
+
 	for {
 		err = s.recvMsg()
-		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.As(err, &opErr) {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.As(err, &opErr) ||
+			errors.Is(err, io.ErrClosedPipe) || errors.Is(err, os.ErrClosed) || isDeadlineErr(err) {
 			break
 		}
 		if err != nil {
-			log.Printf("netconf: failed to read incoming message: %v", err)
+			s.handleError(fmt.Errorf("failed to read incoming message: %w", err))
 		}
 	}
 	s.mu.Lock()
-	defer s.mu.Unlock()
-
 	// Close all outstanding requests
 	for _, req := range s.reqs {
 		close(req.reply)
 	}
+	closing := s.closing
+	s.mu.Unlock()
+	close(s.done)
 
-	if !s.closing {
-		log.Printf("netconf: connection closed unexpectedly")
+	var closeErr error
+	if !closing {
+		closeErr = fmt.Errorf("connection closed unexpectedly: %w", ErrClosed)
+		s.handleError(closeErr)
 	}
+	s.publish(Event{Type: EventDisconnected, Err: closeErr})
 }
 
-func (s *Session) req(msgID uint64) (bool, *req) {
+// Done returns a channel that is closed when the session's connection is
+// closed, either by [Session.Close] or by the server.
+func (s *Session) Done() <-chan struct{} { return s.done }
+
+func (s *Session) req(msgID string) (bool, *req) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -274,8 +580,44 @@ func (s *Session) req(msgID uint64) (bool, *req) {
 	return true, req
 }
 
-func (s *Session) writeMsg(v any) error {
+// msgReader returns the next message reader from the transport counting the
+// bytes read if metrics are enabled.
+func (s *Session) msgReader() (io.ReadCloser, error) {
+	r, err := s.tr.MsgReader()
+	if err != nil {
+		return nil, err
+	}
+	if s.maxMessageSize > 0 {
+		r = &limitReader{ReadCloser: r, limit: s.maxMessageSize, left: s.maxMessageSize}
+	}
+	if in, _ := s.captureWriters(); in != nil {
+		r = &captureReader{ReadCloser: r, w: in, clock: s.clock}
+	}
+	if _, ok := s.metrics.(nopMetrics); ok || s.metrics == nil {
+		return r, nil
+	}
+	return &countingReader{ReadCloser: r, m: s.metrics}, nil
+}
+
+// msgWriter returns the next message writer from the transport counting the
+// bytes written if metrics are enabled.
+func (s *Session) msgWriter() (io.WriteCloser, error) {
 	w, err := s.tr.MsgWriter()
+	if err != nil {
+		return nil, err
+	}
+	s.lastSent.Store(s.clock.Now().UnixNano())
+	if _, out := s.captureWriters(); out != nil {
+		w = &captureWriter{WriteCloser: w, w: out, clock: s.clock}
+	}
+	if _, ok := s.metrics.(nopMetrics); ok || s.metrics == nil {
+		return w, nil
+	}
+	return &countingWriter{WriteCloser: w, m: s.metrics}, nil
+}
+
+func (s *Session) writeMsg(v any) error {
+	w, err := s.msgWriter()
 	if err != nil {
 		return err
 	}
@@ -286,60 +628,237 @@ func (s *Session) writeMsg(v any) error {
 	return w.Close()
 }
 
-func (s *Session) send(ctx context.Context, msg *request) (chan Reply, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// acquireInFlight takes a slot for an in-flight rpc.
+func (s *Session) acquireInFlight(ctx context.Context) error {
+	if s.inFlight == nil {
+		return nil
+	}
+
+	if s.inFlightPolicy == InFlightReject {
+		select {
+		case s.inFlight <- struct{}{}:
+			return nil
+		default:
+			return ErrTooManyRequests
+		}
+	}
+
+	select {
+	case s.inFlight <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *Session) releaseInFlight() {
+	if s.inFlight != nil {
+		<-s.inFlight
+	}
+}
 
-	if err := s.writeMsg(msg); err != nil {
+// send registers the rpc to receive the reply and writes it.  On success the
+// caller must call releaseInFlight once the rpc is complete.
+func (s *Session) send(ctx context.Context, msg *request, body *replyBody) (*req, error) {
+	if err := s.acquireInFlight(ctx); err != nil {
+		return nil, err
+	}
+
+	// encode before queueing so large requests are encoded concurrently
+	// and only hold up other requests for the time to write them.
+	raw, err := xml.Marshal(msg)
+	if err == nil && declaresPrefixes(msg) {
+		raw, err = usePrefixes(raw)
+	}
+	if err == nil {
+		err = validateRequest(raw, s.requestValidation)
+	}
+	if err != nil {
+		s.releaseInFlight()
 		return nil, err
 	}
 
 	// cap of 1 makes sure we don't block on send
-	ch := make(chan Reply, 1)
-	s.reqs[msg.MessageID] = &req{
-		reply: ch,
+	r := &req{
+		reply: make(chan Reply, 1),
 		ctx:   ctx,
+		body:  body,
 	}
+	if s.requestEcho {
+		r.raw = raw
+	}
+
+	// register the request before writing it as the reply can arrive before
+	// the write returns.
+	s.mu.Lock()
+	if _, ok := s.reqs[msg.MessageID]; ok {
+		s.mu.Unlock()
+		s.releaseInFlight()
+		return nil, fmt.Errorf("message-id %q is already in use", msg.MessageID)
+	}
+	s.reqs[msg.MessageID] = r
+	s.mu.Unlock()
 
-	return ch, nil
+	if err := s.queueWrite(ctx, raw); err != nil {
+		s.mu.Lock()
+		delete(s.reqs, msg.MessageID)
+		s.mu.Unlock()
+		s.releaseInFlight()
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// DoOption is an optional argument to [Session.Do] and [Session.Call] that
+// changes how a single rpc is issued.
+type DoOption interface {
+	apply(*doConfig)
+}
+
+type doConfig struct {
+	holdNotifications bool
+	rpcAttrs          []xml.Attr
+	replyWriter       io.Writer
 }
 
+type holdNotificationsOpt struct{}
+
+func (holdNotificationsOpt) apply(cfg *doConfig) { cfg.holdNotifications = true }
+
+// WithHeldNotifications will buffer any notifications received while the rpc
+// is in-flight and deliver them to the NotificationHandler, in order, after the
+// reply is received.  This is useful for critical operations (i.e a confirmed
+// commit) where the notification handler could interfere with the state of the
+// operation.
+func WithHeldNotifications() DoOption { return holdNotificationsOpt{} }
+
 // Do issues a rpc call for the given NETCONF operation returning a Reply.  RPC
 // errors (i.e erros in the `<rpc-errors>` section of the `<rpc-reply>`) are
-// converted into go errors automatically.  Instead use `reply.Err()` or
+// not converted into go errors automatically.  Instead use `reply.Err()` or
 // `reply.RPCErrors` to access the errors and/or warnings.
-func (s *Session) Do(ctx context.Context, req any) (*Reply, error) {
+//
+// If ctx has no deadline the session's default timeout is applied (see
+// [WithDefaultRPCTimeout]).
+//
+// If ctx is done while the rpc is still being written or once the reply has
+// started to be read, the write or read is interrupted on transports that
+// support deadlines (see [transport.Deadliner]) so a stalled device doesn't
+// block the session forever.  The rest of the message can't be skipped
+// without losing the framing so the session is closed and every other
+// in-flight rpc fails with [ErrClosed].  A ctx done before the reply starts
+// only abandons this rpc and the reply is discarded when it arrives.
+func (s *Session) Do(ctx context.Context, req any, opts ...DoOption) (*Reply, error) {
+	var cfg doConfig
+	for _, opt := range opts {
+		opt.apply(&cfg)
+	}
+
+	if _, ok := ctx.Deadline(); !ok && s.rpcTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = clock.WithTimeout(ctx, s.clock, s.rpcTimeout)
+		defer cancel()
+	}
+
+	if cfg.holdNotifications {
+		release := s.holdNotifications()
+		defer release()
+	}
+
+	info := &RPCInfo{
+		Session:   s,
+		Operation: operationName(req),
+	}
+
+	if err := s.checkInterleave(info.Operation); err != nil {
+		return nil, err
+	}
+
+	end, err := s.beginRPC(info.Operation)
+	if err != nil {
+		return nil, err
+	}
+	defer end()
+
+	if len(s.interceptors) == 0 {
+		return s.do(ctx, &cfg, info, req)
+	}
+
+	invoke := s.chainInterceptors(info, func(ctx context.Context, req any) (*Reply, error) {
+		return s.do(ctx, &cfg, info, req)
+	})
+	return invoke(ctx, req)
+}
+
+func (s *Session) do(ctx context.Context, cfg *doConfig, info *RPCInfo, req any) (*Reply, error) {
 	msg := &request{
-		MessageID: s.seq.Add(1),
+		MessageID: s.messageIDFunc(s.seq.Add(1)),
 		Operation: req,
 	}
+	info.MessageID = msg.MessageID
+	addRPCAttrs(msg, s.rpcNamespaces)
+	addRPCAttrs(msg, cfg.rpcAttrs)
+	s.addProvenance(msg, info.Operation)
+
+	op := info.Operation
+
+	if s.datastoreQueues != nil {
+		release, err := s.datastoreQueues.acquire(ctx, req)
+		if err != nil {
+			s.metrics.RPCFailed(op, err)
+			return nil, err
+		}
+		defer release()
+	}
+
+	start := s.clock.Now()
 
-	ch, err := s.send(ctx, msg)
+	var body *replyBody
+	if cfg.replyWriter != nil {
+		body = &replyBody{w: cfg.replyWriter}
+	}
+	r, err := s.send(ctx, msg, body)
 	if err != nil {
+		s.metrics.RPCFailed(op, err)
 		return nil, err
 	}
+	defer s.releaseInFlight()
+	s.metrics.RPCSent(op)
 
 	// wait for reply or context to be cancelled.
 	select {
-	case reply, ok := <-ch:
+	case reply, ok := <-r.reply:
 		if !ok {
-			return nil, ErrClosed
+			err := r.err
+			if err == nil {
+				err = ErrClosed
+			}
+			s.metrics.RPCFailed(op, err)
+			return nil, err
 		}
+		s.metrics.RPCReplied(op, clock.Since(s.clock, start), reply.Errors)
+		reply.requestRaw = r.raw
 		return &reply, nil
 	case <-ctx.Done():
 		// remove any existing request
 		s.mu.Lock()
 		delete(s.reqs, msg.MessageID)
 		s.mu.Unlock()
+		if body != nil {
+			// a reply being streamed must not write to the caller's
+			// writer anymore.
+			body.stop()
+		}
 
+		s.metrics.RPCFailed(op, ctx.Err())
 		return nil, ctx.Err()
 	}
 }
 
 // Call issues a rpc message with `req` as the body and decodes the reponse into
 // a pointer at `resp`.  Any Call errors are presented as a go error.
-func (s *Session) Call(ctx context.Context, req any, resp any) error {
-	reply, err := s.Do(ctx, &req)
+func (s *Session) Call(ctx context.Context, req any, resp any, opts ...DoOption) error {
+	reply, err := s.Do(ctx, &req, opts...)
 	if err != nil {
 		return err
 	}
@@ -355,6 +874,34 @@ func (s *Session) Call(ctx context.Context, req any, resp any) error {
 	return nil
 }
 
+type closeSession struct {
+	XMLName xml.Name `xml:"close-session"`
+}
+
+// closeSessionWriteTimeout bounds writing a `<close-session>` when Close is
+// called with a context that is already done.
+const closeSessionWriteTimeout = time.Second
+
+// sendCloseSession writes a `<close-session>` without waiting for the reply.
+// The write is given its own short deadline as ctx is already done.  Returns
+// the error of ctx if the write succeeds.
+func (s *Session) sendCloseSession(ctx context.Context) error {
+	raw, err := xml.Marshal(&request{
+		MessageID: s.messageIDFunc(s.seq.Add(1)),
+		Operation: &closeSession{},
+	})
+	if err != nil {
+		return err
+	}
+
+	wctx, cancel := clock.WithTimeout(context.WithoutCancel(ctx), s.clock, closeSessionWriteTimeout)
+	defer cancel()
+	if err := s.queueWrite(wctx, raw); err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
 // Close will gracefully close the sessions first by sending a `close-session`
 // operation to the remote and then closing the underlying transport
 func (s *Session) Close(ctx context.Context) error {
@@ -362,12 +909,15 @@ func (s *Session) Close(ctx context.Context) error {
 	s.closing = true
 	s.mu.Unlock()
 
-	type closeSession struct {
-		XMLName xml.Name `xml:"close-session"`
-	}
-
 	// This may fail so save the error but still close the underlying transport.
-	_, callErr := s.Do(ctx, &closeSession{})
+	var callErr error
+	if ctx.Err() != nil {
+		// still let the device know the session is going away so it isn't
+		// left dangling but don't wait for the reply.
+		callErr = s.sendCloseSession(ctx)
+	} else {
+		_, callErr = s.Do(ctx, &closeSession{})
+	}
 
 	// Close the connection and ignore errors if the remote side hung up first.
 	if err := s.tr.Close(); err != nil &&
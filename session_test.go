@@ -1,11 +1,16 @@
 package netconf
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/nemith/netconf/clock"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type testServer struct {
@@ -84,12 +89,27 @@ func (s *testTransport) MsgWriter() (io.WriteCloser, error) {
 	inr, inw := io.Pipe()
 	outr, outw := io.Pipe()
 
-	go func() { s.out <- outr }()
+	go func() { s.out <- drainReader{outr} }()
 	go s.handler(inr, outw)
 
 	return inw, nil
 }
 
+// drainReader discards the rest of the message on close like the framed
+// transports do.
+type drainReader struct{ io.ReadCloser }
+
+func (r drainReader) Close() error {
+	_, _ = io.Copy(io.Discard, r.ReadCloser)
+	return r.ReadCloser.Close()
+}
+
+// pushMsg queues an unsolicited message (i.e a notification) to be read by
+// the session.
+func (s *testTransport) pushMsg(msg string) {
+	go func() { s.out <- io.NopCloser(strings.NewReader(msg)) }()
+}
+
 func (s *testTransport) Close() error {
 	if len(s.out) > 0 {
 		return fmt.Errorf("testtransport: remaining outboard messages not sent at close")
@@ -143,7 +163,7 @@ func TestHello(t *testing.T) {
 	for _, tc := range tt {
 		t.Run(tc.name, func(t *testing.T) {
 			ts := newTestServer(t)
-			sess := &Session{tr: ts.transport()}
+			sess := &Session{tr: ts.transport(), clock: clock.Real}
 
 			ts.queueRespString(tc.serverHello)
 
@@ -158,3 +178,127 @@ func TestHello(t *testing.T) {
 		})
 	}
 }
+
+func TestDefaultRPCTimeout(t *testing.T) {
+	var deadline time.Time
+	ts := newTestServer(t)
+	sess := newSession(ts.transport(),
+		WithDefaultRPCTimeout(10*time.Millisecond),
+		WithInterceptor(func(ctx context.Context, info *RPCInfo, req any, next Invoker) (*Reply, error) {
+			deadline, _ = ctx.Deadline()
+			return next(ctx, req)
+		}),
+	)
+	go sess.recv()
+
+	// the server never replies
+	start := time.Now()
+	_, err := sess.Do(context.Background(), &CommitReq{})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.WithinDuration(t, start.Add(10*time.Millisecond), deadline, 20*time.Millisecond)
+
+	sess.mu.Lock()
+	assert.Empty(t, sess.reqs)
+	sess.mu.Unlock()
+
+	// an existing deadline is kept
+	want := time.Now().Add(time.Hour)
+	ctx, cancel := context.WithDeadline(context.Background(), want)
+	defer cancel()
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="2"><ok/></rpc-reply>`)
+	_, err = sess.Do(ctx, &CommitReq{})
+	assert.NoError(t, err)
+	assert.Equal(t, want, deadline)
+}
+
+func TestMaxInFlight(t *testing.T) {
+	const okReply = `<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="%d"><ok/></rpc-reply>`
+
+	tt := []struct {
+		name    string
+		policy  InFlightPolicy
+		wantErr error
+	}{
+		{"reject", InFlightReject, ErrTooManyRequests},
+		{"wait", InFlightWait, context.DeadlineExceeded},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := newTestServer(t)
+			sess := newSession(ts.transport(), WithMaxInFlight(1, tc.policy))
+			go sess.recv()
+
+			done := make(chan error)
+			go func() {
+				_, err := sess.Do(context.Background(), &CommitReq{})
+				done <- err
+			}()
+
+			// wait for the first rpc to be in-flight
+			_, err := ts.popReq()
+			require.NoError(t, err)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+			_, err = sess.Do(ctx, &CommitReq{})
+			assert.ErrorIs(t, err, tc.wantErr)
+
+			ts.queueRespString(fmt.Sprintf(okReply, 1))
+			require.NoError(t, <-done)
+
+			// the slot is released after the reply.  The failed rpc still used
+			// message-id 2.
+			ts.queueRespString(fmt.Sprintf(okReply, 3))
+			_, err = sess.Do(context.Background(), &CommitReq{})
+			assert.NoError(t, err)
+		})
+	}
+}
+
+// framingTransport records how the session set up the framing.
+type framingTransport struct {
+	*testTransport
+	upgraded, pinned bool
+}
+
+func (t *framingTransport) Upgrade() { t.upgraded = true }
+func (t *framingTransport) PinEOM()  { t.pinned = true }
+
+func TestHelloFraming(t *testing.T) {
+	const helloBase10 = `
+<hello xmlns="urn:ietf:params:xml:ns:netconf:base:1.0">
+  <capabilities>
+	<capability>urn:ietf:params:netconf:base:1.0</capability>
+  </capabilities>
+  <session-id>42</session-id>
+</hello>`
+
+	tt := []struct {
+		name         string
+		serverHello  string
+		clientCaps   []string
+		wantUpgraded bool
+		wantPinned   bool
+	}{
+		{"base 1.1", helloGood, DefaultCapabilities, true, false},
+		{"server base 1.0", helloBase10, DefaultCapabilities, false, true},
+		{"client base 1.0", helloGood, []string{baseCap + ":1.0"}, false, true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := newTestServer(t)
+			tr := &framingTransport{testTransport: ts.transport()}
+			sess := &Session{tr: tr, clock: clock.Real, clientCaps: NewCapabilitySet(tc.clientCaps...)}
+
+			ts.queueRespString(tc.serverHello)
+			require.NoError(t, sess.handshake())
+			_, err := ts.popReqString()
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.wantUpgraded, tr.upgraded)
+			assert.Equal(t, tc.wantPinned, tr.pinned)
+		})
+	}
+}
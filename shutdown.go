@@ -0,0 +1,57 @@
+package netconf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrShuttingDown is returned when issuing a rpc on a session that is being
+// shut down with [Session.Shutdown].
+var ErrShuttingDown = errors.New("netconf: session is shutting down")
+
+// beginRPC registers an rpc with the session so that [Session.Shutdown] can
+// wait for it.  The returned func must be called once the rpc is complete.
+func (s *Session) beginRPC(op string) (func(), error) {
+	// the close-session sent by Shutdown itself must not be rejected.
+	if op == "close-session" {
+		return func() {}, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.draining {
+		return nil, ErrShuttingDown
+	}
+	s.pending.Add(1)
+	return s.pending.Done, nil
+}
+
+// Shutdown gracefully closes the session.  New rpcs are rejected with
+// [ErrShuttingDown], rpcs already issued are given until ctx is done to
+// receive their replies and then the session is closed like [Session.Close].
+//
+// If ctx is done before all replies are received the session is closed anyway
+// and the context's error is returned.  The outstanding rpcs then fail with
+// [ErrClosed].
+func (s *Session) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.draining = true
+	s.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		s.pending.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		return s.Close(ctx)
+	case <-ctx.Done():
+	}
+
+	// Close with a done context still closes the transport.
+	_ = s.Close(ctx)
+	return fmt.Errorf("outstanding rpcs did not complete before shutdown: %w", ctx.Err())
+}
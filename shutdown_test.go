@@ -0,0 +1,75 @@
+package netconf
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShutdown(t *testing.T) {
+	ts := newTestServer(t)
+	sess := newSession(ts.transport())
+	go sess.recv()
+
+	type result struct {
+		reply *Reply
+		err   error
+	}
+	inflight := make(chan result, 1)
+	go func() {
+		reply, err := sess.Do(context.Background(), &GetConfigReq{Source: Running})
+		inflight <- result{reply, err}
+	}()
+	_, err := ts.popReq()
+	require.NoError(t, err)
+
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- sess.Shutdown(context.Background()) }()
+
+	// new rpcs are rejected while the outstanding one is waiting for a reply.
+	require.Eventually(t, func() bool {
+		_, err := sess.Do(context.Background(), &GetConfigReq{Source: Running})
+		return err == ErrShuttingDown
+	}, time.Second, time.Millisecond)
+
+	select {
+	case err := <-shutdown:
+		t.Fatalf("shutdown returned before outstanding rpc completed: %v", err)
+	default:
+	}
+
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><data/></rpc-reply>`)
+	res := <-inflight
+	require.NoError(t, res.err)
+	assert.Equal(t, "1", res.reply.MessageID)
+
+	req, err := ts.popReqString()
+	require.NoError(t, err)
+	assert.Contains(t, req, `message-id="2"`)
+	assert.Contains(t, req, "<close-session>")
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="2"><ok/></rpc-reply>`)
+
+	assert.NoError(t, <-shutdown)
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	ts := newTestServer(t)
+	sess := newSession(ts.transport())
+	go sess.recv()
+
+	go func() { _, _ = sess.Do(context.Background(), &GetConfigReq{Source: Running}) }()
+	_, err := ts.popReq()
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, sess.Shutdown(ctx), context.DeadlineExceeded)
+
+	// the close-session is still sent before the transport is closed.
+	req, err := ts.popReqString()
+	require.NoError(t, err)
+	assert.Contains(t, req, "<close-session>")
+}
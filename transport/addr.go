@@ -0,0 +1,49 @@
+package transport
+
+import (
+	"net"
+	"strconv"
+	"strings"
+)
+
+// JoinDefaultPort returns addr with the given port appended if addr does not
+// already contain a port.  addr can be a hostname, IPv4 address or an IPv6
+// literal with or without brackets.
+//
+//	JoinDefaultPort("router1", 830)          // "router1:830"
+//	JoinDefaultPort("router1:2022", 830)     // "router1:2022"
+//	JoinDefaultPort("2001:db8::1", 830)      // "[2001:db8::1]:830"
+//	JoinDefaultPort("[2001:db8::1]", 830)    // "[2001:db8::1]:830"
+//	JoinDefaultPort("[2001:db8::1]:22", 830) // "[2001:db8::1]:22"
+func JoinDefaultPort(addr string, port int) string {
+	p := strconv.Itoa(port)
+
+	if strings.HasPrefix(addr, "[") {
+		end := strings.IndexByte(addr, ']')
+		if end < 0 {
+			// not valid but let the dialer return the error
+			return addr
+		}
+		if end == len(addr)-1 {
+			return net.JoinHostPort(addr[1:end], p)
+		}
+		return addr
+	}
+
+	switch strings.Count(addr, ":") {
+	case 0:
+		return net.JoinHostPort(addr, p)
+	case 1:
+		return addr
+	default:
+		// more than one colon without brackets must be a bare IPv6 literal
+		// which cannot contain a port.
+		return net.JoinHostPort(addr, p)
+	}
+}
+
+// SplitHostPort splits addr into a host and port using defaultPort if addr
+// does not contain a port.  See [JoinDefaultPort] for the accepted formats.
+func SplitHostPort(addr string, defaultPort int) (host, port string, err error) {
+	return net.SplitHostPort(JoinDefaultPort(addr, defaultPort))
+}
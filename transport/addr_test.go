@@ -0,0 +1,41 @@
+package transport
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJoinDefaultPort(t *testing.T) {
+	tt := []struct {
+		addr string
+		want string
+	}{
+		{"router1", "router1:830"},
+		{"router1:2022", "router1:2022"},
+		{"192.0.2.1", "192.0.2.1:830"},
+		{"192.0.2.1:22", "192.0.2.1:22"},
+		{"2001:db8::1", "[2001:db8::1]:830"},
+		{"::1", "[::1]:830"},
+		{"[2001:db8::1]", "[2001:db8::1]:830"},
+		{"[2001:db8::1]:22", "[2001:db8::1]:22"},
+		{"fe80::1%eth0", "[fe80::1%eth0]:830"},
+		{"", ":830"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.addr, func(t *testing.T) {
+			assert.Equal(t, tc.want, JoinDefaultPort(tc.addr, 830))
+		})
+	}
+}
+
+func TestSplitHostPort(t *testing.T) {
+	host, port, err := SplitHostPort("[2001:db8::1]", 6513)
+	assert.NoError(t, err)
+	assert.Equal(t, "2001:db8::1", host)
+	assert.Equal(t, "6513", port)
+
+	_, _, err = SplitHostPort("[2001:db8::1", 6513)
+	assert.Error(t, err)
+}
@@ -0,0 +1,75 @@
+package transport
+
+import (
+	"bufio"
+	"io"
+	"sync"
+)
+
+// DefaultBufferSize is the size of the buffers in [DefaultBufferPool].
+const DefaultBufferSize = 4096
+
+// DefaultBufferPool is the pool used by a Framer unless another one is given
+// with [WithBufferPool].
+var DefaultBufferPool = NewBufferPool(DefaultBufferSize)
+
+// BufferPool is a pool of write buffers shared between Framers.  A Framer
+// only holds a write buffer while a message is being written so with many
+// mostly idle sessions (i.e polling thousands of devices) the buffers are
+// reused instead of allocating a new one per session.
+//
+// A BufferPool is safe for concurrent use.
+type BufferPool struct {
+	size    int
+	writers sync.Pool
+}
+
+// NewBufferPool returns a pool of write buffers of the given size in bytes.
+// Sizes less than 16 bytes are raised to 16.
+func NewBufferPool(size int) *BufferPool {
+	return &BufferPool{size: max(size, 16)}
+}
+
+// Size returns the size of the buffers in the pool.
+func (p *BufferPool) Size() int { return p.size }
+
+func (p *BufferPool) getWriter(w io.Writer) *bufio.Writer {
+	if bw, ok := p.writers.Get().(*bufio.Writer); ok {
+		bw.Reset(w)
+		return bw
+	}
+	return bufio.NewWriterSize(w, p.size)
+}
+
+func (p *BufferPool) putWriter(bw *bufio.Writer) {
+	// drop the reference to the underlying writer so it can be collected.
+	bw.Reset(nil)
+	p.writers.Put(bw)
+}
+
+var (
+	sizedPoolsMu sync.Mutex
+	sizedPools   = map[int]*BufferPool{DefaultBufferSize: DefaultBufferPool}
+)
+
+// bufferPoolFor returns the shared pool for buffers of the given size.
+func bufferPoolFor(size int) *BufferPool {
+	size = max(size, 16)
+
+	sizedPoolsMu.Lock()
+	defer sizedPoolsMu.Unlock()
+	p, ok := sizedPools[size]
+	if !ok {
+		p = NewBufferPool(size)
+		sizedPools[size] = p
+	}
+	return p
+}
+
+// copyBufPool holds the scratch buffers used by chunkWriter.ReadFrom.
+var copyBufPool = sync.Pool{
+	New: func() any {
+		buf := make([]byte, copyBufSize)
+		return &buf
+	},
+}
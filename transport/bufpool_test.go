@@ -0,0 +1,124 @@
+package transport
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBufferPool(t *testing.T) {
+	assert.Equal(t, 16, NewBufferPool(0).Size())
+	assert.Equal(t, DefaultBufferSize, DefaultBufferPool.Size())
+
+	var out bytes.Buffer
+	f := NewFramer(strings.NewReader(""), &out, WithBufferPool(NewBufferPool(64)))
+	f.Upgrade()
+
+	// messages larger than the buffer and more messages than buffers.
+	msg := strings.Repeat("x", 1000)
+	for i := 0; i < 3; i++ {
+		w, err := f.MsgWriter()
+		require.NoError(t, err)
+		_, err = io.WriteString(w, msg)
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+		assert.ErrorIs(t, w.Close(), ErrInvalidIO)
+	}
+
+	f = NewFramer(&out, io.Discard)
+	f.Upgrade()
+	for i := 0; i < 3; i++ {
+		r, err := f.MsgReader()
+		require.NoError(t, err)
+		got, err := io.ReadAll(r)
+		require.NoError(t, err)
+		assert.Equal(t, msg, string(got))
+	}
+}
+
+func TestFramerBufferSizes(t *testing.T) {
+	f := NewFramer(strings.NewReader(""), io.Discard)
+	assert.Equal(t, DefaultBufferSize, f.br.Size())
+	assert.Same(t, DefaultBufferPool, f.pool)
+
+	f = NewFramer(strings.NewReader(""), io.Discard,
+		WithReadBufferSize(64<<10),
+		WithWriteBufferSize(32<<10))
+	assert.Equal(t, 64<<10, f.br.Size())
+	assert.Equal(t, 32<<10, f.pool.Size())
+
+	// framers with the same write buffer size share a pool.
+	f2 := NewFramer(strings.NewReader(""), io.Discard, WithWriteBufferSize(32<<10))
+	assert.Same(t, f.pool, f2.pool)
+}
+
+func BenchmarkFramerReadLarge(b *testing.B) {
+	// a 10MiB reply sent in 64KiB chunks.
+	var msg bytes.Buffer
+	w := &chunkWriter{w: bufio.NewWriter(&msg)}
+	chunk := []byte(strings.Repeat("x", 64<<10))
+	for i := 0; i < 160; i++ {
+		_, _ = w.Write(chunk)
+	}
+	_ = w.Close()
+	input := msg.Bytes()
+
+	for _, size := range []int{4 << 10, 64 << 10} {
+		b.Run(strconv.Itoa(size>>10)+"KiB", func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(input)))
+			for i := 0; i < b.N; i++ {
+				// read from a pipe so each read is a syscall like it would be
+				// for a connection.
+				pr, pw, err := os.Pipe()
+				if err != nil {
+					b.Fatal(err)
+				}
+				go func() {
+					_, _ = pw.Write(input)
+					pw.Close()
+				}()
+
+				f := NewFramer(pr, io.Discard, WithReadBufferSize(size))
+				f.Upgrade()
+				r, err := f.MsgReader()
+				if err != nil {
+					b.Fatal(err)
+				}
+				// read like the xml decoder does.
+				if _, err := io.Copy(io.Discard, onlyReader{r}); err != nil {
+					b.Fatal(err)
+				}
+				pr.Close()
+			}
+		})
+	}
+}
+
+func BenchmarkFramerWrite(b *testing.B) {
+	msg := []byte(strings.Repeat("x", 1024))
+	f := NewFramer(strings.NewReader(""), io.Discard)
+	f.Upgrade()
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(msg)))
+	for i := 0; i < b.N; i++ {
+		w, err := f.MsgWriter()
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := w.Write(msg); err != nil {
+			b.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
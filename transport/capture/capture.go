@@ -0,0 +1,146 @@
+// Package capture records the messages exchanged on a netconf transport into
+// a compressed, indexed file that can be searched and read back later.
+//
+// Captures are meant to be left running for long periods of time (days or
+// weeks) to track down intermittent issues.  Messages are grouped into blocks
+// that are individually compressed with Zstandard and an index of every
+// message (time, direction, message type and message-id) is written as the
+// capture goes so a [Reader] can jump directly to a message or rpc exchange
+// without decompressing the whole capture.  Files from a process that didn't
+// close the capture cleanly can still be read; only the index of the messages
+// written after the last index block is rebuilt by scanning the blocks.
+//
+// File layout (all integers are big endian):
+//
+//	header = "NCCAP" 0x00 0x00 version
+//	block  = kind(1) length(4) zstd-frame(length)
+//	footer = table-offset(8) "NCCAPIDX"
+//
+// Data blocks ('D') contain a sequence of records each encoded as a unix
+// nanosecond timestamp (8), direction (1), length (4) and the message.  Index
+// blocks ('I') contain the JSON encoded list of entries of the messages in
+// the data blocks since the previous index block and are written every
+// [Writer.IndexEntries] messages.  The table block ('T') written on close
+// contains the JSON encoded list of the offsets of all the index blocks and
+// is pointed to by the footer.
+//
+// For short recordings that are kept with tests messages can also be written
+// as a JSON Lines transcript with a [TranscriptWriter].  Both captures and
+// transcripts can be served back to a session with a [Replay] transport.
+// Messages can be printed in a human readable form while debugging with a
+// [Printer].
+package capture
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"time"
+)
+
+// Dir is the direction of a captured message.
+type Dir uint8
+
+const (
+	// In is a message read from the remote device.
+	In Dir = iota + 1
+	// Out is a message written to the remote device.
+	Out
+)
+
+func (d Dir) String() string {
+	switch d {
+	case In:
+		return "in"
+	case Out:
+		return "out"
+	default:
+		return "unknown"
+	}
+}
+
+// Record is a single captured netconf message.  Data is the unframed message.
+type Record struct {
+	Time time.Time
+	Dir  Dir
+	Data []byte
+}
+
+// Entry is the index entry of a captured message.
+type Entry struct {
+	// Seq is the position of the message in the capture starting at 0.
+	Seq int `json:"seq"`
+
+	Time time.Time `json:"time"`
+	Dir  Dir       `json:"dir"`
+
+	// Kind is the local name of the root element of the message (i.e `hello`,
+	// `rpc`, `rpc-reply` or `notification`).
+	Kind string `json:"kind,omitempty"`
+
+	// MessageID is the value of the `message-id` attribute of `<rpc>` and
+	// `<rpc-reply>` messages.
+	MessageID string `json:"message-id,omitempty"`
+
+	// Size is the length of the message in bytes.
+	Size int `json:"size"`
+
+	// Block is the file offset of the data block containing the message and
+	// Offset is the offset of the record inside of the uncompressed block.
+	Block  int64 `json:"block"`
+	Offset int   `json:"offset"`
+}
+
+// Exchange is a `<rpc>` and the `<rpc-reply>` received for it.  Reply is nil
+// if no reply was captured.
+type Exchange struct {
+	Request *Entry
+	Reply   *Entry
+}
+
+var (
+	magic       = []byte("NCCAP\x00\x00\x01")
+	footerMagic = []byte("NCCAPIDX")
+)
+
+const (
+	headerLen = 8
+	footerLen = 16
+
+	blockHeaderLen = 5
+	recordHeadLen  = 13
+
+	dataBlock  byte = 'D'
+	indexBlock byte = 'I'
+	tableBlock byte = 'T'
+)
+
+var (
+	// ErrInvalidCapture is returned when a file is not a capture or is
+	// corrupt.
+	ErrInvalidCapture = errors.New("capture: invalid capture file")
+
+	// ErrNotFound is returned when a message in the capture cannot be found.
+	ErrNotFound = errors.New("capture: not found")
+)
+
+// sniff returns the root element name and message-id of a message.
+func sniff(msg []byte) (kind, msgID string) {
+	dec := xml.NewDecoder(bytes.NewReader(msg))
+	for {
+		tok, err := dec.RawToken()
+		if err != nil {
+			return "", ""
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		for _, attr := range start.Attr {
+			if attr.Name.Local == "message-id" {
+				msgID = attr.Value
+			}
+		}
+		return start.Name.Local, msgID
+	}
+}
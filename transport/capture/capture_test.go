@@ -0,0 +1,300 @@
+package capture
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nemith/netconf/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var captureTime = time.Date(2023, 6, 7, 18, 31, 48, 0, time.UTC)
+
+func testRecords() []Record {
+	msgs := []struct {
+		dir  Dir
+		data string
+	}{
+		{Out, `<hello xmlns="urn:ietf:params:xml:ns:netconf:base:1.0"/>`},
+		{In, `<hello xmlns="urn:ietf:params:xml:ns:netconf:base:1.0"><session-id>1</session-id></hello>`},
+		{Out, `<rpc xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><get-config/></rpc>`},
+		{Out, `<rpc xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="2"><get/></rpc>`},
+		{In, `<notification xmlns="urn:ietf:params:xml:ns:netconf:notification:1.0"/>`},
+		{In, `<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="2"><data/></rpc-reply>`},
+		{In, `<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><data>one</data></rpc-reply>`},
+		// new session reusing message-ids
+		{Out, `<hello xmlns="urn:ietf:params:xml:ns:netconf:base:1.0"/>`},
+		{Out, `<rpc xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><commit/></rpc>`},
+		{In, `<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1"><ok/></rpc-reply>`},
+	}
+
+	recs := make([]Record, len(msgs))
+	for i, msg := range msgs {
+		recs[i] = Record{
+			Time: captureTime.Add(time.Duration(i) * time.Second),
+			Dir:  msg.dir,
+			Data: []byte(msg.data),
+		}
+	}
+	return recs
+}
+
+func writeCapture(t *testing.T, blockSize int, close bool) *bytes.Buffer {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf)
+	require.NoError(t, err)
+	w.BlockSize = blockSize
+
+	for _, rec := range testRecords() {
+		require.NoError(t, w.Write(rec))
+	}
+
+	if close {
+		require.NoError(t, w.Close())
+	} else {
+		require.NoError(t, w.Flush())
+	}
+	return &buf
+}
+
+func TestRoundTrip(t *testing.T) {
+	tt := []struct {
+		name      string
+		blockSize int
+		close     bool
+	}{
+		{"single block", 0, true},
+		{"many blocks", 200, true},
+		{"no index", 200, false},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			buf := writeCapture(t, tc.blockSize, tc.close)
+
+			r, err := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+			require.NoError(t, err)
+			defer r.Close()
+
+			want := testRecords()
+			require.Equal(t, len(want), r.Len())
+
+			// read backwards to make sure the blocks are not read in order
+			for i := len(want) - 1; i >= 0; i-- {
+				rec, err := r.Record(i)
+				require.NoError(t, err)
+				assert.True(t, want[i].Time.Equal(rec.Time))
+				assert.Equal(t, want[i].Dir, rec.Dir)
+				assert.Equal(t, string(want[i].Data), string(rec.Data))
+			}
+
+			e := r.Entries()[2]
+			assert.Equal(t, "rpc", e.Kind)
+			assert.Equal(t, "1", e.MessageID)
+			assert.Equal(t, Out, e.Dir)
+
+			_, err = r.Record(len(want))
+			assert.ErrorIs(t, err, ErrNotFound)
+		})
+	}
+}
+
+func TestTruncated(t *testing.T) {
+	buf := writeCapture(t, 200, false)
+	full := buf.Len()
+
+	// simulate a partial block being written when the process died.
+	buf.Write([]byte{dataBlock, 0, 0, 1, 0, 0x28, 0xb5})
+
+	r, err := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	assert.Equal(t, len(testRecords()), r.Len())
+	assert.Less(t, r.Entries()[r.Len()-1].Block, int64(full))
+}
+
+func TestInvalid(t *testing.T) {
+	data := []byte("<rpc-reply/>")
+	_, err := NewReader(bytes.NewReader(data), int64(len(data)))
+	assert.ErrorIs(t, err, ErrInvalidCapture)
+}
+
+func TestSearch(t *testing.T) {
+	buf := writeCapture(t, 0, true)
+	r, err := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+
+	assert.Equal(t, 0, r.Search(captureTime.Add(-time.Hour)))
+	assert.Equal(t, 3, r.Search(captureTime.Add(2500*time.Millisecond)))
+	assert.Equal(t, r.Len(), r.Search(captureTime.Add(time.Hour)))
+}
+
+func TestExchanges(t *testing.T) {
+	buf := writeCapture(t, 200, true)
+	r, err := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+
+	var got []string
+	for _, ex := range r.Exchanges() {
+		got = append(got, fmt.Sprintf("%d->%d", ex.Request.Seq, ex.Reply.Seq))
+	}
+	assert.Equal(t, []string{"2->6", "3->5", "8->9"}, got)
+
+	req, reply, err := r.Exchange("1", 0)
+	require.NoError(t, err)
+	assert.Contains(t, string(req.Data), "<get-config/>")
+	assert.Contains(t, string(reply.Data), "<data>one</data>")
+
+	req, reply, err = r.Exchange("1", 7)
+	require.NoError(t, err)
+	assert.Contains(t, string(req.Data), "<commit/>")
+	assert.Contains(t, string(reply.Data), "<ok/>")
+
+	_, _, err = r.Exchange("42", 0)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+type framedTransport struct {
+	*transport.Framer
+}
+
+func (framedTransport) Close() error { return nil }
+
+func TestTransport(t *testing.T) {
+	in := strings.NewReader(`<hello/>]]>]]><rpc-reply message-id="1"><ok/></rpc-reply>]]>]]>`)
+	var out bytes.Buffer
+
+	var capBuf bytes.Buffer
+	w, err := NewWriter(&capBuf)
+	require.NoError(t, err)
+
+	tr := NewTransport(framedTransport{transport.NewFramer(in, &out)}, w)
+
+	// read only part of the first message
+	r, err := tr.MsgReader()
+	require.NoError(t, err)
+	_, err = r.Read(make([]byte, 3))
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+
+	wr, err := tr.MsgWriter()
+	require.NoError(t, err)
+	_, err = io.WriteString(wr, `<rpc message-id="1"><commit/></rpc>`)
+	require.NoError(t, err)
+	require.NoError(t, wr.Close())
+
+	r, err = tr.MsgReader()
+	require.NoError(t, err)
+	_, err = io.ReadAll(r)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+
+	require.NoError(t, w.Close())
+
+	cr, err := NewReader(bytes.NewReader(capBuf.Bytes()), int64(capBuf.Len()))
+	require.NoError(t, err)
+
+	want := []struct {
+		dir  Dir
+		data string
+	}{
+		{In, `<hello/>`},
+		{Out, `<rpc message-id="1"><commit/></rpc>`},
+		{In, `<rpc-reply message-id="1"><ok/></rpc-reply>`},
+	}
+	require.Equal(t, len(want), cr.Len())
+	for i, w := range want {
+		rec, err := cr.Record(i)
+		require.NoError(t, err)
+		assert.Equal(t, w.dir, rec.Dir)
+		assert.Equal(t, w.data, string(rec.Data))
+	}
+	assert.Equal(t, "<rpc message-id=\"1\"><commit/></rpc>\n]]>]]>", out.String())
+}
+
+func TestIndexBlocks(t *testing.T) {
+	write := func(close bool) (*Writer, *bytes.Buffer) {
+		var buf bytes.Buffer
+		w, err := NewWriter(&buf)
+		require.NoError(t, err)
+		w.BlockSize = 200
+		w.IndexEntries = 3
+
+		for _, rec := range testRecords() {
+			require.NoError(t, w.Write(rec))
+			// only the entries since the last index block are kept.
+			assert.Less(t, len(w.entries), 3)
+		}
+		if close {
+			require.NoError(t, w.Close())
+		} else {
+			require.NoError(t, w.Flush())
+		}
+		return w, &buf
+	}
+
+	w, closed := write(true)
+	assert.Greater(t, len(w.indexes), 1)
+	r, err := NewReader(bytes.NewReader(closed.Bytes()), int64(closed.Len()))
+	require.NoError(t, err)
+	defer r.Close()
+	want := r.Entries()
+	require.Len(t, want, len(testRecords()))
+	for i, e := range want {
+		assert.Equal(t, i, e.Seq)
+	}
+
+	// without the table the index blocks written are used and the rest is
+	// scanned.
+	w, open := write(false)
+	require.NotEmpty(t, w.indexes)
+	require.NotEmpty(t, w.entries)
+	r, err = NewReader(bytes.NewReader(open.Bytes()), int64(open.Len()))
+	require.NoError(t, err)
+	defer r.Close()
+	got := r.Entries()
+	require.Len(t, got, len(want))
+	for i := range want {
+		assert.True(t, want[i].Time.Equal(got[i].Time))
+		got[i].Time = want[i].Time
+		assert.Equal(t, want[i], got[i])
+	}
+
+	for i, rec := range testRecords() {
+		got, err := r.Record(i)
+		require.NoError(t, err)
+		assert.Equal(t, string(rec.Data), string(got.Data))
+	}
+}
+
+func TestTransportDebugCapture(t *testing.T) {
+	in := strings.NewReader(`<hello/>]]>]]><rpc-reply message-id="1"><ok/></rpc-reply>]]>]]>`)
+	w, err := NewWriter(io.Discard)
+	require.NoError(t, err)
+	tr := NewTransport(framedTransport{transport.NewFramer(in, io.Discard)}, w)
+
+	readMsg := func() {
+		r, err := tr.MsgReader()
+		require.NoError(t, err)
+		_, err = io.ReadAll(r)
+		require.NoError(t, err)
+		require.NoError(t, r.Close())
+	}
+
+	// capturing is forwarded to the framer and starts with the next message.
+	readMsg()
+	var msgs []string
+	var raw bytes.Buffer
+	tr.DebugCaptureMsgs(func(m transport.CapturedMsg) { msgs = append(msgs, string(m.Data)) })
+	tr.DebugCapture(&raw, nil)
+	readMsg()
+
+	assert.Equal(t, []string{`<rpc-reply message-id="1"><ok/></rpc-reply>`}, msgs)
+	assert.Equal(t, `<rpc-reply message-id="1"><ok/></rpc-reply>]]>]]>`, raw.String())
+}
@@ -0,0 +1,114 @@
+package capture
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/nemith/netconf/transport"
+	"github.com/nemith/netconf/xmltree"
+)
+
+// Printer writes messages in a human readable form for debugging.  Each
+// message is preceded by a line with the time, direction, kind and message-id
+// of the message:
+//
+//	10:00:00.123 >>> rpc message-id=1 (112 bytes)
+//	<rpc xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="1">
+//	  <get-config>...</get-config>
+//	</rpc>
+//
+// `>>>` marks messages sent and `<<<` messages received.  It implements
+// [Recorder] so it can print messages as they are exchanged (with
+// [NewTransport] or [RecordMessages]) or print the records of a capture or
+// transcript.
+//
+// It is safe for concurrent use.
+type Printer struct {
+	indent string
+
+	mu  sync.Mutex
+	w   io.Writer
+	err error
+}
+
+// NewPrinter returns a Printer writing to w.  If indent is non-empty messages
+// are re-indented with it for each level.  Messages that are not valid XML
+// are printed as is.
+func NewPrinter(w io.Writer, indent string) *Printer {
+	return &Printer{w: w, indent: indent}
+}
+
+// Write prints a message.  Once writing fails all further writes return the
+// same error.
+func (p *Printer) Write(rec Record) error {
+	var buf bytes.Buffer
+
+	arrow := "<<<"
+	if rec.Dir == Out {
+		arrow = ">>>"
+	}
+	buf.WriteString(rec.Time.Format("15:04:05.000"))
+	buf.WriteString(" " + arrow)
+
+	kind, msgID := sniff(rec.Data)
+	if kind != "" {
+		buf.WriteString(" " + kind)
+	}
+	if msgID != "" {
+		buf.WriteString(" message-id=" + msgID)
+	}
+	fmt.Fprintf(&buf, " (%d bytes)\n", len(rec.Data))
+
+	buf.Write(p.format(rec.Data))
+	buf.WriteString("\n\n")
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return p.err
+	}
+	_, p.err = p.w.Write(buf.Bytes())
+	return p.err
+}
+
+func (p *Printer) format(msg []byte) []byte {
+	msg = bytes.TrimSpace(msg)
+	if p.indent == "" {
+		return msg
+	}
+	nodes, err := xmltree.Parse(msg)
+	if err != nil || len(nodes) == 0 {
+		return msg
+	}
+	out, err := xmltree.Marshal(nodes, p.indent)
+	if err != nil {
+		return msg
+	}
+	return out
+}
+
+// MsgCapturer is implemented by transports using a [transport.Framer] (i.e
+// the ssh and tls transports).
+type MsgCapturer interface {
+	DebugCaptureMsgs(fn func(transport.CapturedMsg))
+}
+
+// RecordMessages records all messages read and written by tr to w starting
+// with the next message.  Unlike [NewTransport] this doesn't wrap the
+// transport so recording can be started and stopped (with a nil w) at any
+// time during a session.
+func RecordMessages(tr MsgCapturer, w Recorder) {
+	if w == nil {
+		tr.DebugCaptureMsgs(nil)
+		return
+	}
+	tr.DebugCaptureMsgs(func(m transport.CapturedMsg) {
+		dir := In
+		if m.Sent {
+			dir = Out
+		}
+		_ = w.Write(Record{Time: m.Time, Dir: dir, Data: m.Data})
+	})
+}
@@ -0,0 +1,59 @@
+package capture
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nemith/netconf/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrinter(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 10, 0, 0, 123e6, time.UTC)
+
+	var buf bytes.Buffer
+	p := NewPrinter(&buf, "  ")
+	require.NoError(t, p.Write(Record{Time: ts, Dir: Out, Data: []byte(`<rpc message-id="1"><get/></rpc>`)}))
+	require.NoError(t, p.Write(Record{Time: ts, Dir: In, Data: []byte("not xml\n")}))
+
+	want := "10:00:00.123 >>> rpc message-id=1 (32 bytes)\n" +
+		"<rpc message-id=\"1\">\n  <get></get>\n</rpc>\n\n" +
+		"10:00:00.123 <<< (8 bytes)\n" +
+		"not xml\n\n"
+	assert.Equal(t, want, buf.String())
+}
+
+func TestRecordMessages(t *testing.T) {
+	var out bytes.Buffer
+	f := transport.NewFramer(strings.NewReader(`<rpc-reply message-id="1"/>]]>]]>`), &out)
+
+	var transcript bytes.Buffer
+	RecordMessages(f, NewTranscriptWriter(&transcript))
+
+	w, err := f.MsgWriter()
+	require.NoError(t, err)
+	_, err = io.WriteString(w, `<rpc message-id="1"/>`)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	r, err := f.MsgReader()
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+
+	RecordMessages(f, nil)
+	w, err = f.MsgWriter()
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	recs, err := ReadTranscript(&transcript)
+	require.NoError(t, err)
+	require.Len(t, recs, 2)
+	assert.Equal(t, Out, recs[0].Dir)
+	assert.Equal(t, `<rpc message-id="1"/>`, string(recs[0].Data))
+	assert.Equal(t, In, recs[1].Dir)
+	assert.Equal(t, `<rpc-reply message-id="1"/>`, string(recs[1].Data))
+}
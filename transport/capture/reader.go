@@ -0,0 +1,354 @@
+package capture
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Reader provides random access to the messages in a capture file.  It is
+// safe for concurrent use.
+type Reader struct {
+	r       io.ReaderAt
+	size    int64
+	closer  io.Closer
+	dec     *zstd.Decoder
+	entries []Entry
+
+	// cache of the last decompressed block as messages are usually read in
+	// order.
+	mu        sync.Mutex
+	cacheOff  int64
+	cacheData []byte
+}
+
+// Open opens the named capture file for reading.
+func Open(name string) (*Reader, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	r, err := NewReader(f, fi.Size())
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	r.closer = f
+	return r, nil
+}
+
+// NewReader returns a Reader reading a capture of the given size from r.  If
+// the capture was not closed the index of the messages after the last index
+// block is rebuilt by scanning the blocks.
+func NewReader(r io.ReaderAt, size int64) (*Reader, error) {
+	hdr := make([]byte, headerLen)
+	if _, err := r.ReadAt(hdr, 0); err != nil || !bytes.Equal(hdr, magic) {
+		return nil, ErrInvalidCapture
+	}
+
+	dec, err := zstd.NewReader(nil, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+
+	cr := &Reader{
+		r:        r,
+		size:     size,
+		dec:      dec,
+		cacheOff: -1,
+	}
+
+	if err := cr.readIndex(); err != nil {
+		if err := cr.scan(); err != nil {
+			dec.Close()
+			return nil, err
+		}
+	}
+	return cr, nil
+}
+
+func (r *Reader) readIndex() error {
+	if r.size < headerLen+footerLen {
+		return ErrInvalidCapture
+	}
+
+	footer := make([]byte, footerLen)
+	if _, err := r.r.ReadAt(footer, r.size-footerLen); err != nil {
+		return err
+	}
+	if !bytes.Equal(footer[8:], footerMagic) {
+		return ErrInvalidCapture
+	}
+
+	kind, data, _, err := r.readBlock(int64(binary.BigEndian.Uint64(footer[:8])))
+	if err != nil {
+		return err
+	}
+	if kind != tableBlock {
+		return ErrInvalidCapture
+	}
+
+	var table []int64
+	if err := json.Unmarshal(data, &table); err != nil {
+		return fmt.Errorf("%w: bad index table: %v", ErrInvalidCapture, err)
+	}
+
+	r.entries = nil
+	for _, off := range table {
+		if err := r.readIndexBlock(off); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readIndexBlock adds the entries of the index block at off to the index.
+func (r *Reader) readIndexBlock(off int64) error {
+	kind, data, _, err := r.readBlock(off)
+	if err != nil {
+		return err
+	}
+	if kind != indexBlock {
+		return ErrInvalidCapture
+	}
+
+	var entries []Entry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("%w: bad index: %v", ErrInvalidCapture, err)
+	}
+	r.entries = append(r.entries, entries...)
+	return nil
+}
+
+// scan rebuilds the index of a capture without a table (i.e the Writer was
+// never closed).  The index blocks that were written are used and only the
+// data blocks after the last one are read.  A truncated block at the end of
+// the file (from a process that was killed while writing) is ignored.
+func (r *Reader) scan() error {
+	var (
+		indexes []int64
+		data    []int64
+	)
+	for off := int64(headerLen); off < r.size; {
+		kind, n, err := r.blockHeader(off)
+		if err != nil {
+			break
+		}
+		switch kind {
+		case indexBlock:
+			indexes = append(indexes, off)
+			data = data[:0]
+		case dataBlock:
+			data = append(data, off)
+		}
+		off += blockHeaderLen + n
+	}
+
+	r.entries = nil
+	for _, off := range indexes {
+		if err := r.readIndexBlock(off); err != nil {
+			return err
+		}
+	}
+
+	for _, off := range data {
+		_, block, _, err := r.readBlock(off)
+		if err != nil {
+			// the block was cut short.
+			break
+		}
+
+		for pos := 0; pos+recordHeadLen <= len(block); {
+			rec, n, err := decodeRecord(block[pos:])
+			if err != nil {
+				return err
+			}
+
+			kind, msgID := sniff(rec.Data)
+			r.entries = append(r.entries, Entry{
+				Seq:       len(r.entries),
+				Time:      rec.Time,
+				Dir:       rec.Dir,
+				Kind:      kind,
+				MessageID: msgID,
+				Size:      len(rec.Data),
+				Block:     off,
+				Offset:    pos,
+			})
+			pos += n
+		}
+	}
+	return nil
+}
+
+// blockHeader returns the kind and length of the block at the given offset.
+func (r *Reader) blockHeader(off int64) (kind byte, n int64, err error) {
+	var hdr [blockHeaderLen]byte
+	if _, err := r.r.ReadAt(hdr[:], off); err != nil {
+		return 0, 0, err
+	}
+
+	n = int64(binary.BigEndian.Uint32(hdr[1:]))
+	if off+blockHeaderLen+n > r.size {
+		return 0, 0, io.ErrUnexpectedEOF
+	}
+	return hdr[0], n, nil
+}
+
+// readBlock reads and decompresses the block at the given offset.  It returns
+// the offset of the next block.
+func (r *Reader) readBlock(off int64) (kind byte, data []byte, next int64, err error) {
+	kind, n, err := r.blockHeader(off)
+	if err != nil {
+		return 0, nil, 0, err
+	}
+
+	payload := make([]byte, n)
+	if _, err := r.r.ReadAt(payload, off+blockHeaderLen); err != nil {
+		return 0, nil, 0, err
+	}
+
+	data, err = r.dec.DecodeAll(payload, nil)
+	if err != nil {
+		return 0, nil, 0, fmt.Errorf("%w: %v", ErrInvalidCapture, err)
+	}
+	return kind, data, off + blockHeaderLen + n, nil
+}
+
+func decodeRecord(data []byte) (Record, int, error) {
+	if len(data) < recordHeadLen {
+		return Record{}, 0, ErrInvalidCapture
+	}
+
+	size := int(binary.BigEndian.Uint32(data[9:13]))
+	if len(data) < recordHeadLen+size {
+		return Record{}, 0, ErrInvalidCapture
+	}
+
+	return Record{
+		Time: time.Unix(0, int64(binary.BigEndian.Uint64(data[0:8]))),
+		Dir:  Dir(data[8]),
+		Data: data[recordHeadLen : recordHeadLen+size : recordHeadLen+size],
+	}, recordHeadLen + size, nil
+}
+
+// Len returns the number of messages in the capture.
+func (r *Reader) Len() int { return len(r.entries) }
+
+// Entries returns the index of all the messages in the capture.  The returned
+// slice must not be modified.
+func (r *Reader) Entries() []Entry { return r.entries }
+
+// Record returns the message with the given sequence number.
+func (r *Reader) Record(seq int) (Record, error) {
+	if seq < 0 || seq >= len(r.entries) {
+		return Record{}, ErrNotFound
+	}
+	e := r.entries[seq]
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.cacheOff != e.Block {
+		_, data, _, err := r.readBlock(e.Block)
+		if err != nil {
+			return Record{}, err
+		}
+		r.cacheOff, r.cacheData = e.Block, data
+	}
+
+	if e.Offset > len(r.cacheData) {
+		return Record{}, ErrInvalidCapture
+	}
+	rec, _, err := decodeRecord(r.cacheData[e.Offset:])
+	if err != nil {
+		return Record{}, err
+	}
+	rec.Data = append([]byte(nil), rec.Data...)
+	return rec, nil
+}
+
+// Search returns the sequence number of the first message captured at or
+// after t.  Returns Len() if there are none.
+func (r *Reader) Search(t time.Time) int {
+	return sort.Search(len(r.entries), func(i int) bool {
+		return !r.entries[i].Time.Before(t)
+	})
+}
+
+// Exchanges returns all the rpc exchanges in the capture in the order the
+// requests were sent.  Message-ids are only unique within a netconf session so
+// requests are matched to replies that follow them and pending requests are
+// forgotten whenever a new session starts (a `<hello>` is sent).
+func (r *Reader) Exchanges() []Exchange {
+	var (
+		out     []Exchange
+		pending = make(map[string]int)
+	)
+
+	for i := range r.entries {
+		e := &r.entries[i]
+		switch {
+		case e.Kind == "hello" && e.Dir == Out:
+			pending = make(map[string]int)
+		case e.Kind == "rpc" && e.Dir == Out:
+			out = append(out, Exchange{Request: e})
+			if e.MessageID != "" {
+				pending[e.MessageID] = len(out) - 1
+			}
+		case e.Kind == "rpc-reply" && e.Dir == In:
+			if idx, ok := pending[e.MessageID]; ok {
+				out[idx].Reply = e
+				delete(pending, e.MessageID)
+			}
+		}
+	}
+	return out
+}
+
+// Exchange returns the request and reply of the first rpc exchange with the
+// given message-id at or after the message with the sequence number from.
+// reply is empty if the reply was not captured.
+func (r *Reader) Exchange(msgID string, from int) (req, reply Record, err error) {
+	for _, ex := range r.Exchanges() {
+		if ex.Request.Seq < from || ex.Request.MessageID != msgID {
+			continue
+		}
+
+		if req, err = r.Record(ex.Request.Seq); err != nil {
+			return Record{}, Record{}, err
+		}
+		if ex.Reply != nil {
+			if reply, err = r.Record(ex.Reply.Seq); err != nil {
+				return Record{}, Record{}, err
+			}
+		}
+		return req, reply, nil
+	}
+	return Record{}, Record{}, ErrNotFound
+}
+
+// Close releases the resources used by the reader.  If the reader was created
+// with Open then the file is closed.
+func (r *Reader) Close() error {
+	r.dec.Close()
+	if r.closer != nil {
+		return r.closer.Close()
+	}
+	return nil
+}
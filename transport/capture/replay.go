@@ -0,0 +1,220 @@
+package capture
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/nemith/netconf/xmltree"
+)
+
+// ErrReplayMismatch is returned when closing a message written to a [Replay]
+// that doesn't match the next message sent in the recording.
+var ErrReplayMismatch = errors.New("capture: message does not match recording")
+
+// MessageMatcher reports if a message written to a [Replay] matches the
+// message in the recording.
+type MessageMatcher func(recorded, actual []byte) bool
+
+// ReplayOption is an optional argument to [NewReplay].
+type ReplayOption interface {
+	apply(*Replay)
+}
+
+type matcherOpt MessageMatcher
+
+func (o matcherOpt) apply(r *Replay) { r.match = MessageMatcher(o) }
+
+// WithMessageMatcher sets how messages written to the replay are compared to
+// the recording.  The default is [MatchMessages].
+func WithMessageMatcher(m MessageMatcher) ReplayOption { return matcherOpt(m) }
+
+// Replay is a transport that plays back recorded messages (from a transcript
+// or a capture) to allow testing code against captured device behavior
+// without a device.
+//
+// Messages written to the replay are checked in order against the sent
+// messages in the recording.  After each one the received messages recorded
+// after it are served until the next sent message.  The message-ids of
+// replies are rewritten to match the requests actually written so the
+// recording can be replayed with sessions using any message-id scheme.
+//
+// A message that doesn't match the recording (or is written after the end of
+// the recording) fails with [ErrReplayMismatch] when the message writer is
+// closed.
+//
+//	f, err := os.Open("testdata/get-config.jsonl")
+//	if err != nil { /* ... */ }
+//	recs, err := capture.ReadTranscript(f)
+//	if err != nil { /* ... */ }
+//	session, err := netconf.Open(capture.NewReplay(recs))
+type Replay struct {
+	recs  []Record
+	match MessageMatcher
+
+	mu  sync.Mutex
+	pos int
+	ids map[string]string
+
+	in        chan []byte
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewReplay returns a transport that replays the recorded messages.
+func NewReplay(recs []Record, opts ...ReplayOption) *Replay {
+	r := &Replay{
+		recs:  recs,
+		match: MatchMessages,
+		ids:   make(map[string]string),
+		in:    make(chan []byte, len(recs)),
+		done:  make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt.apply(r)
+	}
+
+	// serve the messages received before anything was sent (i.e the server
+	// hello).
+	r.queueReceived()
+	return r
+}
+
+// queueReceived queues the received messages up to the next sent message.
+// Must be called with r.mu held (or before the replay is used).
+func (r *Replay) queueReceived() {
+	for ; r.pos < len(r.recs) && r.recs[r.pos].Dir == In; r.pos++ {
+		r.in <- r.rewriteID(r.recs[r.pos].Data)
+	}
+}
+
+// rewriteID replaces the recorded message-id of a message with the id of the
+// request written during the replay.
+func (r *Replay) rewriteID(data []byte) []byte {
+	_, recID := sniff(data)
+	liveID, ok := r.ids[recID]
+	if recID == "" || !ok || liveID == recID {
+		return data
+	}
+
+	for _, q := range []string{`"`, `'`} {
+		old := []byte("message-id=" + q + recID + q)
+		if bytes.Contains(data, old) {
+			quoted := "message-id=" + `"` + escapeAttr(liveID) + `"`
+			return bytes.Replace(data, old, []byte(quoted), 1)
+		}
+	}
+	return data
+}
+
+func escapeAttr(s string) string {
+	var buf bytes.Buffer
+	_ = xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+// MsgReader implements transport.Transport.  It blocks until there is a
+// recorded message to serve or the replay is closed.
+func (r *Replay) MsgReader() (io.ReadCloser, error) {
+	select {
+	case msg := <-r.in:
+		return io.NopCloser(bytes.NewReader(msg)), nil
+	case <-r.done:
+		return nil, io.EOF
+	}
+}
+
+// MsgWriter implements transport.Transport.
+func (r *Replay) MsgWriter() (io.WriteCloser, error) {
+	select {
+	case <-r.done:
+		return nil, io.ErrClosedPipe
+	default:
+	}
+	return &replayWriter{r: r}, nil
+}
+
+// Close implements transport.Transport.
+func (r *Replay) Close() error {
+	r.closeOnce.Do(func() { close(r.done) })
+	return nil
+}
+
+// Remaining returns the number of recorded messages that have not been
+// replayed yet.  Tests can use it to check that everything in the recording
+// was exercised.
+func (r *Replay) Remaining() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.recs) - r.pos
+}
+
+func (r *Replay) written(msg []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.pos >= len(r.recs) {
+		return fmt.Errorf("%w: unexpected message after the end of the recording: %s", ErrReplayMismatch, msg)
+	}
+
+	rec := r.recs[r.pos]
+	if !r.match(rec.Data, msg) {
+		return fmt.Errorf("%w: message %d: got %s, want %s", ErrReplayMismatch, r.pos, msg, rec.Data)
+	}
+	r.pos++
+
+	if _, recID := sniff(rec.Data); recID != "" {
+		if _, liveID := sniff(msg); liveID != "" {
+			r.ids[recID] = liveID
+		}
+	}
+
+	r.queueReceived()
+	return nil
+}
+
+type replayWriter struct {
+	r   *Replay
+	buf bytes.Buffer
+}
+
+func (w *replayWriter) Write(p []byte) (int, error) { return w.buf.Write(p) }
+
+func (w *replayWriter) Close() error { return w.r.written(w.buf.Bytes()) }
+
+// MatchMessages compares two messages with [xmltree.Equal] ignoring the
+// message-id of the root element.  Namespace prefixes, attribute order,
+// comments, whitespace between elements and leading and trailing whitespace
+// of text are ignored.
+func MatchMessages(recorded, actual []byte) bool {
+	a, err := xmltree.Parse(recorded)
+	if err != nil {
+		return false
+	}
+	b, err := xmltree.Parse(actual)
+	if err != nil {
+		return false
+	}
+	removeMessageID(a)
+	removeMessageID(b)
+	return xmltree.Equal(a, b)
+}
+
+// removeMessageID removes the message-id attribute of the root element.
+func removeMessageID(nodes []*xmltree.Node) {
+	if len(nodes) == 0 {
+		return
+	}
+	root := nodes[0]
+	attrs := root.Attrs[:0]
+	for _, attr := range root.Attrs {
+		if attr.Name.Space == "" && attr.Name.Local == "message-id" {
+			continue
+		}
+		attrs = append(attrs, attr)
+	}
+	root.Attrs = attrs
+}
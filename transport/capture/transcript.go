@@ -0,0 +1,147 @@
+package capture
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Recorder records messages.  It is implemented by [Writer],
+// [TranscriptWriter] and [Printer].
+type Recorder interface {
+	Write(rec Record) error
+}
+
+// transcriptLine is a single line of a transcript.
+type transcriptLine struct {
+	Time      time.Time `json:"time"`
+	Dir       string    `json:"dir"`
+	Kind      string    `json:"kind,omitempty"`
+	MessageID string    `json:"message-id,omitempty"`
+	Data      string    `json:"data"`
+
+	// Raw is the message for messages that aren't valid UTF-8 and can't be
+	// kept exactly in a JSON string.
+	Raw []byte `json:"raw,omitempty"`
+}
+
+// TranscriptWriter writes messages as a transcript: a human readable (and
+// diffable) JSON Lines file with one message per line.  Transcripts are meant
+// for short recordings (i.e. a single run of some automation) that are
+// committed alongside tests and served back with [Replay].  Use [Writer] for
+// long running captures.
+//
+// A line looks like:
+//
+//	{"time":"2024-05-01T10:00:00Z","dir":"out","kind":"rpc","message-id":"1","data":"<rpc ...>...</rpc>"}
+//
+// Messages are written exactly as given.  Record them with [NewTransport] or
+// [RecordMessages] which copy the bytes read from and written to the
+// transport so the transcript holds what was exchanged on the wire rather
+// than a re-encoding of it.  Messages that aren't valid UTF-8 are written
+// base64 encoded in a `raw` field instead of `data`.
+//
+// It is safe for concurrent use.
+type TranscriptWriter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	err error
+}
+
+// NewTranscriptWriter returns a TranscriptWriter writing to w.
+func NewTranscriptWriter(w io.Writer) *TranscriptWriter {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return &TranscriptWriter{enc: enc}
+}
+
+// Write adds a message to the transcript.  If the time of the record is zero
+// the current time is used.  Once writing fails all further writes return the
+// same error.
+func (w *TranscriptWriter) Write(rec Record) error {
+	if rec.Time.IsZero() {
+		rec.Time = time.Now()
+	}
+	kind, msgID := sniff(rec.Data)
+	line := transcriptLine{
+		Time:      rec.Time,
+		Dir:       rec.Dir.String(),
+		Kind:      kind,
+		MessageID: msgID,
+	}
+	// encoding/json replaces invalid UTF-8 in strings.
+	if utf8.Valid(rec.Data) {
+		line.Data = string(rec.Data)
+	} else {
+		line.Raw = rec.Data
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		return w.err
+	}
+	w.err = w.enc.Encode(line)
+	return w.err
+}
+
+// Err returns the first error that occurred while writing.
+func (w *TranscriptWriter) Err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+// ReadTranscript reads all the messages of a transcript written by a
+// [TranscriptWriter].
+func ReadTranscript(r io.Reader) ([]Record, error) {
+	var recs []Record
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 64*1024*1024)
+	for lineNo := 1; sc.Scan(); lineNo++ {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+
+		var line transcriptLine
+		if err := json.Unmarshal(sc.Bytes(), &line); err != nil {
+			return nil, fmt.Errorf("transcript line %d: %w", lineNo, err)
+		}
+
+		rec := Record{Time: line.Time, Data: []byte(line.Data)}
+		if line.Raw != nil {
+			rec.Data = line.Raw
+		}
+		switch line.Dir {
+		case In.String():
+			rec.Dir = In
+		case Out.String():
+			rec.Dir = Out
+		default:
+			return nil, fmt.Errorf("transcript line %d: invalid direction %q", lineNo, line.Dir)
+		}
+		recs = append(recs, rec)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return recs, nil
+}
+
+// Records returns all the messages in the capture in order.
+func (r *Reader) Records() ([]Record, error) {
+	recs := make([]Record, 0, r.Len())
+	for i := 0; i < r.Len(); i++ {
+		rec, err := r.Record(i)
+		if err != nil {
+			return nil, err
+		}
+		recs = append(recs, rec)
+	}
+	return recs, nil
+}
@@ -0,0 +1,144 @@
+package capture
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nemith/netconf"
+	"github.com/nemith/netconf/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTranscriptRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewTranscriptWriter(&buf)
+	for _, rec := range testRecords() {
+		require.NoError(t, w.Write(rec))
+	}
+	require.NoError(t, w.Err())
+
+	first, _, _ := strings.Cut(buf.String(), "\n")
+	assert.Contains(t, first, `"dir":"out","kind":"hello","data":"<hello`)
+
+	recs, err := ReadTranscript(&buf)
+	require.NoError(t, err)
+	want := testRecords()
+	require.Len(t, recs, len(want))
+	for i := range want {
+		assert.True(t, want[i].Time.Equal(recs[i].Time))
+		assert.Equal(t, want[i].Dir, recs[i].Dir)
+		assert.Equal(t, want[i].Data, recs[i].Data)
+	}
+
+	_, err = ReadTranscript(strings.NewReader(`{"dir":"sideways","data":""}`))
+	assert.ErrorContains(t, err, `transcript line 1: invalid direction "sideways"`)
+}
+
+func TestTranscriptExact(t *testing.T) {
+	// formatting, entities and bytes that aren't valid UTF-8 are kept as read.
+	msgs := []string{
+		"<?xml version=\"1.0\"?>\n<hello xmlns='urn:ietf:params:xml:ns:netconf:base:1.0' >\r\n  <x>&#65;&amp;</x>\n</hello>",
+		"<rpc-reply message-id=\"1\"><data>\xff\xfe</data></rpc-reply>",
+	}
+	in := strings.NewReader(msgs[0] + "]]>]]>" + msgs[1] + "]]>]]>")
+
+	var buf bytes.Buffer
+	tr := NewTransport(framedTransport{transport.NewFramer(in, io.Discard)}, NewTranscriptWriter(&buf))
+	for range msgs {
+		r, err := tr.MsgReader()
+		require.NoError(t, err)
+		_, err = io.ReadAll(r)
+		require.NoError(t, err)
+		require.NoError(t, r.Close())
+	}
+
+	recs, err := ReadTranscript(&buf)
+	require.NoError(t, err)
+	require.Len(t, recs, len(msgs))
+	for i, msg := range msgs {
+		assert.Equal(t, []byte(msg), recs[i].Data)
+	}
+}
+
+const replayTranscript = `
+{"dir":"in","data":"<hello xmlns=\"urn:ietf:params:xml:ns:netconf:base:1.0\"><capabilities><capability>urn:ietf:params:netconf:base:1.0</capability></capabilities><session-id>5</session-id></hello>"}
+{"dir":"out","data":"<hello xmlns=\"urn:ietf:params:xml:ns:netconf:base:1.0\"><capabilities><capability>urn:ietf:params:netconf:base:1.0</capability><capability>urn:ietf:params:netconf:base:1.1</capability></capabilities></hello>"}
+{"dir":"out","data":"<rpc xmlns=\"urn:ietf:params:xml:ns:netconf:base:1.0\" message-id=\"101\">\n  <get-config><source><running/></source></get-config>\n</rpc>"}
+{"dir":"in","data":"<rpc-reply xmlns=\"urn:ietf:params:xml:ns:netconf:base:1.0\" message-id=\"101\"><data><system/></data></rpc-reply>"}
+{"dir":"out","data":"<rpc xmlns=\"urn:ietf:params:xml:ns:netconf:base:1.0\" message-id=\"102\"><close-session/></rpc>"}
+{"dir":"in","data":"<rpc-reply xmlns=\"urn:ietf:params:xml:ns:netconf:base:1.0\" message-id=\"102\"><ok/></rpc-reply>"}
+`
+
+func TestMatchMessages(t *testing.T) {
+	tt := []struct {
+		name             string
+		recorded, actual string
+		match            bool
+	}{
+		{
+			name:     "message-id and formatting",
+			recorded: `<rpc xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="101">` + "\n  <get-config><source><running/></source></get-config>\n</rpc>",
+			actual:   `<nc:rpc xmlns:nc="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="test-1"><nc:get-config><nc:source><nc:running></nc:running></nc:source></nc:get-config></nc:rpc>`,
+			match:    true,
+		},
+		{
+			name:     "text",
+			recorded: `<rpc message-id="1"><lock><target>running</target></lock></rpc>`,
+			actual:   `<rpc message-id="1"><lock><target>candidate</target></lock></rpc>`,
+		},
+		{
+			name:     "nested message-id",
+			recorded: `<rpc message-id="1"><x message-id="1"/></rpc>`,
+			actual:   `<rpc message-id="1"><x message-id="2"/></rpc>`,
+		},
+		{
+			name:     "invalid",
+			recorded: `<rpc message-id="1"/>`,
+			actual:   `<rpc message-id="1">`,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.match, MatchMessages([]byte(tc.recorded), []byte(tc.actual)))
+		})
+	}
+}
+
+func TestReplay(t *testing.T) {
+	recs, err := ReadTranscript(strings.NewReader(replayTranscript))
+	require.NoError(t, err)
+
+	tr := NewReplay(recs)
+	sess, err := netconf.Open(tr, netconf.WithMessageIDFunc(netconf.PrefixMessageID("test-")))
+	require.NoError(t, err)
+	assert.EqualValues(t, 5, sess.SessionID())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	data, err := sess.GetConfig(ctx, "running")
+	require.NoError(t, err)
+	assert.Equal(t, "<system/>", string(data))
+
+	require.NoError(t, sess.Close(ctx))
+	assert.Zero(t, tr.Remaining())
+}
+
+func TestReplayMismatch(t *testing.T) {
+	recs, err := ReadTranscript(strings.NewReader(replayTranscript))
+	require.NoError(t, err)
+
+	tr := NewReplay(recs)
+	sess, err := netconf.Open(tr)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err = sess.GetConfig(ctx, "candidate")
+	assert.ErrorIs(t, err, ErrReplayMismatch)
+}
@@ -0,0 +1,185 @@
+package capture
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"os"
+	"time"
+
+	"github.com/nemith/netconf/transport"
+)
+
+// Transport wraps another transport and records every message read or
+// written to a [Recorder] (i.e a [Writer] or a [TranscriptWriter]).
+//
+// Failing to record a message does not interrupt the session.  The error is
+// kept by the Writer and returned from its Close method.
+type Transport struct {
+	transport.Transport
+	w Recorder
+}
+
+// NewTransport returns a transport that records all messages of tr to w.  The
+// Writer is not closed when the transport is closed as it may be shared by
+// multiple transports.
+//
+//	w, err := capture.Create("netconf.ncap")
+//	if err != nil { /* ... */ }
+//	defer w.Close()
+//
+//	tr, err := ssh.Dial(ctx, "tcp", addr, config)
+//	if err != nil { /* ... */ }
+//	session, err := netconf.Open(capture.NewTransport(tr, w))
+func NewTransport(tr transport.Transport, w Recorder) *Transport {
+	return &Transport{Transport: tr, w: w}
+}
+
+// MsgReader implements transport.Transport.
+func (t *Transport) MsgReader() (io.ReadCloser, error) {
+	r, err := t.Transport.MsgReader()
+	if err != nil {
+		return nil, err
+	}
+	return &recordReader{ReadCloser: r, w: t.w}, nil
+}
+
+// MsgWriter implements transport.Transport.
+func (t *Transport) MsgWriter() (io.WriteCloser, error) {
+	w, err := t.Transport.MsgWriter()
+	if err != nil {
+		return nil, err
+	}
+	return &recordWriter{WriteCloser: w, w: t.w}, nil
+}
+
+// Upgrade upgrades the wrapped transport if it supports it.
+func (t *Transport) Upgrade() {
+	if upgrader, ok := t.Transport.(interface{ Upgrade() }); ok {
+		upgrader.Upgrade()
+	}
+}
+
+// PinEOM pins the wrapped transport to End-of-Message framing if it supports
+// it.
+func (t *Transport) PinEOM() {
+	if pinner, ok := t.Transport.(interface{ PinEOM() }); ok {
+		pinner.PinEOM()
+	}
+}
+
+// DebugCapture copies the framed data read from and written to the wrapped
+// transport to in and out if it supports it (see
+// [transport.Framer.DebugCapture]).  It can be called at any time to start or
+// stop capturing a session.
+func (t *Transport) DebugCapture(in, out io.Writer) {
+	if tr, ok := t.Transport.(interface{ DebugCapture(in, out io.Writer) }); ok {
+		tr.DebugCapture(in, out)
+	}
+}
+
+// DebugCaptureMsgs calls fn with every message read from and written to the
+// wrapped transport if it supports it (see [transport.Framer.DebugCaptureMsgs])
+// and does nothing otherwise.  It can be called at any time to start or stop
+// capturing a session.
+func (t *Transport) DebugCaptureMsgs(fn func(transport.CapturedMsg)) {
+	if tr, ok := t.Transport.(interface {
+		DebugCaptureMsgs(fn func(transport.CapturedMsg))
+	}); ok {
+		tr.DebugCaptureMsgs(fn)
+	}
+}
+
+// RemoteAddr returns the remote address of the wrapped transport or nil if
+// it's not known.
+func (t *Transport) RemoteAddr() net.Addr {
+	if tr, ok := t.Transport.(interface{ RemoteAddr() net.Addr }); ok {
+		return tr.RemoteAddr()
+	}
+	return nil
+}
+
+// SetReadDeadline sets the read deadline of the wrapped transport if it
+// supports it.
+func (t *Transport) SetReadDeadline(d time.Time) error {
+	if tr, ok := t.Transport.(transport.Deadliner); ok {
+		return tr.SetReadDeadline(d)
+	}
+	return os.ErrNoDeadline
+}
+
+// SetWriteDeadline sets the write deadline of the wrapped transport if it
+// supports it.
+func (t *Transport) SetWriteDeadline(d time.Time) error {
+	if tr, ok := t.Transport.(transport.Deadliner); ok {
+		return tr.SetWriteDeadline(d)
+	}
+	return os.ErrNoDeadline
+}
+
+// Ping pings the wrapped transport if it supports it (see
+// [transport.Pinger]) or returns nil otherwise.
+func (t *Transport) Ping(ctx context.Context) error {
+	if tr, ok := t.Transport.(transport.Pinger); ok {
+		return tr.Ping(ctx)
+	}
+	return nil
+}
+
+type recordReader struct {
+	io.ReadCloser
+	w   Recorder
+	buf bytes.Buffer
+	eof bool
+}
+
+func (r *recordReader) Read(p []byte) (int, error) {
+	if r.eof {
+		return 0, io.EOF
+	}
+	n, err := r.ReadCloser.Read(p)
+	r.buf.Write(p[:n])
+	if err == io.EOF {
+		r.eof = true
+	}
+	return n, err
+}
+
+func (r *recordReader) Close() error {
+	// read the rest of the message so the whole message is recorded even if
+	// the caller stopped reading early.
+	var err error
+	if !r.eof {
+		_, err = io.Copy(io.Discard, r)
+	}
+	if cerr := r.ReadCloser.Close(); err == nil {
+		err = cerr
+	}
+	if r.buf.Len() > 0 {
+		_ = r.w.Write(Record{Time: time.Now(), Dir: In, Data: r.buf.Bytes()})
+		r.buf.Reset()
+	}
+	return err
+}
+
+type recordWriter struct {
+	io.WriteCloser
+	w   Recorder
+	buf bytes.Buffer
+}
+
+func (w *recordWriter) Write(p []byte) (int, error) {
+	n, err := w.WriteCloser.Write(p)
+	w.buf.Write(p[:n])
+	return n, err
+}
+
+func (w *recordWriter) Close() error {
+	err := w.WriteCloser.Close()
+	if w.buf.Len() > 0 {
+		_ = w.w.Write(Record{Time: time.Now(), Dir: Out, Data: w.buf.Bytes()})
+		w.buf.Reset()
+	}
+	return err
+}
@@ -0,0 +1,255 @@
+package capture
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// DefaultBlockSize is the amount of uncompressed message data that is
+// buffered before a block is compressed and written out.
+const DefaultBlockSize = 256 * 1024
+
+// DefaultIndexEntries is the number of index entries buffered before they
+// are written out as an index block.
+const DefaultIndexEntries = 4096
+
+// Writer writes a capture file.  It is safe for concurrent use.
+type Writer struct {
+	// BlockSize is the amount of uncompressed data buffered before it is
+	// compressed and written as a block.  Larger blocks compress better but
+	// more data is lost if the process exits without closing the Writer.  If
+	// zero, DefaultBlockSize is used.
+	BlockSize int
+
+	// IndexEntries is the number of index entries buffered before they are
+	// written as an index block.  Only the entries since the last index block
+	// are kept in memory.  If zero, DefaultIndexEntries is used.
+	IndexEntries int
+
+	mu      sync.Mutex
+	w       io.Writer
+	closer  io.Closer
+	enc     *zstd.Encoder
+	off     int64
+	buf     bytes.Buffer
+	pending []Entry
+	entries []Entry // entries not written to an index block yet
+	indexes []int64 // offsets of the index blocks
+	n       int     // number of messages written
+	err     error
+}
+
+// NewWriter returns a Writer writing a capture to w.  Closing the Writer does
+// not close w.
+func NewWriter(w io.Writer) (*Writer, error) {
+	enc, err := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+
+	cw := &Writer{w: w, enc: enc}
+	if err := cw.write(magic); err != nil {
+		return nil, err
+	}
+	return cw, nil
+}
+
+// Create creates (or truncates) the named file and returns a Writer for it.
+// The file is closed when the Writer is closed.
+func Create(name string) (*Writer, error) {
+	f, err := os.Create(name)
+	if err != nil {
+		return nil, err
+	}
+
+	w, err := NewWriter(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	w.closer = f
+	return w, nil
+}
+
+func (w *Writer) write(p []byte) error {
+	n, err := w.w.Write(p)
+	w.off += int64(n)
+	return err
+}
+
+// Write adds a message to the capture.  If the time of the record is zero the
+// current time is used.
+func (w *Writer) Write(rec Record) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.err != nil {
+		return w.err
+	}
+
+	if rec.Time.IsZero() {
+		rec.Time = time.Now()
+	}
+
+	kind, msgID := sniff(rec.Data)
+	w.pending = append(w.pending, Entry{
+		Seq:       w.n,
+		Time:      rec.Time,
+		Dir:       rec.Dir,
+		Kind:      kind,
+		MessageID: msgID,
+		Size:      len(rec.Data),
+		Offset:    w.buf.Len(),
+	})
+	w.n++
+
+	var hdr [recordHeadLen]byte
+	binary.BigEndian.PutUint64(hdr[0:8], uint64(rec.Time.UnixNano()))
+	hdr[8] = byte(rec.Dir)
+	binary.BigEndian.PutUint32(hdr[9:13], uint32(len(rec.Data)))
+	w.buf.Write(hdr[:])
+	w.buf.Write(rec.Data)
+
+	blockSize := w.BlockSize
+	if blockSize <= 0 {
+		blockSize = DefaultBlockSize
+	}
+	if w.buf.Len() >= blockSize {
+		return w.flush()
+	}
+	return nil
+}
+
+// Flush compresses and writes any buffered messages to the underlying writer.
+func (w *Writer) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.err != nil {
+		return w.err
+	}
+	return w.flush()
+}
+
+func (w *Writer) flush() error {
+	if w.buf.Len() == 0 {
+		return nil
+	}
+
+	blockOff := w.off
+	if err := w.writeBlock(dataBlock, w.buf.Bytes()); err != nil {
+		w.err = err
+		return err
+	}
+
+	for _, e := range w.pending {
+		e.Block = blockOff
+		w.entries = append(w.entries, e)
+	}
+	w.pending = w.pending[:0]
+	w.buf.Reset()
+
+	indexEntries := w.IndexEntries
+	if indexEntries <= 0 {
+		indexEntries = DefaultIndexEntries
+	}
+	if len(w.entries) >= indexEntries {
+		return w.writeIndex()
+	}
+	return nil
+}
+
+// writeIndex writes the buffered index entries as an index block.  An index
+// block covers all the data blocks written since the previous one.
+func (w *Writer) writeIndex() error {
+	if len(w.entries) == 0 {
+		return nil
+	}
+
+	idx, err := json.Marshal(w.entries)
+	if err != nil {
+		w.err = fmt.Errorf("capture: failed to encode index: %w", err)
+		return w.err
+	}
+
+	idxOff := w.off
+	if err := w.writeBlock(indexBlock, idx); err != nil {
+		w.err = err
+		return err
+	}
+	w.indexes = append(w.indexes, idxOff)
+	w.entries = w.entries[:0]
+	return nil
+}
+
+func (w *Writer) writeBlock(kind byte, data []byte) error {
+	payload := w.enc.EncodeAll(data, nil)
+
+	var hdr [blockHeaderLen]byte
+	hdr[0] = kind
+	binary.BigEndian.PutUint32(hdr[1:], uint32(len(payload)))
+	if err := w.write(hdr[:]); err != nil {
+		return err
+	}
+	return w.write(payload)
+}
+
+// Close flushes any buffered messages and writes the rest of the index.  If the Writer was
+// created with Create then the file is closed as well.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if errors.Is(w.err, errClosed) {
+		return nil
+	}
+
+	err := w.err
+	if err == nil {
+		err = w.finish()
+	}
+	w.err = errClosed
+
+	w.enc.Close()
+	if w.closer != nil {
+		if cerr := w.closer.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+var errClosed = errors.New("capture: writer closed")
+
+func (w *Writer) finish() error {
+	if err := w.flush(); err != nil {
+		return err
+	}
+	if err := w.writeIndex(); err != nil {
+		return err
+	}
+
+	table, err := json.Marshal(w.indexes)
+	if err != nil {
+		return fmt.Errorf("capture: failed to encode index table: %w", err)
+	}
+
+	tableOff := w.off
+	if err := w.writeBlock(tableBlock, table); err != nil {
+		return err
+	}
+
+	var footer [footerLen]byte
+	binary.BigEndian.PutUint64(footer[:8], uint64(tableOff))
+	copy(footer[8:], footerMagic)
+	return w.write(footer[:])
+}
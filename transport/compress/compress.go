@@ -0,0 +1,291 @@
+// Package compress implements a transport that compresses netconf messages
+// with gzip for low-bandwidth management links where large configurations
+// dominate the transfer time.
+//
+// golang.org/x/crypto/ssh doesn't support ssh compression
+// (zlib@openssh.com) so instead each message is compressed on its own.  This
+// is not part of any standard: compression is only used when both ends
+// advertise [Capability] in their hello and use chunked framing (base:1.1)
+// which means the device (or proxy) has to use this package as well.
+// Otherwise messages are passed through unchanged.
+//
+//	tr, err := ssh.Dial(ctx, "tcp", addr, config)
+//	if err != nil { /* ... */ }
+//	session, err := netconf.Open(compress.NewTransport(tr),
+//		netconf.WithCapability(compress.Capability))
+package compress
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/nemith/netconf/transport"
+)
+
+// Capability is advertised in the hello by both ends to compress messages.
+const Capability = "urn:github.com:nemith:netconf:capability:compress:gzip:1.0"
+
+// Option is an optional argument to [NewTransport].
+type Option interface {
+	apply(*Transport)
+}
+
+type levelOpt int
+
+func (o levelOpt) apply(t *Transport) { t.level = int(o) }
+
+// WithLevel sets the gzip compression level (gzip.BestSpeed to
+// gzip.BestCompression).  The default is gzip.DefaultCompression.
+func WithLevel(level int) Option { return levelOpt(level) }
+
+// Transport wraps another transport and compresses the messages sent and
+// received once both ends have agreed to it in the hello exchange.
+type Transport struct {
+	transport.Transport
+	level int
+
+	mu        sync.Mutex
+	sentHello bool
+	recvHello bool
+	sentCap   bool // the hello sent has the capability
+	recvCap   bool // the hello received has the capability
+	upgraded  bool
+
+	// reused for every message as only one message can be read or written
+	// at a time.
+	gzw *gzip.Writer
+	gzr *gzip.Reader
+}
+
+// NewTransport returns a transport that compresses the messages of tr when
+// both ends support it.
+func NewTransport(tr transport.Transport, opts ...Option) *Transport {
+	t := &Transport{
+		Transport: tr,
+		level:     gzip.DefaultCompression,
+	}
+	for _, opt := range opts {
+		opt.apply(t)
+	}
+	return t
+}
+
+// Enabled reports whether messages are compressed.  This is only known after
+// the hello exchange.
+func (t *Transport) Enabled() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.enabled()
+}
+
+func (t *Transport) enabled() bool {
+	return t.upgraded && t.sentCap && t.recvCap
+}
+
+// MsgReader implements transport.Transport.
+func (t *Transport) MsgReader() (io.ReadCloser, error) {
+	r, err := t.Transport.MsgReader()
+	if err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch {
+	case !t.recvHello:
+		t.recvHello = true
+		return &helloReader{ReadCloser: r, seen: func(ok bool) { t.setCap(&t.recvCap, ok) }}, nil
+	case t.enabled():
+		return &reader{ReadCloser: r, t: t}, nil
+	}
+	return r, nil
+}
+
+// MsgWriter implements transport.Transport.
+func (t *Transport) MsgWriter() (io.WriteCloser, error) {
+	w, err := t.Transport.MsgWriter()
+	if err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch {
+	case !t.sentHello:
+		t.sentHello = true
+		return &helloWriter{WriteCloser: w, seen: func(ok bool) { t.setCap(&t.sentCap, ok) }}, nil
+	case t.enabled():
+		if t.gzw == nil {
+			t.gzw, err = gzip.NewWriterLevel(w, t.level)
+			if err != nil {
+				w.Close()
+				return nil, err
+			}
+		} else {
+			t.gzw.Reset(w)
+		}
+		return &writer{gz: t.gzw, w: w}, nil
+	}
+	return w, nil
+}
+
+func (t *Transport) setCap(hasCap *bool, ok bool) {
+	t.mu.Lock()
+	*hasCap = ok
+	t.mu.Unlock()
+}
+
+// Upgrade upgrades the wrapped transport if it supports it.  Messages are
+// only compressed once the transport is upgraded to chunked framing.
+func (t *Transport) Upgrade() {
+	if upgrader, ok := t.Transport.(interface{ Upgrade() }); ok {
+		upgrader.Upgrade()
+		t.mu.Lock()
+		t.upgraded = true
+		t.mu.Unlock()
+	}
+}
+
+// PinEOM pins the wrapped transport to End-of-Message framing if it supports
+// it.
+func (t *Transport) PinEOM() {
+	if pinner, ok := t.Transport.(interface{ PinEOM() }); ok {
+		pinner.PinEOM()
+	}
+}
+
+// DebugCapture copies the framed data read from and written to the wrapped
+// transport to in and out if it supports it (see
+// [transport.Framer.DebugCapture]).  This is the data as sent on the wire so
+// once compression is in use the messages are captured compressed.
+//
+// Unlike the other wrappers in this module the Transport doesn't forward
+// DebugCaptureMsgs as the messages of the wrapped transport are compressed.
+// Sessions capture the uncompressed messages themselves instead (see
+// Session.DebugCapture in the netconf package).
+func (t *Transport) DebugCapture(in, out io.Writer) {
+	if tr, ok := t.Transport.(interface{ DebugCapture(in, out io.Writer) }); ok {
+		tr.DebugCapture(in, out)
+	}
+}
+
+// RemoteAddr returns the remote address of the wrapped transport or nil if
+// it's not known.
+func (t *Transport) RemoteAddr() net.Addr {
+	if tr, ok := t.Transport.(interface{ RemoteAddr() net.Addr }); ok {
+		return tr.RemoteAddr()
+	}
+	return nil
+}
+
+// SetReadDeadline sets the read deadline of the wrapped transport if it
+// supports it.
+func (t *Transport) SetReadDeadline(d time.Time) error {
+	if tr, ok := t.Transport.(transport.Deadliner); ok {
+		return tr.SetReadDeadline(d)
+	}
+	return os.ErrNoDeadline
+}
+
+// SetWriteDeadline sets the write deadline of the wrapped transport if it
+// supports it.
+func (t *Transport) SetWriteDeadline(d time.Time) error {
+	if tr, ok := t.Transport.(transport.Deadliner); ok {
+		return tr.SetWriteDeadline(d)
+	}
+	return os.ErrNoDeadline
+}
+
+// Ping pings the wrapped transport if it supports it (see
+// [transport.Pinger]) or returns nil otherwise.
+func (t *Transport) Ping(ctx context.Context) error {
+	if tr, ok := t.Transport.(transport.Pinger); ok {
+		return tr.Ping(ctx)
+	}
+	return nil
+}
+
+var capability = []byte(Capability)
+
+// helloReader keeps the first message read to check if it advertises the
+// capability once it is closed.
+type helloReader struct {
+	io.ReadCloser
+	buf  bytes.Buffer
+	seen func(ok bool)
+}
+
+func (r *helloReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.buf.Write(p[:n])
+	return n, err
+}
+
+func (r *helloReader) Close() error {
+	r.seen(bytes.Contains(r.buf.Bytes(), capability))
+	return r.ReadCloser.Close()
+}
+
+// helloWriter keeps the first message written to check if it advertises the
+// capability once it is closed.
+type helloWriter struct {
+	io.WriteCloser
+	buf  bytes.Buffer
+	seen func(ok bool)
+}
+
+func (w *helloWriter) Write(p []byte) (int, error) {
+	n, err := w.WriteCloser.Write(p)
+	w.buf.Write(p[:n])
+	return n, err
+}
+
+func (w *helloWriter) Close() error {
+	w.seen(bytes.Contains(w.buf.Bytes(), capability))
+	return w.WriteCloser.Close()
+}
+
+type reader struct {
+	io.ReadCloser
+	t  *Transport
+	gz *gzip.Reader
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	if r.gz == nil {
+		// reading the gzip header blocks so it is done on the first read
+		// instead of in MsgReader.
+		var err error
+		if r.t.gzr == nil {
+			r.t.gzr, err = gzip.NewReader(r.ReadCloser)
+		} else {
+			err = r.t.gzr.Reset(r.ReadCloser)
+		}
+		if err != nil {
+			return 0, err
+		}
+		r.gz = r.t.gzr
+	}
+	return r.gz.Read(p)
+}
+
+type writer struct {
+	gz *gzip.Writer
+	w  io.WriteCloser
+}
+
+func (w *writer) Write(p []byte) (int, error) { return w.gz.Write(p) }
+
+func (w *writer) Close() error {
+	err := w.gz.Close()
+	if cerr := w.w.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,121 @@
+package compress
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/nemith/netconf/transport"
+	"github.com/nemith/netconf/transport/transporttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func helloMsg(caps ...string) string {
+	var sb strings.Builder
+	sb.WriteString("<hello><capabilities>")
+	for _, c := range caps {
+		sb.WriteString("<capability>" + c + "</capability>")
+	}
+	sb.WriteString("</capabilities></hello>")
+	return sb.String()
+}
+
+func send(t *testing.T, tr transport.Transport, msg string) {
+	t.Helper()
+	w, err := tr.MsgWriter()
+	require.NoError(t, err)
+	_, err = io.WriteString(w, msg)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+}
+
+func recv(t *testing.T, tr transport.Transport) string {
+	t.Helper()
+	r, err := tr.MsgReader()
+	require.NoError(t, err)
+	b, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	// End-of-Message framing adds a newline.
+	return strings.TrimSuffix(string(b), "\n")
+}
+
+// exchange sends msg from one transport to the other returning what was
+// received.
+func exchange(t *testing.T, from, to transport.Transport, msg string) string {
+	t.Helper()
+	sent := make(chan struct{})
+	go func() {
+		defer close(sent)
+		send(t, from, msg)
+	}()
+	got := recv(t, to)
+	<-sent
+	return got
+}
+
+func TestCompress(t *testing.T) {
+	const base11 = "urn:ietf:params:netconf:base:1.1"
+	tt := []struct {
+		name       string
+		clientCaps []string
+		serverCaps []string
+		upgrade    bool
+		want       bool
+	}{
+		{"both", []string{base11, Capability}, []string{base11, Capability}, true, true},
+		{"client only", []string{base11, Capability}, []string{base11}, true, false},
+		{"server only", []string{base11}, []string{base11, Capability}, true, false},
+		{"end-of-message framing", []string{Capability}, []string{Capability}, false, false},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			rawClient, rawServer := transporttest.Pipe()
+			client, server := NewTransport(rawClient), NewTransport(rawServer, WithLevel(1))
+			defer client.Close()
+
+			assert.Equal(t, helloMsg(tc.clientCaps...), exchange(t, client, server, helloMsg(tc.clientCaps...)))
+			assert.Equal(t, helloMsg(tc.serverCaps...), exchange(t, server, client, helloMsg(tc.serverCaps...)))
+			if tc.upgrade {
+				client.Upgrade()
+				server.Upgrade()
+			}
+			assert.Equal(t, tc.want, client.Enabled())
+			assert.Equal(t, tc.want, server.Enabled())
+
+			config := "<config>" + strings.Repeat("<interface><name>ge-0/0/0</name></interface>", 1000) + "</config>"
+			for i := 0; i < 2; i++ {
+				before := rawClient.Stats().BytesWritten
+				assert.Equal(t, config, exchange(t, client, server, config))
+				written := rawClient.Stats().BytesWritten - before
+				if tc.want {
+					assert.Less(t, written, uint64(len(config)/10))
+				} else {
+					assert.Greater(t, written, uint64(len(config)))
+				}
+
+				assert.Equal(t, config, exchange(t, server, client, config))
+			}
+		})
+	}
+}
+
+func TestDebugCapture(t *testing.T) {
+	rawClient, rawServer := transporttest.Pipe()
+	client, server := NewTransport(rawClient), NewTransport(rawServer)
+	defer client.Close()
+
+	// the framed data is captured as sent on the wire.
+	var raw strings.Builder
+	client.DebugCapture(nil, &raw)
+	assert.Equal(t, helloMsg(), exchange(t, client, server, helloMsg()))
+	assert.Equal(t, helloMsg()+"\n]]>]]>", raw.String())
+
+	// message capture isn't forwarded as the messages may be compressed.
+	_, ok := any(client).(interface {
+		DebugCaptureMsgs(fn func(transport.CapturedMsg))
+	})
+	assert.False(t, ok)
+}
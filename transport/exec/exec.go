@@ -0,0 +1,197 @@
+// Package exec implements a NETCONF transport over the stdin and stdout of an
+// external command such as `ssh -s router netconf`, `docker exec` or a vendor
+// proxy.  This is useful for devices that golang.org/x/crypto/ssh can't
+// negotiate with or to reuse an existing OpenSSH configuration.
+package exec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nemith/netconf/transport"
+)
+
+// alias it to a private type so we can make it private when embedding
+type framer = transport.Framer //nolint:golint,unused
+
+// CloseTimeout is how long Close waits for the command to exit after its
+// stdin is closed before killing it.
+const CloseTimeout = 5 * time.Second
+
+// maxStderr is the amount of the command's stderr kept for error messages.
+const maxStderr = 4096
+
+// Transport implements NETCONF over the stdin and stdout of a command using
+// the framing from RFC6242.
+type Transport struct {
+	cmd    *exec.Cmd
+	stdin  *os.File
+	stdout *os.File
+	stderr *tailBuffer
+
+	exited  chan struct{}
+	waitErr error
+
+	closeOnce sync.Once
+	closeErr  error
+
+	*framer
+}
+
+// Dial starts the command and returns a transport over its stdin and stdout.
+// The command is killed if ctx is done before the transport is closed so ctx
+// should live as long as the session.
+//
+//	tr, err := exec.Dial(ctx, "ssh", "-s", "admin@router", "netconf")
+func Dial(ctx context.Context, name string, args ...string) (*Transport, error) {
+	return NewTransport(exec.CommandContext(ctx, name, args...))
+}
+
+// NewTransport starts cmd and returns a transport over its stdin and stdout.
+// cmd must not have Stdin or Stdout set.  If Stderr is not set the end of the
+// command's stderr is included in the error returned from Close.  If
+// WaitDelay is not set it defaults to one second.
+func NewTransport(cmd *exec.Cmd) (*Transport, error) {
+	if cmd.Stdin != nil || cmd.Stdout != nil {
+		return nil, errors.New("exec: Stdin or Stdout already set")
+	}
+
+	// Use os pipes rather than cmd.StdinPipe/StdoutPipe as Wait closes those
+	// and it is run in the background as soon as the command is started.
+	inR, inW, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	outR, outW, err := os.Pipe()
+	if err != nil {
+		inR.Close()
+		inW.Close()
+		return nil, err
+	}
+
+	t := &Transport{
+		cmd:    cmd,
+		stdin:  inW,
+		stdout: outR,
+		exited: make(chan struct{}),
+	}
+	cmd.Stdin = inR
+	cmd.Stdout = outW
+	if cmd.Stderr == nil {
+		t.stderr = &tailBuffer{max: maxStderr}
+		cmd.Stderr = t.stderr
+	}
+	if cmd.WaitDelay == 0 {
+		// don't wait forever for children of the command holding stderr
+		// open after the command has been killed.
+		cmd.WaitDelay = time.Second
+	}
+
+	err = cmd.Start()
+	// the child has its own copies of these.
+	inR.Close()
+	outW.Close()
+	if err != nil {
+		inW.Close()
+		outR.Close()
+		return nil, err
+	}
+
+	go func() {
+		t.waitErr = cmd.Wait()
+		close(t.exited)
+	}()
+
+	t.framer = transport.NewFramer(outR, inW)
+	return t, nil
+}
+
+// SetReadDeadline sets the deadline for reading from the command's stdout.
+func (t *Transport) SetReadDeadline(d time.Time) error {
+	return t.stdout.SetReadDeadline(d)
+}
+
+// SetWriteDeadline sets the deadline for writing to the command's stdin.
+func (t *Transport) SetWriteDeadline(d time.Time) error {
+	return t.stdin.SetWriteDeadline(d)
+}
+
+// Done returns a channel that is closed when the command exits.
+func (t *Transport) Done() <-chan struct{} { return t.exited }
+
+// Ping returns an error if the command has exited.
+func (t *Transport) Ping(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	select {
+	case <-t.exited:
+		if t.waitErr != nil {
+			return fmt.Errorf("exec: command %q exited: %w", t.cmd.Path, t.waitErr)
+		}
+		return fmt.Errorf("exec: command %q exited", t.cmd.Path)
+	default:
+		return nil
+	}
+}
+
+// Close closes the command's stdin and waits for it to exit killing it if it
+// hasn't after [CloseTimeout].  A non-zero exit status is returned as an
+// error wrapping an *exec.ExitError.
+func (t *Transport) Close() error {
+	t.closeOnce.Do(func() {
+		t.stdin.Close()
+
+		timer := time.NewTimer(CloseTimeout)
+		defer timer.Stop()
+
+		select {
+		case <-t.exited:
+		case <-timer.C:
+			_ = t.cmd.Process.Kill()
+			<-t.exited
+		}
+		t.stdout.Close()
+
+		if t.waitErr == nil {
+			return
+		}
+		if t.stderr != nil {
+			if msg := strings.TrimSpace(t.stderr.String()); msg != "" {
+				t.closeErr = fmt.Errorf("exec: command %q failed: %w: %s", t.cmd.Path, t.waitErr, msg)
+				return
+			}
+		}
+		t.closeErr = fmt.Errorf("exec: command %q failed: %w", t.cmd.Path, t.waitErr)
+	})
+	return t.closeErr
+}
+
+// tailBuffer keeps the last max bytes written to it.
+type tailBuffer struct {
+	mu  sync.Mutex
+	max int
+	buf []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf = append(b.buf, p...)
+	if over := len(b.buf) - b.max; over > 0 {
+		b.buf = append(b.buf[:0], b.buf[over:]...)
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.buf)
+}
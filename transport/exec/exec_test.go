@@ -0,0 +1,84 @@
+package exec
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func requireSh(t *testing.T) {
+	t.Helper()
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not found")
+	}
+}
+
+func TestTransportEcho(t *testing.T) {
+	requireSh(t)
+	tr, err := Dial(context.Background(), "sh", "-c", "cat")
+	require.NoError(t, err)
+
+	w, err := tr.MsgWriter()
+	require.NoError(t, err)
+	_, err = io.WriteString(w, "<hello/>")
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	r, err := tr.MsgReader()
+	require.NoError(t, err)
+	msg, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "<hello/>", strings.TrimSpace(string(msg)))
+
+	assert.NoError(t, tr.Close())
+	assert.NoError(t, tr.Close())
+}
+
+func TestTransportExitStatus(t *testing.T) {
+	requireSh(t)
+	tr, err := Dial(context.Background(), "sh", "-c", "echo 'permission denied' >&2; exit 3")
+	require.NoError(t, err)
+	<-tr.Done()
+
+	err = tr.Close()
+	var exitErr *exec.ExitError
+	require.True(t, errors.As(err, &exitErr))
+	assert.Equal(t, 3, exitErr.ExitCode())
+	assert.ErrorContains(t, err, "permission denied")
+}
+
+func TestTransportContext(t *testing.T) {
+	requireSh(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	tr, err := Dial(ctx, "sh", "-c", "sleep 10")
+	require.NoError(t, err)
+
+	cancel()
+	select {
+	case <-tr.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("command not killed when context canceled")
+	}
+	assert.Error(t, tr.Close())
+}
+
+func TestNewTransportStdinSet(t *testing.T) {
+	cmd := exec.Command("true")
+	cmd.Stdin = strings.NewReader("")
+	_, err := NewTransport(cmd)
+	assert.Error(t, err)
+}
+
+func TestTailBuffer(t *testing.T) {
+	b := &tailBuffer{max: 4}
+	_, _ = b.Write([]byte("ab"))
+	_, _ = b.Write([]byte("cdef"))
+	assert.Equal(t, "cdef", b.String())
+}
@@ -9,6 +9,10 @@ import (
 	"math"
 	"os"
 	"path/filepath"
+	"runtime/debug"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -16,6 +20,21 @@ import (
 // framing in RFC6242
 var ErrMalformedChunk = errors.New("netconf: invalid chunk")
 
+// DefaultMaxChunkSize is the largest chunk accepted by a Framer unless changed
+// with [Framer.SetMaxChunkSize].
+const DefaultMaxChunkSize = 64 << 20
+
+// ChunkTooLargeError is returned when reading a chunk larger than the maximum
+// chunk size of the Framer.
+type ChunkTooLargeError struct {
+	Size uint64
+	Max  uint32
+}
+
+func (e *ChunkTooLargeError) Error() string {
+	return fmt.Sprintf("netconf: chunk of %d bytes exceeds the maximum of %d bytes", e.Size, e.Max)
+}
+
 type frameReader interface {
 	io.ReadCloser
 	io.ByteReader
@@ -37,23 +56,108 @@ type Framer struct {
 	r io.Reader
 	w io.Writer
 
-	br *bufio.Reader
-	bw *bufio.Writer
+	br   *bufio.Reader
+	pool *BufferPool
+
+	// out is where messages are written to; w or w teed to the capture
+	// writer.
+	out io.Writer
+
+	curReader  frameReader
+	curWriter  frameWriter
+	curCapture *captureReader
+
+	mode     atomic.Int32
+	maxChunk uint32
+	lenient  bool
 
-	curReader frameReader
-	curWriter frameWriter
+	// writerStack is where the current writer was acquired when
+	// writerStacks is set.
+	writerStacks bool
+	writerStack  []byte
 
-	upgraded bool
+	stats frameStats
+
+	// capture writers set with DebugCapture.  They are applied when a new
+	// message reader or writer is created.
+	capMu         sync.Mutex
+	inCap         io.Writer
+	outCap        io.Writer
+	outCapChanged bool
+	msgCap        func(CapturedMsg)
+}
+
+// FramerOption is an optional argument to [NewFramer].
+type FramerOption interface {
+	apply(*framerConfig)
 }
 
+type framerConfig struct {
+	readSize     int
+	pool         *BufferPool
+	lenient      bool
+	writerStacks bool
+}
+
+type bufferPoolOpt struct{ p *BufferPool }
+
+func (o bufferPoolOpt) apply(cfg *framerConfig) { cfg.pool = o.p }
+
+// WithBufferPool sets the pool the write buffers are taken from.  It defaults
+// to [DefaultBufferPool].  Use a pool with larger buffers for sessions that
+// send large messages.
+func WithBufferPool(p *BufferPool) FramerOption { return bufferPoolOpt{p} }
+
+type readBufferSizeOpt int
+
+func (o readBufferSizeOpt) apply(cfg *framerConfig) { cfg.readSize = int(o) }
+
+// WithReadBufferSize sets the size of the read buffer.  The default is
+// [DefaultBufferSize].  Larger buffers (i.e 64KiB) mean far fewer reads from
+// the connection when receiving large replies such as full operational state.
+func WithReadBufferSize(n int) FramerOption { return readBufferSizeOpt(n) }
+
+type writeBufferSizeOpt int
+
+func (o writeBufferSizeOpt) apply(cfg *framerConfig) { cfg.pool = bufferPoolFor(int(o)) }
+
+// WithWriteBufferSize sets the size of the write buffers.  The default is
+// [DefaultBufferSize].  The buffers come from a pool shared by all framers
+// using the same size.  This replaces any pool given with [WithBufferPool].
+func WithWriteBufferSize(n int) FramerOption { return writeBufferSizeOpt(n) }
+
+type lenientOpt struct{}
+
+func (lenientOpt) apply(cfg *framerConfig) { cfg.lenient = true }
+
+// WithLenientFraming tolerates common bugs in the chunked framing sent by
+// devices: a missing newline before a chunk header, whitespace after the
+// end-of-chunks marker and CRLF line endings.  Framing is strict by default.
+func WithLenientFraming() FramerOption { return lenientOpt{} }
+
 // NewFramer return a new Framer to be used against the given io.Reader and io.Writer.
-func NewFramer(r io.Reader, w io.Writer) *Framer {
+func NewFramer(r io.Reader, w io.Writer, opts ...FramerOption) *Framer {
+	cfg := framerConfig{
+		readSize: DefaultBufferSize,
+	}
+	for _, opt := range opts {
+		opt.apply(&cfg)
+	}
+	if cfg.pool == nil {
+		cfg.pool = DefaultBufferPool
+	}
+
 	f := &Framer{
-		r:  r,
-		w:  w,
-		br: bufio.NewReader(r),
-		bw: bufio.NewWriter(w),
+		r:    r,
+		pool: cfg.pool,
+
+		lenient:      cfg.lenient,
+		maxChunk:     DefaultMaxChunkSize,
+		writerStacks: cfg.writerStacks,
 	}
+	f.br = bufio.NewReaderSize(&countingReader{r: r, stats: &f.stats}, cfg.readSize)
+	f.w = &countingWriter{w: w, stats: &f.stats}
+	f.out = f.w
 
 	capDir := os.Getenv("GONETCONF_FRAMED_CAPDIR")
 	if capDir != "" {
@@ -86,33 +190,90 @@ func NewFramer(r io.Reader, w io.Writer) *Framer {
 // capture any data.  Useful for displaying to a screen or capturing to a file
 // for debugging.
 //
-// This needs to be called before `MsgReader` or `MsgWriter`.
+// It is safe to call at any time (including concurrently with reading and
+// writing messages) to start, change or stop (with nil writers) capturing.
+// The change is applied at the next message boundary so that the capture
+// only contains whole messages; a message being read or written when this is
+// called is not affected.
 func (f *Framer) DebugCapture(in io.Writer, out io.Writer) {
-	// XXX: should there be a sentinel flag to indicate write/read has been done already?
-	if f.curReader != nil ||
-		f.curWriter != nil ||
-		f.bw.Buffered() > 0 ||
-		f.br.Buffered() > 0 {
-		panic("debug capture added with active reader or writer")
-	}
+	f.capMu.Lock()
+	defer f.capMu.Unlock()
+	f.inCap = in
+	f.outCap = out
+	f.outCapChanged = true
+}
 
-	if out != nil {
-		f.w = io.MultiWriter(f.w, out)
-		f.bw = bufio.NewWriter(f.w)
-	}
+// Stats returns the counters for the messages read and written since the
+// Framer was created.  It is safe to call concurrently with reading and
+// writing messages.
+func (f *Framer) Stats() Stats {
+	return f.stats.snapshot()
+}
+
+// SetMaxChunkSize sets the largest chunk that will be accepted when reading
+// with chunked framing.  Chunks advertising a larger size fail with a
+// [ChunkTooLargeError] instead of being read.  RFC6242 allows chunks up to
+// 4294967295 bytes but devices send far smaller ones so this protects against
+// a broken or malicious peer.  The default is [DefaultMaxChunkSize].  It
+// applies to messages read after it is called.
+func (f *Framer) SetMaxChunkSize(n uint32) {
+	f.maxChunk = n
+}
 
-	if in != nil {
-		f.r = io.TeeReader(f.r, in)
-		f.br = bufio.NewReader(f.r)
+// FramingMode is the framing used by a [Framer] for messages.
+type FramingMode int
+
+const (
+	// FramingEOM is the End-of-Message framing from netconf 1.0 where
+	// messages end with `]]>]]>`.
+	FramingEOM FramingMode = iota
+
+	// FramingChunked is the Chunked framing from netconf 1.1 (RFC6242
+	// section 4.2).
+	FramingChunked
+)
+
+func (m FramingMode) String() string {
+	switch m {
+	case FramingEOM:
+		return "end-of-message"
+	case FramingChunked:
+		return "chunked"
 	}
+	return fmt.Sprintf("FramingMode(%d)", int(m))
 }
 
+// states of Framer.mode.
+const (
+	stateEOM int32 = iota
+	stateChunked
+	statePinnedEOM
+)
+
 // Upgrade will cause the Framer to switch from End-of-Message framing to
 // Chunked framing.  This is usually called after netconf exchanged the hello
-// messages.
+// messages.  It does nothing after [Framer.PinEOM].
 func (t *Framer) Upgrade() {
-	// XXX: do we need to protect against race conditions (atomic/mutex?)
-	t.upgraded = true
+	t.mode.CompareAndSwap(stateEOM, stateChunked)
+}
+
+// PinEOM keeps the Framer on End-of-Message framing for the rest of the
+// session so later calls to Upgrade are ignored.  Sessions call this when
+// either side only supports base:1.0 so a transport that is upgraded anyway
+// (i.e by a wrapper) can't start sending chunks the device doesn't
+// understand.  It does nothing if the Framer has already been upgraded.
+func (t *Framer) PinEOM() {
+	t.mode.CompareAndSwap(stateEOM, statePinnedEOM)
+}
+
+// Mode returns the framing currently used for messages.  This is useful when
+// debugging devices that advertise base:1.1 but don't implement Chunked
+// framing properly.
+func (t *Framer) Mode() FramingMode {
+	if t.mode.Load() == stateChunked {
+		return FramingChunked
+	}
+	return FramingEOM
 }
 
 // MsgReader returns a new io.Reader that is good for reading exactly one netconf
@@ -122,10 +283,28 @@ func (t *Framer) Upgrade() {
 // reader then the underlying reader is advanced to the start of the next message
 // and invalidates the old reader before returning a new one.
 func (t *Framer) MsgReader() (io.ReadCloser, error) {
-	if t.upgraded {
-		t.curReader = &chunkReader{r: t.br}
+	// the previous message may not have been closed.
+	if t.curCapture != nil {
+		t.curCapture.flush()
+		t.curCapture = nil
+	}
+
+	var r bufReader = t.br
+	t.capMu.Lock()
+	if t.inCap != nil {
+		t.curCapture = &captureReader{Reader: t.br, w: t.inCap}
+		r = t.curCapture
+	}
+	msgCap := t.msgCap
+	t.capMu.Unlock()
+
+	if t.Mode() == FramingChunked {
+		t.curReader = &chunkReader{r: r, max: t.maxChunk, lenient: t.lenient, stats: &t.stats}
 	} else {
-		t.curReader = &eomReader{r: t.br}
+		t.curReader = &eomReader{r: r, stats: &t.stats}
+	}
+	if msgCap != nil {
+		t.curReader = &msgCaptureReader{frameReader: t.curReader, fn: msgCap}
 	}
 	return t.curReader, nil
 }
@@ -137,13 +316,34 @@ func (t *Framer) MsgReader() (io.ReadCloser, error) {
 // existing, unclosed,  writer will result in an error.
 func (t *Framer) MsgWriter() (io.WriteCloser, error) {
 	if t.curWriter != nil && !t.curWriter.isClosed() {
-		return nil, ErrExistingWriter
+		return nil, &ExistingWriterError{Stack: t.writerStack}
+	}
+	if t.writerStacks {
+		t.writerStack = debug.Stack()
 	}
 
-	if t.upgraded {
-		t.curWriter = &chunkWriter{w: t.bw}
+	t.capMu.Lock()
+	if t.outCapChanged {
+		if t.outCap != nil {
+			t.out = io.MultiWriter(t.w, t.outCap)
+		} else {
+			t.out = t.w
+		}
+		t.outCapChanged = false
+	}
+	msgCap := t.msgCap
+	t.capMu.Unlock()
+
+	// the buffer is only held while writing the message and is returned to
+	// the pool once the writer is closed.
+	bw := t.pool.getWriter(t.out)
+	if t.Mode() == FramingChunked {
+		t.curWriter = &chunkWriter{w: bw, pool: t.pool, stats: &t.stats}
 	} else {
-		t.curWriter = &eomWriter{w: t.bw}
+		t.curWriter = &eomWriter{w: bw, pool: t.pool, stats: &t.stats}
+	}
+	if msgCap != nil {
+		t.curWriter = &msgCaptureWriter{frameWriter: t.curWriter, fn: msgCap}
 	}
 	return t.curWriter, nil
 }
@@ -153,12 +353,114 @@ var endOfChunks = []byte("\n##\n")
 // Defined in https://www.rfc-editor.org/rfc/rfc6242#section-4.2
 const maxChunk = math.MaxUint32
 
+// bufReader is the subset of *bufio.Reader used by the frame readers.
+type bufReader interface {
+	io.Reader
+	io.ByteReader
+	Peek(n int) ([]byte, error)
+	Discard(n int) (int, error)
+	Buffered() int
+}
+
+// captureReader copies all bytes consumed from a buffered reader to w.
+// Capturing after the buffering (instead of teeing the underlying reader)
+// means bytes read ahead for the next message are not captured until that
+// message is read.
+//
+// Bytes read one at a time (i.e the framing and End-of-Message data) are
+// collected and written in batches rather than calling w for every byte.
+type captureReader struct {
+	*bufio.Reader
+	w   io.Writer
+	buf []byte
+}
+
+// captureBatch is the most bytes read one at a time that are held before
+// being written to the capture writer.
+const captureBatch = 512
+
+func (r *captureReader) Read(p []byte) (int, error) {
+	r.flush()
+	n, err := r.Reader.Read(p)
+	_, _ = r.w.Write(p[:n])
+	return n, err
+}
+
+func (r *captureReader) ReadByte() (byte, error) {
+	b, err := r.Reader.ReadByte()
+	if err == nil {
+		r.buf = append(r.buf, b)
+	}
+	// write what has been read before waiting on the next read so a live
+	// capture doesn't lag behind.
+	if len(r.buf) >= captureBatch || r.Reader.Buffered() == 0 {
+		r.flush()
+	}
+	return b, err
+}
+
+func (r *captureReader) Discard(n int) (int, error) {
+	r.flush()
+	var discarded int
+	for discarded < n {
+		p, err := r.Reader.Peek(min(n-discarded, r.Reader.Size()))
+		_, _ = r.w.Write(p)
+		d, _ := r.Reader.Discard(len(p))
+		discarded += d
+		if err != nil {
+			return discarded, err
+		}
+	}
+	return discarded, nil
+}
+
+// flush writes the bytes held back to w.
+func (r *captureReader) flush() {
+	if len(r.buf) > 0 {
+		_, _ = r.w.Write(r.buf)
+		r.buf = r.buf[:0]
+	}
+}
+
+// flushCapture writes the bytes held back by r if it is a captureReader.
+func flushCapture(r bufReader) {
+	if cr, ok := r.(*captureReader); ok {
+		cr.flush()
+	}
+}
+
 type chunkReader struct {
-	r         *bufio.Reader
+	r         bufReader
+	max       uint32
+	lenient   bool
+	stats     *frameStats
 	chunkLeft uint32
+
+	// set once the end-of-chunks marker has been read so that the reader
+	// doesn't advance into the next message.
+	eof bool
 }
 
+// readHeader reads the next chunk header updating the stats.
 func (r *chunkReader) readHeader() error {
+	err := r.nextHeader()
+	var tooLarge *ChunkTooLargeError
+	switch {
+	case err == nil:
+		r.stats.chunkRead()
+	case err == io.EOF:
+		r.stats.msgRead()
+	case err == ErrMalformedChunk, errors.As(err, &tooLarge):
+		r.stats.framingError()
+	}
+	return err
+}
+
+func (r *chunkReader) nextHeader() error {
+	if r.lenient {
+		return r.readHeaderLenient()
+	}
+
 	peeked, err := r.r.Peek(4)
 	switch err {
 	case nil:
@@ -187,10 +489,16 @@ func (r *chunkReader) readHeader() error {
 		// not strictly needed but it is the responsibility of this function to
 		// update chunkLeft.
 		r.chunkLeft = 0
+		r.eof = true
 		return io.EOF
 	}
 
-	var n uint32
+	return r.readSize()
+}
+
+// readSize reads the chunk size and the newline ending the chunk header.
+func (r *chunkReader) readSize() error {
+	var n uint64
 	for {
 		c, err := r.r.ReadByte()
 		if err != nil {
@@ -200,26 +508,106 @@ func (r *chunkReader) readHeader() error {
 		if c == '\n' {
 			break
 		}
+		if r.lenient && c == '\r' {
+			if next, err := r.r.Peek(1); err == nil && next[0] == '\n' {
+				continue
+			}
+		}
 		if c < '0' || c > '9' {
 			return ErrMalformedChunk
 		}
-		n = n*10 + uint32(c) - '0'
+		n = n*10 + uint64(c) - '0'
+		// don't let a long run of digits overflow.
+		if n > maxChunk {
+			return ErrMalformedChunk
+		}
 	}
 
-	if n < 1 || n > maxChunk {
+	if n < 1 {
 		return ErrMalformedChunk
 	}
 
-	r.chunkLeft = n
+	max := r.max
+	if max == 0 {
+		max = maxChunk
+	}
+	if n > uint64(max) {
+		return &ChunkTooLargeError{Size: n, Max: max}
+	}
+
+	r.chunkLeft = uint32(n)
 	return nil
 }
 
+// readHeaderLenient reads a chunk header or the end-of-chunks marker allowing
+// for any whitespace (including CRLF line endings or a missing newline) before
+// the `#` and whitespace after the `##`.
+func (r *chunkReader) readHeaderLenient() error {
+	for {
+		c, err := r.r.ReadByte()
+		if err != nil {
+			if err == io.EOF {
+				return io.ErrUnexpectedEOF
+			}
+			return err
+		}
+		if c == '#' {
+			break
+		}
+		if !isSpace(c) {
+			return ErrMalformedChunk
+		}
+	}
+
+	peeked, err := r.r.Peek(1)
+	if err != nil {
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	if peeked[0] != '#' {
+		return r.readSize()
+	}
+	if _, err := r.r.Discard(1); err != nil {
+		return err
+	}
+
+	// consume the whitespace up to and including the newline after the
+	// marker but only what has already been received so that the end of the
+	// message is never held up waiting for the next one.  Anything left is
+	// skipped before the next header.
+	for r.r.Buffered() > 0 {
+		next, _ := r.r.Peek(1)
+		if !isSpace(next[0]) {
+			break
+		}
+		_, _ = r.r.Discard(1)
+		if next[0] == '\n' {
+			break
+		}
+	}
+
+	r.chunkLeft = 0
+	r.eof = true
+	return io.EOF
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
+
 func (r *chunkReader) Read(p []byte) (int, error) {
 	if r.r == nil {
 		return 0, ErrInvalidIO
 	}
+	if r.eof {
+		return 0, io.EOF
+	}
 	// make sure we can't try to read more than the max chunk
-	p = p[:maxChunk]
+	if len(p) > maxChunk {
+		p = p[:maxChunk]
+	}
 
 	// done with existing chunk so grab the next one
 	if r.chunkLeft <= 0 {
@@ -239,10 +627,65 @@ func (r *chunkReader) Read(p []byte) (int, error) {
 	return n, err
 }
 
+// WriteTo writes the rest of the message to w straight from the read buffer
+// without copying it through an intermediate buffer.  It implements
+// io.WriterTo so that io.Copy uses it.
+func (r *chunkReader) WriteTo(w io.Writer) (int64, error) {
+	if r.r == nil {
+		return 0, ErrInvalidIO
+	}
+
+	var written int64
+	for !r.eof {
+		if r.chunkLeft <= 0 {
+			err := r.readHeader()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return written, err
+			}
+		}
+
+		buf, err := peekBuffered(r.r, int(r.chunkLeft))
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return written, err
+		}
+
+		n, err := w.Write(buf)
+		written += int64(n)
+		if _, derr := r.r.Discard(n); derr != nil && err == nil {
+			err = derr
+		}
+		r.chunkLeft -= uint32(n)
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// peekBuffered returns up to max bytes that are already buffered in r, only
+// reading from the underlying reader if nothing is buffered.
+func peekBuffered(r bufReader, max int) ([]byte, error) {
+	if r.Buffered() == 0 {
+		if _, err := r.Peek(1); err != nil {
+			return nil, err
+		}
+	}
+	return r.Peek(min(max, r.Buffered()))
+}
+
 func (r *chunkReader) ReadByte() (byte, error) {
 	if r.r == nil {
 		return 0, ErrInvalidIO
 	}
+	if r.eof {
+		return 0, io.EOF
+	}
 
 	// done with existing chunck so grab the next one
 	if r.chunkLeft <= 0 {
@@ -264,6 +707,11 @@ func (r *chunkReader) ReadByte() (byte, error) {
 func (r *chunkReader) Close() error {
 	// poison the reader so that it can no longer be used
 	defer func() { r.r = nil }()
+	defer flushCapture(r.r)
+
+	if r.eof {
+		return nil
+	}
 
 	// read all remaining chunks until we get to the end of the frame.
 	for {
@@ -290,28 +738,90 @@ func (r *chunkReader) Close() error {
 }
 
 type chunkWriter struct {
-	w *bufio.Writer
+	w     *bufio.Writer
+	pool  *BufferPool
+	stats *frameStats
+}
+
+// copyBufSize is the size of the buffer used by chunkWriter.ReadFrom and so
+// the largest chunk it sends.
+const copyBufSize = 32 * 1024
+
+// ReadFrom reads from src until EOF and writes the data as chunks.  Unlike
+// io.Copy calling Write this fills the buffer before writing a chunk so that
+// short reads don't result in many small chunks.
+func (w *chunkWriter) ReadFrom(src io.Reader) (int64, error) {
+	if w.w == nil {
+		return 0, ErrInvalidIO
+	}
+
+	bufp := copyBufPool.Get().(*[]byte)
+	defer copyBufPool.Put(bufp)
+	buf := *bufp
+
+	var read int64
+	for {
+		n, err := io.ReadFull(src, buf)
+		if n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				return read, werr
+			}
+			read += int64(n)
+		}
+		switch err {
+		case nil:
+		case io.EOF, io.ErrUnexpectedEOF:
+			return read, nil
+		default:
+			return read, err
+		}
+	}
 }
 
 func (w *chunkWriter) Write(p []byte) (int, error) {
 	if w.w == nil {
 		return 0, ErrInvalidIO
 	}
+	// chunks can't be empty.
+	if len(p) == 0 {
+		return 0, nil
+	}
 
-	if _, err := fmt.Fprintf(w.w, "\n#%d\n", len(p)); err != nil {
+	// build the header on the stack instead of using fmt to not allocate
+	// for every chunk.
+	var hdr [16]byte
+	h := append(hdr[:0], '\n', '#')
+	h = strconv.AppendUint(h, uint64(len(p)), 10)
+	h = append(h, '\n')
+	if _, err := w.w.Write(h); err != nil {
 		return 0, err
 	}
+	w.stats.chunkWritten()
 
 	return w.w.Write(p)
 }
 
 func (w *chunkWriter) Close() error {
+	if w.w == nil {
+		return ErrInvalidIO
+	}
 	// poison the writer to prevent writes after close
-	defer func() { w.w = nil }()
+	defer w.release()
 	if _, err := w.w.Write(endOfChunks); err != nil {
 		return err
 	}
-	return w.w.Flush()
+	if err := w.w.Flush(); err != nil {
+		return err
+	}
+	w.stats.msgWritten()
+	return nil
+}
+
+func (w *chunkWriter) release() {
+	if w.pool != nil {
+		w.pool.putWriter(w.w)
+	}
+	w.w = nil
 }
 
 func (w *chunkWriter) isClosed() bool { return w.w == nil }
@@ -319,7 +829,9 @@ func (w *chunkWriter) isClosed() bool { return w.w == nil }
 var endOfMsg = []byte("]]>]]>")
 
 type eomReader struct {
-	r *bufio.Reader
+	r     bufReader
+	stats *frameStats
+	eof   bool
 }
 
 func (r *eomReader) Read(p []byte) (int, error) {
@@ -327,6 +839,11 @@ func (r *eomReader) Read(p []byte) (int, error) {
 	// mainly just called ReadByte() and this probably won't ever be
 	// used.
 	for i := 0; i < len(p); i++ {
+		// return what has been received so far instead of blocking until p
+		// is full.
+		if i > 0 && r.r.Buffered() == 0 {
+			return i, nil
+		}
 		b, err := r.ReadByte()
 		if err != nil {
 			return i, err
@@ -336,10 +853,63 @@ func (r *eomReader) Read(p []byte) (int, error) {
 	return len(p), nil
 }
 
+// WriteTo writes the rest of the message to w straight from the read buffer,
+// only looking at the data byte by byte around possible end-of-message
+// markers.  It implements io.WriterTo so that io.Copy uses it.
+func (r *eomReader) WriteTo(w io.Writer) (int64, error) {
+	if r.r == nil {
+		return 0, ErrInvalidIO
+	}
+
+	var written int64
+	for !r.eof {
+		buf, err := peekBuffered(r.r, math.MaxInt)
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return written, err
+		}
+
+		// everything before a possible marker can be written as is.
+		if i := bytes.IndexByte(buf, endOfMsg[0]); i != 0 {
+			if i > 0 {
+				buf = buf[:i]
+			}
+			n, err := w.Write(buf)
+			written += int64(n)
+			if _, derr := r.r.Discard(n); derr != nil && err == nil {
+				err = derr
+			}
+			if err != nil {
+				return written, err
+			}
+			continue
+		}
+
+		b, err := r.ReadByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return written, err
+		}
+		n, err := w.Write([]byte{b})
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
 func (r *eomReader) ReadByte() (byte, error) {
 	if r.r == nil {
 		return 0, ErrInvalidIO
 	}
+	if r.eof {
+		return 0, io.EOF
+	}
 
 	b, err := r.r.ReadByte()
 	if err != nil {
@@ -365,6 +935,8 @@ func (r *eomReader) ReadByte() (byte, error) {
 				return 0, err
 			}
 
+			r.eof = true
+			r.stats.msgRead()
 			return 0, io.EOF
 		}
 	}
@@ -377,6 +949,7 @@ func (r *eomReader) ReadByte() (byte, error) {
 func (r *eomReader) Close() error {
 	// poison the reader so that it can no longer be used
 	defer func() { r.r = nil }()
+	defer flushCapture(r.r)
 
 	var err error
 	for err == nil {
@@ -389,7 +962,9 @@ func (r *eomReader) Close() error {
 }
 
 type eomWriter struct {
-	w *bufio.Writer
+	w     *bufio.Writer
+	pool  *BufferPool
+	stats *frameStats
 }
 
 func (w *eomWriter) Write(p []byte) (int, error) {
@@ -399,9 +974,20 @@ func (w *eomWriter) Write(p []byte) (int, error) {
 	return w.w.Write(p)
 }
 
+// ReadFrom reads from src until EOF directly into the write buffer.
+func (w *eomWriter) ReadFrom(src io.Reader) (int64, error) {
+	if w.w == nil {
+		return 0, ErrInvalidIO
+	}
+	return w.w.ReadFrom(src)
+}
+
 func (w *eomWriter) Close() error {
+	if w.w == nil {
+		return ErrInvalidIO
+	}
 	// poison the writer to prevent writes after close
-	defer func() { w.w = nil }()
+	defer w.release()
 
 	if err := w.w.WriteByte('\n'); err != nil {
 		return err
@@ -411,7 +997,18 @@ func (w *eomWriter) Close() error {
 		return err
 	}
 
-	return w.w.Flush()
+	if err := w.w.Flush(); err != nil {
+		return err
+	}
+	w.stats.msgWritten()
+	return nil
+}
+
+func (w *eomWriter) release() {
+	if w.pool != nil {
+		w.pool.putWriter(w.w)
+	}
+	w.w = nil
 }
 
 func (w *eomWriter) isClosed() bool { return w.w == nil }
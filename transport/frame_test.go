@@ -4,9 +4,12 @@ import (
 	"bufio"
 	"bytes"
 	"io"
+	"strings"
 	"testing"
+	"testing/iotest"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 var (
@@ -125,9 +128,96 @@ func TestChunkReaderRead(t *testing.T) {
 	}
 }
 
+func TestChunkReaderShortRead(t *testing.T) {
+	for _, tc := range chunkedTests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := &chunkReader{
+				r: bufio.NewReader(bytes.NewReader(tc.input)),
+			}
+
+			// reads smaller than a chunk only fill the given buffer.
+			got, err := io.ReadAll(iotest.OneByteReader(r))
+			assert.Equal(t, tc.err, err)
+			assert.Equal(t, tc.want, got)
+
+			r.Close()
+		})
+	}
+}
+
+func TestChunkReaderWriteTo(t *testing.T) {
+	for _, tc := range chunkedTests {
+		t.Run(tc.name, func(t *testing.T) {
+			// read a byte at a time so that chunks span many reads.
+			r := &chunkReader{
+				r: bufio.NewReader(iotest.OneByteReader(bytes.NewReader(tc.input))),
+			}
+
+			var got bytes.Buffer
+			n, err := r.WriteTo(onlyWriter{&got})
+			assert.Equal(t, tc.err, err)
+			assert.Equal(t, string(tc.want), got.String())
+			assert.EqualValues(t, got.Len(), n)
+			r.Close()
+		})
+	}
+}
+
+func TestChunkReaderLenient(t *testing.T) {
+	tt := []struct {
+		name  string
+		input string
+		want  []string
+		err   error
+	}{
+		{"strict", "\n#3\nfoo\n##\n\n#3\nbar\n##\n", []string{"foo", "bar"}, nil},
+		{"missing newline", "#3\nfoo#3\nbar\n##\n#3\nbaz##\n", []string{"foobar", "baz"}, nil},
+		{"whitespace after end", "\n#3\nfoo\n##  \t\n\n#3\nbar\n## \n", []string{"foo", "bar"}, nil},
+		{"crlf", "\r\n#3\r\nfoo\r\n##\r\n\r\n#3\r\nbar\r\n##\r\n", []string{"foo", "bar"}, nil},
+		{"garbage before header", "\nx#3\nfoo\n##\n", nil, ErrMalformedChunk},
+		{"cr in size", "\n#3\r3\nfoo\n##\n", nil, ErrMalformedChunk},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			f := NewFramer(strings.NewReader(tc.input), io.Discard, WithLenientFraming())
+			f.Upgrade()
+
+			for _, want := range tc.want {
+				r, err := f.MsgReader()
+				require.NoError(t, err)
+				got, err := io.ReadAll(r)
+				require.NoError(t, err)
+				assert.Equal(t, want, string(got))
+				require.NoError(t, r.Close())
+			}
+
+			if tc.err != nil {
+				r, err := f.MsgReader()
+				require.NoError(t, err)
+				_, err = io.ReadAll(r)
+				assert.Equal(t, tc.err, err)
+			}
+		})
+	}
+}
+
+func TestChunkReaderStrict(t *testing.T) {
+	// the lenient inputs are rejected by default.
+	for _, input := range []string{"#3\nfoo\n##\n", "\r\n#3\r\nfoo\n##\n"} {
+		f := NewFramer(strings.NewReader(input), io.Discard)
+		f.Upgrade()
+
+		r, err := f.MsgReader()
+		require.NoError(t, err)
+		_, err = io.ReadAll(r)
+		assert.Error(t, err)
+	}
+}
+
 func TestChunkWriter(t *testing.T) {
 	buf := bytes.Buffer{}
-	w := &chunkWriter{bufio.NewWriter(&buf)}
+	w := &chunkWriter{w: bufio.NewWriter(&buf)}
 
 	n, err := w.Write([]byte("foo"))
 	assert.NoError(t, err)
@@ -137,6 +227,11 @@ func TestChunkWriter(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, 4, n)
 
+	// empty writes don't create an (invalid) empty chunk.
+	n, err = w.Write(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+
 	err = w.Close()
 	assert.NoError(t, err)
 
@@ -144,6 +239,30 @@ func TestChunkWriter(t *testing.T) {
 	assert.Equal(t, want, buf.Bytes())
 }
 
+func TestChunkWriterReadFrom(t *testing.T) {
+	buf := bytes.Buffer{}
+	w := &chunkWriter{w: bufio.NewWriter(&buf)}
+
+	// short reads from the source are combined into a single chunk.
+	n, err := w.ReadFrom(iotest.OneByteReader(strings.NewReader("foobar")))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 6, n)
+	assert.NoError(t, w.Close())
+	assert.Equal(t, "\n#6\nfoobar\n##\n", buf.String())
+
+	buf.Reset()
+	w = &chunkWriter{w: bufio.NewWriter(&buf)}
+	n, err = io.Copy(w, strings.NewReader(strings.Repeat("x", copyBufSize+1)))
+	assert.NoError(t, err)
+	assert.EqualValues(t, copyBufSize+1, n)
+	assert.NoError(t, w.Close())
+
+	r := &chunkReader{r: bufio.NewReader(&buf)}
+	got, err := io.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Len(t, got, copyBufSize+1)
+}
+
 func BenchmarkChunkedReadByte(b *testing.B) {
 	src := bytes.NewReader(rfcChunkedRPC)
 	readers := []struct {
@@ -247,7 +366,7 @@ func TestEOMReadByte(t *testing.T) {
 	for _, tc := range framedTests {
 		t.Run(tc.name, func(t *testing.T) {
 			r := &eomReader{
-				bufio.NewReader(bytes.NewReader(tc.input)),
+				r: bufio.NewReader(bytes.NewReader(tc.input)),
 			}
 
 			buf := make([]byte, 8192)
@@ -293,6 +412,37 @@ func TestEOMRead(t *testing.T) {
 	}
 }
 
+func TestEOMWriteTo(t *testing.T) {
+	for _, tc := range framedTests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := &eomReader{
+				r: bufio.NewReaderSize(bytes.NewReader(tc.input), 16),
+			}
+
+			var got bytes.Buffer
+			n, err := r.WriteTo(onlyWriter{&got})
+			assert.Equal(t, tc.err, err)
+			assert.Equal(t, string(tc.want), got.String())
+			assert.EqualValues(t, got.Len(), n)
+			r.Close()
+		})
+	}
+}
+
+func TestEOMReadPartial(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	go func() { _, _ = io.WriteString(pw, "<rpc-reply>") }()
+
+	// the rest of the message hasn't arrived yet so read returns what has
+	// been received instead of blocking until p is full.
+	r := &eomReader{r: bufio.NewReader(pr)}
+	p := make([]byte, 100)
+	n, err := r.Read(p)
+	assert.NoError(t, err)
+	assert.Equal(t, "<rpc-reply>", string(p[:n]))
+}
+
 func TestEOMWriter(t *testing.T) {
 	buf := bytes.Buffer{}
 	w := &eomWriter{w: bufio.NewWriter(&buf)}
@@ -372,3 +522,247 @@ func BenchmarkEOMRead(b *testing.B) {
 		})
 	}
 }
+
+func TestEOMWriterReadFrom(t *testing.T) {
+	buf := bytes.Buffer{}
+	w := &eomWriter{w: bufio.NewWriter(&buf)}
+
+	n, err := io.Copy(w, onlyReader{strings.NewReader("foo")})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, n)
+	assert.NoError(t, w.Close())
+	assert.Equal(t, "foo\n]]>]]>", buf.String())
+
+	_, err = w.ReadFrom(strings.NewReader("bar"))
+	assert.ErrorIs(t, err, ErrInvalidIO)
+}
+
+func TestCloseAfterEOF(t *testing.T) {
+	tt := []struct {
+		name     string
+		input    string
+		upgraded bool
+	}{
+		{"eom", "foo]]>]]>bar]]>]]>", false},
+		{"chunked", "\n#3\nfoo\n##\n\n#3\nbar\n##\n", true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			f := NewFramer(bytes.NewReader([]byte(tc.input)), io.Discard)
+			if tc.upgraded {
+				f.Upgrade()
+			}
+
+			for _, want := range []string{"foo", "bar"} {
+				r, err := f.MsgReader()
+				assert.NoError(t, err)
+
+				got, err := io.ReadAll(r)
+				assert.NoError(t, err)
+				assert.Equal(t, want, string(got))
+
+				// reading or closing after EOF must not advance into the next
+				// message.
+				_, err = r.Read(make([]byte, 1))
+				assert.Equal(t, io.EOF, err)
+				assert.NoError(t, r.Close())
+			}
+		})
+	}
+}
+
+func TestMaxChunkSize(t *testing.T) {
+	input := "\n#100\n" + strings.Repeat("x", 100) + "\n##\n"
+
+	f := NewFramer(strings.NewReader(input+input), io.Discard)
+	f.Upgrade()
+	f.SetMaxChunkSize(10)
+
+	r, err := f.MsgReader()
+	require.NoError(t, err)
+	_, err = io.ReadAll(r)
+	var chunkErr *ChunkTooLargeError
+	require.ErrorAs(t, err, &chunkErr)
+	assert.EqualValues(t, 100, chunkErr.Size)
+	assert.EqualValues(t, 10, chunkErr.Max)
+
+	f = NewFramer(strings.NewReader(input), io.Discard)
+	f.Upgrade()
+	f.SetMaxChunkSize(100)
+
+	r, err = f.MsgReader()
+	require.NoError(t, err)
+	got, err := io.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Len(t, got, 100)
+}
+
+func TestMaxChunkSizeDefault(t *testing.T) {
+	f := NewFramer(strings.NewReader("\n#67108865\n"), io.Discard)
+	f.Upgrade()
+
+	r, err := f.MsgReader()
+	require.NoError(t, err)
+	_, err = io.ReadAll(r)
+	assert.Equal(t, &ChunkTooLargeError{Size: DefaultMaxChunkSize + 1, Max: DefaultMaxChunkSize}, err)
+
+	// digits past what fits in a chunk size are malformed rather than
+	// overflowing.
+	f = NewFramer(strings.NewReader("\n#"+strings.Repeat("9", 30)+"\n"), io.Discard)
+	f.Upgrade()
+
+	r, err = f.MsgReader()
+	require.NoError(t, err)
+	_, err = io.ReadAll(r)
+	assert.Equal(t, ErrMalformedChunk, err)
+}
+
+func TestDebugCaptureToggle(t *testing.T) {
+	tt := []struct {
+		name     string
+		input    string
+		upgraded bool
+		wantIn   string
+		wantOut  string
+	}{
+		{"eom", "one]]>]]>two]]>]]>three]]>]]>", false, "two]]>]]>", "B\n]]>]]>"},
+		{"chunked", "\n#3\none\n##\n\n#3\ntwo\n##\n\n#5\nthree\n##\n", true, "\n#3\ntwo\n##\n", "\n#1\nB\n##\n"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			f := NewFramer(bytes.NewReader([]byte(tc.input)), &out)
+			if tc.upgraded {
+				f.Upgrade()
+			}
+
+			var inCap, outCap bytes.Buffer
+
+			// enabling in the middle of a message starts with the next one.
+			r, err := f.MsgReader()
+			assert.NoError(t, err)
+			_, err = r.Read(make([]byte, 1))
+			assert.NoError(t, err)
+			f.DebugCapture(&inCap, &outCap)
+			assert.NoError(t, r.Close())
+
+			w, err := f.MsgWriter()
+			assert.NoError(t, err)
+			_, err = w.Write([]byte("B"))
+			assert.NoError(t, err)
+			assert.NoError(t, w.Close())
+
+			r, err = f.MsgReader()
+			assert.NoError(t, err)
+			got, err := io.ReadAll(r)
+			assert.NoError(t, err)
+			assert.Equal(t, "two", string(got))
+			assert.NoError(t, r.Close())
+
+			// disable capture
+			f.DebugCapture(nil, nil)
+
+			r, err = f.MsgReader()
+			assert.NoError(t, err)
+			assert.NoError(t, r.Close())
+
+			w, err = f.MsgWriter()
+			assert.NoError(t, err)
+			_, err = w.Write([]byte("C"))
+			assert.NoError(t, err)
+			assert.NoError(t, w.Close())
+
+			assert.Equal(t, tc.wantIn, inCap.String())
+			assert.Equal(t, tc.wantOut, outCap.String())
+			assert.Contains(t, out.String(), "C")
+		})
+	}
+}
+
+func TestDebugCaptureDiscard(t *testing.T) {
+	input := "\n#5\nhello\n#6\n world\n##\n"
+	f := NewFramer(bytes.NewReader([]byte(input)), io.Discard)
+	f.Upgrade()
+
+	var inCap bytes.Buffer
+	f.DebugCapture(&inCap, nil)
+
+	// closing without reading discards the message which must still be
+	// captured.
+	r, err := f.MsgReader()
+	assert.NoError(t, err)
+	assert.NoError(t, r.Close())
+	assert.Equal(t, input, inCap.String())
+}
+
+// writeCounter counts the writes to it.
+type writeCounter struct {
+	bytes.Buffer
+	writes int
+}
+
+func (w *writeCounter) Write(p []byte) (int, error) {
+	w.writes++
+	return w.Buffer.Write(p)
+}
+
+func TestDebugCaptureBatches(t *testing.T) {
+	msg := strings.Repeat("x", 2000)
+	input := msg + "]]>]]>" + "next]]>]]>"
+	f := NewFramer(bytes.NewReader([]byte(input)), io.Discard)
+
+	var inCap writeCounter
+	f.DebugCapture(&inCap, nil)
+
+	// End-of-Message data is read a byte at a time.
+	r, err := f.MsgReader()
+	require.NoError(t, err)
+	br := r.(io.ByteReader)
+	for range msg {
+		_, err := br.ReadByte()
+		require.NoError(t, err)
+	}
+	require.NoError(t, r.Close())
+	assert.Equal(t, msg+"]]>]]>", inCap.String())
+	assert.Less(t, inCap.writes, 10)
+
+	// bytes held back are written when the next message is read even
+	// without closing the last one.
+	r, err = f.MsgReader()
+	require.NoError(t, err)
+	_, err = r.(io.ByteReader).ReadByte()
+	require.NoError(t, err)
+	_, err = f.MsgReader()
+	require.NoError(t, err)
+	assert.Equal(t, msg+"]]>]]>n", inCap.String())
+}
+
+func TestFramerMode(t *testing.T) {
+	var buf bytes.Buffer
+	f := NewFramer(&buf, &buf)
+	assert.Equal(t, FramingEOM, f.Mode())
+	f.Upgrade()
+	assert.Equal(t, FramingChunked, f.Mode())
+	assert.Equal(t, "chunked", f.Mode().String())
+
+	// pinning after the upgrade does nothing.
+	f.PinEOM()
+	assert.Equal(t, FramingChunked, f.Mode())
+}
+
+func TestFramerPinEOM(t *testing.T) {
+	var buf bytes.Buffer
+	f := NewFramer(&buf, &buf)
+	f.PinEOM()
+	f.Upgrade()
+	assert.Equal(t, FramingEOM, f.Mode())
+
+	w, err := f.MsgWriter()
+	require.NoError(t, err)
+	_, err = io.WriteString(w, "<ok/>")
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	assert.Equal(t, "<ok/>\n]]>]]>", buf.String())
+}
@@ -0,0 +1,111 @@
+package transport
+
+import (
+	"bytes"
+	"io"
+	"time"
+)
+
+// CapturedMsg is a message read or written by a Framer given to the function
+// set with [Framer.DebugCaptureMsgs].
+type CapturedMsg struct {
+	// Time is when the message was completely read or written.
+	Time time.Time
+
+	// Sent is true for messages written to the remote end and false for
+	// messages read from it.
+	Sent bool
+
+	// Data is the message without any framing.
+	Data []byte
+}
+
+// DebugCaptureMsgs calls fn with every whole message read or written.  Unlike
+// [Framer.DebugCapture] which copies the raw framed stream this records the
+// direction, time and boundaries of each message (i.e to write them with
+// capture.TranscriptWriter or print them).  Messages that fail to be read or
+// written are not passed to fn.
+//
+// fn is called from the goroutine reading or writing the message so it should
+// not block.  As with DebugCapture it is safe to call at any time and the
+// change applies from the next message.  A nil fn stops capturing.
+func (f *Framer) DebugCaptureMsgs(fn func(CapturedMsg)) {
+	f.capMu.Lock()
+	defer f.capMu.Unlock()
+	f.msgCap = fn
+}
+
+// msgCaptureReader copies the unframed message read through it and passes it
+// to fn once the end of the message is reached.
+type msgCaptureReader struct {
+	frameReader
+	fn   func(CapturedMsg)
+	buf  bytes.Buffer
+	done bool
+}
+
+func (r *msgCaptureReader) emit() {
+	if !r.done {
+		r.done = true
+		r.fn(CapturedMsg{Time: time.Now(), Data: r.buf.Bytes()})
+	}
+}
+
+func (r *msgCaptureReader) Read(p []byte) (int, error) {
+	n, err := r.frameReader.Read(p)
+	r.buf.Write(p[:n])
+	if err == io.EOF {
+		r.emit()
+	}
+	return n, err
+}
+
+func (r *msgCaptureReader) ReadByte() (byte, error) {
+	b, err := r.frameReader.ReadByte()
+	switch err {
+	case nil:
+		r.buf.WriteByte(b)
+	case io.EOF:
+		r.emit()
+	}
+	return b, err
+}
+
+func (r *msgCaptureReader) WriteTo(w io.Writer) (int64, error) {
+	n, err := io.Copy(io.MultiWriter(w, &r.buf), r.frameReader)
+	if err == nil {
+		r.emit()
+	}
+	return n, err
+}
+
+// Close reads the rest of the message so that the whole message is captured
+// even if it wasn't read completely.
+func (r *msgCaptureReader) Close() error {
+	if !r.done {
+		_, _ = r.WriteTo(io.Discard)
+	}
+	return r.frameReader.Close()
+}
+
+// msgCaptureWriter copies the unframed message written through it and passes
+// it to fn once it has been sent.
+type msgCaptureWriter struct {
+	frameWriter
+	fn  func(CapturedMsg)
+	buf bytes.Buffer
+}
+
+func (w *msgCaptureWriter) Write(p []byte) (int, error) {
+	n, err := w.frameWriter.Write(p)
+	w.buf.Write(p[:n])
+	return n, err
+}
+
+func (w *msgCaptureWriter) Close() error {
+	if err := w.frameWriter.Close(); err != nil {
+		return err
+	}
+	w.fn(CapturedMsg{Time: time.Now(), Sent: true, Data: w.buf.Bytes()})
+	return nil
+}
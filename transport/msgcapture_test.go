@@ -0,0 +1,57 @@
+package transport
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDebugCaptureMsgs(t *testing.T) {
+	var msgs []CapturedMsg
+	capture := func(m CapturedMsg) { msgs = append(msgs, m) }
+
+	var out bytes.Buffer
+	f := NewFramer(strings.NewReader("<hello/>]]>]]>\n#5\n<foo/\n#1\n>\n##\n\n#6\n<bar/>\n##\n"), &out)
+	f.DebugCaptureMsgs(capture)
+
+	r, err := f.MsgReader()
+	require.NoError(t, err)
+	_, err = io.ReadAll(r)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+
+	w, err := f.MsgWriter()
+	require.NoError(t, err)
+	_, err = io.WriteString(w, "<hello/>")
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	f.Upgrade()
+
+	// only part of the message is read but the whole message is captured.
+	r, err = f.MsgReader()
+	require.NoError(t, err)
+	_, err = r.(io.ByteReader).ReadByte()
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+
+	f.DebugCaptureMsgs(nil)
+	r, err = f.MsgReader()
+	require.NoError(t, err)
+	_, err = io.ReadAll(r)
+	require.NoError(t, err)
+
+	require.Len(t, msgs, 3)
+	assert.Equal(t, "<hello/>", string(msgs[0].Data))
+	assert.False(t, msgs[0].Sent)
+	assert.Equal(t, "<hello/>", string(msgs[1].Data))
+	assert.True(t, msgs[1].Sent)
+	assert.Equal(t, "<foo/>", string(msgs[2].Data))
+	for _, m := range msgs {
+		assert.False(t, m.Time.IsZero())
+	}
+}
@@ -0,0 +1,75 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"time"
+)
+
+// PipeTransport is a Transport over any stream (a websocket tunnel, a gRPC
+// stream, a serial console, etc) using the framing defined in RFC6242.
+type PipeTransport struct {
+	rwc io.ReadWriteCloser
+	*Framer
+}
+
+// NewPipeTransport returns a transport that frames messages over rwc.  Closing
+// the transport closes rwc.
+func NewPipeTransport(rwc io.ReadWriteCloser, opts ...FramerOption) *PipeTransport {
+	return &PipeTransport{
+		rwc:    rwc,
+		Framer: NewFramer(rwc, rwc, opts...),
+	}
+}
+
+// RemoteAddr returns the remote address of the stream if it has one (i.e it is
+// a net.Conn) or nil otherwise.
+func (t *PipeTransport) RemoteAddr() net.Addr {
+	if conn, ok := t.rwc.(interface{ RemoteAddr() net.Addr }); ok {
+		return conn.RemoteAddr()
+	}
+	return nil
+}
+
+// SetReadDeadline sets the read deadline of the stream or returns
+// os.ErrNoDeadline if it doesn't support deadlines.
+func (t *PipeTransport) SetReadDeadline(d time.Time) error {
+	if conn, ok := t.rwc.(interface{ SetReadDeadline(time.Time) error }); ok {
+		return conn.SetReadDeadline(d)
+	}
+	return os.ErrNoDeadline
+}
+
+// SetWriteDeadline sets the write deadline of the stream or returns
+// os.ErrNoDeadline if it doesn't support deadlines.
+func (t *PipeTransport) SetWriteDeadline(d time.Time) error {
+	if conn, ok := t.rwc.(interface{ SetWriteDeadline(time.Time) error }); ok {
+		return conn.SetWriteDeadline(d)
+	}
+	return os.ErrNoDeadline
+}
+
+// Ping checks that the stream hasn't been closed or reset by the remote end
+// with [ProbeConn] if it is a socket.  For other streams it returns nil.
+func (t *PipeTransport) Ping(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	conn, ok := t.rwc.(net.Conn)
+	if !ok {
+		return nil
+	}
+	err := ProbeConn(conn)
+	if errors.Is(err, errors.ErrUnsupported) {
+		return nil
+	}
+	return err
+}
+
+// Close closes the underlying stream.
+func (t *PipeTransport) Close() error {
+	return t.rwc.Close()
+}
@@ -0,0 +1,48 @@
+package transport
+
+import (
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPipeTransport(t *testing.T) {
+	c1, c2 := net.Pipe()
+	client, server := NewPipeTransport(c1), NewPipeTransport(c2)
+	assert.Equal(t, c1.RemoteAddr(), client.RemoteAddr())
+
+	go func() {
+		w, err := client.MsgWriter()
+		if err != nil {
+			return
+		}
+		_, _ = io.WriteString(w, "<hello/>")
+		_ = w.Close()
+	}()
+
+	r, err := server.MsgReader()
+	require.NoError(t, err)
+	msg, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "<hello/>", strings.TrimSpace(string(msg)))
+	require.NoError(t, r.Close())
+
+	require.NoError(t, client.Close())
+	r, err = server.MsgReader()
+	require.NoError(t, err)
+	_, err = r.Read(make([]byte, 1))
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+type nopRWC struct{ io.ReadWriter }
+
+func (nopRWC) Close() error { return nil }
+
+func TestPipeTransportNoAddr(t *testing.T) {
+	tr := NewPipeTransport(nopRWC{})
+	assert.Nil(t, tr.RemoteAddr())
+}
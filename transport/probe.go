@@ -0,0 +1,36 @@
+package transport
+
+import (
+	"errors"
+	"net"
+	"syscall"
+)
+
+// ProbeConn checks that conn hasn't been closed or reset without reading or
+// writing any data.  It peeks at the socket without blocking so it is safe to
+// call while another goroutine is reading from conn.  It returns io.EOF if
+// the remote end closed the connection, the socket error if it was reset and
+// errors.ErrUnsupported if conn is not a socket or the platform can't probe
+// sockets.
+//
+// Probing only detects that the remote end closed the connection; a device
+// that silently went away is only detected once the kernel gives up on the
+// connection (see SocketOptions.KeepAlive).
+func ProbeConn(conn net.Conn) error {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return errors.ErrUnsupported
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	// Control doesn't take the read lock of the conn (unlike Read) so this
+	// doesn't wait for a blocked read to finish.
+	var probeErr error
+	if err := raw.Control(func(fd uintptr) { probeErr = peekSocket(fd) }); err != nil {
+		return err
+	}
+	return probeErr
+}
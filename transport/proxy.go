@@ -0,0 +1,265 @@
+package transport
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// NewProxyDialer returns a dialer connecting through the proxy at u using
+// forward to connect to the proxy itself (a net.Dialer if nil).  Supported
+// schemes are `socks5` (and `socks5h`) for SOCKS5 proxies (RFC1928) and
+// `http` for HTTP CONNECT proxies.  Credentials for the proxy are taken from
+// the user info of the url.
+//
+// Addresses are passed to the proxy unresolved so names are resolved by the
+// proxy.
+func NewProxyDialer(u *url.URL, forward ContextDialer) (ContextDialer, error) {
+	if forward == nil {
+		forward = &net.Dialer{}
+	}
+	switch u.Scheme {
+	case "socks5", "socks5h":
+		return &proxyDialer{url: u, forward: forward, handshake: socks5Connect, port: "1080"}, nil
+	case "http":
+		return &proxyDialer{url: u, forward: forward, handshake: httpConnect, port: "80"}, nil
+	}
+	return nil, fmt.Errorf("unsupported proxy scheme %q", u.Scheme)
+}
+
+type proxyDialer struct {
+	url       *url.URL
+	forward   ContextDialer
+	handshake func(conn net.Conn, u *url.URL, addr string) (net.Conn, error)
+	port      string
+}
+
+// DialContext implements ContextDialer.
+func (d *proxyDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return nil, fmt.Errorf("proxy: unsupported network %q", network)
+	}
+
+	proxyAddr := d.url.Host
+	if d.url.Port() == "" {
+		proxyAddr = net.JoinHostPort(d.url.Hostname(), d.port)
+	}
+	conn, err := d.forward.DialContext(ctx, "tcp", proxyAddr)
+	if err != nil {
+		return nil, fmt.Errorf("proxy: failed to connect to %s: %w", proxyAddr, err)
+	}
+
+	// abort the handshake if the context is done.
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { _ = conn.SetDeadline(time.Unix(1, 0)) })
+
+	pconn, err := d.handshake(conn, d.url, addr)
+	if !stop() && err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		conn.Close()
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("proxy: %w", err)
+	}
+	_ = conn.SetDeadline(time.Time{})
+	return pconn, nil
+}
+
+func splitAddr(addr string) (string, uint16, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", 0, err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid port %q", portStr)
+	}
+	return host, uint16(port), nil
+}
+
+const (
+	socks5Version      = 5
+	socks5NoAuth       = 0
+	socks5UserPass     = 2
+	socks5NoAcceptable = 0xff
+	socks5CmdConnect   = 1
+	socks5IPv4         = 1
+	socks5Domain       = 3
+	socks5IPv6         = 4
+)
+
+var socks5Errors = map[byte]string{
+	1: "general failure",
+	2: "connection not allowed by ruleset",
+	3: "network unreachable",
+	4: "host unreachable",
+	5: "connection refused",
+	6: "TTL expired",
+	7: "command not supported",
+	8: "address type not supported",
+}
+
+func socks5Connect(conn net.Conn, u *url.URL, addr string) (net.Conn, error) {
+	host, port, err := splitAddr(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	methods := []byte{socks5NoAuth}
+	if u.User != nil {
+		methods = append(methods, socks5UserPass)
+	}
+	if _, err := conn.Write(append([]byte{socks5Version, byte(len(methods))}, methods...)); err != nil {
+		return nil, err
+	}
+
+	var buf [4]byte
+	if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+		return nil, err
+	}
+	if buf[0] != socks5Version {
+		return nil, fmt.Errorf("unexpected socks version %d", buf[0])
+	}
+	switch buf[1] {
+	case socks5NoAuth:
+	case socks5UserPass:
+		if err := socks5Auth(conn, u.User); err != nil {
+			return nil, err
+		}
+	case socks5NoAcceptable:
+		return nil, errors.New("no acceptable socks authentication methods")
+	default:
+		return nil, fmt.Errorf("unsupported socks authentication method %d", buf[1])
+	}
+
+	req := []byte{socks5Version, socks5CmdConnect, 0}
+	if ip := net.ParseIP(host); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			req = append(append(req, socks5IPv4), ip4...)
+		} else {
+			req = append(append(req, socks5IPv6), ip...)
+		}
+	} else {
+		if len(host) > 255 {
+			return nil, fmt.Errorf("host name too long: %q", host)
+		}
+		req = append(append(req, socks5Domain, byte(len(host))), host...)
+	}
+	req = binary.BigEndian.AppendUint16(req, port)
+	if _, err := conn.Write(req); err != nil {
+		return nil, err
+	}
+
+	if _, err := io.ReadFull(conn, buf[:4]); err != nil {
+		return nil, err
+	}
+	if buf[1] != 0 {
+		msg, ok := socks5Errors[buf[1]]
+		if !ok {
+			msg = fmt.Sprintf("unknown error %d", buf[1])
+		}
+		return nil, fmt.Errorf("socks connect to %s failed: %s", addr, msg)
+	}
+
+	// skip the bound address.
+	var skip int
+	switch buf[3] {
+	case socks5IPv4:
+		skip = net.IPv4len
+	case socks5IPv6:
+		skip = net.IPv6len
+	case socks5Domain:
+		if _, err := io.ReadFull(conn, buf[:1]); err != nil {
+			return nil, err
+		}
+		skip = int(buf[0])
+	default:
+		return nil, fmt.Errorf("unknown socks address type %d", buf[3])
+	}
+	if _, err := io.CopyN(io.Discard, conn, int64(skip)+2); err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+// socks5Auth authenticates with a username and password (RFC1929).
+func socks5Auth(conn net.Conn, user *url.Userinfo) error {
+	username := user.Username()
+	password, _ := user.Password()
+	if len(username) > 255 || len(password) > 255 {
+		return errors.New("socks username or password too long")
+	}
+
+	req := append([]byte{1, byte(len(username))}, username...)
+	req = append(append(req, byte(len(password))), password...)
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+
+	var resp [2]byte
+	if _, err := io.ReadFull(conn, resp[:]); err != nil {
+		return err
+	}
+	if resp[1] != 0 {
+		return errors.New("socks authentication failed")
+	}
+	return nil
+}
+
+func httpConnect(conn net.Conn, u *url.URL, addr string) (net.Conn, error) {
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if u.User != nil {
+		password, _ := u.User.Password()
+		creds := base64.StdEncoding.EncodeToString([]byte(u.User.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+creds)
+	}
+	if err := req.Write(conn); err != nil {
+		return nil, err
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return nil, err
+	}
+	// the body of an error response isn't needed as the connection is closed.
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("http connect to %s failed: %s", addr, resp.Status)
+	}
+
+	// the device may have already sent data (i.e the ssh version banner) that
+	// was buffered while reading the response.
+	if br.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, r: br}, nil
+	}
+	return conn, nil
+}
+
+// bufferedConn is a connection with data already read into a buffer.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) { return c.r.Read(p) }
@@ -0,0 +1,196 @@
+package transport
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// serveGreeting accepts connections and writes a greeting like a ssh server
+// does before the client sends anything.
+func serveGreeting(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_, _ = io.WriteString(conn, "SSH-2.0-router\r\n")
+				_, _ = io.Copy(io.Discard, conn)
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// serveProxy accepts a single connection, runs handshake to get the target
+// and then proxies to it.
+func serveProxy(t *testing.T, handshake func(net.Conn) (string, error)) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		target, err := handshake(conn)
+		if err != nil {
+			return
+		}
+		up, err := net.Dial("tcp", target)
+		if err != nil {
+			return
+		}
+		defer up.Close()
+		go func() { _, _ = io.Copy(up, conn) }()
+		_, _ = io.Copy(conn, up)
+	}()
+	return ln.Addr().String()
+}
+
+func socks5Server(user, pass string) func(net.Conn) (string, error) {
+	return func(conn net.Conn) (string, error) {
+		var hdr [2]byte
+		if _, err := io.ReadFull(conn, hdr[:]); err != nil {
+			return "", err
+		}
+		methods := make([]byte, hdr[1])
+		if _, err := io.ReadFull(conn, methods); err != nil {
+			return "", err
+		}
+
+		if user != "" {
+			_, _ = conn.Write([]byte{5, socks5UserPass})
+			var b [1]byte
+			_, _ = io.ReadFull(conn, b[:]) // version
+			_, _ = io.ReadFull(conn, b[:])
+			u := make([]byte, b[0])
+			_, _ = io.ReadFull(conn, u)
+			_, _ = io.ReadFull(conn, b[:])
+			p := make([]byte, b[0])
+			_, _ = io.ReadFull(conn, p)
+			if string(u) != user || string(p) != pass {
+				_, _ = conn.Write([]byte{1, 1})
+				return "", io.EOF
+			}
+			_, _ = conn.Write([]byte{1, 0})
+		} else {
+			_, _ = conn.Write([]byte{5, socks5NoAuth})
+		}
+
+		var req [4]byte
+		if _, err := io.ReadFull(conn, req[:]); err != nil {
+			return "", err
+		}
+		var host string
+		switch req[3] {
+		case socks5IPv4:
+			ip := make([]byte, 4)
+			_, _ = io.ReadFull(conn, ip)
+			host = net.IP(ip).String()
+		case socks5Domain:
+			var n [1]byte
+			_, _ = io.ReadFull(conn, n[:])
+			name := make([]byte, n[0])
+			_, _ = io.ReadFull(conn, name)
+			host = string(name)
+		}
+		var port [2]byte
+		_, _ = io.ReadFull(conn, port[:])
+
+		_, _ = conn.Write([]byte{5, 0, 0, socks5IPv4, 0, 0, 0, 0, 0, 0})
+		return net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port[:])))), nil
+	}
+}
+
+func httpProxyServer(auth string) func(net.Conn) (string, error) {
+	return func(conn net.Conn) (string, error) {
+		req, err := http.ReadRequest(bufio.NewReader(conn))
+		if err != nil {
+			return "", err
+		}
+		if req.Method != http.MethodConnect {
+			_, _ = io.WriteString(conn, "HTTP/1.1 405 Method Not Allowed\r\n\r\n")
+			return "", io.EOF
+		}
+		if req.Header.Get("Proxy-Authorization") != auth {
+			_, _ = io.WriteString(conn, "HTTP/1.1 407 Proxy Authentication Required\r\nContent-Length: 0\r\n\r\n")
+			return "", io.EOF
+		}
+		_, _ = io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+		return req.Host, nil
+	}
+}
+
+func readGreeting(t *testing.T, d ContextDialer, addr string) {
+	t.Helper()
+	conn, err := d.DialContext(context.Background(), "tcp", addr)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, "SSH-2.0-router\r\n", line)
+}
+
+func TestSOCKS5Proxy(t *testing.T) {
+	target := serveGreeting(t)
+
+	proxy := serveProxy(t, socks5Server("", ""))
+	d, err := NewProxyDialer(&url.URL{Scheme: "socks5", Host: proxy}, nil)
+	require.NoError(t, err)
+	readGreeting(t, d, target)
+
+	proxy = serveProxy(t, socks5Server("admin", "secret"))
+	d, err = NewProxyDialer(&url.URL{Scheme: "socks5h", Host: proxy, User: url.UserPassword("admin", "secret")}, nil)
+	require.NoError(t, err)
+	_, port, _ := net.SplitHostPort(target)
+	readGreeting(t, d, net.JoinHostPort("localhost", port))
+
+	proxy = serveProxy(t, socks5Server("admin", "secret"))
+	d, err = NewProxyDialer(&url.URL{Scheme: "socks5", Host: proxy, User: url.UserPassword("admin", "wrong")}, nil)
+	require.NoError(t, err)
+	_, err = d.DialContext(context.Background(), "tcp", target)
+	assert.ErrorContains(t, err, "socks authentication failed")
+}
+
+func TestHTTPProxy(t *testing.T) {
+	target := serveGreeting(t)
+
+	proxy := serveProxy(t, httpProxyServer("Basic YWRtaW46c2VjcmV0"))
+	d, err := NewProxyDialer(&url.URL{Scheme: "http", Host: proxy, User: url.UserPassword("admin", "secret")}, nil)
+	require.NoError(t, err)
+	readGreeting(t, d, target)
+
+	proxy = serveProxy(t, httpProxyServer("Basic YWRtaW46c2VjcmV0"))
+	d, err = NewProxyDialer(&url.URL{Scheme: "http", Host: proxy}, nil)
+	require.NoError(t, err)
+	_, err = d.DialContext(context.Background(), "tcp", target)
+	assert.ErrorContains(t, err, "407 Proxy Authentication Required")
+}
+
+func TestProxyUnsupported(t *testing.T) {
+	_, err := NewProxyDialer(&url.URL{Scheme: "ftp", Host: "proxy"}, nil)
+	assert.ErrorContains(t, err, `unsupported proxy scheme "ftp"`)
+
+	d, err := NewProxyDialer(&url.URL{Scheme: "socks5", Host: "proxy"}, nil)
+	require.NoError(t, err)
+	_, err = d.DialContext(context.Background(), "udp", "router:830")
+	assert.ErrorContains(t, err, `unsupported network "udp"`)
+}
@@ -0,0 +1,364 @@
+// Package ratelimit implements a transport that throttles the messages and
+// bytes sent to a device.
+//
+// Some devices have weak control planes that fall over when sent a lot of
+// configuration quickly (i.e bulk configuration pushes).  Wrapping the
+// transport of their sessions limits how fast rpcs are sent to them using a
+// token bucket:
+//
+//	tr, err := ssh.Dial(ctx, "tcp", addr, config)
+//	if err != nil { /* ... */ }
+//	tr = ratelimit.NewTransport(tr,
+//		ratelimit.WithMessagesPerSecond(5, 1),
+//		ratelimit.WithBytesPerSecond(64*1024, 16*1024))
+//	session, err := netconf.Open(tr)
+//
+// Only messages sent are throttled; reading is never limited.
+package ratelimit
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/nemith/netconf/clock"
+	"github.com/nemith/netconf/transport"
+)
+
+// Option is an optional argument to [NewTransport].
+type Option interface {
+	apply(*Transport)
+}
+
+type bytesOpt struct {
+	rate  float64
+	burst int
+}
+
+func (o bytesOpt) apply(t *Transport) { t.bytes = newBucket(o.rate, o.burst) }
+
+// WithBytesPerSecond limits the bytes of the messages sent (not counting the
+// framing) to rate per second allowing bursts of up to burst bytes.  Writes
+// larger than burst are sent in pieces of at most burst bytes.
+func WithBytesPerSecond(rate float64, burst int) Option { return bytesOpt{rate, burst} }
+
+type msgsOpt struct {
+	rate  float64
+	burst int
+}
+
+func (o msgsOpt) apply(t *Transport) { t.msgs = newBucket(o.rate, o.burst) }
+
+// WithMessagesPerSecond limits the messages sent to rate per second allowing
+// bursts of up to burst messages.
+func WithMessagesPerSecond(rate float64, burst int) Option { return msgsOpt{rate, burst} }
+
+type clockOpt struct{ c clock.Clock }
+
+func (o clockOpt) apply(t *Transport) { t.clock = o.c }
+
+// WithClock sets the clock used to refill the buckets.  It defaults to the
+// system clock.
+func WithClock(c clock.Clock) Option { return clockOpt{c} }
+
+// Transport wraps another transport and throttles the messages written to it.
+//
+// Waiting for the rate limit is interrupted by closing the transport or by
+// the write deadline (see [transport.Deadliner]) so sessions still honor the
+// context of an rpc held up by the rate limit.
+type Transport struct {
+	transport.Transport
+	clock clock.Clock
+	bytes *bucket
+	msgs  *bucket
+
+	mu              sync.Mutex
+	deadline        time.Time
+	deadlineChanged chan struct{}
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewTransport returns a transport that throttles the messages written to tr.
+// Without any options no limit is applied.
+func NewTransport(tr transport.Transport, opts ...Option) *Transport {
+	t := &Transport{
+		Transport:       tr,
+		deadlineChanged: make(chan struct{}),
+		done:            make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt.apply(t)
+	}
+	t.clock = clock.Or(t.clock)
+	return t
+}
+
+// MsgWriter implements transport.Transport.  It waits until sending another
+// message is allowed.
+func (t *Transport) MsgWriter() (io.WriteCloser, error) {
+	if t.msgs != nil {
+		if err := t.wait(t.msgs, 1); err != nil {
+			return nil, err
+		}
+	}
+
+	w, err := t.Transport.MsgWriter()
+	if err != nil {
+		return nil, err
+	}
+	if t.bytes == nil {
+		return w, nil
+	}
+	return &writer{WriteCloser: w, t: t}, nil
+}
+
+// Close closes the wrapped transport.  Any writes waiting for the rate limit
+// fail with io.ErrClosedPipe.
+func (t *Transport) Close() error {
+	t.closeOnce.Do(func() { close(t.done) })
+	return t.Transport.Close()
+}
+
+// Upgrade upgrades the wrapped transport if it supports it.
+func (t *Transport) Upgrade() {
+	if upgrader, ok := t.Transport.(interface{ Upgrade() }); ok {
+		upgrader.Upgrade()
+	}
+}
+
+// PinEOM pins the wrapped transport to End-of-Message framing if it supports
+// it.
+func (t *Transport) PinEOM() {
+	if pinner, ok := t.Transport.(interface{ PinEOM() }); ok {
+		pinner.PinEOM()
+	}
+}
+
+// DebugCapture copies the framed data read from and written to the wrapped
+// transport to in and out if it supports it (see
+// [transport.Framer.DebugCapture]).  It can be called at any time to start or
+// stop capturing a session.
+func (t *Transport) DebugCapture(in, out io.Writer) {
+	if tr, ok := t.Transport.(interface{ DebugCapture(in, out io.Writer) }); ok {
+		tr.DebugCapture(in, out)
+	}
+}
+
+// DebugCaptureMsgs calls fn with every message read from and written to the
+// wrapped transport if it supports it (see [transport.Framer.DebugCaptureMsgs])
+// and does nothing otherwise.  It can be called at any time to start or stop
+// capturing a session.
+func (t *Transport) DebugCaptureMsgs(fn func(transport.CapturedMsg)) {
+	if tr, ok := t.Transport.(interface {
+		DebugCaptureMsgs(fn func(transport.CapturedMsg))
+	}); ok {
+		tr.DebugCaptureMsgs(fn)
+	}
+}
+
+// RemoteAddr returns the remote address of the wrapped transport or nil if
+// it's not known.
+func (t *Transport) RemoteAddr() net.Addr {
+	if tr, ok := t.Transport.(interface{ RemoteAddr() net.Addr }); ok {
+		return tr.RemoteAddr()
+	}
+	return nil
+}
+
+// SetReadDeadline sets the read deadline of the wrapped transport if it
+// supports it.
+func (t *Transport) SetReadDeadline(d time.Time) error {
+	if tr, ok := t.Transport.(transport.Deadliner); ok {
+		return tr.SetReadDeadline(d)
+	}
+	return os.ErrNoDeadline
+}
+
+// SetWriteDeadline sets the deadline for waiting on the rate limit and the
+// write deadline of the wrapped transport if it supports it.
+func (t *Transport) SetWriteDeadline(d time.Time) error {
+	t.mu.Lock()
+	t.deadline = d
+	close(t.deadlineChanged)
+	t.deadlineChanged = make(chan struct{})
+	t.mu.Unlock()
+
+	if tr, ok := t.Transport.(transport.Deadliner); ok {
+		if err := tr.SetWriteDeadline(d); err != os.ErrNoDeadline {
+			return err
+		}
+	}
+	return nil
+}
+
+// Ping pings the wrapped transport if it supports it (see
+// [transport.Pinger]) or returns nil otherwise.
+func (t *Transport) Ping(ctx context.Context) error {
+	if tr, ok := t.Transport.(transport.Pinger); ok {
+		return tr.Ping(ctx)
+	}
+	return nil
+}
+
+// wait blocks until n tokens are available from b.
+func (t *Transport) wait(b *bucket, n int) error {
+	t.mu.Lock()
+	delay := b.take(t.clock.Now(), float64(n))
+	t.mu.Unlock()
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := t.clock.NewTimer(delay)
+	defer timer.Stop()
+
+	for {
+		t.mu.Lock()
+		deadline, changed := t.deadline, t.deadlineChanged
+		t.mu.Unlock()
+
+		var (
+			deadlineTimer clock.Timer
+			expired       <-chan time.Time
+		)
+		if !deadline.IsZero() {
+			deadlineTimer = t.clock.NewTimer(deadline.Sub(t.clock.Now()))
+			expired = deadlineTimer.C()
+		}
+
+		var err error
+		select {
+		case <-timer.C():
+			return nil
+		case <-changed:
+		case <-expired:
+			err = os.ErrDeadlineExceeded
+		case <-t.done:
+			err = io.ErrClosedPipe
+		}
+		if deadlineTimer != nil {
+			deadlineTimer.Stop()
+		}
+
+		if err != nil {
+			// the tokens were not used.
+			t.mu.Lock()
+			b.refund(float64(n))
+			t.mu.Unlock()
+			return err
+		}
+	}
+}
+
+type writer struct {
+	io.WriteCloser
+	t *Transport
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	var written int
+	for len(p) > 0 {
+		chunk := p[:min(len(p), w.t.bytes.size())]
+		if err := w.t.wait(w.t.bytes, len(chunk)); err != nil {
+			return written, err
+		}
+		n, err := w.WriteCloser.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
+
+// Limiter is a token bucket limiting how often something other than writing
+// messages happens (i.e accepting connections).  A nil *Limiter doesn't limit.
+//
+// A Limiter is safe for concurrent use.
+type Limiter struct {
+	clock clock.Clock
+
+	mu sync.Mutex
+	b  *bucket
+}
+
+// NewLimiter returns a limiter allowing rate events per second with bursts of
+// up to burst events.  A nil clock uses the system clock.
+func NewLimiter(rate float64, burst int, c clock.Clock) *Limiter {
+	return &Limiter{clock: clock.Or(c), b: newBucket(rate, burst)}
+}
+
+// Wait blocks until the next event is allowed or ctx is done.  If ctx is done
+// first its error is returned and the event doesn't count against the limit.
+func (l *Limiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	delay := l.b.take(l.clock.Now(), 1)
+	l.mu.Unlock()
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := l.clock.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C():
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		l.b.refund(1)
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// bucket is a token bucket.  Taking more tokens than available reserves them
+// and returns how long until they are available.  It is guarded by the mutex
+// of the Transport or Limiter.
+type bucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newBucket(rate float64, burst int) *bucket {
+	burst = max(burst, 1)
+	return &bucket{rate: rate, burst: float64(burst), tokens: float64(burst)}
+}
+
+// size returns the most tokens that can be taken at once.
+func (b *bucket) size() int { return int(b.burst) }
+
+func (b *bucket) refill(now time.Time) {
+	if !b.last.IsZero() && now.After(b.last) {
+		b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+	b.last = now
+}
+
+func (b *bucket) take(now time.Time, n float64) time.Duration {
+	b.refill(now)
+	b.tokens -= n
+	if b.tokens >= 0 {
+		return 0
+	}
+	if b.rate <= 0 {
+		// nothing is ever refilled.
+		return time.Duration(1<<63 - 1)
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+func (b *bucket) refund(n float64) {
+	b.tokens = min(b.burst, b.tokens+n)
+}
@@ -0,0 +1,208 @@
+package ratelimit
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nemith/netconf/clock"
+	"github.com/nemith/netconf/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTransport keeps every write made to it.
+type fakeTransport struct {
+	mu     sync.Mutex
+	writes []string
+}
+
+func (t *fakeTransport) MsgReader() (io.ReadCloser, error)  { return nil, io.EOF }
+func (t *fakeTransport) MsgWriter() (io.WriteCloser, error) { return &fakeWriter{t: t}, nil }
+func (t *fakeTransport) Close() error                       { return nil }
+
+func (t *fakeTransport) written() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]string(nil), t.writes...)
+}
+
+type fakeWriter struct{ t *fakeTransport }
+
+func (w *fakeWriter) Write(p []byte) (int, error) {
+	w.t.mu.Lock()
+	defer w.t.mu.Unlock()
+	w.t.writes = append(w.t.writes, string(p))
+	return len(p), nil
+}
+
+func (w *fakeWriter) Close() error { return nil }
+
+func TestMessagesPerSecond(t *testing.T) {
+	clk := clock.NewFake(time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC))
+	tr := NewTransport(&fakeTransport{}, WithMessagesPerSecond(2, 1), WithClock(clk))
+
+	_, err := tr.MsgWriter()
+	require.NoError(t, err)
+
+	done := make(chan error)
+	go func() {
+		_, err := tr.MsgWriter()
+		done <- err
+	}()
+
+	clk.BlockUntil(1)
+	clk.Advance(400 * time.Millisecond)
+	select {
+	case <-done:
+		t.Fatal("writer returned before the rate allowed")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	clk.Advance(100 * time.Millisecond)
+	assert.NoError(t, <-done)
+}
+
+func TestBytesPerSecond(t *testing.T) {
+	clk := clock.NewFake(time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC))
+	inner := &fakeTransport{}
+	tr := NewTransport(inner, WithBytesPerSecond(10, 4), WithClock(clk))
+
+	w, err := tr.MsgWriter()
+	require.NoError(t, err)
+
+	done := make(chan error)
+	go func() {
+		_, err := w.Write([]byte("0123456789"))
+		done <- err
+	}()
+
+	clk.BlockUntil(1)
+	clk.Advance(400 * time.Millisecond)
+	clk.BlockUntil(1)
+	clk.Advance(200 * time.Millisecond)
+	require.NoError(t, <-done)
+	assert.Equal(t, []string{"0123", "4567", "89"}, inner.written())
+}
+
+func TestNoLimit(t *testing.T) {
+	inner := &fakeTransport{}
+	tr := NewTransport(inner)
+
+	w, err := tr.MsgWriter()
+	require.NoError(t, err)
+	_, err = io.Copy(w, bytes.NewReader(make([]byte, 1<<20)))
+	assert.NoError(t, err)
+}
+
+func TestWaitInterrupted(t *testing.T) {
+	clk := clock.NewFake(time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC))
+	tr := NewTransport(&fakeTransport{}, WithMessagesPerSecond(1, 1), WithClock(clk))
+
+	_, err := tr.MsgWriter()
+	require.NoError(t, err)
+
+	done := make(chan error)
+	go func() {
+		_, err := tr.MsgWriter()
+		done <- err
+	}()
+	clk.BlockUntil(1)
+	require.NoError(t, tr.SetWriteDeadline(time.Unix(1, 0)))
+	assert.ErrorIs(t, <-done, os.ErrDeadlineExceeded)
+
+	// a deadline in the future fails the wait once reached.
+	require.NoError(t, tr.SetWriteDeadline(clk.Now().Add(100*time.Millisecond)))
+	go func() {
+		_, err := tr.MsgWriter()
+		done <- err
+	}()
+	clk.BlockUntil(2)
+	clk.Advance(100 * time.Millisecond)
+	assert.ErrorIs(t, <-done, os.ErrDeadlineExceeded)
+
+	require.NoError(t, tr.SetWriteDeadline(time.Time{}))
+	go func() {
+		_, err := tr.MsgWriter()
+		done <- err
+	}()
+	clk.BlockUntil(1)
+	require.NoError(t, tr.Close())
+	assert.ErrorIs(t, <-done, io.ErrClosedPipe)
+}
+
+func TestDebugCaptureForwarded(t *testing.T) {
+	c1, c2 := net.Pipe()
+	server := transport.NewPipeTransport(c2)
+	defer server.Close()
+	tr := NewTransport(transport.NewPipeTransport(c1), WithMessagesPerSecond(100, 10))
+	defer tr.Close()
+
+	var msgs []transport.CapturedMsg
+	var raw bytes.Buffer
+	tr.DebugCaptureMsgs(func(m transport.CapturedMsg) { msgs = append(msgs, m) })
+	tr.DebugCapture(nil, &raw)
+
+	done := make(chan error)
+	go func() {
+		r, err := server.MsgReader()
+		if err == nil {
+			_, err = io.ReadAll(r)
+		}
+		done <- err
+	}()
+	w, err := tr.MsgWriter()
+	require.NoError(t, err)
+	_, err = io.WriteString(w, "<rpc/>")
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	require.NoError(t, <-done)
+
+	require.Len(t, msgs, 1)
+	assert.True(t, msgs[0].Sent)
+	assert.Equal(t, "<rpc/>", string(msgs[0].Data))
+	assert.Equal(t, "<rpc/>\n]]>]]>", raw.String())
+
+	// wrapping a transport without a framer does nothing.
+	NewTransport(&fakeTransport{}).DebugCaptureMsgs(func(transport.CapturedMsg) {})
+}
+
+func TestLimiter(t *testing.T) {
+	ctx := context.Background()
+	require.NoError(t, (*Limiter)(nil).Wait(ctx))
+
+	clk := clock.NewFake(time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC))
+	l := NewLimiter(10, 2, clk)
+	require.NoError(t, l.Wait(ctx))
+	require.NoError(t, l.Wait(ctx))
+
+	// the next one waits for another token.
+	done := make(chan error)
+	go func() { done <- l.Wait(ctx) }()
+	clk.BlockUntil(1)
+	clk.Advance(99 * time.Millisecond)
+	select {
+	case <-done:
+		t.Fatal("wait returned before a token was available")
+	default:
+	}
+	clk.Advance(time.Millisecond)
+	require.NoError(t, <-done)
+
+	// a canceled wait gives its token back.
+	cancelCtx, cancel := context.WithCancel(ctx)
+	go func() { done <- l.Wait(cancelCtx) }()
+	clk.BlockUntil(1)
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+
+	go func() { done <- l.Wait(ctx) }()
+	clk.BlockUntil(1)
+	clk.Advance(100 * time.Millisecond)
+	require.NoError(t, <-done)
+}
@@ -0,0 +1,156 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"runtime/debug"
+	"strconv"
+	"strings"
+)
+
+// ContextDialer is implemented by dialers that can be used to establish the
+// underlying connection for a transport (i.e *net.Dialer or a proxy dialer).
+type ContextDialer interface {
+	DialContext(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+// Resolver resolves the address of a device given to a Dial function into
+// the list of addresses to try connecting to in order.  Returned addresses
+// without a port use the default port of the transport.
+//
+// This allows devices to be addressed by a logical name that is looked up in
+// an inventory system, service discovery or DNS instead of a fixed management
+// address.
+type Resolver interface {
+	Resolve(ctx context.Context, network, addr string) ([]string, error)
+}
+
+// PanicError is the error returned when a user provided callback (i.e a
+// [Resolver]) panics.
+type PanicError struct {
+	// Callback is a description of the callback that panicked (i.e
+	// "notification handler").
+	Callback string
+
+	// Value is the value passed to panic.
+	Value any
+
+	// Stack is the stack trace of the goroutine at the time of the panic.  It
+	// is not part of the error message.
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic in %s: %v", e.Callback, e.Value)
+}
+
+// Unwrap returns the value passed to panic if it was an error.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// Resolve resolves addr with r for a Dial function.  A nil Resolver returns
+// addr as is.  A panic in the resolver is recovered and returned as a
+// PanicError.
+func Resolve(ctx context.Context, r Resolver, network, addr string) (addrs []string, err error) {
+	if r == nil {
+		return []string{addr}, nil
+	}
+
+	defer func() {
+		if v := recover(); v != nil {
+			addrs, err = nil, &PanicError{Callback: "resolver", Value: v, Stack: debug.Stack()}
+		}
+	}()
+	return r.Resolve(ctx, network, addr)
+}
+
+// ResolverFunc is an adapter to allow the use of ordinary functions as a
+// Resolver.
+type ResolverFunc func(ctx context.Context, network, addr string) ([]string, error)
+
+// Resolve calls f(ctx, network, addr).
+func (f ResolverFunc) Resolve(ctx context.Context, network, addr string) ([]string, error) {
+	return f(ctx, network, addr)
+}
+
+// SRVResolver resolves devices with DNS SRV records (RFC2782).  An address
+// without a port is looked up as `_<service>._tcp.<addr>` and the targets are
+// returned in priority and weight order.  Addresses that include a port or
+// have no SRV records are returned as is.
+type SRVResolver struct {
+	// Service is the service name of the SRV record (i.e `netconf-ssh` or
+	// `netconf-tls`).
+	Service string
+
+	// Resolver is used for the lookups.  If nil net.DefaultResolver is used.
+	Resolver *net.Resolver
+}
+
+// Resolve implements Resolver.
+func (r *SRVResolver) Resolve(ctx context.Context, network, addr string) ([]string, error) {
+	if _, _, err := net.SplitHostPort(addr); err == nil {
+		return []string{addr}, nil
+	}
+	// IP addresses never have SRV records.
+	if net.ParseIP(strings.Trim(addr, "[]")) != nil {
+		return []string{addr}, nil
+	}
+
+	resolver := r.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+
+	_, records, err := resolver.LookupSRV(ctx, r.Service, "tcp", addr)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return []string{addr}, nil
+		}
+		return nil, fmt.Errorf("failed to lookup srv records for %q: %w", addr, err)
+	}
+
+	addrs := make([]string, 0, len(records))
+	for _, rec := range records {
+		// a target of "." means the service is decidedly not available.
+		target := strings.TrimSuffix(rec.Target, ".")
+		if target == "" {
+			continue
+		}
+		addrs = append(addrs, net.JoinHostPort(target, strconv.Itoa(int(rec.Port))))
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("service %q not available at %q", r.Service, addr)
+	}
+	return addrs, nil
+}
+
+// DialFirst dials each of the addresses in order and returns the first
+// successful connection.  If all of them fail the errors are joined together.
+func DialFirst(ctx context.Context, d ContextDialer, network string, addrs []string) (net.Conn, error) {
+	if len(addrs) == 0 {
+		return nil, errors.New("no addresses to dial")
+	}
+
+	var errs []error
+	for _, addr := range addrs {
+		conn, err := d.DialContext(ctx, network, addr)
+		if err == nil {
+			return conn, nil
+		}
+		errs = append(errs, err)
+
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	if len(errs) == 1 {
+		return nil, errs[0]
+	}
+	return nil, errors.Join(errs...)
+}
@@ -0,0 +1,79 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSRVResolverPassthrough(t *testing.T) {
+	// these should never hit dns
+	r := &SRVResolver{
+		Service: "netconf-ssh",
+		Resolver: &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+				t.Fatal("unexpected dns lookup")
+				return nil, nil
+			},
+		},
+	}
+
+	for _, addr := range []string{"router1:830", "192.0.2.1", "2001:db8::1", "[2001:db8::1]"} {
+		got, err := r.Resolve(context.Background(), "tcp", addr)
+		require.NoError(t, err)
+		assert.Equal(t, []string{addr}, got)
+	}
+}
+
+type fakeDialer map[string]error
+
+func (d fakeDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if err := d[addr]; err != nil {
+		return nil, err
+	}
+	c1, c2 := net.Pipe()
+	c2.Close()
+	return c1, nil
+}
+
+func TestDialFirst(t *testing.T) {
+	errA := errors.New("a failed")
+	errB := errors.New("b failed")
+	d := fakeDialer{"a": errA, "b": errB}
+
+	conn, err := DialFirst(context.Background(), d, "tcp", []string{"a", "c"})
+	require.NoError(t, err)
+	conn.Close()
+
+	_, err = DialFirst(context.Background(), d, "tcp", []string{"a"})
+	assert.Equal(t, errA, err)
+
+	_, err = DialFirst(context.Background(), d, "tcp", []string{"a", "b"})
+	assert.ErrorIs(t, err, errA)
+	assert.ErrorIs(t, err, errB)
+
+	_, err = DialFirst(context.Background(), d, "tcp", nil)
+	assert.Error(t, err)
+}
+
+func TestResolve(t *testing.T) {
+	got, err := Resolve(context.Background(), nil, "tcp", "router1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"router1"}, got)
+
+	errBoom := errors.New("boom")
+	r := ResolverFunc(func(ctx context.Context, network, addr string) ([]string, error) {
+		panic(errBoom)
+	})
+	got, err = Resolve(context.Background(), r, "tcp", "router1")
+	assert.Nil(t, got)
+	var perr *PanicError
+	require.ErrorAs(t, err, &perr)
+	assert.Equal(t, "resolver", perr.Callback)
+	assert.ErrorIs(t, err, errBoom)
+}
@@ -0,0 +1,75 @@
+package transport
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"time"
+)
+
+// ErrDSCPUnsupported is returned when DSCP marking is requested on a platform
+// where it isn't supported.
+var ErrDSCPUnsupported = errors.New("netconf: dscp marking not supported on this platform")
+
+// SocketOptions tune the TCP connections made by the transports.  The zero
+// value uses the defaults of net.Dialer.
+type SocketOptions struct {
+	// Timeout is the maximum time to wait for the connection to be
+	// established.
+	Timeout time.Duration
+
+	// KeepAlive is the interval between TCP keepalive probes.  Zero uses the
+	// default interval (15 seconds) and a negative value disables keepalives.
+	KeepAlive time.Duration
+
+	// DSCP is the Differentiated Services codepoint (0-63) set on the
+	// packets of the connection, i.e 48 for CS6 commonly used for network
+	// management traffic.  Zero leaves the marking unchanged.
+	DSCP int
+
+	// SourceAddr is the local IP address to connect from, i.e the address of
+	// a loopback interface.  Empty lets the system choose.
+	SourceAddr string
+}
+
+// Dialer returns a dialer applying the options.
+func (o SocketOptions) Dialer() (*net.Dialer, error) {
+	d := &net.Dialer{
+		Timeout:   o.Timeout,
+		KeepAlive: o.KeepAlive,
+	}
+
+	if o.SourceAddr != "" {
+		ip := net.ParseIP(o.SourceAddr)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid source address %q", o.SourceAddr)
+		}
+		d.LocalAddr = &net.TCPAddr{IP: ip}
+	}
+
+	if o.DSCP != 0 {
+		if o.DSCP < 0 || o.DSCP > 63 {
+			return nil, fmt.Errorf("invalid dscp value %d", o.DSCP)
+		}
+		if !dscpSupported {
+			return nil, ErrDSCPUnsupported
+		}
+		tos := o.DSCP << 2
+		d.Control = func(network, address string, c syscall.RawConn) error {
+			var sockErr error
+			err := c.Control(func(fd uintptr) {
+				sockErr = setTOS(fd, network, tos)
+			})
+			if err != nil {
+				return err
+			}
+			if sockErr != nil {
+				return fmt.Errorf("failed to set dscp: %w", sockErr)
+			}
+			return nil
+		}
+	}
+
+	return d, nil
+}
@@ -0,0 +1,15 @@
+//go:build !(linux || darwin || freebsd || netbsd || dragonfly)
+
+package transport
+
+import "errors"
+
+const dscpSupported = false
+
+func setTOS(fd uintptr, network string, tos int) error {
+	return ErrDSCPUnsupported
+}
+
+func peekSocket(fd uintptr) error {
+	return errors.ErrUnsupported
+}
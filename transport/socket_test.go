@@ -0,0 +1,40 @@
+package transport
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSocketOptionsDialer(t *testing.T) {
+	d, err := SocketOptions{}.Dialer()
+	require.NoError(t, err)
+	assert.Nil(t, d.LocalAddr)
+	assert.Nil(t, d.Control)
+
+	d, err = SocketOptions{Timeout: time.Second, KeepAlive: -1, SourceAddr: "127.0.0.1"}.Dialer()
+	require.NoError(t, err)
+	assert.Equal(t, time.Second, d.Timeout)
+	assert.Equal(t, time.Duration(-1), d.KeepAlive)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	conn, err := d.DialContext(context.Background(), "tcp", ln.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	assert.Equal(t, "127.0.0.1", conn.LocalAddr().(*net.TCPAddr).IP.String())
+}
+
+func TestSocketOptionsInvalid(t *testing.T) {
+	_, err := SocketOptions{SourceAddr: "loopback0"}.Dialer()
+	assert.ErrorContains(t, err, "invalid source address")
+
+	_, err = SocketOptions{DSCP: 64}.Dialer()
+	assert.ErrorContains(t, err, "invalid dscp value")
+}
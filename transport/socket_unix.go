@@ -0,0 +1,33 @@
+//go:build linux || darwin || freebsd || netbsd || dragonfly
+
+package transport
+
+import (
+	"io"
+	"syscall"
+)
+
+const dscpSupported = true
+
+// setTOS sets the traffic class of a IPv4 or IPv6 socket.
+func setTOS(fd uintptr, network string, tos int) error {
+	if network == "tcp6" {
+		return syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6, syscall.IPV6_TCLASS, tos)
+	}
+	return syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_TOS, tos)
+}
+
+// peekSocket does a non-blocking peek of a byte from a socket.
+func peekSocket(fd uintptr) error {
+	var b [1]byte
+	n, _, err := syscall.Recvfrom(int(fd), b[:], syscall.MSG_PEEK|syscall.MSG_DONTWAIT)
+	switch {
+	case err == syscall.EAGAIN || err == syscall.EWOULDBLOCK:
+		return nil
+	case err != nil:
+		return err
+	case n == 0:
+		return io.EOF
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+//go:build linux || darwin || freebsd || netbsd || dragonfly
+
+package transport
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSocketOptionsDSCP(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	d, err := SocketOptions{DSCP: 48}.Dialer()
+	require.NoError(t, err)
+	conn, err := d.DialContext(context.Background(), "tcp4", ln.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	raw, err := conn.(*net.TCPConn).SyscallConn()
+	require.NoError(t, err)
+	var tos int
+	var sockErr error
+	require.NoError(t, raw.Control(func(fd uintptr) {
+		tos, sockErr = syscall.GetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_TOS)
+	}))
+	require.NoError(t, sockErr)
+	assert.Equal(t, 48<<2, tos)
+}
+
+func TestProbeConn(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			close(accepted)
+			return
+		}
+		accepted <- conn
+	}()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	peer := <-accepted
+	require.NotNil(t, peer)
+
+	assert.NoError(t, ProbeConn(conn))
+
+	// unread data doesn't affect the probe and isn't consumed.
+	_, err = peer.Write([]byte("hi"))
+	require.NoError(t, err)
+	buf := make([]byte, 2)
+	assert.NoError(t, ProbeConn(conn))
+	_, err = io.ReadFull(conn, buf)
+	require.NoError(t, err)
+	assert.Equal(t, "hi", string(buf))
+
+	peer.Close()
+	assert.Eventually(t, func() bool {
+		return errors.Is(ProbeConn(conn), io.EOF)
+	}, time.Second, 10*time.Millisecond)
+
+	conn.Close()
+	assert.Error(t, ProbeConn(conn))
+}
+
+func TestProbeConnUnsupported(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	assert.ErrorIs(t, ProbeConn(client), errors.ErrUnsupported)
+}
@@ -0,0 +1,206 @@
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// ErrNoAuthMethods is returned when no ssh authentication methods could be
+// found.
+var ErrNoAuthMethods = errors.New("ssh: no authentication methods available")
+
+// PassphrasePrompt returns the passphrase for the private key in the given
+// file.
+type PassphrasePrompt func(file string) ([]byte, error)
+
+// sshDir returns the directory holding the user's ssh files.
+func sshDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".ssh")
+}
+
+// DefaultKeyFiles returns the private key files OpenSSH uses by default
+// (~/.ssh/id_ed25519, ~/.ssh/id_ecdsa and ~/.ssh/id_rsa).
+func DefaultKeyFiles() []string {
+	dir := sshDir()
+	return []string{
+		filepath.Join(dir, "id_ed25519"),
+		filepath.Join(dir, "id_ecdsa"),
+		filepath.Join(dir, "id_rsa"),
+	}
+}
+
+// AgentAuth returns an auth method using the keys from the ssh-agent listening
+// at $SSH_AUTH_SOCK.  A single connection to each agent is shared by all the
+// auth methods returned (and so all the transports dialed with them) for the
+// life of the process.  It is redialed if the agent goes away.
+func AgentAuth() (ssh.AuthMethod, error) {
+	sock := os.Getenv("SSH_AUTH_SOCK")
+	if sock == "" {
+		return nil, errors.New("ssh: SSH_AUTH_SOCK not set")
+	}
+	if _, err := sharedAgent(sock); err != nil {
+		return nil, err
+	}
+
+	return ssh.PublicKeysCallback(func() ([]ssh.Signer, error) {
+		c, err := sharedAgent(sock)
+		if err != nil {
+			return nil, err
+		}
+		signers, err := c.client.Signers()
+		if err == nil {
+			return signers, nil
+		}
+
+		// the agent may have been restarted.
+		dropAgent(sock, c)
+		if c, err = sharedAgent(sock); err != nil {
+			return nil, err
+		}
+		return c.client.Signers()
+	}), nil
+}
+
+// agentConn is a connection to an ssh-agent.
+type agentConn struct {
+	conn   net.Conn
+	client agent.ExtendedAgent
+}
+
+var (
+	agentsMu sync.Mutex
+	agents   = make(map[string]*agentConn)
+)
+
+// sharedAgent returns the connection to the agent listening at sock dialing
+// it if needed.
+func sharedAgent(sock string) (*agentConn, error) {
+	agentsMu.Lock()
+	defer agentsMu.Unlock()
+	if c, ok := agents[sock]; ok {
+		return c, nil
+	}
+
+	conn, err := net.Dial("unix", sock)
+	if err != nil {
+		return nil, fmt.Errorf("ssh: failed to connect to agent: %w", err)
+	}
+	c := &agentConn{conn: conn, client: agent.NewClient(conn)}
+	agents[sock] = c
+	return c, nil
+}
+
+// dropAgent closes a broken agent connection so the next use redials.
+func dropAgent(sock string, c *agentConn) {
+	agentsMu.Lock()
+	defer agentsMu.Unlock()
+	if agents[sock] == c {
+		delete(agents, sock)
+	}
+	c.conn.Close()
+}
+
+// KeyFileAuth returns an auth method using the private keys in the given files
+// or [DefaultKeyFiles] if none are given.  Files that don't exist are
+// skipped.  Keys protected by a passphrase are decrypted with the passphrase
+// from prompt or skipped if prompt is nil.  [ErrNoAuthMethods] is returned if
+// no keys were loaded.
+func KeyFileAuth(prompt PassphrasePrompt, files ...string) (ssh.AuthMethod, error) {
+	if len(files) == 0 {
+		files = DefaultKeyFiles()
+	}
+
+	var signers []ssh.Signer
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		signer, err := ssh.ParsePrivateKey(data)
+		var missing *ssh.PassphraseMissingError
+		if errors.As(err, &missing) {
+			if prompt == nil {
+				continue
+			}
+			passphrase, perr := prompt(file)
+			if perr != nil {
+				return nil, fmt.Errorf("ssh: failed to get passphrase for %q: %w", file, perr)
+			}
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(data, passphrase)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("ssh: failed to parse private key %q: %w", file, err)
+		}
+		signers = append(signers, signer)
+	}
+
+	if len(signers) == 0 {
+		return nil, ErrNoAuthMethods
+	}
+	return ssh.PublicKeys(signers...), nil
+}
+
+// KnownHosts returns a host key callback verifying host keys against the given
+// known_hosts files or ~/.ssh/known_hosts if none are given.
+func KnownHosts(files ...string) (ssh.HostKeyCallback, error) {
+	if len(files) == 0 {
+		files = []string{filepath.Join(sshDir(), "known_hosts")}
+	}
+	return knownhosts.New(files...)
+}
+
+// NewClientConfig returns a client config for user similar to what the
+// OpenSSH client uses by default: host keys are verified against
+// ~/.ssh/known_hosts and authentication uses the ssh-agent if there is one
+// followed by the default private keys (see [KeyFileAuth] for how prompt is
+// used).  If user is empty the current user is used.
+//
+// Other auth methods (i.e ssh.Password) can be appended to the Auth field of
+// the returned config.
+func NewClientConfig(username string, prompt PassphrasePrompt) (*ssh.ClientConfig, error) {
+	if username == "" {
+		cur, err := user.Current()
+		if err != nil {
+			return nil, fmt.Errorf("ssh: failed to get current user: %w", err)
+		}
+		username = cur.Username
+	}
+
+	hostKeyCallback, err := KnownHosts()
+	if err != nil {
+		return nil, fmt.Errorf("ssh: failed to load known hosts: %w", err)
+	}
+
+	config := &ssh.ClientConfig{
+		User:            username,
+		HostKeyCallback: hostKeyCallback,
+	}
+	if auth, err := AgentAuth(); err == nil {
+		config.Auth = append(config.Auth, auth)
+	}
+	auth, err := KeyFileAuth(prompt)
+	switch {
+	case err == nil:
+		config.Auth = append(config.Auth, auth)
+	case !errors.Is(err, ErrNoAuthMethods):
+		return nil, err
+	}
+	return config, nil
+}
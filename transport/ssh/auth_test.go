@@ -0,0 +1,145 @@
+package ssh
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func writeKey(t *testing.T, file string, passphrase string) {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	var block *pem.Block
+	if passphrase == "" {
+		block, err = ssh.MarshalPrivateKey(key, "")
+	} else {
+		block, err = ssh.MarshalPrivateKeyWithPassphrase(key, "", []byte(passphrase))
+	}
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(file, pem.EncodeToMemory(block), 0o600))
+}
+
+func TestKeyFileAuth(t *testing.T) {
+	dir := t.TempDir()
+	plain := filepath.Join(dir, "id_ed25519")
+	locked := filepath.Join(dir, "id_rsa")
+	writeKey(t, plain, "")
+	writeKey(t, locked, "secret")
+
+	_, err := KeyFileAuth(nil, filepath.Join(dir, "missing"))
+	assert.ErrorIs(t, err, ErrNoAuthMethods)
+
+	_, err = KeyFileAuth(nil, locked)
+	assert.ErrorIs(t, err, ErrNoAuthMethods)
+
+	auth, err := KeyFileAuth(nil, plain, locked)
+	require.NoError(t, err)
+	assert.NotNil(t, auth)
+
+	var prompted string
+	_, err = KeyFileAuth(func(file string) ([]byte, error) {
+		prompted = file
+		return []byte("secret"), nil
+	}, locked)
+	assert.NoError(t, err)
+	assert.Equal(t, locked, prompted)
+
+	_, err = KeyFileAuth(func(string) ([]byte, error) { return []byte("wrong"), nil }, locked)
+	assert.ErrorContains(t, err, "failed to parse private key")
+
+	_, err = KeyFileAuth(func(string) ([]byte, error) { return nil, errors.New("no tty") }, locked)
+	assert.ErrorContains(t, err, "no tty")
+}
+
+func TestAgentAuth(t *testing.T) {
+	t.Setenv("SSH_AUTH_SOCK", "")
+	_, err := AgentAuth()
+	assert.Error(t, err)
+
+	sock := filepath.Join(t.TempDir(), "agent.sock")
+	ln, err := net.Listen("unix", sock)
+	require.NoError(t, err)
+	defer ln.Close()
+
+	keyring := agent.NewKeyring()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	require.NoError(t, keyring.Add(agent.AddedKey{PrivateKey: key}))
+
+	accepted := make(chan net.Conn, 10)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- conn
+			go func() {
+				defer conn.Close()
+				_ = agent.ServeAgent(keyring, conn)
+			}()
+		}
+	}()
+
+	t.Setenv("SSH_AUTH_SOCK", sock)
+	auth1, err := AgentAuth()
+	require.NoError(t, err)
+	auth2, err := AgentAuth()
+	require.NoError(t, err)
+	assert.NotNil(t, auth1)
+	assert.NotNil(t, auth2)
+
+	// the connection to the agent is shared.
+	c, err := sharedAgent(sock)
+	require.NoError(t, err)
+	signers, err := c.client.Signers()
+	require.NoError(t, err)
+	assert.Len(t, signers, 1)
+	first := <-accepted
+	assert.Empty(t, accepted)
+
+	// a broken connection is redialed.
+	first.Close()
+	dropAgent(sock, c)
+	c, err = sharedAgent(sock)
+	require.NoError(t, err)
+	signers, err = c.client.Signers()
+	require.NoError(t, err)
+	assert.Len(t, signers, 1)
+	<-accepted
+	dropAgent(sock, c)
+}
+
+func TestNewClientConfig(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("SSH_AUTH_SOCK", "")
+
+	_, err := NewClientConfig("admin", nil)
+	assert.ErrorContains(t, err, "failed to load known hosts")
+
+	require.NoError(t, os.MkdirAll(filepath.Join(home, ".ssh"), 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(home, ".ssh", "known_hosts"), nil, 0o600))
+
+	config, err := NewClientConfig("admin", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "admin", config.User)
+	assert.Empty(t, config.Auth)
+
+	writeKey(t, filepath.Join(home, ".ssh", "id_ecdsa"), "")
+	config, err = NewClientConfig("admin", nil)
+	require.NoError(t, err)
+	assert.Len(t, config.Auth, 1)
+}
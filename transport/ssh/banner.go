@@ -0,0 +1,89 @@
+package ssh
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// DefaultBannerSize is the most text skipped before the hello by
+// [WithSkipBanner] when no size is given.
+const DefaultBannerSize = 64 * 1024
+
+type bannerOpt int
+
+func (o bannerOpt) apply(cfg *dialConfig) { cfg.banner = int(o) }
+
+// WithSkipBanner skips any text the device writes to the netconf subsystem
+// before the first `<` of the hello (i.e a MOTD or login banner) instead of
+// failing to parse it.  At most max bytes are skipped ([DefaultBannerSize]
+// if max is 0 or less).  The skipped text is available from
+// [Transport.Banner].
+func WithSkipBanner(max int) DialOption {
+	if max <= 0 {
+		max = DefaultBannerSize
+	}
+	return bannerOpt(max)
+}
+
+// Banner returns the text skipped before the hello when using
+// [WithSkipBanner].  It is only complete once the hello has been read (i.e
+// after netconf.Open returns).
+func (t *Transport) Banner() []byte {
+	if t.banner == nil {
+		return nil
+	}
+	return t.banner.skipped()
+}
+
+// bannerReader discards everything before the first `<` in the stream.
+type bannerReader struct {
+	r    *bufio.Reader
+	max  int
+	done bool
+
+	mu     sync.Mutex
+	banner []byte
+}
+
+func newBannerReader(r io.Reader, max int) *bannerReader {
+	return &bannerReader{r: bufio.NewReader(r), max: max}
+}
+
+func (b *bannerReader) Read(p []byte) (int, error) {
+	if err := b.skip(); err != nil {
+		return 0, err
+	}
+	return b.r.Read(p)
+}
+
+func (b *bannerReader) skip() error {
+	for !b.done {
+		c, err := b.r.ReadByte()
+		if err != nil {
+			return err
+		}
+		if c == '<' {
+			b.done = true
+			return b.r.UnreadByte()
+		}
+
+		b.mu.Lock()
+		full := len(b.banner) >= b.max
+		if !full {
+			b.banner = append(b.banner, c)
+		}
+		b.mu.Unlock()
+		if full {
+			return fmt.Errorf("ssh: no hello found in the first %d bytes", b.max)
+		}
+	}
+	return nil
+}
+
+func (b *bannerReader) skipped() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]byte(nil), b.banner...)
+}
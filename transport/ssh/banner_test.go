@@ -0,0 +1,55 @@
+package ssh
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestBannerReader(t *testing.T) {
+	r := newBannerReader(strings.NewReader("Welcome to router1\r\nUnauthorized access prohibited\r\n<hello/>]]>]]>"), 1024)
+	data, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "<hello/>]]>]]>", string(data))
+	assert.Equal(t, "Welcome to router1\r\nUnauthorized access prohibited\r\n", string(r.skipped()))
+
+	r = newBannerReader(strings.NewReader("<hello/>"), 1024)
+	data, err = io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "<hello/>", string(data))
+	assert.Empty(t, r.skipped())
+
+	r = newBannerReader(strings.NewReader("too much noise <hello/>"), 4)
+	_, err = io.ReadAll(r)
+	assert.ErrorContains(t, err, "no hello found in the first 4 bytes")
+}
+
+func TestDialSkipBanner(t *testing.T) {
+	server, err := newTestServer(t, func(t *testing.T, ch ssh.Channel, reqs <-chan *ssh.Request) {
+		go func() {
+			for req := range reqs {
+				_ = req.Reply(req.Type == "subsystem", nil)
+			}
+		}()
+		_, _ = io.WriteString(ch, "*** authorized users only ***\n<hello/>]]>]]>")
+		_, _ = io.Copy(io.Discard, ch)
+	})
+	require.NoError(t, err)
+
+	config := &ssh.ClientConfig{HostKeyCallback: ssh.InsecureIgnoreHostKey()}
+	tr, err := Dial(context.Background(), "tcp", server.addr.String(), config, WithSkipBanner(0))
+	require.NoError(t, err)
+	defer tr.Close()
+
+	r, err := tr.MsgReader()
+	require.NoError(t, err)
+	msg, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "<hello/>", string(msg))
+	assert.Equal(t, "*** authorized users only ***\n", string(tr.Banner()))
+}
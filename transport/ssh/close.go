@@ -0,0 +1,80 @@
+package ssh
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+type closeTimeoutOpt time.Duration
+
+func (o closeTimeoutOpt) apply(cfg *dialConfig) { cfg.closeTimeout = time.Duration(o) }
+
+// WithCloseTimeout makes Close wait up to d for the device to close the
+// channel after sending it an EOF before closing it itself.  Some devices log
+// an error and hold on to the session slot for a while if the channel is
+// closed before they have ended the session.  Waiting also allows the exit
+// status of the netconf subsystem to be returned from Close.  By default
+// Close doesn't wait.
+func WithCloseTimeout(d time.Duration) DialOption { return closeTimeoutOpt(d) }
+
+// ExitError is the exit status or signal of the netconf subsystem reported by
+// the device when the channel is closed.
+type ExitError struct {
+	// Status is the exit status or -1 if the device only reported a signal.
+	Status int
+
+	// Signal is the name of the signal (without the SIG prefix) that ended
+	// the subsystem if any.
+	Signal string
+
+	// Msg is the error message sent with the signal.
+	Msg string
+}
+
+func (e *ExitError) Error() string {
+	if e.Signal != "" {
+		if e.Msg != "" {
+			return fmt.Sprintf("ssh: exited on signal %s: %s", e.Signal, e.Msg)
+		}
+		return "ssh: exited on signal " + e.Signal
+	}
+	return fmt.Sprintf("ssh: exited with status %d", e.Status)
+}
+
+// wait handles the requests sent by the device on the channel until it is
+// closed recording the exit status.
+func (t *Transport) wait(reqs <-chan *ssh.Request) {
+	exit := ExitError{Status: -1}
+	for req := range reqs {
+		switch req.Type {
+		case "exit-status":
+			if len(req.Payload) >= 4 {
+				exit.Status = int(binary.BigEndian.Uint32(req.Payload))
+			}
+		case "exit-signal":
+			var sig struct {
+				Signal     string
+				CoreDumped bool
+				Error      string
+				Lang       string
+			}
+			if err := ssh.Unmarshal(req.Payload, &sig); err == nil {
+				exit.Signal = sig.Signal
+				exit.Msg = sig.Error
+			}
+		default:
+			if req.WantReply {
+				_ = req.Reply(false, nil)
+			}
+		}
+	}
+
+	// not sending an exit status is allowed and common for subsystems.
+	if exit.Status > 0 || exit.Signal != "" {
+		t.exitErr = &exit
+	}
+	close(t.exited)
+}
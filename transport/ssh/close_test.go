@@ -0,0 +1,88 @@
+package ssh
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestCloseExitStatus(t *testing.T) {
+	server, err := newTestServer(t, func(t *testing.T, ch ssh.Channel, reqs <-chan *ssh.Request) {
+		discardHandler(t, ch, reqs)
+		_, _ = ch.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{1}))
+	})
+	require.NoError(t, err)
+
+	config := &ssh.ClientConfig{
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	}
+	tr, err := Dial(context.Background(), "tcp", server.addr.String(), config,
+		WithCloseTimeout(5*time.Second))
+	require.NoError(t, err)
+
+	err = tr.Close()
+	var exitErr *ExitError
+	require.ErrorAs(t, err, &exitErr)
+	assert.Equal(t, 1, exitErr.Status)
+
+	// closing again returns the same error.
+	assert.Equal(t, err, tr.Close())
+}
+
+func TestCloseTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	server, err := newTestServer(t, func(t *testing.T, ch ssh.Channel, reqs <-chan *ssh.Request) {
+		go func() {
+			for req := range reqs {
+				_ = req.Reply(req.Type == "subsystem", nil)
+			}
+		}()
+		// ignore the EOF and keep the channel open.
+		_, _ = io.Copy(io.Discard, ch)
+		<-release
+	})
+	require.NoError(t, err)
+
+	config := &ssh.ClientConfig{
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	}
+	tr, err := Dial(context.Background(), "tcp", server.addr.String(), config,
+		WithCloseTimeout(50*time.Millisecond))
+	require.NoError(t, err)
+
+	start := time.Now()
+	assert.NoError(t, tr.Close())
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestCloseNoWait(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	server, err := newTestServer(t, func(t *testing.T, ch ssh.Channel, reqs <-chan *ssh.Request) {
+		go func() {
+			for req := range reqs {
+				_ = req.Reply(req.Type == "subsystem", nil)
+			}
+		}()
+		_, _ = io.Copy(io.Discard, ch)
+		<-release
+	})
+	require.NoError(t, err)
+
+	config := &ssh.ClientConfig{
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	}
+	tr, err := Dial(context.Background(), "tcp", server.addr.String(), config)
+	require.NoError(t, err)
+
+	// by default the channel is closed without waiting for the device.
+	start := time.Now()
+	assert.NoError(t, tr.Close())
+	assert.Less(t, time.Since(start), time.Second)
+}
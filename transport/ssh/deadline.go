@@ -0,0 +1,106 @@
+package ssh
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// deadlineChannel adds read and write deadlines to an ssh channel.  A read or
+// write blocked on a channel (i.e on the window of a stalled device) can't be
+// interrupted without closing it, so the channel is closed once a deadline
+// expires and the transport can't be used anymore.  Reads and writes then
+// fail with os.ErrDeadlineExceeded.
+type deadlineChannel struct {
+	ch ssh.Channel
+
+	mu    sync.Mutex
+	read  deadline
+	write deadline
+}
+
+type deadline struct {
+	timer   *time.Timer
+	expired bool
+}
+
+func (c *deadlineChannel) Read(p []byte) (int, error) {
+	if c.expired(&c.read) {
+		return 0, os.ErrDeadlineExceeded
+	}
+	n, err := c.ch.Read(p)
+	if err != nil && c.expired(&c.read) {
+		err = os.ErrDeadlineExceeded
+	}
+	return n, err
+}
+
+func (c *deadlineChannel) Write(p []byte) (int, error) {
+	if c.expired(&c.write) {
+		return 0, os.ErrDeadlineExceeded
+	}
+	n, err := c.ch.Write(p)
+	if err != nil && c.expired(&c.write) {
+		err = os.ErrDeadlineExceeded
+	}
+	return n, err
+}
+
+func (c *deadlineChannel) expired(d *deadline) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return d.expired
+}
+
+// set sets d to expire at t.  A zero t clears it.
+func (c *deadlineChannel) set(d *deadline, t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+	d.expired = false
+	if t.IsZero() {
+		return
+	}
+
+	until := time.Until(t)
+	if until <= 0 {
+		d.expired = true
+		c.ch.Close()
+		return
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(until, func() {
+		c.mu.Lock()
+		if d.timer != timer {
+			// reset in the meantime.
+			c.mu.Unlock()
+			return
+		}
+		d.expired = true
+		c.mu.Unlock()
+		c.ch.Close()
+	})
+	d.timer = timer
+}
+
+// SetReadDeadline sets the deadline for reading messages.  As an ssh channel
+// can't interrupt a blocked read the channel is closed once the deadline
+// expires.
+func (t *Transport) SetReadDeadline(d time.Time) error {
+	t.deadlines.set(&t.deadlines.read, d)
+	return nil
+}
+
+// SetWriteDeadline sets the deadline for writing messages.  As an ssh channel
+// can't interrupt a blocked write (i.e waiting for the device to open its
+// window) the channel is closed once the deadline expires.
+func (t *Transport) SetWriteDeadline(d time.Time) error {
+	t.deadlines.set(&t.deadlines.write, d)
+	return nil
+}
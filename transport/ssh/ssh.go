@@ -2,9 +2,13 @@ package ssh
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net"
+	"net/url"
+	"sync"
+	"time"
 
 	"github.com/nemith/netconf/transport"
 	"golang.org/x/crypto/ssh"
@@ -13,20 +17,137 @@ import (
 // alias it to a private type so we can make it private when embedding
 type framer = transport.Framer //nolint:golint,unused
 
+// DefaultPort is the IANA assigned port for NETCONF over SSH.  It is used by
+// Dial when the address does not contain a port.
+const DefaultPort = 830
+
 // Transport implements RFC6242 for implementing NETCONF protocol over SSH.
 type Transport struct {
-	c     *ssh.Client
-	sess  *ssh.Session
-	stdin io.WriteCloser
+	c  *ssh.Client
+	ch ssh.Channel
 
 	// set to true if the transport is managing the underlying ssh connection
 	// and should close it when the transport is closed.  This is is set to true
 	// when used with `Dial`.
 	managed bool
 
+	// jump host clients used to reach the device.  They are closed with the
+	// transport.
+	jumps []*ssh.Client
+
+	// hostKey is the key the server presented when the handshake was done by
+	// Dial or Handshake.
+	hostKey ssh.PublicKey
+
+	banner    *bannerReader
+	deadlines *deadlineChannel
+
+	closeTimeout time.Duration
+	exited       chan struct{}
+	exitErr      error
+	closeOnce    sync.Once
+	closeErr     error
+
 	*framer
 }
 
+// SRVService is the service name used to lookup NETCONF over SSH endpoints
+// in DNS SRV records.
+const SRVService = "netconf-ssh"
+
+// DialOption is an optional argument to [Dial].
+type DialOption interface {
+	apply(*dialConfig)
+}
+
+type dialConfig struct {
+	dialer   transport.ContextDialer
+	resolver transport.Resolver
+	socket   *transport.SocketOptions
+	proxy    *url.URL
+	jumps    []jumpHost
+	banner   int
+	framer   []transport.FramerOption
+
+	closeTimeout time.Duration
+}
+
+type dialerOpt struct{ d transport.ContextDialer }
+
+func (o dialerOpt) apply(cfg *dialConfig) { cfg.dialer = o.d }
+
+// WithDialer sets the dialer used to establish the underlying connection.  By
+// default a net.Dialer using the timeout from the ssh.ClientConfig is used.
+func WithDialer(d transport.ContextDialer) DialOption { return dialerOpt{d} }
+
+type socketOpt transport.SocketOptions
+
+func (o socketOpt) apply(cfg *dialConfig) {
+	so := transport.SocketOptions(o)
+	cfg.socket = &so
+}
+
+// WithSocketOptions tunes the TCP connection (keepalive interval, DSCP marking
+// and source address).  It is ignored when a dialer is given with
+// [WithDialer].
+func WithSocketOptions(o transport.SocketOptions) DialOption { return socketOpt(o) }
+
+type proxyOpt struct{ u *url.URL }
+
+func (o proxyOpt) apply(cfg *dialConfig) { cfg.proxy = o.u }
+
+// WithProxy connects through the SOCKS5 or HTTP CONNECT proxy at u (see
+// [transport.NewProxyDialer]).  The proxy is connected to with the dialer from
+// [WithDialer] or [WithSocketOptions].  With jump hosts the proxy is used to
+// reach the first jump host.
+func WithProxy(u *url.URL) DialOption { return proxyOpt{u} }
+
+type resolverOpt struct{ r transport.Resolver }
+
+func (o resolverOpt) apply(cfg *dialConfig) { cfg.resolver = o.r }
+
+// WithResolver sets a resolver used to find the addresses to connect to for
+// the address given to Dial.  The addresses are tried in order until a
+// connection succeeds.  The host key is still verified against the address
+// given to Dial.
+//
+// To discover devices with DNS SRV records use:
+//
+//	ssh.Dial(ctx, "tcp", "router1.example.com", config,
+//		ssh.WithResolver(&transport.SRVResolver{Service: ssh.SRVService}))
+func WithResolver(r transport.Resolver) DialOption { return resolverOpt{r} }
+
+// JumpPort is the port used for jump hosts when the address does not contain
+// one.
+const JumpPort = 22
+
+type jumpHost struct {
+	addr   string
+	config *ssh.ClientConfig
+}
+
+func (o jumpHost) apply(cfg *dialConfig) { cfg.jumps = append(cfg.jumps, o) }
+
+// WithJumpHost connects to the device through the ssh server at addr like the
+// OpenSSH ProxyJump option.  It can be given multiple times to chain through
+// several jump hosts in order with each hop connected through the one before
+// it.  Each hop uses its own client config.  If addr does not contain a port
+// then [JumpPort] is used.
+//
+// The resolver set with [WithResolver] is only used for the device itself and
+// its addresses are dialed through the last jump host.
+func WithJumpHost(addr string, config *ssh.ClientConfig) DialOption {
+	return jumpHost{addr: addr, config: config}
+}
+
+type framerOpt []transport.FramerOption
+
+func (o framerOpt) apply(cfg *dialConfig) { cfg.framer = append(cfg.framer, o...) }
+
+// WithFramerOptions passes options to the framer of the transport, i.e to
+// set the buffer sizes with [transport.WithReadBufferSize].
+func WithFramerOptions(opts ...transport.FramerOption) DialOption { return framerOpt(opts) }
+
 // Dial will connect to a ssh server and issues a transport, it's used as a
 // convenience function as essentially is the same as
 //
@@ -35,13 +156,134 @@ type Transport struct {
 //	 	t, err := NewTransport(c)
 //
 // When the transport is closed the underlying connection is also closed.
-func Dial(ctx context.Context, network, addr string, config *ssh.ClientConfig) (*Transport, error) {
-	d := net.Dialer{Timeout: config.Timeout}
-	conn, err := d.DialContext(ctx, network, addr)
+//
+// If addr does not contain a port then [DefaultPort] is used.  IPv6 literals
+// can be given with or without brackets.
+func Dial(ctx context.Context, network, addr string, config *ssh.ClientConfig, opts ...DialOption) (*Transport, error) {
+	var cfg dialConfig
+	for _, opt := range opts {
+		opt.apply(&cfg)
+	}
+	if cfg.dialer == nil {
+		so := transport.SocketOptions{Timeout: config.Timeout}
+		if cfg.socket != nil {
+			so = *cfg.socket
+			if so.Timeout == 0 {
+				so.Timeout = config.Timeout
+			}
+		}
+		d, err := so.Dialer()
+		if err != nil {
+			return nil, err
+		}
+		cfg.dialer = d
+	}
+	if cfg.proxy != nil {
+		d, err := transport.NewProxyDialer(cfg.proxy, cfg.dialer)
+		if err != nil {
+			return nil, err
+		}
+		cfg.dialer = d
+	}
+
+	addrs, err := transport.Resolve(ctx, cfg.resolver, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	for i := range addrs {
+		addrs[i] = transport.JoinDefaultPort(addrs[i], DefaultPort)
+	}
+	addr = transport.JoinDefaultPort(addr, DefaultPort)
+
+	dialer := cfg.dialer
+	var jumps []*ssh.Client
+	closeJumps := func() {
+		for i := len(jumps) - 1; i >= 0; i-- {
+			jumps[i].Close()
+		}
+	}
+	for _, hop := range cfg.jumps {
+		hopAddr := transport.JoinDefaultPort(hop.addr, JumpPort)
+		conn, err := dialer.DialContext(ctx, network, hopAddr)
+		if err != nil {
+			closeJumps()
+			return nil, fmt.Errorf("failed to connect to jump host %q: %w", hop.addr, err)
+		}
+		client, _, err := clientHandshake(ctx, conn, hopAddr, hop.config)
+		if err != nil {
+			closeJumps()
+			return nil, fmt.Errorf("failed to connect to jump host %q: %w", hop.addr, err)
+		}
+		jumps = append(jumps, client)
+		dialer = client
+	}
+
+	conn, err := transport.DialFirst(ctx, dialer, network, addrs)
 	if err != nil {
+		closeJumps()
 		return nil, err
 	}
 
+	client, hostKey, err := clientHandshake(ctx, conn, addr, config)
+	if err != nil {
+		closeJumps()
+		return nil, err
+	}
+
+	t, err := newTransport(client, true, cfg)
+	if err != nil {
+		client.Close()
+		closeJumps()
+		return nil, err
+	}
+	t.jumps = jumps
+	t.hostKey = hostKey
+	return t, nil
+}
+
+// Handshake establishes a transport over conn which is already connected to
+// the device.  This is used for call home (RFC8071) where the device opens the
+// TCP connection and then acts as the ssh server.  The host key is verified
+// against the remote address of conn.  Like Dial, conn is closed when the
+// transport is closed.  Options for dialing (i.e [WithDialer]) are ignored.
+func Handshake(ctx context.Context, conn net.Conn, config *ssh.ClientConfig, opts ...DialOption) (*Transport, error) {
+	var cfg dialConfig
+	for _, opt := range opts {
+		opt.apply(&cfg)
+	}
+
+	client, hostKey, err := clientHandshake(ctx, conn, conn.RemoteAddr().String(), config)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	t, err := newTransport(client, true, cfg)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	t.hostKey = hostKey
+	return t, nil
+}
+
+// clientHandshake establishes a ssh connection over conn closing conn if ctx is
+// done before the handshake completes.  The host key presented by the server
+// is returned with the client.
+func clientHandshake(ctx context.Context, conn net.Conn, addr string, config *ssh.ClientConfig) (*ssh.Client, ssh.PublicKey, error) {
+	var hostKey ssh.PublicKey
+	if cb := config.HostKeyCallback; cb != nil {
+		c := *config
+		c.HostKeyCallback = func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			if err := cb(hostname, remote, key); err != nil {
+				return err
+			}
+			hostKey = key
+			return nil
+		}
+		config = &c
+	}
+
 	// Setup a go routine to monitor the context and close the connection.  This
 	// is needed as the underlying ssh library doesn't support contexts so this
 	// approximates a context based cancelation/timeout for the ssh handshake.
@@ -61,79 +303,159 @@ func Dial(ctx context.Context, network, addr string, config *ssh.ClientConfig) (
 	}()
 
 	sshConn, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	close(done) // make sure we cleanup the context monitor routine
 	if err != nil {
 		// if there is a context timeout return that error instead of the actual
 		// error from ssh.NewClientConn.
 		if ctx.Err() != nil {
-			return nil, ctx.Err()
+			return nil, nil, ctx.Err()
 		}
-		return nil, err
+		return nil, nil, err
 	}
-	close(done) // make sure we cleanup the context monitor routine
 
-	client := ssh.NewClient(sshConn, chans, reqs)
-	return newTransport(client, true)
+	return ssh.NewClient(sshConn, chans, reqs), hostKey, nil
 }
 
 // NewTransport will create a new ssh transport as defined in RFC6242 for use
 // with netconf.  Unlike Dial, the underlying client will not be automatically
 // closed when the transport is closed (however any sessions and subsystems
-// are still closed).
-func NewTransport(client *ssh.Client) (*Transport, error) {
-	return newTransport(client, false)
+// are still closed).  Only options affecting the netconf subsystem (i.e
+// [WithSkipBanner], [WithFramerOptions] and [WithCloseTimeout]) are used.
+func NewTransport(client *ssh.Client, opts ...DialOption) (*Transport, error) {
+	var cfg dialConfig
+	for _, opt := range opts {
+		opt.apply(&cfg)
+	}
+	return newTransport(client, false, cfg)
 }
 
-func newTransport(client *ssh.Client, managed bool) (*Transport, error) {
-	sess, err := client.NewSession()
+func newTransport(client *ssh.Client, managed bool, cfg dialConfig) (*Transport, error) {
+	// The session channel is used directly rather than an ssh.Session as the
+	// latter only reports the exit status for commands, not subsystems.
+	ch, reqs, err := client.OpenChannel("session", nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create ssh session: %w", err)
 	}
 
-	w, err := sess.StdinPipe()
-	if err != nil {
-		return nil, fmt.Errorf("failed to create stdin pipe: %w", err)
+	t := &Transport{
+		c:            client,
+		ch:           ch,
+		managed:      managed,
+		closeTimeout: cfg.closeTimeout,
+		exited:       make(chan struct{}),
 	}
+	go t.wait(reqs)
+	// stderr isn't used by netconf but has to be read so it doesn't stall the
+	// channel.
+	go func() { _, _ = io.Copy(io.Discard, ch.Stderr()) }()
 
-	r, err := sess.StdoutPipe()
+	const subsystem = "netconf"
+	ok, err := ch.SendRequest("subsystem", true, ssh.Marshal(&struct{ Name string }{subsystem}))
+	if err == nil && !ok {
+		err = errors.New("ssh: subsystem request failed")
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to create stdout pipe: %w", err)
+		ch.Close()
+		return nil, fmt.Errorf("failed to start netconf ssh subsytem: %w", err)
 	}
 
-	const subsystem = "netconf"
-	if err := sess.RequestSubsystem(subsystem); err != nil {
-		return nil, fmt.Errorf("failed to start netconf ssh subsytem: %w", err)
+	t.deadlines = &deadlineChannel{ch: ch}
+	var r io.Reader = t.deadlines
+	if cfg.banner > 0 {
+		t.banner = newBannerReader(r, cfg.banner)
+		r = t.banner
 	}
+	t.framer = transport.NewFramer(r, t.deadlines, cfg.framer...)
+	return t, nil
+}
+
+// RemoteAddr returns the remote address of the underlying ssh connection.
+func (t *Transport) RemoteAddr() net.Addr {
+	return t.c.RemoteAddr()
+}
+
+// HostKey returns the host key the server presented and was accepted by the
+// HostKeyCallback.  It is nil for transports created with [NewTransport] as
+// the handshake was done by the caller.
+func (t *Transport) HostKey() ssh.PublicKey {
+	return t.hostKey
+}
 
-	return &Transport{
-		c:       client,
-		managed: managed,
-		sess:    sess,
-		stdin:   w,
+// keepaliveRequest is the global request sent by Ping.  Like OpenSSH's
+// ServerAliveInterval any reply, even a failure, shows the server is alive.
+const keepaliveRequest = "keepalive@openssh.com"
 
-		framer: transport.NewFramer(r, w),
-	}, nil
+// Ping sends a keepalive request over the ssh connection and waits for the
+// server to reply or ctx to be done.
+func (t *Transport) Ping(ctx context.Context) error {
+	errCh := make(chan error, 1)
+	go func() {
+		_, _, err := t.c.SendRequest(keepaliveRequest, true, nil)
+		errCh <- err
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
-// Close will close the underlying transport.  If the connection was created
-// with Dial then then underlying ssh.Client is closed as well.  If not only
-// the sessions is closed.
+// Close will close the underlying transport.  It sends an EOF to the device
+// and, if set with [WithCloseTimeout], waits for the device to close the
+// channel so it can release the session cleanly.  If the netconf subsystem
+// has exited with a non-zero status an error wrapping an [*ExitError] is
+// returned.
+//
+// If the connection was created with Dial then then underlying ssh.Client is
+// closed as well.  If not only the channel is closed.
 func (t *Transport) Close() error {
+	t.closeOnce.Do(func() { t.closeErr = t.close() })
+	return t.closeErr
+}
+
+func (t *Transport) close() error {
 	// TODO: in go 1.20 this could easily be an errors.Join() but for now we
 	// will save previous errors but try to close everything returning just the
 	// "lowest" abstraction layer error
 	var retErr error
 
-	if err := t.stdin.Close(); err != nil {
+	// io.EOF is returned once the device has closed the channel.
+	if err := t.ch.CloseWrite(); err != nil && !errors.Is(err, io.EOF) {
 		retErr = fmt.Errorf("failed to close ssh stdin: %w", err)
 	}
 
-	if err := t.sess.Close(); err != nil {
+	if t.closeTimeout > 0 {
+		timer := time.NewTimer(t.closeTimeout)
+		select {
+		case <-t.exited:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+
+	select {
+	case <-t.exited:
+		if t.exitErr != nil {
+			retErr = fmt.Errorf("netconf subsystem failed: %w", t.exitErr)
+		}
+	default:
+	}
+
+	if err := t.ch.Close(); err != nil && !errors.Is(err, io.EOF) {
 		retErr = fmt.Errorf("failed to close ssh channel: %w", err)
 	}
 
 	if t.managed {
 		if err := t.c.Close(); err != nil {
-			return fmt.Errorf("failed to close ssh connnection: %w", t.c.Close())
+			retErr = fmt.Errorf("failed to close ssh connnection: %w", err)
+		}
+	}
+
+	for i := len(t.jumps) - 1; i >= 0; i-- {
+		if err := t.jumps[i].Close(); err != nil {
+			retErr = fmt.Errorf("failed to close jump host connection: %w", err)
 		}
 	}
 
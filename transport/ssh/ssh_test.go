@@ -7,8 +7,11 @@ import (
 	"io"
 	"log"
 	"net"
+	"os"
 	"testing"
+	"time"
 
+	"github.com/nemith/netconf/transport"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/crypto/ssh"
@@ -91,6 +94,9 @@ func newTestServer(t *testing.T, handlerFn func(*testing.T, ssh.Channel, <-chan
 			}
 
 			handlerFn(t, ch, reqs)
+			// close the channel once the handler is done like a device
+			// ending the session.
+			ch.Close()
 		}
 	}()
 
@@ -99,6 +105,17 @@ func newTestServer(t *testing.T, handlerFn func(*testing.T, ssh.Channel, <-chan
 	}, nil
 }
 
+// discardHandler accepts the netconf subsystem and discards everything sent
+// to it.
+func discardHandler(t *testing.T, ch ssh.Channel, reqs <-chan *ssh.Request) {
+	go func() {
+		for req := range reqs {
+			_ = req.Reply(req.Type == "subsystem", nil)
+		}
+	}()
+	_, _ = io.Copy(io.Discard, ch)
+}
+
 func TestTransport(t *testing.T) {
 	var (
 		srvIn bytes.Buffer
@@ -151,3 +168,220 @@ func TestTransport(t *testing.T) {
 	want := out + "\n]]>]]>"
 	assert.Equal(t, want, srvIn.String())
 }
+
+func TestDialResolver(t *testing.T) {
+	server, err := newTestServer(t, discardHandler)
+	require.NoError(t, err)
+
+	// grab a free port that nothing is listening on
+	ln, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	deadAddr := ln.Addr().String()
+	ln.Close()
+
+	var resolved string
+	resolver := transport.ResolverFunc(func(ctx context.Context, network, addr string) ([]string, error) {
+		resolved = addr
+		return []string{deadAddr, server.addr.String()}, nil
+	})
+
+	var hostname string
+	config := &ssh.ClientConfig{
+		HostKeyCallback: func(h string, remote net.Addr, key ssh.PublicKey) error {
+			hostname = h
+			return nil
+		},
+	}
+	tr, err := Dial(context.Background(), "tcp", "router1", config, WithResolver(resolver))
+	require.NoError(t, err)
+	defer tr.Close()
+
+	assert.Equal(t, "router1", resolved)
+	assert.Equal(t, "router1:830", hostname)
+	assert.Equal(t, server.addr.String(), tr.RemoteAddr().String())
+}
+
+// newJumpServer starts a ssh server that only forwards direct-tcpip channels
+// like a bastion host.
+func newJumpServer(t *testing.T) net.Addr {
+	config := &ssh.ServerConfig{
+		NoClientAuth: true,
+	}
+	key, err := ssh.ParsePrivateKey([]byte(hostkey))
+	require.NoError(t, err)
+	config.AddHostKey(key)
+
+	ln, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		nconn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		_, chans, reqs, err := ssh.NewServerConn(nconn, config)
+		if err != nil {
+			return
+		}
+		go ssh.DiscardRequests(reqs)
+
+		for newChannel := range chans {
+			if newChannel.ChannelType() != "direct-tcpip" {
+				_ = newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+				continue
+			}
+
+			var target struct {
+				Host     string
+				Port     uint32
+				OrigHost string
+				OrigPort uint32
+			}
+			if err := ssh.Unmarshal(newChannel.ExtraData(), &target); err != nil {
+				_ = newChannel.Reject(ssh.ConnectionFailed, err.Error())
+				continue
+			}
+			conn, err := net.Dial("tcp", net.JoinHostPort(target.Host, fmt.Sprint(target.Port)))
+			if err != nil {
+				_ = newChannel.Reject(ssh.ConnectionFailed, err.Error())
+				continue
+			}
+			ch, chReqs, err := newChannel.Accept()
+			if err != nil {
+				conn.Close()
+				continue
+			}
+			go ssh.DiscardRequests(chReqs)
+			go func() {
+				_, _ = io.Copy(ch, conn)
+				ch.Close()
+			}()
+			go func() {
+				_, _ = io.Copy(conn, ch)
+				conn.Close()
+			}()
+		}
+	}()
+
+	return ln.Addr()
+}
+
+func TestDialJumpHost(t *testing.T) {
+	server, err := newTestServer(t, discardHandler)
+	require.NoError(t, err)
+
+	jump1, jump2 := newJumpServer(t), newJumpServer(t)
+
+	var hosts []string
+	newConfig := func(user string) *ssh.ClientConfig {
+		return &ssh.ClientConfig{
+			User: user,
+			HostKeyCallback: func(h string, remote net.Addr, key ssh.PublicKey) error {
+				hosts = append(hosts, h)
+				return nil
+			},
+		}
+	}
+
+	tr, err := Dial(context.Background(), "tcp", server.addr.String(), newConfig("admin"),
+		WithJumpHost(jump1.String(), newConfig("jump1")),
+		WithJumpHost(jump2.String(), newConfig("jump2")))
+	require.NoError(t, err)
+	assert.Equal(t, []string{jump1.String(), jump2.String(), server.addr.String()}, hosts)
+	assert.Len(t, tr.jumps, 2)
+	assert.NoError(t, tr.Close())
+}
+
+func TestDialJumpHostFailed(t *testing.T) {
+	ln, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	deadAddr := ln.Addr().String()
+	ln.Close()
+
+	config := &ssh.ClientConfig{HostKeyCallback: ssh.InsecureIgnoreHostKey()}
+	_, err = Dial(context.Background(), "tcp", "router1", config, WithJumpHost(deadAddr, config))
+	assert.ErrorContains(t, err, "failed to connect to jump host")
+}
+
+func TestDeadline(t *testing.T) {
+	server, err := newTestServer(t, discardHandler)
+	require.NoError(t, err)
+
+	config := &ssh.ClientConfig{
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	}
+	tr, err := Dial(context.Background(), "tcp", server.addr.String(), config)
+	require.NoError(t, err)
+	defer tr.Close()
+
+	var _ transport.Deadliner = tr
+
+	// a deadline that is cleared in time doesn't affect the channel.
+	require.NoError(t, tr.SetReadDeadline(time.Now().Add(time.Hour)))
+	require.NoError(t, tr.SetReadDeadline(time.Time{}))
+
+	// the device never sends anything so the read is blocked until the
+	// deadline.
+	require.NoError(t, tr.SetReadDeadline(time.Now().Add(20*time.Millisecond)))
+	r, err := tr.MsgReader()
+	require.NoError(t, err)
+	_, err = io.ReadAll(r)
+	assert.ErrorIs(t, err, os.ErrDeadlineExceeded)
+
+	// the channel is unusable once a deadline expired.
+	require.NoError(t, tr.SetWriteDeadline(time.Unix(1, 0)))
+	w, err := tr.MsgWriter()
+	require.NoError(t, err)
+	_, err = io.WriteString(w, "<rpc/>")
+	if err == nil {
+		err = w.Close()
+	}
+	assert.ErrorIs(t, err, os.ErrDeadlineExceeded)
+}
+
+func TestPing(t *testing.T) {
+	server, err := newTestServer(t, discardHandler)
+	require.NoError(t, err)
+
+	config := &ssh.ClientConfig{
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	}
+	tr, err := Dial(context.Background(), "tcp", server.addr.String(), config)
+	require.NoError(t, err)
+
+	// the server rejects the keepalive request which still shows it's alive.
+	assert.NoError(t, tr.Ping(context.Background()))
+
+	require.NoError(t, tr.Close())
+	assert.Error(t, tr.Ping(context.Background()))
+}
+
+func TestHandshake(t *testing.T) {
+	server, err := newTestServer(t, discardHandler)
+	require.NoError(t, err)
+
+	conn, err := net.Dial("tcp", server.addr.String())
+	require.NoError(t, err)
+
+	var (
+		hostname string
+		hostKey  ssh.PublicKey
+	)
+	config := &ssh.ClientConfig{
+		HostKeyCallback: func(h string, remote net.Addr, key ssh.PublicKey) error {
+			hostname, hostKey = h, key
+			return nil
+		},
+	}
+	tr, err := Handshake(context.Background(), conn, config)
+	require.NoError(t, err)
+	assert.Equal(t, server.addr.String(), hostname)
+	require.NotNil(t, tr.HostKey())
+	assert.Equal(t, hostKey.Marshal(), tr.HostKey().Marshal())
+	require.NoError(t, tr.Close())
+
+	// the connection is closed with the transport.
+	_, err = conn.Write([]byte("x"))
+	assert.Error(t, err)
+}
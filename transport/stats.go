@@ -0,0 +1,102 @@
+package transport
+
+import (
+	"io"
+	"sync/atomic"
+)
+
+// Stats are counters for the traffic through a [Framer] since it was created.
+type Stats struct {
+	// BytesRead and BytesWritten count the bytes on the wire including the
+	// framing.
+	BytesRead    uint64
+	BytesWritten uint64
+
+	// MsgsRead and MsgsWritten count complete messages.
+	MsgsRead    uint64
+	MsgsWritten uint64
+
+	// ChunksRead and ChunksWritten count chunks when using chunked framing.
+	ChunksRead    uint64
+	ChunksWritten uint64
+
+	// FramingErrors counts malformed or too large chunk headers that were
+	// read.
+	FramingErrors uint64
+}
+
+// frameStats holds the counters of a Framer.  The methods used by the frame
+// readers and writers are safe to call on a nil *frameStats so that they can
+// be used on their own.
+type frameStats struct {
+	bytesRead, bytesWritten   atomic.Uint64
+	msgsRead, msgsWritten     atomic.Uint64
+	chunksRead, chunksWritten atomic.Uint64
+	framingErrors             atomic.Uint64
+}
+
+func (s *frameStats) msgRead() {
+	if s != nil {
+		s.msgsRead.Add(1)
+	}
+}
+
+func (s *frameStats) msgWritten() {
+	if s != nil {
+		s.msgsWritten.Add(1)
+	}
+}
+
+func (s *frameStats) chunkRead() {
+	if s != nil {
+		s.chunksRead.Add(1)
+	}
+}
+
+func (s *frameStats) chunkWritten() {
+	if s != nil {
+		s.chunksWritten.Add(1)
+	}
+}
+
+func (s *frameStats) framingError() {
+	if s != nil {
+		s.framingErrors.Add(1)
+	}
+}
+
+func (s *frameStats) snapshot() Stats {
+	return Stats{
+		BytesRead:     s.bytesRead.Load(),
+		BytesWritten:  s.bytesWritten.Load(),
+		MsgsRead:      s.msgsRead.Load(),
+		MsgsWritten:   s.msgsWritten.Load(),
+		ChunksRead:    s.chunksRead.Load(),
+		ChunksWritten: s.chunksWritten.Load(),
+		FramingErrors: s.framingErrors.Load(),
+	}
+}
+
+// countingReader counts the bytes read from r.
+type countingReader struct {
+	r     io.Reader
+	stats *frameStats
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.stats.bytesRead.Add(uint64(n))
+	return n, err
+}
+
+// countingWriter counts the bytes written to w.
+type countingWriter struct {
+	w     io.Writer
+	stats *frameStats
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.stats.bytesWritten.Add(uint64(n))
+	return n, err
+}
@@ -0,0 +1,51 @@
+package transport
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFramerStats(t *testing.T) {
+	const input = "hello]]>]]>\n#3\nfoo\n#3\nbar\n##\n\n#big\n"
+
+	var out bytes.Buffer
+	f := NewFramer(strings.NewReader(input), &out)
+
+	readMsg := func() error {
+		r, err := f.MsgReader()
+		require.NoError(t, err)
+		_, err = io.ReadAll(r)
+		return err
+	}
+	writeMsg := func(chunks ...string) {
+		w, err := f.MsgWriter()
+		require.NoError(t, err)
+		for _, c := range chunks {
+			_, err := io.WriteString(w, c)
+			require.NoError(t, err)
+		}
+		require.NoError(t, w.Close())
+	}
+
+	require.NoError(t, readMsg())
+	writeMsg("hello")
+	f.Upgrade()
+	require.NoError(t, readMsg())
+	writeMsg("foo", "bar", "baz")
+	assert.ErrorIs(t, readMsg(), ErrMalformedChunk)
+
+	assert.Equal(t, Stats{
+		BytesRead:     uint64(len(input)),
+		BytesWritten:  uint64(out.Len()),
+		MsgsRead:      2,
+		MsgsWritten:   2,
+		ChunksRead:    2,
+		ChunksWritten: 3,
+		FramingErrors: 1,
+	}, f.Stats())
+}
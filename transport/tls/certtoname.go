@@ -0,0 +1,225 @@
+package tls
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	// hash functions used by fingerprints.
+	_ "crypto/md5"
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+)
+
+// ErrNoIdentity is returned when no cert-to-name entry maps a certificate
+// chain to a name.
+var ErrNoIdentity = errors.New("tls: no cert-to-name entry matched the certificate")
+
+// MapType is how the name is derived from a certificate matching a
+// cert-to-name entry as defined in RFC7407.
+type MapType int
+
+const (
+	// MapSpecified uses the name given in the entry.
+	MapSpecified MapType = iota + 1
+	// MapSANRFC822Name uses the first rfc822Name (email) subjectAltName with
+	// the host part in lowercase.
+	MapSANRFC822Name
+	// MapSANDNSName uses the first dNSName subjectAltName in lowercase.
+	MapSANDNSName
+	// MapSANIPAddress uses the first iPAddress subjectAltName.  IPv4
+	// addresses are in dotted-quad notation (i.e `192.0.2.1`) and IPv6
+	// addresses are 32 lowercase hex digits without colons (i.e
+	// `20010db8000000000000000000000001`).
+	MapSANIPAddress
+	// MapSANAny uses the first rfc822Name, dNSName or iPAddress
+	// subjectAltName.
+	MapSANAny
+	// MapCommonName uses the common name of the subject.
+	MapCommonName
+)
+
+// String returns the name of the map type as used in the ietf-x509-cert-to-name
+// YANG module.
+func (t MapType) String() string {
+	switch t {
+	case MapSpecified:
+		return "specified"
+	case MapSANRFC822Name:
+		return "san-rfc822-name"
+	case MapSANDNSName:
+		return "san-dns-name"
+	case MapSANIPAddress:
+		return "san-ip-address"
+	case MapSANAny:
+		return "san-any"
+	case MapCommonName:
+		return "common-name"
+	}
+	return fmt.Sprintf("MapType(%d)", int(t))
+}
+
+// CertToName is an entry of the cert-to-name list from RFC7407 mapping a
+// certificate to a name.
+type CertToName struct {
+	// ID orders the entries.  Entries are tried from the lowest id.
+	ID uint32
+
+	// Fingerprint of the leaf certificate or one of the CA certificates in the
+	// chain in the tls-fingerprint format from RFC7407: a hash algorithm
+	// byte followed by the hash, as colon separated hex (i.e
+	// `04:1a:2b:...` for a SHA-256 hash).
+	Fingerprint string
+
+	// MapType is how the name is derived from the leaf certificate.
+	MapType MapType
+
+	// Name is the name used with MapSpecified.
+	Name string
+}
+
+// tls-fingerprint hash algorithm identifiers from RFC5246.
+var fingerprintHashes = map[byte]crypto.Hash{
+	1: crypto.MD5,
+	2: crypto.SHA1,
+	3: crypto.SHA224,
+	4: crypto.SHA256,
+	5: crypto.SHA384,
+	6: crypto.SHA512,
+}
+
+// Fingerprint returns the SHA-256 tls-fingerprint of cert as used in
+// [CertToName] entries.
+func Fingerprint(cert *x509.Certificate) string {
+	return formatFingerprint(4, fingerprint(crypto.SHA256, cert))
+}
+
+func fingerprint(h crypto.Hash, cert *x509.Certificate) []byte {
+	hh := h.New()
+	hh.Write(cert.Raw)
+	return hh.Sum(nil)
+}
+
+func formatFingerprint(alg byte, sum []byte) string {
+	parts := make([]string, 0, len(sum)+1)
+	parts = append(parts, hex.EncodeToString([]byte{alg}))
+	for _, b := range sum {
+		parts = append(parts, hex.EncodeToString([]byte{b}))
+	}
+	return strings.Join(parts, ":")
+}
+
+func parseFingerprint(s string) (crypto.Hash, []byte, error) {
+	data, err := hex.DecodeString(strings.ReplaceAll(s, ":", ""))
+	if err != nil || len(data) < 2 {
+		return 0, nil, fmt.Errorf("tls: invalid fingerprint %q", s)
+	}
+	h, ok := fingerprintHashes[data[0]]
+	if !ok {
+		return 0, nil, fmt.Errorf("tls: unsupported fingerprint hash algorithm %d", data[0])
+	}
+	return h, data[1:], nil
+}
+
+// matches reports if the fingerprint of the entry matches any certificate in
+// the chain.
+func (e CertToName) matches(chain []*x509.Certificate) (bool, error) {
+	h, sum, err := parseFingerprint(e.Fingerprint)
+	if err != nil {
+		return false, err
+	}
+	for _, cert := range chain {
+		if bytes.Equal(fingerprint(h, cert), sum) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// name derives the name from the leaf certificate.  An empty name means the
+// certificate has no value for the map type and the next entry should be
+// tried.
+func (e CertToName) name(leaf *x509.Certificate) string {
+	switch e.MapType {
+	case MapSpecified:
+		return e.Name
+	case MapSANRFC822Name:
+		return sanRFC822Name(leaf)
+	case MapSANDNSName:
+		if len(leaf.DNSNames) > 0 {
+			return strings.ToLower(leaf.DNSNames[0])
+		}
+	case MapSANIPAddress:
+		if len(leaf.IPAddresses) > 0 {
+			return sanIPAddress(leaf.IPAddresses[0])
+		}
+	case MapSANAny:
+		if name := sanRFC822Name(leaf); name != "" {
+			return name
+		}
+		if len(leaf.DNSNames) > 0 {
+			return strings.ToLower(leaf.DNSNames[0])
+		}
+		if len(leaf.IPAddresses) > 0 {
+			return sanIPAddress(leaf.IPAddresses[0])
+		}
+	case MapCommonName:
+		return leaf.Subject.CommonName
+	}
+	return ""
+}
+
+// sanIPAddress formats an address as defined for san-ip-address in RFC7407.
+func sanIPAddress(ip net.IP) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.String()
+	}
+	return hex.EncodeToString(ip)
+}
+
+func sanRFC822Name(cert *x509.Certificate) string {
+	if len(cert.EmailAddresses) == 0 {
+		return ""
+	}
+	// only the host part is case insensitive.
+	addr := cert.EmailAddresses[0]
+	if i := strings.LastIndexByte(addr, '@'); i >= 0 {
+		return addr[:i] + strings.ToLower(addr[i:])
+	}
+	return addr
+}
+
+// MapCertToName derives a name from a certificate chain (leaf first) using
+// the cert-to-name rules from RFC7407 as used by RFC7589.  Entries are tried
+// in order of their ID and the first entry whose fingerprint matches a
+// certificate in the chain and that yields a name is used.  [ErrNoIdentity]
+// is returned if no entry maps the chain.
+func MapCertToName(chain []*x509.Certificate, entries []CertToName) (string, error) {
+	if len(chain) == 0 {
+		return "", ErrNoIdentity
+	}
+
+	entries = append([]CertToName(nil), entries...)
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].ID < entries[j].ID })
+
+	for _, e := range entries {
+		ok, err := e.matches(chain)
+		if err != nil {
+			return "", err
+		}
+		if !ok {
+			continue
+		}
+		if name := e.name(chain[0]); name != "" {
+			return name, nil
+		}
+	}
+	return "", ErrNoIdentity
+}
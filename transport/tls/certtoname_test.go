@@ -0,0 +1,82 @@
+package tls
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMapCertToName(t *testing.T) {
+	ca := newTestCA(t)
+	leaf := newTestCert(t, &x509.Certificate{
+		Subject:        pkix.Name{CommonName: "router1"},
+		DNSNames:       []string{"Router1.Example.COM"},
+		EmailAddresses: []string{"NOC@Example.COM"},
+		IPAddresses:    []net.IP{net.ParseIP("192.0.2.1")},
+	}, ca)
+	ipv6 := newTestCert(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "router2"},
+		IPAddresses: []net.IP{net.ParseIP("2001:DB8::1")},
+	}, ca)
+	bare := newTestCert(t, &x509.Certificate{Subject: pkix.Name{CommonName: "bare"}}, ca)
+	other := newTestCA(t)
+
+	chain := []*x509.Certificate{leaf.cert, ca.cert}
+	caFP, leafFP := Fingerprint(ca.cert), Fingerprint(leaf.cert)
+
+	tt := []struct {
+		name    string
+		chain   []*x509.Certificate
+		entries []CertToName
+		want    string
+		wantErr error
+	}{
+		{"specified", chain, []CertToName{{Fingerprint: leafFP, MapType: MapSpecified, Name: "core-1"}}, "core-1", nil},
+		{"rfc822", chain, []CertToName{{Fingerprint: caFP, MapType: MapSANRFC822Name}}, "NOC@example.com", nil},
+		{"dns", chain, []CertToName{{Fingerprint: caFP, MapType: MapSANDNSName}}, "router1.example.com", nil},
+		{"ip", chain, []CertToName{{Fingerprint: caFP, MapType: MapSANIPAddress}}, "192.0.2.1", nil},
+		{"ipv6", []*x509.Certificate{ipv6.cert, ca.cert}, []CertToName{{Fingerprint: caFP, MapType: MapSANIPAddress}}, "20010db8000000000000000000000001", nil},
+		{"any ipv6", []*x509.Certificate{ipv6.cert, ca.cert}, []CertToName{{Fingerprint: caFP, MapType: MapSANAny}}, "20010db8000000000000000000000001", nil},
+		{"any", chain, []CertToName{{Fingerprint: caFP, MapType: MapSANAny}}, "NOC@example.com", nil},
+		{"common name", chain, []CertToName{{Fingerprint: caFP, MapType: MapCommonName}}, "router1", nil},
+		{"fallthrough", []*x509.Certificate{bare.cert, ca.cert}, []CertToName{
+			{ID: 1, Fingerprint: caFP, MapType: MapSANAny},
+			{ID: 2, Fingerprint: caFP, MapType: MapCommonName},
+		}, "bare", nil},
+		{"no match", chain, []CertToName{{Fingerprint: Fingerprint(other.cert), MapType: MapCommonName}}, "", ErrNoIdentity},
+		{"empty chain", nil, []CertToName{{Fingerprint: caFP, MapType: MapCommonName}}, "", ErrNoIdentity},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := MapCertToName(tc.chain, tc.entries)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestFingerprintFormats(t *testing.T) {
+	cert := newTestCA(t).cert
+
+	// sha1 fingerprint
+	sha1 := formatFingerprint(2, fingerprint(fingerprintHashes[2], cert))
+	name, err := MapCertToName([]*x509.Certificate{cert}, []CertToName{{Fingerprint: sha1, MapType: MapCommonName}})
+	require.NoError(t, err)
+	assert.Equal(t, "test ca", name)
+
+	_, err = MapCertToName([]*x509.Certificate{cert}, []CertToName{{Fingerprint: "zz", MapType: MapCommonName}})
+	assert.ErrorContains(t, err, "invalid fingerprint")
+	_, err = MapCertToName([]*x509.Certificate{cert}, []CertToName{{Fingerprint: "09:ab", MapType: MapCommonName}})
+	assert.ErrorContains(t, err, "unsupported fingerprint hash algorithm")
+
+	assert.Equal(t, "common-name", MapCommonName.String())
+}
@@ -0,0 +1,92 @@
+package tls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// NewClientConfig returns a config for mutual TLS as required by RFC7589.  The
+// client certificate and key are loaded from certFile and keyFile (keyFile
+// defaults to certFile for files holding both).  The server certificate is
+// verified against the CA certificates in caFile or the system roots if
+// caFile is empty.  TLS 1.2 or later is required.
+//
+// certFile may be empty for servers that don't authenticate clients with
+// certificates although this is not allowed by RFC7589.
+func NewClientConfig(certFile, keyFile, caFile string) (*tls.Config, error) {
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if certFile != "" || keyFile != "" {
+		if keyFile == "" {
+			keyFile = certFile
+		}
+		if certFile == "" {
+			certFile = keyFile
+		}
+		pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{pair}
+	}
+
+	if caFile != "" {
+		data, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ca file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificates found in ca file %q", caFile)
+		}
+		config.RootCAs = pool
+	}
+
+	return config, nil
+}
+
+// NewServerConfig returns a config for the server side of NETCONF over TLS
+// (RFC7589).  The server certificate and key are loaded from certFile and
+// keyFile (keyFile defaults to certFile for files holding both).  Clients
+// must present a certificate which is verified against the CA certificates in
+// caFile.  If caFile is empty any client certificate is accepted by the
+// handshake and clients must be authenticated from their certificate
+// afterwards (i.e by fingerprint with [MapCertToName]).  TLS 1.2 or later is
+// required.
+func NewServerConfig(certFile, keyFile, caFile string) (*tls.Config, error) {
+	if keyFile == "" {
+		keyFile = certFile
+	}
+	if certFile == "" {
+		certFile = keyFile
+	}
+	pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load server certificate: %w", err)
+	}
+
+	config := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{pair},
+		ClientAuth:   tls.RequireAnyClientCert,
+	}
+
+	if caFile != "" {
+		data, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ca file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificates found in ca file %q", caFile)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return config, nil
+}
@@ -3,7 +3,11 @@ package tls
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"net"
+	"net/url"
+	"time"
 
 	"github.com/nemith/netconf/transport"
 )
@@ -11,32 +15,248 @@ import (
 // alias it to a private type so we can make it private when embedding
 type framer = transport.Framer //nolint:golint,unused
 
+// DefaultPort is the IANA assigned port for NETCONF over TLS.  It is used by
+// Dial when the address does not contain a port.
+const DefaultPort = 6513
+
 // Transport implements RFC7589 for implementing NETCONF over TLS.
 type Transport struct {
 	conn *tls.Conn
 	*framer
 }
 
+// SRVService is the service name used to lookup NETCONF over TLS endpoints
+// in DNS SRV records.
+const SRVService = "netconf-tls"
+
+// DialOption is an optional argument to [Dial].
+type DialOption interface {
+	apply(*dialConfig)
+}
+
+type dialConfig struct {
+	dialer   transport.ContextDialer
+	resolver transport.Resolver
+	socket   *transport.SocketOptions
+	proxy    *url.URL
+	framer   []transport.FramerOption
+	sessions tls.ClientSessionCache
+}
+
+type dialerOpt struct{ d transport.ContextDialer }
+
+func (o dialerOpt) apply(cfg *dialConfig) { cfg.dialer = o.d }
+
+// WithDialer sets the dialer used to establish the underlying connection, i.e
+// a pre-built *net.Dialer.  If the dialer returns a *tls.Conn (like a
+// *tls.Dialer does) it is used as is and the config given to Dial is ignored.
+func WithDialer(d transport.ContextDialer) DialOption { return dialerOpt{d} }
+
+type socketOpt transport.SocketOptions
+
+func (o socketOpt) apply(cfg *dialConfig) {
+	so := transport.SocketOptions(o)
+	cfg.socket = &so
+}
+
+// WithSocketOptions tunes the TCP connection (keepalive interval, DSCP marking
+// and source address).  It is ignored when a dialer is given with
+// [WithDialer].
+func WithSocketOptions(o transport.SocketOptions) DialOption { return socketOpt(o) }
+
+type proxyOpt struct{ u *url.URL }
+
+func (o proxyOpt) apply(cfg *dialConfig) { cfg.proxy = o.u }
+
+// WithProxy connects through the SOCKS5 or HTTP CONNECT proxy at u (see
+// [transport.NewProxyDialer]).  The proxy is connected to with the dialer from
+// [WithDialer] or [WithSocketOptions].
+func WithProxy(u *url.URL) DialOption { return proxyOpt{u} }
+
+type resolverOpt struct{ r transport.Resolver }
+
+func (o resolverOpt) apply(cfg *dialConfig) { cfg.resolver = o.r }
+
+// WithResolver sets a resolver used to find the addresses to connect to for
+// the address given to Dial.  The addresses are tried in order until a
+// connection succeeds.  The server's certificate is still verified against
+// the host given to Dial.
+//
+// To discover devices with DNS SRV records use:
+//
+//	tls.Dial(ctx, "tcp", "router1.example.com", config,
+//		tls.WithResolver(&transport.SRVResolver{Service: tls.SRVService}))
+func WithResolver(r transport.Resolver) DialOption { return resolverOpt{r} }
+
+type framerOpt []transport.FramerOption
+
+func (o framerOpt) apply(cfg *dialConfig) { cfg.framer = append(cfg.framer, o...) }
+
+type sessionCacheOpt struct{ c tls.ClientSessionCache }
+
+func (o sessionCacheOpt) apply(cfg *dialConfig) { cfg.sessions = o.c }
+
+// WithSessionCache resumes TLS sessions with the tickets kept in c if the
+// config given to Dial doesn't have a ClientSessionCache.  Sharing one cache
+// (i.e from tls.NewLRUClientSessionCache) between the many short-lived
+// connections of a poller saves a full handshake each time a device is
+// reconnected to.  Tickets are cached by the server name of each device.
+func WithSessionCache(c tls.ClientSessionCache) DialOption { return sessionCacheOpt{c} }
+
+// WithFramerOptions passes options to the framer of the transport, i.e to
+// set the buffer sizes with [transport.WithReadBufferSize].
+func WithFramerOptions(opts ...transport.FramerOption) DialOption { return framerOpt(opts) }
+
 // Dial will connect to a server via TLS and retuns a Transport.
-func Dial(ctx context.Context, network, addr string, config *tls.Config) (*Transport, error) {
-	var d net.Dialer
-	conn, err := d.DialContext(ctx, network, addr)
+//
+// If addr does not contain a port then [DefaultPort] is used.  IPv6 literals
+// can be given with or without brackets.  If config does not set a ServerName
+// the host from addr is used to verify the server's certificate.
+func Dial(ctx context.Context, network, addr string, config *tls.Config, opts ...DialOption) (*Transport, error) {
+	var cfg dialConfig
+	for _, opt := range opts {
+		opt.apply(&cfg)
+	}
+	if cfg.dialer == nil {
+		var so transport.SocketOptions
+		if cfg.socket != nil {
+			so = *cfg.socket
+		}
+		d, err := so.Dialer()
+		if err != nil {
+			return nil, err
+		}
+		cfg.dialer = d
+	}
+	if cfg.proxy != nil {
+		d, err := transport.NewProxyDialer(cfg.proxy, cfg.dialer)
+		if err != nil {
+			return nil, err
+		}
+		cfg.dialer = d
+	}
+
+	host, _, err := transport.SplitHostPort(addr, DefaultPort)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs, err := transport.Resolve(ctx, cfg.resolver, network, addr)
 	if err != nil {
 		return nil, err
 	}
+	for i := range addrs {
+		addrs[i] = transport.JoinDefaultPort(addrs[i], DefaultPort)
+	}
 
-	tlsConn := tls.Client(conn, config)
-	return NewTransport(tlsConn), nil
+	if config == nil {
+		config = &tls.Config{}
+	}
+	if config.ServerName == "" {
+		config = config.Clone()
+		config.ServerName = host
+	}
+	if cfg.sessions != nil && config.ClientSessionCache == nil {
+		config = config.Clone()
+		config.ClientSessionCache = cfg.sessions
+	}
 
+	conn, err := transport.DialFirst(ctx, cfg.dialer, network, addrs)
+	if err != nil {
+		return nil, err
+	}
+
+	// handshake now so certificate errors are returned from Dial and honor
+	// the context.  This does nothing if the dialer already did it.
+	tlsConn, ok := conn.(*tls.Conn)
+	if !ok {
+		tlsConn = tls.Client(conn, config)
+	}
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		tlsConn.Close()
+		return nil, err
+	}
+	return newTransport(tlsConn, cfg), nil
 }
 
 // NewTransport takes an already connected tls transport and returns a new
-// Transport.
-func NewTransport(conn *tls.Conn) *Transport {
+// Transport.  Only options affecting the transport itself (i.e
+// [WithFramerOptions]) are used.
+func NewTransport(conn *tls.Conn, opts ...DialOption) *Transport {
+	var cfg dialConfig
+	for _, opt := range opts {
+		opt.apply(&cfg)
+	}
+	return newTransport(conn, cfg)
+}
+
+func newTransport(conn *tls.Conn, cfg dialConfig) *Transport {
 	return &Transport{
 		conn:   conn,
-		framer: transport.NewFramer(conn, conn),
+		framer: transport.NewFramer(conn, conn, cfg.framer...),
+	}
+}
+
+// RemoteAddr returns the remote address of the underlying connection.
+func (t *Transport) RemoteAddr() net.Addr {
+	return t.conn.RemoteAddr()
+}
+
+// DidResume reports whether the TLS session was resumed from a ticket instead
+// of doing a full handshake (see [WithSessionCache]).
+func (t *Transport) DidResume() bool {
+	return t.conn.ConnectionState().DidResume
+}
+
+// PeerCertificates returns the certificate chain of the server with the leaf
+// certificate first.  This is the verified chain up to the trust anchor or,
+// if the server certificate wasn't verified, the certificates the server
+// presented.  The TLS handshake is completed first if it hasn't happened yet.
+func (t *Transport) PeerCertificates() ([]*x509.Certificate, error) {
+	if err := t.conn.Handshake(); err != nil {
+		return nil, err
+	}
+	state := t.conn.ConnectionState()
+	if len(state.VerifiedChains) > 0 {
+		return state.VerifiedChains[0], nil
+	}
+	return state.PeerCertificates, nil
+}
+
+// PeerIdentity returns the name of the server derived from its certificate
+// chain with the cert-to-name entries (see [MapCertToName]).  This is useful
+// to log which device identity was actually reached.
+func (t *Transport) PeerIdentity(entries []CertToName) (string, error) {
+	chain, err := t.PeerCertificates()
+	if err != nil {
+		return "", err
+	}
+	return MapCertToName(chain, entries)
+}
+
+// SetReadDeadline sets the read deadline of the underlying connection.
+func (t *Transport) SetReadDeadline(d time.Time) error {
+	return t.conn.SetReadDeadline(d)
+}
+
+// SetWriteDeadline sets the write deadline of the underlying connection.
+func (t *Transport) SetWriteDeadline(d time.Time) error {
+	return t.conn.SetWriteDeadline(d)
+}
+
+// Ping checks that the underlying connection hasn't been closed or reset by
+// the server with [transport.ProbeConn].  A zero-length write can't be used
+// as crypto/tls doesn't send anything for it.  If the connection can't be
+// probed (i.e it goes through a proxy) Ping returns nil.
+func (t *Transport) Ping(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	err := transport.ProbeConn(t.conn.NetConn())
+	if errors.Is(err, errors.ErrUnsupported) {
+		return nil
 	}
+	return err
 }
 
 // Close will close the transport and the underlying TLS connection.
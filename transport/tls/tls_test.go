@@ -0,0 +1,288 @@
+package tls
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nemith/netconf/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCert(t *testing.T, tmpl *x509.Certificate, parent *testCert) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl.SerialNumber = big.NewInt(time.Now().UnixNano())
+	tmpl.NotBefore = time.Now().Add(-time.Hour)
+	tmpl.NotAfter = time.Now().Add(time.Hour)
+
+	parentCert, parentKey := tmpl, key
+	if parent != nil {
+		parentCert, parentKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parentCert, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCert{cert: cert, key: key}
+}
+
+func (c *testCert) writeFiles(t *testing.T, dir, name string) (certFile, keyFile string) {
+	t.Helper()
+	certFile = filepath.Join(dir, name+".pem")
+	keyFile = filepath.Join(dir, name+".key")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.cert.Raw}), 0o600))
+	keyDER, err := x509.MarshalECPrivateKey(c.key)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return certFile, keyFile
+}
+
+func newTestCA(t *testing.T) *testCert {
+	return newTestCert(t, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "test ca"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil)
+}
+
+func TestDialMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	caFile, _ := ca.writeFiles(t, dir, "ca")
+	server := newTestCert(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "router1"},
+		DNSNames:    []string{"localhost"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, ca)
+	client := newTestCert(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "netconf-client"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca)
+	certFile, keyFile := client.writeFiles(t, dir, "client")
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{server.cert.Raw}, PrivateKey: server.key}},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pool,
+	})
+	require.NoError(t, err)
+	defer ln.Close()
+
+	peer := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		tlsConn := conn.(*tls.Conn)
+		if err := tlsConn.Handshake(); err != nil {
+			peer <- err.Error()
+			return
+		}
+		peer <- tlsConn.ConnectionState().PeerCertificates[0].Subject.CommonName
+	}()
+
+	config, err := NewClientConfig(certFile, keyFile, caFile)
+	require.NoError(t, err)
+
+	tr, err := Dial(context.Background(), "tcp", ln.Addr().String(), config)
+	require.NoError(t, err)
+	defer tr.Close()
+	assert.Equal(t, "netconf-client", <-peer)
+
+	name, err := tr.PeerIdentity([]CertToName{
+		{ID: 2, Fingerprint: Fingerprint(ca.cert), MapType: MapCommonName},
+		{ID: 1, Fingerprint: Fingerprint(ca.cert), MapType: MapSANDNSName},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "localhost", name)
+}
+
+func TestDialUntrustedServer(t *testing.T) {
+	dir := t.TempDir()
+	caFile, _ := newTestCA(t).writeFiles(t, dir, "ca")
+	server := newTestCert(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "router1"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+	}, newTestCA(t))
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{server.cert.Raw}, PrivateKey: server.key}},
+	})
+	require.NoError(t, err)
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_ = conn.(*tls.Conn).Handshake()
+	}()
+
+	config, err := NewClientConfig("", "", caFile)
+	require.NoError(t, err)
+	_, err = Dial(context.Background(), "tcp", ln.Addr().String(), config)
+	var unknownAuthority x509.UnknownAuthorityError
+	assert.ErrorAs(t, err, &unknownAuthority)
+}
+
+func TestNewServerConfig(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	caFile, _ := ca.writeFiles(t, dir, "ca")
+	certFile, keyFile := newTestCert(t, &x509.Certificate{
+		Subject: pkix.Name{CommonName: "router1"},
+	}, ca).writeFiles(t, dir, "server")
+
+	config, err := NewServerConfig(certFile, keyFile, caFile)
+	require.NoError(t, err)
+	assert.Len(t, config.Certificates, 1)
+	assert.Equal(t, tls.RequireAndVerifyClientCert, config.ClientAuth)
+	assert.NotNil(t, config.ClientCAs)
+	assert.Equal(t, uint16(tls.VersionTLS12), config.MinVersion)
+
+	config, err = NewServerConfig(certFile, keyFile, "")
+	require.NoError(t, err)
+	assert.Equal(t, tls.RequireAnyClientCert, config.ClientAuth)
+
+	_, err = NewServerConfig(certFile, keyFile, certFile+".missing")
+	assert.Error(t, err)
+	_, err = NewServerConfig(caFile, "", "")
+	assert.Error(t, err)
+}
+
+func TestDialSocketOptions(t *testing.T) {
+	_, err := Dial(context.Background(), "tcp", "router1", nil,
+		WithSocketOptions(transport.SocketOptions{SourceAddr: "not-an-ip"}))
+	assert.ErrorContains(t, err, "invalid source address")
+}
+
+func TestPing(t *testing.T) {
+	server := newTestCert(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "router1"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+	}, nil)
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{server.cert.Raw}, PrivateKey: server.key}},
+	})
+	require.NoError(t, err)
+	defer ln.Close()
+	closeServer := make(chan struct{})
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_ = conn.(*tls.Conn).Handshake()
+		<-closeServer
+	}()
+
+	tr, err := Dial(context.Background(), "tcp", ln.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+	require.NoError(t, err)
+	defer tr.Close()
+	require.NoError(t, tr.conn.Handshake())
+
+	assert.NoError(t, tr.Ping(context.Background()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, tr.Ping(ctx), context.Canceled)
+
+	// the close_notify from the server has to be read before the probe sees
+	// the connection closed like a session reading from the transport would.
+	close(closeServer)
+	_, err = tr.conn.Read(make([]byte, 1))
+	require.Error(t, err)
+	assert.Eventually(t, func() bool {
+		return tr.Ping(context.Background()) != nil
+	}, time.Second, 10*time.Millisecond)
+}
+
+// newResumeServer starts a TLS server that sends a byte on every connection
+// so TLS 1.3 session tickets are received by the client.
+func newResumeServer(t *testing.T) net.Listener {
+	server := newTestCert(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "router1"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+	}, nil)
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{server.cert.Raw}, PrivateKey: server.key}},
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_, _ = conn.Write([]byte{'x'})
+				_, _ = conn.Read(make([]byte, 1))
+			}()
+		}
+	}()
+	return ln
+}
+
+func TestDialSessionCache(t *testing.T) {
+	ln := newResumeServer(t)
+	cache := tls.NewLRUClientSessionCache(8)
+	config := &tls.Config{InsecureSkipVerify: true}
+
+	dial := func() *Transport {
+		tr, err := Dial(context.Background(), "tcp", ln.Addr().String(), config, WithSessionCache(cache))
+		require.NoError(t, err)
+		_, err = tr.conn.Read(make([]byte, 1))
+		require.NoError(t, err)
+		require.NoError(t, tr.Close())
+		return tr
+	}
+
+	assert.False(t, dial().DidResume())
+	assert.True(t, dial().DidResume())
+	assert.Nil(t, config.ClientSessionCache, "config given to Dial was modified")
+}
+
+func TestDialTLSDialer(t *testing.T) {
+	ln := newResumeServer(t)
+	d := &tls.Dialer{Config: &tls.Config{InsecureSkipVerify: true}}
+
+	// the config given to Dial would fail to verify the server.
+	tr, err := Dial(context.Background(), "tcp", ln.Addr().String(), &tls.Config{}, WithDialer(d))
+	require.NoError(t, err)
+	defer tr.Close()
+
+	_, err = tr.conn.Read(make([]byte, 1))
+	assert.NoError(t, err)
+}
@@ -1,13 +1,16 @@
 package transport
 
 import (
+	"context"
 	"errors"
 	"io"
+	"time"
 )
 
 var (
 	// ErrExistingWriter is returned from MsgWriter when there is already a
-	// message io.WriterCloser that hasn't been properly closed yet.
+	// message io.WriterCloser that hasn't been properly closed yet.  Framers
+	// return it as an [*ExistingWriterError].
 	ErrExistingWriter = errors.New("netconf: existing message writer still open")
 
 	// ErrInvalidIO is returned when a write or read operation is called on
@@ -34,3 +37,20 @@ type Transport interface {
 	// Close will close the underlying transport.
 	Close() error
 }
+
+// Deadliner is implemented by transports whose underlying connection supports
+// deadlines.  Sessions use it to interrupt a read or write blocked on a stalled
+// device once the context of the rpc is done.  Transports that can't set a
+// deadline on a particular connection return os.ErrNoDeadline.
+type Deadliner interface {
+	SetReadDeadline(t time.Time) error
+	SetWriteDeadline(t time.Time) error
+}
+
+// Pinger is implemented by transports that can check that the connection to
+// the device is still alive without sending a netconf message.  Ping returns
+// nil if the connection is alive or the error that made it unusable.  It is
+// safe to call concurrently with reads and writes of messages.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
@@ -0,0 +1,299 @@
+package transporttest
+
+import (
+	"io"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/nemith/netconf/transport"
+)
+
+// ChaosOption is an optional argument to [NewChaos].
+type ChaosOption interface {
+	apply(*Chaos)
+}
+
+type latencyOpt struct{ min, max time.Duration }
+
+func (o latencyOpt) apply(c *Chaos) { c.minLatency, c.maxLatency = o.min, max(o.min, o.max) }
+
+// WithLatency delays every message read and written by a random duration
+// between min and max.
+func WithLatency(min, max time.Duration) ChaosOption { return latencyOpt{min, max} }
+
+type truncateOpt float64
+
+func (o truncateOpt) apply(c *Chaos) { c.truncate = float64(o) }
+
+// WithTruncation cuts messages read short with probability p.  The reader
+// returns io.ErrUnexpectedEOF part way through the message like a transport
+// whose connection ended.
+func WithTruncation(p float64) ChaosOption { return truncateOpt(p) }
+
+type corruptOpt float64
+
+func (o corruptOpt) apply(c *Chaos) { c.corrupt = float64(o) }
+
+// WithCorruptChunks corrupts messages read with probability p by flipping
+// bits of a byte part way through the message as if a chunk was damaged on
+// its way from the device.  The message is read without an error so it is up
+// to the session to notice (i.e as invalid xml or a reply for an unknown
+// message-id).  Messages shorter than where the corruption was to happen are
+// passed through unchanged.
+func WithCorruptChunks(p float64) ChaosOption { return corruptOpt(p) }
+
+type disconnectOpt float64
+
+func (o disconnectOpt) apply(c *Chaos) { c.disconnect = float64(o) }
+
+// WithDisconnects closes the transport part way through a message read or
+// written with probability p.  The read or write fails with
+// io.ErrUnexpectedEOF or io.ErrClosedPipe respectively.
+func WithDisconnects(p float64) ChaosOption { return disconnectOpt(p) }
+
+type seedOpt int64
+
+func (o seedOpt) apply(c *Chaos) { c.rand = rand.New(rand.NewSource(int64(o))) }
+
+// WithSeed seeds the random source making the failures repeatable.
+func WithSeed(seed int64) ChaosOption { return seedOpt(seed) }
+
+// Chaos wraps a transport and injects latency and failures into the messages
+// read and written.  It is meant to test that retry and reconnect logic
+// handles the ways real devices and networks fail.
+//
+//	tr := transporttest.NewChaos(tr,
+//		transporttest.WithLatency(10*time.Millisecond, 200*time.Millisecond),
+//		transporttest.WithDisconnects(0.01))
+//	session, err := netconf.Open(tr)
+//
+// At most one failure is injected per message.  Once the transport has been
+// disconnected all further reads and writes fail.
+type Chaos struct {
+	transport.Transport
+
+	minLatency, maxLatency time.Duration
+	truncate               float64
+	corrupt                float64
+	disconnect             float64
+
+	mu   sync.Mutex
+	rand *rand.Rand
+
+	done      chan struct{}
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// NewChaos returns a transport injecting failures into tr.  Without options
+// no failures are injected.
+func NewChaos(tr transport.Transport, opts ...ChaosOption) *Chaos {
+	c := &Chaos{
+		Transport: tr,
+		done:      make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt.apply(c)
+	}
+	if c.rand == nil {
+		c.rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	return c
+}
+
+type fault int
+
+const (
+	noFault fault = iota
+	truncateFault
+	corruptFault
+	disconnectFault
+)
+
+// pick chooses the fault for the next message and how many bytes of it to
+// pass through before the fault happens.
+func (c *Chaos) pick(read bool) (f fault, after int, delay time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delay = c.minLatency
+	if span := c.maxLatency - c.minLatency; span > 0 {
+		delay += time.Duration(c.rand.Int63n(int64(span)))
+	}
+
+	x := c.rand.Float64()
+	switch {
+	case read && x < c.truncate:
+		f = truncateFault
+	case read && x < c.truncate+c.corrupt:
+		f = corruptFault
+	case x < c.truncate+c.corrupt+c.disconnect:
+		f = disconnectFault
+	}
+	return f, c.rand.Intn(64), delay
+}
+
+// mask returns the bits flipped to corrupt a byte.
+func (c *Chaos) mask() byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return byte(1 + c.rand.Intn(255))
+}
+
+func (c *Chaos) sleep(d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-c.done:
+		return io.ErrClosedPipe
+	}
+}
+
+func (c *Chaos) closed() bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// MsgReader implements transport.Transport.
+func (c *Chaos) MsgReader() (io.ReadCloser, error) {
+	if c.closed() {
+		return nil, io.EOF
+	}
+
+	r, err := c.Transport.MsgReader()
+	if err != nil {
+		return nil, err
+	}
+
+	f, after, delay := c.pick(true)
+	if err := c.sleep(delay); err != nil {
+		r.Close()
+		return nil, err
+	}
+	if f == noFault {
+		return r, nil
+	}
+	return &chaosReader{ReadCloser: r, c: c, fault: f, left: after, mask: c.mask()}, nil
+}
+
+// MsgWriter implements transport.Transport.
+func (c *Chaos) MsgWriter() (io.WriteCloser, error) {
+	if c.closed() {
+		return nil, io.ErrClosedPipe
+	}
+
+	f, after, delay := c.pick(false)
+	if err := c.sleep(delay); err != nil {
+		return nil, err
+	}
+
+	w, err := c.Transport.MsgWriter()
+	if err != nil {
+		return nil, err
+	}
+	if f == noFault {
+		return w, nil
+	}
+	return &chaosWriter{WriteCloser: w, c: c, left: after}, nil
+}
+
+// Disconnect closes the transport as if the connection was lost.
+func (c *Chaos) Disconnect() {
+	_ = c.Close()
+}
+
+// Close implements transport.Transport.  The wrapped transport is only closed
+// once.
+func (c *Chaos) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.closeErr = c.Transport.Close()
+	})
+	return c.closeErr
+}
+
+type chaosReader struct {
+	io.ReadCloser
+	c     *Chaos
+	fault fault
+	left  int
+	mask  byte
+	err   error
+}
+
+func (r *chaosReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	if r.fault == corruptFault {
+		n, err := r.ReadCloser.Read(p)
+		if r.left >= 0 && r.left < n {
+			p[r.left] ^= r.mask
+		}
+		r.left -= n
+		return n, err
+	}
+	if r.left <= 0 {
+		switch r.fault {
+		case truncateFault:
+			r.err = io.ErrUnexpectedEOF
+		case disconnectFault:
+			r.c.Disconnect()
+			r.err = io.ErrUnexpectedEOF
+		}
+		return 0, r.err
+	}
+
+	n, err := r.ReadCloser.Read(p[:min(len(p), r.left)])
+	r.left -= n
+	return n, err
+}
+
+func (r *chaosReader) Close() error {
+	err := r.ReadCloser.Close()
+	if r.err != nil {
+		return r.err
+	}
+	return err
+}
+
+// chaosWriter disconnects the transport after writing left bytes.
+type chaosWriter struct {
+	io.WriteCloser
+	c    *Chaos
+	left int
+}
+
+func (w *chaosWriter) Write(p []byte) (int, error) {
+	if len(p) <= w.left {
+		n, err := w.WriteCloser.Write(p)
+		w.left -= n
+		return n, err
+	}
+
+	n, err := w.WriteCloser.Write(p[:w.left])
+	w.left -= n
+	if err != nil {
+		return n, err
+	}
+	w.c.Disconnect()
+	return n, io.ErrClosedPipe
+}
+
+// Close disconnects the transport if the message was shorter than where the
+// disconnect was to happen so the message is never completely sent.
+func (w *chaosWriter) Close() error {
+	w.c.Disconnect()
+	_ = w.WriteCloser.Close()
+	return io.ErrClosedPipe
+}
@@ -0,0 +1,111 @@
+package transporttest
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nemith/netconf/transport"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var longMsg = "<rpc-reply>" + strings.Repeat("<data/>", 20) + "</rpc-reply>"
+
+type stream struct {
+	io.Reader
+	bytes.Buffer
+	closed bool
+}
+
+func (s *stream) Read(p []byte) (int, error) { return s.Reader.Read(p) }
+func (s *stream) Close() error               { s.closed = true; return nil }
+
+func newStream(msgs ...string) *stream {
+	return &stream{Reader: strings.NewReader(strings.Join(msgs, "]]>]]>") + "]]>]]>")}
+}
+
+func TestChaosNoFaults(t *testing.T) {
+	c := NewChaos(transport.NewPipeTransport(newStream(longMsg, longMsg)))
+	for i := 0; i < 2; i++ {
+		r, err := c.MsgReader()
+		require.NoError(t, err)
+		got, err := io.ReadAll(r)
+		require.NoError(t, err)
+		assert.Equal(t, longMsg, string(got))
+		require.NoError(t, r.Close())
+	}
+}
+
+func TestChaosReadFaults(t *testing.T) {
+	tt := []struct {
+		name   string
+		opt    ChaosOption
+		err    error
+		closed bool
+	}{
+		{"truncate", WithTruncation(1), io.ErrUnexpectedEOF, false},
+		{"disconnect", WithDisconnects(1), io.ErrUnexpectedEOF, true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newStream(longMsg)
+			c := NewChaos(transport.NewPipeTransport(s), tc.opt, WithSeed(1))
+
+			r, err := c.MsgReader()
+			require.NoError(t, err)
+			got, err := io.ReadAll(r)
+			assert.ErrorIs(t, err, tc.err)
+			assert.True(t, strings.HasPrefix(longMsg, string(got)))
+			assert.Less(t, len(got), len(longMsg))
+			assert.Equal(t, tc.closed, s.closed)
+		})
+	}
+}
+
+func TestChaosCorrupt(t *testing.T) {
+	c := NewChaos(transport.NewPipeTransport(newStream(longMsg)), WithCorruptChunks(1), WithSeed(1))
+
+	r, err := c.MsgReader()
+	require.NoError(t, err)
+	got, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+
+	// the message is complete with a single byte changed.
+	require.Len(t, got, len(longMsg))
+	var changed int
+	for i := range got {
+		if got[i] != longMsg[i] {
+			changed++
+		}
+	}
+	assert.Equal(t, 1, changed)
+}
+
+func TestChaosWriteDisconnect(t *testing.T) {
+	s := newStream()
+	c := NewChaos(transport.NewPipeTransport(s), WithDisconnects(1))
+
+	w, err := c.MsgWriter()
+	require.NoError(t, err)
+	_, err = io.WriteString(w, longMsg)
+	assert.ErrorIs(t, err, io.ErrClosedPipe)
+	assert.True(t, s.closed)
+	assert.NotContains(t, s.String(), "]]>]]>")
+
+	_, err = c.MsgWriter()
+	assert.ErrorIs(t, err, io.ErrClosedPipe)
+}
+
+func TestChaosLatency(t *testing.T) {
+	c := NewChaos(transport.NewPipeTransport(newStream(longMsg)), WithLatency(20*time.Millisecond, 30*time.Millisecond))
+
+	start := time.Now()
+	_, err := c.MsgReader()
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+}
@@ -0,0 +1,9 @@
+// Package transporttest implements transports for testing code built on
+// netconf sessions without a real device.
+//
+// [Transport] is a scripted server that answers rpcs with canned replies and
+// fails the test on unexpected traffic.  [Chaos] wraps any transport to inject
+// the latency and failures seen with real devices and networks.  [Pipe]
+// connects two framed transports in memory to run a client and a server in
+// one process.
+package transporttest
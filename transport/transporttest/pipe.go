@@ -0,0 +1,17 @@
+package transporttest
+
+import (
+	"net"
+
+	"github.com/nemith/netconf/transport"
+)
+
+// Pipe returns two transports connected to each other over an in-memory
+// [net.Pipe] using the framing from RFC6242.  Messages written to one are read
+// from the other which allows running a server and a client in the same test
+// without sockets.  Both ends need to be upgraded to chunked framing once the
+// hello exchange allows it.  Closing either end closes both.
+func Pipe(opts ...transport.FramerOption) (client, server *transport.PipeTransport) {
+	c, s := net.Pipe()
+	return transport.NewPipeTransport(c, opts...), transport.NewPipeTransport(s, opts...)
+}
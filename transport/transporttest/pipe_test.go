@@ -0,0 +1,62 @@
+package transporttest
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPipe(t *testing.T) {
+	client, server := Pipe()
+	defer client.Close()
+
+	send := func(w interface {
+		MsgWriter() (io.WriteCloser, error)
+	}, msg string) {
+		mw, err := w.MsgWriter()
+		require.NoError(t, err)
+		_, err = io.WriteString(mw, msg)
+		require.NoError(t, err)
+		require.NoError(t, mw.Close())
+	}
+
+	recv := func(r interface {
+		MsgReader() (io.ReadCloser, error)
+	}) string {
+		mr, err := r.MsgReader()
+		require.NoError(t, err)
+		got, err := io.ReadAll(mr)
+		require.NoError(t, err)
+		require.NoError(t, mr.Close())
+		return string(got)
+	}
+
+	// writes block until read so they happen in the background.
+	sent := make(chan struct{})
+	go func() {
+		send(client, "<hello/>")
+		close(sent)
+	}()
+	assert.Equal(t, "<hello/>\n", recv(server))
+	<-sent
+
+	client.Upgrade()
+	server.Upgrade()
+	sent = make(chan struct{})
+	go func() {
+		send(server, "<rpc-reply/>")
+		close(sent)
+	}()
+	assert.Equal(t, "<rpc-reply/>", recv(client))
+	<-sent
+
+	require.NoError(t, server.Close())
+	_, err := client.MsgWriter()
+	require.NoError(t, err)
+	mr, err := client.MsgReader()
+	require.NoError(t, err)
+	_, err = io.ReadAll(mr)
+	assert.Error(t, err)
+}
@@ -0,0 +1,336 @@
+package transporttest
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	baseNamespace  = "urn:ietf:params:xml:ns:netconf:base:1.0"
+	notifNamespace = "urn:ietf:params:xml:ns:netconf:notification:1.0"
+)
+
+var msgIDRe = regexp.MustCompile(`message-id="([^"]*)"`)
+
+// Option is an optional argument to [NewTransport].
+type Option interface {
+	apply(*Transport)
+}
+
+type capabilitiesOpt []string
+
+func (o capabilitiesOpt) apply(t *Transport) { t.caps = append(t.caps, o...) }
+
+// WithCapabilities adds capabilities to the hello sent by the transport.  The
+// base 1.0 and 1.1 capabilities are always sent.
+func WithCapabilities(caps ...string) Option { return capabilitiesOpt(caps) }
+
+type sessionIDOpt uint64
+
+func (o sessionIDOpt) apply(t *Transport) { t.sessionID = uint64(o) }
+
+// WithSessionID sets the session-id sent in the hello.  The default is 1.
+func WithSessionID(id uint64) Option { return sessionIDOpt(id) }
+
+// Transport is a scripted netconf server for tests.  It answers the hello
+// exchange on its own and the rpcs sent by the client with the replies set up
+// with [Transport.Expect].  Messages are not framed.
+//
+//	tr := transporttest.NewTransport(t)
+//	tr.Expect("<get-config>").RespondData("<system/>")
+//
+//	session, err := netconf.Open(tr)
+//	...
+//
+// Rpcs must arrive in the order they are expected.  Any message that isn't
+// expected fails the test and is answered with an `<rpc-error>`.
+// `<close-session>` is answered with `<ok/>` unless it is expected.  When the
+// test finishes any expectations that weren't met fail the test.
+type Transport struct {
+	t         testing.TB
+	caps      []string
+	sessionID uint64
+
+	mu        sync.Mutex
+	steps     []*Step
+	sent      []string
+	hello     string
+	queue     [][]byte
+	queued    chan struct{}
+	pending   sync.WaitGroup
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewTransport returns a new scripted transport.  The server hello is sent as
+// soon as the transport is read from.
+func NewTransport(t testing.TB, opts ...Option) *Transport {
+	tr := &Transport{
+		t:         t,
+		sessionID: 1,
+		queued:    make(chan struct{}, 1),
+		done:      make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt.apply(tr)
+	}
+
+	var hello strings.Builder
+	fmt.Fprintf(&hello, `<hello xmlns=%q><capabilities>`, baseNamespace)
+	caps := append([]string{"urn:ietf:params:netconf:base:1.0", "urn:ietf:params:netconf:base:1.1"}, tr.caps...)
+	for _, c := range caps {
+		fmt.Fprintf(&hello, "<capability>%s</capability>", c)
+	}
+	fmt.Fprintf(&hello, "</capabilities><session-id>%d</session-id></hello>", tr.sessionID)
+	tr.push([]byte(hello.String()))
+
+	t.Cleanup(tr.verify)
+	return tr
+}
+
+// Step is an expected message and what to do when it is received.
+type Step struct {
+	desc  string
+	match func(msg []byte) bool
+
+	reply  func(msgID string) string
+	delay  time.Duration
+	notifs []string
+	close  bool
+}
+
+// Expect expects the next message to contain substr.  Set the reply with one
+// of the Respond methods; without one the message isn't answered.
+func (t *Transport) Expect(substr string) *Step {
+	return t.ExpectFunc(fmt.Sprintf("message containing %q", substr), func(msg []byte) bool {
+		return bytes.Contains(msg, []byte(substr))
+	})
+}
+
+// ExpectFunc expects the next message to match fn.  desc describes the message
+// in failures.
+func (t *Transport) ExpectFunc(desc string, fn func(msg []byte) bool) *Step {
+	s := &Step{desc: desc, match: fn}
+	t.mu.Lock()
+	t.steps = append(t.steps, s)
+	t.mu.Unlock()
+	return s
+}
+
+// Respond replies with an `<rpc-reply>` containing body and the message-id of
+// the request.
+func (s *Step) Respond(body string) *Step {
+	s.reply = func(msgID string) string {
+		return fmt.Sprintf(`<rpc-reply xmlns=%q message-id=%q>%s</rpc-reply>`, baseNamespace, msgID, body)
+	}
+	return s
+}
+
+// RespondOK replies with `<ok/>`.
+func (s *Step) RespondOK() *Step { return s.Respond("<ok/>") }
+
+// RespondData replies with a `<data>` element containing data.
+func (s *Step) RespondData(data string) *Step { return s.Respond("<data>" + data + "</data>") }
+
+// RespondError replies with an `<rpc-error>` with the given error-tag and
+// error-message.
+func (s *Step) RespondError(tag, msg string) *Step {
+	return s.Respond(rpcError(tag, msg))
+}
+
+// RespondRaw replies with msg as is.
+func (s *Step) RespondRaw(msg string) *Step {
+	s.reply = func(string) string { return msg }
+	return s
+}
+
+// After delays the reply by d.
+func (s *Step) After(d time.Duration) *Step {
+	s.delay = d
+	return s
+}
+
+// ThenNotify sends a notification with body (the event) after the reply.
+func (s *Step) ThenNotify(body string) *Step {
+	s.notifs = append(s.notifs, body)
+	return s
+}
+
+// ThenClose closes the transport after the reply as if the device dropped the
+// connection.
+func (s *Step) ThenClose() *Step {
+	s.close = true
+	return s
+}
+
+func rpcError(tag, msg string) string {
+	return "<rpc-error><error-type>protocol</error-type><error-tag>" + tag +
+		"</error-tag><error-severity>error</error-severity><error-message>" + msg +
+		"</error-message></rpc-error>"
+}
+
+// Notify sends a notification with body (the event) to the client now.
+func (t *Transport) Notify(body string) {
+	t.push([]byte(notification(body)))
+}
+
+func notification(body string) string {
+	return fmt.Sprintf(`<notification xmlns=%q><eventTime>%s</eventTime>%s</notification>`,
+		notifNamespace, time.Now().UTC().Format(time.RFC3339Nano), body)
+}
+
+// Sent returns all messages sent by the client except for the hello.
+func (t *Transport) Sent() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]string(nil), t.sent...)
+}
+
+// ClientHello returns the hello sent by the client or an empty string if it
+// hasn't been sent.
+func (t *Transport) ClientHello() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.hello
+}
+
+// Remaining returns the number of expected messages not yet received.
+func (t *Transport) Remaining() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.steps)
+}
+
+func (t *Transport) verify() {
+	t.mu.Lock()
+	_ = t.Close()
+	t.mu.Unlock()
+	t.pending.Wait()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, s := range t.steps {
+		t.t.Errorf("transporttest: expected %s but it was never sent", s.desc)
+	}
+}
+
+func (t *Transport) push(msg []byte) {
+	t.mu.Lock()
+	t.queue = append(t.queue, msg)
+	t.mu.Unlock()
+	select {
+	case t.queued <- struct{}{}:
+	default:
+	}
+}
+
+// MsgReader implements transport.Transport.
+func (t *Transport) MsgReader() (io.ReadCloser, error) {
+	for {
+		t.mu.Lock()
+		if len(t.queue) > 0 {
+			msg := t.queue[0]
+			t.queue = t.queue[1:]
+			t.mu.Unlock()
+			return io.NopCloser(bytes.NewReader(msg)), nil
+		}
+		t.mu.Unlock()
+
+		select {
+		case <-t.queued:
+		case <-t.done:
+			return nil, io.EOF
+		}
+	}
+}
+
+// MsgWriter implements transport.Transport.
+func (t *Transport) MsgWriter() (io.WriteCloser, error) {
+	select {
+	case <-t.done:
+		return nil, io.ErrClosedPipe
+	default:
+	}
+	return &writer{t: t}, nil
+}
+
+// Close implements transport.Transport.
+func (t *Transport) Close() error {
+	t.closeOnce.Do(func() { close(t.done) })
+	return nil
+}
+
+func (t *Transport) handle(msg []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// nothing is handled once closed so that verify can wait for the replies
+	// being sent.
+	select {
+	case <-t.done:
+		return
+	default:
+	}
+
+	if t.hello == "" && bytes.Contains(msg, []byte("<hello")) {
+		t.hello = string(msg)
+		return
+	}
+	t.sent = append(t.sent, string(msg))
+
+	var step *Step
+	switch {
+	case len(t.steps) > 0 && t.steps[0].match(msg):
+		step = t.steps[0]
+		t.steps = t.steps[1:]
+	case bytes.Contains(msg, []byte("<close-session")):
+		step = (&Step{}).RespondOK().ThenClose()
+	default:
+		t.t.Errorf("transporttest: unexpected message: %s", msg)
+		step = (&Step{}).RespondError("operation-not-supported", "unexpected message")
+	}
+
+	var msgID string
+	if m := msgIDRe.FindSubmatch(msg); m != nil {
+		msgID = string(m[1])
+	}
+
+	t.pending.Add(1)
+	go func() {
+		defer t.pending.Done()
+		if step.delay > 0 {
+			select {
+			case <-time.After(step.delay):
+			case <-t.done:
+				return
+			}
+		}
+		if step.reply != nil {
+			t.push([]byte(step.reply(msgID)))
+		}
+		for _, n := range step.notifs {
+			t.Notify(n)
+		}
+		if step.close {
+			// messages already queued are still read before the EOF.
+			_ = t.Close()
+		}
+	}()
+}
+
+type writer struct {
+	t   *Transport
+	buf bytes.Buffer
+}
+
+func (w *writer) Write(p []byte) (int, error) { return w.buf.Write(p) }
+
+func (w *writer) Close() error {
+	w.t.handle(w.buf.Bytes())
+	return nil
+}
@@ -0,0 +1,96 @@
+package transporttest_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nemith/netconf"
+	"github.com/nemith/netconf/transport/transporttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransport(t *testing.T) {
+	tr := transporttest.NewTransport(t, transporttest.WithSessionID(42),
+		transporttest.WithCapabilities("urn:ietf:params:netconf:capability:candidate:1.0"))
+	tr.Expect("<get-config>").RespondData("<system/>")
+	tr.Expect("<lock>").RespondError("lock-denied", "locked by session 7").After(10 * time.Millisecond)
+
+	notifs := make(chan netconf.Notification, 1)
+	tr.Expect("<commit").RespondOK().ThenNotify("<config-change/>")
+
+	sess, err := netconf.Open(tr, netconf.WithNotificationHandler(func(n netconf.Notification) { notifs <- n }))
+	require.NoError(t, err)
+	assert.EqualValues(t, 42, sess.SessionID())
+	assert.True(t, sess.Supports("urn:ietf:params:netconf:capability:candidate:1.0"))
+	assert.Contains(t, tr.ClientHello(), "<hello")
+
+	ctx := context.Background()
+	config, err := sess.GetConfig(ctx, netconf.Running)
+	require.NoError(t, err)
+	assert.Equal(t, "<system/>", string(config))
+
+	err = sess.Lock(ctx, netconf.Candidate)
+	var rpcErr netconf.RPCError
+	require.ErrorAs(t, err, &rpcErr)
+	assert.Equal(t, netconf.ErrLockDenied, rpcErr.Tag)
+	assert.Equal(t, "locked by session 7", rpcErr.Message)
+
+	require.NoError(t, sess.Commit(ctx))
+	select {
+	case n := <-notifs:
+		assert.Contains(t, string(n.Body), "<config-change/>")
+	case <-time.After(time.Second):
+		t.Fatal("notification not received")
+	}
+
+	require.NoError(t, sess.Close(ctx))
+	assert.Equal(t, 0, tr.Remaining())
+
+	sent := tr.Sent()
+	require.Len(t, sent, 4)
+	assert.Contains(t, sent[3], "<close-session")
+}
+
+func TestTransportThenClose(t *testing.T) {
+	tr := transporttest.NewTransport(t)
+	tr.Expect("<get-config>").RespondData("").ThenClose()
+
+	sess, err := netconf.Open(tr)
+	require.NoError(t, err)
+
+	_, err = sess.GetConfig(context.Background(), netconf.Running)
+	require.NoError(t, err)
+
+	select {
+	case <-sess.Done():
+	case <-time.After(time.Second):
+		t.Fatal("session not closed")
+	}
+}
+
+// recorder captures failures of the transport under test.
+type recorder struct {
+	testing.TB
+	errs []string
+}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.errs = append(r.errs, format)
+}
+
+func TestTransportUnexpected(t *testing.T) {
+	rec := &recorder{TB: t}
+	tr := transporttest.NewTransport(rec)
+	tr.Expect("<commit")
+
+	sess, err := netconf.Open(tr)
+	require.NoError(t, err)
+
+	_, err = sess.GetConfig(context.Background(), netconf.Running)
+	assert.ErrorContains(t, err, "unexpected message")
+	require.Len(t, rec.errs, 1)
+	assert.True(t, strings.HasPrefix(rec.errs[0], "transporttest: unexpected message"))
+}
@@ -0,0 +1,32 @@
+package transport
+
+import "fmt"
+
+// ExistingWriterError is returned from MsgWriter when the writer of a previous
+// message was never closed.  It matches [ErrExistingWriter] with errors.Is.
+type ExistingWriterError struct {
+	// Stack is the stack trace of the call to MsgWriter that returned the
+	// writer that is still open.  It is only recorded when the Framer was
+	// created with [WithWriterStacks].
+	Stack []byte
+}
+
+func (e *ExistingWriterError) Error() string {
+	if len(e.Stack) == 0 {
+		return ErrExistingWriter.Error()
+	}
+	return fmt.Sprintf("%v; writer acquired at:\n%s", ErrExistingWriter, e.Stack)
+}
+
+// Is makes the error match [ErrExistingWriter].
+func (e *ExistingWriterError) Is(target error) bool { return target == ErrExistingWriter }
+
+type writerStacksOpt struct{}
+
+func (writerStacksOpt) apply(cfg *framerConfig) { cfg.writerStacks = true }
+
+// WithWriterStacks records the stack trace of every call to MsgWriter so an
+// [ExistingWriterError] shows which call path leaked the open writer.  This
+// is meant for debugging as taking a stack trace for every message is
+// expensive.
+func WithWriterStacks() FramerOption { return writerStacksOpt{} }
@@ -0,0 +1,38 @@
+package transport
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func leakWriter(f *Framer) error {
+	_, err := f.MsgWriter()
+	return err
+}
+
+func TestExistingWriterError(t *testing.T) {
+	var buf bytes.Buffer
+	f := NewFramer(&buf, &buf)
+	require.NoError(t, leakWriter(f))
+
+	_, err := f.MsgWriter()
+	assert.ErrorIs(t, err, ErrExistingWriter)
+	var writerErr *ExistingWriterError
+	require.True(t, errors.As(err, &writerErr))
+	assert.Nil(t, writerErr.Stack)
+	assert.Equal(t, ErrExistingWriter.Error(), err.Error())
+}
+
+func TestWriterStacks(t *testing.T) {
+	var buf bytes.Buffer
+	f := NewFramer(&buf, &buf, WithWriterStacks())
+	require.NoError(t, leakWriter(f))
+
+	_, err := f.MsgWriter()
+	assert.ErrorIs(t, err, ErrExistingWriter)
+	assert.ErrorContains(t, err, "transport.leakWriter")
+}
@@ -0,0 +1,107 @@
+package netconf
+
+import (
+	"encoding/xml"
+	"fmt"
+)
+
+// RawMessage is a top-level message received from the server that is not a
+// `<rpc-reply>` or `<notification>`.
+type RawMessage struct {
+	XMLName xml.Name
+	Attrs   []xml.Attr `xml:",any,attr"`
+
+	// Body is the raw xml contents of the message element.
+	Body []byte `xml:",innerxml"`
+}
+
+// MessageHandler is called for top-level messages registered with
+// [WithMessageHandler].
+type MessageHandler func(msg RawMessage)
+
+type messageHandlerOpt struct {
+	name xml.Name
+	h    MessageHandler
+}
+
+func (o messageHandlerOpt) apply(cfg *sessionConfig) {
+	if cfg.messageHandlers == nil {
+		cfg.messageHandlers = make(map[xml.Name]MessageHandler)
+	}
+	cfg.messageHandlers[o.name] = o.h
+}
+
+// WithMessageHandler registers a handler for top-level messages with the
+// given name that are not `<rpc-reply>` or `<notification>` messages (i.e
+// vendor specific messages or `<hello>` retransmissions).  If the namespace
+// of name is empty the handler matches the local name in any namespace.  The
+// handler is called from the session's receive loop so it must not block.
+//
+// Without a handler unknown messages are reported to the [ErrorHandler],
+// except for `<hello>` retransmissions which are dropped.
+func WithMessageHandler(name xml.Name, h MessageHandler) SessionOption {
+	return messageHandlerOpt{name: name, h: h}
+}
+
+// WithIgnoredMessages silently discards top-level messages with the given
+// names.  Names are matched like [WithMessageHandler].
+func WithIgnoredMessages(names ...xml.Name) SessionOption {
+	return ignoredMessagesOpt(names)
+}
+
+type ignoredMessagesOpt []xml.Name
+
+func (o ignoredMessagesOpt) apply(cfg *sessionConfig) {
+	for _, name := range o {
+		messageHandlerOpt{name: name}.apply(cfg)
+	}
+}
+
+// UnknownMessages returns the number of messages received for each top-level
+// element that is not a `<rpc-reply>` or `<notification>`, including ones
+// that were handled or ignored.  `<hello>` retransmissions are only counted
+// when there is a handler for them.
+func (s *Session) UnknownMessages() map[xml.Name]uint64 {
+	s.unknownMu.Lock()
+	defer s.unknownMu.Unlock()
+
+	out := make(map[xml.Name]uint64, len(s.unknownCounts))
+	for name, n := range s.unknownCounts {
+		out[name] = n
+	}
+	return out
+}
+
+// handleUnknown counts and dispatches a message with an unknown root element.
+// Without a handler the message is an error unless optional is set, in which
+// case it is dropped without being counted.
+func (s *Session) handleUnknown(dec *xml.Decoder, root *xml.StartElement, optional bool) error {
+	h, ok := s.messageHandlers[root.Name]
+	if !ok {
+		h, ok = s.messageHandlers[xml.Name{Local: root.Name.Local}]
+	}
+	if !ok && optional {
+		return nil
+	}
+
+	s.unknownMu.Lock()
+	if s.unknownCounts == nil {
+		s.unknownCounts = make(map[xml.Name]uint64)
+	}
+	s.unknownCounts[root.Name]++
+	s.unknownMu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("unknown message type: %q", root.Name.Local)
+	}
+	if h == nil {
+		return nil
+	}
+
+	var msg RawMessage
+	if err := dec.DecodeElement(&msg, root); err != nil {
+		return fmt.Errorf("failed to decode %q message: %w", root.Name.Local, err)
+	}
+	_ = s.safeCall("message handler", func() { h(msg) })
+	return nil
+}
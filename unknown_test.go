@@ -0,0 +1,72 @@
+package netconf
+
+import (
+	"encoding/xml"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnknownMessages(t *testing.T) {
+	var got []RawMessage
+	tr := newTestServer(t).transport()
+	sess := newSession(tr,
+		WithMessageHandler(xml.Name{Space: "urn:vendor", Local: "chatter"}, func(msg RawMessage) {
+			got = append(got, msg)
+		}),
+		WithIgnoredMessages(xml.Name{Local: "keepalive"}),
+	)
+
+	tr.pushMsg(`<chatter xmlns="urn:vendor" level="info"><text>hi</text></chatter>`)
+	require.NoError(t, sess.recvMsg())
+
+	tr.pushMsg(`<keepalive xmlns="urn:vendor"/>`)
+	require.NoError(t, sess.recvMsg())
+
+	// same local name in another namespace isn't handled
+	tr.pushMsg(`<chatter xmlns="urn:other"/>`)
+	assert.ErrorContains(t, sess.recvMsg(), `unknown message type: "chatter"`)
+
+	require.Len(t, got, 1)
+	assert.Equal(t, xml.Name{Space: "urn:vendor", Local: "chatter"}, got[0].XMLName)
+	assert.Equal(t, "<text>hi</text>", string(got[0].Body))
+	assert.Contains(t, got[0].Attrs, xml.Attr{Name: xml.Name{Local: "level"}, Value: "info"})
+
+	assert.Equal(t, map[xml.Name]uint64{
+		{Space: "urn:vendor", Local: "chatter"}:   1,
+		{Space: "urn:other", Local: "chatter"}:    1,
+		{Space: "urn:vendor", Local: "keepalive"}: 1,
+	}, sess.UnknownMessages())
+}
+
+func TestRepeatedHello(t *testing.T) {
+	tr := newTestServer(t).transport()
+	sess := newSession(tr)
+
+	// dropped without a handler.
+	tr.pushMsg(`<hello xmlns="urn:ietf:params:xml:ns:netconf:base:1.0"><capabilities/></hello>`)
+	require.NoError(t, sess.recvMsg())
+	assert.Empty(t, sess.UnknownMessages())
+
+	var got []RawMessage
+	tr = newTestServer(t).transport()
+	sess = newSession(tr, WithMessageHandler(xml.Name{Local: "hello"}, func(msg RawMessage) {
+		got = append(got, msg)
+	}))
+
+	tr.pushMsg(`<hello xmlns="urn:ietf:params:xml:ns:netconf:base:1.0"><capabilities/></hello>`)
+	require.NoError(t, sess.recvMsg())
+	require.Len(t, got, 1)
+	assert.Equal(t, "<capabilities/>", string(got[0].Body))
+
+	// ignored hellos are counted like any other ignored message.
+	tr = newTestServer(t).transport()
+	sess = newSession(tr, WithIgnoredMessages(xml.Name{Local: "hello"}))
+
+	tr.pushMsg(`<hello xmlns="urn:ietf:params:xml:ns:netconf:base:1.0"/>`)
+	require.NoError(t, sess.recvMsg())
+	assert.Equal(t, map[xml.Name]uint64{
+		{Space: ncNamespace, Local: "hello"}: 1,
+	}, sess.UnknownMessages())
+}
@@ -0,0 +1,83 @@
+package netconf
+
+import "fmt"
+
+// UnmatchedReplyPolicy controls how the session handles a `<rpc-reply>` with
+// a missing message-id or a message-id that doesn't match any outstanding
+// rpc.  Some devices omit or mangle the message-id in their replies.
+type UnmatchedReplyPolicy int
+
+const (
+	// UnmatchedReplyDrop discards the reply and reports an
+	// [UnmatchedReplyError] to the session's [ErrorHandler].  The rpc waiting
+	// for the reply will only return once its context is done.  This is the
+	// default.
+	UnmatchedReplyDrop UnmatchedReplyPolicy = iota
+
+	// UnmatchedReplyMatchSingle delivers the reply to the outstanding rpc if
+	// there is exactly one.  Otherwise the reply is dropped.
+	UnmatchedReplyMatchSingle
+
+	// UnmatchedReplyFail fails the outstanding rpc with an
+	// [UnmatchedReplyError] if there is exactly one.  Otherwise the reply is
+	// dropped.
+	UnmatchedReplyFail
+)
+
+type unmatchedReplyPolicyOpt UnmatchedReplyPolicy
+
+func (o unmatchedReplyPolicyOpt) apply(cfg *sessionConfig) {
+	cfg.unmatchedReplyPolicy = UnmatchedReplyPolicy(o)
+}
+
+// WithUnmatchedReplyPolicy sets how replies that don't match an outstanding
+// rpc are handled.
+func WithUnmatchedReplyPolicy(p UnmatchedReplyPolicy) SessionOption {
+	return unmatchedReplyPolicyOpt(p)
+}
+
+// UnmatchedReplyError is returned for a `<rpc-reply>` that couldn't be matched
+// to an outstanding rpc by its message-id.
+type UnmatchedReplyError struct {
+	// MessageID is the message-id of the reply.  It is empty if the reply
+	// didn't have one.
+	MessageID string
+}
+
+func (e *UnmatchedReplyError) Error() string {
+	if e.MessageID == "" {
+		return "received rpc-reply without a message-id"
+	}
+	return fmt.Sprintf("cannot find reply channel for message-id: %q", e.MessageID)
+}
+
+// unmatchedReply applies the unmatched reply policy.  It returns the request
+// the reply should be delivered to or nil if the reply was handled.
+func (s *Session) unmatchedReply(reply Reply) (*req, error) {
+	unmatchedErr := &UnmatchedReplyError{MessageID: reply.MessageID}
+	if s.unmatchedReplyPolicy == UnmatchedReplyDrop {
+		return nil, unmatchedErr
+	}
+
+	s.mu.Lock()
+	if len(s.reqs) != 1 {
+		s.mu.Unlock()
+		return nil, unmatchedErr
+	}
+	var (
+		msgID string
+		r     *req
+	)
+	for msgID, r = range s.reqs {
+	}
+	delete(s.reqs, msgID)
+	s.mu.Unlock()
+
+	if s.unmatchedReplyPolicy == UnmatchedReplyMatchSingle {
+		return r, nil
+	}
+
+	r.err = fmt.Errorf("rpc %q failed: %w", msgID, unmatchedErr)
+	close(r.reply)
+	return nil, nil
+}
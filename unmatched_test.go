@@ -0,0 +1,61 @@
+package netconf
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnmatchedReply(t *testing.T) {
+	tt := []struct {
+		name    string
+		policy  UnmatchedReplyPolicy
+		reply   string
+		wantErr bool
+	}{
+		{"match missing", UnmatchedReplyMatchSingle, `<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0"><ok/></rpc-reply>`, false},
+		{"match unknown", UnmatchedReplyMatchSingle, `<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="42"><ok/></rpc-reply>`, false},
+		{"fail unknown", UnmatchedReplyFail, `<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="42"><ok/></rpc-reply>`, true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := newTestServer(t)
+			sess := newSession(ts.transport(), WithUnmatchedReplyPolicy(tc.policy))
+			go sess.recv()
+
+			ts.queueRespString(tc.reply)
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			err := sess.Commit(ctx)
+			if !tc.wantErr {
+				assert.NoError(t, err)
+				return
+			}
+
+			var unmatchedErr *UnmatchedReplyError
+			require.ErrorAs(t, err, &unmatchedErr)
+			assert.Equal(t, "42", unmatchedErr.MessageID)
+			assert.NotErrorIs(t, err, context.DeadlineExceeded)
+		})
+	}
+}
+
+func TestUnmatchedReplyDrop(t *testing.T) {
+	tr := newTestServer(t).transport()
+	sess := newSession(tr, WithUnmatchedReplyPolicy(UnmatchedReplyMatchSingle))
+
+	// with more than one outstanding rpc the reply can't be matched
+	sess.reqs["1"] = &req{reply: make(chan Reply, 1), ctx: context.Background()}
+	sess.reqs["2"] = &req{reply: make(chan Reply, 1), ctx: context.Background()}
+
+	tr.pushMsg(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0"><ok/></rpc-reply>`)
+	err := sess.recvMsg()
+	var unmatchedErr *UnmatchedReplyError
+	require.ErrorAs(t, err, &unmatchedErr)
+	assert.Equal(t, "", unmatchedErr.MessageID)
+	assert.Len(t, sess.reqs, 2)
+}
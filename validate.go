@@ -0,0 +1,121 @@
+package netconf
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrMalformedRequest is returned when a rpc fails the checks enabled with
+// [WithRequestValidation].  The request is not sent.
+var ErrMalformedRequest = errors.New("netconf: malformed request")
+
+// RequestValidation is the level of checking done on outgoing rpcs.
+type RequestValidation int
+
+const (
+	// ValidateNone sends requests without checking them.  This is the
+	// default.
+	ValidateNone RequestValidation = iota
+
+	// ValidateWellFormed checks that requests are well-formed xml.  Values
+	// encoded by encoding/xml are always well-formed but raw xml (i.e
+	// [RawXML] or fields tagged with `,innerxml`) is sent as is.
+	ValidateWellFormed
+
+	// ValidateNamespaces checks that requests are well-formed and that all
+	// namespace prefixes used by elements and attributes are declared.
+	ValidateNamespaces
+)
+
+type requestValidationOpt RequestValidation
+
+func (o requestValidationOpt) apply(cfg *sessionConfig) {
+	cfg.requestValidation = RequestValidation(o)
+}
+
+// WithRequestValidation checks every outgoing rpc before it is sent and fails
+// it locally with [ErrMalformedRequest] instead of sending something the
+// server will reject with an unhelpful `malformed-message` error (i.e raw
+// config containing an unescaped `&`).  This costs parsing every request.
+func WithRequestValidation(v RequestValidation) SessionOption {
+	return requestValidationOpt(v)
+}
+
+// validateRequest checks an encoded rpc according to the validation level.
+func validateRequest(data []byte, level RequestValidation) error {
+	if level == ValidateNone {
+		return nil
+	}
+
+	// RawToken is used to see the prefixes as written which means checking
+	// that elements are balanced is left to us.
+	dec := xml.NewDecoder(bytes.NewReader(data))
+
+	// scopes holds the open elements and the prefixes they declare.
+	type scope struct {
+		name     xml.Name
+		prefixes map[string]bool
+	}
+	var scopes []scope
+
+	declared := func(prefix string) bool {
+		if prefix == "xml" || prefix == "xmlns" {
+			return true
+		}
+		for i := len(scopes) - 1; i >= 0; i-- {
+			if scopes[i].prefixes[prefix] {
+				return true
+			}
+		}
+		return false
+	}
+
+	for {
+		tok, err := dec.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrMalformedRequest, err)
+		}
+
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			sc := scope{name: tok.Name, prefixes: make(map[string]bool)}
+			for _, attr := range tok.Attr {
+				if attr.Name.Space == "xmlns" {
+					sc.prefixes[attr.Name.Local] = true
+				}
+			}
+			scopes = append(scopes, sc)
+
+			if level < ValidateNamespaces {
+				continue
+			}
+			if tok.Name.Space != "" && !declared(tok.Name.Space) {
+				return fmt.Errorf("%w: element %q uses undeclared namespace prefix %q", ErrMalformedRequest, tok.Name.Local, tok.Name.Space)
+			}
+			for _, attr := range tok.Attr {
+				if attr.Name.Space != "" && !declared(attr.Name.Space) {
+					return fmt.Errorf("%w: attribute %q uses undeclared namespace prefix %q", ErrMalformedRequest, attr.Name.Local, attr.Name.Space)
+				}
+			}
+		case xml.EndElement:
+			if len(scopes) == 0 {
+				return fmt.Errorf("%w: unexpected end element </%s>", ErrMalformedRequest, tok.Name.Local)
+			}
+			if open := scopes[len(scopes)-1].name; open != tok.Name {
+				return fmt.Errorf("%w: element <%s> closed by </%s>", ErrMalformedRequest, open.Local, tok.Name.Local)
+			}
+			scopes = scopes[:len(scopes)-1]
+		}
+	}
+
+	if len(scopes) != 0 {
+		return fmt.Errorf("%w: unclosed element <%s>", ErrMalformedRequest, scopes[len(scopes)-1].name.Local)
+	}
+	return nil
+}
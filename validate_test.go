@@ -0,0 +1,57 @@
+package netconf
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateRequest(t *testing.T) {
+	tt := []struct {
+		name    string
+		level   RequestValidation
+		in      string
+		wantErr string
+	}{
+		{"none", ValidateNone, `<rpc><a>&</a></rpc>`, ""},
+		{"ok", ValidateWellFormed, `<rpc xmlns="urn:x"><a>b &amp; c</a></rpc>`, ""},
+		{"ampersand", ValidateWellFormed, `<rpc><description>R&D</description></rpc>`, "invalid character entity &D"},
+		{"mismatched", ValidateWellFormed, `<rpc><a></b></rpc>`, "element <a> closed by </b>"},
+		{"unclosed", ValidateWellFormed, `<rpc><a>`, "unclosed element"},
+		{"prefix ignored", ValidateWellFormed, `<rpc><jc:a/></rpc>`, ""},
+		{"declared prefix", ValidateNamespaces, `<rpc xmlns:jc="urn:jc"><x><jc:a jc:b="1"/></x></rpc>`, ""},
+		{"undeclared element", ValidateNamespaces, `<rpc><x xmlns:jc="urn:jc"/><jc:a/></rpc>`, `element "a" uses undeclared namespace prefix "jc"`},
+		{"undeclared attr", ValidateNamespaces, `<rpc><a nc:operation="delete"/></rpc>`, `attribute "operation" uses undeclared namespace prefix "nc"`},
+		{"xml prefix", ValidateNamespaces, `<rpc><a xml:lang="en"/></rpc>`, ""},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateRequest([]byte(tc.in), tc.level)
+			if tc.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, ErrMalformedRequest)
+			assert.ErrorContains(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestRequestValidation(t *testing.T) {
+	ts := newTestServer(t)
+	sess := newSession(ts.transport(), WithRequestValidation(ValidateWellFormed))
+	go sess.recv()
+
+	err := sess.EditConfig(context.Background(), Candidate, "<system><description>R&D</description></system>")
+	require.ErrorIs(t, err, ErrMalformedRequest)
+
+	// nothing was sent so the next request is the first one the server sees.
+	ts.queueRespString(`<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="2"><ok/></rpc-reply>`)
+	require.NoError(t, sess.EditConfig(context.Background(), Candidate, "<system><description>R&amp;D</description></system>"))
+	req, err := ts.popReqString()
+	require.NoError(t, err)
+	assert.Contains(t, req, "R&amp;D")
+}
@@ -0,0 +1,172 @@
+package netconf
+
+import (
+	"fmt"
+
+	"github.com/nemith/netconf/xmltree"
+)
+
+const (
+	// WithDefaultsCapability is the capability advertised by servers
+	// supporting RFC6243 `<with-defaults>` retrieval.
+	WithDefaultsCapability = "urn:ietf:params:netconf:capability:with-defaults:1.0"
+
+	// WithDefaultsNamespace is the namespace of the `default` attribute used
+	// to tag default values in replies with the report-all-tagged mode.
+	WithDefaultsNamespace = "urn:ietf:params:xml:ns:netconf:default:1.0"
+)
+
+// DefaultsMode is a RFC6243 mode for reporting default data.
+type DefaultsMode string
+
+const (
+	// DefaultsReportAll reports all data including default values.
+	DefaultsReportAll DefaultsMode = "report-all"
+
+	// DefaultsReportAllTagged reports all data and tags default values with
+	// a `default="true"` attribute in the [WithDefaultsNamespace] namespace.
+	DefaultsReportAllTagged DefaultsMode = "report-all-tagged"
+
+	// DefaultsTrim omits data that is set to its default value.
+	DefaultsTrim DefaultsMode = "trim"
+
+	// DefaultsExplicit reports only data explicitly set by a client.
+	DefaultsExplicit DefaultsMode = "explicit"
+)
+
+// WithDefaultsSupport describes the RFC6243 support advertised by a server.
+type WithDefaultsSupport struct {
+	// BasicMode is the mode used by the server when no `<with-defaults>`
+	// parameter is given.
+	BasicMode DefaultsMode
+
+	// AlsoSupported are the other modes the server accepts in a
+	// `<with-defaults>` parameter.
+	AlsoSupported []DefaultsMode
+}
+
+// Supports returns true if the mode can be requested from the server.
+func (w WithDefaultsSupport) Supports(mode DefaultsMode) bool {
+	if w.BasicMode == mode {
+		return true
+	}
+	for _, m := range w.AlsoSupported {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// WithDefaults returns the RFC6243 modes advertised by the server.  The
+// returned bool is false if the server doesn't support the `:with-defaults`
+// capability.
+func (s *Session) WithDefaults() (WithDefaultsSupport, bool) {
+	c, ok := s.serverCaps.Capability(WithDefaultsCapability)
+	if !ok {
+		return WithDefaultsSupport{}, false
+	}
+
+	w := WithDefaultsSupport{
+		BasicMode: DefaultsMode(c.Params.Get("basic-mode")),
+	}
+	for _, m := range c.list("also-supported") {
+		w.AlsoSupported = append(w.AlsoSupported, DefaultsMode(m))
+	}
+	return w, true
+}
+
+// isDefaultTagged reports if a node is tagged as a default value.
+func isDefaultTagged(n *xmltree.Node) bool {
+	v, ok := n.Attr(WithDefaultsNamespace, "default")
+	return ok && (v == "true" || v == "1")
+}
+
+func walkNodes(nodes []*xmltree.Node, fn func(n *xmltree.Node, path string), prefix string) {
+	for _, n := range nodes {
+		path := prefix + "/" + n.Name.Local
+		fn(n, path)
+		walkNodes(n.Children, fn, path)
+	}
+}
+
+// DefaultsTagged returns true if the data (i.e the contents of a `<data>` or
+// `<config>` element) contains any values tagged as defaults, which means it
+// was returned with the report-all-tagged mode.
+func DefaultsTagged(data []byte) (bool, error) {
+	nodes, err := xmltree.Parse(data)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse data: %w", err)
+	}
+
+	tagged := false
+	walkNodes(nodes, func(n *xmltree.Node, _ string) {
+		tagged = tagged || isDefaultTagged(n)
+	}, "")
+	return tagged, nil
+}
+
+// DefaultPaths returns the paths (i.e `/system/ntp/enabled`) of all values
+// tagged as defaults in data returned with the report-all-tagged mode.  Paths
+// use the local names of the elements and don't include list keys.
+func DefaultPaths(data []byte) ([]string, error) {
+	nodes, err := xmltree.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse data: %w", err)
+	}
+
+	var paths []string
+	walkNodes(nodes, func(n *xmltree.Node, path string) {
+		if isDefaultTagged(n) {
+			paths = append(paths, path)
+		}
+	}, "")
+	return paths, nil
+}
+
+// NormalizeDefaults converts data returned with the report-all-tagged mode
+// into the equivalent output of another mode so that data retrieved from
+// devices using different modes can be compared.
+//
+// With [DefaultsReportAll] the default tags are removed and with
+// [DefaultsTrim] the tagged values are removed.  Converting to any other mode
+// is an error as it can't be done without the device's schema.  Data without
+// tags is only reformatted.
+func NormalizeDefaults(data []byte, mode DefaultsMode) ([]byte, error) {
+	nodes, err := xmltree.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse data: %w", err)
+	}
+
+	switch mode {
+	case DefaultsReportAll:
+		walkNodes(nodes, func(n *xmltree.Node, _ string) {
+			n.RemoveAttr(WithDefaultsNamespace, "default")
+		}, "")
+	case DefaultsTrim:
+		nodes = trimDefaults(nodes)
+	case DefaultsReportAllTagged:
+	default:
+		return nil, fmt.Errorf("cannot normalize tagged defaults to %q", mode)
+	}
+
+	return xmltree.Marshal(nodes, "")
+}
+
+func trimDefaults(nodes []*xmltree.Node) []*xmltree.Node {
+	out := nodes[:0]
+	for _, n := range nodes {
+		if isDefaultTagged(n) {
+			continue
+		}
+		if !n.IsLeaf() {
+			n.Children = trimDefaults(n.Children)
+			// containers only holding defaults are not reported with trim.
+			if len(n.Children) == 0 {
+				continue
+			}
+		}
+		out = append(out, n)
+	}
+	return out
+}
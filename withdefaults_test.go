@@ -0,0 +1,68 @@
+package netconf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const taggedData = `<interfaces xmlns:wd="urn:ietf:params:xml:ns:netconf:default:1.0">
+  <interface>
+    <name>eth0</name>
+    <mtu wd:default="true">1500</mtu>
+    <status>up</status>
+  </interface>
+  <defaults>
+    <enabled wd:default="true">true</enabled>
+  </defaults>
+</interfaces>`
+
+func TestWithDefaults(t *testing.T) {
+	sess := newSession(newTestServer(t).transport())
+	_, ok := sess.WithDefaults()
+	assert.False(t, ok)
+
+	sess.serverCaps = NewCapabilitySet(WithDefaultsCapability + "?basic-mode=explicit&amp;also-supported=report-all,report-all-tagged")
+	wd, ok := sess.WithDefaults()
+	require.True(t, ok)
+	assert.Equal(t, DefaultsExplicit, wd.BasicMode)
+	assert.Equal(t, []DefaultsMode{DefaultsReportAll, DefaultsReportAllTagged}, wd.AlsoSupported)
+	assert.True(t, wd.Supports(DefaultsReportAllTagged))
+	assert.False(t, wd.Supports(DefaultsTrim))
+}
+
+func TestDefaultsTagged(t *testing.T) {
+	tagged, err := DefaultsTagged([]byte(taggedData))
+	require.NoError(t, err)
+	assert.True(t, tagged)
+
+	tagged, err = DefaultsTagged([]byte(`<interfaces><interface><name>eth0</name></interface></interfaces>`))
+	require.NoError(t, err)
+	assert.False(t, tagged)
+
+	paths, err := DefaultPaths([]byte(taggedData))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/interfaces/interface/mtu", "/interfaces/defaults/enabled"}, paths)
+}
+
+func TestNormalizeDefaults(t *testing.T) {
+	tt := []struct {
+		mode DefaultsMode
+		want string
+	}{
+		{DefaultsReportAll, `<interfaces><interface><name>eth0</name><mtu>1500</mtu><status>up</status></interface><defaults><enabled>true</enabled></defaults></interfaces>`},
+		{DefaultsTrim, `<interfaces><interface><name>eth0</name><status>up</status></interface></interfaces>`},
+	}
+
+	for _, tc := range tt {
+		t.Run(string(tc.mode), func(t *testing.T) {
+			out, err := NormalizeDefaults([]byte(taggedData), tc.mode)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, string(out))
+		})
+	}
+
+	_, err := NormalizeDefaults([]byte(taggedData), DefaultsExplicit)
+	assert.Error(t, err)
+}
@@ -0,0 +1,75 @@
+package netconf
+
+import "context"
+
+// outMsg is an encoded message waiting to be written by the writer goroutine.
+type outMsg struct {
+	ctx  context.Context
+	data []byte
+	err  chan error
+}
+
+// writeLoop writes queued messages in the order they were queued.  Writing
+// happens without holding the session lock so a large message only delays
+// the messages queued after it and never the handling of replies.
+func (s *Session) writeLoop() {
+	for {
+		select {
+		case m := <-s.writeQ:
+			// don't bother writing requests that were given up on while
+			// waiting in the queue.
+			if err := m.ctx.Err(); err != nil {
+				m.err <- err
+				continue
+			}
+			m.err <- s.writeRaw(m.ctx, m.data)
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// queueWrite queues an encoded message to be written and waits until it has
+// been written.
+func (s *Session) queueWrite(ctx context.Context, data []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m := &outMsg{ctx: ctx, data: data, err: make(chan error, 1)}
+	select {
+	case s.writeQ <- m:
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-s.done:
+		return ErrClosed
+	}
+	return <-m.err
+}
+
+// writeRaw writes a message.  If the transport supports deadlines the write is
+// interrupted once ctx is done.  As the message may have been partially
+// written the session is closed in that case.
+func (s *Session) writeRaw(ctx context.Context, data []byte) error {
+	stop := s.interruptWrite(ctx)
+	err := s.writeMsgData(data)
+	stop(err)
+	if isDeadlineErr(err) {
+		_ = s.tr.Close()
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+	}
+	return err
+}
+
+func (s *Session) writeMsgData(data []byte) error {
+	w, err := s.msgWriter()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	return w.Close()
+}
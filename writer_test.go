@@ -0,0 +1,70 @@
+package netconf
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSlowWriteDoesNotBlockReplies checks that a reply can be handled while
+// another request is still being written.
+func TestSlowWriteDoesNotBlockReplies(t *testing.T) {
+	release := make(chan struct{})
+	sendReply := make(chan struct{})
+
+	tr := newTestTransport(func(r io.ReadCloser, w io.WriteCloser) {
+		head := make([]byte, 128)
+		n, _ := io.ReadFull(r, head)
+		if strings.Contains(string(head[:n]), "<slow>") {
+			<-release
+		} else {
+			<-sendReply
+		}
+		_, _ = io.Copy(io.Discard, r)
+
+		id := regexp.MustCompile(`message-id="([^"]+)"`).FindStringSubmatch(string(head[:n]))[1]
+		fmt.Fprintf(w, `<rpc-reply xmlns="urn:ietf:params:xml:ns:netconf:base:1.0" message-id="%s"><ok/></rpc-reply>`, id)
+		w.Close()
+	})
+	sess := newSession(tr)
+	go sess.recv()
+
+	first := make(chan error, 1)
+	go func() {
+		_, err := sess.Do(context.Background(), &LockReq{Target: Running})
+		first <- err
+	}()
+
+	// wait for the first request to be written before starting the slow one.
+	require.Eventually(t, func() bool { return !sess.LastSent().IsZero() }, time.Second, time.Millisecond)
+
+	type slowReq struct {
+		XMLName xml.Name `xml:"slow"`
+		Data    string   `xml:"data"`
+	}
+	slow := make(chan error, 1)
+	go func() {
+		_, err := sess.Do(context.Background(), &slowReq{Data: strings.Repeat("x", 1<<20)})
+		slow <- err
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	close(sendReply)
+	select {
+	case err := <-first:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("reply blocked by slow write")
+	}
+
+	close(release)
+	assert.NoError(t, <-slow)
+}
@@ -0,0 +1,159 @@
+package xmltree
+
+import (
+	"bytes"
+	"encoding/xml"
+	"sort"
+	"strconv"
+)
+
+// Equal reports whether two lists of nodes are semantically equal.  Elements
+// and attributes are compared by namespace and not by prefix, the order of
+// attributes doesn't matter and the order of elements does.  The form of
+// empty elements is lost when parsing and so is leading and trailing
+// whitespace of text (see [Node.Text]): leaf text that only differs in the
+// whitespace around it compares equal.  Compare the text of the documents
+// instead when that whitespace is significant.
+func Equal(a, b []*Node) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !nodeEqual(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func nodeEqual(a, b *Node) bool {
+	if a.Name != b.Name || a.Text != b.Text || len(a.Attrs) != len(b.Attrs) {
+		return false
+	}
+	aa, ba := sortedAttrs(a.Attrs), sortedAttrs(b.Attrs)
+	for i := range aa {
+		if aa[i] != ba[i] {
+			return false
+		}
+	}
+	return Equal(a.Children, b.Children)
+}
+
+func sortedAttrs(attrs []xml.Attr) []xml.Attr {
+	sorted := append([]xml.Attr(nil), attrs...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Name.Space != sorted[j].Name.Space {
+			return sorted[i].Name.Space < sorted[j].Name.Space
+		}
+		return sorted[i].Name.Local < sorted[j].Name.Local
+	})
+	return sorted
+}
+
+// Canonicalize parses XML and returns it in the canonical form written by
+// [Canonical].
+func Canonicalize(data []byte) ([]byte, error) {
+	nodes, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	return Canonical(nodes), nil
+}
+
+// Canonical returns the nodes as XML in a canonical form so that
+// semantically equal trees (see [Equal]) are written identically and can be
+// compared or diffed as text:
+//
+//   - one element per line indented with two spaces.
+//   - a default namespace declaration only where the namespace changes.
+//   - attributes sorted by namespace and name.
+//   - namespaced attributes prefixed `ns1`, `ns2`... in order of first use
+//     and declared on the outermost elements using them.
+//   - empty elements written as `<name/>`.
+func Canonical(nodes []*Node) []byte {
+	c := canonicalizer{prefixes: make(map[string]string)}
+	for _, n := range nodes {
+		c.node(n, "", nil, 0)
+	}
+	return c.buf.Bytes()
+}
+
+type canonicalizer struct {
+	buf      bytes.Buffer
+	prefixes map[string]string
+}
+
+// node writes an element.  declared are the namespaces with a prefix declared
+// by the ancestors of n.
+func (c *canonicalizer) node(n *Node, parentSpace string, declared map[string]bool, depth int) {
+	for i := 0; i < depth; i++ {
+		c.buf.WriteString("  ")
+	}
+	c.buf.WriteByte('<')
+	c.buf.WriteString(n.Name.Local)
+	if n.Name.Space != parentSpace {
+		c.attr("xmlns", n.Name.Space)
+	}
+
+	attrs := sortedAttrs(n.Attrs)
+	for _, a := range attrs {
+		if a.Name.Space == "" {
+			continue
+		}
+		if declared[a.Name.Space] {
+			continue
+		}
+		prefix, ok := c.prefixes[a.Name.Space]
+		if !ok {
+			prefix = "ns" + strconv.Itoa(len(c.prefixes)+1)
+			c.prefixes[a.Name.Space] = prefix
+		}
+		c.attr("xmlns:"+prefix, a.Name.Space)
+
+		scope := make(map[string]bool, len(declared)+1)
+		for ns := range declared {
+			scope[ns] = true
+		}
+		scope[a.Name.Space] = true
+		declared = scope
+	}
+	for _, a := range attrs {
+		name := a.Name.Local
+		if a.Name.Space != "" {
+			name = c.prefixes[a.Name.Space] + ":" + name
+		}
+		c.attr(name, a.Value)
+	}
+
+	switch {
+	case !n.IsLeaf():
+		c.buf.WriteString(">\n")
+		for _, child := range n.Children {
+			c.node(child, n.Name.Space, declared, depth+1)
+		}
+		for i := 0; i < depth; i++ {
+			c.buf.WriteString("  ")
+		}
+		c.end(n)
+	case n.Text != "":
+		c.buf.WriteByte('>')
+		_ = xml.EscapeText(&c.buf, []byte(n.Text))
+		c.end(n)
+	default:
+		c.buf.WriteString("/>\n")
+	}
+}
+
+func (c *canonicalizer) attr(name, value string) {
+	c.buf.WriteByte(' ')
+	c.buf.WriteString(name)
+	c.buf.WriteString(`="`)
+	_ = xml.EscapeText(&c.buf, []byte(value))
+	c.buf.WriteByte('"')
+}
+
+func (c *canonicalizer) end(n *Node) {
+	c.buf.WriteString("</")
+	c.buf.WriteString(n.Name.Local)
+	c.buf.WriteString(">\n")
+}
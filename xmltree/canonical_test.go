@@ -0,0 +1,108 @@
+package xmltree
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEqual(t *testing.T) {
+	tt := []struct {
+		name  string
+		a, b  string
+		equal bool
+	}{
+		{
+			name:  "prefixes",
+			a:     `<if:interfaces xmlns:if="urn:if"><if:interface><if:name>eth0</if:name></if:interface></if:interfaces>`,
+			b:     `<interfaces xmlns="urn:if"><interface><name>eth0</name></interface></interfaces>`,
+			equal: true,
+		},
+		{
+			name:  "attribute order",
+			a:     `<a xmlns:x="urn:x" x:one="1" two="2"/>`,
+			b:     `<a xmlns:y="urn:x" two="2" y:one="1"/>`,
+			equal: true,
+		},
+		{
+			name:  "whitespace and empty elements",
+			a:     "<a>\n  <b>  text </b>\n  <c></c>\n</a>",
+			b:     "<a><b>text</b><c/></a>",
+			equal: true,
+		},
+		{
+			name: "whitespace inside text",
+			a:    "<a>one two</a>",
+			b:    "<a>one  two</a>",
+		},
+		{
+			name: "namespace",
+			a:    `<a xmlns="urn:x"/>`,
+			b:    `<a xmlns="urn:y"/>`,
+		},
+		{
+			name: "attribute value",
+			a:    `<a b="1"/>`,
+			b:    `<a b="2"/>`,
+		},
+		{
+			name: "text",
+			a:    `<a>1</a>`,
+			b:    `<a>2</a>`,
+		},
+		{
+			name: "element order",
+			a:    `<a><b/><c/></a>`,
+			b:    `<a><c/><b/></a>`,
+		},
+		{
+			name: "extra element",
+			a:    `<a><b/></a>`,
+			b:    `<a><b/><b/></a>`,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			a, err := Parse([]byte(tc.a))
+			require.NoError(t, err)
+			b, err := Parse([]byte(tc.b))
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.equal, Equal(a, b))
+
+			ca, cb := Canonical(a), Canonical(b)
+			if tc.equal {
+				assert.Equal(t, string(ca), string(cb))
+			} else {
+				assert.NotEqual(t, string(ca), string(cb))
+			}
+		})
+	}
+}
+
+func TestCanonicalize(t *testing.T) {
+	got, err := Canonicalize([]byte(`<config xmlns="urn:ietf:params:xml:ns:netconf:base:1.0">
+<system xmlns="urn:sys" xmlns:nc="urn:ietf:params:xml:ns:netconf:base:1.0" nc:operation="merge">
+<hostname>a &amp; b</hostname><ntp enabled="true" xmlns:md="urn:md" md:tag="x"></ntp>
+</system><other xmlns="" xmlns:md="urn:md" md:tag="y"/></config>`))
+	require.NoError(t, err)
+
+	assert.Equal(t, `<config xmlns="urn:ietf:params:xml:ns:netconf:base:1.0">
+  <system xmlns="urn:sys" xmlns:ns1="urn:ietf:params:xml:ns:netconf:base:1.0" ns1:operation="merge">
+    <hostname>a &amp; b</hostname>
+    <ntp xmlns:ns2="urn:md" enabled="true" ns2:tag="x"/>
+  </system>
+  <other xmlns="" xmlns:ns2="urn:md" ns2:tag="y"/>
+</config>
+`, string(got))
+
+	// the canonical form parses back to the same tree.
+	again, err := Canonicalize(got)
+	require.NoError(t, err)
+	assert.Equal(t, string(got), string(again))
+
+	_, err = Canonicalize([]byte("<a>"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,253 @@
+package xmltree
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ChangeType is the kind of a [Change].
+type ChangeType string
+
+const (
+	// ChangeAdded is an element only present after.
+	ChangeAdded ChangeType = "added"
+
+	// ChangeRemoved is an element only present before.
+	ChangeRemoved ChangeType = "removed"
+
+	// ChangeModified is a leaf with a different value (or an element that
+	// changed between a leaf and a container).
+	ChangeModified ChangeType = "modified"
+)
+
+// Change is a single difference between two configurations.
+type Change struct {
+	Type ChangeType
+
+	// Path identifies the element with its ancestors in the style of a YANG
+	// instance-identifier (RFC7950 section 9.13), i.e
+	// `/ietf-interfaces:interfaces/interface[name='eth0']/mtu`.  Names are
+	// qualified with the last part of their namespace (the module name for
+	// IETF modules) when it differs from the parent.
+	Path string
+
+	// Old and New are the element before and after the change.  Old is nil
+	// for added elements and New is nil for removed elements.
+	Old, New *Node
+}
+
+// String returns the change as a line prefixed with `+`, `-` or `~` followed
+// by the path and the values of leaves.
+func (c Change) String() string {
+	switch c.Type {
+	case ChangeAdded:
+		return "+ " + c.Path + leafValue(c.New, ": ")
+	case ChangeRemoved:
+		return "- " + c.Path + leafValue(c.Old, ": ")
+	default:
+		if !c.Old.IsLeaf() || !c.New.IsLeaf() {
+			return "~ " + c.Path
+		}
+		return "~ " + c.Path + leafValue(c.Old, ": ") + leafValue(c.New, " -> ")
+	}
+}
+
+func leafValue(n *Node, sep string) string {
+	if n == nil || !n.IsLeaf() {
+		return ""
+	}
+	return sep + fmt.Sprintf("%q", n.Text)
+}
+
+// MarshalJSON implements json.Marshaler.  Old and New are encoded as strings
+// for leaves and as described in [ToMap] otherwise.
+func (c Change) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type ChangeType `json:"type"`
+		Path string     `json:"path"`
+		Old  any        `json:"old,omitempty"`
+		New  any        `json:"new,omitempty"`
+	}{
+		Type: c.Type,
+		Path: c.Path,
+		Old:  jsonValue(c.Old),
+		New:  jsonValue(c.New),
+	})
+}
+
+func jsonValue(n *Node) any {
+	switch {
+	case n == nil:
+		return nil
+	case n.IsLeaf():
+		return n.Text
+	default:
+		cfg := jsonConfig{modules: map[string]string{}, lists: map[string]bool{}}
+		return cfg.object(n.Children, n.Name.Space)
+	}
+}
+
+// Changes is a list of changes in document order with the elements removed
+// from a parent listed before its other changes.
+type Changes []Change
+
+// String returns each change on its own line.
+func (cs Changes) String() string {
+	var sb strings.Builder
+	for _, c := range cs {
+		sb.WriteString(c.String())
+		sb.WriteByte('\n')
+	}
+	return sb.String()
+}
+
+// Diff compares two configurations (i.e the data of `<get-config>` before and
+// after an `<edit-config>`) and returns the changes from before to after.
+// Elements are matched by namespace and local name, list entries by the keys
+// returned by keys (or [NameKey] if nil) and leaf-list entries by value.
+// Attributes and the order of elements are ignored.
+//
+// Without a schema leaves are compared as text, i.e `1` and `01` are
+// different.
+func Diff(before, after []*Node, keys KeyFunc) Changes {
+	if keys == nil {
+		keys = NameKey
+	}
+	d := differ{keys: keys}
+	d.siblings(before, after, "", "")
+	return d.changes
+}
+
+// DiffXML parses two configurations and compares them with [Diff].
+func DiffXML(before, after []byte, keys KeyFunc) (Changes, error) {
+	b, err := Parse(before)
+	if err != nil {
+		return nil, fmt.Errorf("xmltree: invalid before config: %w", err)
+	}
+	a, err := Parse(after)
+	if err != nil {
+		return nil, fmt.Errorf("xmltree: invalid after config: %w", err)
+	}
+	return Diff(b, a, keys), nil
+}
+
+type differ struct {
+	keys    KeyFunc
+	changes Changes
+}
+
+// siblings compares the children of an element in namespace space at path.
+func (d *differ) siblings(before, after []*Node, space, path string) {
+	matched := make([]bool, len(before))
+	pairs := make([]int, len(after))
+	for i, a := range after {
+		pairs[i] = d.match(before, matched, a, after)
+		if pairs[i] >= 0 {
+			matched[pairs[i]] = true
+		}
+	}
+
+	for i, b := range before {
+		if !matched[i] {
+			d.add(ChangeRemoved, path+d.segment(b, space, before, after), b, nil)
+		}
+	}
+
+	for i, a := range after {
+		elemPath := path + d.segment(a, space, before, after)
+		if pairs[i] < 0 {
+			d.add(ChangeAdded, elemPath, nil, a)
+			continue
+		}
+
+		b := before[pairs[i]]
+		switch {
+		case b.IsLeaf() && a.IsLeaf():
+			if b.Text != a.Text {
+				d.add(ChangeModified, elemPath, b, a)
+			}
+		case b.IsLeaf() != a.IsLeaf():
+			d.add(ChangeModified, elemPath, b, a)
+		default:
+			d.siblings(b.Children, a.Children, a.Name.Space, elemPath)
+		}
+	}
+}
+
+func (d *differ) add(typ ChangeType, path string, before, after *Node) {
+	d.changes = append(d.changes, Change{Type: typ, Path: path, Old: before, New: after})
+}
+
+// match returns the index of the unmatched element of before that matches n
+// or -1 if there isn't one.
+func (d *differ) match(before []*Node, matched []bool, n *Node, after []*Node) int {
+	keys := d.keys(n)
+	leafList := isLeafList(n, before, after)
+	for i, b := range before {
+		if matched[i] || !exactName(b, n) {
+			continue
+		}
+		switch {
+		case len(keys) > 0:
+			if keysEqual(b, n, keys) {
+				return i
+			}
+		case leafList:
+			if b.IsLeaf() && b.Text == n.Text {
+				return i
+			}
+		default:
+			return i
+		}
+	}
+	return -1
+}
+
+// isLeafList reports if a leaf is repeated on either side and so an entry
+// of a leaf-list identified by its value.
+func isLeafList(n *Node, before, after []*Node) bool {
+	return n.IsLeaf() && (countExact(before, n) > 1 || countExact(after, n) > 1)
+}
+
+func exactName(a, b *Node) bool { return a.Name == b.Name }
+
+func countExact(siblings []*Node, n *Node) int {
+	var count int
+	for _, s := range siblings {
+		if exactName(s, n) {
+			count++
+		}
+	}
+	return count
+}
+
+// segment returns the path segment of n whose parent is in namespace space.
+func (d *differ) segment(n *Node, space string, before, after []*Node) string {
+	var sb strings.Builder
+	sb.WriteByte('/')
+	if n.Name.Space != space && n.Name.Space != "" {
+		sb.WriteString(moduleName(n.Name.Space))
+		sb.WriteByte(':')
+	}
+	sb.WriteString(n.Name.Local)
+
+	if isLeafList(n, before, after) {
+		fmt.Fprintf(&sb, "[.=%s]", quoteXPath(n.Text))
+		return sb.String()
+	}
+	for _, key := range d.keys(n) {
+		if c := n.Child(key); c != nil {
+			fmt.Fprintf(&sb, "[%s=%s]", key, quoteXPath(c.Text))
+		}
+	}
+	return sb.String()
+}
+
+// quoteXPath quotes a literal with single quotes unless it contains one.
+func quoteXPath(s string) string {
+	if strings.Contains(s, "'") {
+		return `"` + s + `"`
+	}
+	return "'" + s + "'"
+}
@@ -0,0 +1,117 @@
+package xmltree
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiff(t *testing.T) {
+	before := `
+<interfaces xmlns="urn:ietf:params:xml:ns:yang:ietf-interfaces">
+  <interface>
+    <name>eth0</name>
+    <mtu>1500</mtu>
+    <ipv4 xmlns="urn:ietf:params:xml:ns:yang:ietf-ip"><enabled>true</enabled></ipv4>
+  </interface>
+  <interface>
+    <name>eth1</name>
+    <mtu>1500</mtu>
+  </interface>
+</interfaces>
+<system xmlns="http://example.com/system">
+  <dns-search>a.example.com</dns-search>
+  <dns-search>b.example.com</dns-search>
+  <ntp><server>192.0.2.1</server></ntp>
+</system>`
+
+	after := `
+<system xmlns="http://example.com/system">
+  <dns-search>b.example.com</dns-search>
+  <dns-search>c.example.com</dns-search>
+  <ntp/>
+</system>
+<interfaces xmlns="urn:ietf:params:xml:ns:yang:ietf-interfaces">
+  <interface>
+    <name>eth2</name>
+  </interface>
+  <interface>
+    <ipv4 xmlns="urn:ietf:params:xml:ns:yang:ietf-ip"><enabled>false</enabled></ipv4>
+    <mtu>9000</mtu>
+    <name>eth0</name>
+  </interface>
+</interfaces>`
+
+	changes, err := DiffXML([]byte(before), []byte(after), nil)
+	require.NoError(t, err)
+
+	// changes follow the order of after.
+	assert.Equal(t, `- /system:system/dns-search[.='a.example.com']: "a.example.com"
++ /system:system/dns-search[.='c.example.com']: "c.example.com"
+~ /system:system/ntp
+- /ietf-interfaces:interfaces/interface[name='eth1']
++ /ietf-interfaces:interfaces/interface[name='eth2']
+~ /ietf-interfaces:interfaces/interface[name='eth0']/ietf-ip:ipv4/enabled: "true" -> "false"
+~ /ietf-interfaces:interfaces/interface[name='eth0']/mtu: "1500" -> "9000"
+`, changes.String())
+
+	require.Len(t, changes, 7)
+	assert.Equal(t, ChangeModified, changes[5].Type)
+	assert.Equal(t, "true", changes[5].Old.Text)
+	assert.Equal(t, "false", changes[5].New.Text)
+	assert.Nil(t, changes[4].Old)
+	assert.Nil(t, changes[3].New)
+
+	got, err := json.Marshal(changes[3:5])
+	require.NoError(t, err)
+	assert.JSONEq(t, `[
+		{"type": "removed", "path": "/ietf-interfaces:interfaces/interface[name='eth1']", "old": {"name": "eth1", "mtu": "1500"}},
+		{"type": "added", "path": "/ietf-interfaces:interfaces/interface[name='eth2']", "new": {"name": "eth2"}}
+	]`, string(got))
+}
+
+func TestDiffNamespaces(t *testing.T) {
+	changes, err := DiffXML(
+		[]byte(`<system xmlns="urn:a"><hostname>r1</hostname></system>`),
+		[]byte(`<system xmlns="urn:b"><hostname>r1</hostname></system>`),
+		nil)
+	require.NoError(t, err)
+	assert.Equal(t, "- /a:system\n+ /b:system\n", changes.String())
+}
+
+func TestDiffEqual(t *testing.T) {
+	changes, err := DiffXML(
+		[]byte(`<system xmlns="urn:a"><hostname>r1</hostname><user><name>x</name></user></system>`),
+		[]byte(`<s:system xmlns:s="urn:a"><s:user><s:name>x</s:name></s:user><s:hostname> r1 </s:hostname></s:system>`),
+		nil)
+	require.NoError(t, err)
+	assert.Empty(t, changes)
+	assert.Equal(t, "", changes.String())
+}
+
+func TestDiffKeys(t *testing.T) {
+	keys := func(n *Node) []string {
+		if n.Name.Local == "route" {
+			return []string{"prefix", "next-hop"}
+		}
+		return nil
+	}
+	changes, err := DiffXML(
+		[]byte(`<routes><route><prefix>0.0.0.0/0</prefix><next-hop>192.0.2.1</next-hop><metric>1</metric></route></routes>`),
+		[]byte(`<routes><route><prefix>0.0.0.0/0</prefix><next-hop>192.0.2.1</next-hop><metric>2</metric></route>`+
+			`<route><prefix>0.0.0.0/0</prefix><next-hop>192.0.2.2</next-hop></route></routes>`),
+		keys)
+	require.NoError(t, err)
+	assert.Equal(t, `~ /routes/route[prefix='0.0.0.0/0'][next-hop='192.0.2.1']/metric: "1" -> "2"
++ /routes/route[prefix='0.0.0.0/0'][next-hop='192.0.2.2']
+`, changes.String())
+}
+
+func TestDiffXMLInvalid(t *testing.T) {
+	_, err := DiffXML([]byte("<a>"), []byte("<a/>"), nil)
+	assert.ErrorContains(t, err, "invalid before config")
+	_, err = DiffXML([]byte("<a/>"), []byte("<a>"), nil)
+	assert.ErrorContains(t, err, "invalid after config")
+}
@@ -0,0 +1,271 @@
+package xmltree
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// netconfNamespace is the namespace of the `operation` attribute used in the
+// `<config>` subtree of an `<edit-config>` operation.
+const netconfNamespace = "urn:ietf:params:xml:ns:netconf:base:1.0"
+
+var (
+	// ErrDataExists is returned by Edit when a `create` operation targets
+	// data that already exists.
+	ErrDataExists = errors.New("xmltree: data already exists")
+
+	// ErrDataMissing is returned by Edit when a `delete` operation targets
+	// data that doesn't exist.
+	ErrDataMissing = errors.New("xmltree: data missing")
+
+	// ErrUnknownOperation is returned for an unknown value of the
+	// `operation` attribute.
+	ErrUnknownOperation = errors.New("xmltree: unknown operation")
+)
+
+// KeyFunc returns the local names of the child leaves that uniquely identify
+// a list entry.  Without a schema there is no way to know the keys of a list so
+// this is a heuristic used to match elements in an edit against the existing
+// configuration.  A nil return means the element is not a list entry and is
+// matched by name alone.
+type KeyFunc func(n *Node) []string
+
+// NameKey is the default KeyFunc.  It treats any element with a `<name>` leaf
+// as a list entry keyed by that leaf which covers most vendor and OpenConfig
+// models.
+func NameKey(n *Node) []string {
+	if c := n.Child("name"); c != nil && c.IsLeaf() {
+		return []string{"name"}
+	}
+	return nil
+}
+
+// Edit applies an edit (the contents of a `<config>` element in an
+// `<edit-config>`) to a copy of config and returns the result.  The semantics
+// of the `operation` attribute and `default-operation` parameter follow RFC6241
+// section 7.2.  An empty defaultOp is treated as `merge`.  If keys is nil then
+// NameKey is used.
+//
+// config is not modified.
+func Edit(config, edit []*Node, defaultOp string, keys KeyFunc) ([]*Node, error) {
+	if defaultOp == "" {
+		defaultOp = "merge"
+	}
+	if keys == nil {
+		keys = NameKey
+	}
+
+	out := make([]*Node, len(config))
+	for i, n := range config {
+		out[i] = n.Clone()
+	}
+
+	e := editor{keys: keys}
+	return e.apply(out, edit, defaultOp, "")
+}
+
+type editor struct {
+	keys KeyFunc
+}
+
+func (e *editor) apply(siblings []*Node, edits []*Node, inherited, path string) ([]*Node, error) {
+	for _, edit := range edits {
+		op, ok := edit.Attr(netconfNamespace, "operation")
+		if !ok {
+			op = inherited
+		}
+		elemPath := path + "/" + e.describe(edit)
+
+		idx := e.find(siblings, edit)
+
+		switch op {
+		case "merge":
+			if idx < 0 {
+				if n := clean(edit); n != nil {
+					siblings = append(siblings, n)
+				}
+				continue
+			}
+			match := siblings[idx]
+			if edit.IsLeaf() {
+				match.Text = edit.Text
+				continue
+			}
+			children, err := e.apply(match.Children, edit.Children, op, elemPath)
+			if err != nil {
+				return nil, err
+			}
+			match.Children = children
+		case "replace":
+			n := clean(edit)
+			switch {
+			case idx < 0 && n != nil:
+				siblings = append(siblings, n)
+			case n == nil:
+				siblings = removeAt(siblings, idx)
+			default:
+				siblings[idx] = n
+			}
+		case "create":
+			if idx >= 0 {
+				return nil, fmt.Errorf("%w: %s", ErrDataExists, elemPath)
+			}
+			if n := clean(edit); n != nil {
+				siblings = append(siblings, n)
+			}
+		case "delete":
+			if idx < 0 {
+				return nil, fmt.Errorf("%w: %s", ErrDataMissing, elemPath)
+			}
+			siblings = removeAt(siblings, idx)
+		case "remove":
+			if idx >= 0 {
+				siblings = removeAt(siblings, idx)
+			}
+		case "none":
+			if idx < 0 {
+				// create a placeholder element that only contains the keys
+				// for any descendants that create data.
+				shell := &Node{Name: edit.Name}
+				for _, key := range e.keys(edit) {
+					if c := edit.Child(key); c != nil {
+						shell.Children = append(shell.Children, clean(c))
+					}
+				}
+				keyCount := len(shell.Children)
+
+				children, err := e.apply(shell.Children, edit.Children, op, elemPath)
+				if err != nil {
+					return nil, err
+				}
+				if len(children) > keyCount {
+					shell.Children = children
+					siblings = append(siblings, shell)
+				}
+				continue
+			}
+			match := siblings[idx]
+			children, err := e.apply(match.Children, edit.Children, op, elemPath)
+			if err != nil {
+				return nil, err
+			}
+			match.Children = children
+		default:
+			return nil, fmt.Errorf("%w %q: %s", ErrUnknownOperation, op, elemPath)
+		}
+	}
+	return siblings, nil
+}
+
+// find returns the index of the sibling that matches the given edit element
+// or -1 if there isn't one.
+func (e *editor) find(siblings []*Node, edit *Node) int {
+	keys := e.keys(edit)
+	leafIdx := -1
+	for i, n := range siblings {
+		if !sameName(n, edit) {
+			continue
+		}
+
+		if len(keys) > 0 {
+			if keysEqual(n, edit, keys) {
+				return i
+			}
+			continue
+		}
+
+		// Multiple leaves with the same name are leaf-list entries which are
+		// identified by their value.
+		if edit.IsLeaf() && n.IsLeaf() {
+			if n.Text == edit.Text {
+				return i
+			}
+			if leafIdx < 0 {
+				leafIdx = i
+			}
+			continue
+		}
+		return i
+	}
+
+	if leafIdx >= 0 && countNamed(siblings, edit) == 1 {
+		return leafIdx
+	}
+	return -1
+}
+
+func (e *editor) describe(n *Node) string {
+	keys := e.keys(n)
+	if len(keys) == 0 {
+		return n.Name.Local
+	}
+
+	var sb strings.Builder
+	sb.WriteString(n.Name.Local)
+	for _, key := range keys {
+		if c := n.Child(key); c != nil {
+			fmt.Fprintf(&sb, "[%s=%q]", key, c.Text)
+		}
+	}
+	return sb.String()
+}
+
+// sameName compares two element names.  Namespaces are only compared when
+// both elements have one as edits are often sent without namespaces.
+func sameName(a, b *Node) bool {
+	if a.Name.Local != b.Name.Local {
+		return false
+	}
+	return a.Name.Space == "" || b.Name.Space == "" || a.Name.Space == b.Name.Space
+}
+
+func keysEqual(a, b *Node, keys []string) bool {
+	for _, key := range keys {
+		ak, bk := a.Child(key), b.Child(key)
+		if ak == nil || bk == nil || ak.Text != bk.Text {
+			return false
+		}
+	}
+	return true
+}
+
+func countNamed(siblings []*Node, n *Node) int {
+	var count int
+	for _, s := range siblings {
+		if sameName(s, n) {
+			count++
+		}
+	}
+	return count
+}
+
+func removeAt(nodes []*Node, i int) []*Node {
+	return append(nodes[:i], nodes[i+1:]...)
+}
+
+// clean returns a copy of an edit element with the `operation` attributes
+// removed and any deleted or removed descendants pruned.  Returns nil if the
+// element itself is deleted or removed.
+func clean(n *Node) *Node {
+	if op, ok := n.Attr(netconfNamespace, "operation"); ok && (op == "delete" || op == "remove") {
+		return nil
+	}
+
+	c := &Node{
+		Name: n.Name,
+		Text: n.Text,
+	}
+	for _, attr := range n.Attrs {
+		if attr.Name.Space == netconfNamespace && attr.Name.Local == "operation" {
+			continue
+		}
+		c.Attrs = append(c.Attrs, attr)
+	}
+	for _, child := range n.Children {
+		if cc := clean(child); cc != nil {
+			c.Children = append(c.Children, cc)
+		}
+	}
+	return c
+}
@@ -0,0 +1,135 @@
+package xmltree
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const editBaseConfig = `
+<system>
+  <host-name>darkstar</host-name>
+  <name-server>10.0.0.1</name-server>
+  <name-server>10.0.0.2</name-server>
+</system>
+<interfaces>
+  <interface>
+    <name>ge-0/0/0</name>
+    <description>uplink</description>
+    <mtu>1500</mtu>
+  </interface>
+  <interface>
+    <name>ge-0/0/1</name>
+  </interface>
+</interfaces>`
+
+func TestEdit(t *testing.T) {
+	const nc = `xmlns:nc="urn:ietf:params:xml:ns:netconf:base:1.0"`
+
+	tt := []struct {
+		name      string
+		edit      string
+		defaultOp string
+		want      string
+		err       error
+	}{
+		{
+			name: "merge leaf",
+			edit: `<system><host-name>lightstar</host-name></system>`,
+			want: `<system><host-name>lightstar</host-name><name-server>10.0.0.1</name-server><name-server>10.0.0.2</name-server></system>`,
+		},
+		{
+			name: "merge new list entry",
+			edit: `<interfaces><interface><name>ge-0/0/2</name><mtu>9000</mtu></interface></interfaces>`,
+			want: `<interfaces><interface><name>ge-0/0/0</name><description>uplink</description><mtu>1500</mtu></interface><interface><name>ge-0/0/1</name></interface><interface><name>ge-0/0/2</name><mtu>9000</mtu></interface></interfaces>`,
+		},
+		{
+			name: "merge existing list entry",
+			edit: `<interfaces><interface><name>ge-0/0/1</name><mtu>9000</mtu></interface></interfaces>`,
+			want: `<interfaces><interface><name>ge-0/0/0</name><description>uplink</description><mtu>1500</mtu></interface><interface><name>ge-0/0/1</name><mtu>9000</mtu></interface></interfaces>`,
+		},
+		{
+			name: "merge leaf-list entry",
+			edit: `<system><name-server>10.0.0.3</name-server></system>`,
+			want: `<system><host-name>darkstar</host-name><name-server>10.0.0.1</name-server><name-server>10.0.0.2</name-server><name-server>10.0.0.3</name-server></system>`,
+		},
+		{
+			name: "replace list entry",
+			edit: `<interfaces><interface ` + nc + ` nc:operation="replace"><name>ge-0/0/0</name></interface></interfaces>`,
+			want: `<interfaces><interface><name>ge-0/0/0</name></interface><interface><name>ge-0/0/1</name></interface></interfaces>`,
+		},
+		{
+			name: "delete leaf",
+			edit: `<interfaces><interface><name>ge-0/0/0</name><description ` + nc + ` nc:operation="delete"/></interface></interfaces>`,
+			want: `<interfaces><interface><name>ge-0/0/0</name><mtu>1500</mtu></interface><interface><name>ge-0/0/1</name></interface></interfaces>`,
+		},
+		{
+			name: "delete missing",
+			edit: `<interfaces><interface ` + nc + ` nc:operation="delete"><name>ge-0/0/9</name></interface></interfaces>`,
+			err:  ErrDataMissing,
+		},
+		{
+			name: "remove missing",
+			edit: `<interfaces><interface ` + nc + ` nc:operation="remove"><name>ge-0/0/9</name></interface></interfaces>`,
+			want: `<interfaces><interface><name>ge-0/0/0</name><description>uplink</description><mtu>1500</mtu></interface><interface><name>ge-0/0/1</name></interface></interfaces>`,
+		},
+		{
+			name: "create existing",
+			edit: `<system><host-name ` + nc + ` nc:operation="create">foo</host-name></system>`,
+			err:  ErrDataExists,
+		},
+		{
+			name:      "default replace",
+			edit:      `<system><host-name>lightstar</host-name></system>`,
+			defaultOp: "replace",
+			want:      `<system><host-name>lightstar</host-name></system>`,
+		},
+		{
+			name:      "default none",
+			edit:      `<system><host-name>lightstar</host-name><location ` + nc + ` nc:operation="create">lab</location></system>`,
+			defaultOp: "none",
+			want:      `<system><host-name>darkstar</host-name><name-server>10.0.0.1</name-server><name-server>10.0.0.2</name-server><location>lab</location></system>`,
+		},
+		{
+			name: "unknown operation",
+			edit: `<system ` + nc + ` nc:operation="frobnicate"/>`,
+			err:  ErrUnknownOperation,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			base, err := Parse([]byte(editBaseConfig))
+			require.NoError(t, err)
+			orig, err := Marshal(base, "")
+			require.NoError(t, err)
+
+			edit, err := Parse([]byte(tc.edit))
+			require.NoError(t, err)
+
+			got, err := Edit(base, edit, tc.defaultOp, nil)
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+
+			// only compare the top-level element the edit touched
+			var touched []*Node
+			for _, n := range got {
+				if n.Name.Local == edit[0].Name.Local {
+					touched = append(touched, n)
+				}
+			}
+			out, err := Marshal(touched, "")
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, string(out))
+
+			// make sure the original was not modified
+			after, err := Marshal(base, "")
+			require.NoError(t, err)
+			assert.Equal(t, orig, after)
+		})
+	}
+}
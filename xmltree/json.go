@@ -0,0 +1,139 @@
+package xmltree
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+const yangNamespacePrefix = "urn:ietf:params:xml:ns:yang:"
+
+// JSONOption is an optional argument to [ToMap] and [ToJSON].
+type JSONOption interface {
+	apply(*jsonConfig)
+}
+
+type jsonConfig struct {
+	modules map[string]string
+	lists   map[string]bool
+}
+
+type modulesOpt map[string]string
+
+func (o modulesOpt) apply(cfg *jsonConfig) {
+	for ns, mod := range o {
+		cfg.modules[ns] = mod
+	}
+}
+
+// WithModuleNames maps XML namespaces to the YANG module names used to
+// qualify member names (i.e `http://openconfig.net/yang/interfaces` to
+// `openconfig-interfaces`).  Namespaces that aren't mapped use the last part
+// of the namespace which is the module name for IETF modules.
+func WithModuleNames(modules map[string]string) JSONOption { return modulesOpt(modules) }
+
+type listsOpt []string
+
+func (o listsOpt) apply(cfg *jsonConfig) {
+	for _, name := range o {
+		cfg.lists[name] = true
+	}
+}
+
+// WithLists sets the local names of elements that are always encoded as
+// arrays.  Without a schema a list or leaf-list with a single entry can't be
+// told apart from a container or leaf so only repeated elements become arrays
+// otherwise.
+func WithLists(names ...string) JSONOption { return listsOpt(names) }
+
+// ToMap converts nodes into a generic representation following the JSON
+// encoding of YANG data from RFC7951 as closely as possible without a schema:
+//
+//   - member names are qualified with the module name (i.e
+//     `ietf-interfaces:interfaces`) for top-level elements and elements in a
+//     different namespace than their parent.
+//   - elements with children are objects (map[string]any).
+//   - repeated elements are arrays ([]any).
+//   - leaves are strings and empty leaves are `[null]`.
+//
+// Attributes are dropped.
+func ToMap(nodes []*Node, opts ...JSONOption) map[string]any {
+	cfg := jsonConfig{
+		modules: make(map[string]string),
+		lists:   make(map[string]bool),
+	}
+	for _, opt := range opts {
+		opt.apply(&cfg)
+	}
+	return cfg.object(nodes, "\x00")
+}
+
+// ToJSON converts nodes to JSON as described in [ToMap].  Members are sorted
+// by name.
+func ToJSON(nodes []*Node, opts ...JSONOption) ([]byte, error) {
+	return json.Marshal(ToMap(nodes, opts...))
+}
+
+// object converts sibling nodes whose parent is in namespace parent.
+func (cfg *jsonConfig) object(nodes []*Node, parent string) map[string]any {
+	var (
+		names  []string
+		groups = make(map[string][]*Node)
+	)
+	for _, n := range nodes {
+		name := n.Name.Local
+		if n.Name.Space != parent {
+			if mod := cfg.module(n.Name.Space); mod != "" {
+				name = mod + ":" + name
+			}
+		}
+		if _, ok := groups[name]; !ok {
+			names = append(names, name)
+		}
+		groups[name] = append(groups[name], n)
+	}
+
+	obj := make(map[string]any, len(names))
+	for _, name := range names {
+		group := groups[name]
+		if len(group) == 1 && !cfg.lists[group[0].Name.Local] {
+			obj[name] = cfg.value(group[0])
+			continue
+		}
+		values := make([]any, len(group))
+		for i, n := range group {
+			values[i] = cfg.value(n)
+		}
+		obj[name] = values
+	}
+	return obj
+}
+
+func (cfg *jsonConfig) value(n *Node) any {
+	switch {
+	case len(n.Children) > 0:
+		return cfg.object(n.Children, n.Name.Space)
+	case n.Text == "":
+		return []any{nil}
+	default:
+		return n.Text
+	}
+}
+
+// module returns the module name for a namespace.
+func (cfg *jsonConfig) module(ns string) string {
+	if mod, ok := cfg.modules[ns]; ok {
+		return mod
+	}
+	return moduleName(ns)
+}
+
+// moduleName guesses the module name of a namespace from its last part.
+func moduleName(ns string) string {
+	if mod, ok := strings.CutPrefix(ns, yangNamespacePrefix); ok {
+		return mod
+	}
+	if i := strings.LastIndexAny(ns, "/:"); i >= 0 {
+		return ns[i+1:]
+	}
+	return ns
+}
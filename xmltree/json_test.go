@@ -0,0 +1,67 @@
+package xmltree
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToMap(t *testing.T) {
+	nodes, err := Parse([]byte(`
+<interfaces xmlns="urn:ietf:params:xml:ns:yang:ietf-interfaces">
+  <interface>
+    <name>eth0</name>
+    <enabled>true</enabled>
+    <ipv4 xmlns="urn:ietf:params:xml:ns:yang:ietf-ip">
+      <address><ip>192.0.2.1</ip></address>
+      <address><ip>192.0.2.2</ip></address>
+      <forwarding/>
+    </ipv4>
+  </interface>
+</interfaces>
+<system xmlns="http://example.com/yang/system">
+  <dns-search>example.com</dns-search>
+</system>
+<bare/>`))
+	require.NoError(t, err)
+
+	got := ToMap(nodes, WithModuleNames(map[string]string{
+		"http://example.com/yang/system": "example-system",
+	}), WithLists("interface", "dns-search"))
+
+	assert.Equal(t, map[string]any{
+		"ietf-interfaces:interfaces": map[string]any{
+			"interface": []any{
+				map[string]any{
+					"name":    "eth0",
+					"enabled": "true",
+					"ietf-ip:ipv4": map[string]any{
+						"address": []any{
+							map[string]any{"ip": "192.0.2.1"},
+							map[string]any{"ip": "192.0.2.2"},
+						},
+						"forwarding": []any{nil},
+					},
+				},
+			},
+		},
+		"example-system:system": map[string]any{
+			"dns-search": []any{"example.com"},
+		},
+		"bare": []any{nil},
+	}, got)
+}
+
+func TestToJSON(t *testing.T) {
+	nodes, err := Parse([]byte(`<system xmlns="http://openconfig.net/yang/system"><config><hostname>r1</hostname></config></system>`))
+	require.NoError(t, err)
+
+	out, err := ToJSON(nodes)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"system:system": {"config": {"hostname": "r1"}}}`, string(out))
+
+	out, err = ToJSON(nodes, WithModuleNames(map[string]string{"http://openconfig.net/yang/system": "openconfig-system"}))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"openconfig-system:system": {"config": {"hostname": "r1"}}}`, string(out))
+}
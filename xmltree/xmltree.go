@@ -0,0 +1,199 @@
+// Package xmltree implements a simple in-memory tree of XML elements for
+// inspecting and manipulating configuration and state data returned by
+// NETCONF servers.
+//
+// The tree is intentionally simple: mixed content is not preserved (character
+// data is only kept for elements without child elements) and comments,
+// processing instructions and directives are dropped.  This is good enough
+// for YANG modeled data which never uses mixed content.
+package xmltree
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+)
+
+// Node is a single XML element.
+type Node struct {
+	Name     xml.Name
+	Attrs    []xml.Attr
+	Children []*Node
+
+	// Text is the character data of the element with leading and trailing
+	// whitespace removed.  It is only set for elements without any child
+	// elements.
+	Text string
+}
+
+// Parse parses a XML document or fragment into a list of nodes.  Fragments
+// can contain multiple top-level elements (i.e the contents of a `<data>` or
+// `<config>` element).
+func Parse(data []byte) ([]*Node, error) {
+	return Decode(xml.NewDecoder(bytes.NewReader(data)))
+}
+
+// Decode reads elements from the decoder until EOF and returns them as a
+// list of nodes.
+func Decode(d *xml.Decoder) ([]*Node, error) {
+	var (
+		roots []*Node
+		stack []*Node
+		text  strings.Builder
+	)
+
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			n := &Node{
+				Name:  tok.Name,
+				Attrs: filterAttrs(tok.Attr),
+			}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.Children = append(parent.Children, n)
+			} else {
+				roots = append(roots, n)
+			}
+			stack = append(stack, n)
+			text.Reset()
+		case xml.EndElement:
+			n := stack[len(stack)-1]
+			if len(n.Children) == 0 {
+				n.Text = strings.TrimSpace(text.String())
+			}
+			stack = stack[:len(stack)-1]
+			text.Reset()
+		case xml.CharData:
+			if len(stack) > 0 {
+				text.Write(tok)
+			}
+		}
+	}
+
+	if len(stack) > 0 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return roots, nil
+}
+
+// filterAttrs removes namespace declarations from the list of attributes as
+// namespaces are already resolved into the names of elements and attributes.
+func filterAttrs(attrs []xml.Attr) []xml.Attr {
+	var out []xml.Attr
+	for _, attr := range attrs {
+		if attr.Name.Space == "xmlns" || (attr.Name.Space == "" && attr.Name.Local == "xmlns") {
+			continue
+		}
+		out = append(out, attr)
+	}
+	return out
+}
+
+// Attr returns the value of the attribute with the given namespace and local
+// name.  If space is empty then only the local name is matched.
+func (n *Node) Attr(space, local string) (string, bool) {
+	for _, attr := range n.Attrs {
+		if attr.Name.Local == local && (space == "" || attr.Name.Space == space) {
+			return attr.Value, true
+		}
+	}
+	return "", false
+}
+
+// RemoveAttr removes all attributes with the given namespace and local name.
+// If space is empty then only the local name is matched.
+func (n *Node) RemoveAttr(space, local string) {
+	attrs := n.Attrs[:0]
+	for _, attr := range n.Attrs {
+		if attr.Name.Local == local && (space == "" || attr.Name.Space == space) {
+			continue
+		}
+		attrs = append(attrs, attr)
+	}
+	n.Attrs = attrs
+}
+
+// Child returns the first child element with the given local name.
+func (n *Node) Child(local string) *Node {
+	for _, c := range n.Children {
+		if c.Name.Local == local {
+			return c
+		}
+	}
+	return nil
+}
+
+// IsLeaf returns true if the node has no child elements.
+func (n *Node) IsLeaf() bool { return len(n.Children) == 0 }
+
+// Clone returns a deep copy of the node.
+func (n *Node) Clone() *Node {
+	c := &Node{
+		Name: n.Name,
+		Text: n.Text,
+	}
+	if n.Attrs != nil {
+		c.Attrs = append([]xml.Attr(nil), n.Attrs...)
+	}
+	if n.Children != nil {
+		c.Children = make([]*Node, len(n.Children))
+		for i, child := range n.Children {
+			c.Children[i] = child.Clone()
+		}
+	}
+	return c
+}
+
+// MarshalXML implements xml.Marshaler.  The start element given is ignored in
+// favor of the name and attributes of the node.
+func (n *Node) MarshalXML(e *xml.Encoder, _ xml.StartElement) error {
+	start := xml.StartElement{Name: n.Name, Attr: n.Attrs}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+
+	if n.IsLeaf() {
+		if n.Text != "" {
+			if err := e.EncodeToken(xml.CharData(n.Text)); err != nil {
+				return err
+			}
+		}
+	} else {
+		for _, c := range n.Children {
+			if err := c.MarshalXML(e, xml.StartElement{}); err != nil {
+				return err
+			}
+		}
+	}
+
+	return e.EncodeToken(start.End())
+}
+
+// Marshal encodes a list of nodes into XML.  If indent is non-empty the output
+// is indented with the given string for each level.
+func Marshal(nodes []*Node, indent string) ([]byte, error) {
+	var buf bytes.Buffer
+	e := xml.NewEncoder(&buf)
+	if indent != "" {
+		e.Indent("", indent)
+	}
+	for _, n := range nodes {
+		if err := e.Encode(n); err != nil {
+			return nil, err
+		}
+	}
+	if err := e.Flush(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
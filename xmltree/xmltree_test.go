@@ -0,0 +1,56 @@
+package xmltree
+
+import (
+	"encoding/xml"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	nodes, err := Parse([]byte(`<?xml version="1.0"?>
+<system xmlns="urn:example:system" xmlns:ex="urn:example:ext">
+  <host-name>darkstar</host-name>
+  <services ex:enabled="true"><ssh/></services>
+</system>
+<interfaces/>`))
+	require.NoError(t, err)
+	require.Len(t, nodes, 2)
+
+	sys := nodes[0]
+	assert.Equal(t, xml.Name{Space: "urn:example:system", Local: "system"}, sys.Name)
+	assert.Empty(t, sys.Attrs)
+	require.Len(t, sys.Children, 2)
+	assert.Equal(t, "darkstar", sys.Child("host-name").Text)
+
+	v, ok := sys.Child("services").Attr("urn:example:ext", "enabled")
+	assert.True(t, ok)
+	assert.Equal(t, "true", v)
+
+	assert.Equal(t, "interfaces", nodes[1].Name.Local)
+	assert.True(t, nodes[1].IsLeaf())
+}
+
+func TestParseTruncated(t *testing.T) {
+	_, err := Parse([]byte(`<system><host-name>darkstar</host-name>`))
+	assert.Error(t, err)
+}
+
+func TestMarshal(t *testing.T) {
+	nodes, err := Parse([]byte(`<system><host-name> darkstar </host-name><services><ssh/></services></system>`))
+	require.NoError(t, err)
+
+	got, err := Marshal(nodes, "")
+	require.NoError(t, err)
+	assert.Equal(t, `<system><host-name>darkstar</host-name><services><ssh></ssh></services></system>`, string(got))
+}
+
+func TestClone(t *testing.T) {
+	nodes, err := Parse([]byte(`<a><b>1</b></a>`))
+	require.NoError(t, err)
+
+	c := nodes[0].Clone()
+	c.Children[0].Text = "2"
+	assert.Equal(t, "1", nodes[0].Child("b").Text)
+}
@@ -0,0 +1,524 @@
+package xmltree
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Path is a compiled XPath expression.  Only the location paths commonly
+// used to pick data out of NETCONF replies are supported:
+//
+//   - absolute and relative paths with `/` and `//` (i.e `/data/interfaces`
+//     or `//interface`).
+//   - name tests with optional prefixes (i.e `if:interface`), `*`, `.`,
+//     `..` and a final `text()`.
+//   - predicates comparing a child path, an attribute (`@name`), `.` or
+//     `text()` to a literal with `=` or `!=`, testing for their existence,
+//     positions (i.e `[2]`) and tests joined with `and`.
+//
+// Unlike XPath 1.0 names without a prefix match elements in any namespace as
+// the default namespace of NETCONF data changes from module to module.
+type Path struct {
+	expr  string
+	steps []step
+}
+
+type axis int
+
+const (
+	childAxis axis = iota
+	descendantAxis
+	selfAxis
+	parentAxis
+)
+
+type step struct {
+	axis  axis
+	space string // empty matches any namespace
+	local string // `*` matches any name
+	text  bool   // text()
+	preds [][]test
+}
+
+// test is a single condition in a predicate.
+type test struct {
+	pos   int    // position (1 based) if non zero
+	attr  string // attribute local name
+	space string // attribute namespace
+	path  []step // relative path otherwise
+	op    string // `=`, `!=` or empty to test for existence
+	value string
+}
+
+// Compile parses an XPath expression.  namespaces maps the prefixes used in
+// the expression to namespaces.
+func Compile(expr string, namespaces map[string]string) (*Path, error) {
+	toks, err := tokenize(expr)
+	if err != nil {
+		return nil, fmt.Errorf("xmltree: invalid xpath %q: %w", expr, err)
+	}
+
+	p := &xpathParser{toks: toks, namespaces: namespaces}
+	steps, err := p.path()
+	if err == nil && p.more() {
+		err = fmt.Errorf("unexpected %q", p.peek())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("xmltree: invalid xpath %q: %w", expr, err)
+	}
+	return &Path{expr: expr, steps: steps}, nil
+}
+
+// MustCompile is like [Compile] but panics if the expression is invalid.
+func MustCompile(expr string, namespaces map[string]string) *Path {
+	p, err := Compile(expr, namespaces)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// String returns the source of the expression.
+func (p *Path) String() string { return p.expr }
+
+// Find returns the nodes selected by an XPath expression (see [Path]) from a
+// list of top-level nodes (i.e the contents of a `<rpc-reply>`).
+func Find(nodes []*Node, expr string, namespaces map[string]string) ([]*Node, error) {
+	p, err := Compile(expr, namespaces)
+	if err != nil {
+		return nil, err
+	}
+	return p.Find(nodes), nil
+}
+
+// Find returns the nodes selected by the expression from a list of top-level
+// nodes in document order.  Absolute and relative paths are both evaluated
+// from the document root whose children are nodes.
+func (p *Path) Find(nodes []*Node) []*Node {
+	root := &Node{Children: nodes}
+	e := evaluator{parents: make(map[*Node]*Node)}
+	e.index(root)
+
+	result := e.steps([]*Node{root}, p.steps)
+	out := result[:0]
+	for _, n := range result {
+		if n != root {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+type evaluator struct {
+	parents map[*Node]*Node
+}
+
+func (e *evaluator) index(n *Node) {
+	for _, c := range n.Children {
+		e.parents[c] = n
+		e.index(c)
+	}
+}
+
+func (e *evaluator) steps(context []*Node, steps []step) []*Node {
+	for _, s := range steps {
+		context = e.step(context, s)
+		if len(context) == 0 {
+			break
+		}
+	}
+	return context
+}
+
+func (e *evaluator) step(context []*Node, s step) []*Node {
+	var (
+		out  []*Node
+		seen = make(map[*Node]bool)
+	)
+	add := func(nodes []*Node) {
+		for _, n := range nodes {
+			if !seen[n] {
+				seen[n] = true
+				out = append(out, n)
+			}
+		}
+	}
+
+	for _, n := range context {
+		switch s.axis {
+		case selfAxis:
+			if !s.text || n.IsLeaf() {
+				add(e.filter([]*Node{n}, s.preds))
+			}
+		case parentAxis:
+			if p, ok := e.parents[n]; ok {
+				add(e.filter([]*Node{p}, s.preds))
+			}
+		case childAxis:
+			add(e.filter(s.children(n), s.preds))
+		case descendantAxis:
+			// descendant-or-self::node()/child::name so that positions are
+			// relative to each parent.
+			walk(n, func(d *Node) {
+				add(e.filter(s.children(d), s.preds))
+			})
+		}
+	}
+	return out
+}
+
+// children returns the children of n matching the name test.
+func (s step) children(n *Node) []*Node {
+	var out []*Node
+	for _, c := range n.Children {
+		if (s.local == "*" || c.Name.Local == s.local) && (s.space == "" || c.Name.Space == s.space) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func walk(n *Node, fn func(*Node)) {
+	fn(n)
+	for _, c := range n.Children {
+		walk(c, fn)
+	}
+}
+
+// filter applies predicates in order.  Positions are relative to the nodes
+// left by the previous predicate.
+func (e *evaluator) filter(nodes []*Node, preds [][]test) []*Node {
+	for _, pred := range preds {
+		var out []*Node
+		for i, n := range nodes {
+			if e.match(n, i+1, pred) {
+				out = append(out, n)
+			}
+		}
+		nodes = out
+	}
+	return nodes
+}
+
+func (e *evaluator) match(n *Node, pos int, tests []test) bool {
+	for _, t := range tests {
+		if !e.matchTest(n, pos, t) {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *evaluator) matchTest(n *Node, pos int, t test) bool {
+	if t.pos != 0 {
+		return pos == t.pos
+	}
+
+	var values []string
+	if t.attr != "" {
+		for _, a := range n.Attrs {
+			if a.Name.Local == t.attr && (t.space == "" || a.Name.Space == t.space) {
+				values = append(values, a.Value)
+			}
+		}
+	} else {
+		for _, m := range e.steps([]*Node{n}, t.path) {
+			values = append(values, stringValue(m))
+		}
+	}
+
+	switch t.op {
+	case "":
+		return len(values) > 0
+	case "=":
+		for _, v := range values {
+			if equalValues(v, t.value) {
+				return true
+			}
+		}
+	case "!=":
+		for _, v := range values {
+			if !equalValues(v, t.value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// stringValue returns the text of a leaf or the text of all the leaves below
+// an element.
+func stringValue(n *Node) string {
+	if n.IsLeaf() {
+		return n.Text
+	}
+	var sb strings.Builder
+	walk(n, func(d *Node) {
+		if d.IsLeaf() {
+			sb.WriteString(d.Text)
+		}
+	})
+	return sb.String()
+}
+
+// equalValues compares values as numbers if both are numbers and as strings
+// otherwise.
+func equalValues(a, b string) bool {
+	if a == b {
+		return true
+	}
+	fa, errA := strconv.ParseFloat(strings.TrimSpace(a), 64)
+	fb, errB := strconv.ParseFloat(strings.TrimSpace(b), 64)
+	return errA == nil && errB == nil && fa == fb
+}
+
+type token struct {
+	kind  byte // one of `/[]=!@().*` for punctuation, 'n' name, 'l' literal, 'd' `//`, 'p' `..`, 'e' `!=`
+	value string
+}
+
+func tokenize(expr string) ([]token, error) {
+	var toks []token
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case strings.HasPrefix(expr[i:], "//"):
+			toks = append(toks, token{kind: 'd'})
+			i += 2
+		case strings.HasPrefix(expr[i:], ".."):
+			toks = append(toks, token{kind: 'p'})
+			i += 2
+		case strings.HasPrefix(expr[i:], "!="):
+			toks = append(toks, token{kind: 'e'})
+			i += 2
+		case strings.IndexByte("/[]=@().*", c) >= 0:
+			toks = append(toks, token{kind: c})
+			i++
+		case c == '\'' || c == '"':
+			end := strings.IndexByte(expr[i+1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated literal at %d", i)
+			}
+			toks = append(toks, token{kind: 'l', value: expr[i+1 : i+1+end]})
+			i += end + 2
+		case c >= '0' && c <= '9':
+			j := i
+			for j < len(expr) && (expr[j] >= '0' && expr[j] <= '9' || expr[j] == '.') {
+				j++
+			}
+			toks = append(toks, token{kind: '#', value: expr[i:j]})
+			i = j
+		case isNameStart(rune(c)) || c >= 0x80:
+			j := i
+			for j < len(expr) && (isNameChar(rune(expr[j])) || expr[j] >= 0x80 || expr[j] == ':' && j+1 < len(expr) && (isNameStart(rune(expr[j+1])) || expr[j+1] == '*')) {
+				if expr[j] == ':' && expr[j+1] == '*' {
+					j += 2
+					break
+				}
+				j++
+			}
+			toks = append(toks, token{kind: 'n', value: expr[i:j]})
+			i = j
+		default:
+			return nil, fmt.Errorf("unexpected %q at %d", c, i)
+		}
+	}
+	return toks, nil
+}
+
+func isNameStart(r rune) bool { return r == '_' || unicode.IsLetter(r) }
+
+func isNameChar(r rune) bool {
+	return isNameStart(r) || unicode.IsDigit(r) || r == '-' || r == '.'
+}
+
+type xpathParser struct {
+	toks       []token
+	pos        int
+	namespaces map[string]string
+}
+
+func (p *xpathParser) more() bool { return p.pos < len(p.toks) }
+
+func (p *xpathParser) peek() string {
+	if !p.more() {
+		return ""
+	}
+	t := p.toks[p.pos]
+	switch t.kind {
+	case 'n', '#':
+		return t.value
+	case 'l':
+		return "'" + t.value + "'"
+	case 'd':
+		return "//"
+	case 'p':
+		return ".."
+	case 'e':
+		return "!="
+	}
+	return string(t.kind)
+}
+
+func (p *xpathParser) is(kind byte) bool { return p.more() && p.toks[p.pos].kind == kind }
+
+func (p *xpathParser) accept(kind byte) bool {
+	if p.is(kind) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// path parses a location path.
+func (p *xpathParser) path() ([]step, error) {
+	ax := childAxis
+	switch {
+	case p.accept('/'):
+		if !p.more() {
+			// `/` alone is the root.
+			return []step{{axis: selfAxis}}, nil
+		}
+	case p.accept('d'):
+		ax = descendantAxis
+	}
+
+	var steps []step
+	for {
+		s, err := p.step(ax)
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, s)
+
+		switch {
+		case p.accept('/'):
+			ax = childAxis
+		case p.accept('d'):
+			ax = descendantAxis
+		default:
+			return steps, nil
+		}
+	}
+}
+
+func (p *xpathParser) step(ax axis) (step, error) {
+	s := step{axis: ax}
+	switch {
+	case p.accept('.'):
+		s.axis = selfAxis
+	case p.accept('p'):
+		s.axis = parentAxis
+	case p.accept('*'):
+		s.local = "*"
+	case p.is('n'):
+		name := p.toks[p.pos].value
+		p.pos++
+		if name == "text" && p.accept('(') {
+			if !p.accept(')') {
+				return s, fmt.Errorf("expected ) after text(")
+			}
+			s.axis, s.text = selfAxis, true
+			return s, nil
+		}
+		space, local, err := p.name(name)
+		if err != nil {
+			return s, err
+		}
+		s.space, s.local = space, local
+	default:
+		return s, fmt.Errorf("expected a step but got %q", p.peek())
+	}
+
+	for p.accept('[') {
+		pred, err := p.predicate()
+		if err != nil {
+			return s, err
+		}
+		s.preds = append(s.preds, pred)
+	}
+	return s, nil
+}
+
+// name resolves the prefix of a name.
+func (p *xpathParser) name(name string) (space, local string, err error) {
+	prefix, local, ok := strings.Cut(name, ":")
+	if !ok {
+		return "", name, nil
+	}
+	space, ok = p.namespaces[prefix]
+	if !ok {
+		return "", "", fmt.Errorf("unknown prefix %q", prefix)
+	}
+	return space, local, nil
+}
+
+func (p *xpathParser) predicate() ([]test, error) {
+	var tests []test
+	for {
+		t, err := p.test()
+		if err != nil {
+			return nil, err
+		}
+		tests = append(tests, t)
+
+		if p.is('n') && p.toks[p.pos].value == "and" {
+			p.pos++
+			continue
+		}
+		if !p.accept(']') {
+			return nil, fmt.Errorf("expected ] but got %q", p.peek())
+		}
+		return tests, nil
+	}
+}
+
+func (p *xpathParser) test() (test, error) {
+	var t test
+	switch {
+	case p.is('#'):
+		pos, err := strconv.Atoi(p.toks[p.pos].value)
+		if err != nil || pos < 1 {
+			return t, fmt.Errorf("invalid position %q", p.toks[p.pos].value)
+		}
+		p.pos++
+		t.pos = pos
+		return t, nil
+	case p.accept('@'):
+		if !p.is('n') {
+			return t, fmt.Errorf("expected an attribute name but got %q", p.peek())
+		}
+		space, local, err := p.name(p.toks[p.pos].value)
+		if err != nil {
+			return t, err
+		}
+		p.pos++
+		t.space, t.attr = space, local
+	default:
+		steps, err := p.path()
+		if err != nil {
+			return t, err
+		}
+		t.path = steps
+	}
+
+	switch {
+	case p.accept('='):
+		t.op = "="
+	case p.accept('e'):
+		t.op = "!="
+	default:
+		return t, nil
+	}
+	switch {
+	case p.is('l'), p.is('#'):
+		t.value = p.toks[p.pos].value
+		p.pos++
+		return t, nil
+	}
+	return t, fmt.Errorf("expected a literal but got %q", p.peek())
+}
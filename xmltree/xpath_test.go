@@ -0,0 +1,123 @@
+package xmltree
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const xpathDoc = `
+<data xmlns="urn:ietf:params:xml:ns:netconf:base:1.0">
+  <interfaces xmlns="urn:ietf:params:xml:ns:yang:ietf-interfaces">
+    <interface>
+      <name>eth0</name>
+      <enabled>true</enabled>
+      <mtu>1500</mtu>
+      <ipv4 xmlns="urn:ietf:params:xml:ns:yang:ietf-ip">
+        <address><ip>192.0.2.1</ip></address>
+        <address><ip>192.0.2.2</ip></address>
+      </ipv4>
+    </interface>
+    <interface>
+      <name>eth1</name>
+      <enabled>false</enabled>
+      <mtu>9000</mtu>
+    </interface>
+  </interfaces>
+  <system xmlns="http://example.com/system" version="2">
+    <hostname>router1</hostname>
+  </system>
+</data>`
+
+func TestFind(t *testing.T) {
+	nodes, err := Parse([]byte(xpathDoc))
+	require.NoError(t, err)
+
+	ns := map[string]string{
+		"if": "urn:ietf:params:xml:ns:yang:ietf-interfaces",
+		"ip": "urn:ietf:params:xml:ns:yang:ietf-ip",
+	}
+
+	tt := []struct {
+		expr string
+		want []string
+	}{
+		{"/data/interfaces/interface/name", []string{"eth0", "eth1"}},
+		{"data/interfaces/interface/name", []string{"eth0", "eth1"}},
+		{"//name", []string{"eth0", "eth1"}},
+		{"//interface[name='eth1']/mtu", []string{"9000"}},
+		{`//interface[name="eth0"]/mtu`, []string{"1500"}},
+		{"//interface[name!='eth0']/name", []string{"eth1"}},
+		{"//interface[enabled='true' and mtu=1500.0]/name", []string{"eth0"}},
+		{"//interface[enabled='true' and mtu=9000]/name", nil},
+		{"//interface[2]/name", []string{"eth1"}},
+		{"//interface[ipv4]/name", []string{"eth0"}},
+		{"//interface[ipv4/address/ip='192.0.2.2']/name", []string{"eth0"}},
+		{"//address[2]/ip", []string{"192.0.2.2"}},
+		{"//ip:address/ip:ip", []string{"192.0.2.1", "192.0.2.2"}},
+		{"/data/if:interfaces/if:interface/if:name", []string{"eth0", "eth1"}},
+		{"/data/if:interfaces/if:*/if:name", []string{"eth0", "eth1"}},
+		{"/data/ip:interfaces", nil},
+		{"/data/*/hostname", []string{"router1"}},
+		{"//system[@version='2']/hostname", []string{"router1"}},
+		{"//system[@version='3']/hostname", nil},
+		{"//hostname/text()", []string{"router1"}},
+		{"//name[.='eth1']/../mtu", []string{"9000"}},
+		{"//name[text()='eth0']", []string{"eth0"}},
+		{"//ip/../../../name", []string{"eth0"}},
+		{"//interface/name[1]", []string{"eth0", "eth1"}},
+		{"//missing", nil},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.expr, func(t *testing.T) {
+			found, err := Find(nodes, tc.expr, ns)
+			require.NoError(t, err)
+
+			var got []string
+			for _, n := range found {
+				got = append(got, n.Text)
+			}
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestFindRoot(t *testing.T) {
+	nodes, err := Parse([]byte(xpathDoc))
+	require.NoError(t, err)
+
+	found, err := Find(nodes, "/", nil)
+	require.NoError(t, err)
+	assert.Empty(t, found)
+
+	found, err = Find(nodes, "/*", nil)
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	assert.Equal(t, "data", found[0].Name.Local)
+}
+
+func TestCompileInvalid(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"//",
+		"/a/",
+		"/a[",
+		"/a[name=]",
+		"/a[name='x'",
+		"/a[0]",
+		"/a['x",
+		"/x:a",
+		"/a]",
+		"/a + 1",
+	} {
+		_, err := Compile(expr, nil)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestMustCompile(t *testing.T) {
+	assert.Equal(t, "//name", MustCompile("//name", nil).String())
+	assert.Panics(t, func() { MustCompile("/a[", nil) })
+}
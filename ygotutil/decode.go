@@ -0,0 +1,268 @@
+package ygotutil
+
+import (
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/nemith/netconf/xmltree"
+)
+
+// Unmarshal decodes XML data into s.  data is either the `<data>` (or
+// `<config>`) element of a reply or its contents (i.e as returned by
+// [netconf.Session.GetConfig]).  Elements are matched to fields by their local
+// name and elements without a field are ignored.
+func Unmarshal(data []byte, s GoStruct) error {
+	v := reflect.ValueOf(s)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("ygotutil: %T is not a pointer to a struct", s)
+	}
+
+	nodes, err := xmltree.Parse(data)
+	if err != nil {
+		return fmt.Errorf("ygotutil: %w", err)
+	}
+	if len(nodes) == 1 && nodes[0].Name.Space == baseNamespace &&
+		(nodes[0].Name.Local == "data" || nodes[0].Name.Local == "config") {
+		nodes = nodes[0].Children
+	}
+
+	if err := decodeStruct(v.Elem(), nodes); err != nil {
+		return fmt.Errorf("ygotutil: %w", err)
+	}
+	return nil
+}
+
+// find returns the nodes at the path of elements below nodes.
+func find(nodes []*xmltree.Node, elems []string) []*xmltree.Node {
+	for i, elem := range elems {
+		var matched []*xmltree.Node
+		for _, n := range nodes {
+			if n.Name.Local == elem {
+				matched = append(matched, n)
+			}
+		}
+		if i == len(elems)-1 {
+			return matched
+		}
+
+		nodes = nil
+		for _, n := range matched {
+			nodes = append(nodes, n.Children...)
+		}
+	}
+	return nil
+}
+
+func decodeStruct(v reflect.Value, nodes []*xmltree.Node) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, ok := f.Tag.Lookup("path")
+		if !ok || tag == "" || !f.IsExported() {
+			continue
+		}
+
+		// compressed structs list the paths of a leaf (i.e a list key both
+		// as `name` and `config/name`).  The first one found is used.
+		for _, path := range strings.Split(tag, "|") {
+			elems := strings.Split(strings.TrimPrefix(path, "/"), "/")
+			found := find(nodes, elems)
+			if len(found) == 0 {
+				continue
+			}
+			if err := decodeField(v.Field(i), found); err != nil {
+				return fmt.Errorf("%s: %w", f.Name, err)
+			}
+			break
+		}
+	}
+	return nil
+}
+
+func decodeField(v reflect.Value, nodes []*xmltree.Node) error {
+	t := v.Type()
+	switch {
+	case t.Kind() == reflect.Pointer && t.Elem().Kind() == reflect.Struct:
+		if v.IsNil() {
+			v.Set(reflect.New(t.Elem()))
+		}
+		// a container can be split over several elements (i.e config and
+		// state of different modules).
+		var children []*xmltree.Node
+		for _, n := range nodes {
+			children = append(children, n.Children...)
+		}
+		return decodeStruct(v.Elem(), children)
+
+	case t.Kind() == reflect.Map:
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(t))
+		}
+		for _, n := range nodes {
+			entry := reflect.New(t.Elem().Elem())
+			if err := decodeStruct(entry.Elem(), n.Children); err != nil {
+				return err
+			}
+			key, err := mapKey(t.Key(), entry)
+			if err != nil {
+				return err
+			}
+			if existing := v.MapIndex(key); existing.IsValid() {
+				if err := decodeStruct(existing.Elem(), n.Children); err != nil {
+					return err
+				}
+				continue
+			}
+			v.SetMapIndex(key, entry)
+		}
+		return nil
+
+	case t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8:
+		for _, n := range nodes {
+			elem := reflect.New(t.Elem()).Elem()
+			if err := decodeField(elem, []*xmltree.Node{n}); err != nil {
+				return err
+			}
+			v.Set(reflect.Append(v, elem))
+		}
+		return nil
+
+	case t.Kind() == reflect.Interface:
+		// the type of union values isn't known.
+		return nil
+	}
+
+	if t.Kind() == reflect.Pointer {
+		leaf := reflect.New(t.Elem())
+		if err := parseLeaf(leaf.Elem(), nodes[0].Text); err != nil {
+			return err
+		}
+		v.Set(leaf)
+		return nil
+	}
+	return parseLeaf(v, nodes[0].Text)
+}
+
+// mapKey returns the key of a decoded list entry in a map of type t.  Lists
+// with several keys use a generated key struct with a field for each key.
+func mapKey(t reflect.Type, entry reflect.Value) (reflect.Value, error) {
+	keys, err := listKeys(entry)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+
+	if t.Kind() != reflect.Struct {
+		for _, k := range keys {
+			return convertKey(reflect.ValueOf(k), t)
+		}
+		return reflect.Value{}, fmt.Errorf("%s has no list keys", entry.Type())
+	}
+
+	key := reflect.New(t).Elem()
+	for i := 0; i < t.NumField(); i++ {
+		name := t.Field(i).Tag.Get("path")
+		k, ok := keys[name]
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("missing list key %q", name)
+		}
+		kv, err := convertKey(reflect.ValueOf(k), t.Field(i).Type)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		key.Field(i).Set(kv)
+	}
+	return key, nil
+}
+
+func convertKey(v reflect.Value, t reflect.Type) (reflect.Value, error) {
+	if !v.IsValid() || !v.Type().ConvertibleTo(t) {
+		return reflect.Value{}, fmt.Errorf("list key %v can't be used as %s", v, t)
+	}
+	return v.Convert(t), nil
+}
+
+// parseLeaf sets v from the text of a leaf.
+func parseLeaf(v reflect.Value, text string) error {
+	if v.Kind() == reflect.Int64 && hasEnumMap(v.Type()) {
+		return parseEnum(v, text)
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(text)
+	case reflect.Bool:
+		if v.Type() != reflect.TypeOf(true) {
+			// YANGEmpty is set by the element being present.
+			v.SetBool(true)
+			return nil
+		}
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(text, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(text, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(text, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.Uint8 {
+			return fmt.Errorf("unsupported leaf type %s", v.Type())
+		}
+		b, err := base64.StdEncoding.DecodeString(text)
+		if err != nil {
+			return err
+		}
+		v.SetBytes(b)
+	default:
+		return fmt.Errorf("unsupported leaf type %s", v.Type())
+	}
+	return nil
+}
+
+// hasEnumMap reports whether t is a generated enumeration or identity type.
+func hasEnumMap(t reflect.Type) bool {
+	_, ok := t.MethodByName("ΛMap")
+	return ok
+}
+
+// parseEnum sets an enumeration from its name using the ΛMap method of the
+// generated type.  The map is keyed by type name and then by value with a
+// struct with a Name field (ygot.EnumDefinition) as the value.
+func parseEnum(v reflect.Value, text string) error {
+	name := enumName(strings.TrimSpace(text))
+	enumMap := v.MethodByName("ΛMap").Call(nil)[0]
+
+	defs := enumMap.MapIndex(reflect.ValueOf(v.Type().Name()))
+	if !defs.IsValid() {
+		return fmt.Errorf("no values for enumeration %s", v.Type().Name())
+	}
+	iter := defs.MapRange()
+	for iter.Next() {
+		def := iter.Value()
+		if def.Kind() == reflect.Struct {
+			if f := def.FieldByName("Name"); f.IsValid() && f.String() == name {
+				v.SetInt(iter.Key().Int())
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("invalid value %q for enumeration %s", text, v.Type().Name())
+}
@@ -0,0 +1,507 @@
+// Package ygotutil converts between ygot generated GoStructs (i.e OpenConfig
+// models) and the XML payloads of NETCONF operations.
+//
+// [Marshal] encodes a GoStruct as the contents of the `<config>` of an
+// `<edit-config>` with optional per-node operations and [Unmarshal] decodes
+// the `<data>` of a `<get-config>` reply into one:
+//
+//	device := &oc.Device{}
+//	intf := device.GetOrCreateInterfaces().GetOrCreateInterface("eth0")
+//	intf.Description = ygot.String("uplink")
+//
+//	config, err := ygotutil.Marshal(device, ygotutil.WithNamespaces(namespaces))
+//	err = sess.EditConfig(ctx, netconf.Candidate, config)
+//
+//	data, err := sess.GetConfig(ctx, netconf.Running)
+//	running := &oc.Device{}
+//	err = ygotutil.Unmarshal(data, running)
+//
+// The package doesn't depend on ygot.  Structs are walked with reflection
+// using the `path` and `module` tags the generator adds to every field so it
+// works with any generated code, compressed or not.  As the tags don't carry
+// the XML namespaces of modules they have to be given with
+// [WithNamespaces].
+//
+// Union leaves are encoded from their value but are skipped when decoding as
+// the concrete type can't be known without the schema.
+package ygotutil
+
+import (
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/nemith/netconf"
+)
+
+// GoStruct is implemented by all ygot generated structs.  It matches
+// ygot.GoStruct.
+type GoStruct interface {
+	IsYANGGoStruct()
+}
+
+const baseNamespace = "urn:ietf:params:xml:ns:netconf:base:1.0"
+
+// Option is an optional argument to [Marshal].
+type Option interface {
+	apply(*marshaler)
+}
+
+type namespacesOpt map[string]string
+
+func (o namespacesOpt) apply(m *marshaler) {
+	for mod, ns := range o {
+		m.namespaces[mod] = ns
+	}
+}
+
+// WithNamespaces sets the XML namespace of YANG modules by module name (i.e
+// `openconfig-interfaces` to `http://openconfig.net/yang/interfaces`).  Every
+// module used by the struct needs a namespace.
+func WithNamespaces(namespaces map[string]string) Option { return namespacesOpt(namespaces) }
+
+type operationOpt struct {
+	path string
+	op   netconf.MergeStrategy
+}
+
+func (o operationOpt) apply(m *marshaler) { m.ops[normalizePath(o.path)] = o.op }
+
+// WithOperation sets the `operation` attribute of the node at path (i.e
+// `/interfaces/interface[name=eth0]`).  Path elements are the YANG node
+// names and list entries are selected with all of their keys.  Entries of
+// lists deleted or removed only have their keys encoded.
+func WithOperation(path string, op netconf.MergeStrategy) Option {
+	return operationOpt{path: path, op: op}
+}
+
+// node is an element being encoded.
+type node struct {
+	name     string
+	space    string
+	attrs    []attr
+	children []*node
+	text     string
+	hasText  bool
+}
+
+type attr struct{ name, value string }
+
+func (n *node) child(name, space string) *node {
+	for _, c := range n.children {
+		if c.name == name && c.space == space && !c.hasText {
+			return c
+		}
+	}
+	c := &node{name: name, space: space}
+	n.children = append(n.children, c)
+	return c
+}
+
+func (n *node) write(sb *strings.Builder, parentSpace string) {
+	sb.WriteString("<" + n.name)
+	if n.space != parentSpace {
+		sb.WriteString(` xmlns="`)
+		sb.WriteString(escape(n.space))
+		sb.WriteString(`"`)
+	}
+	for _, a := range n.attrs {
+		sb.WriteString(" " + a.name + `="`)
+		sb.WriteString(escape(a.value))
+		sb.WriteString(`"`)
+	}
+	if len(n.children) == 0 && n.text == "" {
+		sb.WriteString("/>")
+		return
+	}
+	sb.WriteString(">")
+	sb.WriteString(escape(n.text))
+	for _, c := range n.children {
+		c.write(sb, n.space)
+	}
+	sb.WriteString("</" + n.name + ">")
+}
+
+var escaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+
+func escape(s string) string { return escaper.Replace(s) }
+
+type marshaler struct {
+	namespaces map[string]string
+	ops        map[string]netconf.MergeStrategy
+	usedOps    map[string]bool
+}
+
+// Marshal encodes the fields of s as XML elements.  s is usually the root
+// (device) struct and the result is the contents of the `<config>` of an
+// `<edit-config>`.  Nil and unset fields are omitted.
+func Marshal(s GoStruct, opts ...Option) ([]byte, error) {
+	m := &marshaler{
+		namespaces: make(map[string]string),
+		ops:        make(map[string]netconf.MergeStrategy),
+		usedOps:    make(map[string]bool),
+	}
+	for _, opt := range opts {
+		opt.apply(m)
+	}
+
+	v := reflect.ValueOf(s)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("ygotutil: %T is not a pointer to a struct", s)
+	}
+
+	root := &node{}
+	if err := m.encodeStruct(root, v.Elem(), "", nil); err != nil {
+		return nil, fmt.Errorf("ygotutil: %w", err)
+	}
+	for path := range m.ops {
+		if !m.usedOps[path] {
+			return nil, fmt.Errorf("ygotutil: no node for operation at %q", path)
+		}
+	}
+
+	var sb strings.Builder
+	for _, c := range root.children {
+		c.write(&sb, "")
+	}
+	return []byte(sb.String()), nil
+}
+
+// fieldPaths returns the path elements and modules of each path of a field.
+// Compressed structs list several paths separated by `|` (i.e a list key
+// that is both `name` and `config/name`).
+func fieldPaths(f reflect.StructField) (elems, mods [][]string) {
+	tag := f.Tag.Get("path")
+	if tag == "" {
+		return nil, nil
+	}
+	modTags := strings.Split(f.Tag.Get("module"), "|")
+	for i, path := range strings.Split(tag, "|") {
+		elems = append(elems, strings.Split(strings.TrimPrefix(path, "/"), "/"))
+
+		var mod []string
+		if i < len(modTags) && modTags[i] != "" {
+			mod = strings.Split(strings.TrimPrefix(modTags[i], "/"), "/")
+		}
+		mods = append(mods, mod)
+	}
+	return elems, mods
+}
+
+func (m *marshaler) namespace(mods []string, i int, parent string) (string, error) {
+	if len(mods) == 0 {
+		return parent, nil
+	}
+	mod := mods[min(i, len(mods)-1)]
+	ns, ok := m.namespaces[mod]
+	if !ok {
+		return "", fmt.Errorf("no namespace for module %q", mod)
+	}
+	return ns, nil
+}
+
+// encodeStruct encodes the fields of v as children of parent.  Leaves named
+// in skip are left out as they were already encoded (i.e list keys).
+func (m *marshaler) encodeStruct(parent *node, v reflect.Value, path string, skip map[string]bool) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		fv := v.Field(i)
+		if isUnset(fv) {
+			continue
+		}
+
+		paths, mods := fieldPaths(f)
+		for p, elems := range paths {
+			if len(elems) == 1 && skip[elems[0]] {
+				continue
+			}
+
+			// intermediate elements of compressed paths are containers
+			// shared with the other fields (i.e `config`).
+			n, fieldPath := parent, path
+			for j, elem := range elems[:len(elems)-1] {
+				ns, err := m.namespace(mods[p], j, n.space)
+				if err != nil {
+					return err
+				}
+				n = n.child(elem, ns)
+				fieldPath += "/" + elem
+			}
+			ns, err := m.namespace(mods[p], len(elems)-1, n.space)
+			if err != nil {
+				return err
+			}
+			name := elems[len(elems)-1]
+			if err := m.encodeField(n, name, ns, fv, fieldPath+"/"+name); err != nil {
+				return fmt.Errorf("%s: %w", f.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (m *marshaler) encodeField(parent *node, name, ns string, v reflect.Value, path string) error {
+	switch {
+	case v.Kind() == reflect.Pointer && v.Elem().Kind() == reflect.Struct:
+		return m.encodeEntry(parent, name, ns, v, path, "")
+	case v.Kind() == reflect.Map:
+		keys := v.MapKeys()
+		entries := make([]reflect.Value, len(keys))
+		preds := make([]string, len(keys))
+		for i, k := range keys {
+			entries[i] = v.MapIndex(k)
+			pred, err := listPredicate(entries[i])
+			if err != nil {
+				return err
+			}
+			preds[i] = pred
+		}
+		idx := make([]int, len(keys))
+		for i := range idx {
+			idx[i] = i
+		}
+		sort.Slice(idx, func(a, b int) bool { return preds[idx[a]] < preds[idx[b]] })
+		for _, i := range idx {
+			if err := m.encodeEntry(parent, name, ns, entries[i], path+preds[i], preds[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() != reflect.Uint8:
+		for i := 0; i < v.Len(); i++ {
+			if err := m.encodeField(parent, name, ns, v.Index(i), path); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	text, ok, err := formatLeaf(v)
+	if err != nil || !ok {
+		return err
+	}
+	n := &node{name: name, space: ns, text: text, hasText: true}
+	m.setOperation(n, path)
+	parent.children = append(parent.children, n)
+	return nil
+}
+
+// encodeEntry encodes a container or an entry of a list.
+func (m *marshaler) encodeEntry(parent *node, name, ns string, v reflect.Value, path, pred string) error {
+	n := &node{name: name, space: ns}
+	parent.children = append(parent.children, n)
+
+	op := m.setOperation(n, path)
+	if pred == "" {
+		return m.encodeStruct(n, v.Elem(), path, nil)
+	}
+
+	// the keys of a list entry are encoded first (RFC7950 section 7.8.5).
+	keys, err := encodeKeys(n, v)
+	if err != nil {
+		return err
+	}
+	if op == netconf.DeleteConfig || op == netconf.RemoveConfig {
+		// only the keys are needed to find the entry to delete.
+		return nil
+	}
+	return m.encodeStruct(n, v.Elem(), path, keys)
+}
+
+func (m *marshaler) setOperation(n *node, path string) netconf.MergeStrategy {
+	op, ok := m.ops[path]
+	if !ok {
+		return ""
+	}
+	m.usedOps[path] = true
+	n.attrs = append(n.attrs,
+		attr{"xmlns:nc", baseNamespace},
+		attr{"nc:operation", string(op)},
+	)
+	return op
+}
+
+// isUnset reports whether a field has no value to encode.
+func isUnset(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		return v.IsNil()
+	case reflect.Map, reflect.Slice:
+		return v.Len() == 0
+	case reflect.Bool:
+		// YANG empty leaves are only present when true.
+		return !v.Bool()
+	case reflect.Int64:
+		// enumerations start at 1 with 0 as unset.
+		return v.Int() == 0
+	}
+	return v.IsZero()
+}
+
+// formatLeaf returns the text of a leaf value.
+func formatLeaf(v reflect.Value) (string, bool, error) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return "", false, nil
+		}
+		v = v.Elem()
+	}
+
+	if v.Kind() == reflect.Int64 {
+		if s, ok := v.Interface().(fmt.Stringer); ok && hasEnumMap(v.Type()) {
+			return enumName(s.String()), true, nil
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), true, nil
+	case reflect.Bool:
+		if v.Type() != reflect.TypeOf(true) {
+			// YANGEmpty
+			return "", v.Bool(), nil
+		}
+		return strconv.FormatBool(v.Bool()), true, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), true, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), true, nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64), true, nil
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return base64.StdEncoding.EncodeToString(v.Bytes()), true, nil
+		}
+	case reflect.Struct:
+		// union wrappers have the value as their only field.
+		if v.NumField() == 1 {
+			return formatLeaf(v.Field(0))
+		}
+	}
+	return "", false, fmt.Errorf("unsupported leaf type %s", v.Type())
+}
+
+// enumName drops the module prefix ygot adds to identities.
+func enumName(s string) string {
+	if i := strings.LastIndexByte(s, ':'); i >= 0 {
+		return s[i+1:]
+	}
+	return s
+}
+
+// listKeys returns the key leaves of a list entry from its generated
+// ΛListKeyMap method.
+func listKeys(entry reflect.Value) (map[string]any, error) {
+	method := entry.MethodByName("ΛListKeyMap")
+	if !method.IsValid() {
+		return nil, fmt.Errorf("%s has no list keys", entry.Type())
+	}
+	out := method.Call(nil)
+	if err, _ := out[1].Interface().(error); err != nil {
+		return nil, err
+	}
+	return out[0].Interface().(map[string]any), nil
+}
+
+func sortedKeys(keys map[string]any) []string {
+	names := make([]string, 0, len(keys))
+	for name := range keys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// listPredicate returns the keys of an entry as path predicates (i.e
+// `[name=eth0]`) in the order of the key names.
+func listPredicate(entry reflect.Value) (string, error) {
+	keys, err := listKeys(entry)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	for _, name := range sortedKeys(keys) {
+		text, _, err := formatLeaf(reflect.ValueOf(keys[name]))
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&sb, "[%s=%s]", name, text)
+	}
+	return sb.String(), nil
+}
+
+// encodeKeys adds the key leaves of a list entry to n and returns their
+// names.
+func encodeKeys(n *node, entry reflect.Value) (map[string]bool, error) {
+	keys, err := listKeys(entry)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]bool, len(keys))
+	for _, name := range sortedKeys(keys) {
+		text, _, err := formatLeaf(reflect.ValueOf(keys[name]))
+		if err != nil {
+			return nil, err
+		}
+		n.children = append(n.children, &node{name: name, space: n.space, text: text, hasText: true})
+		names[name] = true
+	}
+	return names, nil
+}
+
+// normalizePath sorts the key predicates of each element of path so that
+// they match the order used while encoding.
+func normalizePath(path string) string {
+	var sb strings.Builder
+	for _, elem := range splitPath(path) {
+		name, preds, _ := strings.Cut(elem, "[")
+		sb.WriteString("/" + name)
+		if preds == "" {
+			continue
+		}
+		parts := strings.Split(strings.TrimSuffix(preds, "]"), "][")
+		for i, p := range parts {
+			k, v, _ := strings.Cut(p, "=")
+			parts[i] = strings.TrimSpace(k) + "=" + strings.Trim(strings.TrimSpace(v), `'"`)
+		}
+		sort.Strings(parts)
+		for _, p := range parts {
+			sb.WriteString("[" + p + "]")
+		}
+	}
+	return sb.String()
+}
+
+// splitPath splits a path on `/` outside of predicates.
+func splitPath(path string) []string {
+	var (
+		elems []string
+		depth int
+		start int
+	)
+	path = strings.TrimPrefix(path, "/")
+	for i, c := range path {
+		switch c {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case '/':
+			if depth == 0 {
+				elems = append(elems, path[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if start < len(path) {
+		elems = append(elems, path[start:])
+	}
+	return elems
+}
@@ -0,0 +1,215 @@
+package ygotutil
+
+import (
+	"testing"
+
+	"github.com/nemith/netconf"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The types below mimic the code ygot generates for a compressed schema.
+
+type enumDefinition struct {
+	Name           string
+	DefiningModule string
+}
+
+type E_AdminStatus int64
+
+const (
+	AdminStatus_UNSET E_AdminStatus = 0
+	AdminStatus_UP    E_AdminStatus = 1
+	AdminStatus_DOWN  E_AdminStatus = 2
+)
+
+func (E_AdminStatus) ΛMap() map[string]map[int64]enumDefinition {
+	return map[string]map[int64]enumDefinition{
+		"E_AdminStatus": {1: {Name: "UP"}, 2: {Name: "DOWN"}},
+	}
+}
+
+func (e E_AdminStatus) String() string {
+	if d, ok := e.ΛMap()["E_AdminStatus"][int64(e)]; ok {
+		return d.Name
+	}
+	return "out-of-range"
+}
+
+type E_InterfaceType int64
+
+const (
+	InterfaceType_ethernetCsmacd E_InterfaceType = 1
+)
+
+func (E_InterfaceType) ΛMap() map[string]map[int64]enumDefinition {
+	return map[string]map[int64]enumDefinition{
+		"E_InterfaceType": {1: {Name: "ethernetCsmacd", DefiningModule: "iana-if-type"}},
+	}
+}
+
+func (e E_InterfaceType) String() string {
+	return "iana-if-type:" + e.ΛMap()["E_InterfaceType"][int64(e)].Name
+}
+
+type YANGEmpty bool
+
+type Binary []byte
+
+type Device struct {
+	Interfaces *Interfaces `path:"interfaces" module:"openconfig-interfaces"`
+	System     *System     `path:"system" module:"openconfig-system"`
+}
+
+func (*Device) IsYANGGoStruct() {}
+
+type Interfaces struct {
+	Interface map[string]*Interface `path:"interface" module:"openconfig-interfaces"`
+}
+
+func (*Interfaces) IsYANGGoStruct() {}
+
+type Interface struct {
+	Name        *string         `path:"config/name|name" module:"openconfig-interfaces/openconfig-interfaces|openconfig-interfaces"`
+	Description *string         `path:"config/description" module:"openconfig-interfaces/openconfig-interfaces"`
+	Enabled     *bool           `path:"config/enabled" module:"openconfig-interfaces/openconfig-interfaces"`
+	Mtu         *uint16         `path:"config/mtu" module:"openconfig-interfaces/openconfig-interfaces"`
+	Type        E_InterfaceType `path:"config/type" module:"openconfig-interfaces/openconfig-interfaces"`
+	AdminStatus E_AdminStatus   `path:"state/admin-status" module:"openconfig-interfaces/openconfig-interfaces"`
+	Loopback    YANGEmpty       `path:"config/loopback-mode" module:"openconfig-interfaces/openconfig-interfaces"`
+}
+
+func (*Interface) IsYANGGoStruct() {}
+
+func (i *Interface) ΛListKeyMap() (map[string]any, error) {
+	return map[string]any{"name": *i.Name}, nil
+}
+
+type System struct {
+	Hostname   *string                            `path:"config/hostname" module:"openconfig-system/openconfig-system"`
+	DNSServers []string                           `path:"dns/config/search" module:"openconfig-system/openconfig-system/openconfig-system"`
+	Banner     Binary                             `path:"config/motd-banner" module:"openconfig-system/openconfig-system"`
+	Routes     map[System_Route_Key]*System_Route `path:"routes/route" module:"openconfig-system/openconfig-system"`
+}
+
+func (*System) IsYANGGoStruct() {}
+
+type System_Route_Key struct {
+	Prefix string `path:"prefix"`
+	VRF    string `path:"vrf"`
+}
+
+type System_Route struct {
+	Prefix  *string `path:"prefix" module:"openconfig-system"`
+	VRF     *string `path:"vrf" module:"openconfig-system"`
+	NextHop *string `path:"next-hop" module:"openconfig-system"`
+}
+
+func (*System_Route) IsYANGGoStruct() {}
+
+func (r *System_Route) ΛListKeyMap() (map[string]any, error) {
+	return map[string]any{"prefix": *r.Prefix, "vrf": *r.VRF}, nil
+}
+
+var namespaces = map[string]string{
+	"openconfig-interfaces": "http://openconfig.net/yang/interfaces",
+	"openconfig-system":     "http://openconfig.net/yang/system",
+}
+
+func ptr[T any](v T) *T { return &v }
+
+func testDevice() *Device {
+	return &Device{
+		Interfaces: &Interfaces{Interface: map[string]*Interface{
+			"eth1": {Name: ptr("eth1"), Enabled: ptr(false)},
+			"eth0": {
+				Name:        ptr("eth0"),
+				Description: ptr("uplink & more"),
+				Enabled:     ptr(true),
+				Mtu:         ptr(uint16(9000)),
+				Type:        InterfaceType_ethernetCsmacd,
+				Loopback:    true,
+			},
+		}},
+		System: &System{
+			Hostname:   ptr("router1"),
+			DNSServers: []string{"example.com", "example.net"},
+			Banner:     Binary("hello"),
+			Routes: map[System_Route_Key]*System_Route{
+				{Prefix: "10.0.0.0/8", VRF: "default"}: {Prefix: ptr("10.0.0.0/8"), VRF: ptr("default"), NextHop: ptr("192.0.2.1")},
+			},
+		},
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	got, err := Marshal(testDevice(), WithNamespaces(namespaces))
+	require.NoError(t, err)
+	assert.Equal(t, `<interfaces xmlns="http://openconfig.net/yang/interfaces">`+
+		`<interface><name>eth0</name><config><name>eth0</name><description>uplink &amp; more</description><enabled>true</enabled>`+
+		`<mtu>9000</mtu><type>ethernetCsmacd</type><loopback-mode/></config></interface>`+
+		`<interface><name>eth1</name><config><name>eth1</name><enabled>false</enabled></config></interface>`+
+		`</interfaces>`+
+		`<system xmlns="http://openconfig.net/yang/system">`+
+		`<config><hostname>router1</hostname><motd-banner>aGVsbG8=</motd-banner></config>`+
+		`<dns><config><search>example.com</search><search>example.net</search></config></dns>`+
+		`<routes><route><prefix>10.0.0.0/8</prefix><vrf>default</vrf><next-hop>192.0.2.1</next-hop></route></routes>`+
+		`</system>`, string(got))
+}
+
+func TestMarshalOperations(t *testing.T) {
+	got, err := Marshal(testDevice(),
+		WithNamespaces(namespaces),
+		WithOperation("/interfaces/interface[name=eth1]", netconf.DeleteConfig),
+		WithOperation("/system/routes/route[vrf=default][prefix=10.0.0.0/8]", netconf.ReplaceConfig),
+		WithOperation("/system/config/hostname", netconf.MergeConfig),
+	)
+	require.NoError(t, err)
+
+	s := string(got)
+	assert.Contains(t, s, `<interface xmlns:nc="urn:ietf:params:xml:ns:netconf:base:1.0" nc:operation="delete"><name>eth1</name></interface>`)
+	assert.Contains(t, s, `<route xmlns:nc="urn:ietf:params:xml:ns:netconf:base:1.0" nc:operation="replace"><prefix>10.0.0.0/8</prefix><vrf>default</vrf><next-hop>`)
+	assert.Contains(t, s, `<hostname xmlns:nc="urn:ietf:params:xml:ns:netconf:base:1.0" nc:operation="merge">router1</hostname>`)
+
+	_, err = Marshal(testDevice(),
+		WithNamespaces(namespaces),
+		WithOperation("/interfaces/interface[name=eth9]", netconf.DeleteConfig))
+	assert.ErrorContains(t, err, "no node for operation")
+}
+
+func TestMarshalErrors(t *testing.T) {
+	_, err := Marshal(testDevice())
+	assert.ErrorContains(t, err, `no namespace for module "openconfig-interfaces"`)
+
+	_, err = Marshal((*Device)(nil))
+	assert.Error(t, err)
+}
+
+func TestUnmarshal(t *testing.T) {
+	data, err := Marshal(testDevice(), WithNamespaces(namespaces))
+	require.NoError(t, err)
+
+	// replies carry state and elements of other modules.
+	reply := `<data xmlns="urn:ietf:params:xml:ns:netconf:base:1.0">` + string(data) +
+		`<interfaces xmlns="http://openconfig.net/yang/interfaces"><interface><name>eth0</name>` +
+		`<state><admin-status>UP</admin-status></state></interface></interfaces>` +
+		`<unknown xmlns="urn:example"><leaf>1</leaf></unknown></data>`
+
+	var got Device
+	require.NoError(t, Unmarshal([]byte(reply), &got))
+
+	want := testDevice()
+	want.Interfaces.Interface["eth0"].AdminStatus = AdminStatus_UP
+	assert.Equal(t, want, &got)
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	var d Device
+	err := Unmarshal([]byte(`<interfaces><interface><name>eth0</name><config><mtu>big</mtu></config></interface></interfaces>`), &d)
+	assert.ErrorContains(t, err, "Mtu")
+
+	err = Unmarshal([]byte(`<interfaces><interface><name>eth0</name><config><type>bogus</type></config></interface></interfaces>`), &d)
+	assert.ErrorContains(t, err, `invalid value "bogus"`)
+
+	assert.Error(t, Unmarshal([]byte(`<interfaces>`), &d))
+}